@@ -0,0 +1,97 @@
+// Browser side of the passkey ceremonies. The server starts a ceremony by
+// executing passkeys.register or passkeys.signIn with the options it
+// generated. The authenticator response is handed back to datastar through a
+// "passkey-complete" event on the target element, whose data-on handler
+// posts it to the server. Failures are reported with a "passkey-error" event.
+
+function decode(value) {
+	const base64 = value.replace(/-/g, "+").replace(/_/g, "/")
+	const padded = base64.padEnd(base64.length + ((4 - (base64.length % 4)) % 4), "=")
+	const binary = atob(padded)
+	const bytes = new Uint8Array(binary.length)
+	for (let i = 0; i < binary.length; i++) {
+		bytes[i] = binary.charCodeAt(i)
+	}
+	return bytes.buffer
+}
+
+function encode(buffer) {
+	if (!buffer) {
+		return undefined
+	}
+	const bytes = new Uint8Array(buffer)
+	let binary = ""
+	for (const byte of bytes) {
+		binary += String.fromCharCode(byte)
+	}
+	return btoa(binary).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "")
+}
+
+function decodeDescriptors(descriptors) {
+	return (descriptors || []).map((descriptor) => ({ ...descriptor, id: decode(descriptor.id) }))
+}
+
+function dispatch(targetID, name, detail) {
+	const target = document.getElementById(targetID)
+	if (target) {
+		target.dispatchEvent(new CustomEvent(name, { detail }))
+	}
+}
+
+async function register(options, targetID) {
+	try {
+		const publicKey = {
+			...options.publicKey,
+			challenge: decode(options.publicKey.challenge),
+			user: { ...options.publicKey.user, id: decode(options.publicKey.user.id) },
+			excludeCredentials: decodeDescriptors(options.publicKey.excludeCredentials),
+		}
+
+		const credential = await navigator.credentials.create({ publicKey })
+
+		dispatch(targetID, "passkey-complete", {
+			id: credential.id,
+			rawId: encode(credential.rawId),
+			type: credential.type,
+			authenticatorAttachment: credential.authenticatorAttachment,
+			clientExtensionResults: credential.getClientExtensionResults(),
+			response: {
+				clientDataJSON: encode(credential.response.clientDataJSON),
+				attestationObject: encode(credential.response.attestationObject),
+				transports: credential.response.getTransports ? credential.response.getTransports() : [],
+			},
+		})
+	} catch (err) {
+		dispatch(targetID, "passkey-error", err.message)
+	}
+}
+
+async function signIn(options, targetID) {
+	try {
+		const publicKey = {
+			...options.publicKey,
+			challenge: decode(options.publicKey.challenge),
+			allowCredentials: decodeDescriptors(options.publicKey.allowCredentials),
+		}
+
+		const credential = await navigator.credentials.get({ publicKey })
+
+		dispatch(targetID, "passkey-complete", {
+			id: credential.id,
+			rawId: encode(credential.rawId),
+			type: credential.type,
+			authenticatorAttachment: credential.authenticatorAttachment,
+			clientExtensionResults: credential.getClientExtensionResults(),
+			response: {
+				clientDataJSON: encode(credential.response.clientDataJSON),
+				authenticatorData: encode(credential.response.authenticatorData),
+				signature: encode(credential.response.signature),
+				userHandle: encode(credential.response.userHandle),
+			},
+		})
+	} catch (err) {
+		dispatch(targetID, "passkey-error", err.message)
+	}
+}
+
+window.passkeys = { register, signIn }
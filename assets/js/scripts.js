@@ -1,2 +1,3 @@
 import "./datastar_1-0-0-rc6.min.js"
 import "./basecoat_0-3-10-beta.2.min.js"
+import "./passkeys.js"
@@ -0,0 +1,148 @@
+package mailclients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"mbvlabs/email"
+)
+
+var _ email.TransactionalSender = (*Failover)(nil)
+var _ email.MarketingSender = (*Failover)(nil)
+
+var ErrNoProviders = errors.New("no email providers configured")
+
+// Sender is a delivery backend that sends both kinds of email.
+type Sender interface {
+	email.TransactionalSender
+	email.MarketingSender
+}
+
+type FailoverProvider struct {
+	// Name identifies the provider in errors.
+	Name   string
+	Sender Sender
+}
+
+// Failover delivers through the first of its providers that takes the
+// message. A provider that fails with a temporary error is marked unhealthy
+// and the next one is tried; for the cooldown that follows, unhealthy
+// providers are only tried after the healthy ones. A permanent error ends
+// the attempt, as no other provider would take the message either. It is
+// safe for concurrent use.
+type Failover struct {
+	providers []FailoverProvider
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu             sync.Mutex
+	unhealthyUntil []time.Time
+}
+
+func NewFailover(cooldown time.Duration, providers ...FailoverProvider) *Failover {
+	return &Failover{
+		providers:      providers,
+		cooldown:       cooldown,
+		now:            time.Now,
+		unhealthyUntil: make([]time.Time, len(providers)),
+	}
+}
+
+func (f *Failover) SendTransactional(ctx context.Context, payload email.TransactionalPayload) (string, error) {
+	return f.send(ctx, func(sender Sender) (string, error) {
+		return sender.SendTransactional(ctx, payload)
+	})
+}
+
+func (f *Failover) SendMarketing(ctx context.Context, payload email.MarketingPayload) (string, error) {
+	return f.send(ctx, func(sender Sender) (string, error) {
+		return sender.SendMarketing(ctx, payload)
+	})
+}
+
+// Shutdown shuts down the providers that hold resources, such as pooled
+// SMTP connections.
+func (f *Failover) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, provider := range f.providers {
+		shutdowner, ok := provider.Sender.(interface {
+			Shutdown(ctx context.Context) error
+		})
+		if !ok {
+			continue
+		}
+
+		if err := shutdowner.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (f *Failover) send(ctx context.Context, deliver func(sender Sender) (string, error)) (string, error) {
+	if len(f.providers) == 0 {
+		return "", email.TemporaryError{Err: ErrNoProviders}
+	}
+
+	var errs []error
+	for _, i := range f.order() {
+		provider := f.providers[i]
+
+		messageID, err := deliver(provider.Sender)
+		if err == nil {
+			f.setUnhealthyUntil(i, time.Time{})
+			return messageID, nil
+		}
+
+		if !email.IsRetryable(err) {
+			return "", fmt.Errorf("%s: %w", provider.Name, err)
+		}
+
+		// A cancelled or expired context fails every provider alike and
+		// says nothing about this one.
+		if ctx.Err() != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Name, err))
+			break
+		}
+
+		f.setUnhealthyUntil(i, f.now().Add(f.cooldown))
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name, err))
+	}
+
+	return "", email.TemporaryError{Err: errors.Join(errs...)}
+}
+
+// order lists the providers to try: the healthy ones as configured, then
+// the unhealthy ones, those closest to the end of their cooldown first.
+func (f *Failover) order() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+	var healthy, unhealthy []int
+	for i := range f.providers {
+		if now.Before(f.unhealthyUntil[i]) {
+			unhealthy = append(unhealthy, i)
+		} else {
+			healthy = append(healthy, i)
+		}
+	}
+
+	slices.SortStableFunc(unhealthy, func(a, b int) int {
+		return f.unhealthyUntil[a].Compare(f.unhealthyUntil[b])
+	})
+
+	return append(healthy, unhealthy...)
+}
+
+func (f *Failover) setUnhealthyUntil(i int, until time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.unhealthyUntil[i] = until
+}
@@ -0,0 +1,170 @@
+package mailclients
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"mbvlabs/email"
+)
+
+var failoverPayload = email.TransactionalPayload{
+	To:       "jane@example.com",
+	From:     "noreply@example.com",
+	Subject:  "Your Sign-in Link",
+	TextBody: "Sign in",
+}
+
+// newTestFailover puts Postmark first and SendGrid second, each against its
+// stand-in, on a clock the test moves.
+func newTestFailover(t *testing.T) (*Failover, *fakePostmark, *fakeSendGrid, *time.Time) {
+	t.Helper()
+
+	postmark := newFakePostmark(t)
+	sendGrid := newFakeSendGrid(t)
+
+	now := time.Date(2026, time.March, 14, 9, 0, 0, 0, time.UTC)
+	failover := NewFailover(time.Minute,
+		FailoverProvider{Name: "postmark", Sender: postmark.client()},
+		FailoverProvider{Name: "sendgrid", Sender: sendGrid.client()},
+	)
+	failover.now = func() time.Time { return now }
+
+	return failover, postmark, sendGrid, &now
+}
+
+func TestFailoverUsesFirstHealthyProvider(t *testing.T) {
+	failover, postmark, sendGrid, _ := newTestFailover(t)
+
+	id, err := failover.SendTransactional(context.Background(), failoverPayload)
+	if err != nil || id != "pm-1" {
+		t.Fatalf("SendTransactional = %q, %v, want pm-1", id, err)
+	}
+
+	if _, messages := postmark.received(); len(messages) != 1 {
+		t.Errorf("Postmark received %d messages, want 1", len(messages))
+	}
+	if n := len(sendGrid.received()); n != 0 {
+		t.Errorf("SendGrid received %d requests, want none", n)
+	}
+}
+
+func TestFailoverOnTemporaryError(t *testing.T) {
+	failover, postmark, sendGrid, now := newTestFailover(t)
+	down := true
+	postmark.reply = func(postmarkMessage) (int, postmarkReply) {
+		if down {
+			return http.StatusServiceUnavailable, postmarkReply{Message: "down"}
+		}
+		return http.StatusOK, postmarkReply{MessageID: "pm-recovered"}
+	}
+
+	id, err := failover.SendTransactional(context.Background(), failoverPayload)
+	if err != nil || id != "sg-1" {
+		t.Fatalf("SendTransactional = %q, %v, want SendGrid's sg-1", id, err)
+	}
+
+	// Postmark is skipped while it cools down.
+	id, err = failover.SendTransactional(context.Background(), failoverPayload)
+	if err != nil || id != "sg-2" {
+		t.Fatalf("second SendTransactional = %q, %v, want sg-2", id, err)
+	}
+	if _, messages := postmark.received(); len(messages) != 1 {
+		t.Errorf("Postmark was tried %d times during its cooldown, want once", len(messages))
+	}
+
+	// After the cooldown it is first again.
+	down = false
+	*now = now.Add(time.Minute)
+
+	id, err = failover.SendTransactional(context.Background(), failoverPayload)
+	if err != nil || id != "pm-recovered" {
+		t.Fatalf("SendTransactional after the cooldown = %q, %v, want pm-recovered", id, err)
+	}
+	if n := len(sendGrid.received()); n != 2 {
+		t.Errorf("SendGrid received %d requests, want 2", n)
+	}
+}
+
+func TestFailoverTriesUnhealthyProvidersLast(t *testing.T) {
+	failover, postmark, sendGrid, _ := newTestFailover(t)
+	postmark.reply = func(postmarkMessage) (int, postmarkReply) {
+		return http.StatusOK, postmarkReply{ErrorCode: 100, Message: "maintenance"}
+	}
+	sendGrid.reply = func(sendGridMessage) (int, any) {
+		return http.StatusTooManyRequests, nil
+	}
+
+	_, err := failover.SendTransactional(context.Background(), failoverPayload)
+	if !errors.As(err, new(email.TemporaryError)) {
+		t.Fatalf("SendTransactional with every provider down = %v, want a TemporaryError", err)
+	}
+
+	var apiErr APIError
+	if !errors.As(err, &apiErr) {
+		t.Errorf("error %v does not carry the providers' errors", err)
+	}
+
+	// Both are unhealthy; Postmark's cooldown ends first, so it is still
+	// tried first rather than not at all.
+	postmark.reply = nil
+	id, err := failover.SendTransactional(context.Background(), failoverPayload)
+	if err != nil || id == "" {
+		t.Fatalf("SendTransactional = %q, %v, want Postmark to deliver", id, err)
+	}
+}
+
+func TestFailoverStopsOnPermanentError(t *testing.T) {
+	failover, postmark, sendGrid, _ := newTestFailover(t)
+	postmark.reply = func(postmarkMessage) (int, postmarkReply) {
+		return http.StatusUnprocessableEntity, postmarkReply{ErrorCode: 406, Message: "Inactive recipient"}
+	}
+
+	_, err := failover.SendTransactional(context.Background(), failoverPayload)
+	if !errors.As(err, new(email.PermanentError)) {
+		t.Fatalf("SendTransactional = %v, want a PermanentError", err)
+	}
+	if n := len(sendGrid.received()); n != 0 {
+		t.Errorf("SendGrid received %d requests after a permanent error, want none", n)
+	}
+
+	// A permanent error says nothing about the provider's health.
+	postmark.reply = nil
+	if id, err := failover.SendTransactional(context.Background(), failoverPayload); err != nil || id != "pm-2" {
+		t.Errorf("next SendTransactional = %q, %v, want Postmark's pm-2", id, err)
+	}
+}
+
+func TestFailoverCancelledContext(t *testing.T) {
+	failover, postmark, sendGrid, _ := newTestFailover(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := failover.SendMarketing(ctx, email.MarketingPayload{
+		To: []string{"a@example.com"}, From: "news@example.com", Subject: "Hi", HTMLBody: "Hi",
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("SendMarketing = %v, want context.Canceled", err)
+	}
+	if n := len(sendGrid.received()); n != 0 {
+		t.Errorf("SendGrid was tried after the context was cancelled")
+	}
+
+	// Postmark was not marked unhealthy by the cancellation.
+	if id, err := failover.SendTransactional(context.Background(), failoverPayload); err != nil || id != "pm-1" {
+		t.Errorf("SendTransactional = %q, %v, want Postmark's pm-1", id, err)
+	}
+	if requests, _ := postmark.received(); len(requests) != 1 {
+		t.Errorf("Postmark received %d requests, want 1", len(requests))
+	}
+}
+
+func TestFailoverWithoutProviders(t *testing.T) {
+	_, err := NewFailover(time.Minute).SendTransactional(context.Background(), failoverPayload)
+	if !errors.Is(err, ErrNoProviders) || !email.IsRetryable(err) {
+		t.Errorf("SendTransactional = %v, want a TemporaryError wrapping ErrNoProviders", err)
+	}
+}
@@ -0,0 +1,144 @@
+package mailclients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"mbvlabs/email"
+)
+
+// maxResponseSize caps how much of a provider's response is read. Replies
+// are small JSON documents; anything larger is not worth holding in memory.
+const maxResponseSize = 1 << 20
+
+const defaultHTTPTimeout = 30 * time.Second
+
+// APIError is a request a provider's HTTP API answered with an error. Code
+// is the provider's own error code, where it has one.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Code       int
+	Message    string
+}
+
+func (e APIError) Error() string {
+	if e.Code != 0 {
+		return fmt.Sprintf("%s: status %d, error %d: %s", e.Provider, e.StatusCode, e.Code, e.Message)
+	}
+
+	return fmt.Sprintf("%s: status %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// classifyHTTPStatus wraps err by what the status says about retrying.
+// Rate limits and server errors pass with time. So do rejected
+// credentials: they say nothing about the message, which another provider
+// may well deliver. Any other 4xx means the provider will never take the
+// message as it is.
+func classifyHTTPStatus(status int, err error) error {
+	switch {
+	case status == http.StatusRequestTimeout,
+		status == http.StatusTooManyRequests,
+		status == http.StatusUnauthorized,
+		status == http.StatusForbidden,
+		status >= 500:
+		return email.TemporaryError{Err: err}
+	default:
+		return email.PermanentError{Err: err}
+	}
+}
+
+// apiResponse is what a provider answered.
+type apiResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func (r apiResponse) ok() bool {
+	return r.status >= 200 && r.status < 300
+}
+
+// postJSON sends body to url. Any answer comes back for the caller to
+// interpret; only failing to get one is an error, a TemporaryError.
+func postJSON(
+	ctx context.Context,
+	client *http.Client,
+	url string,
+	header http.Header,
+	body any,
+) (apiResponse, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return apiResponse{}, email.ValidationError{Err: err}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return apiResponse{}, err
+	}
+	req.Header = header.Clone()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return apiResponse{}, email.TemporaryError{Err: err}
+	}
+	defer res.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+	if err != nil {
+		return apiResponse{}, email.TemporaryError{Err: err}
+	}
+
+	return apiResponse{res.StatusCode, res.Header, raw}, nil
+}
+
+func parseAddress(raw string) (*mail.Address, error) {
+	address, err := mail.ParseAddress(raw)
+	if err != nil {
+		return nil, email.ValidationError{Err: fmt.Errorf("%w %q: %w", email.ErrInvalidAddress, raw, err)}
+	}
+
+	return address, nil
+}
+
+// sendInBatches hands recipients to send at most size at a time. Once a
+// batch is out, a failure in a later one is permanent: retrying the message
+// would send the earlier batches again.
+func sendInBatches(recipients []string, size int, send func(recipients []string) error) error {
+	for start := 0; start < len(recipients); start += size {
+		batch := recipients[start:min(start+size, len(recipients))]
+		if err := send(batch); err != nil {
+			if start == 0 {
+				return err
+			}
+
+			return partialDelivery(start, len(recipients), err)
+		}
+	}
+
+	return nil
+}
+
+// partialDelivery is the permanent error for a message that reached some
+// of its recipients. err loses any TemporaryError wrapping, which would
+// otherwise make the whole message retryable.
+func partialDelivery(delivered int, total int, err error) error {
+	var temporary email.TemporaryError
+	if errors.As(err, &temporary) {
+		err = temporary.Err
+	}
+
+	return email.PermanentError{
+		Err: fmt.Errorf("delivered to %d of %d recipients, not retrying: %w", delivered, total, err),
+	}
+}
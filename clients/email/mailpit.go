@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"net/smtp"
-	"strings"
 
 	"mbvlabs/email"
 )
@@ -24,115 +23,44 @@ func NewMailpit(host, port string) *Mailpit {
 	}
 }
 
-func (m *Mailpit) SendTransactional(ctx context.Context, payload email.TransactionalPayload) error {
+func (m *Mailpit) SendTransactional(ctx context.Context, payload email.TransactionalPayload) (string, error) {
 	addr := fmt.Sprintf("%s:%s", m.host, m.port)
 
-	boundary := "boundary-mailpit-client"
-	headers := make(map[string]string)
-	headers["From"] = payload.From
-	headers["To"] = payload.To
-
-	if len(payload.Cc) > 0 {
-		headers["Cc"] = strings.Join(payload.Cc, ", ")
-	}
-
-	if len(payload.Bcc) > 0 {
-		headers["Bcc"] = strings.Join(payload.Bcc, ", ")
-	}
-
-	if payload.ReplyTo != "" {
-		headers["Reply-To"] = payload.ReplyTo
-	}
-
-	headers["Subject"] = payload.Subject
-	headers["MIME-Version"] = "1.0"
-	headers["Content-Type"] = fmt.Sprintf("multipart/alternative; boundary=\"%s\"", boundary)
-
-	var message strings.Builder
-	for k, v := range headers {
-		message.WriteString(fmt.Sprintf("%s: %s\r\n", k, v))
+	message, err := newRawMessage(email.NewTransactionalMessage(payload))
+	if err != nil {
+		return "", err
 	}
-	message.WriteString("\r\n")
 
-	if payload.TextBody != "" {
-		message.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-		message.WriteString("Content-Type: text/plain; charset=\"UTF-8\"\r\n")
-		message.WriteString("\r\n")
-		message.WriteString(payload.TextBody)
-		message.WriteString("\r\n")
-	}
-
-	if payload.HTMLBody != "" {
-		message.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-		message.WriteString("Content-Type: text/html; charset=\"UTF-8\"\r\n")
-		message.WriteString("\r\n")
-		message.WriteString(payload.HTMLBody)
-		message.WriteString("\r\n")
-	}
-
-	message.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
-
-	recipients := []string{payload.To}
-	recipients = append(recipients, payload.Cc...)
-	recipients = append(recipients, payload.Bcc...)
-
-	return smtp.SendMail(
+	if err := smtp.SendMail(
 		addr,
 		nil,
-		payload.From,
-		recipients,
-		[]byte(message.String()),
-	)
-}
-
-func (m *Mailpit) SendMarketing(ctx context.Context, payload email.MarketingPayload) error {
-	addr := fmt.Sprintf("%s:%s", m.host, m.port)
-
-	boundary := "boundary-mailpit-client"
-	headers := make(map[string]string)
-	headers["From"] = payload.From
-
-	if len(payload.To) > 0 {
-		headers["To"] = strings.Join(payload.To, ", ")
+		message.from,
+		message.recipients,
+		message.data,
+	); err != nil {
+		return "", err
 	}
 
-	if payload.ReplyTo != "" {
-		headers["Reply-To"] = payload.ReplyTo
-	}
-
-	headers["Subject"] = payload.Subject
-	headers["MIME-Version"] = "1.0"
-	headers["Content-Type"] = fmt.Sprintf("multipart/alternative; boundary=\"%s\"", boundary)
-
-	var message strings.Builder
-	for k, v := range headers {
-		message.WriteString(fmt.Sprintf("%s: %s\r\n", k, v))
-	}
-	message.WriteString("\r\n")
+	return message.id, nil
+}
 
-	if payload.TextBody != "" {
-		message.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-		message.WriteString("Content-Type: text/plain; charset=\"UTF-8\"\r\n")
-		message.WriteString("\r\n")
-		message.WriteString(payload.TextBody)
-		message.WriteString("\r\n")
-	}
+func (m *Mailpit) SendMarketing(ctx context.Context, payload email.MarketingPayload) (string, error) {
+	addr := fmt.Sprintf("%s:%s", m.host, m.port)
 
-	if payload.HTMLBody != "" {
-		message.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-		message.WriteString("Content-Type: text/html; charset=\"UTF-8\"\r\n")
-		message.WriteString("\r\n")
-		message.WriteString(payload.HTMLBody)
-		message.WriteString("\r\n")
+	message, err := newRawMessage(email.NewMarketingMessage(payload))
+	if err != nil {
+		return "", err
 	}
 
-	message.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
-
-	return smtp.SendMail(
+	if err := smtp.SendMail(
 		addr,
 		nil,
-		payload.From,
-		payload.To,
-		[]byte(message.String()),
-	)
+		message.from,
+		message.recipients,
+		message.data,
+	); err != nil {
+		return "", err
+	}
+
+	return message.id, nil
 }
@@ -0,0 +1,40 @@
+package mailclients
+
+import (
+	"mbvlabs/email"
+)
+
+// rawMessage is a message written as MIME together with its SMTP envelope
+// and Message-ID.
+type rawMessage struct {
+	id         string
+	from       string
+	recipients []string
+	data       []byte
+}
+
+func newRawMessage(message email.Message) (rawMessage, error) {
+	from, err := message.Sender()
+	if err != nil {
+		return rawMessage{}, err
+	}
+
+	if message.MessageID == "" {
+		message.MessageID, err = email.NewMessageID(from)
+		if err != nil {
+			return rawMessage{}, err
+		}
+	}
+
+	recipients, err := message.Recipients()
+	if err != nil {
+		return rawMessage{}, err
+	}
+
+	data, err := message.Bytes()
+	if err != nil {
+		return rawMessage{}, err
+	}
+
+	return rawMessage{message.MessageID, from, recipients, data}, nil
+}
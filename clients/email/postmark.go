@@ -0,0 +1,262 @@
+package mailclients
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"mbvlabs/email"
+)
+
+var _ email.TransactionalSender = (*Postmark)(nil)
+var _ email.MarketingSender = (*Postmark)(nil)
+
+// postmarkBatchSize is the most messages the batch endpoint takes at once.
+const postmarkBatchSize = 500
+
+// Postmark error codes that say nothing about the message itself: a bad
+// server token, maintenance, an account out of credits and one still
+// pending approval. Another provider, or a later attempt, can deliver.
+var postmarkTemporaryCodes = map[int]bool{
+	10:  true,
+	100: true,
+	405: true,
+	412: true,
+}
+
+type PostmarkConfig struct {
+	ServerToken string
+	// BaseURL defaults to https://api.postmarkapp.com.
+	BaseURL string
+	// MessageStream carries transactional email and defaults to
+	// "outbound". BroadcastStream carries marketing email and defaults to
+	// "broadcast".
+	MessageStream   string
+	BroadcastStream string
+	// HTTPClient defaults to a client with a 30 second timeout.
+	HTTPClient *http.Client
+}
+
+// Postmark delivers through the Postmark HTTP API.
+type Postmark struct {
+	cfg PostmarkConfig
+}
+
+func NewPostmark(cfg PostmarkConfig) *Postmark {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.postmarkapp.com"
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	if cfg.MessageStream == "" {
+		cfg.MessageStream = "outbound"
+	}
+	if cfg.BroadcastStream == "" {
+		cfg.BroadcastStream = "broadcast"
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: defaultHTTPTimeout}
+	}
+
+	return &Postmark{cfg: cfg}
+}
+
+type postmarkMessage struct {
+	From          string               `json:"From"`
+	To            string               `json:"To"`
+	Cc            string               `json:"Cc,omitempty"`
+	Bcc           string               `json:"Bcc,omitempty"`
+	ReplyTo       string               `json:"ReplyTo,omitempty"`
+	Subject       string               `json:"Subject"`
+	HTMLBody      string               `json:"HtmlBody,omitempty"`
+	TextBody      string               `json:"TextBody,omitempty"`
+	Tag           string               `json:"Tag,omitempty"`
+	Headers       []postmarkHeader     `json:"Headers,omitempty"`
+	TrackOpens    bool                 `json:"TrackOpens,omitempty"`
+	TrackLinks    string               `json:"TrackLinks,omitempty"`
+	Metadata      map[string]string    `json:"Metadata,omitempty"`
+	Attachments   []postmarkAttachment `json:"Attachments,omitempty"`
+	MessageStream string               `json:"MessageStream"`
+}
+
+type postmarkHeader struct {
+	Name  string `json:"Name"`
+	Value string `json:"Value"`
+}
+
+type postmarkAttachment struct {
+	Name        string `json:"Name"`
+	Content     string `json:"Content"`
+	ContentType string `json:"ContentType"`
+	ContentID   string `json:"ContentID,omitempty"`
+}
+
+type postmarkReply struct {
+	ErrorCode int    `json:"ErrorCode"`
+	Message   string `json:"Message"`
+	MessageID string `json:"MessageID"`
+}
+
+func (p *Postmark) SendTransactional(ctx context.Context, payload email.TransactionalPayload) (string, error) {
+	message := postmarkMessage{
+		From:          payload.From,
+		To:            payload.To,
+		Cc:            strings.Join(payload.Cc, ", "),
+		Bcc:           strings.Join(payload.Bcc, ", "),
+		ReplyTo:       payload.ReplyTo,
+		Subject:       payload.Subject,
+		HTMLBody:      payload.HTMLBody,
+		TextBody:      payload.TextBody,
+		Metadata:      payload.Metadata,
+		MessageStream: p.cfg.MessageStream,
+	}
+
+	for _, attachment := range payload.Attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		var contentID string
+		if attachment.Inline {
+			contentID = "cid:" + attachment.Name
+		}
+
+		message.Attachments = append(message.Attachments, postmarkAttachment{
+			Name:        attachment.Name,
+			Content:     base64.StdEncoding.EncodeToString(attachment.Content),
+			ContentType: contentType,
+			ContentID:   contentID,
+		})
+	}
+
+	res, err := p.post(ctx, "/email", message)
+	if err != nil {
+		return "", err
+	}
+
+	var reply postmarkReply
+	_ = json.Unmarshal(res.body, &reply)
+	if !res.ok() || reply.ErrorCode != 0 {
+		return "", p.classify(res.status, reply)
+	}
+
+	return reply.MessageID, nil
+}
+
+// SendMarketing sends each recipient a message of their own, so no one sees
+// who else got it, through the batch endpoint. It returns the id of the
+// first message. Postmark keeps one tag per message, the first of
+// payload.Tags.
+func (p *Postmark) SendMarketing(ctx context.Context, payload email.MarketingPayload) (string, error) {
+	if len(payload.To) == 0 {
+		return "", email.ValidationError{Err: email.ErrMissingRecipient}
+	}
+
+	trackLinks := "None"
+	if payload.TrackClicks {
+		trackLinks = "HtmlAndText"
+	}
+
+	var tag string
+	if len(payload.Tags) > 0 {
+		tag = payload.Tags[0]
+	}
+
+	var firstMessageID string
+	err := sendInBatches(payload.To, postmarkBatchSize, func(recipients []string) error {
+		messages := make([]postmarkMessage, len(recipients))
+		for i, to := range recipients {
+			messages[i] = postmarkMessage{
+				From:     payload.From,
+				To:       to,
+				ReplyTo:  payload.ReplyTo,
+				Subject:  payload.Subject,
+				HTMLBody: payload.HTMLBody,
+				TextBody: payload.TextBody,
+				Tag:      tag,
+				Headers: []postmarkHeader{
+					{Name: "List-Unsubscribe", Value: "<" + payload.UnsubscribeURL + ">"},
+				},
+				TrackOpens:    payload.TrackOpens,
+				TrackLinks:    trackLinks,
+				Metadata:      payload.Metadata,
+				MessageStream: p.cfg.BroadcastStream,
+			}
+		}
+
+		res, err := p.post(ctx, "/email/batch", messages)
+		if err != nil {
+			return err
+		}
+
+		if !res.ok() {
+			var reply postmarkReply
+			_ = json.Unmarshal(res.body, &reply)
+			return p.classify(res.status, reply)
+		}
+
+		var replies []postmarkReply
+		if err := json.Unmarshal(res.body, &replies); err != nil {
+			return email.TemporaryError{Err: err}
+		}
+
+		// The batch is accepted as a whole but each message can still be
+		// refused on its own.
+		var failures []error
+		var first postmarkReply
+		for _, reply := range replies {
+			if reply.ErrorCode == 0 {
+				if firstMessageID == "" {
+					firstMessageID = reply.MessageID
+				}
+				continue
+			}
+			if len(failures) == 0 {
+				first = reply
+			}
+			failures = append(failures, p.apiError(http.StatusUnprocessableEntity, reply))
+		}
+
+		switch len(failures) {
+		case 0:
+			return nil
+		case len(replies):
+			return p.classify(http.StatusUnprocessableEntity, first)
+		default:
+			return partialDelivery(len(replies)-len(failures), len(replies), errors.Join(failures...))
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return firstMessageID, nil
+}
+
+func (p *Postmark) post(ctx context.Context, path string, body any) (apiResponse, error) {
+	header := http.Header{}
+	header.Set("X-Postmark-Server-Token", p.cfg.ServerToken)
+
+	return postJSON(ctx, p.cfg.HTTPClient, p.cfg.BaseURL+path, header, body)
+}
+
+func (p *Postmark) apiError(status int, reply postmarkReply) APIError {
+	return APIError{
+		Provider:   "postmark",
+		StatusCode: status,
+		Code:       reply.ErrorCode,
+		Message:    reply.Message,
+	}
+}
+
+func (p *Postmark) classify(status int, reply postmarkReply) error {
+	err := p.apiError(status, reply)
+	if postmarkTemporaryCodes[reply.ErrorCode] {
+		return email.TemporaryError{Err: err}
+	}
+
+	return classifyHTTPStatus(status, err)
+}
@@ -0,0 +1,358 @@
+package mailclients
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"mbvlabs/email"
+)
+
+const fakePostmarkToken = "server-token"
+
+// fakePostmark stands in for the Postmark API. It checks the server token,
+// records every message it is sent and answers as reply says.
+type fakePostmark struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	requests []string
+	messages []postmarkMessage
+	// reply answers a message; nil accepts everything.
+	reply func(message postmarkMessage) (status int, body postmarkReply)
+}
+
+func newFakePostmark(t *testing.T) *fakePostmark {
+	t.Helper()
+
+	f := &fakePostmark{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /email", f.single)
+	mux.HandleFunc("POST /email/batch", f.batch)
+
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+
+	return f
+}
+
+func (f *fakePostmark) client() *Postmark {
+	return NewPostmark(PostmarkConfig{ServerToken: fakePostmarkToken, BaseURL: f.server.URL})
+}
+
+func (f *fakePostmark) authorized(w http.ResponseWriter, r *http.Request) bool {
+	f.mu.Lock()
+	f.requests = append(f.requests, r.URL.Path)
+	f.mu.Unlock()
+
+	if r.Header.Get("X-Postmark-Server-Token") != fakePostmarkToken {
+		writeJSON(w, http.StatusUnauthorized, postmarkReply{ErrorCode: 10, Message: "Bad or missing Server API token."})
+		return false
+	}
+
+	return true
+}
+
+// answer records message and returns the reply for it.
+func (f *fakePostmark) answer(message postmarkMessage) (int, postmarkReply) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.messages = append(f.messages, message)
+	if f.reply != nil {
+		return f.reply(message)
+	}
+
+	return http.StatusOK, postmarkReply{MessageID: fmt.Sprintf("pm-%d", len(f.messages)), Message: "OK"}
+}
+
+func (f *fakePostmark) single(w http.ResponseWriter, r *http.Request) {
+	if !f.authorized(w, r) {
+		return
+	}
+
+	var message postmarkMessage
+	if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+		writeJSON(w, http.StatusBadRequest, postmarkReply{ErrorCode: 402, Message: "Invalid JSON"})
+		return
+	}
+
+	status, reply := f.answer(message)
+	writeJSON(w, status, reply)
+}
+
+// batch answers with 200 and a reply per message unless one of the
+// messages gets a status other than 200, which then answers the request.
+func (f *fakePostmark) batch(w http.ResponseWriter, r *http.Request) {
+	if !f.authorized(w, r) {
+		return
+	}
+
+	var messages []postmarkMessage
+	if err := json.NewDecoder(r.Body).Decode(&messages); err != nil {
+		writeJSON(w, http.StatusBadRequest, postmarkReply{ErrorCode: 402, Message: "Invalid JSON"})
+		return
+	}
+
+	replies := make([]postmarkReply, len(messages))
+	for i, message := range messages {
+		status, reply := f.answer(message)
+		if status != http.StatusOK {
+			writeJSON(w, status, reply)
+			return
+		}
+		replies[i] = reply
+	}
+
+	writeJSON(w, http.StatusOK, replies)
+}
+
+func (f *fakePostmark) received() ([]string, []postmarkMessage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.requests...), append([]postmarkMessage(nil), f.messages...)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func TestPostmarkSendTransactional(t *testing.T) {
+	fake := newFakePostmark(t)
+
+	id, err := fake.client().SendTransactional(context.Background(), email.TransactionalPayload{
+		To:       "Jane Doe <jane@example.com>",
+		Bcc:      []string{"audit@example.com", "ops@example.com"},
+		From:     "noreply@example.com",
+		Subject:  "Your invoice",
+		HTMLBody: `<img src="cid:logo.png">`,
+		TextBody: "Your invoice",
+		Metadata: map[string]string{"template": "invoice"},
+		Attachments: []email.Attachment{
+			{Name: "logo.png", Content: []byte("png"), ContentType: "image/png", Inline: true},
+			{Name: "invoice.pdf", Content: []byte("%PDF")},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SendTransactional: %v", err)
+	}
+	if id != "pm-1" {
+		t.Errorf("message id = %q, want pm-1", id)
+	}
+
+	_, messages := fake.received()
+	if len(messages) != 1 {
+		t.Fatalf("Postmark received %d messages, want 1", len(messages))
+	}
+
+	got := messages[0]
+	if got.To != "Jane Doe <jane@example.com>" || got.Bcc != "audit@example.com, ops@example.com" {
+		t.Errorf("To = %q, Bcc = %q", got.To, got.Bcc)
+	}
+	if got.MessageStream != "outbound" {
+		t.Errorf("MessageStream = %q, want outbound", got.MessageStream)
+	}
+	if got.Metadata["template"] != "invoice" {
+		t.Errorf("Metadata = %v", got.Metadata)
+	}
+	if len(got.Attachments) != 2 {
+		t.Fatalf("got %d attachments, want 2", len(got.Attachments))
+	}
+	if a := got.Attachments[0]; a.ContentID != "cid:logo.png" || a.Content != base64.StdEncoding.EncodeToString([]byte("png")) {
+		t.Errorf("inline attachment = %+v", a)
+	}
+	if a := got.Attachments[1]; a.ContentID != "" || a.ContentType != "application/octet-stream" {
+		t.Errorf("attachment = %+v", a)
+	}
+}
+
+func TestPostmarkErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		code      int
+		temporary bool
+	}{
+		{name: "inactive recipient", status: http.StatusUnprocessableEntity, code: 406},
+		{name: "invalid email request", status: http.StatusUnprocessableEntity, code: 300},
+		{name: "bad server token", status: http.StatusUnauthorized, code: 10, temporary: true},
+		{name: "maintenance", status: http.StatusUnprocessableEntity, code: 100, temporary: true},
+		{name: "out of credits", status: http.StatusUnprocessableEntity, code: 405, temporary: true},
+		{name: "pending approval", status: http.StatusUnprocessableEntity, code: 412, temporary: true},
+		{name: "rate limited", status: http.StatusTooManyRequests, temporary: true},
+		{name: "server error", status: http.StatusInternalServerError, temporary: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakePostmark(t)
+			fake.reply = func(postmarkMessage) (int, postmarkReply) {
+				return tt.status, postmarkReply{ErrorCode: tt.code, Message: tt.name}
+			}
+
+			_, err := fake.client().SendTransactional(context.Background(), email.TransactionalPayload{
+				To: "jane@example.com", From: "noreply@example.com", Subject: "Hi", TextBody: "Hi",
+			})
+
+			var apiErr APIError
+			if !errors.As(err, &apiErr) || apiErr.Code != tt.code || apiErr.StatusCode != tt.status {
+				t.Fatalf("SendTransactional = %v, want an APIError with code %d", err, tt.code)
+			}
+			if got := errors.As(err, new(email.TemporaryError)); got != tt.temporary {
+				t.Errorf("temporary = %v, want %v (%v)", got, tt.temporary, err)
+			}
+			if got := errors.As(err, new(email.PermanentError)); got == tt.temporary {
+				t.Errorf("permanent = %v, want %v (%v)", got, !tt.temporary, err)
+			}
+		})
+	}
+}
+
+func TestPostmarkRejectsWrongToken(t *testing.T) {
+	fake := newFakePostmark(t)
+	client := NewPostmark(PostmarkConfig{ServerToken: "wrong", BaseURL: fake.server.URL})
+
+	_, err := client.SendTransactional(context.Background(), email.TransactionalPayload{
+		To: "jane@example.com", From: "noreply@example.com", Subject: "Hi", TextBody: "Hi",
+	})
+	if !errors.As(err, new(email.TemporaryError)) {
+		t.Errorf("SendTransactional with a bad token = %v, want a TemporaryError", err)
+	}
+}
+
+func TestPostmarkSendMarketing(t *testing.T) {
+	fake := newFakePostmark(t)
+
+	id, err := fake.client().SendMarketing(context.Background(), email.MarketingPayload{
+		To:             []string{"a@example.com", "b@example.com"},
+		From:           "news@example.com",
+		Subject:        "Spring update",
+		HTMLBody:       "<h1>Spring</h1>",
+		UnsubscribeURL: "https://example.com/unsubscribe/abc",
+		Tags:           []string{"newsletter", "spring"},
+		TrackClicks:    true,
+	})
+	if err != nil {
+		t.Fatalf("SendMarketing: %v", err)
+	}
+	if id != "pm-1" {
+		t.Errorf("message id = %q, want the first message's pm-1", id)
+	}
+
+	requests, messages := fake.received()
+	if len(requests) != 1 || requests[0] != "/email/batch" {
+		t.Errorf("requests = %q, want one to /email/batch", requests)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("Postmark received %d messages, want one per recipient", len(messages))
+	}
+	for i, message := range messages {
+		if message.To != []string{"a@example.com", "b@example.com"}[i] || message.Cc != "" || message.Bcc != "" {
+			t.Errorf("message %d is addressed To %q Cc %q Bcc %q", i, message.To, message.Cc, message.Bcc)
+		}
+		if message.MessageStream != "broadcast" || message.Tag != "newsletter" || message.TrackLinks != "HtmlAndText" {
+			t.Errorf("message %d = stream %q, tag %q, links %q", i, message.MessageStream, message.Tag, message.TrackLinks)
+		}
+		if len(message.Headers) != 1 || message.Headers[0].Value != "<https://example.com/unsubscribe/abc>" {
+			t.Errorf("message %d headers = %+v", i, message.Headers)
+		}
+	}
+}
+
+func TestPostmarkSendMarketingFailures(t *testing.T) {
+	recipients := func(n int) []string {
+		to := make([]string, n)
+		for i := range to {
+			to[i] = fmt.Sprintf("user%d@example.com", i)
+		}
+		return to
+	}
+
+	tests := []struct {
+		name      string
+		to        []string
+		reply     func(message postmarkMessage) (int, postmarkReply)
+		temporary bool
+	}{
+		{
+			name: "every message refused",
+			to:   recipients(2),
+			reply: func(postmarkMessage) (int, postmarkReply) {
+				return http.StatusOK, postmarkReply{ErrorCode: 406, Message: "Inactive recipient"}
+			},
+		},
+		{
+			name: "some messages refused",
+			to:   recipients(2),
+			reply: func(message postmarkMessage) (int, postmarkReply) {
+				if message.To == "user1@example.com" {
+					return http.StatusOK, postmarkReply{ErrorCode: 406, Message: "Inactive recipient"}
+				}
+				return http.StatusOK, postmarkReply{MessageID: "pm"}
+			},
+		},
+		{
+			name: "first batch unavailable",
+			to:   recipients(2),
+			reply: func(postmarkMessage) (int, postmarkReply) {
+				return http.StatusServiceUnavailable, postmarkReply{Message: "down"}
+			},
+			temporary: true,
+		},
+		{
+			// Retrying would send the first batch again.
+			name: "second batch unavailable",
+			to:   recipients(postmarkBatchSize + 1),
+			reply: func(message postmarkMessage) (int, postmarkReply) {
+				if message.To == fmt.Sprintf("user%d@example.com", postmarkBatchSize) {
+					return http.StatusServiceUnavailable, postmarkReply{Message: "down"}
+				}
+				return http.StatusOK, postmarkReply{MessageID: "pm"}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakePostmark(t)
+			fake.reply = tt.reply
+
+			_, err := fake.client().SendMarketing(context.Background(), email.MarketingPayload{
+				To: tt.to, From: "news@example.com", Subject: "Hi", HTMLBody: "Hi",
+			})
+			if err == nil {
+				t.Fatal("SendMarketing succeeded")
+			}
+			if got := email.IsRetryable(err); got != tt.temporary {
+				t.Errorf("retryable = %v, want %v (%v)", got, tt.temporary, err)
+			}
+		})
+	}
+}
+
+func TestPostmarkUnreachable(t *testing.T) {
+	fake := newFakePostmark(t)
+	client := fake.client()
+	fake.server.Close()
+
+	_, err := client.SendTransactional(context.Background(), email.TransactionalPayload{
+		To: "jane@example.com", From: "noreply@example.com", Subject: "Hi", TextBody: "Hi",
+	})
+	if !errors.As(err, new(email.TemporaryError)) {
+		t.Errorf("SendTransactional to a closed server = %v, want a TemporaryError", err)
+	}
+	if strings.Contains(fmt.Sprint(err), fakePostmarkToken) {
+		t.Errorf("error leaks the server token: %v", err)
+	}
+}
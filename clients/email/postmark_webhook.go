@@ -0,0 +1,74 @@
+package mailclients
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"mbvlabs/email"
+)
+
+// postmarkHardBounceTypes are the bounce types Postmark reports for an
+// address that will never accept mail. The others are soft bounces, auto
+// replies and the like.
+var postmarkHardBounceTypes = map[string]bool{
+	"HardBounce":      true,
+	"BadEmailAddress": true,
+}
+
+type postmarkWebhookRecord struct {
+	RecordType  string            `json:"RecordType"`
+	Type        string            `json:"Type"`
+	MessageID   string            `json:"MessageID"`
+	Email       string            `json:"Email"`
+	Description string            `json:"Description"`
+	Details     string            `json:"Details"`
+	Metadata    map[string]string `json:"Metadata"`
+}
+
+// VerifyPostmarkWebhook checks the basic auth credentials Postmark was
+// given in the webhook URL. Postmark does not sign its webhooks.
+func VerifyPostmarkWebhook(r *http.Request, username string, password string) error {
+	gotUsername, gotPassword, ok := r.BasicAuth()
+	if !ok {
+		return ErrWebhookUnauthorized
+	}
+
+	usernameMatches := subtle.ConstantTimeCompare([]byte(gotUsername), []byte(username))
+	passwordMatches := subtle.ConstantTimeCompare([]byte(gotPassword), []byte(password))
+	if usernameMatches&passwordMatches != 1 {
+		return ErrWebhookUnauthorized
+	}
+
+	return nil
+}
+
+// ParsePostmarkWebhook reads the record of a bounce or spam complaint
+// webhook. Postmark posts one record per request; soft bounces and every
+// other record type come back as no events.
+func ParsePostmarkWebhook(body []byte) ([]email.Event, error) {
+	var record postmarkWebhookRecord
+	if err := json.Unmarshal(body, &record); err != nil {
+		return nil, err
+	}
+
+	event := email.Event{
+		Provider:          "postmark",
+		Recipient:         record.Email,
+		MessageID:         record.Metadata[email.MetadataMessageID],
+		ProviderMessageID: record.MessageID,
+		Details:           strings.TrimSpace(record.Description + " " + record.Details),
+	}
+
+	switch {
+	case record.RecordType == "Bounce" && postmarkHardBounceTypes[record.Type]:
+		event.Type = email.EventBounce
+	case record.RecordType == "SpamComplaint":
+		event.Type = email.EventComplaint
+	default:
+		return nil, nil
+	}
+
+	return []email.Event{event}, nil
+}
@@ -0,0 +1,298 @@
+package mailclients
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mbvlabs/email"
+)
+
+var _ email.TransactionalSender = (*SendGrid)(nil)
+var _ email.MarketingSender = (*SendGrid)(nil)
+
+// sendGridBatchSize is the most personalizations one request takes.
+const sendGridBatchSize = 1000
+
+var ErrInvalidUnsubscribeGroup = errors.New("unsubscribe group must be a numeric SendGrid group id")
+
+type SendGridConfig struct {
+	APIKey string
+	// BaseURL defaults to https://api.sendgrid.com.
+	BaseURL string
+	// HTTPClient defaults to a client with a 30 second timeout.
+	HTTPClient *http.Client
+}
+
+// SendGrid delivers through the SendGrid v3 mail send API.
+type SendGrid struct {
+	cfg SendGridConfig
+}
+
+func NewSendGrid(cfg SendGridConfig) *SendGrid {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.sendgrid.com"
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: defaultHTTPTimeout}
+	}
+
+	return &SendGrid{cfg: cfg}
+}
+
+type sendGridMessage struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	ReplyTo          *sendGridAddress          `json:"reply_to,omitempty"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+	Attachments      []sendGridAttachment      `json:"attachments,omitempty"`
+	Categories       []string                  `json:"categories,omitempty"`
+	CustomArgs       map[string]string         `json:"custom_args,omitempty"`
+	Headers          map[string]string         `json:"headers,omitempty"`
+	ASM              *sendGridASM              `json:"asm,omitempty"`
+	TrackingSettings *sendGridTracking         `json:"tracking_settings,omitempty"`
+}
+
+type sendGridPersonalization struct {
+	To  []sendGridAddress `json:"to"`
+	Cc  []sendGridAddress `json:"cc,omitempty"`
+	Bcc []sendGridAddress `json:"bcc,omitempty"`
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridAttachment struct {
+	Content     string `json:"content"`
+	Type        string `json:"type,omitempty"`
+	Filename    string `json:"filename"`
+	Disposition string `json:"disposition"`
+	ContentID   string `json:"content_id,omitempty"`
+}
+
+type sendGridASM struct {
+	GroupID int `json:"group_id"`
+}
+
+type sendGridTracking struct {
+	ClickTracking sendGridToggle `json:"click_tracking"`
+	OpenTracking  sendGridToggle `json:"open_tracking"`
+}
+
+type sendGridToggle struct {
+	Enable bool `json:"enable"`
+}
+
+type sendGridErrors struct {
+	Errors []struct {
+		Message string `json:"message"`
+		Field   string `json:"field"`
+	} `json:"errors"`
+}
+
+func (s *SendGrid) SendTransactional(ctx context.Context, payload email.TransactionalPayload) (string, error) {
+	message, err := s.message(payload.From, payload.ReplyTo, payload.Subject, payload.TextBody, payload.HTMLBody)
+	if err != nil {
+		return "", err
+	}
+
+	var personalization sendGridPersonalization
+	for _, field := range []struct {
+		list []string
+		into *[]sendGridAddress
+	}{
+		{[]string{payload.To}, &personalization.To},
+		{payload.Cc, &personalization.Cc},
+		{payload.Bcc, &personalization.Bcc},
+	} {
+		for _, raw := range field.list {
+			if raw == "" {
+				continue
+			}
+			address, err := sendGridAddressOf(raw)
+			if err != nil {
+				return "", err
+			}
+			*field.into = append(*field.into, address)
+		}
+	}
+	message.Personalizations = []sendGridPersonalization{personalization}
+	message.CustomArgs = payload.Metadata
+
+	for _, attachment := range payload.Attachments {
+		disposition := "attachment"
+		var contentID string
+		if attachment.Inline {
+			disposition = "inline"
+			contentID = attachment.Name
+		}
+
+		message.Attachments = append(message.Attachments, sendGridAttachment{
+			Content:     base64.StdEncoding.EncodeToString(attachment.Content),
+			Type:        attachment.ContentType,
+			Filename:    attachment.Name,
+			Disposition: disposition,
+			ContentID:   contentID,
+		})
+	}
+
+	return s.send(ctx, message)
+}
+
+// SendMarketing gives each recipient a personalization of their own, so
+// no one sees who else got the message. UnsubscribeGroup is the id of a
+// SendGrid unsubscribe group.
+func (s *SendGrid) SendMarketing(ctx context.Context, payload email.MarketingPayload) (string, error) {
+	if len(payload.To) == 0 {
+		return "", email.ValidationError{Err: email.ErrMissingRecipient}
+	}
+
+	message, err := s.message(payload.From, payload.ReplyTo, payload.Subject, payload.TextBody, payload.HTMLBody)
+	if err != nil {
+		return "", err
+	}
+
+	message.Categories = payload.Tags
+	message.CustomArgs = payload.Metadata
+	message.Headers = map[string]string{
+		"List-Unsubscribe": "<" + payload.UnsubscribeURL + ">",
+	}
+	message.TrackingSettings = &sendGridTracking{
+		ClickTracking: sendGridToggle{payload.TrackClicks},
+		OpenTracking:  sendGridToggle{payload.TrackOpens},
+	}
+
+	if payload.UnsubscribeGroup != "" {
+		groupID, err := strconv.Atoi(payload.UnsubscribeGroup)
+		if err != nil {
+			return "", email.ValidationError{Err: fmt.Errorf("%w: %q", ErrInvalidUnsubscribeGroup, payload.UnsubscribeGroup)}
+		}
+		message.ASM = &sendGridASM{GroupID: groupID}
+	}
+
+	personalizations := make([]sendGridPersonalization, len(payload.To))
+	for i, raw := range payload.To {
+		address, err := sendGridAddressOf(raw)
+		if err != nil {
+			return "", err
+		}
+		personalizations[i] = sendGridPersonalization{To: []sendGridAddress{address}}
+	}
+
+	// Each request either queues every personalization in it or none.
+	var firstMessageID string
+	next := 0
+	err = sendInBatches(payload.To, sendGridBatchSize, func(recipients []string) error {
+		message.Personalizations = personalizations[next : next+len(recipients)]
+		next += len(recipients)
+
+		messageID, err := s.send(ctx, message)
+		if firstMessageID == "" {
+			firstMessageID = messageID
+		}
+
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return firstMessageID, nil
+}
+
+// message fills in what transactional and marketing messages share. The
+// API wants plain text before HTML.
+func (s *SendGrid) message(
+	from string,
+	replyTo string,
+	subject string,
+	textBody string,
+	htmlBody string,
+) (sendGridMessage, error) {
+	sender, err := sendGridAddressOf(from)
+	if err != nil {
+		return sendGridMessage{}, err
+	}
+
+	message := sendGridMessage{
+		From:    sender,
+		Subject: subject,
+	}
+
+	if replyTo != "" {
+		address, err := sendGridAddressOf(replyTo)
+		if err != nil {
+			return sendGridMessage{}, err
+		}
+		message.ReplyTo = &address
+	}
+
+	if textBody != "" {
+		message.Content = append(message.Content, sendGridContent{"text/plain", textBody})
+	}
+	if htmlBody != "" {
+		message.Content = append(message.Content, sendGridContent{"text/html", htmlBody})
+	}
+
+	return message, nil
+}
+
+// send returns the id SendGrid gave the request, which its event reports
+// carry as the prefix of sg_message_id.
+func (s *SendGrid) send(ctx context.Context, message sendGridMessage) (string, error) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+s.cfg.APIKey)
+
+	res, err := postJSON(ctx, s.cfg.HTTPClient, s.cfg.BaseURL+"/v3/mail/send", header, message)
+	if err != nil {
+		return "", err
+	}
+
+	if res.ok() {
+		return res.header.Get("X-Message-Id"), nil
+	}
+
+	var reply sendGridErrors
+	_ = json.Unmarshal(res.body, &reply)
+
+	messages := make([]string, 0, len(reply.Errors))
+	for _, e := range reply.Errors {
+		if e.Field != "" {
+			messages = append(messages, e.Field+": "+e.Message)
+		} else {
+			messages = append(messages, e.Message)
+		}
+	}
+	if len(messages) == 0 {
+		messages = append(messages, http.StatusText(res.status))
+	}
+
+	return "", classifyHTTPStatus(res.status, APIError{
+		Provider:   "sendgrid",
+		StatusCode: res.status,
+		Message:    strings.Join(messages, "; "),
+	})
+}
+
+func sendGridAddressOf(raw string) (sendGridAddress, error) {
+	address, err := parseAddress(raw)
+	if err != nil {
+		return sendGridAddress{}, err
+	}
+
+	return sendGridAddress{Email: address.Address, Name: address.Name}, nil
+}
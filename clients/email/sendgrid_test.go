@@ -0,0 +1,303 @@
+package mailclients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"mbvlabs/email"
+)
+
+const fakeSendGridKey = "SG.test-key"
+
+// fakeSendGrid stands in for the SendGrid v3 mail send API. It checks the
+// API key, records every request and answers as reply says.
+type fakeSendGrid struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	messages []sendGridMessage
+	// reply answers a request; nil accepts everything with 202.
+	reply func(message sendGridMessage) (status int, body any)
+}
+
+func newFakeSendGrid(t *testing.T) *fakeSendGrid {
+	t.Helper()
+
+	f := &fakeSendGrid{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v3/mail/send", f.send)
+
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+
+	return f
+}
+
+func (f *fakeSendGrid) client() *SendGrid {
+	return NewSendGrid(SendGridConfig{APIKey: fakeSendGridKey, BaseURL: f.server.URL})
+}
+
+func (f *fakeSendGrid) send(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+fakeSendGridKey {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{
+			"errors": []map[string]string{{"message": "The provided authorization grant is invalid"}},
+		})
+		return
+	}
+
+	var message sendGridMessage
+	if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"errors": []map[string]string{{"message": "Bad Request"}},
+		})
+		return
+	}
+
+	f.mu.Lock()
+	f.messages = append(f.messages, message)
+	n := len(f.messages)
+	reply := f.reply
+	f.mu.Unlock()
+
+	if reply != nil {
+		if status, body := reply(message); status != http.StatusAccepted {
+			writeJSON(w, status, body)
+			return
+		}
+	}
+
+	w.Header().Set("X-Message-Id", fmt.Sprintf("sg-%d", n))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (f *fakeSendGrid) received() []sendGridMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]sendGridMessage(nil), f.messages...)
+}
+
+func TestSendGridSendTransactional(t *testing.T) {
+	fake := newFakeSendGrid(t)
+
+	id, err := fake.client().SendTransactional(context.Background(), email.TransactionalPayload{
+		To:       "Jane Doe <jane@example.com>",
+		Cc:       []string{"ops@example.com"},
+		Bcc:      []string{"audit@example.com"},
+		From:     "Acme <noreply@example.com>",
+		ReplyTo:  "help@example.com",
+		Subject:  "Your invoice",
+		HTMLBody: `<img src="cid:logo.png">`,
+		TextBody: "Your invoice",
+		Metadata: map[string]string{"template": "invoice"},
+		Attachments: []email.Attachment{
+			{Name: "logo.png", Content: []byte("png"), ContentType: "image/png", Inline: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SendTransactional: %v", err)
+	}
+	if id != "sg-1" {
+		t.Errorf("message id = %q, want sg-1", id)
+	}
+
+	messages := fake.received()
+	if len(messages) != 1 {
+		t.Fatalf("SendGrid received %d requests, want 1", len(messages))
+	}
+
+	got := messages[0]
+	if len(got.Personalizations) != 1 {
+		t.Fatalf("got %d personalizations, want 1", len(got.Personalizations))
+	}
+	p := got.Personalizations[0]
+	if len(p.To) != 1 || p.To[0] != (sendGridAddress{Email: "jane@example.com", Name: "Jane Doe"}) {
+		t.Errorf("to = %+v", p.To)
+	}
+	if len(p.Cc) != 1 || p.Cc[0].Email != "ops@example.com" || len(p.Bcc) != 1 || p.Bcc[0].Email != "audit@example.com" {
+		t.Errorf("cc = %+v, bcc = %+v", p.Cc, p.Bcc)
+	}
+	if got.From != (sendGridAddress{Email: "noreply@example.com", Name: "Acme"}) || got.ReplyTo == nil || got.ReplyTo.Email != "help@example.com" {
+		t.Errorf("from = %+v, reply to = %+v", got.From, got.ReplyTo)
+	}
+	if len(got.Content) != 2 || got.Content[0].Type != "text/plain" || got.Content[1].Type != "text/html" {
+		t.Errorf("content = %+v, want plain text before HTML", got.Content)
+	}
+	if got.CustomArgs["template"] != "invoice" {
+		t.Errorf("custom args = %v", got.CustomArgs)
+	}
+	if len(got.Attachments) != 1 || got.Attachments[0].Disposition != "inline" || got.Attachments[0].ContentID != "logo.png" {
+		t.Errorf("attachments = %+v", got.Attachments)
+	}
+}
+
+func TestSendGridSendMarketing(t *testing.T) {
+	fake := newFakeSendGrid(t)
+
+	_, err := fake.client().SendMarketing(context.Background(), email.MarketingPayload{
+		To:               []string{"a@example.com", "b@example.com"},
+		From:             "news@example.com",
+		Subject:          "Spring update",
+		HTMLBody:         "<h1>Spring</h1>",
+		UnsubscribeURL:   "https://example.com/unsubscribe/abc",
+		UnsubscribeGroup: "1234",
+		Tags:             []string{"newsletter", "spring"},
+		TrackOpens:       true,
+	})
+	if err != nil {
+		t.Fatalf("SendMarketing: %v", err)
+	}
+
+	messages := fake.received()
+	if len(messages) != 1 {
+		t.Fatalf("SendGrid received %d requests, want 1", len(messages))
+	}
+
+	got := messages[0]
+	if len(got.Personalizations) != 2 {
+		t.Fatalf("got %d personalizations, want one per recipient", len(got.Personalizations))
+	}
+	for i, p := range got.Personalizations {
+		if len(p.To) != 1 || len(p.Cc) != 0 || len(p.Bcc) != 0 {
+			t.Errorf("personalization %d = %+v, want a single recipient", i, p)
+		}
+	}
+	if got.ASM == nil || got.ASM.GroupID != 1234 {
+		t.Errorf("asm = %+v, want group 1234", got.ASM)
+	}
+	if strings.Join(got.Categories, ",") != "newsletter,spring" {
+		t.Errorf("categories = %q", got.Categories)
+	}
+	if got.Headers["List-Unsubscribe"] != "<https://example.com/unsubscribe/abc>" {
+		t.Errorf("headers = %v", got.Headers)
+	}
+	if got.TrackingSettings == nil || !got.TrackingSettings.OpenTracking.Enable || got.TrackingSettings.ClickTracking.Enable {
+		t.Errorf("tracking = %+v", got.TrackingSettings)
+	}
+}
+
+func TestSendGridSendMarketingBatches(t *testing.T) {
+	fake := newFakeSendGrid(t)
+
+	to := make([]string, sendGridBatchSize+1)
+	for i := range to {
+		to[i] = fmt.Sprintf("user%d@example.com", i)
+	}
+
+	id, err := fake.client().SendMarketing(context.Background(), email.MarketingPayload{
+		To: to, From: "news@example.com", Subject: "Hi", HTMLBody: "Hi",
+	})
+	if err != nil {
+		t.Fatalf("SendMarketing: %v", err)
+	}
+	if id != "sg-1" {
+		t.Errorf("message id = %q, want the first request's sg-1", id)
+	}
+
+	messages := fake.received()
+	if len(messages) != 2 || len(messages[0].Personalizations) != sendGridBatchSize || len(messages[1].Personalizations) != 1 {
+		t.Fatalf("requests carried %d personalizations in %d requests", sendGridBatchSize+1, len(messages))
+	}
+	if last := messages[1].Personalizations[0].To[0].Email; last != to[sendGridBatchSize] {
+		t.Errorf("second request went to %q, want %q", last, to[sendGridBatchSize])
+	}
+
+	// Once the first batch is out, a failure is final.
+	fake.reply = func(message sendGridMessage) (int, any) {
+		if len(message.Personalizations) == 1 {
+			return http.StatusServiceUnavailable, nil
+		}
+		return http.StatusAccepted, nil
+	}
+
+	_, err = fake.client().SendMarketing(context.Background(), email.MarketingPayload{
+		To: to, From: "news@example.com", Subject: "Hi", HTMLBody: "Hi",
+	})
+	if !errors.As(err, new(email.PermanentError)) || email.IsRetryable(err) {
+		t.Errorf("SendMarketing with a failed second batch = %v, want a PermanentError", err)
+	}
+}
+
+func TestSendGridRejectsInvalidUnsubscribeGroup(t *testing.T) {
+	fake := newFakeSendGrid(t)
+
+	_, err := fake.client().SendMarketing(context.Background(), email.MarketingPayload{
+		To: []string{"a@example.com"}, From: "news@example.com", Subject: "Hi", HTMLBody: "Hi",
+		UnsubscribeGroup: "newsletter",
+	})
+	if !email.IsValidationError(err) || !errors.Is(err, ErrInvalidUnsubscribeGroup) {
+		t.Errorf("SendMarketing = %v, want ErrInvalidUnsubscribeGroup", err)
+	}
+	if n := len(fake.received()); n != 0 {
+		t.Errorf("SendGrid received %d requests, want none", n)
+	}
+}
+
+func TestSendGridErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      any
+		temporary bool
+		message   string
+	}{
+		{
+			name:   "invalid request",
+			status: http.StatusBadRequest,
+			body: map[string]any{"errors": []map[string]string{
+				{"message": "Does not contain a valid address.", "field": "personalizations.0.to.0.email"},
+			}},
+			message: "personalizations.0.to.0.email: Does not contain a valid address.",
+		},
+		{name: "payload too large", status: http.StatusRequestEntityTooLarge},
+		{name: "rejected key", status: http.StatusForbidden, temporary: true},
+		{name: "rate limited", status: http.StatusTooManyRequests, temporary: true},
+		{name: "server error", status: http.StatusInternalServerError, temporary: true},
+		{name: "unavailable", status: http.StatusServiceUnavailable, temporary: true, message: "Service Unavailable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeSendGrid(t)
+			fake.reply = func(sendGridMessage) (int, any) { return tt.status, tt.body }
+
+			_, err := fake.client().SendTransactional(context.Background(), email.TransactionalPayload{
+				To: "jane@example.com", From: "noreply@example.com", Subject: "Hi", TextBody: "Hi",
+			})
+
+			var apiErr APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+				t.Fatalf("SendTransactional = %v, want an APIError with status %d", err, tt.status)
+			}
+			if tt.message != "" && apiErr.Message != tt.message {
+				t.Errorf("message = %q, want %q", apiErr.Message, tt.message)
+			}
+			if got := errors.As(err, new(email.TemporaryError)); got != tt.temporary {
+				t.Errorf("temporary = %v, want %v (%v)", got, tt.temporary, err)
+			}
+			if got := errors.As(err, new(email.PermanentError)); got == tt.temporary {
+				t.Errorf("permanent = %v, want %v (%v)", got, !tt.temporary, err)
+			}
+		})
+	}
+}
+
+func TestSendGridRejectsWrongKey(t *testing.T) {
+	fake := newFakeSendGrid(t)
+	client := NewSendGrid(SendGridConfig{APIKey: "wrong", BaseURL: fake.server.URL})
+
+	_, err := client.SendTransactional(context.Background(), email.TransactionalPayload{
+		To: "jane@example.com", From: "noreply@example.com", Subject: "Hi", TextBody: "Hi",
+	})
+	if !errors.As(err, new(email.TemporaryError)) {
+		t.Errorf("SendTransactional with a bad key = %v, want a TemporaryError", err)
+	}
+}
@@ -0,0 +1,129 @@
+package mailclients
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"mbvlabs/email"
+)
+
+// Headers SendGrid signs its event webhook requests with.
+const (
+	SendGridSignatureHeader = "X-Twilio-Email-Event-Webhook-Signature"
+	SendGridTimestampHeader = "X-Twilio-Email-Event-Webhook-Timestamp"
+)
+
+// sendGridWebhookTolerance is how far a signed timestamp may be from now. A
+// captured request is worthless once it is older than this.
+const sendGridWebhookTolerance = 5 * time.Minute
+
+var ErrInvalidWebhookKey = errors.New("SendGrid webhook verification key must be a base64 encoded ECDSA public key")
+
+type sendGridWebhookEvent struct {
+	Event       string `json:"event"`
+	Type        string `json:"type"`
+	Email       string `json:"email"`
+	Reason      string `json:"reason"`
+	SGMessageID string `json:"sg_message_id"`
+	// Custom args sent with the message come back as fields of their own.
+	EmailMessageID string `json:"email_message_id"`
+}
+
+// VerifySendGridWebhook checks the signature of a signed event webhook
+// request: an ECDSA signature over the timestamp followed by the body, made
+// with the key whose public half is publicKey, as SendGrid shows it. The
+// timestamp, in Unix seconds, must be within a few minutes of now, so a
+// captured request cannot be replayed later.
+func VerifySendGridWebhook(
+	publicKey string,
+	signature string,
+	timestamp string,
+	body []byte,
+	now time.Time,
+) error {
+	der, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidWebhookKey, err)
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidWebhookKey, err)
+	}
+
+	key, ok := parsed.(*ecdsa.PublicKey)
+	if !ok {
+		return ErrInvalidWebhookKey
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrWebhookUnauthorized
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrWebhookUnauthorized
+	}
+
+	skew := now.Sub(time.Unix(seconds, 0))
+	if skew > sendGridWebhookTolerance || skew < -sendGridWebhookTolerance {
+		return ErrWebhookUnauthorized
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(timestamp))
+	hash.Write(body)
+
+	if !ecdsa.VerifyASN1(key, hash.Sum(nil), sig) {
+		return ErrWebhookUnauthorized
+	}
+
+	return nil
+}
+
+// ParseSendGridWebhook reads a batch of events, keeping bounces and spam
+// reports. Blocked messages, which SendGrid reports as bounces too, are
+// temporary and left out.
+func ParseSendGridWebhook(body []byte) ([]email.Event, error) {
+	var records []sendGridWebhookEvent
+	if err := json.Unmarshal(body, &records); err != nil {
+		return nil, err
+	}
+
+	var events []email.Event
+	for _, record := range records {
+		// sg_message_id is the id the send request returned followed by a
+		// suffix per recipient.
+		providerMessageID, _, _ := strings.Cut(record.SGMessageID, ".")
+
+		event := email.Event{
+			Provider:          "sendgrid",
+			Recipient:         record.Email,
+			MessageID:         record.EmailMessageID,
+			ProviderMessageID: providerMessageID,
+			Details:           record.Reason,
+		}
+
+		switch {
+		case record.Event == "bounce" && record.Type != "blocked":
+			event.Type = email.EventBounce
+		case record.Event == "spamreport":
+			event.Type = email.EventComplaint
+		default:
+			continue
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
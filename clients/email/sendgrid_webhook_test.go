@@ -0,0 +1,98 @@
+package mailclients
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"mbvlabs/email"
+)
+
+func signSendGridWebhook(t *testing.T, key *ecdsa.PrivateKey, timestamp string, body []byte) string {
+	t.Helper()
+
+	hash := sha256.Sum256(append([]byte(timestamp), body...))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+func TestVerifySendGridWebhook(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := base64.StdEncoding.EncodeToString(der)
+
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte(`[{"email":"a@example.com","event":"bounce"}]`)
+	fresh := strconv.FormatInt(now.Unix(), 10)
+	stale := strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		publicKey string
+		signature string
+		timestamp string
+		body      []byte
+		want      error
+	}{
+		{"valid", publicKey, signSendGridWebhook(t, key, fresh, body), fresh, body, nil},
+		{"tampered body", publicKey, signSendGridWebhook(t, key, fresh, body), fresh, []byte(`[]`), ErrWebhookUnauthorized},
+		{"replayed", publicKey, signSendGridWebhook(t, key, stale, body), stale, body, ErrWebhookUnauthorized},
+		{"missing timestamp", publicKey, signSendGridWebhook(t, key, "", body), "", body, ErrWebhookUnauthorized},
+		{"bad signature", publicKey, "not base64", fresh, body, ErrWebhookUnauthorized},
+		{"bad key", "not a key", signSendGridWebhook(t, key, fresh, body), fresh, body, ErrInvalidWebhookKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySendGridWebhook(tt.publicKey, tt.signature, tt.timestamp, tt.body, now)
+			if tt.want == nil && err != nil {
+				t.Fatalf("got %v, want nil", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSendGridWebhook(t *testing.T) {
+	events, err := ParseSendGridWebhook([]byte(`[
+		{"email":"a@example.com","event":"bounce","type":"bounce","reason":"550 no such user","sg_message_id":"abc.filter0001","email_message_id":"m1"},
+		{"email":"b@example.com","event":"bounce","type":"blocked"},
+		{"email":"c@example.com","event":"spamreport","sg_message_id":"def.filter0002"},
+		{"email":"d@example.com","event":"delivered"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []email.Event{
+		{Type: email.EventBounce, Provider: "sendgrid", Recipient: "a@example.com", MessageID: "m1", ProviderMessageID: "abc", Details: "550 no such user"},
+		{Type: email.EventComplaint, Provider: "sendgrid", Recipient: "c@example.com", ProviderMessageID: "def"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d: got %+v, want %+v", i, events[i], want[i])
+		}
+	}
+}
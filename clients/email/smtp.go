@@ -0,0 +1,498 @@
+package mailclients
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"mbvlabs/email"
+)
+
+var _ email.TransactionalSender = (*SMTP)(nil)
+var _ email.MarketingSender = (*SMTP)(nil)
+
+var (
+	ErrSMTPClosed            = errors.New("smtp client is closed")
+	ErrSMTPStartTLSMissing   = errors.New("smtp server does not support STARTTLS")
+	ErrSMTPAuthNotSupported  = errors.New("smtp server does not support the configured AUTH mechanism")
+	ErrSMTPUnencryptedAuth   = errors.New("smtp refusing to send credentials over an unencrypted connection")
+	ErrSMTPUnexpectedAuthMsg = errors.New("smtp server sent an unexpected AUTH LOGIN challenge")
+)
+
+// SMTPSecurity is how the connection to the server is encrypted.
+type SMTPSecurity string
+
+const (
+	// SMTPStartTLS connects in plain text and upgrades with STARTTLS before
+	// anything else is sent. The server must support it. Usually port 587.
+	SMTPStartTLS SMTPSecurity = "starttls"
+	// SMTPImplicitTLS speaks TLS from the first byte. Usually port 465.
+	SMTPImplicitTLS SMTPSecurity = "tls"
+	// SMTPPlain never encrypts. Only for local catchers such as Mailpit.
+	SMTPPlain SMTPSecurity = "none"
+)
+
+// SMTPAuthMechanism is the SASL mechanism used to log in.
+type SMTPAuthMechanism string
+
+const (
+	SMTPAuthPlain SMTPAuthMechanism = "plain"
+	SMTPAuthLogin SMTPAuthMechanism = "login"
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Security SMTPSecurity
+	// Username and Password are sent with Auth once the connection is
+	// encrypted. An empty Username skips authentication. An empty Auth
+	// picks PLAIN if the server offers it and LOGIN otherwise.
+	Username string
+	Password string
+	Auth     SMTPAuthMechanism
+	// LocalName is the host name sent with EHLO. It defaults to
+	// "localhost", as in net/smtp.
+	LocalName string
+	// TLSConfig overrides the TLS settings. ServerName defaults to Host.
+	TLSConfig *tls.Config
+	// Timeout bounds a single delivery when ctx carries no deadline of its
+	// own. It defaults to a minute.
+	Timeout time.Duration
+	// MaxIdleConns is how many open connections are kept for reuse. It
+	// defaults to 2.
+	MaxIdleConns int
+	// IdleTimeout closes connections that have not been used for this long,
+	// before the server drops them on its side. It defaults to 30 seconds.
+	IdleTimeout time.Duration
+	// MaxMessagesPerConn replaces a connection after this many messages, as
+	// many providers limit messages per connection. It defaults to 100.
+	MaxMessagesPerConn int
+}
+
+// SMTP delivers through an SMTP submission server. Connections to the
+// server are kept open between messages and reused; each SMTP value holds
+// the pool for its host. It is safe for concurrent use.
+type SMTP struct {
+	cfg SMTPConfig
+
+	mu     sync.Mutex
+	idle   []*smtpConn
+	closed bool
+}
+
+func NewSMTP(cfg SMTPConfig) *SMTP {
+	if cfg.Security == "" {
+		cfg.Security = SMTPStartTLS
+	}
+	if cfg.LocalName == "" {
+		cfg.LocalName = "localhost"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Minute
+	}
+	if cfg.MaxIdleConns <= 0 {
+		cfg.MaxIdleConns = 2
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 30 * time.Second
+	}
+	if cfg.MaxMessagesPerConn <= 0 {
+		cfg.MaxMessagesPerConn = 100
+	}
+
+	return &SMTP{cfg: cfg}
+}
+
+func (s *SMTP) SendTransactional(ctx context.Context, payload email.TransactionalPayload) (string, error) {
+	message, err := newRawMessage(email.NewTransactionalMessage(payload))
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.send(ctx, message); err != nil {
+		return "", err
+	}
+
+	return message.id, nil
+}
+
+func (s *SMTP) SendMarketing(ctx context.Context, payload email.MarketingPayload) (string, error) {
+	message, err := newRawMessage(email.NewMarketingMessage(payload))
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.send(ctx, message); err != nil {
+		return "", err
+	}
+
+	return message.id, nil
+}
+
+// Shutdown closes the idle connections. Messages still being sent finish
+// on their own connections, which are closed instead of returned.
+func (s *SMTP) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	idle := s.idle
+	s.idle = nil
+	s.closed = true
+	s.mu.Unlock()
+
+	for _, conn := range idle {
+		conn.quit(ctx)
+	}
+
+	return nil
+}
+
+func (s *SMTP) send(ctx context.Context, message rawMessage) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.Timeout)
+		defer cancel()
+	}
+
+	conn, err := s.get(ctx)
+	if err != nil {
+		return classifySMTPError(ctx, err)
+	}
+
+	err = conn.withContext(ctx, func() error {
+		return conn.deliver(message.from, message.recipients, message.data)
+	})
+	if err != nil {
+		s.release(ctx, conn, err)
+		return classifySMTPError(ctx, err)
+	}
+
+	conn.sent++
+	s.release(ctx, conn, nil)
+
+	return nil
+}
+
+// get returns an idle connection that still answers, or dials a new one.
+func (s *SMTP) get(ctx context.Context) (*smtpConn, error) {
+	for {
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return nil, ErrSMTPClosed
+		}
+		if len(s.idle) == 0 {
+			s.mu.Unlock()
+			break
+		}
+		conn := s.idle[len(s.idle)-1]
+		s.idle = s.idle[:len(s.idle)-1]
+		s.mu.Unlock()
+
+		if time.Since(conn.idleSince) > s.cfg.IdleTimeout {
+			conn.close()
+			continue
+		}
+
+		if err := conn.withContext(ctx, conn.client.Noop); err != nil {
+			conn.close()
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		return conn, nil
+	}
+
+	return s.dial(ctx)
+}
+
+// release puts conn back in the pool if it can carry another message. After
+// a reply error the transaction is reset, which keeps the connection in
+// step with the server; after any other error it is closed.
+func (s *SMTP) release(ctx context.Context, conn *smtpConn, err error) {
+	if err != nil {
+		var protoErr *textproto.Error
+		if !errors.As(err, &protoErr) || ctx.Err() != nil {
+			conn.close()
+			return
+		}
+
+		if resetErr := conn.withContext(ctx, conn.client.Reset); resetErr != nil {
+			conn.close()
+			return
+		}
+	}
+
+	if conn.sent >= s.cfg.MaxMessagesPerConn {
+		conn.quit(ctx)
+		return
+	}
+
+	conn.idleSince = time.Now()
+
+	s.mu.Lock()
+	if s.closed || len(s.idle) >= s.cfg.MaxIdleConns {
+		s.mu.Unlock()
+		conn.quit(ctx)
+		return
+	}
+	s.idle = append(s.idle, conn)
+	s.mu.Unlock()
+}
+
+func (s *SMTP) dial(ctx context.Context) (*smtpConn, error) {
+	addr := net.JoinHostPort(s.cfg.Host, s.cfg.Port)
+	tlsConfig := s.tlsConfig()
+
+	var netConn net.Conn
+	var err error
+	if s.cfg.Security == SMTPImplicitTLS {
+		dialer := tls.Dialer{Config: tlsConfig}
+		netConn, err = dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		var dialer net.Dialer
+		netConn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &smtpConn{conn: netConn}
+
+	err = conn.withContext(ctx, func() error {
+		client, err := smtp.NewClient(netConn, s.cfg.Host)
+		if err != nil {
+			return err
+		}
+		conn.client = client
+
+		if err := client.Hello(s.cfg.LocalName); err != nil {
+			return err
+		}
+
+		if s.cfg.Security == SMTPStartTLS {
+			if ok, _ := client.Extension("STARTTLS"); !ok {
+				return ErrSMTPStartTLSMissing
+			}
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		}
+
+		if s.cfg.Username == "" {
+			return nil
+		}
+
+		auth, err := s.auth(client)
+		if err != nil {
+			return err
+		}
+
+		return client.Auth(auth)
+	})
+	if err != nil {
+		conn.close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func (s *SMTP) tlsConfig() *tls.Config {
+	var tlsConfig *tls.Config
+	if s.cfg.TLSConfig != nil {
+		tlsConfig = s.cfg.TLSConfig.Clone()
+	} else {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = s.cfg.Host
+	}
+
+	return tlsConfig
+}
+
+// auth picks the configured mechanism, or the best one the server offers.
+func (s *SMTP) auth(client *smtp.Client) (smtp.Auth, error) {
+	_, advertised := client.Extension("AUTH")
+	offered := strings.Fields(strings.ToUpper(advertised))
+
+	mechanism := s.cfg.Auth
+	if mechanism == "" {
+		mechanism = SMTPAuthPlain
+		if !slices.Contains(offered, "PLAIN") && slices.Contains(offered, "LOGIN") {
+			mechanism = SMTPAuthLogin
+		}
+	}
+
+	if !slices.Contains(offered, strings.ToUpper(string(mechanism))) {
+		return nil, ErrSMTPAuthNotSupported
+	}
+
+	switch mechanism {
+	case SMTPAuthPlain:
+		return smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host), nil
+	case SMTPAuthLogin:
+		return &loginAuth{
+			username: s.cfg.Username,
+			password: s.cfg.Password,
+			host:     s.cfg.Host,
+		}, nil
+	default:
+		return nil, ErrSMTPAuthNotSupported
+	}
+}
+
+type smtpConn struct {
+	conn      net.Conn
+	client    *smtp.Client
+	sent      int
+	idleSince time.Time
+}
+
+// withContext runs fn with the connection's deadline taken from ctx. If
+// ctx is cancelled while fn runs, the deadline is moved to now so the
+// blocked read or write returns.
+func (c *smtpConn) withContext(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	deadline, _ := ctx.Deadline()
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		c.conn.SetDeadline(time.Now())
+	})
+
+	err := fn()
+
+	if !stop() {
+		// ctx ended while fn was running, so the error, if any, is
+		// from the forced deadline.
+		if err == nil {
+			err = ctx.Err()
+		}
+		return err
+	}
+
+	// The connection can reach ctx's deadline a moment before ctx does.
+	if err != nil && !deadline.IsZero() && !time.Now().Before(deadline) && !errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("%w: %w", context.DeadlineExceeded, err)
+	}
+
+	if resetErr := c.conn.SetDeadline(time.Time{}); err == nil {
+		err = resetErr
+	}
+
+	return err
+}
+
+func (c *smtpConn) deliver(from string, to []string, message []byte) error {
+	if err := c.client.Mail(from); err != nil {
+		return err
+	}
+
+	for _, recipient := range to {
+		if err := c.client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.client.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+// quit says goodbye to the server, giving up quickly if it does not answer.
+func (c *smtpConn) quit(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	c.withContext(ctx, c.client.Quit)
+	c.close()
+}
+
+func (c *smtpConn) close() {
+	if c.client != nil {
+		c.client.Close()
+		return
+	}
+
+	c.conn.Close()
+}
+
+// classifySMTPError tells the email workers whether retrying can help.
+// Permanent 5xx replies will not change; 4xx replies and network failures
+// usually do.
+func classifySMTPError(ctx context.Context, err error) error {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		if protoErr.Code >= 500 {
+			return email.PermanentError{Err: fmt.Errorf("smtp: %w", err)}
+		}
+		return email.TemporaryError{Err: fmt.Errorf("smtp: %w", err)}
+	}
+
+	if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(err, ctxErr) {
+		err = fmt.Errorf("%w: %w", ctxErr, err)
+	}
+
+	return email.TemporaryError{Err: fmt.Errorf("smtp: %w", err)}
+}
+
+// loginAuth is the LOGIN mechanism, which net/smtp does not provide. Like
+// smtp.PlainAuth it only sends credentials over TLS or to localhost.
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, ErrSMTPUnencryptedAuth
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("smtp: wrong host name")
+	}
+
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	default:
+		return nil, ErrSMTPUnexpectedAuthMsg
+	}
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
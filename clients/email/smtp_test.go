@@ -0,0 +1,535 @@
+package mailclients
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mbvlabs/email"
+)
+
+// smtpServer is an in-process SMTP submission server. It speaks enough of
+// the protocol for the client: EHLO, STARTTLS, AUTH PLAIN and LOGIN, MAIL,
+// RCPT, DATA, RSET, NOOP and QUIT.
+type smtpServer struct {
+	t        *testing.T
+	listener net.Listener
+	tls      *tls.Config
+	roots    *x509.CertPool
+
+	// Set before the first connection.
+	offerStartTLS bool
+	authMechs     []string
+	username      string
+	password      string
+	// rejectRcpt answers RCPT for the address with the given reply.
+	rejectRcpt map[string]string
+
+	mu          sync.Mutex
+	connections int
+	open        []net.Conn
+	messages    []receivedMessage
+	logins      []string
+}
+
+type receivedMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, roots := selfSignedCert(t)
+	s := &smtpServer{
+		t:             t,
+		listener:      listener,
+		tls:           &tls.Config{Certificates: []tls.Certificate{cert}},
+		roots:         roots,
+		offerStartTLS: true,
+		authMechs:     []string{"PLAIN", "LOGIN"},
+		username:      "mailer",
+		password:      "secret",
+		rejectRcpt:    make(map[string]string),
+	}
+
+	go s.serve()
+	t.Cleanup(func() {
+		listener.Close()
+		s.dropConnections()
+	})
+
+	return s
+}
+
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(parsed)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, roots
+}
+
+func (s *smtpServer) client(cfg SMTPConfig) *SMTP {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	cfg.Host = host
+	cfg.Port = port
+	if cfg.Security == "" {
+		cfg.Security = SMTPStartTLS
+	}
+	if cfg.TLSConfig == nil {
+		cfg.TLSConfig = &tls.Config{RootCAs: s.roots, MinVersion: tls.VersionTLS12}
+	}
+	if cfg.Username == "" {
+		cfg.Username, cfg.Password = s.username, s.password
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+
+	client := NewSMTP(cfg)
+	s.t.Cleanup(func() { client.Shutdown(context.Background()) })
+
+	return client
+}
+
+func (s *smtpServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.connections++
+		s.open = append(s.open, conn)
+		s.mu.Unlock()
+
+		go s.handle(conn)
+	}
+}
+
+// dropConnections closes every open connection, as a server does when it
+// times out idle clients.
+func (s *smtpServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.open {
+		conn.Close()
+	}
+	s.open = nil
+}
+
+func (s *smtpServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 127.0.0.1 ESMTP test")
+
+	var encrypted, authenticated bool
+	var current receivedMessage
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			extensions := []string{"250-127.0.0.1"}
+			if s.offerStartTLS && !encrypted {
+				extensions = append(extensions, "250-STARTTLS")
+			}
+			if len(s.authMechs) > 0 {
+				extensions = append(extensions, "250-AUTH "+strings.Join(s.authMechs, " "))
+			}
+			extensions = append(extensions, "250 8BITMIME")
+			text.PrintfLine("%s", strings.Join(extensions, "\r\n"))
+		case "STARTTLS":
+			text.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			text = textproto.NewConn(conn)
+			encrypted = true
+		case "AUTH":
+			login, ok := s.authenticate(text, arg)
+			if !ok {
+				text.PrintfLine("535 authentication failed")
+				continue
+			}
+			s.mu.Lock()
+			s.logins = append(s.logins, login)
+			s.mu.Unlock()
+			authenticated = true
+			text.PrintfLine("235 authenticated")
+		case "MAIL":
+			if !authenticated {
+				text.PrintfLine("530 authentication required")
+				continue
+			}
+			current = receivedMessage{from: trimPath(arg, "FROM:")}
+			text.PrintfLine("250 ok")
+		case "RCPT":
+			address := trimPath(arg, "TO:")
+			if reply, ok := s.rejectRcpt[address]; ok {
+				text.PrintfLine("%s", reply)
+				continue
+			}
+			current.to = append(current.to, address)
+			text.PrintfLine("250 ok")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			data, err := io.ReadAll(text.DotReader())
+			if err != nil {
+				return
+			}
+			current.data = string(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, current)
+			s.mu.Unlock()
+			current = receivedMessage{}
+			text.PrintfLine("250 queued")
+		case "RSET":
+			current = receivedMessage{}
+			text.PrintfLine("250 ok")
+		case "NOOP":
+			text.PrintfLine("250 ok")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func (s *smtpServer) authenticate(text *textproto.Conn, arg string) (string, bool) {
+	mechanism, initial, _ := strings.Cut(arg, " ")
+
+	switch strings.ToUpper(mechanism) {
+	case "PLAIN":
+		decoded, err := base64.StdEncoding.DecodeString(initial)
+		if err != nil {
+			return "", false
+		}
+		parts := strings.Split(string(decoded), "\x00")
+		if len(parts) != 3 || parts[1] != s.username || parts[2] != s.password {
+			return "", false
+		}
+		return "PLAIN " + parts[1], true
+	case "LOGIN":
+		username := s.challenge(text, "Username:")
+		password := s.challenge(text, "Password:")
+		if username != s.username || password != s.password {
+			return "", false
+		}
+		return "LOGIN " + username, true
+	default:
+		return "", false
+	}
+}
+
+func (s *smtpServer) challenge(text *textproto.Conn, prompt string) string {
+	text.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(prompt)))
+
+	line, err := text.ReadLine()
+	if err != nil {
+		return ""
+	}
+
+	decoded, _ := base64.StdEncoding.DecodeString(line)
+
+	return string(decoded)
+}
+
+func trimPath(arg, prefix string) string {
+	arg = strings.TrimPrefix(strings.ToUpper(arg[:len(prefix)]), prefix) + arg[len(prefix):]
+	arg, _, _ = strings.Cut(arg, " ")
+
+	return strings.Trim(arg, "<>")
+}
+
+func (s *smtpServer) stats() (connections int, messages []receivedMessage, logins []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.connections, append([]receivedMessage(nil), s.messages...), append([]string(nil), s.logins...)
+}
+
+func testPayload(to string) email.TransactionalPayload {
+	return email.TransactionalPayload{
+		To:       to,
+		Bcc:      []string{"audit@example.com"},
+		From:     "noreply@example.com",
+		Subject:  "Your Sign-in Link",
+		HTMLBody: "<p>Hello</p>",
+		TextBody: "Hello",
+	}
+}
+
+func TestSMTPDeliversOverStartTLS(t *testing.T) {
+	server := newSMTPServer(t)
+	client := server.client(SMTPConfig{})
+
+	id, err := client.SendTransactional(context.Background(), testPayload("jane@example.com"))
+	if err != nil {
+		t.Fatalf("SendTransactional: %v", err)
+	}
+
+	_, messages, logins := server.stats()
+	if len(messages) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(messages))
+	}
+	if len(logins) != 1 || logins[0] != "PLAIN mailer" {
+		t.Errorf("logins = %q, want PLAIN mailer", logins)
+	}
+
+	received := messages[0]
+	if received.from != "noreply@example.com" {
+		t.Errorf("MAIL FROM = %q", received.from)
+	}
+	if strings.Join(received.to, ",") != "jane@example.com,audit@example.com" {
+		t.Errorf("RCPT TO = %q, want the recipient and the Bcc", received.to)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(received.data))
+	if err != nil {
+		t.Fatalf("parse received message: %v", err)
+	}
+	if got := parsed.Header.Get("Subject"); got != "Your Sign-in Link" {
+		t.Errorf("Subject = %q", got)
+	}
+	if got := parsed.Header.Get("Message-Id"); got != id {
+		t.Errorf("Message-ID = %q, want the returned id %q", got, id)
+	}
+	if parsed.Header.Get("Bcc") != "" {
+		t.Error("Bcc header was sent")
+	}
+}
+
+func TestSMTPAuthLogin(t *testing.T) {
+	server := newSMTPServer(t)
+	server.authMechs = []string{"LOGIN"}
+	client := server.client(SMTPConfig{})
+
+	if _, err := client.SendTransactional(context.Background(), testPayload("jane@example.com")); err != nil {
+		t.Fatalf("SendTransactional: %v", err)
+	}
+
+	if _, _, logins := server.stats(); len(logins) != 1 || logins[0] != "LOGIN mailer" {
+		t.Errorf("logins = %q, want LOGIN mailer", logins)
+	}
+}
+
+func TestSMTPReusesConnections(t *testing.T) {
+	server := newSMTPServer(t)
+	client := server.client(SMTPConfig{MaxMessagesPerConn: 3})
+
+	for range 5 {
+		if _, err := client.SendTransactional(context.Background(), testPayload("jane@example.com")); err != nil {
+			t.Fatalf("SendTransactional: %v", err)
+		}
+	}
+
+	connections, messages, _ := server.stats()
+	if len(messages) != 5 {
+		t.Errorf("server received %d messages, want 5", len(messages))
+	}
+	if connections != 2 {
+		t.Errorf("client opened %d connections, want 2 with 3 messages each at most", connections)
+	}
+}
+
+func TestSMTPRedialsDroppedConnections(t *testing.T) {
+	server := newSMTPServer(t)
+	client := server.client(SMTPConfig{})
+
+	if _, err := client.SendTransactional(context.Background(), testPayload("jane@example.com")); err != nil {
+		t.Fatalf("first send: %v", err)
+	}
+
+	server.dropConnections()
+
+	if _, err := client.SendTransactional(context.Background(), testPayload("jane@example.com")); err != nil {
+		t.Fatalf("send after the server dropped the connection: %v", err)
+	}
+
+	if connections, _, _ := server.stats(); connections != 2 {
+		t.Errorf("client opened %d connections, want 2", connections)
+	}
+}
+
+func TestSMTPRejectedRecipients(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		check func(error) bool
+	}{
+		{
+			name:  "permanent",
+			reply: "550 5.1.1 no such user",
+			check: func(err error) bool { return errors.As(err, new(email.PermanentError)) },
+		},
+		{
+			name:  "temporary",
+			reply: "450 4.2.1 mailbox busy",
+			check: func(err error) bool { return errors.As(err, new(email.TemporaryError)) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newSMTPServer(t)
+			server.rejectRcpt["gone@example.com"] = tt.reply
+			client := server.client(SMTPConfig{})
+
+			_, err := client.SendTransactional(context.Background(), testPayload("gone@example.com"))
+			if !tt.check(err) {
+				t.Fatalf("SendTransactional = %v (%T), want a %s error", err, err, tt.name)
+			}
+
+			// The transaction is reset and the connection kept.
+			if _, err := client.SendTransactional(context.Background(), testPayload("jane@example.com")); err != nil {
+				t.Fatalf("send after a rejection: %v", err)
+			}
+
+			connections, messages, _ := server.stats()
+			if connections != 1 || len(messages) != 1 {
+				t.Errorf("connections = %d, messages = %d, want 1 and 1", connections, len(messages))
+			}
+		})
+	}
+}
+
+func TestSMTPRequiresStartTLS(t *testing.T) {
+	server := newSMTPServer(t)
+	server.offerStartTLS = false
+	client := server.client(SMTPConfig{})
+
+	_, err := client.SendTransactional(context.Background(), testPayload("jane@example.com"))
+	if !errors.Is(err, ErrSMTPStartTLSMissing) {
+		t.Fatalf("SendTransactional = %v, want ErrSMTPStartTLSMissing", err)
+	}
+
+	if _, messages, logins := server.stats(); len(messages) != 0 || len(logins) != 0 {
+		t.Errorf("credentials or mail sent without TLS: logins %q, messages %d", logins, len(messages))
+	}
+}
+
+func TestSMTPRejectsUntrustedCertificate(t *testing.T) {
+	server := newSMTPServer(t)
+	client := server.client(SMTPConfig{TLSConfig: &tls.Config{MinVersion: tls.VersionTLS12}})
+
+	_, err := client.SendTransactional(context.Background(), testPayload("jane@example.com"))
+	if err == nil {
+		t.Fatal("SendTransactional succeeded against an untrusted certificate")
+	}
+
+	if _, _, logins := server.stats(); len(logins) != 0 {
+		t.Errorf("credentials sent to an untrusted server: %q", logins)
+	}
+}
+
+func TestSMTPRespectsContext(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// A server that accepts and never greets.
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		bufio.NewReader(conn).ReadString('\n')
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	client := NewSMTP(SMTPConfig{Host: host, Port: port, Security: SMTPPlain})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = client.SendTransactional(ctx, testPayload("jane@example.com"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("SendTransactional = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("SendTransactional took %s after the deadline", elapsed)
+	}
+}
+
+func TestSMTPShutdown(t *testing.T) {
+	server := newSMTPServer(t)
+	client := server.client(SMTPConfig{})
+
+	if _, err := client.SendTransactional(context.Background(), testPayload("jane@example.com")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	_, err := client.SendTransactional(context.Background(), testPayload("jane@example.com"))
+	if !errors.Is(err, ErrSMTPClosed) {
+		t.Errorf("SendTransactional after Shutdown = %v, want ErrSMTPClosed", err)
+	}
+}
@@ -0,0 +1,7 @@
+package mailclients
+
+import "errors"
+
+// ErrWebhookUnauthorized is a webhook request that does not prove it came
+// from the provider.
+var ErrWebhookUnauthorized = errors.New("webhook request is not authenticated")
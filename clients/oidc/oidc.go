@@ -0,0 +1,221 @@
+// Package oidcclient signs users in with OpenID Connect providers using the
+// authorization code flow with PKCE.
+package oidcclient
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown identity provider")
+	ErrStateMismatch   = errors.New("state does not match the authorization request")
+	ErrNonceMismatch   = errors.New("nonce does not match the authorization request")
+	ErrMissingIDToken  = errors.New("token response did not include an id_token")
+)
+
+type ProviderConfig struct {
+	Name         string
+	DisplayName  string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Provider is a single OpenID Connect provider. Discovery happens on first
+// use, so an unreachable provider does not stop the application from booting.
+type Provider struct {
+	cfg ProviderConfig
+
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func NewProvider(cfg ProviderConfig) *Provider {
+	return &Provider{cfg: cfg}
+}
+
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+func (p *Provider) DisplayName() string {
+	return p.cfg.DisplayName
+}
+
+func (p *Provider) discover(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oauth != nil {
+		return p.oauth, p.verifier, nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, p.cfg.IssuerURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("discover %s: %w", p.cfg.Name, err)
+	}
+
+	p.oauth = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       p.cfg.Scopes,
+	}
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.cfg.ClientID})
+
+	return p.oauth, p.verifier, nil
+}
+
+// AuthRequest is the per-login state that has to survive the round trip to
+// the provider. It must be stored somewhere only this browser can read.
+type AuthRequest struct {
+	Provider     string `json:"provider"`
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// AuthCodeURL starts a login and returns the URL to send the browser to.
+func (p *Provider) AuthCodeURL(ctx context.Context) (string, AuthRequest, error) {
+	oauth, _, err := p.discover(ctx)
+	if err != nil {
+		return "", AuthRequest{}, err
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return "", AuthRequest{}, err
+	}
+
+	nonce, err := randomString()
+	if err != nil {
+		return "", AuthRequest{}, err
+	}
+
+	req := AuthRequest{
+		Provider:     p.cfg.Name,
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: oauth2.GenerateVerifier(),
+	}
+
+	url := oauth.AuthCodeURL(
+		req.State,
+		oidc.Nonce(req.Nonce),
+		oauth2.S256ChallengeOption(req.CodeVerifier),
+	)
+
+	return url, req, nil
+}
+
+// Claims are the verified facts about the user taken from the ID token.
+type Claims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// Exchange redeems the authorization code, verifies the ID token signature,
+// issuer, audience and expiry, and checks state and nonce against the
+// original request.
+func (p *Provider) Exchange(
+	ctx context.Context,
+	req AuthRequest,
+	state string,
+	code string,
+) (Claims, error) {
+	if req.Provider != p.cfg.Name ||
+		subtle.ConstantTimeCompare([]byte(req.State), []byte(state)) != 1 {
+		return Claims{}, ErrStateMismatch
+	}
+
+	oauth, verifier, err := p.discover(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	token, err := oauth.Exchange(ctx, code, oauth2.VerifierOption(req.CodeVerifier))
+	if err != nil {
+		return Claims{}, err
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return Claims{}, ErrMissingIDToken
+	}
+
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(req.Nonce)) != 1 {
+		return Claims{}, ErrNonceMismatch
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return Claims{}, err
+	}
+
+	return Claims{
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+	}, nil
+}
+
+// Providers looks up configured providers by name.
+type Providers struct {
+	byName map[string]*Provider
+	order  []*Provider
+}
+
+func NewProviders(cfgs ...ProviderConfig) Providers {
+	providers := Providers{byName: make(map[string]*Provider, len(cfgs))}
+	for _, cfg := range cfgs {
+		provider := NewProvider(cfg)
+		providers.byName[cfg.Name] = provider
+		providers.order = append(providers.order, provider)
+	}
+
+	return providers
+}
+
+func (p Providers) Get(name string) (*Provider, error) {
+	provider, ok := p.byName[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	return provider, nil
+}
+
+func (p Providers) All() []*Provider {
+	return p.order
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
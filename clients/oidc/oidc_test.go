@@ -0,0 +1,376 @@
+package oidcclient
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeIdP is an in-process OpenID Connect provider. It serves discovery,
+// keys and a token endpoint that checks the client secret and the PKCE
+// verifier, and issues ID tokens shaped by the test.
+type fakeIdP struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu sync.Mutex
+	// pending maps issued codes to the authorization request they answer.
+	pending map[string]url.Values
+	// idToken rewrites the claims or the signing key of the next token.
+	idToken func(claims map[string]any) (map[string]any, *rsa.PrivateKey)
+	// omitIDToken leaves the id_token out of the token response.
+	omitIDToken bool
+}
+
+const (
+	fakeClientID     = "app-client"
+	fakeClientSecret = "app-secret"
+)
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &fakeIdP{t: t, key: key, pending: make(map[string]url.Values)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("GET /keys", idp.keys)
+	mux.HandleFunc("POST /token", idp.token)
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+func (f *fakeIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                f.server.URL,
+		"authorization_endpoint":                f.server.URL + "/authorize",
+		"token_endpoint":                        f.server.URL + "/token",
+		"jwks_uri":                              f.server.URL + "/keys",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (f *fakeIdP) keys(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(f.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(f.key.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize stands in for the user signing in at the provider: it accepts
+// the authorization URL and returns the code the browser would bring back.
+func (f *fakeIdP) authorize(authURL string) (state, code string) {
+	f.t.Helper()
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+
+	query := parsed.Query()
+	if query.Get("client_id") != fakeClientID || query.Get("code_challenge_method") != "S256" {
+		f.t.Fatalf("unexpected authorization request %s", authURL)
+	}
+
+	code = base64.RawURLEncoding.EncodeToString([]byte(query.Get("state")))
+
+	f.mu.Lock()
+	f.pending[code] = query
+	f.mu.Unlock()
+
+	return query.Get("state"), code
+}
+
+func (f *fakeIdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != fakeClientID || clientSecret != fakeClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	f.mu.Lock()
+	request, ok := f.pending[r.PostForm.Get("code")]
+	delete(f.pending, r.PostForm.Get("code"))
+	f.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(challenge[:]) != request.Get("code_challenge") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := map[string]any{
+		"iss":            f.server.URL,
+		"sub":            "idp-user-1",
+		"aud":            fakeClientID,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(5 * time.Minute).Unix(),
+		"nonce":          request.Get("nonce"),
+		"email":          "jane@example.com",
+		"email_verified": true,
+	}
+	key := f.key
+	if f.idToken != nil {
+		claims, key = f.idToken(claims)
+	}
+
+	response := map[string]any{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   300,
+	}
+	if !f.omitIDToken {
+		response["id_token"] = signJWT(f.t, key, claims)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func signJWT(t *testing.T, key *rsa.PrivateKey, claims map[string]any) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func (f *fakeIdP) provider() *Provider {
+	return NewProvider(ProviderConfig{
+		Name:         "fake",
+		DisplayName:  "Fake",
+		IssuerURL:    f.server.URL,
+		ClientID:     fakeClientID,
+		ClientSecret: fakeClientSecret,
+		RedirectURL:  "http://localhost:8080/auth/fake/callback",
+		Scopes:       []string{"openid", "email"},
+	})
+}
+
+func TestProviderExchange(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		setup   func(idp *fakeIdP, req *AuthRequest, state *string)
+		wantErr error
+	}{
+		{
+			name: "valid login",
+		},
+		{
+			name: "state from another login",
+			setup: func(idp *fakeIdP, req *AuthRequest, state *string) {
+				*state = "forged"
+			},
+			wantErr: ErrStateMismatch,
+		},
+		{
+			name: "request for another provider",
+			setup: func(idp *fakeIdP, req *AuthRequest, state *string) {
+				req.Provider = "other"
+			},
+			wantErr: ErrStateMismatch,
+		},
+		{
+			name: "replayed id token nonce",
+			setup: func(idp *fakeIdP, req *AuthRequest, state *string) {
+				idp.idToken = func(claims map[string]any) (map[string]any, *rsa.PrivateKey) {
+					claims["nonce"] = "stolen"
+					return claims, idp.key
+				}
+			},
+			wantErr: ErrNonceMismatch,
+		},
+		{
+			name: "missing id token",
+			setup: func(idp *fakeIdP, req *AuthRequest, state *string) {
+				idp.omitIDToken = true
+			},
+			wantErr: ErrMissingIDToken,
+		},
+		{
+			name: "wrong code verifier",
+			setup: func(idp *fakeIdP, req *AuthRequest, state *string) {
+				req.CodeVerifier = "intercepted-code-without-the-verifier-xxxxxxxxxxxx"
+			},
+		},
+		{
+			name: "token for another client",
+			setup: func(idp *fakeIdP, req *AuthRequest, state *string) {
+				idp.idToken = func(claims map[string]any) (map[string]any, *rsa.PrivateKey) {
+					claims["aud"] = "another-client"
+					return claims, idp.key
+				}
+			},
+		},
+		{
+			name: "expired token",
+			setup: func(idp *fakeIdP, req *AuthRequest, state *string) {
+				idp.idToken = func(claims map[string]any) (map[string]any, *rsa.PrivateKey) {
+					claims["exp"] = time.Now().Add(-time.Hour).Unix()
+					return claims, idp.key
+				}
+			},
+		},
+		{
+			name: "token from another issuer",
+			setup: func(idp *fakeIdP, req *AuthRequest, state *string) {
+				idp.idToken = func(claims map[string]any) (map[string]any, *rsa.PrivateKey) {
+					claims["iss"] = "https://evil.example.com"
+					return claims, idp.key
+				}
+			},
+		},
+		{
+			name: "token signed with an unknown key",
+			setup: func(idp *fakeIdP, req *AuthRequest, state *string) {
+				idp.idToken = func(claims map[string]any) (map[string]any, *rsa.PrivateKey) {
+					return claims, otherKey
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			idp := newFakeIdP(t)
+			provider := idp.provider()
+
+			authURL, req, err := provider.AuthCodeURL(ctx)
+			if err != nil {
+				t.Fatalf("AuthCodeURL: %v", err)
+			}
+			if !strings.HasPrefix(authURL, idp.server.URL+"/authorize?") {
+				t.Fatalf("AuthCodeURL = %s, want the provider's authorization endpoint", authURL)
+			}
+
+			state, code := idp.authorize(authURL)
+
+			wantSuccess := tt.setup == nil
+			if tt.setup != nil {
+				tt.setup(idp, &req, &state)
+			}
+
+			claims, err := provider.Exchange(ctx, req, state, code)
+			switch {
+			case wantSuccess:
+				if err != nil {
+					t.Fatalf("Exchange: %v", err)
+				}
+				want := Claims{Subject: "idp-user-1", Email: "jane@example.com", EmailVerified: true}
+				if claims != want {
+					t.Errorf("Exchange = %+v, want %+v", claims, want)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Exchange = %v, want %v", err, tt.wantErr)
+				}
+			case err == nil:
+				t.Errorf("Exchange succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestProviderCodeIsSingleUse(t *testing.T) {
+	ctx := context.Background()
+	idp := newFakeIdP(t)
+	provider := idp.provider()
+
+	authURL, req, err := provider.AuthCodeURL(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, code := idp.authorize(authURL)
+
+	if _, err := provider.Exchange(ctx, req, state, code); err != nil {
+		t.Fatalf("first Exchange: %v", err)
+	}
+	if _, err := provider.Exchange(ctx, req, state, code); err == nil {
+		t.Error("second Exchange with the same code succeeded")
+	}
+}
+
+func TestProvidersGet(t *testing.T) {
+	providers := NewProviders(
+		ProviderConfig{Name: "google"},
+		ProviderConfig{Name: "github"},
+	)
+
+	if provider, err := providers.Get("github"); err != nil || provider.Name() != "github" {
+		t.Errorf("Get(github) = %v, %v", provider, err)
+	}
+	if _, err := providers.Get("gitlab"); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("Get(gitlab) = %v, want ErrUnknownProvider", err)
+	}
+	if all := providers.All(); len(all) != 2 || all[0].Name() != "google" {
+		t.Errorf("All() = %v, want providers in configured order", all)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/hex"
 	"fmt"
 	"log/slog"
+	"net"
+	"net/url"
 	"os"
 	"os/signal"
 	"strings"
@@ -13,15 +15,21 @@ import (
 	"mbvlabs/config"
 	"mbvlabs/controllers"
 	"mbvlabs/database"
+	"mbvlabs/email"
+	"mbvlabs/internal/passwords"
 	"mbvlabs/internal/server"
 	"mbvlabs/internal/storage"
+	"mbvlabs/models"
 	"mbvlabs/queue"
 	"mbvlabs/router"
 	"mbvlabs/router/middleware"
+	"mbvlabs/services"
 	"mbvlabs/telemetry"
 	"mbvlabs/queue/workers"
 	"riverqueue.com/riverui"
 	"mbvlabs/clients/email"
+	"mbvlabs/clients/oidc"
+	"mbvlabs/router/routes"
 
 	"github.com/a-h/templ"
 	//"github.com/labstack/echo/v4"
@@ -49,10 +57,37 @@ func setupControllers(
 	assets := controllers.NewAssets(assetsCache)
 	api := controllers.NewAPI(db)
 	pages := controllers.NewPages(db, insertOnly, pagesCache)
-	sessions := controllers.NewSessions(db, cfg)
+	sessions := controllers.NewSessions(db, insertOnly, cfg)
 	registrations := controllers.NewRegistrations(db, insertOnly, cfg)
-	confirmations := controllers.NewConfirmations(db, cfg)
+	confirmations := controllers.NewConfirmations(db, insertOnly, cfg)
 	resetPasswords := controllers.NewResetPasswords(db, insertOnly, cfg)
+	twoFactors := controllers.NewTwoFactors(db, cfg)
+	twoFactorChallenges := controllers.NewTwoFactorChallenges(db, insertOnly, cfg)
+	passkeys := controllers.NewPasskeys(db, cfg)
+	passkeySessions := controllers.NewPasskeySessions(db, cfg)
+	magicLinks := controllers.NewMagicLinks(db, insertOnly, cfg)
+
+	oidcProviders, err := setupOIDCProviders(cfg)
+	if err != nil {
+		return err
+	}
+	oidcSessions := controllers.NewOIDCSessions(db, insertOnly, cfg, oidcProviders)
+	accounts := controllers.NewAccounts(db, cfg)
+	emailChanges := controllers.NewEmailChanges(db, insertOnly, cfg)
+	apiTokens := controllers.NewAPITokens(db, cfg)
+	adminUsers := controllers.NewAdminUsers(db, insertOnly, cfg)
+	impersonations := controllers.NewImpersonations(db, cfg)
+	adminAuditEvents := controllers.NewAdminAuditEvents(db, cfg)
+	dataExports := controllers.NewDataExports(db, insertOnly, cfg)
+	accountDeletions := controllers.NewAccountDeletions(db, insertOnly, cfg)
+	passwords := controllers.NewPasswords(db, insertOnly, cfg)
+	devices := controllers.NewDevices(db, cfg)
+	organizations := controllers.NewOrganizations(db, cfg)
+	organizationMembers := controllers.NewOrganizationMembers(db, cfg)
+	organizationInvitations := controllers.NewOrganizationInvitations(db, insertOnly, cfg)
+	adminEmailMessages := controllers.NewAdminEmailMessages(db, cfg)
+	adminEmailSuppressions := controllers.NewAdminEmailSuppressions(db, cfg)
+	emailWebhooks := controllers.NewEmailWebhooks(db, cfg)
 
 	rtr.RegisterCtrlRoutes(
 		mw,
@@ -63,6 +98,28 @@ func setupControllers(
 		registrations,
 		confirmations,
 		resetPasswords,
+		twoFactors,
+		twoFactorChallenges,
+		passkeys,
+		passkeySessions,
+		magicLinks,
+		oidcSessions,
+		accounts,
+		emailChanges,
+		apiTokens,
+		adminUsers,
+		impersonations,
+		adminAuditEvents,
+		dataExports,
+		accountDeletions,
+		passwords,
+		devices,
+		organizations,
+		organizationMembers,
+		organizationInvitations,
+		adminEmailMessages,
+		adminEmailSuppressions,
+		emailWebhooks,
 	)
 
 	rtr.RegisterCustomRoutes(
@@ -73,6 +130,102 @@ func setupControllers(
 	return nil
 }
 
+func setupOIDCProviders(cfg config.Config) (oidcclient.Providers, error) {
+	providerCfgs := make([]oidcclient.ProviderConfig, len(cfg.OIDC.Providers))
+	for i, provider := range cfg.OIDC.Providers {
+		redirectURL, err := url.JoinPath(config.BaseURL, routes.OIDCSessionCallback.URL(provider.Name))
+		if err != nil {
+			return oidcclient.Providers{}, err
+		}
+
+		providerCfgs[i] = oidcclient.ProviderConfig{
+			Name:         provider.Name,
+			DisplayName:  provider.DisplayName,
+			IssuerURL:    provider.IssuerURL,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       provider.Scopes,
+		}
+	}
+
+	return oidcclient.NewProviders(providerCfgs...), nil
+}
+
+// setupBreachChecker uses the local Pwned Passwords corpus when one is
+// configured, and the bundled common password list otherwise.
+func setupBreachChecker(cfg config.Config) passwords.BreachChecker {
+	if cfg.Auth.PasswordBreachCorpusDir == "" {
+		return passwords.Bundled()
+	}
+
+	return passwords.NewRangeCorpus(os.DirFS(cfg.Auth.PasswordBreachCorpusDir))
+}
+
+// emailSender is what the email workers need from a delivery backend.
+type emailSender interface {
+	email.TransactionalSender
+	email.MarketingSender
+}
+
+// setupEmailSender delivers through the providers listed in the config,
+// failing over between them. Without any it delivers through the
+// configured SMTP server, or to Mailpit when none is configured.
+func setupEmailSender(cfg config.Config) (emailSender, error) {
+	if len(cfg.Email.Providers) == 0 {
+		if cfg.Email.SMTPHost == "" {
+			return mailclients.NewMailpit(cfg.Email.MailpitHost, cfg.Email.MailpitPort), nil
+		}
+
+		return setupSMTPSender(cfg), nil
+	}
+
+	providers := make([]mailclients.FailoverProvider, len(cfg.Email.Providers))
+	for i, name := range cfg.Email.Providers {
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		var sender mailclients.Sender
+		switch name {
+		case "postmark":
+			sender = mailclients.NewPostmark(mailclients.PostmarkConfig{
+				ServerToken:     cfg.Email.PostmarkServerToken,
+				MessageStream:   cfg.Email.PostmarkMessageStream,
+				BroadcastStream: cfg.Email.PostmarkBroadcastStream,
+			})
+		case "sendgrid":
+			sender = mailclients.NewSendGrid(mailclients.SendGridConfig{
+				APIKey: cfg.Email.SendGridAPIKey,
+			})
+		case "smtp":
+			sender = setupSMTPSender(cfg)
+		default:
+			return nil, fmt.Errorf("unknown email provider %q", name)
+		}
+
+		providers[i] = mailclients.FailoverProvider{Name: name, Sender: sender}
+	}
+
+	return mailclients.NewFailover(cfg.Email.ProviderCooldown, providers...), nil
+}
+
+func setupSMTPSender(cfg config.Config) *mailclients.SMTP {
+	localName := config.Domain
+	if host, _, err := net.SplitHostPort(config.Domain); err == nil {
+		localName = host
+	}
+
+	return mailclients.NewSMTP(mailclients.SMTPConfig{
+		Host:         cfg.Email.SMTPHost,
+		Port:         cfg.Email.SMTPPort,
+		Security:     mailclients.SMTPSecurity(cfg.Email.SMTPSecurity),
+		Username:     cfg.Email.SMTPUsername,
+		Password:     cfg.Email.SMTPPassword,
+		Auth:         mailclients.SMTPAuthMechanism(cfg.Email.SMTPAuth),
+		LocalName:    localName,
+		MaxIdleConns: cfg.Email.SMTPMaxIdleConns,
+	})
+}
+
 func setupRouter(
 	ctx context.Context,
 	cfg config.Config,
@@ -147,6 +300,25 @@ func run(ctx context.Context) error {
 
 	cfg := config.NewConfig()
 
+	models.ConfigurePasswordHashing(models.PasswordHashing{
+		Params: models.Argon2Params{
+			Time:    cfg.Auth.PasswordHashTime,
+			Memory:  cfg.Auth.PasswordHashMemory,
+			Threads: cfg.Auth.PasswordHashThreads,
+		},
+		PepperID:       cfg.Auth.PepperID,
+		RetiredPeppers: cfg.Auth.PreviousPeppers,
+	})
+
+	models.ConfigureTOTPEncryption(cfg.Auth.TOTPEncryptionKey)
+
+	services.ConfigurePasswordPolicy(services.PasswordPolicy{
+		MinLength: cfg.Auth.PasswordMinLength,
+		MaxLength: cfg.Auth.PasswordMaxLength,
+		MinScore:  cfg.Auth.PasswordMinScore,
+		Breaches:  setupBreachChecker(cfg),
+	})
+
 	tel, err := buildTelemetry(ctx, cfg)
 	if err != nil {
 		return fmt.Errorf("failed to initialize telemetry: %w", err)
@@ -168,9 +340,18 @@ func run(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	emailClient := mailclients.NewMailpit(cfg.Email.MailpitHost, cfg.Email.MailpitPort)
+	emailClient, err := setupEmailSender(cfg)
+	if err != nil {
+		return err
+	}
 
-	wrks, err := workers.Register(emailClient, emailClient)
+	wrks, err := workers.Register(
+		db,
+		cfg.Auth.Pepper,
+		cfg.Auth.UnverifiedUserMaxAge,
+		emailClient,
+		emailClient,
+	)
 	if err != nil {
 		return err
 	}
@@ -187,12 +368,13 @@ func run(ctx context.Context) error {
 		ctx,
 		db,
 		wrks,
+		workers.PeriodicJobs(),
 	)
 	if err != nil {
 		return err
 	}
 
-	mw := middleware.New(db)
+	mw := middleware.New(db, cfg)
 
 	endpoints := riverui.NewEndpoints(processor.Client, nil)
 	opts := &riverui.HandlerOpts{
@@ -226,13 +408,20 @@ func run(ctx context.Context) error {
 
 	handler := rtr.Handler
 
+	// The processor goes first, so no email job is still sending when the
+	// email client closes its connections.
+	shutdowners := []server.Shutdowner{processor}
+	if shutdowner, ok := emailClient.(server.Shutdowner); ok {
+		shutdowners = append(shutdowners, shutdowner)
+	}
+
 	server := server.New(
 		ctx,
 		cfg.App.Host,
 		cfg.App.Port,
 		config.Env,
 		handler,
-		[]server.Shutdowner{processor},
+		shutdowners,
 	)
 
 	slog.InfoContext(ctx, "starting server", "host", cfg.App.Host, "port", cfg.App.Port)
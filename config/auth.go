@@ -1,9 +1,42 @@
 package config
 
-import "github.com/caarlos0/env/v10"
+import (
+	"time"
+
+	"github.com/caarlos0/env/v10"
+)
 
 type auth struct {
 	Pepper         string `env:"PEPPER"`
+	// RequireAdminTwoFactor forces admins to enroll in TOTP before they can
+	// use the rest of the application.
+	RequireAdminTwoFactor bool `env:"REQUIRE_ADMIN_TWO_FACTOR" envDefault:"true"`
+	// PepperID names the current pepper. Bump it when rotating PEPPER and
+	// move the old value to PREVIOUS_PEPPERS as "id:pepper". Password
+	// hashes, tokens, API tokens, remember tokens and recovery codes made
+	// with a retired pepper keep working until they are replaced, so only
+	// drop a retired pepper once those have expired or been reissued.
+	PepperID        string            `env:"PEPPER_ID" envDefault:"1"`
+	PreviousPeppers map[string]string `env:"PREVIOUS_PEPPERS" envDefault:""`
+	// TOTPEncryptionKey seals TOTP secrets at rest. It is independent of the
+	// pepper and cannot be rotated without re-enrolling every authenticator.
+	TOTPEncryptionKey string `env:"TOTP_ENCRYPTION_KEY"`
+	// Argon2id cost for new password hashes. Weaker stored hashes are
+	// upgraded when their owner signs in.
+	PasswordHashTime    uint32 `env:"PASSWORD_HASH_TIME" envDefault:"2"`
+	PasswordHashMemory  uint32 `env:"PASSWORD_HASH_MEMORY_KIB" envDefault:"19456"`
+	PasswordHashThreads uint8  `env:"PASSWORD_HASH_THREADS" envDefault:"1"`
+	// Policy for new passwords. PasswordMinScore is the lowest accepted
+	// strength estimate, from 0 to 4. PASSWORD_BREACH_CORPUS_DIR points at
+	// a local Pwned Passwords corpus in range format; without it only the
+	// bundled list of common passwords is checked.
+	PasswordMinLength       int    `env:"PASSWORD_MIN_LENGTH" envDefault:"8"`
+	PasswordMaxLength       int    `env:"PASSWORD_MAX_LENGTH" envDefault:"72"`
+	PasswordMinScore        int    `env:"PASSWORD_MIN_SCORE" envDefault:"3"`
+	PasswordBreachCorpusDir string `env:"PASSWORD_BREACH_CORPUS_DIR" envDefault:""`
+	// UnverifiedUserMaxAge is how long an account may go without verifying
+	// its email before a cleanup job removes it. Zero keeps them forever.
+	UnverifiedUserMaxAge time.Duration `env:"UNVERIFIED_USER_MAX_AGE" envDefault:"720h"`
 }
 
 func newAuthConfig() auth {
@@ -63,6 +63,7 @@ type Config struct {
 	Telemetry telemetry
 	Email email
 	Auth auth
+	OIDC oidc
 }
 
 func NewConfig() Config {
@@ -72,5 +73,6 @@ func NewConfig() Config {
 		Telemetry: newTelemetryConfig(),
 		Email: newEmailConfig(),
 		Auth: newAuthConfig(),
+		OIDC: newOIDCConfig(),
 	}
 }
@@ -1,12 +1,43 @@
 package config
 
 import (
+	"time"
+
 	"github.com/caarlos0/env/v11"
 )
 
 type email struct {
 	MailpitHost string `env:"MAILPIT_HOST" envDefault:"0.0.0.0"`
 	MailpitPort string `env:"MAILPIT_PORT" envDefault:"1025"`
+	// SMTPHost switches delivery from Mailpit to an SMTP submission server.
+	// SMTPSecurity is "starttls", "tls" for implicit TLS or "none", and
+	// SMTPAuth is "plain", "login" or empty to pick what the server offers.
+	SMTPHost     string `env:"SMTP_HOST" envDefault:""`
+	SMTPPort     string `env:"SMTP_PORT" envDefault:"587"`
+	SMTPSecurity string `env:"SMTP_SECURITY" envDefault:"starttls"`
+	SMTPUsername string `env:"SMTP_USERNAME" envDefault:""`
+	SMTPPassword string `env:"SMTP_PASSWORD" envDefault:""`
+	SMTPAuth     string `env:"SMTP_AUTH" envDefault:""`
+	// SMTPMaxIdleConns is how many connections are kept open for reuse.
+	SMTPMaxIdleConns int `env:"SMTP_MAX_IDLE_CONNS" envDefault:"2"`
+	// Providers lists what to deliver through, in order of preference:
+	// "postmark", "sendgrid" and "smtp". A provider failing with a
+	// temporary error hands the message to the next one and is tried last
+	// for ProviderCooldown. Without any, delivery goes through SMTP when
+	// SMTPHost is set and to Mailpit otherwise.
+	Providers               []string      `env:"EMAIL_PROVIDERS" envDefault:""`
+	ProviderCooldown        time.Duration `env:"EMAIL_PROVIDER_COOLDOWN" envDefault:"1m"`
+	PostmarkServerToken     string        `env:"POSTMARK_SERVER_TOKEN" envDefault:""`
+	PostmarkMessageStream   string        `env:"POSTMARK_MESSAGE_STREAM" envDefault:"outbound"`
+	PostmarkBroadcastStream string        `env:"POSTMARK_BROADCAST_STREAM" envDefault:"broadcast"`
+	SendGridAPIKey          string        `env:"SENDGRID_API_KEY" envDefault:""`
+	// The bounce and spam complaint webhooks are off until their secrets are
+	// set. Postmark is given the username and password as basic auth in
+	// the webhook URL; SendGridWebhookPublicKey is the verification key of
+	// SendGrid's signed event webhook.
+	PostmarkWebhookUsername  string `env:"POSTMARK_WEBHOOK_USERNAME" envDefault:""`
+	PostmarkWebhookPassword  string `env:"POSTMARK_WEBHOOK_PASSWORD" envDefault:""`
+	SendGridWebhookPublicKey string `env:"SENDGRID_WEBHOOK_PUBLIC_KEY" envDefault:""`
 }
 
 func newEmailConfig() email {
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"os"
+	"strings"
+
+	"github.com/caarlos0/env/v11"
+)
+
+// OIDCProvider holds the client registration for one OpenID Connect
+// provider. Each provider listed in OIDC_PROVIDERS is read from variables
+// prefixed with OIDC_<NAME>_, e.g. OIDC_GOOGLE_CLIENT_ID.
+type OIDCProvider struct {
+	Name         string
+	DisplayName  string   `env:"DISPLAY_NAME" envDefault:""`
+	IssuerURL    string   `env:"ISSUER_URL"`
+	ClientID     string   `env:"CLIENT_ID"`
+	ClientSecret string   `env:"CLIENT_SECRET"`
+	Scopes       []string `env:"SCOPES" envDefault:"openid,email,profile"`
+}
+
+type oidc struct {
+	Providers []OIDCProvider
+}
+
+func newOIDCConfig() oidc {
+	cfg := oidc{}
+
+	for name := range strings.SplitSeq(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		provider := OIDCProvider{Name: name}
+		if err := env.ParseWithOptions(&provider, env.Options{
+			Prefix:          "OIDC_" + strings.ToUpper(name) + "_",
+			RequiredIfNoDef: true,
+		}); err != nil {
+			panic(err)
+		}
+
+		if provider.DisplayName == "" {
+			provider.DisplayName = name
+		}
+
+		cfg.Providers = append(cfg.Providers, provider)
+	}
+
+	return cfg
+}
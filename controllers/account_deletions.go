@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/queue"
+	"mbvlabs/router/cookies"
+	"mbvlabs/router/routes"
+	"mbvlabs/services"
+	"mbvlabs/views"
+
+	"github.com/labstack/echo/v4"
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+type AccountDeletions struct {
+	db         storage.Pool
+	insertOnly queue.InsertOnly
+	cfg        config.Config
+}
+
+func NewAccountDeletions(
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	cfg config.Config,
+) AccountDeletions {
+	return AccountDeletions{db, insertOnly, cfg}
+}
+
+// Create schedules the account for deletion. Every session is revoked, so
+// the browser is signed out.
+func (a AccountDeletions) Create(c echo.Context) error {
+	var payload struct {
+		DeleteConfirmation string `json:"deleteConfirmation"`
+	}
+
+	if err := c.Bind(&payload); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"could not parse account deletion payload",
+			"error",
+			err,
+		)
+		return render(c, views.BadRequest())
+	}
+
+	user, err := services.ScheduleAccountDeletion(
+		c.Request().Context(),
+		a.db,
+		a.insertOnly,
+		a.cfg.Auth.Pepper,
+		services.ScheduleAccountDeletionData{
+			UserID:       cookies.GetApp(c).UserID,
+			Confirmation: payload.DeleteConfirmation,
+		},
+	)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to schedule account deletion",
+			"error",
+			err,
+		)
+
+		var errorMsg string
+		switch {
+		case errors.Is(err, services.ErrDeletionConfirmationMismatch):
+			errorMsg = "Type your email address exactly to confirm"
+		case errors.Is(err, services.ErrDeletionAlreadyScheduled):
+			errorMsg = "Your account is already scheduled for deletion"
+		case errors.Is(err, services.ErrLastOrganizationOwner):
+			errorMsg = "You are the only owner of an organization. Make someone else an owner first."
+		default:
+			errorMsg = "Failed to delete your account"
+		}
+
+		if flashErr := cookies.AddFlash(c, cookies.FlashError, errorMsg); flashErr != nil {
+			return render(c, views.InternalError())
+		}
+
+		return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.AccountShow.URL())
+	}
+
+	if err := cookies.DestroyAppSession(c); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to clear session cookie",
+			"error",
+			err,
+		)
+	}
+
+	message := fmt.Sprintf(
+		"Your account will be deleted on %s. To keep it, use the link we emailed you or sign in again and visit your account settings.",
+		user.DeletionScheduledAt.Format("January 2, 2006"),
+	)
+	if flashErr := cookies.AddFlash(c, cookies.FlashSuccess, message); flashErr != nil {
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.SessionNew.URL())
+}
+
+func (a AccountDeletions) Destroy(c echo.Context) error {
+	if err := services.CancelAccountDeletion(
+		c.Request().Context(),
+		a.db,
+		cookies.GetApp(c).UserID,
+	); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to cancel account deletion",
+			"error",
+			err,
+		)
+
+		errorMsg := "Failed to keep your account"
+		if errors.Is(err, services.ErrDeletionNotScheduled) {
+			errorMsg = "Your account is not scheduled for deletion"
+		}
+
+		if flashErr := cookies.AddFlash(c, cookies.FlashError, errorMsg); flashErr != nil {
+			return render(c, views.InternalError())
+		}
+
+		return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.AccountShow.URL())
+	}
+
+	if flashErr := cookies.AddFlash(c, cookies.FlashSuccess, "Your account will not be deleted."); flashErr != nil {
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.AccountShow.URL())
+}
+
+func (a AccountDeletions) ShowCancel(c echo.Context) error {
+	c.Response().Header().Set("Referrer-Policy", "strict-origin")
+
+	return render(c, views.AccountDeletionCancelForm(c.Param("token")))
+}
+
+func (a AccountDeletions) Cancel(c echo.Context) error {
+	if _, err := services.CancelAccountDeletionWithToken(
+		c.Request().Context(),
+		a.db,
+		a.cfg.Auth.Pepper,
+		c.Param("token"),
+	); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to cancel account deletion",
+			"error",
+			err,
+		)
+
+		errorMsg := "Failed to keep your account"
+		if errors.Is(err, services.ErrInvalidDeletionCancel) {
+			errorMsg = "That link is invalid or has expired"
+		}
+
+		if flashErr := cookies.AddFlash(c, cookies.FlashError, errorMsg); flashErr != nil {
+			return render(c, views.InternalError())
+		}
+
+		return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.SessionNew.URL())
+	}
+
+	if flashErr := cookies.AddFlash(c, cookies.FlashSuccess, "Your account will not be deleted. Sign in to continue."); flashErr != nil {
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.SessionNew.URL())
+}
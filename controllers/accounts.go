@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"log/slog"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/router/cookies"
+	"mbvlabs/services"
+	"mbvlabs/views"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Accounts struct {
+	db  storage.Pool
+	cfg config.Config
+}
+
+func NewAccounts(db storage.Pool, cfg config.Config) Accounts {
+	return Accounts{db, cfg}
+}
+
+func (a Accounts) Show(c echo.Context) error {
+	userID := cookies.GetApp(c).UserID
+
+	user, err := models.FindUser(c.Request().Context(), a.db.Conn(), userID)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to find user",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	pendingEmail, err := services.PendingEmailChange(c.Request().Context(), a.db, userID)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to look up pending email change",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	return render(c, views.AccountShow(user, pendingEmail))
+}
@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/router/cookies"
+	"mbvlabs/services"
+	"mbvlabs/views"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	adminAuditEventsPageSize = 50
+	auditFilterDateLayout    = "2006-01-02"
+)
+
+type AdminAuditEvents struct {
+	db  storage.Pool
+	cfg config.Config
+}
+
+func NewAdminAuditEvents(db storage.Pool, cfg config.Config) AdminAuditEvents {
+	return AdminAuditEvents{db, cfg}
+}
+
+func (a AdminAuditEvents) Index(c echo.Context) error {
+	form, filter, ok := parseAuditEventFilter(c)
+	if !ok {
+		return render(c, views.BadRequest())
+	}
+
+	page, err := strconv.ParseInt(c.QueryParam("page"), 10, 64)
+	if err != nil {
+		page = 1
+	}
+
+	events, err := models.PaginateAuditEvents(
+		c.Request().Context(),
+		a.db.Conn(),
+		filter,
+		page,
+		adminAuditEventsPageSize,
+	)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to list audit events",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	return render(c, views.AdminAuditEventIndex(events, form))
+}
+
+// Export streams every matching event as CSV. Headers are sent before the
+// first row, so a failure part way through can only be logged.
+func (a AdminAuditEvents) Export(c echo.Context) error {
+	_, filter, ok := parseAuditEventFilter(c)
+	if !ok {
+		return render(c, views.BadRequest())
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().Header().Set(
+		echo.HeaderContentDisposition,
+		fmt.Sprintf(`attachment; filename="audit-events-%s.csv"`, time.Now().Format(auditFilterDateLayout)),
+	)
+	c.Response().WriteHeader(http.StatusOK)
+
+	if err := services.ExportAuditEvents(
+		c.Request().Context(),
+		a.db,
+		cookies.GetApp(c).UserID,
+		filter,
+		c.Response(),
+	); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to export audit events",
+			"error",
+			err,
+		)
+	}
+
+	return nil
+}
+
+// parseAuditEventFilter reads the filter form from the query string. Dates
+// are whole days, with until being inclusive.
+func parseAuditEventFilter(c echo.Context) (views.AuditEventFilterForm, models.AuditEventFilter, bool) {
+	form := views.AuditEventFilterForm{
+		Actor:   strings.TrimSpace(c.QueryParam("actor")),
+		Subject: strings.TrimSpace(c.QueryParam("subject")),
+		Action:  strings.TrimSpace(c.QueryParam("action")),
+		Since:   c.QueryParam("since"),
+		Until:   c.QueryParam("until"),
+	}
+
+	filter := models.AuditEventFilter{Action: form.Action}
+
+	if form.Actor != "" {
+		id, err := uuid.Parse(form.Actor)
+		if err != nil {
+			return form, filter, false
+		}
+		filter.ActorID = id
+	}
+
+	if form.Subject != "" {
+		id, err := uuid.Parse(form.Subject)
+		if err != nil {
+			return form, filter, false
+		}
+		filter.SubjectID = id
+	}
+
+	if form.Since != "" {
+		since, err := time.Parse(auditFilterDateLayout, form.Since)
+		if err != nil {
+			return form, filter, false
+		}
+		filter.Since = since
+	}
+
+	if form.Until != "" {
+		until, err := time.Parse(auditFilterDateLayout, form.Until)
+		if err != nil {
+			return form, filter, false
+		}
+		filter.Until = until.AddDate(0, 0, 1)
+	}
+
+	return form, filter, true
+}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/views"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const adminEmailMessagesPageSize = 50
+
+type AdminEmailMessages struct {
+	db  storage.Pool
+	cfg config.Config
+}
+
+func NewAdminEmailMessages(db storage.Pool, cfg config.Config) AdminEmailMessages {
+	return AdminEmailMessages{db, cfg}
+}
+
+// Index lists logged email, searchable by subject and recipient and
+// filterable by status.
+func (a AdminEmailMessages) Index(c echo.Context) error {
+	filter := models.EmailMessageFilter{
+		Search: strings.TrimSpace(c.QueryParam("search")),
+		Status: models.EmailMessageStatus(c.QueryParam("status")),
+	}
+	if filter.Status != "" && !filter.Status.Valid() {
+		return render(c, views.BadRequest())
+	}
+
+	page, err := strconv.ParseInt(c.QueryParam("page"), 10, 64)
+	if err != nil {
+		page = 1
+	}
+
+	messages, err := models.PaginateEmailMessages(
+		c.Request().Context(),
+		a.db.Conn(),
+		filter,
+		page,
+		adminEmailMessagesPageSize,
+	)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to list email messages",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	return render(c, views.AdminEmailMessageIndex(messages, filter))
+}
+
+// Show displays a message with its delivery status and the bodies as
+// they were sent.
+func (a AdminEmailMessages) Show(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return render(c, views.BadRequest())
+	}
+
+	message, err := models.FindEmailMessage(c.Request().Context(), a.db.Conn(), id)
+	if err != nil {
+		return render(c, views.NotFound())
+	}
+
+	return render(c, views.AdminEmailMessageShow(message))
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/router/cookies"
+	"mbvlabs/services"
+	"mbvlabs/views"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+const adminEmailSuppressionsPageSize = 50
+
+type AdminEmailSuppressions struct {
+	db  storage.Pool
+	cfg config.Config
+}
+
+func NewAdminEmailSuppressions(db storage.Pool, cfg config.Config) AdminEmailSuppressions {
+	return AdminEmailSuppressions{db, cfg}
+}
+
+// Index lists suppressed addresses, searchable by address.
+func (a AdminEmailSuppressions) Index(c echo.Context) error {
+	search := strings.TrimSpace(c.QueryParam("search"))
+
+	page, err := strconv.ParseInt(c.QueryParam("page"), 10, 64)
+	if err != nil {
+		page = 1
+	}
+
+	suppressions, err := models.PaginateEmailSuppressions(
+		c.Request().Context(),
+		a.db.Conn(),
+		search,
+		page,
+		adminEmailSuppressionsPageSize,
+	)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to list email suppressions",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	return render(c, views.AdminEmailSuppressionIndex(suppressions, search))
+}
+
+// Destroy lifts a suppression so the address gets email again. One that is
+// already gone is treated as lifted.
+func (a AdminEmailSuppressions) Destroy(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return render(c, views.BadRequest())
+	}
+
+	actor, err := models.FindUser(c.Request().Context(), a.db.Conn(), cookies.GetApp(c).UserID)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to find acting admin",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	_, err = services.LiftEmailSuppression(c.Request().Context(), a.db, actor, id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to lift email suppression",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).RemoveElementByID("email-suppression-" + id.String())
+}
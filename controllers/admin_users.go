@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/queue"
+	"mbvlabs/router/cookies"
+	"mbvlabs/router/routes"
+	"mbvlabs/services"
+	"mbvlabs/views"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+const adminUsersPageSize = 25
+
+type AdminUsers struct {
+	db         storage.Pool
+	insertOnly queue.InsertOnly
+	cfg        config.Config
+}
+
+func NewAdminUsers(db storage.Pool, insertOnly queue.InsertOnly, cfg config.Config) AdminUsers {
+	return AdminUsers{db, insertOnly, cfg}
+}
+
+func (a AdminUsers) Index(c echo.Context) error {
+	actor, err := a.actor(c)
+	if err != nil {
+		return render(c, views.InternalError())
+	}
+
+	page, err := strconv.ParseInt(c.QueryParam("page"), 10, 64)
+	if err != nil {
+		page = 1
+	}
+
+	search := c.QueryParam("q")
+
+	users, err := models.PaginateUsers(
+		c.Request().Context(),
+		a.db.Conn(),
+		search,
+		page,
+		adminUsersPageSize,
+	)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to list users",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	return render(c, views.AdminUserIndex(users, search, actor.IsAdmin))
+}
+
+func (a AdminUsers) Show(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return render(c, views.BadRequest())
+	}
+
+	actor, err := a.actor(c)
+	if err != nil {
+		return render(c, views.InternalError())
+	}
+
+	user, err := models.FindUser(c.Request().Context(), a.db.Conn(), id)
+	if err != nil {
+		return render(c, views.NotFound())
+	}
+
+	roles, err := models.FindRolesByUserID(c.Request().Context(), a.db.Conn(), id)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to list user roles",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	return render(c, views.AdminUserShow(user, roles, actor.IsAdmin))
+}
+
+func (a AdminUsers) VerifyEmail(c echo.Context) error {
+	return a.act(c, func(actor models.User, id uuid.UUID) (models.User, error) {
+		return services.AdminVerifyUserEmail(c.Request().Context(), a.db, actor, id)
+	})
+}
+
+func (a AdminUsers) ToggleAdmin(c echo.Context) error {
+	return a.act(c, func(actor models.User, id uuid.UUID) (models.User, error) {
+		return services.AdminToggleUserAdmin(c.Request().Context(), a.db, actor, id)
+	})
+}
+
+func (a AdminUsers) ForcePasswordReset(c echo.Context) error {
+	return a.act(c, func(actor models.User, id uuid.UUID) (models.User, error) {
+		return services.AdminForcePasswordReset(
+			c.Request().Context(),
+			a.db,
+			a.insertOnly,
+			a.cfg.Auth.Pepper,
+			actor,
+			id,
+		)
+	})
+}
+
+func (a AdminUsers) ResendVerification(c echo.Context) error {
+	return a.act(c, func(actor models.User, id uuid.UUID) (models.User, error) {
+		return services.AdminResendVerification(
+			c.Request().Context(),
+			a.db,
+			a.insertOnly,
+			a.cfg.Auth.Pepper,
+			actor,
+			id,
+		)
+	})
+}
+
+func (a AdminUsers) Destroy(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return render(c, views.BadRequest())
+	}
+
+	actor, err := a.actor(c)
+	if err != nil {
+		return render(c, views.InternalError())
+	}
+
+	sse := datastar.NewSSE(c.Response(), c.Request())
+
+	if err := services.AdminDestroyUser(c.Request().Context(), a.db, actor, id); err != nil {
+		return sse.MarshalAndPatchSignals(map[string]any{
+			"adminUserError": adminUserErrorMessage(c, err),
+		})
+	}
+
+	if flashErr := cookies.AddFlash(c, cookies.FlashSuccess, "User deleted."); flashErr != nil {
+		return render(c, views.InternalError())
+	}
+
+	return sse.Redirect(routes.AdminUserIndex.URL())
+}
+
+// act runs an admin action against the user in the path and patches their
+// row with the result, or shows why it was refused.
+func (a AdminUsers) act(
+	c echo.Context,
+	action func(actor models.User, id uuid.UUID) (models.User, error),
+) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return render(c, views.BadRequest())
+	}
+
+	actor, err := a.actor(c)
+	if err != nil {
+		return render(c, views.InternalError())
+	}
+
+	sse := datastar.NewSSE(c.Response(), c.Request())
+
+	user, err := action(actor, id)
+	if err != nil {
+		return sse.MarshalAndPatchSignals(map[string]any{
+			"adminUserError": adminUserErrorMessage(c, err),
+		})
+	}
+
+	if err := sse.PatchElementTempl(views.AdminUserRow(user, actor.IsAdmin)); err != nil {
+		return err
+	}
+
+	return sse.MarshalAndPatchSignals(map[string]any{
+		"adminUserError": "",
+	})
+}
+
+func (a AdminUsers) actor(c echo.Context) (models.User, error) {
+	actor, err := models.FindUser(c.Request().Context(), a.db.Conn(), cookies.GetApp(c).UserID)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to find acting admin",
+			"error",
+			err,
+		)
+	}
+
+	return actor, err
+}
+
+func adminUserErrorMessage(c echo.Context, err error) string {
+	switch {
+	case errors.Is(err, services.ErrAdminRequired):
+		return "Only admins can do that."
+	case errors.Is(err, services.ErrCannotManageSelf):
+		return "You cannot do that to your own account."
+	case errors.Is(err, services.ErrEmailAlreadyVerified):
+		return "That email address is already verified."
+	case errors.Is(err, services.ErrUserNotFound):
+		return "That user no longer exists."
+	case errors.Is(err, services.ErrLastOrganizationOwner):
+		return "That user is the only owner of an organization. Make someone else an owner first."
+	}
+
+	slog.ErrorContext(
+		c.Request().Context(),
+		"admin user action failed",
+		"error",
+		err,
+	)
+
+	return "Something went wrong. Please try again."
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"mbvlabs/internal/storage"
+	"mbvlabs/router/middleware"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
@@ -18,3 +19,17 @@ func NewAPI(db storage.Pool) API {
 func (a API) Health(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, "app is healthy and running")
 }
+
+// Me describes the user and token the request was authenticated with.
+func (a API) Me(ctx echo.Context) error {
+	principal, ok := middleware.GetAPIPrincipal(ctx)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]any{
+		"id":     principal.User.ID,
+		"email":  principal.User.Email,
+		"scopes": principal.Token.Scopes,
+	})
+}
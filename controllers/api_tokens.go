@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/router/cookies"
+	"mbvlabs/services"
+	"mbvlabs/views"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+type APITokens struct {
+	db  storage.Pool
+	cfg config.Config
+}
+
+func NewAPITokens(db storage.Pool, cfg config.Config) APITokens {
+	return APITokens{db, cfg}
+}
+
+func (a APITokens) Index(c echo.Context) error {
+	tokens, err := models.FindAPITokensByUserID(
+		c.Request().Context(),
+		a.db.Conn(),
+		cookies.GetApp(c).UserID,
+	)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to list api tokens",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	return render(c, views.APITokenIndex(tokens))
+}
+
+// Create issues a token and patches its secret into the page. The secret is
+// never rendered again, so this response is the user's only chance to copy it.
+func (a APITokens) Create(c echo.Context) error {
+	var payload struct {
+		APITokenName      string `json:"apiTokenName"`
+		APITokenRead      bool   `json:"apiTokenRead"`
+		APITokenWrite     bool   `json:"apiTokenWrite"`
+		APITokenExpiresIn string `json:"apiTokenExpiresIn"`
+	}
+
+	if err := c.Bind(&payload); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"could not parse api token payload",
+			"error",
+			err,
+		)
+		return render(c, views.BadRequest())
+	}
+
+	var scopes []string
+	if payload.APITokenRead {
+		scopes = append(scopes, models.APIScopeRead)
+	}
+	if payload.APITokenWrite {
+		scopes = append(scopes, models.APIScopeWrite)
+	}
+
+	var expiresIn time.Duration
+	if payload.APITokenExpiresIn != "" {
+		days, err := strconv.Atoi(payload.APITokenExpiresIn)
+		if err != nil || days <= 0 {
+			return render(c, views.BadRequest())
+		}
+		expiresIn = time.Duration(days) * 24 * time.Hour
+	}
+
+	sse := datastar.NewSSE(c.Response(), c.Request())
+
+	token, secret, err := services.CreateAPIToken(
+		c.Request().Context(),
+		a.db,
+		a.cfg.Auth.Pepper,
+		services.CreateAPITokenData{
+			UserID:    cookies.GetApp(c).UserID,
+			Name:      payload.APITokenName,
+			Scopes:    scopes,
+			ExpiresIn: expiresIn,
+		},
+	)
+	if err != nil {
+		if errors.Is(err, models.ErrDomainValidation) {
+			return sse.MarshalAndPatchSignals(map[string]any{
+				"apiTokenError": "Give the token a name and at least one scope.",
+			})
+		}
+
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to create api token",
+			"error",
+			err,
+		)
+		return sse.MarshalAndPatchSignals(map[string]any{
+			"apiTokenError": "Failed to create token",
+		})
+	}
+
+	if err := sse.PatchElementTempl(views.APITokenCreated(token, secret)); err != nil {
+		return err
+	}
+
+	if err := sse.PatchElementTempl(
+		views.APITokenListItem(token),
+		datastar.WithSelectorID("api-token-list"),
+		datastar.WithModePrepend(),
+	); err != nil {
+		return err
+	}
+
+	return sse.MarshalAndPatchSignals(map[string]any{
+		"apiTokenName":  "",
+		"apiTokenError": "",
+	})
+}
+
+func (a APITokens) Destroy(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return render(c, views.BadRequest())
+	}
+
+	if err := services.RevokeAPIToken(
+		c.Request().Context(),
+		a.db,
+		cookies.GetApp(c).UserID,
+		id,
+	); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to revoke api token",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).RemoveElementByID("api-token-" + id.String())
+}
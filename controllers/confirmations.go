@@ -5,6 +5,7 @@ import (
 
 	"mbvlabs/config"
 	"mbvlabs/internal/storage"
+	"mbvlabs/queue"
 	"mbvlabs/router/cookies"
 	"mbvlabs/router/routes"
 	"mbvlabs/services"
@@ -15,21 +16,27 @@ import (
 )
 
 type Confirmations struct {
-	db  storage.Pool
-	cfg config.Config
+	db         storage.Pool
+	insertOnly queue.InsertOnly
+	cfg        config.Config
 }
 
-func NewConfirmations(db storage.Pool, cfg config.Config) Confirmations {
-	return Confirmations{db, cfg}
+func NewConfirmations(
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	cfg config.Config,
+) Confirmations {
+	return Confirmations{db, insertOnly, cfg}
 }
 
 func (r Confirmations) New(c echo.Context) error {
-	return render(c, views.ConfirmationForm())
+	return render(c, views.ConfirmationForm(cookies.GetPendingConfirmation(c)))
 }
 
 func (r Confirmations) Create(c echo.Context) error {
 	var payload struct {
-		Code string `json:"code"`
+		Email string `json:"email"`
+		Code  string `json:"code"`
 	}
 
 	if err := c.Bind(&payload); err != nil {
@@ -42,12 +49,38 @@ func (r Confirmations) Create(c echo.Context) error {
 		return render(c, views.BadRequest())
 	}
 
+	target := services.ThrottleTarget{
+		Action: services.ThrottleEmailConfirmation,
+		IP:     c.RealIP(),
+		Email:  payload.Email,
+	}
+
+	attempt, err := services.ReserveThrottleAttempt(c.Request().Context(), r.db, target)
+	if err != nil {
+		errorMsg, ok := throttledMessage(c, err)
+		if !ok {
+			slog.ErrorContext(
+				c.Request().Context(),
+				"failed to check verification throttle",
+				"error",
+				err,
+			)
+			errorMsg = "Failed to verify email"
+		}
+
+		if flashErr := cookies.AddFlash(c, cookies.FlashError, errorMsg); flashErr != nil {
+			return render(c, views.InternalError())
+		}
+		return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.ConfirmationNew.URL())
+	}
+
 	if err := services.VerifyEmail(
 		c.Request().Context(),
 		r.db,
 		r.cfg.Auth.Pepper,
 		services.VerifyEmailData{
-			Code: payload.Code,
+			Email: payload.Email,
+			Code:  payload.Code,
 		},
 	); err != nil {
 		slog.ErrorContext(
@@ -57,12 +90,43 @@ func (r Confirmations) Create(c echo.Context) error {
 			err,
 		)
 
+		if err == services.ErrInvalidVerificationCode ||
+			err == services.ErrExpiredVerificationCode ||
+			err == services.ErrTooManyVerificationAttempts {
+			if throttleErr := services.RecordThrottleFailure(
+				c.Request().Context(),
+				r.db,
+				r.insertOnly,
+				attempt,
+			); throttleErr != nil {
+				slog.ErrorContext(
+					c.Request().Context(),
+					"failed to record verification failure",
+					"error",
+					throttleErr,
+				)
+			}
+		} else if throttleErr := services.RefundThrottleAttempt(
+			c.Request().Context(),
+			r.db,
+			attempt,
+		); throttleErr != nil {
+			slog.ErrorContext(
+				c.Request().Context(),
+				"failed to refund verification attempt",
+				"error",
+				throttleErr,
+			)
+		}
+
 		var errorMsg string
 		switch err {
 		case services.ErrInvalidVerificationCode:
 			errorMsg = "Invalid verification code"
 		case services.ErrExpiredVerificationCode:
 			errorMsg = "Verification code has expired"
+		case services.ErrTooManyVerificationAttempts:
+			errorMsg = "Too many incorrect codes. Please request a new one."
 		default:
 			errorMsg = "Failed to verify email"
 		}
@@ -73,9 +137,116 @@ func (r Confirmations) Create(c echo.Context) error {
 		return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.ConfirmationNew.URL())
 	}
 
+	if err := services.ResetThrottle(c.Request().Context(), r.db, attempt); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to reset verification throttle",
+			"error",
+			err,
+		)
+	}
+
+	if err := cookies.ClearPendingConfirmation(c); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to clear pending confirmation",
+			"error",
+			err,
+		)
+	}
+
 	if flashErr := cookies.AddFlash(c, cookies.FlashSuccess, "Email verified successfully!"); flashErr != nil {
 		return render(c, views.InternalError())
 	}
 
 	return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.HomePage.URL())
 }
+
+func (r Confirmations) Resend(c echo.Context) error {
+	var payload struct {
+		Email string `json:"email"`
+	}
+
+	if err := c.Bind(&payload); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"could not parse resend verification payload",
+			"error",
+			err,
+		)
+		return render(c, views.BadRequest())
+	}
+
+	target := services.ThrottleTarget{
+		Action: services.ThrottleConfirmationResend,
+		IP:     c.RealIP(),
+		Email:  payload.Email,
+	}
+
+	attempt, err := services.ReserveThrottleAttempt(c.Request().Context(), r.db, target)
+	if err != nil {
+		errorMsg, ok := throttledMessage(c, err)
+		if !ok {
+			slog.ErrorContext(
+				c.Request().Context(),
+				"failed to check resend verification throttle",
+				"error",
+				err,
+			)
+			errorMsg = "Failed to send a new verification code"
+		}
+
+		if flashErr := cookies.AddFlash(c, cookies.FlashError, errorMsg); flashErr != nil {
+			return render(c, views.InternalError())
+		}
+		return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.ConfirmationNew.URL())
+	}
+
+	if err := services.RecordThrottleFailure(
+		c.Request().Context(),
+		r.db,
+		r.insertOnly,
+		attempt,
+	); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to record resend verification request",
+			"error",
+			err,
+		)
+	}
+
+	if err := services.ResendVerificationCode(
+		c.Request().Context(),
+		r.db,
+		r.insertOnly,
+		r.cfg.Auth.Pepper,
+		services.ResendVerificationCodeData{
+			Email: payload.Email,
+		},
+	); err != nil {
+		// The reply is the same either way, so nobody learns from it
+		// whether the address has a pending sign-up.
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to resend verification code",
+			"error",
+			err,
+		)
+	}
+
+	if err := cookies.SetPendingConfirmation(c, payload.Email); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to remember pending confirmation",
+			"error",
+			err,
+		)
+	}
+
+	if flashErr := cookies.AddFlash(c, cookies.FlashSuccess, "If that address is waiting for verification, a new code is on its way."); flashErr != nil {
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.ConfirmationNew.URL())
+}
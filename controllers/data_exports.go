@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/queue"
+	"mbvlabs/router/cookies"
+	"mbvlabs/router/routes"
+	"mbvlabs/services"
+	"mbvlabs/views"
+
+	"github.com/labstack/echo/v4"
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+type DataExports struct {
+	db         storage.Pool
+	insertOnly queue.InsertOnly
+	cfg        config.Config
+}
+
+func NewDataExports(
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	cfg config.Config,
+) DataExports {
+	return DataExports{db, insertOnly, cfg}
+}
+
+func (d DataExports) Create(c echo.Context) error {
+	if err := services.RequestDataExport(
+		c.Request().Context(),
+		d.db,
+		d.insertOnly,
+		cookies.GetApp(c).UserID,
+	); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to request data export",
+			"error",
+			err,
+		)
+
+		if flashErr := cookies.AddFlash(c, cookies.FlashError, "Failed to start your data export"); flashErr != nil {
+			return render(c, views.InternalError())
+		}
+
+		return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.AccountShow.URL())
+	}
+
+	if flashErr := cookies.AddFlash(c, cookies.FlashSuccess, "We are preparing your data. You will get an email with a download link shortly."); flashErr != nil {
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.AccountShow.URL())
+}
+
+func (d DataExports) Show(c echo.Context) error {
+	c.Response().Header().Set("Referrer-Policy", "strict-origin")
+
+	export, err := services.DownloadDataExport(
+		c.Request().Context(),
+		d.db,
+		d.cfg.Auth.Pepper,
+		cookies.GetApp(c).UserID,
+		c.Param("token"),
+	)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidDataExport) {
+			if flashErr := cookies.AddFlash(c, cookies.FlashError, "That download link is invalid or has expired"); flashErr != nil {
+				return render(c, views.InternalError())
+			}
+
+			return c.Redirect(http.StatusSeeOther, routes.AccountShow.URL())
+		}
+
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to download data export",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	c.Response().Header().Set(
+		echo.HeaderContentDisposition,
+		fmt.Sprintf(`attachment; filename="data-export-%s.zip"`, export.CreatedAt.Format("2006-01-02")),
+	)
+
+	return c.Stream(http.StatusOK, "application/zip", bytes.NewReader(export.Archive))
+}
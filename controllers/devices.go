@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/router/cookies"
+	"mbvlabs/router/routes"
+	"mbvlabs/services"
+	"mbvlabs/views"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+type Devices struct {
+	db  storage.Pool
+	cfg config.Config
+}
+
+func NewDevices(db storage.Pool, cfg config.Config) Devices {
+	return Devices{db, cfg}
+}
+
+func (d Devices) Index(c echo.Context) error {
+	app := cookies.GetApp(c)
+
+	devices, err := services.ListDevices(c.Request().Context(), d.db, app.UserID)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to list devices",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	return render(c, views.DeviceIndex(devices, app.SessionID))
+}
+
+// Destroy signs the user out on one device. Signing out the device in use
+// is the same as signing out, so the browser is sent to sign in.
+func (d Devices) Destroy(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return render(c, views.BadRequest())
+	}
+
+	app := cookies.GetApp(c)
+
+	if err := services.RevokeDevice(c.Request().Context(), d.db, app.UserID, id); err != nil {
+		if errors.Is(err, services.ErrDeviceNotFound) {
+			return render(c, views.NotFound())
+		}
+
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to revoke device",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	sse := datastar.NewSSE(c.Response(), c.Request())
+
+	if id == app.SessionID {
+		if err := cookies.DestroyAppSession(c); err != nil {
+			return render(c, views.InternalError())
+		}
+
+		if flashErr := cookies.AddFlash(c, cookies.FlashSuccess, "Successfully logged out!"); flashErr != nil {
+			return render(c, views.InternalError())
+		}
+
+		return sse.Redirect(routes.SessionNew.URL())
+	}
+
+	return sse.RemoveElementByID("device-" + id.String())
+}
+
+// DestroyOthers signs the user out on every device but the one in use.
+func (d Devices) DestroyOthers(c echo.Context) error {
+	app := cookies.GetApp(c)
+
+	if err := services.RevokeOtherDevices(c.Request().Context(), d.db, app.UserID, app.SessionID); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to revoke other devices",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	devices, err := services.ListDevices(c.Request().Context(), d.db, app.UserID)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to list devices",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).PatchElementTempl(views.DeviceList(devices, app.SessionID))
+}
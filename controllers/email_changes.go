@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/queue"
+	"mbvlabs/router/cookies"
+	"mbvlabs/router/routes"
+	"mbvlabs/services"
+	"mbvlabs/views"
+
+	"github.com/labstack/echo/v4"
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+type EmailChanges struct {
+	db         storage.Pool
+	insertOnly queue.InsertOnly
+	cfg        config.Config
+}
+
+func NewEmailChanges(
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	cfg config.Config,
+) EmailChanges {
+	return EmailChanges{db, insertOnly, cfg}
+}
+
+func (e EmailChanges) Create(c echo.Context) error {
+	var payload struct {
+		NewEmail string `json:"newEmail"`
+	}
+
+	if err := c.Bind(&payload); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"could not parse email change payload",
+			"error",
+			err,
+		)
+		return render(c, views.BadRequest())
+	}
+
+	if err := services.RequestEmailChange(
+		c.Request().Context(),
+		e.db,
+		e.insertOnly,
+		e.cfg.Auth.Pepper,
+		services.RequestEmailChangeData{
+			UserID:   cookies.GetApp(c).UserID,
+			NewEmail: payload.NewEmail,
+		},
+	); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to request email change",
+			"error",
+			err,
+		)
+
+		var errorMsg string
+		switch {
+		case errors.Is(err, services.ErrInvalidEmail):
+			errorMsg = "Please enter a valid email address"
+		case errors.Is(err, services.ErrEmailUnchanged):
+			errorMsg = "That is already your email address"
+		default:
+			errorMsg = "Failed to change email address"
+		}
+
+		if flashErr := cookies.AddFlash(c, cookies.FlashError, errorMsg); flashErr != nil {
+			return render(c, views.InternalError())
+		}
+
+		return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.AccountShow.URL())
+	}
+
+	if flashErr := cookies.AddFlash(c, cookies.FlashSuccess, "Check your new inbox for a link to confirm the change."); flashErr != nil {
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.AccountShow.URL())
+}
+
+// ShowConfirm asks for a click before the token is spent, so link scanners
+// in mail clients cannot confirm the change on the user's behalf.
+func (e EmailChanges) ShowConfirm(c echo.Context) error {
+	c.Response().Header().Set("Referrer-Policy", "strict-origin")
+
+	return render(c, views.EmailChangeConfirmForm(c.Param("token")))
+}
+
+func (e EmailChanges) Confirm(c echo.Context) error {
+	if _, err := services.ConfirmEmailChange(
+		c.Request().Context(),
+		e.db,
+		e.cfg.Auth.Pepper,
+		c.Param("token"),
+	); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to confirm email change",
+			"error",
+			err,
+		)
+
+		var errorMsg string
+		switch {
+		case errors.Is(err, services.ErrInvalidEmailChange):
+			errorMsg = "That confirmation link is invalid or has expired"
+		case errors.Is(err, models.ErrEmailTaken):
+			errorMsg = "That email address is already in use"
+		default:
+			errorMsg = "Failed to change email address"
+		}
+
+		if flashErr := cookies.AddFlash(c, cookies.FlashError, errorMsg); flashErr != nil {
+			return render(c, views.InternalError())
+		}
+
+		return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.AccountShow.URL())
+	}
+
+	if flashErr := cookies.AddFlash(c, cookies.FlashSuccess, "Your email address has been changed."); flashErr != nil {
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.AccountShow.URL())
+}
+
+func (e EmailChanges) ShowRevert(c echo.Context) error {
+	c.Response().Header().Set("Referrer-Policy", "strict-origin")
+
+	return render(c, views.EmailChangeRevertForm(c.Param("token")))
+}
+
+// Revert cancels or undoes a change from the link sent to the old address.
+// All sessions are revoked, so the browser is sent to sign in again.
+func (e EmailChanges) Revert(c echo.Context) error {
+	if _, err := services.RevertEmailChange(
+		c.Request().Context(),
+		e.db,
+		e.insertOnly,
+		e.cfg.Auth.Pepper,
+		c.Param("token"),
+	); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to revert email change",
+			"error",
+			err,
+		)
+
+		errorMsg := "Failed to restore your email address"
+		if errors.Is(err, services.ErrInvalidEmailChange) {
+			errorMsg = "That link is invalid or has expired"
+		}
+
+		if flashErr := cookies.AddFlash(c, cookies.FlashError, errorMsg); flashErr != nil {
+			return render(c, views.InternalError())
+		}
+
+		return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.SessionNew.URL())
+	}
+
+	if err := cookies.DestroyAppSession(c); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to clear session cookie",
+			"error",
+			err,
+		)
+	}
+
+	if flashErr := cookies.AddFlash(c, cookies.FlashSuccess, "Your email address is unchanged and every device has been signed out. Check your inbox for a link to set a new password."); flashErr != nil {
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.SessionNew.URL())
+}
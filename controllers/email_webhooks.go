@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	mailclients "mbvlabs/clients/email"
+	"mbvlabs/config"
+	"mbvlabs/email"
+	"mbvlabs/internal/storage"
+	"mbvlabs/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxWebhookBodySize bounds what a webhook reads; SendGrid batches events
+// but keeps each request well under this.
+const maxWebhookBodySize = 5 << 20
+
+// EmailWebhooks receives bounce and spam complaint reports from the
+// delivery providers. A webhook whose secret is not configured does not
+// exist. Failing to record the events answers with an error, so the
+// provider sends them again later.
+type EmailWebhooks struct {
+	db  storage.Pool
+	cfg config.Config
+}
+
+func NewEmailWebhooks(db storage.Pool, cfg config.Config) EmailWebhooks {
+	return EmailWebhooks{db, cfg}
+}
+
+func (e EmailWebhooks) Postmark(c echo.Context) error {
+	username := e.cfg.Email.PostmarkWebhookUsername
+	password := e.cfg.Email.PostmarkWebhookPassword
+	if username == "" || password == "" {
+		return echo.ErrNotFound
+	}
+
+	if err := mailclients.VerifyPostmarkWebhook(c.Request(), username, password); err != nil {
+		return echo.ErrUnauthorized
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxWebhookBodySize))
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	events, err := mailclients.ParsePostmarkWebhook(body)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	return e.record(c, events)
+}
+
+func (e EmailWebhooks) SendGrid(c echo.Context) error {
+	publicKey := e.cfg.Email.SendGridWebhookPublicKey
+	if publicKey == "" {
+		return echo.ErrNotFound
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxWebhookBodySize))
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := mailclients.VerifySendGridWebhook(
+		publicKey,
+		c.Request().Header.Get(mailclients.SendGridSignatureHeader),
+		c.Request().Header.Get(mailclients.SendGridTimestampHeader),
+		body,
+		time.Now(),
+	); err != nil {
+		if errors.Is(err, mailclients.ErrWebhookUnauthorized) {
+			return echo.ErrUnauthorized
+		}
+
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to verify sendgrid webhook",
+			"error",
+			err,
+		)
+		return echo.ErrInternalServerError
+	}
+
+	events, err := mailclients.ParseSendGridWebhook(body)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	return e.record(c, events)
+}
+
+func (e EmailWebhooks) record(c echo.Context, events []email.Event) error {
+	if err := services.RecordEmailEvents(c.Request().Context(), e.db, events); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to record email events",
+			"error",
+			err,
+		)
+		return echo.ErrInternalServerError
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
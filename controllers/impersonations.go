@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/router/cookies"
+	"mbvlabs/router/routes"
+	"mbvlabs/services"
+	"mbvlabs/views"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+type Impersonations struct {
+	db  storage.Pool
+	cfg config.Config
+}
+
+func NewImpersonations(db storage.Pool, cfg config.Config) Impersonations {
+	return Impersonations{db, cfg}
+}
+
+func (i Impersonations) Create(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return render(c, views.BadRequest())
+	}
+
+	app := cookies.GetApp(c)
+
+	actor, err := models.FindUser(c.Request().Context(), i.db.Conn(), app.UserID)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to find acting admin",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	sse := datastar.NewSSE(c.Response(), c.Request())
+
+	session, err := services.StartImpersonation(c.Request().Context(), i.db, actor, id)
+	if err != nil {
+		errorMsg := adminUserErrorMessage(c, err)
+		if errors.Is(err, services.ErrCannotImpersonate) {
+			errorMsg = "Admins and staff cannot be impersonated."
+		}
+
+		return sse.MarshalAndPatchSignals(map[string]any{
+			"adminUserError": errorMsg,
+		})
+	}
+
+	if err := cookies.StartImpersonation(c, session, cookies.Impersonator{
+		UserID:    app.UserID,
+		SessionID: app.SessionID,
+	}); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to start impersonation",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	return sse.Redirect(routes.HomePage.URL())
+}
+
+func (i Impersonations) Destroy(c echo.Context) error {
+	app := cookies.GetApp(c)
+	if !app.IsImpersonating() {
+		return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.HomePage.URL())
+	}
+
+	if err := stopImpersonating(c, i.db); err != nil {
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.AdminUserShow.URL(app.UserID))
+}
+
+// stopImpersonating ends the current impersonation and puts the admin back
+// in their own session.
+func stopImpersonating(c echo.Context, db storage.Pool) error {
+	app := cookies.GetApp(c)
+
+	if err := services.StopImpersonation(c.Request().Context(), db, services.StopImpersonationData{
+		ImpersonatorID: app.ImpersonatorID,
+		SubjectID:      app.UserID,
+		SessionID:      app.SessionID,
+		Reason:         "stopped",
+	}); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to stop impersonation",
+			"error",
+			err,
+		)
+		return err
+	}
+
+	if err := cookies.StopImpersonation(c); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to restore admin session",
+			"error",
+			err,
+		)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/queue"
+	"mbvlabs/router/cookies"
+	"mbvlabs/router/routes"
+	"mbvlabs/services"
+	"mbvlabs/views"
+
+	"github.com/labstack/echo/v4"
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+type MagicLinks struct {
+	db         storage.Pool
+	insertOnly queue.InsertOnly
+	cfg        config.Config
+}
+
+func NewMagicLinks(
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	cfg config.Config,
+) MagicLinks {
+	return MagicLinks{db, insertOnly, cfg}
+}
+
+func (m MagicLinks) New(c echo.Context) error {
+	return render(c, views.MagicLinkRequestForm())
+}
+
+func (m MagicLinks) Create(c echo.Context) error {
+	var payload struct {
+		Email string `json:"email"`
+	}
+
+	if err := c.Bind(&payload); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"could not parse sign-in link request payload",
+			"error",
+			err,
+		)
+
+		return render(c, views.BadRequest())
+	}
+
+	target := services.ThrottleTarget{
+		Action: services.ThrottleMagicLink,
+		IP:     c.RealIP(),
+		Email:  payload.Email,
+	}
+
+	attempt, err := services.ReserveThrottleAttempt(c.Request().Context(), m.db, target)
+	if err != nil {
+		errorMsg, ok := throttledMessage(c, err)
+		if !ok {
+			slog.ErrorContext(
+				c.Request().Context(),
+				"failed to check sign-in link throttle",
+				"error",
+				err,
+			)
+			errorMsg = "Failed to send sign-in link"
+		}
+
+		if flashErr := cookies.AddFlash(c, cookies.FlashError, errorMsg); flashErr != nil {
+			return render(c, views.InternalError())
+		}
+
+		return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.MagicLinkNew.URL())
+	}
+
+	// Every request counts, since each one sends an email.
+	if err := services.RecordThrottleFailure(
+		c.Request().Context(),
+		m.db,
+		m.insertOnly,
+		attempt,
+	); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to record sign-in link request",
+			"error",
+			err,
+		)
+	}
+
+	if err := services.RequestMagicLink(
+		c.Request().Context(),
+		m.db,
+		m.insertOnly,
+		m.cfg.Auth.Pepper,
+		services.RequestMagicLinkData{
+			Email: payload.Email,
+		},
+	); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to request sign-in link",
+			"error",
+			err,
+		)
+		if flashErr := cookies.AddFlash(c, cookies.FlashError, "Failed to send sign-in link"); flashErr != nil {
+			return render(c, views.InternalError())
+		}
+
+		return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.MagicLinkNew.URL())
+	}
+
+	if flashErr := cookies.AddFlash(c, cookies.FlashSuccess, "If an account exists with that email, you will receive a sign-in link shortly."); flashErr != nil {
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.SessionNew.URL())
+}
+
+// Show asks the user to confirm before the token is spent, so link scanners
+// in mail clients cannot use up the link by fetching it.
+func (m MagicLinks) Show(c echo.Context) error {
+	c.Response().Header().Set("Referrer-Policy", "strict-origin")
+
+	token := c.Param("token")
+	if token == "" {
+		return c.Redirect(http.StatusSeeOther, routes.MagicLinkNew.URL())
+	}
+
+	return render(c, views.MagicLinkConfirmForm(token))
+}
+
+func (m MagicLinks) Consume(c echo.Context) error {
+	user, err := services.ConsumeMagicLink(
+		c.Request().Context(),
+		m.db,
+		m.cfg.Auth.Pepper,
+		c.Param("token"),
+	)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to consume sign-in link",
+			"error",
+			err,
+		)
+
+		errorMsg := "Failed to sign in"
+		if err == services.ErrInvalidMagicLink {
+			errorMsg = "That sign-in link is invalid or has expired"
+		}
+
+		if flashErr := cookies.AddFlash(c, cookies.FlashError, errorMsg); flashErr != nil {
+			return render(c, views.InternalError())
+		}
+
+		return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.MagicLinkNew.URL())
+	}
+
+	return completeSignIn(c, m.db, m.cfg, user, false)
+}
@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"mbvlabs/clients/oidc"
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/queue"
+	"mbvlabs/router/cookies"
+	"mbvlabs/router/routes"
+	"mbvlabs/services"
+	"mbvlabs/views"
+
+	"github.com/labstack/echo/v4"
+)
+
+type OIDCSessions struct {
+	db         storage.Pool
+	insertOnly queue.InsertOnly
+	cfg        config.Config
+	providers  oidcclient.Providers
+}
+
+func NewOIDCSessions(
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	cfg config.Config,
+	providers oidcclient.Providers,
+) OIDCSessions {
+	return OIDCSessions{db, insertOnly, cfg, providers}
+}
+
+// New sends the browser to the provider. State, nonce and the PKCE verifier
+// are kept in the session cookie until the provider redirects back.
+func (o OIDCSessions) New(c echo.Context) error {
+	provider, err := o.providers.Get(c.Param("slug"))
+	if err != nil {
+		return render(c, views.NotFound())
+	}
+
+	authURL, authRequest, err := provider.AuthCodeURL(c.Request().Context())
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to start provider login",
+			"error",
+			err,
+		)
+
+		return o.fail(c, "Could not reach "+provider.DisplayName()+". Please try again later.")
+	}
+
+	encoded, err := json.Marshal(authRequest)
+	if err != nil {
+		return render(c, views.InternalError())
+	}
+
+	if err := cookies.SetOIDCRequest(c, encoded); err != nil {
+		return render(c, views.InternalError())
+	}
+
+	return c.Redirect(http.StatusSeeOther, authURL)
+}
+
+func (o OIDCSessions) Callback(c echo.Context) error {
+	provider, err := o.providers.Get(c.Param("slug"))
+	if err != nil {
+		return render(c, views.NotFound())
+	}
+
+	encoded, ok := cookies.PopOIDCRequest(c)
+	if !ok {
+		return o.fail(c, "Your sign in attempt expired. Please try again.")
+	}
+
+	if providerErr := c.QueryParam("error"); providerErr != "" {
+		slog.InfoContext(
+			c.Request().Context(),
+			"provider login was not completed",
+			"provider",
+			provider.Name(),
+			"error",
+			providerErr,
+		)
+
+		return o.fail(c, "Sign in with "+provider.DisplayName()+" was cancelled.")
+	}
+
+	var authRequest oidcclient.AuthRequest
+	if err := json.Unmarshal(encoded, &authRequest); err != nil {
+		return o.fail(c, "Your sign in attempt expired. Please try again.")
+	}
+
+	claims, err := provider.Exchange(
+		c.Request().Context(),
+		authRequest,
+		c.QueryParam("state"),
+		c.QueryParam("code"),
+	)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to verify provider login",
+			"error",
+			err,
+		)
+
+		return o.fail(c, "Sign in with "+provider.DisplayName()+" could not be verified.")
+	}
+
+	user, err := services.SignInWithIdentity(
+		c.Request().Context(),
+		o.db,
+		o.insertOnly,
+		o.cfg.Auth.Pepper,
+		services.IdentitySignInData{
+			Provider:      provider.Name(),
+			Subject:       claims.Subject,
+			Email:         claims.Email,
+			EmailVerified: claims.EmailVerified,
+		},
+	)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to sign in with identity",
+			"error",
+			err,
+		)
+
+		var errorMsg string
+		switch {
+		case errors.Is(err, services.ErrEmailNotVerified):
+			if flashErr := cookies.AddFlash(c, cookies.FlashInfo, "Please check your inbox and verify your email to finish signing up."); flashErr != nil {
+				return render(c, views.InternalError())
+			}
+
+			return c.Redirect(http.StatusSeeOther, routes.ConfirmationNew.URL())
+		case errors.Is(err, services.ErrIdentityEmailUnverified):
+			errorMsg = "An account with this email already exists. Verify your email with " + provider.DisplayName() + " or log in with your password."
+		case errors.Is(err, services.ErrIdentityEmailMissing):
+			errorMsg = provider.DisplayName() + " did not share an email address with us."
+		default:
+			errorMsg = "Failed to log in"
+		}
+
+		return o.fail(c, errorMsg)
+	}
+
+	next, err := signIn(c, o.db, o.cfg, user, false)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to sign in",
+			"error",
+			err,
+		)
+
+		return render(c, views.InternalError())
+	}
+
+	return c.Redirect(http.StatusSeeOther, next)
+}
+
+func (o OIDCSessions) fail(c echo.Context, message string) error {
+	if flashErr := cookies.AddFlash(c, cookies.FlashError, message); flashErr != nil {
+		return render(c, views.InternalError())
+	}
+
+	return c.Redirect(http.StatusSeeOther, routes.SessionNew.URL())
+}
@@ -0,0 +1,307 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/queue"
+	"mbvlabs/router/cookies"
+	"mbvlabs/router/routes"
+	"mbvlabs/services"
+	"mbvlabs/views"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+type OrganizationInvitations struct {
+	db         storage.Pool
+	insertOnly queue.InsertOnly
+	cfg        config.Config
+}
+
+func NewOrganizationInvitations(
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	cfg config.Config,
+) OrganizationInvitations {
+	return OrganizationInvitations{db, insertOnly, cfg}
+}
+
+// Create emails an invitation to join the current organization.
+func (o OrganizationInvitations) Create(c echo.Context) error {
+	var payload struct {
+		InvitationEmail string `json:"invitationEmail"`
+		InvitationRole  string `json:"invitationRole"`
+	}
+
+	if err := c.Bind(&payload); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"could not parse organization invitation payload",
+			"error",
+			err,
+		)
+		return render(c, views.BadRequest())
+	}
+
+	app := cookies.GetApp(c)
+	sse := datastar.NewSSE(c.Response(), c.Request())
+
+	if err := services.InviteToOrganization(
+		c.Request().Context(),
+		o.db,
+		o.insertOnly,
+		o.cfg.Auth.Pepper,
+		services.InviteToOrganizationData{
+			OrganizationID: app.Organization.OrganizationID,
+			InviterID:      app.UserID,
+			Email:          payload.InvitationEmail,
+			Role:           models.OrganizationRole(payload.InvitationRole),
+		},
+	); err != nil {
+		var errorMsg string
+		switch {
+		case errors.Is(err, models.ErrDomainValidation):
+			errorMsg = "Enter a valid email address and pick a role"
+		case errors.Is(err, services.ErrAlreadyOrganizationMember):
+			errorMsg = "That person is already a member"
+		case errors.Is(err, services.ErrOrganizationForbidden):
+			errorMsg = "You are not allowed to invite people to this organization"
+		default:
+			slog.ErrorContext(
+				c.Request().Context(),
+				"failed to invite to organization",
+				"error",
+				err,
+			)
+			errorMsg = "Failed to send the invitation"
+		}
+
+		return sse.MarshalAndPatchSignals(map[string]any{
+			"invitationError": errorMsg,
+		})
+	}
+
+	invitations, err := models.FindPendingOrganizationInvitations(
+		c.Request().Context(),
+		o.db.Conn(),
+		app.Organization.OrganizationID,
+	)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to list organization invitations",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	if err := sse.PatchElementTempl(views.OrganizationInvitationList(invitations)); err != nil {
+		return err
+	}
+
+	return sse.MarshalAndPatchSignals(map[string]any{
+		"invitationEmail": "",
+		"invitationError": "",
+	})
+}
+
+func (o OrganizationInvitations) Destroy(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return render(c, views.BadRequest())
+	}
+
+	app := cookies.GetApp(c)
+	sse := datastar.NewSSE(c.Response(), c.Request())
+
+	if err := services.RevokeOrganizationInvitation(
+		c.Request().Context(),
+		o.db,
+		app.Organization.OrganizationID,
+		app.UserID,
+		id,
+	); err != nil {
+		if handled, flashErr := flashOrganizationError(c, err); handled {
+			if flashErr != nil {
+				return render(c, views.InternalError())
+			}
+
+			return sse.Redirect(routes.OrganizationShow.URL())
+		}
+
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to revoke organization invitation",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	return sse.RemoveElementByID("organization-invitation-" + id.String())
+}
+
+// Show is the page the invitation email links to. It works signed in or
+// out, as the invited person may not have an account yet.
+func (o OrganizationInvitations) Show(c echo.Context) error {
+	c.Response().Header().Set("Referrer-Policy", "strict-origin")
+
+	token := c.Param("token")
+
+	details, err := services.FindOrganizationInvitation(
+		c.Request().Context(),
+		o.db,
+		o.cfg.Auth.Pepper,
+		token,
+	)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidOrganizationInvitation) {
+			if flashErr := cookies.AddFlash(c, cookies.FlashError, "That invitation is invalid or has expired"); flashErr != nil {
+				return render(c, views.InternalError())
+			}
+
+			return c.Redirect(http.StatusSeeOther, routes.HomePage.URL())
+		}
+
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to find organization invitation",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	return render(c, views.InvitationShow(
+		details.OrganizationName,
+		details.Invitation,
+		details.AccountExists,
+		token,
+	))
+}
+
+// Accept joins the organization. Signed in users join as themselves;
+// visitors create an account for the invited address first and are signed
+// in to it.
+func (o OrganizationInvitations) Accept(c echo.Context) error {
+	token := c.Param("token")
+	app := cookies.GetApp(c)
+	sse := datastar.NewSSE(c.Response(), c.Request())
+
+	if app.IsAuthenticated {
+		membership, err := services.AcceptOrganizationInvitation(
+			c.Request().Context(),
+			o.db,
+			o.cfg.Auth.Pepper,
+			token,
+			app.UserID,
+		)
+		if err != nil {
+			return o.acceptFailed(c, sse, token, err)
+		}
+
+		return o.joined(c, sse, membership)
+	}
+
+	var payload struct {
+		Password        string `json:"password"`
+		ConfirmPassword string `json:"confirmPassword"`
+	}
+
+	if err := c.Bind(&payload); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"could not parse invitation sign up payload",
+			"error",
+			err,
+		)
+		return render(c, views.BadRequest())
+	}
+
+	user, membership, err := services.AcceptOrganizationInvitationWithSignUp(
+		c.Request().Context(),
+		o.db,
+		o.insertOnly,
+		o.cfg.Auth.Pepper,
+		token,
+		services.AcceptOrganizationInvitationWithSignUpData{
+			Password:        payload.Password,
+			ConfirmPassword: payload.ConfirmPassword,
+		},
+	)
+	if err != nil {
+		return o.acceptFailed(c, sse, token, err)
+	}
+
+	if err := startAppSession(c, o.db, o.cfg, user, false); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to start session after accepting invitation",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	return o.joined(c, sse, membership)
+}
+
+func (o OrganizationInvitations) joined(
+	c echo.Context,
+	sse *datastar.ServerSentEventGenerator,
+	membership models.OrganizationMembership,
+) error {
+	if err := cookies.SetCurrentOrganization(c, membership.OrganizationID); err != nil {
+		return render(c, views.InternalError())
+	}
+
+	if flashErr := cookies.AddFlash(c, cookies.FlashSuccess, "Welcome to "+membership.OrganizationName); flashErr != nil {
+		return render(c, views.InternalError())
+	}
+
+	return sse.Redirect(routes.OrganizationShow.URL())
+}
+
+func (o OrganizationInvitations) acceptFailed(
+	c echo.Context,
+	sse *datastar.ServerSentEventGenerator,
+	token string,
+	err error,
+) error {
+	errorMsg := passwordErrorMessage(err)
+	redirectURL := routes.InvitationShow.URL(token)
+
+	switch {
+	case errorMsg != "":
+	case errors.Is(err, services.ErrInvalidOrganizationInvitation):
+		errorMsg = "That invitation is invalid or has expired"
+		redirectURL = routes.HomePage.URL()
+	case errors.Is(err, services.ErrInvitationEmailMismatch):
+		errorMsg = "This invitation was sent to another email address"
+	case errors.Is(err, services.ErrInvitationAccountExists):
+		errorMsg = "You already have an account. Sign in, then open the invitation again."
+		redirectURL = routes.SessionNew.URL()
+	default:
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to accept organization invitation",
+			"error",
+			err,
+		)
+		errorMsg = "Failed to accept the invitation"
+	}
+
+	if flashErr := cookies.AddFlash(c, cookies.FlashError, errorMsg); flashErr != nil {
+		return render(c, views.InternalError())
+	}
+
+	return sse.Redirect(redirectURL)
+}
@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/router/cookies"
+	"mbvlabs/router/routes"
+	"mbvlabs/services"
+	"mbvlabs/views"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+type OrganizationMembers struct {
+	db  storage.Pool
+	cfg config.Config
+}
+
+func NewOrganizationMembers(db storage.Pool, cfg config.Config) OrganizationMembers {
+	return OrganizationMembers{db, cfg}
+}
+
+// Update changes the role of a member of the current organization. The new
+// role comes in the role query parameter.
+func (o OrganizationMembers) Update(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return render(c, views.BadRequest())
+	}
+
+	role := models.OrganizationRole(c.QueryParam("role"))
+	if !role.Valid() {
+		return render(c, views.BadRequest())
+	}
+
+	app := cookies.GetApp(c)
+	sse := datastar.NewSSE(c.Response(), c.Request())
+
+	if err := services.ChangeOrganizationMemberRole(
+		c.Request().Context(),
+		o.db,
+		app.Organization.OrganizationID,
+		app.UserID,
+		id,
+		role,
+	); err != nil {
+		if handled, flashErr := flashOrganizationError(c, err); handled {
+			if flashErr != nil {
+				return render(c, views.InternalError())
+			}
+
+			return sse.Redirect(routes.OrganizationShow.URL())
+		}
+
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to change organization member role",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	if id == app.UserID {
+		// The user's own role changed, which changes what the page offers.
+		return sse.Redirect(routes.OrganizationShow.URL())
+	}
+
+	members, err := models.FindOrganizationMembers(c.Request().Context(), o.db.Conn(), app.Organization.OrganizationID)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to list organization members",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	return sse.PatchElementTempl(views.OrganizationMemberList(app.Organization, members))
+}
+
+// Destroy removes a member from the current organization. Removing
+// oneself is leaving the organization.
+func (o OrganizationMembers) Destroy(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return render(c, views.BadRequest())
+	}
+
+	app := cookies.GetApp(c)
+	sse := datastar.NewSSE(c.Response(), c.Request())
+
+	if err := services.RemoveOrganizationMember(
+		c.Request().Context(),
+		o.db,
+		app.Organization.OrganizationID,
+		app.UserID,
+		id,
+	); err != nil {
+		if handled, flashErr := flashOrganizationError(c, err); handled {
+			if flashErr != nil {
+				return render(c, views.InternalError())
+			}
+
+			return sse.Redirect(routes.OrganizationShow.URL())
+		}
+
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to remove organization member",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	if id == app.UserID {
+		if flashErr := cookies.AddFlash(c, cookies.FlashSuccess, "You left "+app.Organization.OrganizationName); flashErr != nil {
+			return render(c, views.InternalError())
+		}
+
+		return sse.Redirect(routes.HomePage.URL())
+	}
+
+	return sse.RemoveElementByID("organization-member-" + id.String())
+}
+
+// flashOrganizationError turns the errors a member of an organization can
+// run into into a flash message. It reports whether err was one of them.
+func flashOrganizationError(c echo.Context, err error) (bool, error) {
+	var msg string
+	switch {
+	case errors.Is(err, services.ErrLastOrganizationOwner):
+		msg = "The organization needs at least one owner. Make someone else an owner first."
+	case errors.Is(err, services.ErrOrganizationForbidden):
+		msg = "You are not allowed to do that in this organization"
+	case errors.Is(err, services.ErrOrganizationMemberNotFound),
+		errors.Is(err, services.ErrOrganizationNotFound):
+		msg = "That member is no longer part of the organization"
+	case errors.Is(err, services.ErrOrganizationInvitationNotFound):
+		msg = "That invitation has already been used or revoked"
+	default:
+		return false, nil
+	}
+
+	return true, cookies.AddFlash(c, cookies.FlashError, msg)
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/router/cookies"
+	"mbvlabs/router/routes"
+	"mbvlabs/services"
+	"mbvlabs/views"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+type Organizations struct {
+	db  storage.Pool
+	cfg config.Config
+}
+
+func NewOrganizations(db storage.Pool, cfg config.Config) Organizations {
+	return Organizations{db, cfg}
+}
+
+func (o Organizations) New(c echo.Context) error {
+	return render(c, views.OrganizationNew())
+}
+
+// Create makes a new organization and switches to it.
+func (o Organizations) Create(c echo.Context) error {
+	var payload struct {
+		OrganizationName string `json:"organizationName"`
+	}
+
+	if err := c.Bind(&payload); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"could not parse organization payload",
+			"error",
+			err,
+		)
+		return render(c, views.BadRequest())
+	}
+
+	sse := datastar.NewSSE(c.Response(), c.Request())
+
+	organization, err := services.CreateOrganization(
+		c.Request().Context(),
+		o.db,
+		cookies.GetApp(c).UserID,
+		payload.OrganizationName,
+	)
+	if err != nil {
+		errorMsg := "Failed to create organization"
+		if errors.Is(err, models.ErrDomainValidation) {
+			errorMsg = "Give the organization a name of at most 100 characters"
+		} else {
+			slog.ErrorContext(
+				c.Request().Context(),
+				"failed to create organization",
+				"error",
+				err,
+			)
+		}
+
+		if flashErr := cookies.AddFlash(c, cookies.FlashError, errorMsg); flashErr != nil {
+			return render(c, views.InternalError())
+		}
+
+		return sse.Redirect(routes.OrganizationNew.URL())
+	}
+
+	if err := cookies.SetCurrentOrganization(c, organization.ID); err != nil {
+		return render(c, views.InternalError())
+	}
+
+	return sse.Redirect(routes.OrganizationShow.URL())
+}
+
+// Show is the members page of the organization the user is working in.
+func (o Organizations) Show(c echo.Context) error {
+	app := cookies.GetApp(c)
+
+	members, err := models.FindOrganizationMembers(
+		c.Request().Context(),
+		o.db.Conn(),
+		app.Organization.OrganizationID,
+	)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to list organization members",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	var invitations []models.OrganizationInvitation
+	if app.Organization.Role.CanManageMembers() {
+		invitations, err = models.FindPendingOrganizationInvitations(
+			c.Request().Context(),
+			o.db.Conn(),
+			app.Organization.OrganizationID,
+		)
+		if err != nil {
+			slog.ErrorContext(
+				c.Request().Context(),
+				"failed to list organization invitations",
+				"error",
+				err,
+			)
+			return render(c, views.InternalError())
+		}
+	}
+
+	return render(c, views.OrganizationShow(app.Organization, members, invitations))
+}
+
+// Switch changes the organization the user works in. Only organizations
+// the user belongs to can be picked.
+func (o Organizations) Switch(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return render(c, views.BadRequest())
+	}
+
+	isMember := slices.ContainsFunc(cookies.GetApp(c).Organizations, func(membership models.OrganizationMembership) bool {
+		return membership.OrganizationID == id
+	})
+	if !isMember {
+		return render(c, views.NotFound())
+	}
+
+	if err := cookies.SetCurrentOrganization(c, id); err != nil {
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.OrganizationShow.URL())
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/router/cookies"
+	"mbvlabs/router/routes"
+	"mbvlabs/services"
+	"mbvlabs/views"
+
+	"github.com/labstack/echo/v4"
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+// PasskeySessions signs users in with a passkey. A passkey that passed user
+// verification is already two factors, so no TOTP challenge follows.
+type PasskeySessions struct {
+	db  storage.Pool
+	cfg config.Config
+}
+
+func NewPasskeySessions(db storage.Pool, cfg config.Config) PasskeySessions {
+	return PasskeySessions{db, cfg}
+}
+
+func (p PasskeySessions) Options(c echo.Context) error {
+	ceremony, err := services.BeginPasskeySignIn()
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to begin passkey sign in",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	if err := cookies.SetPasskeyCeremony(c, ceremony.Session); err != nil {
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).ExecuteScript(
+		fmt.Sprintf("passkeys.signIn(%s, %q)", ceremony.Options, "passkey-sign-in"),
+	)
+}
+
+func (p PasskeySessions) Create(c echo.Context) error {
+	var payload struct {
+		PasskeyResponse json.RawMessage `json:"passkeyResponse"`
+		RememberMe      bool            `json:"rememberMe"`
+	}
+
+	if err := c.Bind(&payload); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"could not parse passkey sign in payload",
+			"error",
+			err,
+		)
+		return render(c, views.BadRequest())
+	}
+
+	session, ok := cookies.PopPasskeyCeremony(c)
+	if !ok {
+		return datastar.NewSSE(c.Response(), c.Request()).MarshalAndPatchSignals(map[string]any{
+			"passkeyError": "Your sign in attempt expired. Please try again.",
+		})
+	}
+
+	user, err := services.FinishPasskeySignIn(
+		c.Request().Context(),
+		p.db,
+		services.FinishPasskeySignInData{
+			Session:  session,
+			Response: payload.PasskeyResponse,
+		},
+	)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to sign in with passkey",
+			"error",
+			err,
+		)
+
+		var errorMsg string
+		switch {
+		case errors.Is(err, services.ErrInvalidPasskey),
+			errors.Is(err, services.ErrInvalidPasskeyCeremony):
+			errorMsg = "Your passkey could not be verified"
+		case errors.Is(err, services.ErrEmailNotVerified):
+			errorMsg = "Please verify your email before logging in"
+		default:
+			errorMsg = "Failed to log in"
+		}
+
+		return datastar.NewSSE(c.Response(), c.Request()).MarshalAndPatchSignals(map[string]any{
+			"passkeyError": errorMsg,
+		})
+	}
+
+	if err := startAppSession(c, p.db, p.cfg, user, payload.RememberMe); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to create session",
+			"error",
+			err,
+		)
+
+		return render(c, views.InternalError())
+	}
+
+	if flashErr := cookies.AddFlash(c, cookies.FlashSuccess, "Successfully logged in!"); flashErr != nil {
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.HomePage.URL())
+}
@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/router/cookies"
+	"mbvlabs/router/routes"
+	"mbvlabs/services"
+	"mbvlabs/views"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+type Passkeys struct {
+	db  storage.Pool
+	cfg config.Config
+}
+
+func NewPasskeys(db storage.Pool, cfg config.Config) Passkeys {
+	return Passkeys{db, cfg}
+}
+
+func (p Passkeys) Index(c echo.Context) error {
+	passkeys, err := models.FindWebAuthnCredentialsByUserID(
+		c.Request().Context(),
+		p.db.Conn(),
+		cookies.GetApp(c).UserID,
+	)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to list passkeys",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	return render(c, views.PasskeyIndex(passkeys))
+}
+
+// Options starts the registration ceremony and hands the generated options to
+// the browser, which answers by posting to Create.
+func (p Passkeys) Options(c echo.Context) error {
+	user, err := models.FindUser(c.Request().Context(), p.db.Conn(), cookies.GetApp(c).UserID)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to find user",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	ceremony, err := services.BeginPasskeyRegistration(c.Request().Context(), p.db, user)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to begin passkey registration",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	if err := cookies.SetPasskeyCeremony(c, ceremony.Session); err != nil {
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).ExecuteScript(
+		fmt.Sprintf("passkeys.register(%s, %q)", ceremony.Options, "passkey-registration"),
+	)
+}
+
+func (p Passkeys) Create(c echo.Context) error {
+	var payload struct {
+		PasskeyName     string          `json:"passkeyName"`
+		PasskeyResponse json.RawMessage `json:"passkeyResponse"`
+	}
+
+	if err := c.Bind(&payload); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"could not parse passkey registration payload",
+			"error",
+			err,
+		)
+		return render(c, views.BadRequest())
+	}
+
+	session, ok := cookies.PopPasskeyCeremony(c)
+	if !ok {
+		return datastar.NewSSE(c.Response(), c.Request()).MarshalAndPatchSignals(map[string]any{
+			"passkeyError": "Your passkey request expired. Please try again.",
+		})
+	}
+
+	user, err := models.FindUser(c.Request().Context(), p.db.Conn(), cookies.GetApp(c).UserID)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to find user",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	if _, err := services.FinishPasskeyRegistration(
+		c.Request().Context(),
+		p.db,
+		user,
+		services.FinishPasskeyRegistrationData{
+			Name:     payload.PasskeyName,
+			Session:  session,
+			Response: payload.PasskeyResponse,
+		},
+	); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to register passkey",
+			"error",
+			err,
+		)
+
+		errorMsg := "Failed to add passkey"
+		if errors.Is(err, services.ErrInvalidPasskey) ||
+			errors.Is(err, services.ErrInvalidPasskeyCeremony) {
+			errorMsg = "Your passkey could not be verified. Please try again."
+		}
+
+		return datastar.NewSSE(c.Response(), c.Request()).MarshalAndPatchSignals(map[string]any{
+			"passkeyError": errorMsg,
+		})
+	}
+
+	if flashErr := cookies.AddFlash(c, cookies.FlashSuccess, "Passkey added."); flashErr != nil {
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.PasskeyIndex.URL())
+}
+
+func (p Passkeys) Destroy(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return render(c, views.BadRequest())
+	}
+
+	if err := services.RemovePasskey(
+		c.Request().Context(),
+		p.db,
+		cookies.GetApp(c).UserID,
+		id,
+	); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to remove passkey",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	if flashErr := cookies.AddFlash(c, cookies.FlashSuccess, "Passkey removed."); flashErr != nil {
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.PasskeyIndex.URL())
+}
@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/queue"
+	"mbvlabs/router/cookies"
+	"mbvlabs/router/routes"
+	"mbvlabs/services"
+	"mbvlabs/views"
+
+	"github.com/labstack/echo/v4"
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+type Passwords struct {
+	db         storage.Pool
+	insertOnly queue.InsertOnly
+	cfg        config.Config
+}
+
+func NewPasswords(
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	cfg config.Config,
+) Passwords {
+	return Passwords{db, insertOnly, cfg}
+}
+
+// Strength answers the live feedback requests sent while a new password is
+// typed. The email signal is only set on the sign up form; signed in users
+// are checked against their own address.
+func (p Passwords) Strength(c echo.Context) error {
+	var payload struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	if err := c.Bind(&payload); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"could not parse password strength payload",
+			"error",
+			err,
+		)
+		return render(c, views.BadRequest())
+	}
+
+	target := services.ThrottleTarget{
+		Action: services.ThrottlePasswordStrength,
+		IP:     c.RealIP(),
+	}
+
+	attempt, err := services.ReserveThrottleAttempt(c.Request().Context(), p.db, target)
+	if err != nil {
+		errorMsg, ok := throttledMessage(c, err)
+		if !ok {
+			slog.ErrorContext(
+				c.Request().Context(),
+				"failed to check password strength throttle",
+				"error",
+				err,
+			)
+			return render(c, views.InternalError())
+		}
+
+		return datastar.NewSSE(c.Response(), c.Request()).MarshalAndPatchSignals(map[string]any{
+			"passwordScore":      0,
+			"passwordAcceptable": false,
+			"passwordFeedback":   errorMsg,
+		})
+	}
+
+	// Every request counts, since each one runs the estimator.
+	if err := services.RecordThrottleFailure(
+		c.Request().Context(),
+		p.db,
+		p.insertOnly,
+		attempt,
+	); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to record password strength request",
+			"error",
+			err,
+		)
+	}
+
+	email := payload.Email
+	if app := cookies.GetApp(c); app.IsAuthenticated {
+		email = app.Email
+	}
+
+	assessment, err := services.AssessPassword(c.Request().Context(), payload.Password, email)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to assess password",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	feedback := assessment.Problems
+	if len(feedback) == 0 {
+		feedback = assessment.Suggestions
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).MarshalAndPatchSignals(map[string]any{
+		"passwordScore":      assessment.Score,
+		"passwordAcceptable": assessment.Acceptable(),
+		"passwordFeedback":   strings.Join(feedback, ". "),
+	})
+}
+
+func (p Passwords) Update(c echo.Context) error {
+	var payload struct {
+		CurrentPassword string `json:"currentPassword"`
+		Password        string `json:"password"`
+		ConfirmPassword string `json:"confirmPassword"`
+	}
+
+	if err := c.Bind(&payload); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"could not parse password change payload",
+			"error",
+			err,
+		)
+		return render(c, views.BadRequest())
+	}
+
+	app := cookies.GetApp(c)
+
+	if err := services.ChangePassword(
+		c.Request().Context(),
+		p.db,
+		p.cfg.Auth.Pepper,
+		services.ChangePasswordData{
+			UserID:          app.UserID,
+			SessionID:       app.SessionID,
+			CurrentPassword: payload.CurrentPassword,
+			Password:        payload.Password,
+			ConfirmPassword: payload.ConfirmPassword,
+		},
+	); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to change password",
+			"error",
+			err,
+		)
+
+		errorMsg := passwordErrorMessage(err)
+		if errorMsg == "" {
+			if errors.Is(err, services.ErrInvalidCurrentPassword) {
+				errorMsg = "Your current password is incorrect"
+			} else {
+				errorMsg = "Failed to change password"
+			}
+		}
+
+		if flashErr := cookies.AddFlash(c, cookies.FlashError, errorMsg); flashErr != nil {
+			return render(c, views.InternalError())
+		}
+
+		return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.AccountShow.URL())
+	}
+
+	if flashErr := cookies.AddFlash(c, cookies.FlashSuccess, "Your password has been changed and your other devices signed out."); flashErr != nil {
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.AccountShow.URL())
+}
+
+// passwordErrorMessage explains a password rejected by the policy, or
+// returns an empty string for any other error.
+func passwordErrorMessage(err error) string {
+	var policyErr services.PasswordPolicyError
+	switch {
+	case errors.Is(err, services.ErrPasswordMismatch):
+		return "Passwords do not match"
+	case errors.As(err, &policyErr):
+		return policyErr.Error()
+	default:
+		return ""
+	}
+}
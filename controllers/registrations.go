@@ -69,12 +69,26 @@ func (r Registrations) Create(c echo.Context) error {
 			err,
 		)
 
-		if flashErr := cookies.AddFlash(c, cookies.FlashError, "Failed to register user"); flashErr != nil {
+		errorMsg := passwordErrorMessage(err)
+		if errorMsg == "" {
+			errorMsg = "Failed to register user"
+		}
+
+		if flashErr := cookies.AddFlash(c, cookies.FlashError, errorMsg); flashErr != nil {
 			return render(c, views.InternalError())
 		}
 
 		return c.Redirect(http.StatusSeeOther, routes.RegistrationNew.URL())
 	}
 
+	if err := cookies.SetPendingConfirmation(c, payload.Email); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to remember pending confirmation",
+			"error",
+			err,
+		)
+	}
+
 	return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.ConfirmationNew.URL())
 }
@@ -49,6 +49,47 @@ func (p ResetPasswords) Create(c echo.Context) error {
 		return render(c, views.BadRequest())
 	}
 
+	target := services.ThrottleTarget{
+		Action: services.ThrottlePasswordReset,
+		IP:     c.RealIP(),
+		Email:  payload.Email,
+	}
+
+	attempt, err := services.ReserveThrottleAttempt(c.Request().Context(), p.db, target)
+	if err != nil {
+		errorMsg, ok := throttledMessage(c, err)
+		if !ok {
+			slog.ErrorContext(
+				c.Request().Context(),
+				"failed to check password reset throttle",
+				"error",
+				err,
+			)
+			errorMsg = "Failed to send password reset code"
+		}
+
+		if flashErr := cookies.AddFlash(c, cookies.FlashError, errorMsg); flashErr != nil {
+			return render(c, views.InternalError())
+		}
+
+		return c.Redirect(http.StatusSeeOther, routes.PasswordNew.URL())
+	}
+
+	// Every request counts, since each one sends an email.
+	if err := services.RecordThrottleFailure(
+		c.Request().Context(),
+		p.db,
+		p.insertOnly,
+		attempt,
+	); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to record password reset request",
+			"error",
+			err,
+		)
+	}
+
 	if err := services.RequestResetPassword(
 		c.Request().Context(),
 		p.db,
@@ -133,7 +174,10 @@ func (p ResetPasswords) Update(c echo.Context) error {
 		case services.ErrExpiredResetCode:
 			errorMsg = "Reset code has expired"
 		default:
-			errorMsg = "Failed to reset password"
+			errorMsg = passwordErrorMessage(err)
+			if errorMsg == "" {
+				errorMsg = "Failed to reset password"
+			}
 		}
 
 		if flashErr := cookies.AddFlash(c, cookies.FlashError, errorMsg); flashErr != nil {
@@ -6,6 +6,8 @@ import (
 
 	"mbvlabs/config"
 	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/queue"
 	"mbvlabs/router/cookies"
 	"mbvlabs/router/routes"
 	"mbvlabs/services"
@@ -16,22 +18,28 @@ import (
 )
 
 type Sessions struct {
-	db  storage.Pool
-	cfg config.Config
+	db         storage.Pool
+	insertOnly queue.InsertOnly
+	cfg        config.Config
 }
 
-func NewSessions(db storage.Pool, cfg config.Config) Sessions {
-	return Sessions{db, cfg}
+func NewSessions(
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	cfg config.Config,
+) Sessions {
+	return Sessions{db, insertOnly, cfg}
 }
 
 func (s Sessions) New(c echo.Context) error {
-	return render(c, views.LoginForm())
+	return render(c, views.LoginForm(s.cfg.OIDC.Providers))
 }
 
 func (s Sessions) Create(c echo.Context) error {
 	var payload struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email      string `json:"email"`
+		Password   string `json:"password"`
+		RememberMe bool   `json:"rememberMe"`
 	}
 
 	if err := c.Bind(&payload); err != nil {
@@ -44,6 +52,32 @@ func (s Sessions) Create(c echo.Context) error {
 		return render(c, views.BadRequest())
 	}
 
+	target := services.ThrottleTarget{
+		Action: services.ThrottleSignIn,
+		IP:     c.RealIP(),
+		Email:  payload.Email,
+	}
+
+	attempt, err := services.ReserveThrottleAttempt(c.Request().Context(), s.db, target)
+	if err != nil {
+		errorMsg, ok := throttledMessage(c, err)
+		if !ok {
+			slog.ErrorContext(
+				c.Request().Context(),
+				"failed to check login throttle",
+				"error",
+				err,
+			)
+			errorMsg = "Failed to log in"
+		}
+
+		if flashErr := cookies.AddFlash(c, cookies.FlashError, errorMsg); flashErr != nil {
+			return render(c, views.InternalError())
+		}
+
+		return c.Redirect(http.StatusSeeOther, routes.SessionNew.URL())
+	}
+
 	user, err := services.AuthenticateUser(
 		c.Request().Context(),
 		s.db,
@@ -61,6 +95,33 @@ func (s Sessions) Create(c echo.Context) error {
 			err,
 		)
 
+		if err == services.ErrInvalidCredentials {
+			if throttleErr := services.RecordThrottleFailure(
+				c.Request().Context(),
+				s.db,
+				s.insertOnly,
+				attempt,
+			); throttleErr != nil {
+				slog.ErrorContext(
+					c.Request().Context(),
+					"failed to record login failure",
+					"error",
+					throttleErr,
+				)
+			}
+		} else if throttleErr := services.RefundThrottleAttempt(
+			c.Request().Context(),
+			s.db,
+			attempt,
+		); throttleErr != nil {
+			slog.ErrorContext(
+				c.Request().Context(),
+				"failed to refund login attempt",
+				"error",
+				throttleErr,
+			)
+		}
+
 		var errorMsg string
 		switch err {
 		case services.ErrInvalidCredentials:
@@ -78,10 +139,32 @@ func (s Sessions) Create(c echo.Context) error {
 		return c.Redirect(http.StatusSeeOther, routes.SessionNew.URL())
 	}
 
-	if err := cookies.CreateAppSession(c, user); err != nil {
+	if err := services.ResetThrottle(c.Request().Context(), s.db, attempt); err != nil {
 		slog.ErrorContext(
 			c.Request().Context(),
-			"failed to create session",
+			"failed to reset login throttle",
+			"error",
+			err,
+		)
+	}
+
+	return completeSignIn(c, s.db, s.cfg, user, payload.RememberMe)
+}
+
+// completeSignIn finishes a datastar driven sign in once the first factor
+// has been checked.
+func completeSignIn(
+	c echo.Context,
+	db storage.Pool,
+	cfg config.Config,
+	user models.User,
+	rememberMe bool,
+) error {
+	next, err := signIn(c, db, cfg, user, rememberMe)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to sign in",
 			"error",
 			err,
 		)
@@ -89,14 +172,133 @@ func (s Sessions) Create(c echo.Context) error {
 		return render(c, views.InternalError())
 	}
 
-	if flashErr := cookies.AddFlash(c, cookies.FlashSuccess, "Successfully logged in!"); flashErr != nil {
-		return render(c, views.InternalError())
+	return datastar.NewSSE(c.Response(), c.Request()).Redirect(next)
+}
+
+// signIn decides what happens after the first factor and returns where to
+// send the browser next. Users with TOTP are sent to the challenge step,
+// everyone else gets a session straight away. rememberMe keeps the device
+// signed in after the session ends.
+func signIn(
+	c echo.Context,
+	db storage.Pool,
+	cfg config.Config,
+	user models.User,
+	rememberMe bool,
+) (string, error) {
+	twoFactorEnabled, err := services.TwoFactorEnabled(c.Request().Context(), db, user.ID)
+	if err != nil {
+		return "", err
+	}
+
+	if twoFactorEnabled {
+		challenge, err := services.StartTwoFactorChallenge(
+			c.Request().Context(),
+			db,
+			cfg.Auth.Pepper,
+			user.ID,
+		)
+		if err != nil {
+			return "", err
+		}
+
+		if err := cookies.SetTwoFactorChallenge(c, challenge, rememberMe); err != nil {
+			return "", err
+		}
+
+		return routes.TwoFactorChallengeNew.URL(), nil
+	}
+
+	if user.IsAdmin && cfg.Auth.RequireAdminTwoFactor {
+		hasPasskey, err := services.HasPasskey(c.Request().Context(), db, user.ID)
+		if err != nil {
+			return "", err
+		}
+
+		// Admins with a passkey and no TOTP would otherwise get in with a
+		// single factor.
+		if hasPasskey {
+			if err := cookies.AddFlash(c, cookies.FlashError, "Please sign in with your passkey."); err != nil {
+				return "", err
+			}
+
+			return routes.SessionNew.URL(), nil
+		}
+	}
+
+	if err := startAppSession(c, db, cfg, user, rememberMe); err != nil {
+		return "", err
 	}
 
-	return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.HomePage.URL())
+	if user.IsAdmin && cfg.Auth.RequireAdminTwoFactor {
+		if err := cookies.AddFlash(c, cookies.FlashWarning, "Admins must set up two-factor authentication or a passkey."); err != nil {
+			return "", err
+		}
+
+		return routes.TwoFactorNew.URL(), nil
+	}
+
+	if err := cookies.AddFlash(c, cookies.FlashSuccess, "Successfully logged in!"); err != nil {
+		return "", err
+	}
+
+	return routes.HomePage.URL(), nil
+}
+
+// startAppSession creates the server side session for a fully authenticated
+// user and points the session cookie at it. With rememberMe the device also
+// gets a remember token.
+func startAppSession(
+	c echo.Context,
+	db storage.Pool,
+	cfg config.Config,
+	user models.User,
+	rememberMe bool,
+) error {
+	appSession, rememberSecret, err := services.CreateSession(
+		c.Request().Context(),
+		db,
+		cfg.Auth.Pepper,
+		user.ID,
+		rememberMe,
+	)
+	if err != nil {
+		return err
+	}
+
+	if rememberSecret != "" {
+		cookies.SetRememberToken(c, rememberSecret, services.RememberMeDuration)
+	} else {
+		cookies.ClearRememberToken(c)
+	}
+
+	return cookies.CreateAppSession(c, appSession)
 }
 
 func (s Sessions) Destroy(c echo.Context) error {
+	app := cookies.GetApp(c)
+	if app.IsImpersonating() {
+		// Signing out of an impersonation ends it rather than the admin's
+		// own session.
+		if err := stopImpersonating(c, s.db); err != nil {
+			return render(c, views.InternalError())
+		}
+
+		return c.Redirect(http.StatusSeeOther, routes.AdminUserShow.URL(app.UserID))
+	}
+
+	if app.IsAuthenticated {
+		if err := services.RevokeSession(c.Request().Context(), s.db, app.UserID, app.SessionID); err != nil {
+			slog.ErrorContext(
+				c.Request().Context(),
+				"failed to revoke session",
+				"error",
+				err,
+			)
+			return render(c, views.InternalError())
+		}
+	}
+
 	if err := cookies.DestroyAppSession(c); err != nil {
 		slog.ErrorContext(
 			c.Request().Context(),
@@ -113,3 +315,36 @@ func (s Sessions) Destroy(c echo.Context) error {
 
 	return c.Redirect(http.StatusSeeOther, routes.SessionNew.URL())
 }
+
+func (s Sessions) DestroyAll(c echo.Context) error {
+	app := cookies.GetApp(c)
+	if !app.IsAuthenticated {
+		return c.Redirect(http.StatusSeeOther, routes.SessionNew.URL())
+	}
+
+	if err := services.RevokeAllSessions(c.Request().Context(), s.db, app.UserID); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to revoke all sessions",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	if err := cookies.DestroyAppSession(c); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to destroy session",
+			"error",
+			err,
+		)
+		return render(c, views.InternalError())
+	}
+
+	if flashErr := cookies.AddFlash(c, cookies.FlashSuccess, "Signed out of all devices."); flashErr != nil {
+		return render(c, views.InternalError())
+	}
+
+	return c.Redirect(http.StatusSeeOther, routes.SessionNew.URL())
+}
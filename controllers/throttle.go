@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"mbvlabs/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// throttledMessage sets Retry-After and returns the message to flash when err
+// is a throttling rejection. It reports false for any other error.
+func throttledMessage(c echo.Context, err error) (string, bool) {
+	var throttled services.ThrottledError
+	if !errors.As(err, &throttled) {
+		return "", false
+	}
+
+	c.Response().Header().Set(
+		"Retry-After",
+		strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))),
+	)
+
+	minutes := int(math.Ceil(throttled.RetryAfter.Minutes()))
+	if minutes <= 1 {
+		return "Too many attempts. Please try again in a minute.", true
+	}
+
+	return fmt.Sprintf("Too many attempts. Please try again in %d minutes.", minutes), true
+}
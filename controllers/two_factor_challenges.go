@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/queue"
+	"mbvlabs/router/cookies"
+	"mbvlabs/router/routes"
+	"mbvlabs/services"
+	"mbvlabs/views"
+
+	"github.com/labstack/echo/v4"
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+type TwoFactorChallenges struct {
+	db         storage.Pool
+	insertOnly queue.InsertOnly
+	cfg        config.Config
+}
+
+func NewTwoFactorChallenges(
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	cfg config.Config,
+) TwoFactorChallenges {
+	return TwoFactorChallenges{db, insertOnly, cfg}
+}
+
+func (t TwoFactorChallenges) New(c echo.Context) error {
+	if _, ok := cookies.GetTwoFactorChallenge(c); !ok {
+		return c.Redirect(http.StatusSeeOther, routes.SessionNew.URL())
+	}
+
+	return render(c, views.TwoFactorChallengeForm())
+}
+
+func (t TwoFactorChallenges) Create(c echo.Context) error {
+	var payload struct {
+		Code string `json:"code"`
+	}
+
+	if err := c.Bind(&payload); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"could not parse two-factor challenge payload",
+			"error",
+			err,
+		)
+		return render(c, views.BadRequest())
+	}
+
+	challenge, ok := cookies.GetTwoFactorChallenge(c)
+	if !ok {
+		return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.SessionNew.URL())
+	}
+
+	userID, err := services.TwoFactorChallengeUser(
+		c.Request().Context(),
+		t.db,
+		t.cfg.Auth.Pepper,
+		challenge,
+	)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to find two-factor challenge",
+			"error",
+			err,
+		)
+
+		return t.restartSignIn(c, "Your sign in attempt expired. Please log in again.")
+	}
+
+	target := services.ThrottleTarget{
+		Action: services.ThrottleTwoFactor,
+		IP:     c.RealIP(),
+		UserID: userID,
+	}
+
+	attempt, err := services.ReserveThrottleAttempt(c.Request().Context(), t.db, target)
+	if err != nil {
+		errorMsg, ok := throttledMessage(c, err)
+		if !ok {
+			slog.ErrorContext(
+				c.Request().Context(),
+				"failed to check two-factor throttle",
+				"error",
+				err,
+			)
+			errorMsg = "Failed to verify authentication code"
+		}
+
+		if flashErr := cookies.AddFlash(c, cookies.FlashError, errorMsg); flashErr != nil {
+			return render(c, views.InternalError())
+		}
+
+		return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.TwoFactorChallengeNew.URL())
+	}
+
+	user, err := services.CompleteTwoFactorChallenge(
+		c.Request().Context(),
+		t.db,
+		t.cfg.Auth.Pepper,
+		services.CompleteTwoFactorChallengeData{
+			Challenge: challenge,
+			Code:      payload.Code,
+		},
+	)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to complete two-factor challenge",
+			"error",
+			err,
+		)
+
+		if errors.Is(err, services.ErrInvalidTwoFactorCode) ||
+			errors.Is(err, services.ErrTooManyTwoFactorAttempts) {
+			if throttleErr := services.RecordThrottleFailure(
+				c.Request().Context(),
+				t.db,
+				t.insertOnly,
+				attempt,
+			); throttleErr != nil {
+				slog.ErrorContext(
+					c.Request().Context(),
+					"failed to record two-factor failure",
+					"error",
+					throttleErr,
+				)
+			}
+		} else if throttleErr := services.RefundThrottleAttempt(
+			c.Request().Context(),
+			t.db,
+			attempt,
+		); throttleErr != nil {
+			slog.ErrorContext(
+				c.Request().Context(),
+				"failed to refund two-factor attempt",
+				"error",
+				throttleErr,
+			)
+		}
+
+		if errors.Is(err, services.ErrTooManyTwoFactorAttempts) {
+			return t.restartSignIn(c, "Too many incorrect codes. Please log in again.")
+		}
+
+		if errors.Is(err, services.ErrInvalidTwoFactorCode) {
+			if flashErr := cookies.AddFlash(c, cookies.FlashError, "Invalid authentication code"); flashErr != nil {
+				return render(c, views.InternalError())
+			}
+
+			return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.TwoFactorChallengeNew.URL())
+		}
+
+		return t.restartSignIn(c, "Your sign in attempt expired. Please log in again.")
+	}
+
+	if err := services.ResetThrottle(c.Request().Context(), t.db, attempt); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to reset two-factor throttle",
+			"error",
+			err,
+		)
+	}
+
+	rememberMe := cookies.GetTwoFactorRememberMe(c)
+
+	if err := cookies.ClearTwoFactorChallenge(c); err != nil {
+		return render(c, views.InternalError())
+	}
+
+	if err := startAppSession(c, t.db, t.cfg, user, rememberMe); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to create session",
+			"error",
+			err,
+		)
+
+		return render(c, views.InternalError())
+	}
+
+	if flashErr := cookies.AddFlash(c, cookies.FlashSuccess, "Successfully logged in!"); flashErr != nil {
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.HomePage.URL())
+}
+
+// restartSignIn drops the pending challenge and sends the user back to the
+// password step with message.
+func (t TwoFactorChallenges) restartSignIn(c echo.Context, message string) error {
+	if err := cookies.ClearTwoFactorChallenge(c); err != nil {
+		return render(c, views.InternalError())
+	}
+
+	if flashErr := cookies.AddFlash(c, cookies.FlashError, message); flashErr != nil {
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.SessionNew.URL())
+}
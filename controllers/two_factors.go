@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/router/cookies"
+	"mbvlabs/router/routes"
+	"mbvlabs/services"
+	"mbvlabs/views"
+
+	"github.com/labstack/echo/v4"
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+type TwoFactors struct {
+	db  storage.Pool
+	cfg config.Config
+}
+
+func NewTwoFactors(db storage.Pool, cfg config.Config) TwoFactors {
+	return TwoFactors{db, cfg}
+}
+
+func (t TwoFactors) New(c echo.Context) error {
+	ctx := c.Request().Context()
+	app := cookies.GetApp(c)
+
+	enabled, err := services.TwoFactorEnabled(ctx, t.db, app.UserID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to look up two-factor status", "error", err)
+		return render(c, views.InternalError())
+	}
+
+	if enabled {
+		remaining, err := models.CountUnusedRecoveryCodes(ctx, t.db.Conn(), app.UserID)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to count recovery codes", "error", err)
+			return render(c, views.InternalError())
+		}
+
+		return render(c, views.TwoFactorSettings(remaining))
+	}
+
+	user, err := models.FindUser(ctx, t.db.Conn(), app.UserID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find user", "error", err)
+		return render(c, views.InternalError())
+	}
+
+	enrollment, err := services.BeginTOTPEnrollment(ctx, t.db, user)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to begin two-factor enrollment", "error", err)
+		return render(c, views.InternalError())
+	}
+
+	return render(c, views.TwoFactorSetupForm(enrollment.Secret, enrollment.URI, enrollment.QRCode))
+}
+
+func (t TwoFactors) Create(c echo.Context) error {
+	var payload struct {
+		Code string `json:"code"`
+	}
+
+	if err := c.Bind(&payload); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"could not parse two-factor enrollment payload",
+			"error",
+			err,
+		)
+		return render(c, views.BadRequest())
+	}
+
+	codes, err := services.ConfirmTOTPEnrollment(
+		c.Request().Context(),
+		t.db,
+		t.cfg.Auth.Pepper,
+		cookies.GetApp(c).UserID,
+		payload.Code,
+	)
+	if err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to confirm two-factor enrollment",
+			"error",
+			err,
+		)
+
+		var errorMsg string
+		switch {
+		case errors.Is(err, services.ErrInvalidTwoFactorCode):
+			errorMsg = "That code is not valid. Check your authenticator app and try again."
+		case errors.Is(err, services.ErrTwoFactorAlreadyEnabled):
+			errorMsg = "Two-factor authentication is already enabled."
+		default:
+			errorMsg = "Failed to enable two-factor authentication"
+		}
+
+		return datastar.NewSSE(c.Response(), c.Request()).PatchElementTempl(views.TwoFactorError(errorMsg))
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).PatchElementTempl(views.TwoFactorRecoveryCodes(codes))
+}
+
+func (t TwoFactors) Destroy(c echo.Context) error {
+	var payload struct {
+		Code string `json:"code"`
+	}
+
+	if err := c.Bind(&payload); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"could not parse two-factor removal payload",
+			"error",
+			err,
+		)
+		return render(c, views.BadRequest())
+	}
+
+	if err := services.DisableTwoFactor(
+		c.Request().Context(),
+		t.db,
+		t.cfg.Auth.Pepper,
+		cookies.GetApp(c).UserID,
+		payload.Code,
+	); err != nil {
+		slog.ErrorContext(
+			c.Request().Context(),
+			"failed to disable two-factor authentication",
+			"error",
+			err,
+		)
+
+		errorMsg := "Failed to disable two-factor authentication"
+		if errors.Is(err, services.ErrInvalidTwoFactorCode) {
+			errorMsg = "Invalid authentication code"
+		}
+
+		if flashErr := cookies.AddFlash(c, cookies.FlashError, errorMsg); flashErr != nil {
+			return render(c, views.InternalError())
+		}
+
+		return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.TwoFactorNew.URL())
+	}
+
+	if flashErr := cookies.AddFlash(c, cookies.FlashSuccess, "Two-factor authentication disabled."); flashErr != nil {
+		return render(c, views.InternalError())
+	}
+
+	return datastar.NewSSE(c.Response(), c.Request()).Redirect(routes.HomePage.URL())
+}
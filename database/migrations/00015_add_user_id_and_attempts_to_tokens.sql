@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE tokens
+    ADD COLUMN IF NOT EXISTS user_id uuid REFERENCES users(id) ON DELETE CASCADE,
+    ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS tokens_scope_user_id_idx ON tokens(scope, user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS tokens_scope_user_id_idx;
+
+ALTER TABLE tokens
+    DROP COLUMN IF EXISTS attempts,
+    DROP COLUMN IF EXISTS user_id;
+-- +goose StatementEnd
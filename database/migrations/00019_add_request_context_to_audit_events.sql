@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE audit_events
+    ADD COLUMN ip TEXT NOT NULL DEFAULT '',
+    ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+    ADD COLUMN trace_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS audit_events_actor_id_idx ON audit_events(actor_id);
+CREATE INDEX IF NOT EXISTS audit_events_action_idx ON audit_events(action);
+
+-- The audit trail is append-only: rows can be added but never changed or
+-- removed through the application.
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();
+DROP INDEX IF EXISTS audit_events_action_idx;
+DROP INDEX IF EXISTS audit_events_actor_id_idx;
+ALTER TABLE audit_events
+    DROP COLUMN trace_id,
+    DROP COLUMN user_agent,
+    DROP COLUMN ip;
+-- +goose StatementEnd
@@ -0,0 +1,52 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN deletion_scheduled_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS users_deletion_scheduled_at_idx
+    ON users(deletion_scheduled_at) WHERE deletion_scheduled_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS data_exports (
+    id uuid PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    archive BYTEA NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS data_exports_user_id_idx ON data_exports(user_id);
+
+-- Events stay append-only, except that the personal data of a deleted user
+-- may be blanked out. Who did what, and when, is kept.
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE'
+        AND NEW.id = OLD.id
+        AND NEW.created_at = OLD.created_at
+        AND NEW.actor_id IS NOT DISTINCT FROM OLD.actor_id
+        AND NEW.subject_id IS NOT DISTINCT FROM OLD.subject_id
+        AND NEW.action = OLD.action
+        AND NEW.trace_id = OLD.trace_id
+        AND NEW.ip = ''
+        AND NEW.user_agent = ''
+        AND NEW.details = '{}'::jsonb THEN
+        RETURN NEW;
+    END IF;
+
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TABLE IF EXISTS data_exports;
+DROP INDEX IF EXISTS users_deletion_scheduled_at_idx;
+ALTER TABLE users DROP COLUMN deletion_scheduled_at;
+-- +goose StatementEnd
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE sessions
+    ADD COLUMN ip TEXT NOT NULL DEFAULT '',
+    ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+    ADD COLUMN last_seen_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE sessions SET last_seen_at = updated_at;
+
+ALTER TABLE sessions ALTER COLUMN last_seen_at SET NOT NULL;
+
+-- A remember token keeps a device signed in after its session runs out.
+-- The validator is rotated every time it is used; previous_hash keeps the
+-- one it replaced so a replayed, stolen token can be told apart from a
+-- request that raced the rotation.
+CREATE TABLE IF NOT EXISTS remember_tokens (
+    id uuid PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    session_id uuid NOT NULL UNIQUE REFERENCES sessions(id) ON DELETE CASCADE,
+    hash TEXT NOT NULL,
+    previous_hash TEXT NOT NULL DEFAULT '',
+    rotated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS remember_tokens;
+ALTER TABLE sessions
+    DROP COLUMN last_seen_at,
+    DROP COLUMN user_agent,
+    DROP COLUMN ip;
+-- +goose StatementEnd
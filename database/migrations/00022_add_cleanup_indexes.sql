@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS tokens_expires_at_idx ON tokens(expires_at);
+
+CREATE INDEX IF NOT EXISTS users_unverified_created_at_idx
+    ON users(created_at) WHERE email_validated_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS users_unverified_created_at_idx;
+DROP INDEX IF EXISTS tokens_expires_at_idx;
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+-- job_id is the River job sending the message. It is not a foreign key:
+-- River deletes finished jobs long before the log is cleared.
+CREATE TABLE IF NOT EXISTS email_messages (
+    id uuid PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('transactional', 'marketing')),
+    template TEXT NOT NULL DEFAULT '',
+    sender TEXT NOT NULL,
+    recipients TEXT[] NOT NULL,
+    subject TEXT NOT NULL,
+    html_body TEXT NOT NULL,
+    text_body TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL CHECK (status IN ('queued', 'sent', 'bounced', 'complained', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    provider_message_id TEXT NOT NULL DEFAULT '',
+    job_id BIGINT UNIQUE,
+    sent_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS email_messages_created_at_idx ON email_messages(created_at DESC);
+CREATE INDEX IF NOT EXISTS email_messages_provider_message_id_idx
+    ON email_messages(provider_message_id) WHERE provider_message_id <> '';
+
+INSERT INTO permissions (id, created_at, name, description) VALUES
+    (gen_random_uuid(), now(), 'emails.view', 'Read sent email and its delivery status');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permissions WHERE name = 'emails.view';
+DROP TABLE IF EXISTS email_messages;
+-- +goose StatementEnd
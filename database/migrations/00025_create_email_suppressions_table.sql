@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+-- An address lands here when a provider reports a hard bounce or a spam
+-- complaint for it. Nothing is sent to it again until an admin lifts the
+-- suppression.
+CREATE TABLE IF NOT EXISTS email_suppressions (
+    id uuid PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    email TEXT NOT NULL UNIQUE,
+    reason TEXT NOT NULL CHECK (reason IN ('bounce', 'complaint')),
+    provider TEXT NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    email_message_id uuid REFERENCES email_messages(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS email_suppressions_created_at_idx ON email_suppressions(created_at DESC);
+
+INSERT INTO permissions (id, created_at, name, description) VALUES
+    (gen_random_uuid(), now(), 'emails.manage', 'Lift suppressions on bounced and complaining addresses');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'support' AND p.name = 'emails.manage';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permissions WHERE name = 'emails.manage';
+DROP TABLE IF EXISTS email_suppressions;
+-- +goose StatementEnd
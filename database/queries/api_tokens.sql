@@ -0,0 +1,23 @@
+-- name: QueryAPITokenByHash :one
+select * from api_tokens where hash=$1;
+
+-- name: QueryAPITokensByUserID :many
+select * from api_tokens where user_id=$1 order by created_at desc;
+
+-- name: InsertAPIToken :one
+insert into
+    api_tokens (id, created_at, updated_at, user_id, name, prefix, hash, scopes, expires_at)
+values
+    ($1, now(), now(), $2, $3, $4, $5, $6, $7)
+returning *;
+
+-- name: UpdateAPITokenLastUsedAt :exec
+update api_tokens
+    set last_used_at=now()
+where id = $1;
+
+-- name: DeleteAPITokenByIDAndUserID :exec
+delete from api_tokens where id=$1 and user_id=$2;
+
+-- name: DeleteAPITokensByUserID :exec
+delete from api_tokens where user_id=$1;
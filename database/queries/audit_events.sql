@@ -0,0 +1,37 @@
+-- name: InsertAuditEvent :one
+insert into
+    audit_events (id, created_at, actor_id, subject_id, action, ip, user_agent, trace_id, details)
+values
+    ($1, now(), $2, $3, $4, $5, $6, $7, $8)
+returning *;
+
+-- name: QueryAuditEvents :many
+select * from audit_events
+where (sqlc.narg('actor_id')::uuid is null or actor_id = sqlc.narg('actor_id')::uuid)
+    and (sqlc.narg('subject_id')::uuid is null or subject_id = sqlc.narg('subject_id')::uuid)
+    and (sqlc.arg('action')::text = '' or action like sqlc.arg('action')::text || '%')
+    and (sqlc.narg('since')::timestamptz is null or created_at >= sqlc.narg('since')::timestamptz)
+    and (sqlc.narg('until')::timestamptz is null or created_at < sqlc.narg('until')::timestamptz)
+order by created_at desc
+limit sqlc.arg('limit')::bigint offset sqlc.arg('offset')::bigint;
+
+-- name: CountAuditEvents :one
+select count(*) from audit_events
+where (sqlc.narg('actor_id')::uuid is null or actor_id = sqlc.narg('actor_id')::uuid)
+    and (sqlc.narg('subject_id')::uuid is null or subject_id = sqlc.narg('subject_id')::uuid)
+    and (sqlc.arg('action')::text = '' or action like sqlc.arg('action')::text || '%')
+    and (sqlc.narg('since')::timestamptz is null or created_at >= sqlc.narg('since')::timestamptz)
+    and (sqlc.narg('until')::timestamptz is null or created_at < sqlc.narg('until')::timestamptz);
+
+-- name: QueryAuditEventsByUserID :many
+select * from audit_events
+where actor_id = sqlc.arg('user_id')::uuid or subject_id = sqlc.arg('user_id')::uuid
+order by created_at;
+
+-- name: RedactAuditEventsByUser :execrows
+update audit_events
+    set ip='', user_agent='', details='{}'
+where (actor_id = sqlc.arg('user_id')::uuid
+        or subject_id = sqlc.arg('user_id')::uuid
+        or lower(details->>'email') = lower(sqlc.arg('email')::text))
+    and (ip <> '' or user_agent <> '' or details <> '{}'::jsonb);
@@ -0,0 +1,12 @@
+-- name: QueryDataExportByHash :one
+select * from data_exports where hash=$1;
+
+-- name: InsertDataExport :one
+insert into
+    data_exports (id, created_at, user_id, hash, expires_at, archive)
+values
+    ($1, now(), $2, $3, $4, $5)
+returning *;
+
+-- name: DeleteExpiredDataExports :execrows
+delete from data_exports where expires_at <= now();
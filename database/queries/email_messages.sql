@@ -0,0 +1,56 @@
+-- name: QueryEmailMessageByID :one
+select * from email_messages where id=$1;
+
+-- name: QueryEmailMessages :many
+select * from email_messages
+where (sqlc.arg('search')::text = ''
+        or subject ilike '%' || sqlc.arg('search')::text || '%'
+        or array_to_string(recipients, ' ') ilike '%' || sqlc.arg('search')::text || '%')
+    and (sqlc.arg('status')::text = '' or status = sqlc.arg('status')::text)
+order by created_at desc
+limit sqlc.arg('limit')::bigint offset sqlc.arg('offset')::bigint;
+
+-- name: CountEmailMessages :one
+select count(*) from email_messages
+where (sqlc.arg('search')::text = ''
+        or subject ilike '%' || sqlc.arg('search')::text || '%'
+        or array_to_string(recipients, ' ') ilike '%' || sqlc.arg('search')::text || '%')
+    and (sqlc.arg('status')::text = '' or status = sqlc.arg('status')::text);
+
+-- name: UpsertEmailMessageAttempt :one
+-- A job retrying a message counts another attempt on the same row. A
+-- message that failed for good goes back in the queue if its job is run
+-- again by hand.
+insert into
+    email_messages (id, created_at, updated_at, kind, template, sender, recipients, subject, html_body, text_body, status, attempts, job_id)
+values
+    ($1, now(), now(), $2, $3, $4, $5, $6, $7, $8, 'queued', 1, sqlc.narg('job_id'))
+on conflict (job_id) do update
+    set updated_at=now(),
+        attempts=email_messages.attempts + 1,
+        status=case when email_messages.status = 'failed' then 'queued' else email_messages.status end
+returning *;
+
+-- name: UpdateEmailMessageSent :execrows
+update email_messages
+    set updated_at=now(), status='sent', sent_at=now(), last_error='', provider_message_id=$2
+where id=$1;
+
+-- name: UpdateEmailMessageError :execrows
+update email_messages
+    set updated_at=now(), status=$2, last_error=$3
+where id=$1;
+
+-- name: QueryEmailMessagesByRecipient :many
+select * from email_messages
+where sqlc.arg('email')::text = any(recipients)
+order by created_at desc;
+
+-- name: DeleteEmailMessagesByRecipient :execrows
+delete from email_messages where sqlc.arg('email')::text = any(recipients);
+
+-- name: QueryEmailMessageByProviderMessageID :one
+select * from email_messages
+where provider_message_id=$1
+order by created_at desc
+limit 1;
@@ -0,0 +1,33 @@
+-- name: QueryEmailSuppressionByID :one
+select * from email_suppressions where id=$1;
+
+-- name: QuerySuppressedEmails :many
+select email from email_suppressions where email = any(sqlc.arg('emails')::text[]);
+
+-- name: QueryEmailSuppressions :many
+select * from email_suppressions
+where sqlc.arg('search')::text = '' or email ilike '%' || sqlc.arg('search')::text || '%'
+order by created_at desc
+limit sqlc.arg('limit')::bigint offset sqlc.arg('offset')::bigint;
+
+-- name: CountEmailSuppressions :one
+select count(*) from email_suppressions
+where sqlc.arg('search')::text = '' or email ilike '%' || sqlc.arg('search')::text || '%';
+
+-- name: UpsertEmailSuppression :one
+-- A later report for a suppressed address replaces the reason on record
+-- but keeps when it was first suppressed.
+insert into
+    email_suppressions (id, created_at, updated_at, email, reason, provider, details, email_message_id)
+values
+    ($1, now(), now(), $2, $3, $4, $5, sqlc.narg('email_message_id'))
+on conflict (email) do update
+    set updated_at=now(),
+        reason=excluded.reason,
+        provider=excluded.provider,
+        details=excluded.details,
+        email_message_id=excluded.email_message_id
+returning *;
+
+-- name: DeleteEmailSuppression :execrows
+delete from email_suppressions where id=$1;
@@ -0,0 +1,17 @@
+-- name: QueryIdentityByProviderAndSubject :one
+select * from identities where provider=$1 and subject=$2;
+
+-- name: QueryIdentitiesByUserID :many
+select * from identities where user_id=$1 order by created_at;
+
+-- name: InsertIdentity :one
+insert into
+    identities (id, created_at, updated_at, user_id, provider, subject, email)
+values
+    ($1, now(), now(), $2, $3, $4, $5)
+returning *;
+
+-- name: UpdateIdentityEmail :exec
+update identities
+    set updated_at=now(), email=$2
+where id = $1;
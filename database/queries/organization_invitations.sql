@@ -0,0 +1,22 @@
+-- name: QueryOrganizationInvitationByHash :one
+select * from organization_invitations where hash=$1;
+
+-- name: QueryPendingOrganizationInvitations :many
+select * from organization_invitations
+where organization_id = $1 and expires_at > now()
+order by created_at desc;
+
+-- name: InsertOrganizationInvitation :one
+insert into
+    organization_invitations (id, created_at, organization_id, invited_by_id, email, role, hash, expires_at)
+values
+    ($1, now(), $2, $3, $4, $5, $6, $7)
+returning *;
+
+-- name: DeleteOrganizationInvitation :execrows
+delete from organization_invitations
+where id = $1 and organization_id = $2;
+
+-- name: DeleteOrganizationInvitationByEmail :exec
+delete from organization_invitations
+where organization_id = $1 and email = $2;
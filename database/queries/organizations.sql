@@ -0,0 +1,67 @@
+-- name: QueryOrganizationByID :one
+select * from organizations where id=$1;
+
+-- name: InsertOrganization :one
+insert into
+    organizations (id, created_at, updated_at, name)
+values
+    ($1, now(), now(), $2)
+returning *;
+
+-- name: LockOrganization :exec
+select id from organizations where id=$1 for update;
+
+-- name: QueryOrganizationMembershipsByUserID :many
+select
+    organization_memberships.organization_id,
+    organizations.name as organization_name,
+    organization_memberships.user_id,
+    organization_memberships.role,
+    organization_memberships.created_at
+from organization_memberships
+join organizations on organizations.id = organization_memberships.organization_id
+where organization_memberships.user_id = $1
+order by organizations.name, organizations.id;
+
+-- name: QueryOrganizationMembership :one
+select
+    organization_memberships.organization_id,
+    organizations.name as organization_name,
+    organization_memberships.user_id,
+    organization_memberships.role,
+    organization_memberships.created_at
+from organization_memberships
+join organizations on organizations.id = organization_memberships.organization_id
+where organization_memberships.organization_id = $1
+    and organization_memberships.user_id = $2;
+
+-- name: QueryOrganizationMembers :many
+select
+    organization_memberships.user_id,
+    users.email,
+    organization_memberships.role,
+    organization_memberships.created_at
+from organization_memberships
+join users on users.id = organization_memberships.user_id
+where organization_memberships.organization_id = $1
+order by users.email;
+
+-- name: InsertOrganizationMembership :exec
+insert into
+    organization_memberships (organization_id, user_id, created_at, updated_at, role)
+values
+    ($1, $2, now(), now(), $3)
+on conflict (organization_id, user_id) do nothing;
+
+-- name: UpdateOrganizationMembershipRole :execrows
+update organization_memberships
+    set updated_at=now(), role=$3
+where organization_id = $1 and user_id = $2;
+
+-- name: DeleteOrganizationMembership :execrows
+delete from organization_memberships
+where organization_id = $1 and user_id = $2;
+
+-- name: CountOrganizationOwners :one
+select count(*) from organization_memberships
+where organization_id = $1 and role = 'owner';
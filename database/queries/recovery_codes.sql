@@ -0,0 +1,20 @@
+-- name: InsertRecoveryCode :one
+insert into
+    recovery_codes (id, created_at, updated_at, user_id, hash, used_at)
+values
+    ($1, now(), now(), $2, $3, null)
+returning *;
+
+-- name: QueryUnusedRecoveryCodeByUserIDAndHash :one
+select * from recovery_codes where user_id=$1 and hash=$2 and used_at is null limit 1;
+
+-- name: CountUnusedRecoveryCodesByUserID :one
+select count(*) from recovery_codes where user_id=$1 and used_at is null;
+
+-- name: MarkRecoveryCodeUsed :exec
+update recovery_codes
+    set updated_at=now(), used_at=now()
+where id = $1;
+
+-- name: DeleteRecoveryCodesByUserID :exec
+delete from recovery_codes where user_id=$1;
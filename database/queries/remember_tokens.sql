@@ -0,0 +1,19 @@
+-- name: QueryRememberTokenByID :one
+select * from remember_tokens where id=$1;
+
+-- name: InsertRememberToken :one
+insert into
+    remember_tokens (id, created_at, updated_at, session_id, hash, previous_hash, rotated_at, expires_at)
+values
+    ($1, now(), now(), $2, $3, '', now(), $4)
+returning *;
+
+-- name: RotateRememberToken :one
+update remember_tokens
+    set updated_at=now(), rotated_at=now(), previous_hash=hash, hash=sqlc.arg('new_hash')
+where id = sqlc.arg('id') and hash = sqlc.arg('current_hash')
+returning *;
+
+-- name: DeleteRememberTokensByUserID :exec
+delete from remember_tokens
+where session_id in (select id from sessions where user_id=$1);
@@ -0,0 +1,39 @@
+-- name: QueryRoles :many
+select * from roles order by name;
+
+-- name: QueryRoleByName :one
+select * from roles where name=$1;
+
+-- name: QueryRolesByUserID :many
+select roles.* from roles
+    join user_roles on user_roles.role_id = roles.id
+where user_roles.user_id = $1
+order by roles.name;
+
+-- name: QueryPermissionNames :many
+select name from permissions order by name;
+
+-- name: QueryPermissionNamesByUserID :many
+select distinct permissions.name from permissions
+    join role_permissions on role_permissions.permission_id = permissions.id
+    join user_roles on user_roles.role_id = role_permissions.role_id
+where user_roles.user_id = $1
+order by permissions.name;
+
+-- name: QueryUserHasPermission :one
+select exists (
+    select 1 from permissions
+        join role_permissions on role_permissions.permission_id = permissions.id
+        join user_roles on user_roles.role_id = role_permissions.role_id
+    where user_roles.user_id = $1 and permissions.name = $2
+);
+
+-- name: InsertUserRole :exec
+insert into
+    user_roles (user_id, role_id, created_at)
+values
+    ($1, $2, now())
+on conflict do nothing;
+
+-- name: DeleteUserRole :exec
+delete from user_roles where user_id=$1 and role_id=$2;
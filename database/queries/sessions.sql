@@ -0,0 +1,55 @@
+-- name: QuerySessionByID :one
+select * from sessions where id=$1;
+
+-- name: QuerySessionsByUserID :many
+select * from sessions where user_id=$1 order by created_at desc;
+
+-- name: QueryDevicesByUserID :many
+select * from sessions
+where user_id = $1
+    and revoked_at is null
+    and (
+        expires_at > now()
+        or exists (
+            select 1 from remember_tokens
+            where remember_tokens.session_id = sessions.id
+                and remember_tokens.expires_at > now()
+        )
+    )
+order by last_seen_at desc;
+
+-- name: InsertSession :one
+insert into
+    sessions (id, created_at, updated_at, user_id, expires_at, revoked_at, ip, user_agent, last_seen_at)
+values
+    ($1, now(), now(), $2, $3, null, $4, $5, now())
+returning *;
+
+-- name: TouchSession :exec
+update sessions
+    set last_seen_at=now(), ip=$2, user_agent=$3
+where id = $1;
+
+-- name: ExtendSession :one
+update sessions
+    set updated_at=now(), expires_at=$2
+where id = $1
+returning *;
+
+-- name: RevokeSession :exec
+update sessions
+    set updated_at=now(), revoked_at=now()
+where id = $1 and revoked_at is null;
+
+-- name: RevokeSessionsByUserID :exec
+update sessions
+    set updated_at=now(), revoked_at=now()
+where user_id = $1 and revoked_at is null;
+
+-- name: DeleteSession :exec
+delete from sessions where id=$1;
+
+-- name: RevokeOtherSessionsByUserID :exec
+update sessions
+    set updated_at=now(), revoked_at=now()
+where user_id = $1 and id <> $2 and revoked_at is null;
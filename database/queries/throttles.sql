@@ -0,0 +1,39 @@
+-- name: QueryThrottlesByKeys :many
+select * from throttles where key = any(@keys::text[]);
+
+-- name: UpsertThrottleAttempt :one
+insert into
+    throttles (key, created_at, updated_at, failures, window_started_at)
+values
+    (@key, now(), now(), 1, now())
+on conflict (key) do update
+    set updated_at=now(),
+        failures=case
+            when throttles.locked_until > now() then throttles.failures
+            when throttles.window_started_at < @window_start::timestamptz then 1
+            else throttles.failures + 1
+        end,
+        window_started_at=case
+            when throttles.locked_until > now() then throttles.window_started_at
+            when throttles.window_started_at < @window_start::timestamptz then now()
+            else throttles.window_started_at
+        end
+returning *;
+
+-- name: UpdateThrottleLockedUntil :exec
+update throttles
+    set updated_at=now(), locked_until=greatest(locked_until, @locked_until::timestamptz)
+where key = @key;
+
+-- name: RefundThrottleAttempt :exec
+update throttles
+    set updated_at=now(),
+        failures=greatest(failures - 1, 0),
+        locked_until=case
+            when failures - 1 < @lock_threshold::integer then null
+            else locked_until
+        end
+where key = @key;
+
+-- name: DeleteThrottle :exec
+delete from throttles where key=$1;
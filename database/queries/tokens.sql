@@ -6,9 +6,9 @@ select * from tokens;
 
 -- name: InsertToken :one
 insert into
-    tokens (id, created_at, updated_at, scope, expires_at, hash, meta_data)
+    tokens (id, created_at, updated_at, scope, expires_at, hash, meta_data, user_id)
 values
-    ($1, now(), now(), $2, $3, $4, $5)
+    ($1, now(), now(), $2, $3, $4, $5, $6)
 returning *;
 
 -- name: UpdateToken :one
@@ -17,7 +17,7 @@ update tokens
 where id = $1
 returning *;
 
--- name: DeleteToken :exec
+-- name: DeleteToken :execrows
 delete from tokens where id=$1;
 
 -- name: QueryPaginatedTokens :many
@@ -30,3 +30,30 @@ select count(*) from tokens;
 
 -- name: QueryTokenByScopeAndHash :one
 select * from tokens where scope=$1 and hash=$2 limit 1;
+
+-- name: QueryLatestTokenByScopeAndUserID :one
+select * from tokens
+where scope=$1 and user_id=$2
+order by created_at desc
+limit 1;
+
+-- name: IncrementTokenAttempts :one
+update tokens
+    set updated_at=now(), attempts=attempts + 1
+where id = $1
+returning attempts;
+
+-- name: DeleteTokensByScopeAndUserID :exec
+delete from tokens where scope=$1 and user_id=$2;
+
+-- name: DeleteExpiredTokens :execrows
+delete from tokens
+where id in (
+    select id from tokens
+    where expires_at <= now()
+    order by expires_at
+    limit sqlc.arg('limit')::bigint
+);
+
+-- name: QueryTokensByUserID :many
+select * from tokens where user_id=$1 order by created_at desc;
@@ -0,0 +1,30 @@
+-- name: QueryTotpCredentialByUserID :one
+select * from totp_credentials where user_id=$1;
+
+-- name: UpsertTotpCredential :one
+insert into
+    totp_credentials (id, created_at, updated_at, user_id, secret, confirmed_at, last_used_step)
+values
+    ($1, now(), now(), $2, $3, null, 0)
+on conflict (user_id) do update
+    set updated_at=now(), secret=excluded.secret, confirmed_at=null, last_used_step=0
+returning *;
+
+-- name: ConfirmTotpCredential :one
+update totp_credentials
+    set updated_at=now(), confirmed_at=now(), last_used_step=$2
+where id = $1
+returning *;
+
+-- name: UpdateTotpCredentialLastUsedStep :exec
+update totp_credentials
+    set updated_at=now(), last_used_step=$2
+where id = $1;
+
+-- name: UpdateTotpCredentialSecret :exec
+update totp_credentials
+    set updated_at=now(), secret=$2
+where id = $1;
+
+-- name: DeleteTotpCredentialByUserID :exec
+delete from totp_credentials where user_id=$1;
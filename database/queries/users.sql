@@ -25,8 +25,41 @@ delete from users where id=$1;
 
 -- name: QueryPaginatedUsers :many
 select * from users
+where sqlc.arg('search')::text = '' or email ilike '%' || sqlc.arg('search')::text || '%'
 order by created_at desc
 limit sqlc.arg('limit')::bigint offset sqlc.arg('offset')::bigint;
 
 -- name: CountUsers :one
-select count(*) from users;
+select count(*) from users
+where sqlc.arg('search')::text = '' or email ilike '%' || sqlc.arg('search')::text || '%';
+
+-- name: UpdateUserDeletionScheduledAt :one
+update users
+    set updated_at=now(), deletion_scheduled_at=$2
+where id = $1
+returning *;
+
+-- name: QueryUsersDueForDeletion :many
+select * from users
+where deletion_scheduled_at <= now()
+order by deletion_scheduled_at, id
+limit sqlc.arg('limit')::bigint offset sqlc.arg('offset')::bigint;
+
+-- name: QueryUnverifiedUsersCreatedBefore :many
+select * from users
+where users.email_validated_at is null
+    and not users.is_admin
+    and users.created_at < sqlc.arg('created_before')
+    and not exists (
+        select 1 from organization_memberships owned
+        where owned.user_id = users.id
+            and owned.role = 'owner'
+            and not exists (
+                select 1 from organization_memberships others
+                where others.organization_id = owned.organization_id
+                    and others.role = 'owner'
+                    and others.user_id <> owned.user_id
+            )
+    )
+order by users.created_at
+limit sqlc.arg('limit')::bigint;
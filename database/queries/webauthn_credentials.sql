@@ -0,0 +1,20 @@
+-- name: QueryWebauthnCredentialByCredentialID :one
+select * from webauthn_credentials where credential_id=$1;
+
+-- name: QueryWebauthnCredentialsByUserID :many
+select * from webauthn_credentials where user_id=$1 order by created_at;
+
+-- name: InsertWebauthnCredential :one
+insert into
+    webauthn_credentials (id, created_at, updated_at, user_id, credential_id, name, credential)
+values
+    ($1, now(), now(), $2, $3, $4, $5)
+returning *;
+
+-- name: UpdateWebauthnCredentialAfterLogin :exec
+update webauthn_credentials
+    set updated_at=now(), last_used_at=now(), credential=$2
+where id = $1;
+
+-- name: DeleteWebauthnCredentialByIDAndUserID :exec
+delete from webauthn_credentials where id=$1 and user_id=$2;
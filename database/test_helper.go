@@ -2,11 +2,11 @@ package database
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
-	"log"
-	"os"
-	"path/filepath"
+	"io/fs"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -18,6 +18,36 @@ import (
 	"github.com/testcontainers/testcontainers-go/wait"
 )
 
+var (
+	sharedOnce sync.Once
+	shared     *TestDB
+	sharedErr  error
+)
+
+// SharedTestDB returns a database started on first use and shared by every
+// test in the binary; call CloseSharedTestDB from TestMain. Tests are
+// skipped when no container runtime is available.
+func SharedTestDB(t *testing.T) *TestDB {
+	t.Helper()
+
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	sharedOnce.Do(func() {
+		shared, sharedErr = NewTestDB()
+	})
+	if sharedErr != nil {
+		t.Fatalf("failed to start test database: %v", sharedErr)
+	}
+
+	return shared
+}
+
+func CloseSharedTestDB() {
+	if shared != nil {
+		shared.Close()
+	}
+}
+
 type TestDB struct {
 	DB        storage.Pool
 	Container *postgres.PostgresContainer
@@ -46,17 +76,18 @@ func NewTestDB() (*TestDB, error) {
 		return nil, fmt.Errorf("failed to get connection string: %w", err)
 	}
 
-	if err := runMigrations("postgres", dsn); err != nil {
-		pgContainer.Terminate(ctx)
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
-	}
-
 	db, err := NewPostgres(ctx, dsn)
 	if err != nil {
 		pgContainer.Terminate(ctx)
 		return nil, fmt.Errorf("failed to connect to test database: %w", err)
 	}
 
+	if err := runMigrations(ctx, db); err != nil {
+		db.Conn().Close()
+		pgContainer.Terminate(ctx)
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+
 	return &TestDB{
 		DB:        db,
 		Container: pgContainer,
@@ -66,6 +97,9 @@ func NewTestDB() (*TestDB, error) {
 
 func (tdb *TestDB) Close() error {
 	ctx := context.Background()
+	if tdb.DB != nil {
+		tdb.DB.Conn().Close()
+	}
 	if tdb.Container != nil {
 		return tdb.Container.Terminate(ctx)
 	}
@@ -92,38 +126,23 @@ func (tdb *TestDB) WithTx(t *testing.T, fn func(tx pgx.Tx)) {
 	fn(tx)
 }
 
-func runMigrations(driver, dsn string) error {
-	wd, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get working directory: %w", err)
-	}
-
-	migrationsDir := filepath.Join(wd, "database", "migrations")
-
-	if _, err := os.Stat(migrationsDir); os.IsNotExist(err) {
-		cwd, _ := os.Getwd()
-		log.Printf("Warning: migrations directory not found at %s (cwd: %s)", migrationsDir, cwd)
-		return nil
-	}
-
-	db, err := sql.Open(driver, dsn)
-	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
-	}
-	defer db.Close()
-
-	files, err := filepath.Glob(filepath.Join(migrationsDir, "*.sql"))
+// runMigrations applies the Up section of every embedded migration, in
+// order.
+func runMigrations(ctx context.Context, db storage.Pool) error {
+	files, err := fs.Glob(Migrations, "migrations/*.sql")
 	if err != nil {
 		return fmt.Errorf("failed to list migrations: %w", err)
 	}
+	slices.Sort(files)
 
 	for _, file := range files {
-		content, err := os.ReadFile(file)
+		content, err := fs.ReadFile(Migrations, file)
 		if err != nil {
 			return fmt.Errorf("failed to read migration %s: %w", file, err)
 		}
 
-		if _, err := db.Exec(string(content)); err != nil {
+		up, _, _ := strings.Cut(string(content), "-- +goose Down")
+		if _, err := db.Conn().Exec(ctx, up); err != nil {
 			return fmt.Errorf("failed to execute migration %s: %w", file, err)
 		}
 	}
@@ -4,10 +4,19 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"reflect"
+	"slices"
 	"strings"
+	"unicode"
 
 	"github.com/a-h/templ"
 	"golang.org/x/net/html"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
 )
 
 type Transformer interface {
@@ -15,12 +24,37 @@ type Transformer interface {
 	ToText() (string, error)
 }
 
+// TemplateName names the email t renders after its type, so ResetPassword
+// is "reset_password", as its template file.
+func TemplateName(t Transformer) string {
+	typ := reflect.TypeOf(t)
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	var name strings.Builder
+	for i, r := range typ.Name() {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				name.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		name.WriteRune(r)
+	}
+
+	return name.String()
+}
+
 var (
 	ErrUnsubscribeURLRequired = errors.New("marketing emails require unsubscribe URL")
 	ErrMissingRecipient       = errors.New("missing recipient email address")
 	ErrMissingSender          = errors.New("missing sender email address")
 	ErrMissingSubject         = errors.New("missing email subject")
 	ErrMissingHTMLBody        = errors.New("missing email HTML body")
+	// ErrSuppressed comes wrapped in a PermanentError for a message to an
+	// address that hard bounced or complained about spam.
+	ErrSuppressed = errors.New("recipient is suppressed")
 )
 
 type ValidationError struct {
@@ -91,6 +125,8 @@ func IsRetryable(err error) bool {
 }
 
 type TransactionalData struct {
+	// Template names the email the message was rendered from, for the log.
+	Template    string
 	To          string
 	Cc          []string
 	Bcc         []string
@@ -101,9 +137,14 @@ type TransactionalData struct {
 	TextBody    string
 	Attachments []Attachment
 	Metadata    map[string]string
+	// Secrets are values in the body, such as sign-in tokens, that are
+	// sent but blanked out of the copy kept in the message log.
+	Secrets []string
 }
 
 type MarketingData struct {
+	// Template names the email the message was rendered from, for the log.
+	Template         string
 	To               []string
 	From             string
 	ReplyTo          string
@@ -153,33 +194,85 @@ type MarketingPayload struct {
 	TrackClicks      bool
 }
 
+// TransactionalSender and MarketingSender hand messages to a delivery
+// backend. They return the id the backend gave the message, if any, which
+// its delivery reports refer to.
 type TransactionalSender interface {
-	SendTransactional(ctx context.Context, payload TransactionalPayload) error
+	SendTransactional(ctx context.Context, payload TransactionalPayload) (string, error)
 }
 
 type MarketingSender interface {
-	SendMarketing(ctx context.Context, payload MarketingPayload) error
+	SendMarketing(ctx context.Context, payload MarketingPayload) (string, error)
 }
 
+// MetadataMessageID is the metadata key carrying the id of the logged
+// message, so delivery reports can be matched to it.
+const MetadataMessageID = "email_message_id"
+
+// Attempt identifies the run of the job sending a message. Retries of a job
+// update the message it logged on its first run.
+type Attempt struct {
+	JobID int64
+	// Final is set on the job's last attempt, after which a temporary
+	// failure is as good as a permanent one.
+	Final bool
+}
+
+// SendTransactional refuses with ErrSuppressed a message to any suppressed
+// address, copies included, rather than send it to part of its recipients.
 func SendTransactional(
 	ctx context.Context,
+	exec storage.Executor,
+	attempt Attempt,
 	data TransactionalData,
 	sender TransactionalSender,
 ) error {
-	if data.To == "" && len(data.Cc) == 0 && len(data.Bcc) == 0 {
-		return ValidationError{Err: ErrMissingRecipient}
+	var recipients []string
+	if data.To != "" {
+		recipients = append(recipients, data.To)
+	}
+	recipients = append(recipients, data.Cc...)
+	recipients = append(recipients, data.Bcc...)
+
+	message, err := models.StartEmailMessageAttempt(ctx, exec, models.StartEmailMessageAttemptData{
+		JobID:      attempt.JobID,
+		Kind:       models.EmailMessageKindTransactional,
+		Template:   data.Template,
+		Sender:     data.From,
+		Recipients: recipients,
+		Subject:    data.Subject,
+		HTMLBody:   redactSecrets(data.HTMLBody, data.Secrets),
+		TextBody:   redactSecrets(data.TextBody, data.Secrets),
+	})
+	if err != nil {
+		return err
 	}
 
-	if data.From == "" {
-		return ValidationError{Err: ErrMissingSender}
+	if message.IsDelivered() {
+		return nil
 	}
 
-	if data.Subject == "" {
-		return ValidationError{Err: ErrMissingSubject}
+	var validationErr error
+	switch {
+	case data.To == "" && len(data.Cc) == 0 && len(data.Bcc) == 0:
+		validationErr = ValidationError{Err: ErrMissingRecipient}
+	case data.From == "":
+		validationErr = ValidationError{Err: ErrMissingSender}
+	case data.Subject == "":
+		validationErr = ValidationError{Err: ErrMissingSubject}
+	case data.HTMLBody == "":
+		validationErr = ValidationError{Err: ErrMissingHTMLBody}
+	}
+	if validationErr != nil {
+		return recordResult(ctx, exec, attempt, message, "", validationErr)
 	}
 
-	if data.HTMLBody == "" {
-		return ValidationError{Err: ErrMissingHTMLBody}
+	suppressed, err := suppressedRecipients(ctx, exec, recipients)
+	if err != nil {
+		return err
+	}
+	if len(suppressed) > 0 {
+		return recordResult(ctx, exec, attempt, message, "", suppressedError(suppressed))
 	}
 
 	payload := TransactionalPayload{
@@ -192,23 +285,79 @@ func SendTransactional(
 		HTMLBody:    data.HTMLBody,
 		TextBody:    data.TextBody,
 		Attachments: data.Attachments,
-		Metadata:    data.Metadata,
+		Metadata:    withMessageID(data.Metadata, message),
 	}
 
-	return sender.SendTransactional(ctx, payload)
+	providerMessageID, err := sender.SendTransactional(ctx, payload)
+
+	return recordResult(ctx, exec, attempt, message, providerMessageID, err)
 }
 
-func SendMarketing(ctx context.Context, data MarketingData, sender MarketingSender) error {
+// redactSecrets replaces every secret in body, so the message log never
+// holds a working link.
+func redactSecrets(body string, secrets []string) string {
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		body = strings.ReplaceAll(body, secret, "[redacted]")
+	}
+
+	return body
+}
+
+// SendMarketing leaves suppressed addresses out and sends to the rest. Only
+// a message with no one else left to send to fails with ErrSuppressed.
+func SendMarketing(
+	ctx context.Context,
+	exec storage.Executor,
+	attempt Attempt,
+	data MarketingData,
+	sender MarketingSender,
+) error {
+	message, err := models.StartEmailMessageAttempt(ctx, exec, models.StartEmailMessageAttemptData{
+		JobID:      attempt.JobID,
+		Kind:       models.EmailMessageKindMarketing,
+		Template:   data.Template,
+		Sender:     data.From,
+		Recipients: data.To,
+		Subject:    data.Subject,
+		HTMLBody:   data.HTMLBody,
+		TextBody:   data.TextBody,
+	})
+	if err != nil {
+		return err
+	}
+
+	if message.IsDelivered() {
+		return nil
+	}
+
 	if data.UnsubscribeURL == "" {
-		return ErrUnsubscribeURLRequired
+		return recordResult(ctx, exec, attempt, message, "", ErrUnsubscribeURLRequired)
 	}
 
 	if data.HTMLBody == "" {
-		return ValidationError{Err: ErrMissingHTMLBody}
+		return recordResult(ctx, exec, attempt, message, "", ValidationError{Err: ErrMissingHTMLBody})
+	}
+
+	suppressed, err := suppressedRecipients(ctx, exec, data.To)
+	if err != nil {
+		return err
+	}
+
+	to := make([]string, 0, len(data.To))
+	for _, recipient := range data.To {
+		if !slices.Contains(suppressed, recipient) {
+			to = append(to, recipient)
+		}
+	}
+	if len(to) == 0 && len(suppressed) > 0 {
+		return recordResult(ctx, exec, attempt, message, "", suppressedError(suppressed))
 	}
 
 	payload := MarketingPayload{
-		To:               data.To,
+		To:               to,
 		From:             data.From,
 		ReplyTo:          data.ReplyTo,
 		Subject:          data.Subject,
@@ -217,12 +366,96 @@ func SendMarketing(ctx context.Context, data MarketingData, sender MarketingSend
 		UnsubscribeURL:   data.UnsubscribeURL,
 		UnsubscribeGroup: data.UnsubscribeGroup,
 		Tags:             data.Tags,
-		Metadata:         data.Metadata,
+		Metadata:         withMessageID(data.Metadata, message),
 		TrackOpens:       data.TrackOpens,
 		TrackClicks:      data.TrackClicks,
 	}
 
-	return sender.SendMarketing(ctx, payload)
+	providerMessageID, err := sender.SendMarketing(ctx, payload)
+
+	return recordResult(ctx, exec, attempt, message, providerMessageID, err)
+}
+
+// suppressedRecipients returns the recipients whose address is suppressed,
+// as they were given.
+func suppressedRecipients(
+	ctx context.Context,
+	exec storage.Executor,
+	recipients []string,
+) ([]string, error) {
+	addresses := make([]string, len(recipients))
+	for i, recipient := range recipients {
+		addresses[i] = recipient
+		if address, err := parseAddress(recipient); err == nil {
+			addresses[i] = address.Address
+		}
+	}
+
+	emails, err := models.FindSuppressedEmails(ctx, exec, addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	var suppressed []string
+	for i, address := range addresses {
+		if slices.Contains(emails, strings.ToLower(address)) {
+			suppressed = append(suppressed, recipients[i])
+		}
+	}
+
+	return suppressed, nil
+}
+
+func suppressedError(recipients []string) error {
+	return PermanentError{Err: fmt.Errorf("%w: %s", ErrSuppressed, strings.Join(recipients, ", "))}
+}
+
+func withMessageID(metadata map[string]string, message models.EmailMessage) map[string]string {
+	metadata = maps.Clone(metadata)
+	if metadata == nil {
+		metadata = make(map[string]string, 1)
+	}
+	metadata[MetadataMessageID] = message.ID.String()
+
+	return metadata
+}
+
+// recordResult logs how an attempt went and returns sendErr. A message that
+// went out but could not be marked sent is only logged: failing the job
+// would send it again.
+func recordResult(
+	ctx context.Context,
+	exec storage.Executor,
+	attempt Attempt,
+	message models.EmailMessage,
+	providerMessageID string,
+	sendErr error,
+) error {
+	if sendErr == nil {
+		if err := models.MarkEmailMessageSent(ctx, exec, message.ID, providerMessageID); err != nil {
+			slog.ErrorContext(
+				ctx,
+				"failed to mark email message sent",
+				"email_message_id",
+				message.ID,
+				"error",
+				err,
+			)
+		}
+
+		return nil
+	}
+
+	status := models.EmailMessageQueued
+	if !IsRetryable(sendErr) || attempt.Final {
+		status = models.EmailMessageFailed
+	}
+
+	if err := models.RecordEmailMessageError(ctx, exec, message.ID, status, sendErr.Error()); err != nil {
+		return errors.Join(sendErr, err)
+	}
+
+	return sendErr
 }
 
 func renderComponent(component templ.Component) (string, error) {
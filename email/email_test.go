@@ -0,0 +1,163 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/database"
+	"mbvlabs/models"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	body := `<a href="https://example.com/sessions/magic/ABCD2345EFGH">Sign in</a> ABCD2345EFGH`
+
+	got := redactSecrets(body, []string{"ABCD2345EFGH", ""})
+	if strings.Contains(got, "ABCD2345EFGH") {
+		t.Errorf("redactSecrets left the secret in %q", got)
+	}
+	if want := `<a href="https://example.com/sessions/magic/[redacted]">Sign in</a> [redacted]`; got != want {
+		t.Errorf("redactSecrets = %q, want %q", got, want)
+	}
+
+	if got := redactSecrets(body, nil); got != body {
+		t.Errorf("redactSecrets without secrets = %q, want the body unchanged", got)
+	}
+}
+
+func TestMagicLinkBodyRedacted(t *testing.T) {
+	token := "QWERTY234567ASDFGH"
+	message := MagicLink{SignInURL: "https://example.com/sessions/magic/" + token}
+
+	html, err := message.ToHTML()
+	if err != nil {
+		t.Fatalf("ToHTML: %v", err)
+	}
+	text, err := message.ToText()
+	if err != nil {
+		t.Fatalf("ToText: %v", err)
+	}
+
+	for name, body := range map[string]string{"html": html, "text": text} {
+		if !strings.Contains(body, token) {
+			t.Fatalf("%s body does not contain the token", name)
+		}
+		if strings.Contains(redactSecrets(body, []string{token}), token) {
+			t.Errorf("%s body still contains the token after redaction", name)
+		}
+	}
+}
+
+// fakeTransactionalSender answers every send with id and err, counting the
+// sends.
+type fakeTransactionalSender struct {
+	id    string
+	err   error
+	sends int
+}
+
+func (f *fakeTransactionalSender) SendTransactional(context.Context, TransactionalPayload) (string, error) {
+	f.sends++
+	return f.id, f.err
+}
+
+func TestSendTransactionalLogsDelivery(t *testing.T) {
+	exec := database.SharedTestDB(t).DB.Conn()
+	ctx := context.Background()
+
+	tests := []struct {
+		name           string
+		final          bool
+		sender         fakeTransactionalSender
+		wantStatus     models.EmailMessageStatus
+		wantProviderID string
+		wantError      bool
+	}{
+		{
+			name:           "sent",
+			sender:         fakeTransactionalSender{id: "pm-1"},
+			wantStatus:     models.EmailMessageSent,
+			wantProviderID: "pm-1",
+		},
+		{
+			name:       "temporary failure is retried",
+			sender:     fakeTransactionalSender{err: TemporaryError{Err: errors.New("down")}},
+			wantStatus: models.EmailMessageQueued,
+			wantError:  true,
+		},
+		{
+			name:       "temporary failure on the last attempt",
+			final:      true,
+			sender:     fakeTransactionalSender{err: TemporaryError{Err: errors.New("down")}},
+			wantStatus: models.EmailMessageFailed,
+			wantError:  true,
+		},
+		{
+			name:       "permanent failure",
+			sender:     fakeTransactionalSender{err: PermanentError{Err: errors.New("inactive recipient")}},
+			wantStatus: models.EmailMessageFailed,
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := "TOKEN" + strings.ToUpper(uuid.NewString()[:8])
+			attempt := Attempt{JobID: rand.Int64(), Final: tt.final}
+			data := TransactionalData{
+				Template: "magic_link",
+				To:       uuid.NewString() + "@example.com",
+				From:     "noreply@example.com",
+				Subject:  "Your Sign-in Link",
+				HTMLBody: `<a href="https://example.com/magic/` + secret + `">Sign in</a>`,
+				TextBody: "https://example.com/magic/" + secret,
+				Secrets:  []string{secret},
+			}
+
+			err := SendTransactional(ctx, exec, attempt, data, &tt.sender)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("SendTransactional = %v, want error %v", err, tt.wantError)
+			}
+
+			messages, err := models.FindEmailMessagesByRecipient(ctx, exec, data.To)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(messages) != 1 {
+				t.Fatalf("logged %d messages, want 1", len(messages))
+			}
+
+			message := messages[0]
+			if message.Status != tt.wantStatus || message.Attempts != 1 || message.ProviderMessageID != tt.wantProviderID {
+				t.Errorf("status %q, %d attempts, provider id %q; want %q, 1, %q",
+					message.Status, message.Attempts, message.ProviderMessageID, tt.wantStatus, tt.wantProviderID)
+			}
+			if tt.wantError && message.LastError == "" {
+				t.Error("the failure was not recorded")
+			}
+			if message.Template != data.Template {
+				t.Errorf("template = %q, want %q", message.Template, data.Template)
+			}
+			if strings.Contains(message.HTMLBody+message.TextBody, secret) {
+				t.Error("the logged bodies hold the secret")
+			}
+
+			// Running the job again must not send a delivered message
+			// twice.
+			retry := fakeTransactionalSender{id: "pm-retry"}
+			_ = SendTransactional(ctx, exec, attempt, data, &retry)
+
+			wantRetrySends := 1
+			if message.IsDelivered() {
+				wantRetrySends = 0
+			}
+			if retry.sends != wantRetrySends {
+				t.Errorf("retry sent %d times, want %d", retry.sends, wantRetrySends)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package email
+
+// EventType is what a provider reported about a sent message.
+type EventType string
+
+const (
+	// EventBounce is a hard bounce: the receiving server will never accept
+	// mail for the address.
+	EventBounce EventType = "bounce"
+	// EventComplaint is a recipient marking a message as spam.
+	EventComplaint EventType = "complaint"
+)
+
+// Event is a delivery report received from a provider's webhook. Only
+// reports that make an address unsafe to send to again are kept.
+type Event struct {
+	Type     EventType
+	Provider string
+	// Recipient is the address the report is about.
+	Recipient string
+	// MessageID is the id of the logged message, read back from the
+	// metadata it was sent with. It is empty for mail sent without it.
+	MessageID string
+	// ProviderMessageID is the id the provider gave the message when it
+	// was sent.
+	ProviderMessageID string
+	Details           string
+}
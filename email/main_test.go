@@ -0,0 +1,14 @@
+package email
+
+import (
+	"os"
+	"testing"
+
+	"mbvlabs/database"
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	database.CloseSharedTestDB()
+	os.Exit(code)
+}
@@ -0,0 +1,487 @@
+package email
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxHeaderLineLength is where header lines are folded, as RFC 5322
+// recommends. Lines only break at spaces, so an unbreakable value can run
+// longer.
+const maxHeaderLineLength = 78
+
+var ErrInvalidAddress = errors.New("invalid email address")
+
+// Message is an email ready to be written as MIME. Senders that speak SMTP
+// write it with WriteTo; Bcc only ever reaches the envelope.
+type Message struct {
+	From     string
+	To       []string
+	Cc       []string
+	Bcc      []string
+	ReplyTo  string
+	Subject  string
+	TextBody string
+	HTMLBody string
+	// Attachments marked Inline are images the HTML body shows with
+	// src="cid:<Name>". Without an HTML body they are sent as ordinary
+	// attachments.
+	Attachments []Attachment
+	// Headers are extra fields, such as List-Unsubscribe. They are written
+	// after the standard fields, sorted by name.
+	Headers map[string]string
+	// Date defaults to now and MessageID to a random id at the sender's
+	// domain.
+	Date      time.Time
+	MessageID string
+}
+
+func NewTransactionalMessage(payload TransactionalPayload) Message {
+	var to []string
+	if payload.To != "" {
+		to = []string{payload.To}
+	}
+
+	return Message{
+		From:        payload.From,
+		To:          to,
+		Cc:          payload.Cc,
+		Bcc:         payload.Bcc,
+		ReplyTo:     payload.ReplyTo,
+		Subject:     payload.Subject,
+		TextBody:    payload.TextBody,
+		HTMLBody:    payload.HTMLBody,
+		Attachments: payload.Attachments,
+		Headers:     metadataHeaders(payload.Metadata),
+	}
+}
+
+func NewMarketingMessage(payload MarketingPayload) Message {
+	headers := metadataHeaders(payload.Metadata)
+	if payload.UnsubscribeURL != "" {
+		headers["List-Unsubscribe"] = "<" + payload.UnsubscribeURL + ">"
+	}
+	if len(payload.Tags) > 0 {
+		headers["X-Tags"] = strings.Join(payload.Tags, ", ")
+	}
+
+	return Message{
+		From:     payload.From,
+		To:       payload.To,
+		ReplyTo:  payload.ReplyTo,
+		Subject:  payload.Subject,
+		TextBody: payload.TextBody,
+		HTMLBody: payload.HTMLBody,
+		Headers:  headers,
+	}
+}
+
+// metadataHeaders carries metadata as X-Metadata-<Key> fields, so it can be
+// found again in bounces and in the provider's logs.
+func metadataHeaders(metadata map[string]string) map[string]string {
+	headers := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		name := headerToken(key)
+		if name == "" {
+			continue
+		}
+		headers["X-Metadata-"+name] = value
+	}
+
+	return headers
+}
+
+// Recipients returns the bare addresses of every recipient, Bcc included,
+// for the SMTP envelope.
+func (m Message) Recipients() ([]string, error) {
+	var recipients []string
+	for _, list := range [][]string{m.To, m.Cc, m.Bcc} {
+		for _, raw := range list {
+			address, err := parseAddress(raw)
+			if err != nil {
+				return nil, err
+			}
+			recipients = append(recipients, address.Address)
+		}
+	}
+
+	return recipients, nil
+}
+
+// Sender returns the bare address of From for the SMTP envelope.
+func (m Message) Sender() (string, error) {
+	address, err := parseAddress(m.From)
+	if err != nil {
+		return "", err
+	}
+
+	return address.Address, nil
+}
+
+// Bytes returns the message as written by WriteTo.
+func (m Message) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// WriteTo writes the message with CRLF line endings. Header fields come in
+// a fixed order, non-ASCII text is encoded per RFC 2047, bodies are
+// quoted-printable and attachments base64. Malformed addresses fail with a
+// ValidationError.
+func (m Message) WriteTo(w io.Writer) (int64, error) {
+	header, err := m.header()
+	if err != nil {
+		return 0, err
+	}
+
+	buffered := bufio.NewWriter(w)
+	counter := &countingWriter{w: buffered}
+	if err := m.writeBody(counter, header); err != nil {
+		return counter.n, err
+	}
+
+	return counter.n, buffered.Flush()
+}
+
+// header returns the top level fields, in the order they are written.
+func (m Message) header() ([][2]string, error) {
+	from, err := parseAddress(m.From)
+	if err != nil {
+		return nil, err
+	}
+
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	messageID := m.MessageID
+	if messageID == "" {
+		messageID, err = NewMessageID(from.Address)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	header := [][2]string{
+		{"Date", date.Format(time.RFC1123Z)},
+		{"Message-ID", messageID},
+		{"From", from.String()},
+	}
+
+	if m.ReplyTo != "" {
+		replyTo, err := parseAddress(m.ReplyTo)
+		if err != nil {
+			return nil, err
+		}
+		header = append(header, [2]string{"Reply-To", replyTo.String()})
+	}
+
+	for _, field := range []struct {
+		name string
+		list []string
+	}{
+		{"To", m.To},
+		{"Cc", m.Cc},
+	} {
+		if len(field.list) == 0 {
+			continue
+		}
+
+		addresses := make([]string, len(field.list))
+		for i, raw := range field.list {
+			address, err := parseAddress(raw)
+			if err != nil {
+				return nil, err
+			}
+			addresses[i] = address.String()
+		}
+		header = append(header, [2]string{field.name, strings.Join(addresses, ", ")})
+	}
+
+	header = append(header,
+		[2]string{"Subject", mime.QEncoding.Encode("utf-8", m.Subject)},
+		[2]string{"MIME-Version", "1.0"},
+	)
+
+	for _, name := range slices.Sorted(maps.Keys(m.Headers)) {
+		canonical := textproto.CanonicalMIMEHeaderKey(headerToken(name))
+		if canonical == "" || isStandardHeader(canonical) {
+			continue
+		}
+		header = append(header, [2]string{canonical, mime.QEncoding.Encode("utf-8", m.Headers[name])})
+	}
+
+	return header, nil
+}
+
+// part writes its own header fields, extra ones first, and its content.
+type part func(w io.Writer, fields [][2]string) error
+
+// writeBody lays the parts out as
+//
+//	mixed
+//	├── alternative
+//	│   ├── text/plain
+//	│   └── related
+//	│       ├── text/html
+//	│       └── inline images
+//	└── attachments
+//
+// leaving out every multipart level that would hold a single part.
+func (m Message) writeBody(w io.Writer, header [][2]string) error {
+	var inline, attached []part
+	for _, attachment := range m.Attachments {
+		if attachment.Inline && m.HTMLBody != "" {
+			inline = append(inline, attachmentPart(attachment))
+		} else {
+			attached = append(attached, attachmentPart(attachment))
+		}
+	}
+
+	html := textPart("text/html", m.HTMLBody)
+	if len(inline) > 0 {
+		html = multipartPart("multipart/related", append([]part{html}, inline...))
+	}
+
+	var body part
+	switch {
+	case m.HTMLBody == "":
+		body = textPart("text/plain", m.TextBody)
+	case m.TextBody == "":
+		body = html
+	default:
+		body = multipartPart("multipart/alternative", []part{textPart("text/plain", m.TextBody), html})
+	}
+
+	if len(attached) > 0 {
+		body = multipartPart("multipart/mixed", append([]part{body}, attached...))
+	}
+
+	return body(w, header)
+}
+
+func multipartPart(contentType string, parts []part) part {
+	return func(w io.Writer, fields [][2]string) error {
+		boundary, err := randomHex(24)
+		if err != nil {
+			return err
+		}
+
+		fields = append(fields, [2]string{
+			"Content-Type",
+			mime.FormatMediaType(contentType, map[string]string{"boundary": boundary}),
+		})
+		if err := writeFields(w, fields); err != nil {
+			return err
+		}
+
+		for i, p := range parts {
+			// The line break before a delimiter belongs to the delimiter,
+			// so the first one has none.
+			delimiter := "\r\n--" + boundary + "\r\n"
+			if i == 0 {
+				delimiter = delimiter[2:]
+			}
+			if _, err := io.WriteString(w, delimiter); err != nil {
+				return err
+			}
+			if err := p(w, nil); err != nil {
+				return err
+			}
+		}
+
+		_, err = io.WriteString(w, "\r\n--"+boundary+"--\r\n")
+
+		return err
+	}
+}
+
+func textPart(contentType string, text string) part {
+	return func(w io.Writer, fields [][2]string) error {
+		fields = append(fields,
+			[2]string{"Content-Type", contentType + "; charset=utf-8"},
+			[2]string{"Content-Transfer-Encoding", "quoted-printable"},
+		)
+		if err := writeFields(w, fields); err != nil {
+			return err
+		}
+
+		qp := quotedprintable.NewWriter(w)
+		if _, err := io.WriteString(qp, text); err != nil {
+			return err
+		}
+
+		return qp.Close()
+	}
+}
+
+// attachmentPart writes an attachment base64 encoded. Inline attachments
+// get their name as Content-ID.
+func attachmentPart(attachment Attachment) part {
+	return func(w io.Writer, fields [][2]string) error {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = mime.TypeByExtension(path.Ext(attachment.Name))
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		disposition := "attachment"
+		fields = append(fields,
+			[2]string{"Content-Type", contentType},
+			[2]string{"Content-Transfer-Encoding", "base64"},
+		)
+		if attachment.Inline {
+			disposition = "inline"
+			fields = append(fields, [2]string{"Content-ID", "<" + attachment.Name + ">"})
+		}
+		fields = append(fields, [2]string{
+			"Content-Disposition",
+			mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Name}),
+		})
+
+		if err := writeFields(w, fields); err != nil {
+			return err
+		}
+
+		encoded := base64.StdEncoding.EncodeToString(attachment.Content)
+		lines := make([]string, 0, len(encoded)/76+1)
+		for len(encoded) > 76 {
+			lines = append(lines, encoded[:76])
+			encoded = encoded[76:]
+		}
+		lines = append(lines, encoded)
+
+		_, err := io.WriteString(w, strings.Join(lines, "\r\n"))
+
+		return err
+	}
+}
+
+// writeFields writes the header fields, folded, and the blank line that
+// ends them.
+func writeFields(w io.Writer, fields [][2]string) error {
+	var b strings.Builder
+	for _, field := range fields {
+		b.WriteString(foldHeader(field[0], field[1]))
+	}
+	b.WriteString("\r\n")
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// foldHeader writes one field, breaking it before spaces so that lines
+// stay within maxHeaderLineLength where possible. Unfolding gives back the
+// value unchanged.
+func foldHeader(name string, value string) string {
+	var b strings.Builder
+	line := name + ":"
+
+	for i, word := range strings.Split(value, " ") {
+		if i > 0 && word != "" && len(line)+1+len(word) > maxHeaderLineLength {
+			b.WriteString(line)
+			b.WriteString("\r\n")
+			line = ""
+		}
+		line += " " + word
+	}
+
+	b.WriteString(line)
+	b.WriteString("\r\n")
+
+	return b.String()
+}
+
+func parseAddress(raw string) (*mail.Address, error) {
+	address, err := mail.ParseAddress(raw)
+	if err != nil {
+		return nil, ValidationError{Err: fmt.Errorf("%w %q: %w", ErrInvalidAddress, raw, err)}
+	}
+
+	return address, nil
+}
+
+// NewMessageID returns a random Message-ID at the sender's domain.
+func NewMessageID(sender string) (string, error) {
+	_, domain, ok := strings.Cut(sender, "@")
+	if !ok || domain == "" {
+		domain = "localhost"
+	}
+
+	id, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+
+	return "<" + id + "@" + domain + ">", nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// headerToken keeps the characters allowed in a field name that are also
+// safe in any header, turning underscores into dashes and dropping
+// everything else.
+func headerToken(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r == '_':
+			return '-'
+		default:
+			return -1
+		}
+	}, s)
+}
+
+func isStandardHeader(name string) bool {
+	switch name {
+	case "Date", "Message-Id", "From", "Reply-To", "To", "Cc", "Bcc",
+		"Subject", "Mime-Version", "Content-Type", "Content-Transfer-Encoding":
+		return true
+	default:
+		return false
+	}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+
+	return n, err
+}
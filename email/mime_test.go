@@ -0,0 +1,383 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var (
+	fixedDate   = time.Date(2026, time.March, 14, 9, 26, 53, 0, time.UTC)
+	pixel       = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01")
+	invoicePDF  = bytes.Repeat([]byte("%PDF-1.7 invoice "), 8)
+	boundaryRes = regexp.MustCompile(`boundary=([0-9a-f]{48})`)
+)
+
+// mimeCases are written out and compared with testdata/<name>.golden.
+var mimeCases = []struct {
+	name    string
+	message Message
+}{
+	{
+		name: "text_only",
+		message: Message{
+			From:     "noreply@example.com",
+			To:       []string{"jane@example.com"},
+			Subject:  "Your Sign-in Link",
+			TextBody: "Sign in: https://example.com/sessions/magic/ABC\n",
+		},
+	},
+	{
+		name: "alternative_non_ascii",
+		message: NewTransactionalMessage(TransactionalPayload{
+			To:       "Zoë Ångström <zoe@example.com>",
+			Cc:       []string{"ops@example.com"},
+			Bcc:      []string{"audit@example.com"},
+			From:     "Acme Support <support@example.com>",
+			ReplyTo:  "help@example.com",
+			Subject:  "Bekræft din e-mail — næsten færdig",
+			TextBody: "Hej Zoë,\n\nBekræft din e-mail her. Denne linje er lang nok til at blive brudt af quoted-printable, fordi den er over 76 tegn.\n",
+			HTMLBody: "<p>Hej Zoë,</p><p>Bekræft din e-mail <a href=\"https://example.com/confirm?token=abc&amp;next=%2F\">her</a>.</p>",
+			Metadata: map[string]string{"template": "verify_email", "user_id": "42"},
+		}),
+	},
+	{
+		name: "inline_and_attachment",
+		message: NewTransactionalMessage(TransactionalPayload{
+			To:       "jane@example.com",
+			From:     "billing@example.com",
+			Subject:  "Your invoice",
+			TextBody: "Your invoice is attached.",
+			HTMLBody: `<p><img src="cid:logo.png"> Your invoice is attached.</p>`,
+			Attachments: []Attachment{
+				{Name: "logo.png", Content: pixel, Inline: true},
+				{Name: "invoice 2026-03.pdf", Content: invoicePDF},
+			},
+		}),
+	},
+	{
+		name: "marketing_long_headers",
+		message: NewMarketingMessage(MarketingPayload{
+			To: []string{
+				"Alexandra Constantinopoulou <alexandra.constantinopoulou@example.com>",
+				"Bartholomew Featherstonehaugh <bartholomew.featherstonehaugh@example.com>",
+				"carol@example.com",
+			},
+			From:           "Acme Newsletter <news@example.com>",
+			Subject:        "Spring update: new dashboards, faster exports, and a sneak peek at what comes next",
+			HTMLBody:       "<h1>Spring update</h1>",
+			TextBody:       "Spring update",
+			UnsubscribeURL: "https://example.com/unsubscribe/abcdef",
+			Tags:           []string{"newsletter", "spring", "product"},
+		}),
+	},
+}
+
+// normalizedBytes writes the message with a fixed date and id, and with
+// the random boundaries replaced by numbered placeholders.
+func normalizedBytes(t *testing.T, message Message) []byte {
+	t.Helper()
+
+	message.Date = fixedDate
+	message.MessageID = "<0123456789abcdef@example.com>"
+
+	raw, err := message.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+
+	for i, match := range boundaryRes.FindAllSubmatch(raw, -1) {
+		raw = bytes.ReplaceAll(raw, match[1], []byte(fmt.Sprintf("BOUNDARY-%d", i+1)))
+	}
+
+	return raw
+}
+
+func TestMessageGolden(t *testing.T) {
+	for _, tc := range mimeCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := normalizedBytes(t, tc.message)
+
+			golden := filepath.Join("testdata", tc.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("read golden file (run with -update to create it): %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("message differs from %s (run with -update if the change is intended):\n%s", golden, got)
+			}
+		})
+	}
+}
+
+// parsedPart is a leaf of a parsed message with its content decoded.
+type parsedPart struct {
+	contentType string
+	header      map[string][]string
+	content     []byte
+}
+
+// parseMessage reads a message back with net/mail and mime/multipart and
+// returns its leaf parts in order.
+func parseMessage(t *testing.T, raw []byte) (*mail.Message, []parsedPart) {
+	t.Helper()
+
+	message, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("net/mail could not read the message: %v", err)
+	}
+
+	return message, collectParts(t, message.Header, message.Body)
+}
+
+func collectParts(t *testing.T, header map[string][]string, body io.Reader) []parsedPart {
+	t.Helper()
+
+	get := func(name string) string {
+		if values := header[name]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	mediaType, params, err := mime.ParseMediaType(get("Content-Type"))
+	if err != nil {
+		t.Fatalf("parse Content-Type %q: %v", get("Content-Type"), err)
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		var parts []parsedPart
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := reader.NextRawPart()
+			if err == io.EOF {
+				return parts
+			}
+			if err != nil {
+				t.Fatalf("read %s part: %v", mediaType, err)
+			}
+			parts = append(parts, collectParts(t, p.Header, p)...)
+		}
+	}
+
+	switch get("Content-Transfer-Encoding") {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	content, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("decode %s part: %v", mediaType, err)
+	}
+
+	return []parsedPart{{contentType: mediaType, header: header, content: content}}
+}
+
+func TestMessageParsesBack(t *testing.T) {
+	decoder := new(mime.WordDecoder)
+
+	for _, tc := range mimeCases {
+		t.Run(tc.name, func(t *testing.T) {
+			raw, err := tc.message.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, parts := parseMessage(t, raw)
+
+			subject, err := decoder.DecodeHeader(parsed.Header.Get("Subject"))
+			if err != nil || subject != tc.message.Subject {
+				t.Errorf("Subject = %q, %v, want %q", subject, err, tc.message.Subject)
+			}
+
+			from, err := parsed.Header.AddressList("From")
+			want, _ := mail.ParseAddress(tc.message.From)
+			if err != nil || len(from) != 1 || *from[0] != *want {
+				t.Errorf("From = %v, %v, want %v", from, err, want)
+			}
+
+			to, err := parsed.Header.AddressList("To")
+			if err != nil || len(to) != len(tc.message.To) {
+				t.Fatalf("To = %v, %v, want %d addresses", to, err, len(tc.message.To))
+			}
+			for i, raw := range tc.message.To {
+				want, _ := mail.ParseAddress(raw)
+				if *to[i] != *want {
+					t.Errorf("To[%d] = %v, want %v", i, to[i], want)
+				}
+			}
+
+			if _, err := parsed.Header.Date(); err != nil {
+				t.Errorf("Date: %v", err)
+			}
+			if parsed.Header.Get("Message-Id") == "" {
+				t.Error("Message-ID is missing")
+			}
+			if parsed.Header.Get("Bcc") != "" {
+				t.Error("Bcc reached the header")
+			}
+
+			var text, html string
+			var attachments []Attachment
+			for _, p := range parts {
+				switch p.contentType {
+				// Text line breaks go out as CRLF, as RFC 2045 requires.
+				case "text/plain":
+					text = strings.ReplaceAll(string(p.content), "\r\n", "\n")
+				case "text/html":
+					html = strings.ReplaceAll(string(p.content), "\r\n", "\n")
+				default:
+					_, params, err := mime.ParseMediaType(p.header["Content-Disposition"][0])
+					if err != nil {
+						t.Fatalf("parse Content-Disposition: %v", err)
+					}
+					attachments = append(attachments, Attachment{
+						Name:    params["filename"],
+						Content: p.content,
+						Inline:  len(p.header["Content-Id"]) > 0,
+					})
+				}
+			}
+
+			if text != tc.message.TextBody {
+				t.Errorf("text body = %q, want %q", text, tc.message.TextBody)
+			}
+			if html != tc.message.HTMLBody {
+				t.Errorf("html body = %q, want %q", html, tc.message.HTMLBody)
+			}
+			if len(attachments) != len(tc.message.Attachments) {
+				t.Fatalf("got %d attachments, want %d", len(attachments), len(tc.message.Attachments))
+			}
+			for i, want := range tc.message.Attachments {
+				got := attachments[i]
+				if got.Name != want.Name || got.Inline != want.Inline || !bytes.Equal(got.Content, want.Content) {
+					t.Errorf("attachment %d = %q inline=%v, want %q inline=%v", i, got.Name, got.Inline, want.Name, want.Inline)
+				}
+			}
+		})
+	}
+}
+
+func TestMessageHeaderLinesAreFolded(t *testing.T) {
+	for _, tc := range mimeCases {
+		raw, err := tc.message.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		header, _, _ := bytes.Cut(raw, []byte("\r\n\r\n"))
+		for _, line := range strings.Split(string(header), "\r\n") {
+			if len(line) > maxHeaderLineLength && strings.Contains(strings.TrimSpace(line), " ") {
+				t.Errorf("%s: header line of %d characters was not folded: %q", tc.name, len(line), line)
+			}
+		}
+	}
+}
+
+func TestMessageBoundariesAreRandom(t *testing.T) {
+	message := mimeCases[1].message
+
+	first, err := message.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := message.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := boundaryRes.FindSubmatch(first)
+	b := boundaryRes.FindSubmatch(second)
+	if a == nil || b == nil {
+		t.Fatal("no boundary found")
+	}
+	if bytes.Equal(a[1], b[1]) {
+		t.Errorf("two messages share the boundary %s", a[1])
+	}
+}
+
+func TestMessageRecipients(t *testing.T) {
+	message := mimeCases[1].message
+
+	recipients, err := message.Recipients()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"zoe@example.com", "ops@example.com", "audit@example.com"}
+	if strings.Join(recipients, ",") != strings.Join(want, ",") {
+		t.Errorf("Recipients = %q, want %q", recipients, want)
+	}
+
+	sender, err := message.Sender()
+	if err != nil || sender != "support@example.com" {
+		t.Errorf("Sender = %q, %v, want support@example.com", sender, err)
+	}
+}
+
+func TestMessageRejectsInvalidAddresses(t *testing.T) {
+	tests := []struct {
+		name    string
+		message Message
+	}{
+		{name: "from", message: Message{From: "not an address", To: []string{"jane@example.com"}}},
+		{name: "to", message: Message{From: "noreply@example.com", To: []string{"jane@"}}},
+		{name: "header injection", message: Message{From: "noreply@example.com", To: []string{"jane@example.com\r\nBcc: x@example.com"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.message.Bytes()
+			if !IsValidationError(err) || !errors.Is(err, ErrInvalidAddress) {
+				t.Errorf("Bytes = %v, want a ValidationError wrapping ErrInvalidAddress", err)
+			}
+		})
+	}
+}
+
+func TestMetadataHeadersAreSanitized(t *testing.T) {
+	message := Message{
+		From:     "noreply@example.com",
+		To:       []string{"jane@example.com"},
+		TextBody: "hi",
+		Headers: metadataHeaders(map[string]string{
+			"user_id":           "42",
+			"bad\r\nBcc: x@y.z": "value",
+			"note":              "line\r\nBcc: x@example.com",
+		}),
+	}
+
+	raw, err := message.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, _ := parseMessage(t, raw)
+	if parsed.Header.Get("Bcc") != "" {
+		t.Fatalf("metadata injected a Bcc field:\n%s", raw)
+	}
+	if got := parsed.Header.Get("X-Metadata-User-Id"); got != "42" {
+		t.Errorf("X-Metadata-User-Id = %q, want 42", got)
+	}
+}
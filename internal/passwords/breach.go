@@ -0,0 +1,110 @@
+package passwords
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// BreachChecker reports how many times a password has been seen in known
+// data breaches. Zero means it was not found.
+type BreachChecker interface {
+	Occurrences(ctx context.Context, password string) (int, error)
+}
+
+// RangeCorpus reads a local copy of the Pwned Passwords corpus in the HIBP
+// range format: one file per five character SHA-1 prefix, named after the
+// prefix with an optional ".txt" extension, holding "SUFFIX:COUNT" lines.
+// This is the layout the official downloader writes, so no password or
+// hash ever leaves the machine.
+type RangeCorpus struct {
+	fsys fs.FS
+}
+
+func NewRangeCorpus(fsys fs.FS) RangeCorpus {
+	return RangeCorpus{fsys: fsys}
+}
+
+var _ BreachChecker = RangeCorpus{}
+
+func (r RangeCorpus) Occurrences(ctx context.Context, password string) (int, error) {
+	prefix, suffix := hashRange(password)
+
+	file, err := r.fsys.Open(prefix)
+	if errors.Is(err, fs.ErrNotExist) {
+		file, err = r.fsys.Open(prefix + ".txt")
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		candidate, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok || !strings.EqualFold(candidate, suffix) {
+			continue
+		}
+
+		occurrences, err := strconv.Atoi(count)
+		if err != nil {
+			return 0, err
+		}
+
+		return occurrences, nil
+	}
+
+	return 0, scanner.Err()
+}
+
+// bundledCorpus holds the common passwords shipped with the binary. Their
+// breach counts are not known, so a match is reported as one occurrence.
+type bundledCorpus struct{}
+
+// Bundled returns a checker for the common password list compiled into the
+// binary. It catches the most popular breached passwords without needing a
+// copy of the full corpus.
+func Bundled() BreachChecker {
+	return bundledCorpus{}
+}
+
+var bundledHashes = sync.OnceValue(func() map[string]struct{} {
+	hashes := make(map[string]struct{}, len(commonPasswords()))
+	for password := range commonPasswords() {
+		prefix, suffix := hashRange(password)
+		hashes[prefix+suffix] = struct{}{}
+	}
+
+	return hashes
+})
+
+func (bundledCorpus) Occurrences(ctx context.Context, password string) (int, error) {
+	prefix, suffix := hashRange(password)
+	if _, ok := bundledHashes()[prefix+suffix]; ok {
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+// hashRange splits the uppercase hex SHA-1 of password into the five
+// character range prefix and the remaining suffix.
+func hashRange(password string) (string, string) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	return hash[:5], hash[5:]
+}
@@ -0,0 +1,742 @@
+// Package passwords estimates how guessable a password is and checks it
+// against known breaches.
+//
+// The estimator follows zxcvbn: it finds every dictionary word, sequence,
+// repeat, keyboard row and year in the password, then picks the cheapest
+// way for an attacker to cover the whole password with those patterns and
+// brute force. The result is a guess count and a 0-4 score.
+package passwords
+
+import (
+	_ "embed"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	bruteforceCardinality           = 10
+	minGuessesBeforeGrowingSequence = 10000
+	minSubmatchGuessesSingleChar    = 10
+	minSubmatchGuessesMultiChar     = 50
+	minYearSpace                    = 20
+
+	keyboardStartingPositions = 47
+	keyboardAverageDegree     = 4
+)
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+// commonPasswords maps each bundled common password to its popularity
+// rank, starting at 1.
+var commonPasswords = sync.OnceValue(func() map[string]int {
+	ranks := make(map[string]int)
+	for line := range strings.Lines(commonPasswordList) {
+		word := strings.TrimSpace(line)
+		if word == "" {
+			continue
+		}
+		if _, ok := ranks[word]; !ok {
+			ranks[word] = len(ranks) + 1
+		}
+	}
+
+	return ranks
+})
+
+var keyboardRows = []string{
+	"`1234567890-=",
+	"qwertyuiop[]\\",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+}
+
+var l33tTable = map[rune][]rune{
+	'4': {'a'},
+	'@': {'a'},
+	'8': {'b'},
+	'(': {'c'},
+	'3': {'e'},
+	'6': {'g'},
+	'1': {'i', 'l'},
+	'!': {'i'},
+	'|': {'i', 'l'},
+	'0': {'o'},
+	'$': {'s'},
+	'5': {'s'},
+	'7': {'t'},
+	'+': {'t'},
+	'2': {'z'},
+}
+
+// Strength is the estimate for one password. Warning and Suggestions are
+// only filled in for passwords that score 2 or lower.
+type Strength struct {
+	Score       int
+	Guesses     float64
+	Warning     string
+	Suggestions []string
+}
+
+type matchKind int
+
+const (
+	kindBruteforce matchKind = iota
+	kindDictionary
+	kindUserInput
+	kindSequence
+	kindRepeat
+	kindKeyboard
+	kindYear
+)
+
+type match struct {
+	kind     matchKind
+	i, j     int
+	token    string
+	guesses  float64
+	rank     int
+	reversed bool
+	l33t     bool
+	baseLen  int
+}
+
+// Estimate scores password. userInputs are words an attacker would try
+// first for this account, such as the email address and the site name.
+func Estimate(password string, userInputs ...string) Strength {
+	chars := []rune(password)
+	if len(chars) == 0 {
+		return Strength{
+			Warning: "Enter a password",
+			Suggestions: []string{
+				"Use a few words, avoid common phrases",
+				"No need for symbols, digits, or uppercase letters",
+			},
+		}
+	}
+
+	matches := findMatches(chars, rankUserInputs(userInputs))
+	guesses, sequence := mostGuessableSequence(chars, matches)
+
+	strength := Strength{
+		Score:   scoreFor(guesses),
+		Guesses: guesses,
+	}
+	if strength.Score <= 2 {
+		strength.Warning, strength.Suggestions = feedback(sequence)
+	}
+
+	return strength
+}
+
+func scoreFor(guesses float64) int {
+	const delta = 5
+	switch {
+	case guesses < 1e3+delta:
+		return 0
+	case guesses < 1e6+delta:
+		return 1
+	case guesses < 1e8+delta:
+		return 2
+	case guesses < 1e10+delta:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// rankUserInputs splits inputs into words, so "jane.doe@example.com" also
+// yields "jane", "doe" and "example".
+func rankUserInputs(inputs []string) map[string]int {
+	ranks := make(map[string]int)
+	add := func(word string) {
+		word = strings.ToLower(word)
+		if len([]rune(word)) < 3 {
+			return
+		}
+		if _, ok := ranks[word]; !ok {
+			ranks[word] = len(ranks) + 1
+		}
+	}
+
+	for _, input := range inputs {
+		add(input)
+		for _, part := range strings.FieldsFunc(input, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			add(part)
+		}
+	}
+
+	return ranks
+}
+
+func findMatches(chars []rune, userInputs map[string]int) []match {
+	var matches []match
+	matches = append(matches, dictionaryMatches(chars, userInputs)...)
+	matches = append(matches, sequenceMatches(chars)...)
+	matches = append(matches, repeatMatches(chars, userInputs)...)
+	matches = append(matches, keyboardMatches(chars)...)
+	matches = append(matches, yearMatches(chars)...)
+
+	return matches
+}
+
+func dictionaryMatches(chars []rune, userInputs map[string]int) []match {
+	lower := []rune(strings.ToLower(string(chars)))
+	common := commonPasswords()
+
+	var matches []match
+	lookup := func(i, j int, word string, reversed, l33t bool) {
+		token := string(chars[i : j+1])
+		if rank, ok := userInputs[word]; ok {
+			matches = append(matches, newDictionaryMatch(kindUserInput, i, j, token, rank, reversed, l33t))
+		}
+		if rank, ok := common[word]; ok {
+			matches = append(matches, newDictionaryMatch(kindDictionary, i, j, token, rank, reversed, l33t))
+		}
+	}
+
+	for i := range lower {
+		for j := i + 2; j < len(lower); j++ {
+			word := string(lower[i : j+1])
+			lookup(i, j, word, false, false)
+			lookup(i, j, reverse(word), true, false)
+
+			for _, variant := range unl33t(lower[i : j+1]) {
+				lookup(i, j, variant, false, true)
+			}
+		}
+	}
+
+	return matches
+}
+
+func newDictionaryMatch(kind matchKind, i, j int, token string, rank int, reversed, l33t bool) match {
+	guesses := float64(rank) * uppercaseVariations(token)
+	if reversed {
+		guesses *= 2
+	}
+	if l33t {
+		guesses *= 2
+	}
+
+	return match{
+		kind:     kind,
+		i:        i,
+		j:        j,
+		token:    token,
+		rank:     rank,
+		reversed: reversed,
+		l33t:     l33t,
+		guesses:  guesses,
+	}
+}
+
+// uppercaseVariations counts the ways an attacker would capitalise a word,
+// with the common first-letter, last-letter and all-caps forms cheapest.
+func uppercaseVariations(token string) float64 {
+	var upper, lower int
+	for _, r := range token {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+
+	if upper == 0 || token == strings.ToLower(token) {
+		return 1
+	}
+
+	runes := []rune(token)
+	if lower == 0 || (upper == 1 && (unicode.IsUpper(runes[0]) || unicode.IsUpper(runes[len(runes)-1]))) {
+		return 2
+	}
+
+	var variations float64
+	for k := 1; k <= min(upper, lower); k++ {
+		variations += binomial(upper+lower, k)
+	}
+
+	return max(variations, 1)
+}
+
+// unl33t returns the lowercase word with common substitutions undone. A
+// symbol that stands for more than one letter yields one variant per letter.
+func unl33t(token []rune) []string {
+	variants := []string{""}
+	substituted := false
+	for _, r := range token {
+		letters, ok := l33tTable[r]
+		if !ok {
+			for i := range variants {
+				variants[i] += string(r)
+			}
+			continue
+		}
+
+		substituted = true
+		next := make([]string, 0, len(variants)*len(letters))
+		for _, variant := range variants {
+			for _, letter := range letters {
+				next = append(next, variant+string(letter))
+			}
+		}
+		variants = next
+		if len(variants) > 16 {
+			return nil
+		}
+	}
+
+	if !substituted {
+		return nil
+	}
+
+	return variants
+}
+
+// sequenceMatches finds runs like "abc", "6543" or "XYZ" that step by one
+// within a single character class.
+func sequenceMatches(chars []rune) []match {
+	var matches []match
+
+	flush := func(i, j, delta int) {
+		if j-i < 2 || (delta != 1 && delta != -1) {
+			return
+		}
+
+		class := charClass(chars[i])
+		for _, r := range chars[i : j+1] {
+			if class == 0 || charClass(r) != class {
+				return
+			}
+		}
+
+		first := chars[i]
+
+		var base float64
+		switch {
+		case strings.ContainsRune("aAzZ019", first):
+			base = 4
+		case unicode.IsDigit(first):
+			base = 10
+		default:
+			base = 26
+		}
+		if delta < 0 {
+			base *= 2
+		}
+
+		matches = append(matches, match{
+			kind:    kindSequence,
+			i:       i,
+			j:       j,
+			token:   string(chars[i : j+1]),
+			guesses: base * float64(j-i+1),
+		})
+	}
+
+	if len(chars) < 3 {
+		return nil
+	}
+
+	start := 0
+	lastDelta := int(chars[1]) - int(chars[0])
+	for k := 2; k < len(chars); k++ {
+		delta := int(chars[k]) - int(chars[k-1])
+		if delta == lastDelta {
+			continue
+		}
+
+		flush(start, k-1, lastDelta)
+		start = k - 1
+		lastDelta = delta
+	}
+	flush(start, len(chars)-1, lastDelta)
+
+	return matches
+}
+
+func charClass(r rune) int {
+	switch {
+	case unicode.IsLower(r):
+		return 1
+	case unicode.IsUpper(r):
+		return 2
+	case unicode.IsDigit(r):
+		return 3
+	default:
+		return 0
+	}
+}
+
+// repeatMatches finds a unit repeated back to back, like "aaa" or
+// "abcabc". Its guesses are those of the unit times the repeat count. Only
+// the longest run of each smallest unit is considered.
+func repeatMatches(chars []rune, userInputs map[string]int) []match {
+	var matches []match
+	baseGuesses := make(map[string]float64)
+
+	for i := range chars {
+		for unit := 1; i+2*unit <= len(chars); unit++ {
+			base := string(chars[i : i+unit])
+			if i >= unit && string(chars[i-unit:i]) == base {
+				continue
+			}
+			if !isPrimitive(chars[i : i+unit]) {
+				continue
+			}
+
+			count := 1
+			for i+(count+1)*unit <= len(chars) &&
+				string(chars[i+count*unit:i+(count+1)*unit]) == base {
+				count++
+			}
+
+			if count < 2 || count*unit < 3 {
+				continue
+			}
+
+			guesses, ok := baseGuesses[base]
+			if !ok {
+				guesses = float64(cardinality(chars[i]))
+				if unit > 1 {
+					guesses, _ = mostGuessableSequence([]rune(base), findMatches([]rune(base), userInputs))
+				}
+				baseGuesses[base] = guesses
+			}
+
+			matches = append(matches, match{
+				kind:    kindRepeat,
+				i:       i,
+				j:       i + count*unit - 1,
+				token:   string(chars[i : i+count*unit]),
+				guesses: guesses * float64(count),
+				baseLen: unit,
+			})
+		}
+	}
+
+	return matches
+}
+
+// isPrimitive reports whether unit is not itself a repeat of a shorter
+// unit, as "abab" is of "ab".
+func isPrimitive(unit []rune) bool {
+	n := len(unit)
+	for size := 1; size <= n/2; size++ {
+		if n%size != 0 {
+			continue
+		}
+
+		repeated := true
+		for k := size; k < n; k++ {
+			if unit[k] != unit[k-size] {
+				repeated = false
+				break
+			}
+		}
+		if repeated {
+			return false
+		}
+	}
+
+	return true
+}
+
+func cardinality(r rune) int {
+	switch charClass(r) {
+	case 1, 2:
+		return 26
+	case 3:
+		return 10
+	default:
+		return 33
+	}
+}
+
+func keyboardMatches(chars []rune) []match {
+	lower := []rune(strings.ToLower(string(chars)))
+
+	var matches []match
+	for i := range lower {
+		for j := i + 3; j < len(lower); j++ {
+			token := string(lower[i : j+1])
+			if !onKeyboardRow(token) {
+				continue
+			}
+
+			guesses := float64(keyboardStartingPositions*keyboardAverageDegree) * float64(j-i)
+			guesses *= uppercaseVariations(string(chars[i : j+1]))
+
+			matches = append(matches, match{
+				kind:    kindKeyboard,
+				i:       i,
+				j:       j,
+				token:   string(chars[i : j+1]),
+				guesses: guesses,
+			})
+		}
+	}
+
+	return matches
+}
+
+func onKeyboardRow(token string) bool {
+	for _, row := range keyboardRows {
+		if strings.Contains(row, token) || strings.Contains(row, reverse(token)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func yearMatches(chars []rune) []match {
+	reference := time.Now().Year()
+
+	var matches []match
+	for i := 0; i+4 <= len(chars); i++ {
+		token := string(chars[i : i+4])
+		year, err := strconv.Atoi(token)
+		if err != nil || year < 1900 || year > 2049 {
+			continue
+		}
+
+		matches = append(matches, match{
+			kind:    kindYear,
+			i:       i,
+			j:       i + 3,
+			token:   token,
+			guesses: float64(max(abs(year-reference), minYearSpace)),
+		})
+	}
+
+	return matches
+}
+
+// mostGuessableSequence finds the cover of the password by matches and
+// brute force runs that an attacker would need the fewest guesses for.
+// Longer sequences pay a factorial and additive penalty, as in zxcvbn.
+func mostGuessableSequence(chars []rune, matches []match) (float64, []match) {
+	n := len(chars)
+
+	type step struct {
+		match   match
+		product float64
+		guesses float64
+	}
+
+	// optimal[k][l] is the best cover of chars[:k+1] using l matches.
+	optimal := make([]map[int]step, n)
+	for k := range optimal {
+		optimal[k] = make(map[int]step)
+	}
+
+	update := func(m match, l int) {
+		k := m.j
+		product := m.guesses
+		if l > 1 {
+			product *= optimal[m.i-1][l-1].product
+		}
+
+		guesses := factorial(l)*product + math.Pow(minGuessesBeforeGrowingSequence, float64(l-1))
+		for otherL, other := range optimal[k] {
+			if otherL <= l && other.guesses <= guesses {
+				return
+			}
+		}
+
+		optimal[k][l] = step{match: m, product: product, guesses: guesses}
+	}
+
+	byEnd := make([][]match, n)
+	for _, m := range matches {
+		m.guesses = max(m.guesses, minSubmatchGuesses(m, n))
+		byEnd[m.j] = append(byEnd[m.j], m)
+	}
+
+	for k := range n {
+		for _, m := range byEnd[k] {
+			if m.i == 0 {
+				update(m, 1)
+				continue
+			}
+			for l := range optimal[m.i-1] {
+				update(m, l+1)
+			}
+		}
+
+		for i := 0; i <= k; i++ {
+			bruteforce := bruteforceMatch(chars, i, k)
+			if i == 0 {
+				update(bruteforce, 1)
+				continue
+			}
+			for l, previous := range optimal[i-1] {
+				if previous.match.kind == kindBruteforce {
+					continue
+				}
+				update(bruteforce, l+1)
+			}
+		}
+	}
+
+	bestL := 0
+	best := math.Inf(1)
+	for l, s := range optimal[n-1] {
+		if s.guesses < best {
+			best = s.guesses
+			bestL = l
+		}
+	}
+
+	sequence := make([]match, bestL)
+	k, l := n-1, bestL
+	for l > 0 {
+		m := optimal[k][l].match
+		sequence[l-1] = m
+		k = m.i - 1
+		l--
+	}
+
+	return best, sequence
+}
+
+func minSubmatchGuesses(m match, passwordLen int) float64 {
+	if m.j-m.i+1 >= passwordLen {
+		return 1
+	}
+	if m.i == m.j {
+		return minSubmatchGuessesSingleChar
+	}
+
+	return minSubmatchGuessesMultiChar
+}
+
+func bruteforceMatch(chars []rune, i, j int) match {
+	length := j - i + 1
+	guesses := math.Pow(bruteforceCardinality, float64(length))
+
+	minGuesses := float64(minSubmatchGuessesMultiChar + 1)
+	if length == 1 {
+		minGuesses = minSubmatchGuessesSingleChar + 1
+	}
+
+	return match{
+		kind:    kindBruteforce,
+		i:       i,
+		j:       j,
+		token:   string(chars[i : j+1]),
+		guesses: max(guesses, minGuesses),
+	}
+}
+
+// feedback explains the weakest part of a password, judged by its longest
+// match.
+func feedback(sequence []match) (string, []string) {
+	suggestions := []string{"Add another word or two. Uncommon words are better."}
+
+	var longest match
+	found := false
+	for _, m := range sequence {
+		if m.kind == kindBruteforce {
+			continue
+		}
+		if !found || m.j-m.i > longest.j-longest.i {
+			longest = m
+			found = true
+		}
+	}
+
+	if !found {
+		return "", suggestions
+	}
+
+	switch longest.kind {
+	case kindUserInput:
+		return "Avoid your email address and the name of this site", suggestions
+	case kindDictionary:
+		warning := "This is similar to a commonly used password"
+		if len(sequence) == 1 && !longest.l33t && !longest.reversed {
+			switch {
+			case longest.rank <= 10:
+				warning = "This is a top-10 common password"
+			case longest.rank <= 100:
+				warning = "This is a top-100 common password"
+			default:
+				warning = "This is a very common password"
+			}
+		}
+
+		if uppercaseVariations(longest.token) > 1 {
+			suggestions = append(suggestions, "Capitalization doesn't help very much")
+		}
+		if longest.reversed {
+			suggestions = append(suggestions, "Reversed words aren't much harder to guess")
+		}
+		if longest.l33t {
+			suggestions = append(suggestions, "Predictable substitutions like '@' instead of 'a' don't help very much")
+		}
+
+		return warning, suggestions
+	case kindSequence:
+		return "Sequences like abc or 6543 are easy to guess", suggestions
+	case kindRepeat:
+		if longest.baseLen == 1 {
+			return `Repeats like "aaa" are easy to guess`, suggestions
+		}
+		return `Repeats like "abcabcabc" are only slightly harder to guess than "abc"`, suggestions
+	case kindKeyboard:
+		return "Straight rows of keys are easy to guess", suggestions
+	case kindYear:
+		return "Recent years are easy to guess", append(suggestions, "Avoid years that are associated with you")
+	default:
+		return "", suggestions
+	}
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+
+	return string(runes)
+}
+
+func factorial(n int) float64 {
+	result := 1.0
+	for i := 2; i <= n; i++ {
+		result *= float64(i)
+	}
+
+	return result
+}
+
+func binomial(n, k int) float64 {
+	if k > n {
+		return 0
+	}
+
+	result := 1.0
+	for i := 1; i <= k; i++ {
+		result = result * float64(n-k+i) / float64(i)
+	}
+
+	return result
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
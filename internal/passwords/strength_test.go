@@ -0,0 +1,224 @@
+package passwords
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		password string
+		score    int
+		warning  string
+	}{
+		{"", 0, "Enter a password"},
+		{"password", 0, "top-10 common password"},
+		{"qwertyuiop", 0, "top-100 common password"},
+		{"p@ssw0rd", 0, ""},
+		{"drowssap", 0, ""},
+		{"abcdefgh", 0, "Sequences like abc or 6543"},
+		{"aaaaaaaa", 0, `Repeats like "aaa"`},
+		{"abcabcabc", 0, `Repeats like "abcabcabc"`},
+		{"1987", 0, "Recent years"},
+		{"zxcvbnm1", 1, ""},
+		{"jane.doe2024", 3, ""},
+		{"Tr0ub4dor&3", 4, ""},
+		{"correct horse battery staple", 4, ""},
+		{"kX9#mQ2$vL7!", 4, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			got := Estimate(tt.password, "jane.doe@example.com", "mbvlabs")
+			if got.Score != tt.score {
+				t.Errorf("score = %d, want %d (guesses %g)", got.Score, tt.score, got.Guesses)
+			}
+			if !strings.Contains(got.Warning, tt.warning) {
+				t.Errorf("warning = %q, want it to contain %q", got.Warning, tt.warning)
+			}
+			if got.Score > 2 && (got.Warning != "" || len(got.Suggestions) > 0) {
+				t.Errorf("feedback given for a strong password: %q %q", got.Warning, got.Suggestions)
+			}
+		})
+	}
+}
+
+func TestEstimateSuggestions(t *testing.T) {
+	tests := map[string]string{
+		"p@ssw0rd": "Predictable substitutions",
+		"drowssap": "Reversed words",
+	}
+
+	for password, want := range tests {
+		got := Estimate(password)
+		if !slices.ContainsFunc(got.Suggestions, func(s string) bool {
+			return strings.Contains(s, want)
+		}) {
+			t.Errorf("Estimate(%q).Suggestions = %q, want one containing %q", password, got.Suggestions, want)
+		}
+	}
+}
+
+func TestEstimateUserInputs(t *testing.T) {
+	without := Estimate("janedoe")
+	with := Estimate("janedoe", "jane.doe@example.com")
+	if with.Guesses >= without.Guesses {
+		t.Errorf("guesses with user inputs = %g, want fewer than %g", with.Guesses, without.Guesses)
+	}
+}
+
+func TestScoreFor(t *testing.T) {
+	tests := map[float64]int{
+		1:    0,
+		1e3:  0,
+		1e4:  1,
+		1e7:  2,
+		1e9:  3,
+		1e11: 4,
+	}
+
+	for guesses, want := range tests {
+		if got := scoreFor(guesses); got != want {
+			t.Errorf("scoreFor(%g) = %d, want %d", guesses, got, want)
+		}
+	}
+}
+
+func TestUppercaseVariations(t *testing.T) {
+	tests := map[string]float64{
+		"password": 1,
+		"Password": 2,
+		"passworD": 2,
+		"PASSWORD": 2,
+		"1234":     1,
+		"PaSsword": binomial(8, 1) + binomial(8, 2),
+	}
+
+	for token, want := range tests {
+		if got := uppercaseVariations(token); got != want {
+			t.Errorf("uppercaseVariations(%q) = %g, want %g", token, got, want)
+		}
+	}
+}
+
+func TestUnl33t(t *testing.T) {
+	if got := unl33t([]rune("password")); got != nil {
+		t.Errorf("unl33t(password) = %q, want nil", got)
+	}
+
+	got := unl33t([]rune("p@ssw0rd"))
+	if !slices.Equal(got, []string{"password"}) {
+		t.Errorf("unl33t(p@ssw0rd) = %q, want [password]", got)
+	}
+
+	got = unl33t([]rune("1ove"))
+	slices.Sort(got)
+	if !slices.Equal(got, []string{"iove", "love"}) {
+		t.Errorf("unl33t(1ove) = %q, want [iove love]", got)
+	}
+
+	if got := unl33t([]rune("11111")); got != nil {
+		t.Errorf("unl33t(11111) = %d variants, want nil past the limit", len(got))
+	}
+}
+
+func TestIsPrimitive(t *testing.T) {
+	tests := map[string]bool{
+		"a":      true,
+		"ab":     true,
+		"aa":     false,
+		"abab":   false,
+		"abcabd": true,
+		"abcabc": false,
+	}
+
+	for unit, want := range tests {
+		if got := isPrimitive([]rune(unit)); got != want {
+			t.Errorf("isPrimitive(%q) = %v, want %v", unit, got, want)
+		}
+	}
+}
+
+func TestOnKeyboardRow(t *testing.T) {
+	tests := map[string]bool{
+		"qwer":  true,
+		"rewq":  true,
+		"asdf":  true,
+		"7890":  true,
+		"qwas":  false,
+		"hello": false,
+	}
+
+	for token, want := range tests {
+		if got := onKeyboardRow(token); got != want {
+			t.Errorf("onKeyboardRow(%q) = %v, want %v", token, got, want)
+		}
+	}
+}
+
+func TestYearMatches(t *testing.T) {
+	matches := yearMatches([]rune("ab1987cd2100"))
+	if len(matches) != 1 {
+		t.Fatalf("yearMatches = %d matches, want 1", len(matches))
+	}
+	if m := matches[0]; m.token != "1987" || m.i != 2 || m.j != 5 {
+		t.Errorf("yearMatches = %q at %d-%d, want 1987 at 2-5", m.token, m.i, m.j)
+	}
+}
+
+func TestSequenceMatches(t *testing.T) {
+	tests := map[string]string{
+		"xxabcdxx": "abcd",
+		"9876":     "9876",
+		"aXYZa":    "XYZ",
+	}
+
+	for password, want := range tests {
+		matches := sequenceMatches([]rune(password))
+		if !slices.ContainsFunc(matches, func(m match) bool { return m.token == want }) {
+			t.Errorf("sequenceMatches(%q) did not find %q", password, want)
+		}
+	}
+
+	if matches := sequenceMatches([]rune("acegi")); len(matches) != 0 {
+		t.Errorf("sequenceMatches(acegi) = %d matches, want 0", len(matches))
+	}
+}
+
+func TestMathHelpers(t *testing.T) {
+	if got := factorial(5); got != 120 {
+		t.Errorf("factorial(5) = %g, want 120", got)
+	}
+	if got := binomial(5, 2); got != 10 {
+		t.Errorf("binomial(5, 2) = %g, want 10", got)
+	}
+	if got := binomial(2, 5); got != 0 {
+		t.Errorf("binomial(2, 5) = %g, want 0", got)
+	}
+	if got := reverse("abc"); got != "cba" {
+		t.Errorf("reverse(abc) = %q, want cba", got)
+	}
+	if got := abs(-3); got != 3 {
+		t.Errorf("abs(-3) = %d, want 3", got)
+	}
+}
+
+// BenchmarkEstimateWorstCase covers the inputs that produce the most
+// overlapping matches at the longest length the strength endpoint accepts.
+func BenchmarkEstimateWorstCase(b *testing.B) {
+	inputs := map[string]string{
+		"repeat":   strings.Repeat("ab1!", 18),
+		"sequence": strings.Repeat("abcdefghijklmnopqrstuvwxyz", 3)[:72],
+		"keyboard": strings.Repeat("qwertyuiop", 8)[:72],
+		"l33t":     strings.Repeat("p@55w0rd", 9),
+	}
+
+	for name, password := range inputs {
+		b.Run(name, func(b *testing.B) {
+			for b.Loop() {
+				Estimate(password, "jane.doe@example.com", "mbvlabs")
+			}
+		})
+	}
+}
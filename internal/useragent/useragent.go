@@ -0,0 +1,117 @@
+// Package useragent turns a User-Agent header into the few facts a person
+// needs to recognise one of their devices. It only knows the common
+// browsers and platforms; anything else is reported as unknown rather than
+// guessed.
+package useragent
+
+import (
+	"strings"
+)
+
+type DeviceType string
+
+const (
+	Desktop DeviceType = "desktop"
+	Mobile  DeviceType = "mobile"
+	Tablet  DeviceType = "tablet"
+	Unknown DeviceType = "unknown"
+)
+
+// Agent is a parsed User-Agent header.
+type Agent struct {
+	Browser string
+	OS      string
+	Device  DeviceType
+}
+
+// String describes the agent as, for example, "Firefox on macOS".
+func (a Agent) String() string {
+	switch {
+	case a.Browser != "" && a.OS != "":
+		return a.Browser + " on " + a.OS
+	case a.Browser != "":
+		return a.Browser
+	case a.OS != "":
+		return a.OS
+	default:
+		return "Unknown device"
+	}
+}
+
+// browsers is checked in order. Most browsers also claim to be Chrome or
+// Safari, so the more specific tokens must come first.
+var browsers = []struct {
+	token string
+	name  string
+}{
+	{"Edg/", "Edge"},
+	{"EdgA/", "Edge"},
+	{"EdgiOS/", "Edge"},
+	{"OPR/", "Opera"},
+	{"SamsungBrowser/", "Samsung Internet"},
+	{"Vivaldi/", "Vivaldi"},
+	{"Firefox/", "Firefox"},
+	{"FxiOS/", "Firefox"},
+	{"CriOS/", "Chrome"},
+	{"Chrome/", "Chrome"},
+	{"Chromium/", "Chromium"},
+	{"Safari/", "Safari"},
+	{"curl/", "curl"},
+}
+
+// Parse reads header. It never fails; unknown parts are left empty.
+func Parse(header string) Agent {
+	agent := Agent{
+		Browser: parseBrowser(header),
+		OS:      parseOS(header),
+	}
+	agent.Device = parseDevice(header, agent.OS)
+
+	return agent
+}
+
+func parseBrowser(header string) string {
+	for _, browser := range browsers {
+		if strings.Contains(header, browser.token) {
+			return browser.name
+		}
+	}
+
+	return ""
+}
+
+func parseOS(header string) string {
+	switch {
+	case strings.Contains(header, "iPhone"), strings.Contains(header, "iPod"):
+		return "iOS"
+	case strings.Contains(header, "iPad"):
+		return "iPadOS"
+	case strings.Contains(header, "Android"):
+		return "Android"
+	case strings.Contains(header, "CrOS"):
+		return "ChromeOS"
+	case strings.Contains(header, "Windows"):
+		return "Windows"
+	case strings.Contains(header, "Mac OS X"), strings.Contains(header, "Macintosh"):
+		return "macOS"
+	case strings.Contains(header, "Linux"):
+		return "Linux"
+	default:
+		return ""
+	}
+}
+
+func parseDevice(header string, os string) DeviceType {
+	switch {
+	case os == "iPadOS", strings.Contains(header, "Tablet"):
+		return Tablet
+	case os == "Android" && !strings.Contains(header, "Mobile"):
+		return Tablet
+	case os == "iOS", strings.Contains(header, "Mobi"):
+		return Mobile
+	case os != "":
+		return Desktop
+	default:
+		return Unknown
+	}
+}
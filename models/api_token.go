@@ -0,0 +1,170 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models/internal/db"
+)
+
+const (
+	APIScopeRead  = "read"
+	APIScopeWrite = "write"
+
+	// apiTokenPrefix marks personal access tokens so they are easy to spot
+	// in logs and by secret scanners.
+	apiTokenPrefix = "mbv_"
+)
+
+var APIScopes = []string{APIScopeRead, APIScopeWrite}
+
+// APIToken is a personal access token for the JSON API. Only an HMAC of the
+// secret is stored; the prefix is kept so users can tell tokens apart.
+type APIToken struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	Prefix     string
+	Hash       string
+	Scopes     []string
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+}
+
+func (t APIToken) IsExpired() bool {
+	return !t.ExpiresAt.IsZero() && time.Now().After(t.ExpiresAt)
+}
+
+func (t APIToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+func FindAPITokenBySecret(
+	ctx context.Context,
+	exec storage.Executor,
+	pepper string,
+	secret string,
+) (APIToken, error) {
+	row, err := findByStorageHash(secret, pepper, func(hash string) (db.ApiToken, error) {
+		return queries.QueryAPITokenByHash(ctx, exec, hash)
+	})
+	if err != nil {
+		return APIToken{}, err
+	}
+
+	return rowToAPIToken(row), nil
+}
+
+func FindAPITokensByUserID(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+) ([]APIToken, error) {
+	rows, err := queries.QueryAPITokensByUserID(ctx, exec, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([]APIToken, len(rows))
+	for i, row := range rows {
+		tokens[i] = rowToAPIToken(row)
+	}
+
+	return tokens, nil
+}
+
+type CreateAPITokenData struct {
+	UserID    uuid.UUID `validate:"required"`
+	Name      string    `validate:"required,max=100"`
+	Scopes    []string  `validate:"required,min=1,dive,oneof=read write"`
+	ExpiresAt time.Time
+}
+
+// CreateAPIToken stores a new token and returns it with its secret. The
+// secret cannot be recovered later.
+func CreateAPIToken(
+	ctx context.Context,
+	exec storage.Executor,
+	pepper string,
+	data CreateAPITokenData,
+) (APIToken, string, error) {
+	if err := validate.Struct(data); err != nil {
+		return APIToken{}, "", errors.Join(ErrDomainValidation, err)
+	}
+
+	secret, err := GenerateSecureToken()
+	if err != nil {
+		return APIToken{}, "", err
+	}
+	secret = apiTokenPrefix + secret
+
+	row, err := queries.InsertAPIToken(ctx, exec, db.InsertAPITokenParams{
+		ID:     uuid.New(),
+		UserID: data.UserID,
+		Name:   data.Name,
+		Prefix: secret[:len(apiTokenPrefix)+6],
+		Hash:   HashForStorage(secret, pepper),
+		Scopes: data.Scopes,
+		ExpiresAt: pgtype.Timestamptz{
+			Time:  data.ExpiresAt,
+			Valid: !data.ExpiresAt.IsZero(),
+		},
+	})
+	if err != nil {
+		return APIToken{}, "", err
+	}
+
+	return rowToAPIToken(row), secret, nil
+}
+
+func TouchAPIToken(
+	ctx context.Context,
+	exec storage.Executor,
+	id uuid.UUID,
+) error {
+	return queries.UpdateAPITokenLastUsedAt(ctx, exec, id)
+}
+
+func DestroyAPIToken(
+	ctx context.Context,
+	exec storage.Executor,
+	id uuid.UUID,
+	userID uuid.UUID,
+) error {
+	return queries.DeleteAPITokenByIDAndUserID(ctx, exec, db.DeleteAPITokenByIDAndUserIDParams{
+		ID:     id,
+		UserID: userID,
+	})
+}
+
+// DestroyAPITokensByUserID revokes every API token the user holds.
+func DestroyAPITokensByUserID(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+) error {
+	return queries.DeleteAPITokensByUserID(ctx, exec, userID)
+}
+
+func rowToAPIToken(row db.ApiToken) APIToken {
+	return APIToken{
+		ID:         row.ID,
+		CreatedAt:  row.CreatedAt.Time,
+		UpdatedAt:  row.UpdatedAt.Time,
+		UserID:     row.UserID,
+		Name:       row.Name,
+		Prefix:     row.Prefix,
+		Hash:       row.Hash,
+		Scopes:     row.Scopes,
+		LastUsedAt: row.LastUsedAt.Time,
+		ExpiresAt:  row.ExpiresAt.Time,
+	}
+}
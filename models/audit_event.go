@@ -0,0 +1,251 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models/internal/db"
+)
+
+// AuditEvent records who did what to whom. Actor and subject are plain ids
+// rather than foreign keys so the trail outlives deleted accounts.
+type AuditEvent struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	ActorID   uuid.UUID
+	SubjectID uuid.UUID
+	Action    string
+	IP        string
+	UserAgent string
+	TraceID   string
+	Details   map[string]any
+}
+
+type CreateAuditEventData struct {
+	ActorID   uuid.UUID
+	SubjectID uuid.UUID
+	Action    string `validate:"required,max=100"`
+	IP        string `validate:"max=64"`
+	UserAgent string `validate:"max=512"`
+	TraceID   string `validate:"max=32"`
+	Details   map[string]any
+}
+
+func CreateAuditEvent(
+	ctx context.Context,
+	exec storage.Executor,
+	data CreateAuditEventData,
+) (AuditEvent, error) {
+	if err := validate.Struct(data); err != nil {
+		return AuditEvent{}, errors.Join(ErrDomainValidation, err)
+	}
+
+	details := data.Details
+	if details == nil {
+		details = map[string]any{}
+	}
+
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return AuditEvent{}, err
+	}
+
+	row, err := queries.InsertAuditEvent(ctx, exec, db.InsertAuditEventParams{
+		ID: uuid.New(),
+		ActorID: pgtype.UUID{
+			Bytes: data.ActorID,
+			Valid: data.ActorID != uuid.Nil,
+		},
+		SubjectID: pgtype.UUID{
+			Bytes: data.SubjectID,
+			Valid: data.SubjectID != uuid.Nil,
+		},
+		Action:    data.Action,
+		Ip:        data.IP,
+		UserAgent: data.UserAgent,
+		TraceID:   data.TraceID,
+		Details:   encoded,
+	})
+	if err != nil {
+		return AuditEvent{}, err
+	}
+
+	return rowToAuditEvent(row)
+}
+
+// AuditEventFilter narrows a listing of audit events. Zero values match
+// everything; Action matches as a prefix, so "admin." selects all admin
+// actions.
+type AuditEventFilter struct {
+	ActorID   uuid.UUID
+	SubjectID uuid.UUID
+	Action    string
+	Since     time.Time
+	Until     time.Time
+}
+
+func (f AuditEventFilter) countParams() db.CountAuditEventsParams {
+	return db.CountAuditEventsParams{
+		ActorID: pgtype.UUID{
+			Bytes: f.ActorID,
+			Valid: f.ActorID != uuid.Nil,
+		},
+		SubjectID: pgtype.UUID{
+			Bytes: f.SubjectID,
+			Valid: f.SubjectID != uuid.Nil,
+		},
+		Action: f.Action,
+		Since: pgtype.Timestamptz{
+			Time:  f.Since,
+			Valid: !f.Since.IsZero(),
+		},
+		Until: pgtype.Timestamptz{
+			Time:  f.Until,
+			Valid: !f.Until.IsZero(),
+		},
+	}
+}
+
+// FindAuditEvents returns matching events, newest first.
+func FindAuditEvents(
+	ctx context.Context,
+	exec storage.Executor,
+	filter AuditEventFilter,
+	limit int64,
+	offset int64,
+) ([]AuditEvent, error) {
+	params := filter.countParams()
+
+	rows, err := queries.QueryAuditEvents(ctx, exec, db.QueryAuditEventsParams{
+		ActorID:   params.ActorID,
+		SubjectID: params.SubjectID,
+		Action:    params.Action,
+		Since:     params.Since,
+		Until:     params.Until,
+		Limit:     limit,
+		Offset:    offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]AuditEvent, len(rows))
+	for i, row := range rows {
+		event, err := rowToAuditEvent(row)
+		if err != nil {
+			return nil, err
+		}
+		events[i] = event
+	}
+
+	return events, nil
+}
+
+// FindAuditEventsByUserID returns every event where the user is the actor or
+// the subject, oldest first.
+func FindAuditEventsByUserID(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+) ([]AuditEvent, error) {
+	rows, err := queries.QueryAuditEventsByUserID(ctx, exec, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]AuditEvent, len(rows))
+	for i, row := range rows {
+		event, err := rowToAuditEvent(row)
+		if err != nil {
+			return nil, err
+		}
+		events[i] = event
+	}
+
+	return events, nil
+}
+
+// RedactAuditEventsByUser blanks the IP, user agent and details of every
+// event involving the user, including those that only name the address,
+// such as failed sign-ins and lockouts. The ids, action and time are kept,
+// which is the only change the append-only trigger allows.
+func RedactAuditEventsByUser(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+	email string,
+) (int64, error) {
+	return queries.RedactAuditEventsByUser(ctx, exec, db.RedactAuditEventsByUserParams{
+		UserID: userID,
+		Email:  email,
+	})
+}
+
+type PaginatedAuditEvents struct {
+	Events     []AuditEvent
+	TotalCount int64
+	Page       int64
+	PageSize   int64
+	TotalPages int64
+}
+
+func PaginateAuditEvents(
+	ctx context.Context,
+	exec storage.Executor,
+	filter AuditEventFilter,
+	page int64,
+	pageSize int64,
+) (PaginatedAuditEvents, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	totalCount, err := queries.CountAuditEvents(ctx, exec, filter.countParams())
+	if err != nil {
+		return PaginatedAuditEvents{}, err
+	}
+
+	events, err := FindAuditEvents(ctx, exec, filter, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return PaginatedAuditEvents{}, err
+	}
+
+	return PaginatedAuditEvents{
+		Events:     events,
+		TotalCount: totalCount,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: (totalCount + pageSize - 1) / pageSize,
+	}, nil
+}
+
+func rowToAuditEvent(row db.AuditEvent) (AuditEvent, error) {
+	var details map[string]any
+	if err := json.Unmarshal(row.Details, &details); err != nil {
+		return AuditEvent{}, err
+	}
+
+	return AuditEvent{
+		ID:        row.ID,
+		CreatedAt: row.CreatedAt.Time,
+		ActorID:   uuid.UUID(row.ActorID.Bytes),
+		SubjectID: uuid.UUID(row.SubjectID.Bytes),
+		Action:    row.Action,
+		IP:        row.Ip,
+		UserAgent: row.UserAgent,
+		TraceID:   row.TraceID,
+		Details:   details,
+	}, nil
+}
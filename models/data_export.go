@@ -0,0 +1,102 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models/internal/db"
+)
+
+// DataExport is a ZIP archive of a user's personal data, downloadable with a
+// secret link until it expires. Only an HMAC of the secret is stored.
+type DataExport struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Hash      string
+	ExpiresAt time.Time
+	Archive   []byte
+}
+
+func (d DataExport) IsExpired() bool {
+	return time.Now().After(d.ExpiresAt)
+}
+
+func FindDataExportBySecret(
+	ctx context.Context,
+	exec storage.Executor,
+	pepper string,
+	secret string,
+) (DataExport, error) {
+	row, err := findByStorageHash(secret, pepper, func(hash string) (db.DataExport, error) {
+		return queries.QueryDataExportByHash(ctx, exec, hash)
+	})
+	if err != nil {
+		return DataExport{}, err
+	}
+
+	return rowToDataExport(row), nil
+}
+
+type CreateDataExportData struct {
+	UserID    uuid.UUID `validate:"required"`
+	ExpiresAt time.Time `validate:"required"`
+	Archive   []byte    `validate:"required"`
+}
+
+// CreateDataExport stores the archive and returns the secret for its
+// download link, which cannot be recovered later.
+func CreateDataExport(
+	ctx context.Context,
+	exec storage.Executor,
+	pepper string,
+	data CreateDataExportData,
+) (DataExport, string, error) {
+	if err := validate.Struct(data); err != nil {
+		return DataExport{}, "", errors.Join(ErrDomainValidation, err)
+	}
+
+	secret, err := GenerateSecureToken()
+	if err != nil {
+		return DataExport{}, "", err
+	}
+
+	row, err := queries.InsertDataExport(ctx, exec, db.InsertDataExportParams{
+		ID:     uuid.New(),
+		UserID: data.UserID,
+		Hash:   HashForStorage(secret, pepper),
+		ExpiresAt: pgtype.Timestamptz{
+			Time:  data.ExpiresAt,
+			Valid: true,
+		},
+		Archive: data.Archive,
+	})
+	if err != nil {
+		return DataExport{}, "", err
+	}
+
+	return rowToDataExport(row), secret, nil
+}
+
+func DestroyExpiredDataExports(
+	ctx context.Context,
+	exec storage.Executor,
+) (int64, error) {
+	return queries.DeleteExpiredDataExports(ctx, exec)
+}
+
+func rowToDataExport(row db.DataExport) DataExport {
+	return DataExport{
+		ID:        row.ID,
+		CreatedAt: row.CreatedAt.Time,
+		UserID:    row.UserID,
+		Hash:      row.Hash,
+		ExpiresAt: row.ExpiresAt.Time,
+		Archive:   row.Archive,
+	}
+}
@@ -0,0 +1,336 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models/internal/db"
+)
+
+type EmailMessageKind string
+
+const (
+	EmailMessageKindTransactional EmailMessageKind = "transactional"
+	EmailMessageKindMarketing     EmailMessageKind = "marketing"
+)
+
+type EmailMessageStatus string
+
+const (
+	// EmailMessageQueued is a message waiting for its first or next
+	// attempt.
+	EmailMessageQueued     EmailMessageStatus = "queued"
+	EmailMessageSent       EmailMessageStatus = "sent"
+	EmailMessageBounced    EmailMessageStatus = "bounced"
+	EmailMessageComplained EmailMessageStatus = "complained"
+	// EmailMessageFailed is a message no attempt will deliver.
+	EmailMessageFailed EmailMessageStatus = "failed"
+)
+
+var EmailMessageStatuses = []EmailMessageStatus{
+	EmailMessageQueued,
+	EmailMessageSent,
+	EmailMessageBounced,
+	EmailMessageComplained,
+	EmailMessageFailed,
+}
+
+func (s EmailMessageStatus) Valid() bool {
+	switch s {
+	case EmailMessageQueued, EmailMessageSent, EmailMessageBounced, EmailMessageComplained, EmailMessageFailed:
+		return true
+	default:
+		return false
+	}
+}
+
+// maxEmailMessageErrorLength bounds last_error; provider replies can carry
+// whole HTML error pages.
+const maxEmailMessageErrorLength = 2000
+
+// EmailMessage logs one message handed to a sender and what became of it.
+// The rendered bodies are kept with the message's secrets blanked out, but
+// still hold personal data, which is why reading them takes its own
+// permission.
+type EmailMessage struct {
+	ID                uuid.UUID
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	Kind              EmailMessageKind
+	Template          string
+	Sender            string
+	Recipients        []string
+	Subject           string
+	HTMLBody          string
+	TextBody          string
+	Status            EmailMessageStatus
+	Attempts          int32
+	LastError         string
+	ProviderMessageID string
+	// JobID is the River job that sends the message, zero if there is none.
+	JobID  int64
+	SentAt time.Time
+}
+
+// IsDelivered reports whether a sender has taken the message.
+func (e EmailMessage) IsDelivered() bool {
+	return !e.SentAt.IsZero()
+}
+
+func FindEmailMessage(
+	ctx context.Context,
+	exec storage.Executor,
+	id uuid.UUID,
+) (EmailMessage, error) {
+	row, err := queries.QueryEmailMessageByID(ctx, exec, id)
+	if err != nil {
+		return EmailMessage{}, err
+	}
+
+	return rowToEmailMessage(row), nil
+}
+
+// FindEmailMessageByProviderMessageID returns the latest message a
+// provider gave the id.
+func FindEmailMessageByProviderMessageID(
+	ctx context.Context,
+	exec storage.Executor,
+	providerMessageID string,
+) (EmailMessage, error) {
+	row, err := queries.QueryEmailMessageByProviderMessageID(ctx, exec, providerMessageID)
+	if err != nil {
+		return EmailMessage{}, err
+	}
+
+	return rowToEmailMessage(row), nil
+}
+
+type StartEmailMessageAttemptData struct {
+	JobID      int64
+	Kind       EmailMessageKind `validate:"required,oneof=transactional marketing"`
+	Template   string           `validate:"max=100"`
+	Sender     string
+	Recipients []string
+	Subject    string
+	HTMLBody   string
+	TextBody   string
+}
+
+// StartEmailMessageAttempt logs an attempt to send a message. The first
+// attempt of a job creates the message; later ones count another attempt
+// on it and return it as it is, so callers can tell an already delivered
+// message from one still to send.
+func StartEmailMessageAttempt(
+	ctx context.Context,
+	exec storage.Executor,
+	data StartEmailMessageAttemptData,
+) (EmailMessage, error) {
+	if err := validate.Struct(data); err != nil {
+		return EmailMessage{}, errors.Join(ErrDomainValidation, err)
+	}
+
+	recipients := data.Recipients
+	if recipients == nil {
+		recipients = []string{}
+	}
+
+	row, err := queries.UpsertEmailMessageAttempt(ctx, exec, db.UpsertEmailMessageAttemptParams{
+		ID:         uuid.New(),
+		Kind:       string(data.Kind),
+		Template:   data.Template,
+		Sender:     data.Sender,
+		Recipients: recipients,
+		Subject:    data.Subject,
+		HtmlBody:   data.HTMLBody,
+		TextBody:   data.TextBody,
+		JobID: pgtype.Int8{
+			Int64: data.JobID,
+			Valid: data.JobID != 0,
+		},
+	})
+	if err != nil {
+		return EmailMessage{}, err
+	}
+
+	return rowToEmailMessage(row), nil
+}
+
+// MarkEmailMessageSent returns sql.ErrNoRows if the message does not
+// exist.
+func MarkEmailMessageSent(
+	ctx context.Context,
+	exec storage.Executor,
+	id uuid.UUID,
+	providerMessageID string,
+) error {
+	rowsAffected, err := queries.UpdateEmailMessageSent(ctx, exec, db.UpdateEmailMessageSentParams{
+		ID:                id,
+		ProviderMessageID: providerMessageID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// RecordEmailMessageError stores why an attempt failed along with the
+// status it leaves the message in. It returns sql.ErrNoRows if the message
+// does not exist.
+func RecordEmailMessageError(
+	ctx context.Context,
+	exec storage.Executor,
+	id uuid.UUID,
+	status EmailMessageStatus,
+	lastError string,
+) error {
+	if !status.Valid() {
+		return ErrDomainValidation
+	}
+
+	if len(lastError) > maxEmailMessageErrorLength {
+		lastError = strings.ToValidUTF8(lastError[:maxEmailMessageErrorLength], "")
+	}
+
+	rowsAffected, err := queries.UpdateEmailMessageError(ctx, exec, db.UpdateEmailMessageErrorParams{
+		ID:        id,
+		Status:    string(status),
+		LastError: lastError,
+	})
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// EmailMessageFilter narrows a listing of email messages. Search matches
+// part of the subject or of any recipient; zero values match everything.
+type EmailMessageFilter struct {
+	Search string
+	Status EmailMessageStatus
+}
+
+type PaginatedEmailMessages struct {
+	Messages   []EmailMessage
+	TotalCount int64
+	Page       int64
+	PageSize   int64
+	TotalPages int64
+}
+
+// PaginateEmailMessages returns matching messages, newest first.
+func PaginateEmailMessages(
+	ctx context.Context,
+	exec storage.Executor,
+	filter EmailMessageFilter,
+	page int64,
+	pageSize int64,
+) (PaginatedEmailMessages, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	totalCount, err := queries.CountEmailMessages(ctx, exec, db.CountEmailMessagesParams{
+		Search: escapeLike(filter.Search),
+		Status: string(filter.Status),
+	})
+	if err != nil {
+		return PaginatedEmailMessages{}, err
+	}
+
+	rows, err := queries.QueryEmailMessages(ctx, exec, db.QueryEmailMessagesParams{
+		Search: escapeLike(filter.Search),
+		Status: string(filter.Status),
+		Limit:  pageSize,
+		Offset: (page - 1) * pageSize,
+	})
+	if err != nil {
+		return PaginatedEmailMessages{}, err
+	}
+
+	messages := make([]EmailMessage, len(rows))
+	for i, row := range rows {
+		messages[i] = rowToEmailMessage(row)
+	}
+
+	return PaginatedEmailMessages{
+		Messages:   messages,
+		TotalCount: totalCount,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: (totalCount + pageSize - 1) / pageSize,
+	}, nil
+}
+
+func rowToEmailMessage(row db.EmailMessage) EmailMessage {
+	return EmailMessage{
+		ID:                row.ID,
+		CreatedAt:         row.CreatedAt.Time,
+		UpdatedAt:         row.UpdatedAt.Time,
+		Kind:              EmailMessageKind(row.Kind),
+		Template:          row.Template,
+		Sender:            row.Sender,
+		Recipients:        row.Recipients,
+		Subject:           row.Subject,
+		HTMLBody:          row.HtmlBody,
+		TextBody:          row.TextBody,
+		Status:            EmailMessageStatus(row.Status),
+		Attempts:          row.Attempts,
+		LastError:         row.LastError,
+		ProviderMessageID: row.ProviderMessageID,
+		JobID:             row.JobID.Int64,
+		SentAt:            row.SentAt.Time,
+	}
+}
+
+// FindEmailMessagesByRecipient returns every logged message sent to the
+// address, newest first.
+func FindEmailMessagesByRecipient(
+	ctx context.Context,
+	exec storage.Executor,
+	email string,
+) ([]EmailMessage, error) {
+	rows, err := queries.QueryEmailMessagesByRecipient(ctx, exec, email)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]EmailMessage, len(rows))
+	for i, row := range rows {
+		messages[i] = rowToEmailMessage(row)
+	}
+
+	return messages, nil
+}
+
+// DestroyEmailMessagesByRecipient deletes every logged message sent to the
+// address.
+func DestroyEmailMessagesByRecipient(
+	ctx context.Context,
+	exec storage.Executor,
+	email string,
+) (int64, error) {
+	return queries.DeleteEmailMessagesByRecipient(ctx, exec, email)
+}
@@ -0,0 +1,202 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models/internal/db"
+)
+
+type EmailSuppressionReason string
+
+const (
+	// EmailSuppressionBounce is an address the receiving server rejected
+	// for good.
+	EmailSuppressionBounce EmailSuppressionReason = "bounce"
+	// EmailSuppressionComplaint is an address whose owner marked a message
+	// as spam.
+	EmailSuppressionComplaint EmailSuppressionReason = "complaint"
+)
+
+// EmailSuppression is an address no email is sent to.
+type EmailSuppression struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Email     string
+	Reason    EmailSuppressionReason
+	// Provider is the delivery provider that reported the address.
+	Provider string
+	Details  string
+	// EmailMessageID is the logged message the report was about, if it is
+	// known and still logged.
+	EmailMessageID uuid.UUID
+}
+
+func FindEmailSuppression(
+	ctx context.Context,
+	exec storage.Executor,
+	id uuid.UUID,
+) (EmailSuppression, error) {
+	row, err := queries.QueryEmailSuppressionByID(ctx, exec, id)
+	if err != nil {
+		return EmailSuppression{}, err
+	}
+
+	return rowToEmailSuppression(row), nil
+}
+
+// FindSuppressedEmails returns which of emails are suppressed, in lower
+// case.
+func FindSuppressedEmails(
+	ctx context.Context,
+	exec storage.Executor,
+	emails []string,
+) ([]string, error) {
+	if len(emails) == 0 {
+		return nil, nil
+	}
+
+	normalized := make([]string, len(emails))
+	for i, email := range emails {
+		normalized[i] = strings.ToLower(email)
+	}
+
+	return queries.QuerySuppressedEmails(ctx, exec, normalized)
+}
+
+type SuppressEmailData struct {
+	Email          string                 `validate:"required,email"`
+	Reason         EmailSuppressionReason `validate:"required,oneof=bounce complaint"`
+	Provider       string                 `validate:"required,max=50"`
+	Details        string
+	EmailMessageID uuid.UUID
+}
+
+// SuppressEmail stops email going to an address. Suppressing an address
+// again records the latest reason for it.
+func SuppressEmail(
+	ctx context.Context,
+	exec storage.Executor,
+	data SuppressEmailData,
+) (EmailSuppression, error) {
+	if err := validate.Struct(data); err != nil {
+		return EmailSuppression{}, errors.Join(ErrDomainValidation, err)
+	}
+
+	details := data.Details
+	if len(details) > maxEmailMessageErrorLength {
+		details = strings.ToValidUTF8(details[:maxEmailMessageErrorLength], "")
+	}
+
+	row, err := queries.UpsertEmailSuppression(ctx, exec, db.UpsertEmailSuppressionParams{
+		ID:       uuid.New(),
+		Email:    strings.ToLower(data.Email),
+		Reason:   string(data.Reason),
+		Provider: data.Provider,
+		Details:  details,
+		EmailMessageID: pgtype.UUID{
+			Bytes: data.EmailMessageID,
+			Valid: data.EmailMessageID != uuid.Nil,
+		},
+	})
+	if err != nil {
+		return EmailSuppression{}, err
+	}
+
+	return rowToEmailSuppression(row), nil
+}
+
+type PaginatedEmailSuppressions struct {
+	Suppressions []EmailSuppression
+	TotalCount   int64
+	Page         int64
+	PageSize     int64
+	TotalPages   int64
+}
+
+// PaginateEmailSuppressions returns suppressions whose address contains
+// search, newest first.
+func PaginateEmailSuppressions(
+	ctx context.Context,
+	exec storage.Executor,
+	search string,
+	page int64,
+	pageSize int64,
+) (PaginatedEmailSuppressions, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	totalCount, err := queries.CountEmailSuppressions(ctx, exec, escapeLike(search))
+	if err != nil {
+		return PaginatedEmailSuppressions{}, err
+	}
+
+	rows, err := queries.QueryEmailSuppressions(ctx, exec, db.QueryEmailSuppressionsParams{
+		Search: escapeLike(search),
+		Limit:  pageSize,
+		Offset: (page - 1) * pageSize,
+	})
+	if err != nil {
+		return PaginatedEmailSuppressions{}, err
+	}
+
+	suppressions := make([]EmailSuppression, len(rows))
+	for i, row := range rows {
+		suppressions[i] = rowToEmailSuppression(row)
+	}
+
+	return PaginatedEmailSuppressions{
+		Suppressions: suppressions,
+		TotalCount:   totalCount,
+		Page:         page,
+		PageSize:     pageSize,
+		TotalPages:   (totalCount + pageSize - 1) / pageSize,
+	}, nil
+}
+
+// DestroyEmailSuppression lifts a suppression. It returns sql.ErrNoRows if
+// the suppression does not exist.
+func DestroyEmailSuppression(
+	ctx context.Context,
+	exec storage.Executor,
+	id uuid.UUID,
+) error {
+	rowsAffected, err := queries.DeleteEmailSuppression(ctx, exec, id)
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func rowToEmailSuppression(row db.EmailSuppression) EmailSuppression {
+	return EmailSuppression{
+		ID:             row.ID,
+		CreatedAt:      row.CreatedAt.Time,
+		UpdatedAt:      row.UpdatedAt.Time,
+		Email:          row.Email,
+		Reason:         EmailSuppressionReason(row.Reason),
+		Provider:       row.Provider,
+		Details:        row.Details,
+		EmailMessageID: row.EmailMessageID.Bytes,
+	}
+}
@@ -2,4 +2,7 @@ package models
 
 import "errors"
 
-var ErrDomainValidation = errors.New("the provided payload failed validations")
+var (
+	ErrDomainValidation = errors.New("the provided payload failed validations")
+	ErrEmailTaken       = errors.New("email address is already taken")
+)
@@ -0,0 +1,84 @@
+package factories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+)
+
+// SessionFactory wraps models.Session and adds factory methods
+type SessionFactory struct {
+	models.Session // Embedded
+}
+
+// SessionOption is a functional option for configuring a SessionFactory
+type SessionOption func(*SessionFactory)
+
+// BuildSession creates a Session struct with default values and applies any provided options.
+// This creates an in-memory struct only - it does not persist to the database.
+//
+// Use CreateSession to build and save to the database in one step.
+func BuildSession(opts ...SessionOption) models.Session {
+	f := &SessionFactory{
+		Session: models.Session{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			UserID:    uuid.New(),
+			ExpiresAt: time.Now().Add(24 * time.Hour),
+		},
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f.Session
+}
+
+// CreateSession creates a Session in the database for the given user.
+// Default expiration is 24 hours from now.
+//
+// Example:
+//
+//	session, err := factories.CreateSession(ctx, db, user.ID)
+//	session, err := factories.CreateSession(ctx, db, user.ID, factories.WithSessionExpired())
+func CreateSession(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+	opts ...SessionOption,
+) (models.Session, error) {
+	f := &SessionFactory{
+		Session: models.Session{
+			UserID:    userID,
+			ExpiresAt: time.Now().Add(24 * time.Hour),
+		},
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return models.CreateSession(ctx, exec, models.CreateSessionData{
+		UserID:    f.UserID,
+		ExpiresAt: f.ExpiresAt,
+	})
+}
+
+// WithSessionExpiresAt sets the expiration time for the session
+func WithSessionExpiresAt(t time.Time) SessionOption {
+	return func(f *SessionFactory) {
+		f.ExpiresAt = t
+	}
+}
+
+// WithSessionExpired creates a session that has already expired
+func WithSessionExpired() SessionOption {
+	return WithSessionExpiresAt(time.Now().Add(-1 * time.Hour))
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models/internal/db"
+)
+
+// Identity links a user to an account at an external identity provider.
+type Identity struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Provider  string
+	Subject   string
+	Email     string
+}
+
+func FindIdentityByProviderAndSubject(
+	ctx context.Context,
+	exec storage.Executor,
+	provider string,
+	subject string,
+) (Identity, error) {
+	row, err := queries.QueryIdentityByProviderAndSubject(
+		ctx,
+		exec,
+		db.QueryIdentityByProviderAndSubjectParams{
+			Provider: provider,
+			Subject:  subject,
+		},
+	)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	return rowToIdentity(row)
+}
+
+func FindIdentitiesByUserID(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+) ([]Identity, error) {
+	rows, err := queries.QueryIdentitiesByUserID(ctx, exec, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	identities := make([]Identity, len(rows))
+	for i, row := range rows {
+		identity, err := rowToIdentity(row)
+		if err != nil {
+			return nil, err
+		}
+
+		identities[i] = identity
+	}
+
+	return identities, nil
+}
+
+type CreateIdentityData struct {
+	UserID   uuid.UUID `validate:"required"`
+	Provider string    `validate:"required,max=100"`
+	Subject  string    `validate:"required,max=255"`
+	Email    string    `validate:"omitempty,email,max=255"`
+}
+
+func CreateIdentity(
+	ctx context.Context,
+	exec storage.Executor,
+	data CreateIdentityData,
+) (Identity, error) {
+	if err := validate.Struct(data); err != nil {
+		return Identity{}, errors.Join(ErrDomainValidation, err)
+	}
+
+	row, err := queries.InsertIdentity(ctx, exec, db.InsertIdentityParams{
+		ID:       uuid.New(),
+		UserID:   data.UserID,
+		Provider: data.Provider,
+		Subject:  data.Subject,
+		Email:    strings.ToLower(data.Email),
+	})
+	if err != nil {
+		return Identity{}, err
+	}
+
+	return rowToIdentity(row)
+}
+
+func UpdateIdentityEmail(
+	ctx context.Context,
+	exec storage.Executor,
+	id uuid.UUID,
+	email string,
+) error {
+	return queries.UpdateIdentityEmail(ctx, exec, db.UpdateIdentityEmailParams{
+		ID:    id,
+		Email: strings.ToLower(email),
+	})
+}
+
+func rowToIdentity(row db.Identity) (Identity, error) {
+	return Identity{
+		ID:        row.ID,
+		CreatedAt: row.CreatedAt.Time,
+		UpdatedAt: row.UpdatedAt.Time,
+		UserID:    row.UserID,
+		Provider:  row.Provider,
+		Subject:   row.Subject,
+		Email:     row.Email,
+	}, nil
+}
@@ -0,0 +1,172 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: api_tokens.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const deleteAPITokenByIDAndUserID = `-- name: DeleteAPITokenByIDAndUserID :exec
+delete from api_tokens where id=$1 and user_id=$2
+`
+
+type DeleteAPITokenByIDAndUserIDParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+// DeleteAPITokenByIDAndUserID
+//
+//	delete from api_tokens where id=$1 and user_id=$2
+func (q *Queries) DeleteAPITokenByIDAndUserID(ctx context.Context, db DBTX, arg DeleteAPITokenByIDAndUserIDParams) error {
+	_, err := db.Exec(ctx, deleteAPITokenByIDAndUserID, arg.ID, arg.UserID)
+	return err
+}
+
+const deleteAPITokensByUserID = `-- name: DeleteAPITokensByUserID :exec
+delete from api_tokens where user_id=$1
+`
+
+// DeleteAPITokensByUserID
+//
+//	delete from api_tokens where user_id=$1
+func (q *Queries) DeleteAPITokensByUserID(ctx context.Context, db DBTX, userID uuid.UUID) error {
+	_, err := db.Exec(ctx, deleteAPITokensByUserID, userID)
+	return err
+}
+
+const insertAPIToken = `-- name: InsertAPIToken :one
+insert into
+    api_tokens (id, created_at, updated_at, user_id, name, prefix, hash, scopes, expires_at)
+values
+    ($1, now(), now(), $2, $3, $4, $5, $6, $7)
+returning id, created_at, updated_at, user_id, name, prefix, hash, scopes, last_used_at, expires_at
+`
+
+type InsertAPITokenParams struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Name      string
+	Prefix    string
+	Hash      string
+	Scopes    []string
+	ExpiresAt pgtype.Timestamptz
+}
+
+// InsertAPIToken
+//
+//	insert into
+//	    api_tokens (id, created_at, updated_at, user_id, name, prefix, hash, scopes, expires_at)
+//	values
+//	    ($1, now(), now(), $2, $3, $4, $5, $6, $7)
+//	returning id, created_at, updated_at, user_id, name, prefix, hash, scopes, last_used_at, expires_at
+func (q *Queries) InsertAPIToken(ctx context.Context, db DBTX, arg InsertAPITokenParams) (ApiToken, error) {
+	row := db.QueryRow(ctx, insertAPIToken,
+		arg.ID,
+		arg.UserID,
+		arg.Name,
+		arg.Prefix,
+		arg.Hash,
+		arg.Scopes,
+		arg.ExpiresAt,
+	)
+	var i ApiToken
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.Prefix,
+		&i.Hash,
+		&i.Scopes,
+		&i.LastUsedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const queryAPITokenByHash = `-- name: QueryAPITokenByHash :one
+select id, created_at, updated_at, user_id, name, prefix, hash, scopes, last_used_at, expires_at from api_tokens where hash=$1
+`
+
+// QueryAPITokenByHash
+//
+//	select id, created_at, updated_at, user_id, name, prefix, hash, scopes, last_used_at, expires_at from api_tokens where hash=$1
+func (q *Queries) QueryAPITokenByHash(ctx context.Context, db DBTX, hash string) (ApiToken, error) {
+	row := db.QueryRow(ctx, queryAPITokenByHash, hash)
+	var i ApiToken
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.Prefix,
+		&i.Hash,
+		&i.Scopes,
+		&i.LastUsedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const queryAPITokensByUserID = `-- name: QueryAPITokensByUserID :many
+select id, created_at, updated_at, user_id, name, prefix, hash, scopes, last_used_at, expires_at from api_tokens where user_id=$1 order by created_at desc
+`
+
+// QueryAPITokensByUserID
+//
+//	select id, created_at, updated_at, user_id, name, prefix, hash, scopes, last_used_at, expires_at from api_tokens where user_id=$1 order by created_at desc
+func (q *Queries) QueryAPITokensByUserID(ctx context.Context, db DBTX, userID uuid.UUID) ([]ApiToken, error) {
+	rows, err := db.Query(ctx, queryAPITokensByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ApiToken
+	for rows.Next() {
+		var i ApiToken
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Name,
+			&i.Prefix,
+			&i.Hash,
+			&i.Scopes,
+			&i.LastUsedAt,
+			&i.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateAPITokenLastUsedAt = `-- name: UpdateAPITokenLastUsedAt :exec
+update api_tokens
+    set last_used_at=now()
+where id = $1
+`
+
+// UpdateAPITokenLastUsedAt
+//
+//	update api_tokens
+//	    set last_used_at=now()
+//	where id = $1
+func (q *Queries) UpdateAPITokenLastUsedAt(ctx context.Context, db DBTX, id uuid.UUID) error {
+	_, err := db.Exec(ctx, updateAPITokenLastUsedAt, id)
+	return err
+}
@@ -0,0 +1,243 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: audit_events.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const countAuditEvents = `-- name: CountAuditEvents :one
+select count(*) from audit_events
+where ($1::uuid is null or actor_id = $1::uuid)
+    and ($2::uuid is null or subject_id = $2::uuid)
+    and ($3::text = '' or action like $3::text || '%')
+    and ($4::timestamptz is null or created_at >= $4::timestamptz)
+    and ($5::timestamptz is null or created_at < $5::timestamptz)
+`
+
+type CountAuditEventsParams struct {
+	ActorID   pgtype.UUID
+	SubjectID pgtype.UUID
+	Action    string
+	Since     pgtype.Timestamptz
+	Until     pgtype.Timestamptz
+}
+
+// CountAuditEvents
+//
+//	select count(*) from audit_events
+//	where ($1::uuid is null or actor_id = $1::uuid)
+//	    and ($2::uuid is null or subject_id = $2::uuid)
+//	    and ($3::text = '' or action like $3::text || '%')
+//	    and ($4::timestamptz is null or created_at >= $4::timestamptz)
+//	    and ($5::timestamptz is null or created_at < $5::timestamptz)
+func (q *Queries) CountAuditEvents(ctx context.Context, db DBTX, arg CountAuditEventsParams) (int64, error) {
+	row := db.QueryRow(ctx, countAuditEvents,
+		arg.ActorID,
+		arg.SubjectID,
+		arg.Action,
+		arg.Since,
+		arg.Until,
+	)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const insertAuditEvent = `-- name: InsertAuditEvent :one
+insert into
+    audit_events (id, created_at, actor_id, subject_id, action, ip, user_agent, trace_id, details)
+values
+    ($1, now(), $2, $3, $4, $5, $6, $7, $8)
+returning id, created_at, actor_id, subject_id, action, details, ip, user_agent, trace_id
+`
+
+type InsertAuditEventParams struct {
+	ID        uuid.UUID
+	ActorID   pgtype.UUID
+	SubjectID pgtype.UUID
+	Action    string
+	Ip        string
+	UserAgent string
+	TraceID   string
+	Details   []byte
+}
+
+// InsertAuditEvent
+//
+//	insert into
+//	    audit_events (id, created_at, actor_id, subject_id, action, ip, user_agent, trace_id, details)
+//	values
+//	    ($1, now(), $2, $3, $4, $5, $6, $7, $8)
+//	returning id, created_at, actor_id, subject_id, action, details, ip, user_agent, trace_id
+func (q *Queries) InsertAuditEvent(ctx context.Context, db DBTX, arg InsertAuditEventParams) (AuditEvent, error) {
+	row := db.QueryRow(ctx, insertAuditEvent,
+		arg.ID,
+		arg.ActorID,
+		arg.SubjectID,
+		arg.Action,
+		arg.Ip,
+		arg.UserAgent,
+		arg.TraceID,
+		arg.Details,
+	)
+	var i AuditEvent
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.ActorID,
+		&i.SubjectID,
+		&i.Action,
+		&i.Details,
+		&i.Ip,
+		&i.UserAgent,
+		&i.TraceID,
+	)
+	return i, err
+}
+
+const queryAuditEvents = `-- name: QueryAuditEvents :many
+select id, created_at, actor_id, subject_id, action, details, ip, user_agent, trace_id from audit_events
+where ($1::uuid is null or actor_id = $1::uuid)
+    and ($2::uuid is null or subject_id = $2::uuid)
+    and ($3::text = '' or action like $3::text || '%')
+    and ($4::timestamptz is null or created_at >= $4::timestamptz)
+    and ($5::timestamptz is null or created_at < $5::timestamptz)
+order by created_at desc
+limit $7::bigint offset $6::bigint
+`
+
+type QueryAuditEventsParams struct {
+	ActorID   pgtype.UUID
+	SubjectID pgtype.UUID
+	Action    string
+	Since     pgtype.Timestamptz
+	Until     pgtype.Timestamptz
+	Offset    int64
+	Limit     int64
+}
+
+// QueryAuditEvents
+//
+//	select id, created_at, actor_id, subject_id, action, details, ip, user_agent, trace_id from audit_events
+//	where ($1::uuid is null or actor_id = $1::uuid)
+//	    and ($2::uuid is null or subject_id = $2::uuid)
+//	    and ($3::text = '' or action like $3::text || '%')
+//	    and ($4::timestamptz is null or created_at >= $4::timestamptz)
+//	    and ($5::timestamptz is null or created_at < $5::timestamptz)
+//	order by created_at desc
+//	limit $7::bigint offset $6::bigint
+func (q *Queries) QueryAuditEvents(ctx context.Context, db DBTX, arg QueryAuditEventsParams) ([]AuditEvent, error) {
+	rows, err := db.Query(ctx, queryAuditEvents,
+		arg.ActorID,
+		arg.SubjectID,
+		arg.Action,
+		arg.Since,
+		arg.Until,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditEvent
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.ActorID,
+			&i.SubjectID,
+			&i.Action,
+			&i.Details,
+			&i.Ip,
+			&i.UserAgent,
+			&i.TraceID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryAuditEventsByUserID = `-- name: QueryAuditEventsByUserID :many
+select id, created_at, actor_id, subject_id, action, details, ip, user_agent, trace_id from audit_events
+where actor_id = $1::uuid or subject_id = $1::uuid
+order by created_at
+`
+
+// QueryAuditEventsByUserID
+//
+//	select id, created_at, actor_id, subject_id, action, details, ip, user_agent, trace_id from audit_events
+//	where actor_id = $1::uuid or subject_id = $1::uuid
+//	order by created_at
+func (q *Queries) QueryAuditEventsByUserID(ctx context.Context, db DBTX, userID uuid.UUID) ([]AuditEvent, error) {
+	rows, err := db.Query(ctx, queryAuditEventsByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditEvent
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.ActorID,
+			&i.SubjectID,
+			&i.Action,
+			&i.Details,
+			&i.Ip,
+			&i.UserAgent,
+			&i.TraceID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const redactAuditEventsByUser = `-- name: RedactAuditEventsByUser :execrows
+update audit_events
+    set ip='', user_agent='', details='{}'
+where (actor_id = $1::uuid
+        or subject_id = $1::uuid
+        or lower(details->>'email') = lower($2::text))
+    and (ip <> '' or user_agent <> '' or details <> '{}'::jsonb)
+`
+
+type RedactAuditEventsByUserParams struct {
+	UserID uuid.UUID
+	Email  string
+}
+
+// RedactAuditEventsByUser
+//
+//	update audit_events
+//	    set ip='', user_agent='', details='{}'
+//	where (actor_id = $1::uuid
+//	        or subject_id = $1::uuid
+//	        or lower(details->>'email') = lower($2::text))
+//	    and (ip <> '' or user_agent <> '' or details <> '{}'::jsonb)
+func (q *Queries) RedactAuditEventsByUser(ctx context.Context, db DBTX, arg RedactAuditEventsByUserParams) (int64, error) {
+	result, err := db.Exec(ctx, redactAuditEventsByUser, arg.UserID, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
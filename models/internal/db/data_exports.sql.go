@@ -0,0 +1,92 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: data_exports.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const deleteExpiredDataExports = `-- name: DeleteExpiredDataExports :execrows
+delete from data_exports where expires_at <= now()
+`
+
+// DeleteExpiredDataExports
+//
+//	delete from data_exports where expires_at <= now()
+func (q *Queries) DeleteExpiredDataExports(ctx context.Context, db DBTX) (int64, error) {
+	result, err := db.Exec(ctx, deleteExpiredDataExports)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const insertDataExport = `-- name: InsertDataExport :one
+insert into
+    data_exports (id, created_at, user_id, hash, expires_at, archive)
+values
+    ($1, now(), $2, $3, $4, $5)
+returning id, created_at, user_id, hash, expires_at, archive
+`
+
+type InsertDataExportParams struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Hash      string
+	ExpiresAt pgtype.Timestamptz
+	Archive   []byte
+}
+
+// InsertDataExport
+//
+//	insert into
+//	    data_exports (id, created_at, user_id, hash, expires_at, archive)
+//	values
+//	    ($1, now(), $2, $3, $4, $5)
+//	returning id, created_at, user_id, hash, expires_at, archive
+func (q *Queries) InsertDataExport(ctx context.Context, db DBTX, arg InsertDataExportParams) (DataExport, error) {
+	row := db.QueryRow(ctx, insertDataExport,
+		arg.ID,
+		arg.UserID,
+		arg.Hash,
+		arg.ExpiresAt,
+		arg.Archive,
+	)
+	var i DataExport
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Hash,
+		&i.ExpiresAt,
+		&i.Archive,
+	)
+	return i, err
+}
+
+const queryDataExportByHash = `-- name: QueryDataExportByHash :one
+select id, created_at, user_id, hash, expires_at, archive from data_exports where hash=$1
+`
+
+// QueryDataExportByHash
+//
+//	select id, created_at, user_id, hash, expires_at, archive from data_exports where hash=$1
+func (q *Queries) QueryDataExportByHash(ctx context.Context, db DBTX, hash string) (DataExport, error) {
+	row := db.QueryRow(ctx, queryDataExportByHash, hash)
+	var i DataExport
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Hash,
+		&i.ExpiresAt,
+		&i.Archive,
+	)
+	return i, err
+}
@@ -0,0 +1,359 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: email_messages.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const countEmailMessages = `-- name: CountEmailMessages :one
+select count(*) from email_messages
+where ($1::text = ''
+        or subject ilike '%' || $1::text || '%'
+        or array_to_string(recipients, ' ') ilike '%' || $1::text || '%')
+    and ($2::text = '' or status = $2::text)
+`
+
+type CountEmailMessagesParams struct {
+	Search string
+	Status string
+}
+
+// CountEmailMessages
+//
+//	select count(*) from email_messages
+//	where ($1::text = ''
+//	        or subject ilike '%' || $1::text || '%'
+//	        or array_to_string(recipients, ' ') ilike '%' || $1::text || '%')
+//	    and ($2::text = '' or status = $2::text)
+func (q *Queries) CountEmailMessages(ctx context.Context, db DBTX, arg CountEmailMessagesParams) (int64, error) {
+	row := db.QueryRow(ctx, countEmailMessages, arg.Search, arg.Status)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deleteEmailMessagesByRecipient = `-- name: DeleteEmailMessagesByRecipient :execrows
+delete from email_messages where $1::text = any(recipients)
+`
+
+// DeleteEmailMessagesByRecipient
+//
+//	delete from email_messages where $1::text = any(recipients)
+func (q *Queries) DeleteEmailMessagesByRecipient(ctx context.Context, db DBTX, email string) (int64, error) {
+	result, err := db.Exec(ctx, deleteEmailMessagesByRecipient, email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const queryEmailMessageByID = `-- name: QueryEmailMessageByID :one
+select id, created_at, updated_at, kind, template, sender, recipients, subject, html_body, text_body, status, attempts, last_error, provider_message_id, job_id, sent_at from email_messages where id=$1
+`
+
+// QueryEmailMessageByID
+//
+//	select id, created_at, updated_at, kind, template, sender, recipients, subject, html_body, text_body, status, attempts, last_error, provider_message_id, job_id, sent_at from email_messages where id=$1
+func (q *Queries) QueryEmailMessageByID(ctx context.Context, db DBTX, id uuid.UUID) (EmailMessage, error) {
+	row := db.QueryRow(ctx, queryEmailMessageByID, id)
+	var i EmailMessage
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Kind,
+		&i.Template,
+		&i.Sender,
+		&i.Recipients,
+		&i.Subject,
+		&i.HtmlBody,
+		&i.TextBody,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.ProviderMessageID,
+		&i.JobID,
+		&i.SentAt,
+	)
+	return i, err
+}
+
+const queryEmailMessageByProviderMessageID = `-- name: QueryEmailMessageByProviderMessageID :one
+select id, created_at, updated_at, kind, template, sender, recipients, subject, html_body, text_body, status, attempts, last_error, provider_message_id, job_id, sent_at from email_messages
+where provider_message_id=$1
+order by created_at desc
+limit 1
+`
+
+// QueryEmailMessageByProviderMessageID
+//
+//	select id, created_at, updated_at, kind, template, sender, recipients, subject, html_body, text_body, status, attempts, last_error, provider_message_id, job_id, sent_at from email_messages
+//	where provider_message_id=$1
+//	order by created_at desc
+//	limit 1
+func (q *Queries) QueryEmailMessageByProviderMessageID(ctx context.Context, db DBTX, providerMessageID string) (EmailMessage, error) {
+	row := db.QueryRow(ctx, queryEmailMessageByProviderMessageID, providerMessageID)
+	var i EmailMessage
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Kind,
+		&i.Template,
+		&i.Sender,
+		&i.Recipients,
+		&i.Subject,
+		&i.HtmlBody,
+		&i.TextBody,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.ProviderMessageID,
+		&i.JobID,
+		&i.SentAt,
+	)
+	return i, err
+}
+
+const queryEmailMessages = `-- name: QueryEmailMessages :many
+select id, created_at, updated_at, kind, template, sender, recipients, subject, html_body, text_body, status, attempts, last_error, provider_message_id, job_id, sent_at from email_messages
+where ($1::text = ''
+        or subject ilike '%' || $1::text || '%'
+        or array_to_string(recipients, ' ') ilike '%' || $1::text || '%')
+    and ($2::text = '' or status = $2::text)
+order by created_at desc
+limit $4::bigint offset $3::bigint
+`
+
+type QueryEmailMessagesParams struct {
+	Search string
+	Status string
+	Offset int64
+	Limit  int64
+}
+
+// QueryEmailMessages
+//
+//	select id, created_at, updated_at, kind, template, sender, recipients, subject, html_body, text_body, status, attempts, last_error, provider_message_id, job_id, sent_at from email_messages
+//	where ($1::text = ''
+//	        or subject ilike '%' || $1::text || '%'
+//	        or array_to_string(recipients, ' ') ilike '%' || $1::text || '%')
+//	    and ($2::text = '' or status = $2::text)
+//	order by created_at desc
+//	limit $4::bigint offset $3::bigint
+func (q *Queries) QueryEmailMessages(ctx context.Context, db DBTX, arg QueryEmailMessagesParams) ([]EmailMessage, error) {
+	rows, err := db.Query(ctx, queryEmailMessages,
+		arg.Search,
+		arg.Status,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []EmailMessage
+	for rows.Next() {
+		var i EmailMessage
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Kind,
+			&i.Template,
+			&i.Sender,
+			&i.Recipients,
+			&i.Subject,
+			&i.HtmlBody,
+			&i.TextBody,
+			&i.Status,
+			&i.Attempts,
+			&i.LastError,
+			&i.ProviderMessageID,
+			&i.JobID,
+			&i.SentAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryEmailMessagesByRecipient = `-- name: QueryEmailMessagesByRecipient :many
+select id, created_at, updated_at, kind, template, sender, recipients, subject, html_body, text_body, status, attempts, last_error, provider_message_id, job_id, sent_at from email_messages
+where $1::text = any(recipients)
+order by created_at desc
+`
+
+// QueryEmailMessagesByRecipient
+//
+//	select id, created_at, updated_at, kind, template, sender, recipients, subject, html_body, text_body, status, attempts, last_error, provider_message_id, job_id, sent_at from email_messages
+//	where $1::text = any(recipients)
+//	order by created_at desc
+func (q *Queries) QueryEmailMessagesByRecipient(ctx context.Context, db DBTX, email string) ([]EmailMessage, error) {
+	rows, err := db.Query(ctx, queryEmailMessagesByRecipient, email)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []EmailMessage
+	for rows.Next() {
+		var i EmailMessage
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Kind,
+			&i.Template,
+			&i.Sender,
+			&i.Recipients,
+			&i.Subject,
+			&i.HtmlBody,
+			&i.TextBody,
+			&i.Status,
+			&i.Attempts,
+			&i.LastError,
+			&i.ProviderMessageID,
+			&i.JobID,
+			&i.SentAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateEmailMessageError = `-- name: UpdateEmailMessageError :execrows
+update email_messages
+    set updated_at=now(), status=$2, last_error=$3
+where id=$1
+`
+
+type UpdateEmailMessageErrorParams struct {
+	ID        uuid.UUID
+	Status    string
+	LastError string
+}
+
+// UpdateEmailMessageError
+//
+//	update email_messages
+//	    set updated_at=now(), status=$2, last_error=$3
+//	where id=$1
+func (q *Queries) UpdateEmailMessageError(ctx context.Context, db DBTX, arg UpdateEmailMessageErrorParams) (int64, error) {
+	result, err := db.Exec(ctx, updateEmailMessageError, arg.ID, arg.Status, arg.LastError)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateEmailMessageSent = `-- name: UpdateEmailMessageSent :execrows
+update email_messages
+    set updated_at=now(), status='sent', sent_at=now(), last_error='', provider_message_id=$2
+where id=$1
+`
+
+type UpdateEmailMessageSentParams struct {
+	ID                uuid.UUID
+	ProviderMessageID string
+}
+
+// UpdateEmailMessageSent
+//
+//	update email_messages
+//	    set updated_at=now(), status='sent', sent_at=now(), last_error='', provider_message_id=$2
+//	where id=$1
+func (q *Queries) UpdateEmailMessageSent(ctx context.Context, db DBTX, arg UpdateEmailMessageSentParams) (int64, error) {
+	result, err := db.Exec(ctx, updateEmailMessageSent, arg.ID, arg.ProviderMessageID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const upsertEmailMessageAttempt = `-- name: UpsertEmailMessageAttempt :one
+insert into
+    email_messages (id, created_at, updated_at, kind, template, sender, recipients, subject, html_body, text_body, status, attempts, job_id)
+values
+    ($1, now(), now(), $2, $3, $4, $5, $6, $7, $8, 'queued', 1, $9)
+on conflict (job_id) do update
+    set updated_at=now(),
+        attempts=email_messages.attempts + 1,
+        status=case when email_messages.status = 'failed' then 'queued' else email_messages.status end
+returning id, created_at, updated_at, kind, template, sender, recipients, subject, html_body, text_body, status, attempts, last_error, provider_message_id, job_id, sent_at
+`
+
+type UpsertEmailMessageAttemptParams struct {
+	ID         uuid.UUID
+	Kind       string
+	Template   string
+	Sender     string
+	Recipients []string
+	Subject    string
+	HtmlBody   string
+	TextBody   string
+	JobID      pgtype.Int8
+}
+
+// A job retrying a message counts another attempt on the same row. A
+// message that failed for good goes back in the queue if its job is run
+// again by hand.
+//
+//	insert into
+//	    email_messages (id, created_at, updated_at, kind, template, sender, recipients, subject, html_body, text_body, status, attempts, job_id)
+//	values
+//	    ($1, now(), now(), $2, $3, $4, $5, $6, $7, $8, 'queued', 1, $9)
+//	on conflict (job_id) do update
+//	    set updated_at=now(),
+//	        attempts=email_messages.attempts + 1,
+//	        status=case when email_messages.status = 'failed' then 'queued' else email_messages.status end
+//	returning id, created_at, updated_at, kind, template, sender, recipients, subject, html_body, text_body, status, attempts, last_error, provider_message_id, job_id, sent_at
+func (q *Queries) UpsertEmailMessageAttempt(ctx context.Context, db DBTX, arg UpsertEmailMessageAttemptParams) (EmailMessage, error) {
+	row := db.QueryRow(ctx, upsertEmailMessageAttempt,
+		arg.ID,
+		arg.Kind,
+		arg.Template,
+		arg.Sender,
+		arg.Recipients,
+		arg.Subject,
+		arg.HtmlBody,
+		arg.TextBody,
+		arg.JobID,
+	)
+	var i EmailMessage
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Kind,
+		&i.Template,
+		&i.Sender,
+		&i.Recipients,
+		&i.Subject,
+		&i.HtmlBody,
+		&i.TextBody,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.ProviderMessageID,
+		&i.JobID,
+		&i.SentAt,
+	)
+	return i, err
+}
@@ -0,0 +1,202 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: email_suppressions.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const countEmailSuppressions = `-- name: CountEmailSuppressions :one
+select count(*) from email_suppressions
+where $1::text = '' or email ilike '%' || $1::text || '%'
+`
+
+// CountEmailSuppressions
+//
+//	select count(*) from email_suppressions
+//	where $1::text = '' or email ilike '%' || $1::text || '%'
+func (q *Queries) CountEmailSuppressions(ctx context.Context, db DBTX, search string) (int64, error) {
+	row := db.QueryRow(ctx, countEmailSuppressions, search)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deleteEmailSuppression = `-- name: DeleteEmailSuppression :execrows
+delete from email_suppressions where id=$1
+`
+
+// DeleteEmailSuppression
+//
+//	delete from email_suppressions where id=$1
+func (q *Queries) DeleteEmailSuppression(ctx context.Context, db DBTX, id uuid.UUID) (int64, error) {
+	result, err := db.Exec(ctx, deleteEmailSuppression, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const queryEmailSuppressionByID = `-- name: QueryEmailSuppressionByID :one
+select id, created_at, updated_at, email, reason, provider, details, email_message_id from email_suppressions where id=$1
+`
+
+// QueryEmailSuppressionByID
+//
+//	select id, created_at, updated_at, email, reason, provider, details, email_message_id from email_suppressions where id=$1
+func (q *Queries) QueryEmailSuppressionByID(ctx context.Context, db DBTX, id uuid.UUID) (EmailSuppression, error) {
+	row := db.QueryRow(ctx, queryEmailSuppressionByID, id)
+	var i EmailSuppression
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Reason,
+		&i.Provider,
+		&i.Details,
+		&i.EmailMessageID,
+	)
+	return i, err
+}
+
+const queryEmailSuppressions = `-- name: QueryEmailSuppressions :many
+select id, created_at, updated_at, email, reason, provider, details, email_message_id from email_suppressions
+where $1::text = '' or email ilike '%' || $1::text || '%'
+order by created_at desc
+limit $3::bigint offset $2::bigint
+`
+
+type QueryEmailSuppressionsParams struct {
+	Search string
+	Offset int64
+	Limit  int64
+}
+
+// QueryEmailSuppressions
+//
+//	select id, created_at, updated_at, email, reason, provider, details, email_message_id from email_suppressions
+//	where $1::text = '' or email ilike '%' || $1::text || '%'
+//	order by created_at desc
+//	limit $3::bigint offset $2::bigint
+func (q *Queries) QueryEmailSuppressions(ctx context.Context, db DBTX, arg QueryEmailSuppressionsParams) ([]EmailSuppression, error) {
+	rows, err := db.Query(ctx, queryEmailSuppressions, arg.Search, arg.Offset, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []EmailSuppression
+	for rows.Next() {
+		var i EmailSuppression
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.Reason,
+			&i.Provider,
+			&i.Details,
+			&i.EmailMessageID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const querySuppressedEmails = `-- name: QuerySuppressedEmails :many
+select email from email_suppressions where email = any($1::text[])
+`
+
+// QuerySuppressedEmails
+//
+//	select email from email_suppressions where email = any($1::text[])
+func (q *Queries) QuerySuppressedEmails(ctx context.Context, db DBTX, emails []string) ([]string, error) {
+	rows, err := db.Query(ctx, querySuppressedEmails, emails)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, err
+		}
+		items = append(items, email)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertEmailSuppression = `-- name: UpsertEmailSuppression :one
+insert into
+    email_suppressions (id, created_at, updated_at, email, reason, provider, details, email_message_id)
+values
+    ($1, now(), now(), $2, $3, $4, $5, $6)
+on conflict (email) do update
+    set updated_at=now(),
+        reason=excluded.reason,
+        provider=excluded.provider,
+        details=excluded.details,
+        email_message_id=excluded.email_message_id
+returning id, created_at, updated_at, email, reason, provider, details, email_message_id
+`
+
+type UpsertEmailSuppressionParams struct {
+	ID             uuid.UUID
+	Email          string
+	Reason         string
+	Provider       string
+	Details        string
+	EmailMessageID pgtype.UUID
+}
+
+// A later report for a suppressed address replaces the reason on record
+// but keeps when it was first suppressed.
+//
+//	insert into
+//	    email_suppressions (id, created_at, updated_at, email, reason, provider, details, email_message_id)
+//	values
+//	    ($1, now(), now(), $2, $3, $4, $5, $6)
+//	on conflict (email) do update
+//	    set updated_at=now(),
+//	        reason=excluded.reason,
+//	        provider=excluded.provider,
+//	        details=excluded.details,
+//	        email_message_id=excluded.email_message_id
+//	returning id, created_at, updated_at, email, reason, provider, details, email_message_id
+func (q *Queries) UpsertEmailSuppression(ctx context.Context, db DBTX, arg UpsertEmailSuppressionParams) (EmailSuppression, error) {
+	row := db.QueryRow(ctx, upsertEmailSuppression,
+		arg.ID,
+		arg.Email,
+		arg.Reason,
+		arg.Provider,
+		arg.Details,
+		arg.EmailMessageID,
+	)
+	var i EmailSuppression
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Reason,
+		&i.Provider,
+		&i.Details,
+		&i.EmailMessageID,
+	)
+	return i, err
+}
@@ -60,6 +60,133 @@ func (ns NullRiverJobState) Value() (driver.Value, error) {
 	return string(ns.RiverJobState), nil
 }
 
+type ApiToken struct {
+	ID         uuid.UUID
+	CreatedAt  pgtype.Timestamptz
+	UpdatedAt  pgtype.Timestamptz
+	UserID     uuid.UUID
+	Name       string
+	Prefix     string
+	Hash       string
+	Scopes     []string
+	LastUsedAt pgtype.Timestamptz
+	ExpiresAt  pgtype.Timestamptz
+}
+
+type AuditEvent struct {
+	ID        uuid.UUID
+	CreatedAt pgtype.Timestamptz
+	ActorID   pgtype.UUID
+	SubjectID pgtype.UUID
+	Action    string
+	Details   []byte
+	Ip        string
+	UserAgent string
+	TraceID   string
+}
+
+type DataExport struct {
+	ID        uuid.UUID
+	CreatedAt pgtype.Timestamptz
+	UserID    uuid.UUID
+	Hash      string
+	ExpiresAt pgtype.Timestamptz
+	Archive   []byte
+}
+
+type EmailMessage struct {
+	ID                uuid.UUID
+	CreatedAt         pgtype.Timestamptz
+	UpdatedAt         pgtype.Timestamptz
+	Kind              string
+	Template          string
+	Sender            string
+	Recipients        []string
+	Subject           string
+	HtmlBody          string
+	TextBody          string
+	Status            string
+	Attempts          int32
+	LastError         string
+	ProviderMessageID string
+	JobID             pgtype.Int8
+	SentAt            pgtype.Timestamptz
+}
+
+type EmailSuppression struct {
+	ID             uuid.UUID
+	CreatedAt      pgtype.Timestamptz
+	UpdatedAt      pgtype.Timestamptz
+	Email          string
+	Reason         string
+	Provider       string
+	Details        string
+	EmailMessageID pgtype.UUID
+}
+
+type Identity struct {
+	ID        uuid.UUID
+	CreatedAt pgtype.Timestamptz
+	UpdatedAt pgtype.Timestamptz
+	UserID    uuid.UUID
+	Provider  string
+	Subject   string
+	Email     string
+}
+
+type Organization struct {
+	ID        uuid.UUID
+	CreatedAt pgtype.Timestamptz
+	UpdatedAt pgtype.Timestamptz
+	Name      string
+}
+
+type OrganizationInvitation struct {
+	ID             uuid.UUID
+	CreatedAt      pgtype.Timestamptz
+	OrganizationID uuid.UUID
+	InvitedByID    pgtype.UUID
+	Email          string
+	Role           string
+	Hash           string
+	ExpiresAt      pgtype.Timestamptz
+}
+
+type OrganizationMembership struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+	CreatedAt      pgtype.Timestamptz
+	UpdatedAt      pgtype.Timestamptz
+	Role           string
+}
+
+type Permission struct {
+	ID          uuid.UUID
+	CreatedAt   pgtype.Timestamptz
+	Name        string
+	Description string
+}
+
+type RecoveryCode struct {
+	ID        uuid.UUID
+	CreatedAt pgtype.Timestamptz
+	UpdatedAt pgtype.Timestamptz
+	UserID    uuid.UUID
+	Hash      string
+	UsedAt    pgtype.Timestamptz
+}
+
+type RememberToken struct {
+	ID           uuid.UUID
+	CreatedAt    pgtype.Timestamptz
+	UpdatedAt    pgtype.Timestamptz
+	SessionID    uuid.UUID
+	Hash         string
+	PreviousHash string
+	RotatedAt    pgtype.Timestamptz
+	ExpiresAt    pgtype.Timestamptz
+}
+
 type RiverClient struct {
 	ID        string
 	CreatedAt pgtype.Timestamptz
@@ -121,6 +248,40 @@ type RiverQueue struct {
 	UpdatedAt pgtype.Timestamptz
 }
 
+type Role struct {
+	ID          uuid.UUID
+	CreatedAt   pgtype.Timestamptz
+	UpdatedAt   pgtype.Timestamptz
+	Name        string
+	Description string
+}
+
+type RolePermission struct {
+	RoleID       uuid.UUID
+	PermissionID uuid.UUID
+}
+
+type Session struct {
+	ID         uuid.UUID
+	CreatedAt  pgtype.Timestamptz
+	UpdatedAt  pgtype.Timestamptz
+	UserID     uuid.UUID
+	ExpiresAt  pgtype.Timestamptz
+	RevokedAt  pgtype.Timestamptz
+	Ip         string
+	UserAgent  string
+	LastSeenAt pgtype.Timestamptz
+}
+
+type Throttle struct {
+	Key             string
+	CreatedAt       pgtype.Timestamptz
+	UpdatedAt       pgtype.Timestamptz
+	Failures        int32
+	WindowStartedAt pgtype.Timestamptz
+	LockedUntil     pgtype.Timestamptz
+}
+
 type Token struct {
 	ID        uuid.UUID
 	CreatedAt pgtype.Timestamptz
@@ -129,14 +290,44 @@ type Token struct {
 	ExpiresAt pgtype.Timestamptz
 	Hash      string
 	MetaData  []byte
+	UserID    pgtype.UUID
+	Attempts  int32
+}
+
+type TotpCredential struct {
+	ID           uuid.UUID
+	CreatedAt    pgtype.Timestamptz
+	UpdatedAt    pgtype.Timestamptz
+	UserID       uuid.UUID
+	Secret       string
+	ConfirmedAt  pgtype.Timestamptz
+	LastUsedStep int64
 }
 
 type User struct {
-	ID               uuid.UUID
-	CreatedAt        pgtype.Timestamptz
-	UpdatedAt        pgtype.Timestamptz
-	Email            string
-	EmailValidatedAt pgtype.Timestamptz
-	Password         []byte
-	IsAdmin          bool
+	ID                  uuid.UUID
+	CreatedAt           pgtype.Timestamptz
+	UpdatedAt           pgtype.Timestamptz
+	Email               string
+	EmailValidatedAt    pgtype.Timestamptz
+	Password            []byte
+	IsAdmin             bool
+	DeletionScheduledAt pgtype.Timestamptz
+}
+
+type UserRole struct {
+	UserID    uuid.UUID
+	RoleID    uuid.UUID
+	CreatedAt pgtype.Timestamptz
+}
+
+type WebauthnCredential struct {
+	ID           uuid.UUID
+	CreatedAt    pgtype.Timestamptz
+	UpdatedAt    pgtype.Timestamptz
+	UserID       uuid.UUID
+	CredentialID []byte
+	Name         string
+	Credential   []byte
+	LastUsedAt   pgtype.Timestamptz
 }
@@ -0,0 +1,139 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: identities.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const insertIdentity = `-- name: InsertIdentity :one
+insert into
+    identities (id, created_at, updated_at, user_id, provider, subject, email)
+values
+    ($1, now(), now(), $2, $3, $4, $5)
+returning id, created_at, updated_at, user_id, provider, subject, email
+`
+
+type InsertIdentityParams struct {
+	ID       uuid.UUID
+	UserID   uuid.UUID
+	Provider string
+	Subject  string
+	Email    string
+}
+
+// InsertIdentity
+//
+//	insert into
+//	    identities (id, created_at, updated_at, user_id, provider, subject, email)
+//	values
+//	    ($1, now(), now(), $2, $3, $4, $5)
+//	returning id, created_at, updated_at, user_id, provider, subject, email
+func (q *Queries) InsertIdentity(ctx context.Context, db DBTX, arg InsertIdentityParams) (Identity, error) {
+	row := db.QueryRow(ctx, insertIdentity,
+		arg.ID,
+		arg.UserID,
+		arg.Provider,
+		arg.Subject,
+		arg.Email,
+	)
+	var i Identity
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Provider,
+		&i.Subject,
+		&i.Email,
+	)
+	return i, err
+}
+
+const queryIdentitiesByUserID = `-- name: QueryIdentitiesByUserID :many
+select id, created_at, updated_at, user_id, provider, subject, email from identities where user_id=$1 order by created_at
+`
+
+// QueryIdentitiesByUserID
+//
+//	select id, created_at, updated_at, user_id, provider, subject, email from identities where user_id=$1 order by created_at
+func (q *Queries) QueryIdentitiesByUserID(ctx context.Context, db DBTX, userID uuid.UUID) ([]Identity, error) {
+	rows, err := db.Query(ctx, queryIdentitiesByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Identity
+	for rows.Next() {
+		var i Identity
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Provider,
+			&i.Subject,
+			&i.Email,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryIdentityByProviderAndSubject = `-- name: QueryIdentityByProviderAndSubject :one
+select id, created_at, updated_at, user_id, provider, subject, email from identities where provider=$1 and subject=$2
+`
+
+type QueryIdentityByProviderAndSubjectParams struct {
+	Provider string
+	Subject  string
+}
+
+// QueryIdentityByProviderAndSubject
+//
+//	select id, created_at, updated_at, user_id, provider, subject, email from identities where provider=$1 and subject=$2
+func (q *Queries) QueryIdentityByProviderAndSubject(ctx context.Context, db DBTX, arg QueryIdentityByProviderAndSubjectParams) (Identity, error) {
+	row := db.QueryRow(ctx, queryIdentityByProviderAndSubject, arg.Provider, arg.Subject)
+	var i Identity
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Provider,
+		&i.Subject,
+		&i.Email,
+	)
+	return i, err
+}
+
+const updateIdentityEmail = `-- name: UpdateIdentityEmail :exec
+update identities
+    set updated_at=now(), email=$2
+where id = $1
+`
+
+type UpdateIdentityEmailParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+// UpdateIdentityEmail
+//
+//	update identities
+//	    set updated_at=now(), email=$2
+//	where id = $1
+func (q *Queries) UpdateIdentityEmail(ctx context.Context, db DBTX, arg UpdateIdentityEmailParams) error {
+	_, err := db.Exec(ctx, updateIdentityEmail, arg.ID, arg.Email)
+	return err
+}
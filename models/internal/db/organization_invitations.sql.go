@@ -0,0 +1,166 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: organization_invitations.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const deleteOrganizationInvitation = `-- name: DeleteOrganizationInvitation :execrows
+delete from organization_invitations
+where id = $1 and organization_id = $2
+`
+
+type DeleteOrganizationInvitationParams struct {
+	ID             uuid.UUID
+	OrganizationID uuid.UUID
+}
+
+// DeleteOrganizationInvitation
+//
+//	delete from organization_invitations
+//	where id = $1 and organization_id = $2
+func (q *Queries) DeleteOrganizationInvitation(ctx context.Context, db DBTX, arg DeleteOrganizationInvitationParams) (int64, error) {
+	result, err := db.Exec(ctx, deleteOrganizationInvitation, arg.ID, arg.OrganizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteOrganizationInvitationByEmail = `-- name: DeleteOrganizationInvitationByEmail :exec
+delete from organization_invitations
+where organization_id = $1 and email = $2
+`
+
+type DeleteOrganizationInvitationByEmailParams struct {
+	OrganizationID uuid.UUID
+	Email          string
+}
+
+// DeleteOrganizationInvitationByEmail
+//
+//	delete from organization_invitations
+//	where organization_id = $1 and email = $2
+func (q *Queries) DeleteOrganizationInvitationByEmail(ctx context.Context, db DBTX, arg DeleteOrganizationInvitationByEmailParams) error {
+	_, err := db.Exec(ctx, deleteOrganizationInvitationByEmail, arg.OrganizationID, arg.Email)
+	return err
+}
+
+const insertOrganizationInvitation = `-- name: InsertOrganizationInvitation :one
+insert into
+    organization_invitations (id, created_at, organization_id, invited_by_id, email, role, hash, expires_at)
+values
+    ($1, now(), $2, $3, $4, $5, $6, $7)
+returning id, created_at, organization_id, invited_by_id, email, role, hash, expires_at
+`
+
+type InsertOrganizationInvitationParams struct {
+	ID             uuid.UUID
+	OrganizationID uuid.UUID
+	InvitedByID    pgtype.UUID
+	Email          string
+	Role           string
+	Hash           string
+	ExpiresAt      pgtype.Timestamptz
+}
+
+// InsertOrganizationInvitation
+//
+//	insert into
+//	    organization_invitations (id, created_at, organization_id, invited_by_id, email, role, hash, expires_at)
+//	values
+//	    ($1, now(), $2, $3, $4, $5, $6, $7)
+//	returning id, created_at, organization_id, invited_by_id, email, role, hash, expires_at
+func (q *Queries) InsertOrganizationInvitation(ctx context.Context, db DBTX, arg InsertOrganizationInvitationParams) (OrganizationInvitation, error) {
+	row := db.QueryRow(ctx, insertOrganizationInvitation,
+		arg.ID,
+		arg.OrganizationID,
+		arg.InvitedByID,
+		arg.Email,
+		arg.Role,
+		arg.Hash,
+		arg.ExpiresAt,
+	)
+	var i OrganizationInvitation
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.OrganizationID,
+		&i.InvitedByID,
+		&i.Email,
+		&i.Role,
+		&i.Hash,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const queryOrganizationInvitationByHash = `-- name: QueryOrganizationInvitationByHash :one
+select id, created_at, organization_id, invited_by_id, email, role, hash, expires_at from organization_invitations where hash=$1
+`
+
+// QueryOrganizationInvitationByHash
+//
+//	select id, created_at, organization_id, invited_by_id, email, role, hash, expires_at from organization_invitations where hash=$1
+func (q *Queries) QueryOrganizationInvitationByHash(ctx context.Context, db DBTX, hash string) (OrganizationInvitation, error) {
+	row := db.QueryRow(ctx, queryOrganizationInvitationByHash, hash)
+	var i OrganizationInvitation
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.OrganizationID,
+		&i.InvitedByID,
+		&i.Email,
+		&i.Role,
+		&i.Hash,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const queryPendingOrganizationInvitations = `-- name: QueryPendingOrganizationInvitations :many
+select id, created_at, organization_id, invited_by_id, email, role, hash, expires_at from organization_invitations
+where organization_id = $1 and expires_at > now()
+order by created_at desc
+`
+
+// QueryPendingOrganizationInvitations
+//
+//	select id, created_at, organization_id, invited_by_id, email, role, hash, expires_at from organization_invitations
+//	where organization_id = $1 and expires_at > now()
+//	order by created_at desc
+func (q *Queries) QueryPendingOrganizationInvitations(ctx context.Context, db DBTX, organizationID uuid.UUID) ([]OrganizationInvitation, error) {
+	rows, err := db.Query(ctx, queryPendingOrganizationInvitations, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []OrganizationInvitation
+	for rows.Next() {
+		var i OrganizationInvitation
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.OrganizationID,
+			&i.InvitedByID,
+			&i.Email,
+			&i.Role,
+			&i.Hash,
+			&i.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
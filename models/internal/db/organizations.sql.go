@@ -0,0 +1,330 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: organizations.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const countOrganizationOwners = `-- name: CountOrganizationOwners :one
+select count(*) from organization_memberships
+where organization_id = $1 and role = 'owner'
+`
+
+// CountOrganizationOwners
+//
+//	select count(*) from organization_memberships
+//	where organization_id = $1 and role = 'owner'
+func (q *Queries) CountOrganizationOwners(ctx context.Context, db DBTX, organizationID uuid.UUID) (int64, error) {
+	row := db.QueryRow(ctx, countOrganizationOwners, organizationID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deleteOrganizationMembership = `-- name: DeleteOrganizationMembership :execrows
+delete from organization_memberships
+where organization_id = $1 and user_id = $2
+`
+
+type DeleteOrganizationMembershipParams struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+}
+
+// DeleteOrganizationMembership
+//
+//	delete from organization_memberships
+//	where organization_id = $1 and user_id = $2
+func (q *Queries) DeleteOrganizationMembership(ctx context.Context, db DBTX, arg DeleteOrganizationMembershipParams) (int64, error) {
+	result, err := db.Exec(ctx, deleteOrganizationMembership, arg.OrganizationID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const insertOrganization = `-- name: InsertOrganization :one
+insert into
+    organizations (id, created_at, updated_at, name)
+values
+    ($1, now(), now(), $2)
+returning id, created_at, updated_at, name
+`
+
+type InsertOrganizationParams struct {
+	ID   uuid.UUID
+	Name string
+}
+
+// InsertOrganization
+//
+//	insert into
+//	    organizations (id, created_at, updated_at, name)
+//	values
+//	    ($1, now(), now(), $2)
+//	returning id, created_at, updated_at, name
+func (q *Queries) InsertOrganization(ctx context.Context, db DBTX, arg InsertOrganizationParams) (Organization, error) {
+	row := db.QueryRow(ctx, insertOrganization, arg.ID, arg.Name)
+	var i Organization
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+	)
+	return i, err
+}
+
+const insertOrganizationMembership = `-- name: InsertOrganizationMembership :exec
+insert into
+    organization_memberships (organization_id, user_id, created_at, updated_at, role)
+values
+    ($1, $2, now(), now(), $3)
+on conflict (organization_id, user_id) do nothing
+`
+
+type InsertOrganizationMembershipParams struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+	Role           string
+}
+
+// InsertOrganizationMembership
+//
+//	insert into
+//	    organization_memberships (organization_id, user_id, created_at, updated_at, role)
+//	values
+//	    ($1, $2, now(), now(), $3)
+//	on conflict (organization_id, user_id) do nothing
+func (q *Queries) InsertOrganizationMembership(ctx context.Context, db DBTX, arg InsertOrganizationMembershipParams) error {
+	_, err := db.Exec(ctx, insertOrganizationMembership, arg.OrganizationID, arg.UserID, arg.Role)
+	return err
+}
+
+const lockOrganization = `-- name: LockOrganization :exec
+select id from organizations where id=$1 for update
+`
+
+// LockOrganization
+//
+//	select id from organizations where id=$1 for update
+func (q *Queries) LockOrganization(ctx context.Context, db DBTX, id uuid.UUID) error {
+	_, err := db.Exec(ctx, lockOrganization, id)
+	return err
+}
+
+const queryOrganizationByID = `-- name: QueryOrganizationByID :one
+select id, created_at, updated_at, name from organizations where id=$1
+`
+
+// QueryOrganizationByID
+//
+//	select id, created_at, updated_at, name from organizations where id=$1
+func (q *Queries) QueryOrganizationByID(ctx context.Context, db DBTX, id uuid.UUID) (Organization, error) {
+	row := db.QueryRow(ctx, queryOrganizationByID, id)
+	var i Organization
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+	)
+	return i, err
+}
+
+const queryOrganizationMembers = `-- name: QueryOrganizationMembers :many
+select
+    organization_memberships.user_id,
+    users.email,
+    organization_memberships.role,
+    organization_memberships.created_at
+from organization_memberships
+join users on users.id = organization_memberships.user_id
+where organization_memberships.organization_id = $1
+order by users.email
+`
+
+type QueryOrganizationMembersRow struct {
+	UserID    uuid.UUID
+	Email     string
+	Role      string
+	CreatedAt pgtype.Timestamptz
+}
+
+// QueryOrganizationMembers
+//
+//	select
+//	    organization_memberships.user_id,
+//	    users.email,
+//	    organization_memberships.role,
+//	    organization_memberships.created_at
+//	from organization_memberships
+//	join users on users.id = organization_memberships.user_id
+//	where organization_memberships.organization_id = $1
+//	order by users.email
+func (q *Queries) QueryOrganizationMembers(ctx context.Context, db DBTX, organizationID uuid.UUID) ([]QueryOrganizationMembersRow, error) {
+	rows, err := db.Query(ctx, queryOrganizationMembers, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []QueryOrganizationMembersRow
+	for rows.Next() {
+		var i QueryOrganizationMembersRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Email,
+			&i.Role,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryOrganizationMembership = `-- name: QueryOrganizationMembership :one
+select
+    organization_memberships.organization_id,
+    organizations.name as organization_name,
+    organization_memberships.user_id,
+    organization_memberships.role,
+    organization_memberships.created_at
+from organization_memberships
+join organizations on organizations.id = organization_memberships.organization_id
+where organization_memberships.organization_id = $1
+    and organization_memberships.user_id = $2
+`
+
+type QueryOrganizationMembershipParams struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+}
+
+type QueryOrganizationMembershipRow struct {
+	OrganizationID   uuid.UUID
+	OrganizationName string
+	UserID           uuid.UUID
+	Role             string
+	CreatedAt        pgtype.Timestamptz
+}
+
+// QueryOrganizationMembership
+//
+//	select
+//	    organization_memberships.organization_id,
+//	    organizations.name as organization_name,
+//	    organization_memberships.user_id,
+//	    organization_memberships.role,
+//	    organization_memberships.created_at
+//	from organization_memberships
+//	join organizations on organizations.id = organization_memberships.organization_id
+//	where organization_memberships.organization_id = $1
+//	    and organization_memberships.user_id = $2
+func (q *Queries) QueryOrganizationMembership(ctx context.Context, db DBTX, arg QueryOrganizationMembershipParams) (QueryOrganizationMembershipRow, error) {
+	row := db.QueryRow(ctx, queryOrganizationMembership, arg.OrganizationID, arg.UserID)
+	var i QueryOrganizationMembershipRow
+	err := row.Scan(
+		&i.OrganizationID,
+		&i.OrganizationName,
+		&i.UserID,
+		&i.Role,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const queryOrganizationMembershipsByUserID = `-- name: QueryOrganizationMembershipsByUserID :many
+select
+    organization_memberships.organization_id,
+    organizations.name as organization_name,
+    organization_memberships.user_id,
+    organization_memberships.role,
+    organization_memberships.created_at
+from organization_memberships
+join organizations on organizations.id = organization_memberships.organization_id
+where organization_memberships.user_id = $1
+order by organizations.name, organizations.id
+`
+
+type QueryOrganizationMembershipsByUserIDRow struct {
+	OrganizationID   uuid.UUID
+	OrganizationName string
+	UserID           uuid.UUID
+	Role             string
+	CreatedAt        pgtype.Timestamptz
+}
+
+// QueryOrganizationMembershipsByUserID
+//
+//	select
+//	    organization_memberships.organization_id,
+//	    organizations.name as organization_name,
+//	    organization_memberships.user_id,
+//	    organization_memberships.role,
+//	    organization_memberships.created_at
+//	from organization_memberships
+//	join organizations on organizations.id = organization_memberships.organization_id
+//	where organization_memberships.user_id = $1
+//	order by organizations.name, organizations.id
+func (q *Queries) QueryOrganizationMembershipsByUserID(ctx context.Context, db DBTX, userID uuid.UUID) ([]QueryOrganizationMembershipsByUserIDRow, error) {
+	rows, err := db.Query(ctx, queryOrganizationMembershipsByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []QueryOrganizationMembershipsByUserIDRow
+	for rows.Next() {
+		var i QueryOrganizationMembershipsByUserIDRow
+		if err := rows.Scan(
+			&i.OrganizationID,
+			&i.OrganizationName,
+			&i.UserID,
+			&i.Role,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateOrganizationMembershipRole = `-- name: UpdateOrganizationMembershipRole :execrows
+update organization_memberships
+    set updated_at=now(), role=$3
+where organization_id = $1 and user_id = $2
+`
+
+type UpdateOrganizationMembershipRoleParams struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+	Role           string
+}
+
+// UpdateOrganizationMembershipRole
+//
+//	update organization_memberships
+//	    set updated_at=now(), role=$3
+//	where organization_id = $1 and user_id = $2
+func (q *Queries) UpdateOrganizationMembershipRole(ctx context.Context, db DBTX, arg UpdateOrganizationMembershipRoleParams) (int64, error) {
+	result, err := db.Exec(ctx, updateOrganizationMembershipRole, arg.OrganizationID, arg.UserID, arg.Role)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,115 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: recovery_codes.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const countUnusedRecoveryCodesByUserID = `-- name: CountUnusedRecoveryCodesByUserID :one
+select count(*) from recovery_codes where user_id=$1 and used_at is null
+`
+
+// CountUnusedRecoveryCodesByUserID
+//
+//	select count(*) from recovery_codes where user_id=$1 and used_at is null
+func (q *Queries) CountUnusedRecoveryCodesByUserID(ctx context.Context, db DBTX, userID uuid.UUID) (int64, error) {
+	row := db.QueryRow(ctx, countUnusedRecoveryCodesByUserID, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deleteRecoveryCodesByUserID = `-- name: DeleteRecoveryCodesByUserID :exec
+delete from recovery_codes where user_id=$1
+`
+
+// DeleteRecoveryCodesByUserID
+//
+//	delete from recovery_codes where user_id=$1
+func (q *Queries) DeleteRecoveryCodesByUserID(ctx context.Context, db DBTX, userID uuid.UUID) error {
+	_, err := db.Exec(ctx, deleteRecoveryCodesByUserID, userID)
+	return err
+}
+
+const insertRecoveryCode = `-- name: InsertRecoveryCode :one
+insert into
+    recovery_codes (id, created_at, updated_at, user_id, hash, used_at)
+values
+    ($1, now(), now(), $2, $3, null)
+returning id, created_at, updated_at, user_id, hash, used_at
+`
+
+type InsertRecoveryCodeParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	Hash   string
+}
+
+// InsertRecoveryCode
+//
+//	insert into
+//	    recovery_codes (id, created_at, updated_at, user_id, hash, used_at)
+//	values
+//	    ($1, now(), now(), $2, $3, null)
+//	returning id, created_at, updated_at, user_id, hash, used_at
+func (q *Queries) InsertRecoveryCode(ctx context.Context, db DBTX, arg InsertRecoveryCodeParams) (RecoveryCode, error) {
+	row := db.QueryRow(ctx, insertRecoveryCode, arg.ID, arg.UserID, arg.Hash)
+	var i RecoveryCode
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Hash,
+		&i.UsedAt,
+	)
+	return i, err
+}
+
+const markRecoveryCodeUsed = `-- name: MarkRecoveryCodeUsed :exec
+update recovery_codes
+    set updated_at=now(), used_at=now()
+where id = $1
+`
+
+// MarkRecoveryCodeUsed
+//
+//	update recovery_codes
+//	    set updated_at=now(), used_at=now()
+//	where id = $1
+func (q *Queries) MarkRecoveryCodeUsed(ctx context.Context, db DBTX, id uuid.UUID) error {
+	_, err := db.Exec(ctx, markRecoveryCodeUsed, id)
+	return err
+}
+
+const queryUnusedRecoveryCodeByUserIDAndHash = `-- name: QueryUnusedRecoveryCodeByUserIDAndHash :one
+select id, created_at, updated_at, user_id, hash, used_at from recovery_codes where user_id=$1 and hash=$2 and used_at is null limit 1
+`
+
+type QueryUnusedRecoveryCodeByUserIDAndHashParams struct {
+	UserID uuid.UUID
+	Hash   string
+}
+
+// QueryUnusedRecoveryCodeByUserIDAndHash
+//
+//	select id, created_at, updated_at, user_id, hash, used_at from recovery_codes where user_id=$1 and hash=$2 and used_at is null limit 1
+func (q *Queries) QueryUnusedRecoveryCodeByUserIDAndHash(ctx context.Context, db DBTX, arg QueryUnusedRecoveryCodeByUserIDAndHashParams) (RecoveryCode, error) {
+	row := db.QueryRow(ctx, queryUnusedRecoveryCodeByUserIDAndHash, arg.UserID, arg.Hash)
+	var i RecoveryCode
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Hash,
+		&i.UsedAt,
+	)
+	return i, err
+}
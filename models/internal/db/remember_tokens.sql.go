@@ -0,0 +1,128 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: remember_tokens.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const deleteRememberTokensByUserID = `-- name: DeleteRememberTokensByUserID :exec
+delete from remember_tokens
+where session_id in (select id from sessions where user_id=$1)
+`
+
+// DeleteRememberTokensByUserID
+//
+//	delete from remember_tokens
+//	where session_id in (select id from sessions where user_id=$1)
+func (q *Queries) DeleteRememberTokensByUserID(ctx context.Context, db DBTX, userID uuid.UUID) error {
+	_, err := db.Exec(ctx, deleteRememberTokensByUserID, userID)
+	return err
+}
+
+const insertRememberToken = `-- name: InsertRememberToken :one
+insert into
+    remember_tokens (id, created_at, updated_at, session_id, hash, previous_hash, rotated_at, expires_at)
+values
+    ($1, now(), now(), $2, $3, '', now(), $4)
+returning id, created_at, updated_at, session_id, hash, previous_hash, rotated_at, expires_at
+`
+
+type InsertRememberTokenParams struct {
+	ID        uuid.UUID
+	SessionID uuid.UUID
+	Hash      string
+	ExpiresAt pgtype.Timestamptz
+}
+
+// InsertRememberToken
+//
+//	insert into
+//	    remember_tokens (id, created_at, updated_at, session_id, hash, previous_hash, rotated_at, expires_at)
+//	values
+//	    ($1, now(), now(), $2, $3, '', now(), $4)
+//	returning id, created_at, updated_at, session_id, hash, previous_hash, rotated_at, expires_at
+func (q *Queries) InsertRememberToken(ctx context.Context, db DBTX, arg InsertRememberTokenParams) (RememberToken, error) {
+	row := db.QueryRow(ctx, insertRememberToken,
+		arg.ID,
+		arg.SessionID,
+		arg.Hash,
+		arg.ExpiresAt,
+	)
+	var i RememberToken
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.SessionID,
+		&i.Hash,
+		&i.PreviousHash,
+		&i.RotatedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const queryRememberTokenByID = `-- name: QueryRememberTokenByID :one
+select id, created_at, updated_at, session_id, hash, previous_hash, rotated_at, expires_at from remember_tokens where id=$1
+`
+
+// QueryRememberTokenByID
+//
+//	select id, created_at, updated_at, session_id, hash, previous_hash, rotated_at, expires_at from remember_tokens where id=$1
+func (q *Queries) QueryRememberTokenByID(ctx context.Context, db DBTX, id uuid.UUID) (RememberToken, error) {
+	row := db.QueryRow(ctx, queryRememberTokenByID, id)
+	var i RememberToken
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.SessionID,
+		&i.Hash,
+		&i.PreviousHash,
+		&i.RotatedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const rotateRememberToken = `-- name: RotateRememberToken :one
+update remember_tokens
+    set updated_at=now(), rotated_at=now(), previous_hash=hash, hash=$1
+where id = $2 and hash = $3
+returning id, created_at, updated_at, session_id, hash, previous_hash, rotated_at, expires_at
+`
+
+type RotateRememberTokenParams struct {
+	NewHash     string
+	ID          uuid.UUID
+	CurrentHash string
+}
+
+// RotateRememberToken
+//
+//	update remember_tokens
+//	    set updated_at=now(), rotated_at=now(), previous_hash=hash, hash=$1
+//	where id = $2 and hash = $3
+//	returning id, created_at, updated_at, session_id, hash, previous_hash, rotated_at, expires_at
+func (q *Queries) RotateRememberToken(ctx context.Context, db DBTX, arg RotateRememberTokenParams) (RememberToken, error) {
+	row := db.QueryRow(ctx, rotateRememberToken, arg.NewHash, arg.ID, arg.CurrentHash)
+	var i RememberToken
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.SessionID,
+		&i.Hash,
+		&i.PreviousHash,
+		&i.RotatedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
@@ -0,0 +1,237 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: roles.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const deleteUserRole = `-- name: DeleteUserRole :exec
+delete from user_roles where user_id=$1 and role_id=$2
+`
+
+type DeleteUserRoleParams struct {
+	UserID uuid.UUID
+	RoleID uuid.UUID
+}
+
+// DeleteUserRole
+//
+//	delete from user_roles where user_id=$1 and role_id=$2
+func (q *Queries) DeleteUserRole(ctx context.Context, db DBTX, arg DeleteUserRoleParams) error {
+	_, err := db.Exec(ctx, deleteUserRole, arg.UserID, arg.RoleID)
+	return err
+}
+
+const insertUserRole = `-- name: InsertUserRole :exec
+insert into
+    user_roles (user_id, role_id, created_at)
+values
+    ($1, $2, now())
+on conflict do nothing
+`
+
+type InsertUserRoleParams struct {
+	UserID uuid.UUID
+	RoleID uuid.UUID
+}
+
+// InsertUserRole
+//
+//	insert into
+//	    user_roles (user_id, role_id, created_at)
+//	values
+//	    ($1, $2, now())
+//	on conflict do nothing
+func (q *Queries) InsertUserRole(ctx context.Context, db DBTX, arg InsertUserRoleParams) error {
+	_, err := db.Exec(ctx, insertUserRole, arg.UserID, arg.RoleID)
+	return err
+}
+
+const queryPermissionNames = `-- name: QueryPermissionNames :many
+select name from permissions order by name
+`
+
+// QueryPermissionNames
+//
+//	select name from permissions order by name
+func (q *Queries) QueryPermissionNames(ctx context.Context, db DBTX) ([]string, error) {
+	rows, err := db.Query(ctx, queryPermissionNames)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		items = append(items, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryPermissionNamesByUserID = `-- name: QueryPermissionNamesByUserID :many
+select distinct permissions.name from permissions
+    join role_permissions on role_permissions.permission_id = permissions.id
+    join user_roles on user_roles.role_id = role_permissions.role_id
+where user_roles.user_id = $1
+order by permissions.name
+`
+
+// QueryPermissionNamesByUserID
+//
+//	select distinct permissions.name from permissions
+//	    join role_permissions on role_permissions.permission_id = permissions.id
+//	    join user_roles on user_roles.role_id = role_permissions.role_id
+//	where user_roles.user_id = $1
+//	order by permissions.name
+func (q *Queries) QueryPermissionNamesByUserID(ctx context.Context, db DBTX, userID uuid.UUID) ([]string, error) {
+	rows, err := db.Query(ctx, queryPermissionNamesByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		items = append(items, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryRoleByName = `-- name: QueryRoleByName :one
+select id, created_at, updated_at, name, description from roles where name=$1
+`
+
+// QueryRoleByName
+//
+//	select id, created_at, updated_at, name, description from roles where name=$1
+func (q *Queries) QueryRoleByName(ctx context.Context, db DBTX, name string) (Role, error) {
+	row := db.QueryRow(ctx, queryRoleByName, name)
+	var i Role
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Description,
+	)
+	return i, err
+}
+
+const queryRoles = `-- name: QueryRoles :many
+select id, created_at, updated_at, name, description from roles order by name
+`
+
+// QueryRoles
+//
+//	select id, created_at, updated_at, name, description from roles order by name
+func (q *Queries) QueryRoles(ctx context.Context, db DBTX) ([]Role, error) {
+	rows, err := db.Query(ctx, queryRoles)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Role
+	for rows.Next() {
+		var i Role
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Description,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryRolesByUserID = `-- name: QueryRolesByUserID :many
+select roles.id, roles.created_at, roles.updated_at, roles.name, roles.description from roles
+    join user_roles on user_roles.role_id = roles.id
+where user_roles.user_id = $1
+order by roles.name
+`
+
+// QueryRolesByUserID
+//
+//	select roles.id, roles.created_at, roles.updated_at, roles.name, roles.description from roles
+//	    join user_roles on user_roles.role_id = roles.id
+//	where user_roles.user_id = $1
+//	order by roles.name
+func (q *Queries) QueryRolesByUserID(ctx context.Context, db DBTX, userID uuid.UUID) ([]Role, error) {
+	rows, err := db.Query(ctx, queryRolesByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Role
+	for rows.Next() {
+		var i Role
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Description,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryUserHasPermission = `-- name: QueryUserHasPermission :one
+select exists (
+    select 1 from permissions
+        join role_permissions on role_permissions.permission_id = permissions.id
+        join user_roles on user_roles.role_id = role_permissions.role_id
+    where user_roles.user_id = $1 and permissions.name = $2
+)
+`
+
+type QueryUserHasPermissionParams struct {
+	UserID uuid.UUID
+	Name   string
+}
+
+// QueryUserHasPermission
+//
+//	select exists (
+//	    select 1 from permissions
+//	        join role_permissions on role_permissions.permission_id = permissions.id
+//	        join user_roles on user_roles.role_id = role_permissions.role_id
+//	    where user_roles.user_id = $1 and permissions.name = $2
+//	)
+func (q *Queries) QueryUserHasPermission(ctx context.Context, db DBTX, arg QueryUserHasPermissionParams) (bool, error) {
+	row := db.QueryRow(ctx, queryUserHasPermission, arg.UserID, arg.Name)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,301 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: sessions.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const deleteSession = `-- name: DeleteSession :exec
+delete from sessions where id=$1
+`
+
+// DeleteSession
+//
+//	delete from sessions where id=$1
+func (q *Queries) DeleteSession(ctx context.Context, db DBTX, id uuid.UUID) error {
+	_, err := db.Exec(ctx, deleteSession, id)
+	return err
+}
+
+const extendSession = `-- name: ExtendSession :one
+update sessions
+    set updated_at=now(), expires_at=$2
+where id = $1
+returning id, created_at, updated_at, user_id, expires_at, revoked_at, ip, user_agent, last_seen_at
+`
+
+type ExtendSessionParams struct {
+	ID        uuid.UUID
+	ExpiresAt pgtype.Timestamptz
+}
+
+// ExtendSession
+//
+//	update sessions
+//	    set updated_at=now(), expires_at=$2
+//	where id = $1
+//	returning id, created_at, updated_at, user_id, expires_at, revoked_at, ip, user_agent, last_seen_at
+func (q *Queries) ExtendSession(ctx context.Context, db DBTX, arg ExtendSessionParams) (Session, error) {
+	row := db.QueryRow(ctx, extendSession, arg.ID, arg.ExpiresAt)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.Ip,
+		&i.UserAgent,
+		&i.LastSeenAt,
+	)
+	return i, err
+}
+
+const insertSession = `-- name: InsertSession :one
+insert into
+    sessions (id, created_at, updated_at, user_id, expires_at, revoked_at, ip, user_agent, last_seen_at)
+values
+    ($1, now(), now(), $2, $3, null, $4, $5, now())
+returning id, created_at, updated_at, user_id, expires_at, revoked_at, ip, user_agent, last_seen_at
+`
+
+type InsertSessionParams struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	ExpiresAt pgtype.Timestamptz
+	Ip        string
+	UserAgent string
+}
+
+// InsertSession
+//
+//	insert into
+//	    sessions (id, created_at, updated_at, user_id, expires_at, revoked_at, ip, user_agent, last_seen_at)
+//	values
+//	    ($1, now(), now(), $2, $3, null, $4, $5, now())
+//	returning id, created_at, updated_at, user_id, expires_at, revoked_at, ip, user_agent, last_seen_at
+func (q *Queries) InsertSession(ctx context.Context, db DBTX, arg InsertSessionParams) (Session, error) {
+	row := db.QueryRow(ctx, insertSession,
+		arg.ID,
+		arg.UserID,
+		arg.ExpiresAt,
+		arg.Ip,
+		arg.UserAgent,
+	)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.Ip,
+		&i.UserAgent,
+		&i.LastSeenAt,
+	)
+	return i, err
+}
+
+const queryDevicesByUserID = `-- name: QueryDevicesByUserID :many
+select id, created_at, updated_at, user_id, expires_at, revoked_at, ip, user_agent, last_seen_at from sessions
+where user_id = $1
+    and revoked_at is null
+    and (
+        expires_at > now()
+        or exists (
+            select 1 from remember_tokens
+            where remember_tokens.session_id = sessions.id
+                and remember_tokens.expires_at > now()
+        )
+    )
+order by last_seen_at desc
+`
+
+// QueryDevicesByUserID
+//
+//	select id, created_at, updated_at, user_id, expires_at, revoked_at, ip, user_agent, last_seen_at from sessions
+//	where user_id = $1
+//	    and revoked_at is null
+//	    and (
+//	        expires_at > now()
+//	        or exists (
+//	            select 1 from remember_tokens
+//	            where remember_tokens.session_id = sessions.id
+//	                and remember_tokens.expires_at > now()
+//	        )
+//	    )
+//	order by last_seen_at desc
+func (q *Queries) QueryDevicesByUserID(ctx context.Context, db DBTX, userID uuid.UUID) ([]Session, error) {
+	rows, err := db.Query(ctx, queryDevicesByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Session
+	for rows.Next() {
+		var i Session
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.Ip,
+			&i.UserAgent,
+			&i.LastSeenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const querySessionByID = `-- name: QuerySessionByID :one
+select id, created_at, updated_at, user_id, expires_at, revoked_at, ip, user_agent, last_seen_at from sessions where id=$1
+`
+
+// QuerySessionByID
+//
+//	select id, created_at, updated_at, user_id, expires_at, revoked_at, ip, user_agent, last_seen_at from sessions where id=$1
+func (q *Queries) QuerySessionByID(ctx context.Context, db DBTX, id uuid.UUID) (Session, error) {
+	row := db.QueryRow(ctx, querySessionByID, id)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.Ip,
+		&i.UserAgent,
+		&i.LastSeenAt,
+	)
+	return i, err
+}
+
+const querySessionsByUserID = `-- name: QuerySessionsByUserID :many
+select id, created_at, updated_at, user_id, expires_at, revoked_at, ip, user_agent, last_seen_at from sessions where user_id=$1 order by created_at desc
+`
+
+// QuerySessionsByUserID
+//
+//	select id, created_at, updated_at, user_id, expires_at, revoked_at, ip, user_agent, last_seen_at from sessions where user_id=$1 order by created_at desc
+func (q *Queries) QuerySessionsByUserID(ctx context.Context, db DBTX, userID uuid.UUID) ([]Session, error) {
+	rows, err := db.Query(ctx, querySessionsByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Session
+	for rows.Next() {
+		var i Session
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.Ip,
+			&i.UserAgent,
+			&i.LastSeenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeOtherSessionsByUserID = `-- name: RevokeOtherSessionsByUserID :exec
+update sessions
+    set updated_at=now(), revoked_at=now()
+where user_id = $1 and id <> $2 and revoked_at is null
+`
+
+type RevokeOtherSessionsByUserIDParams struct {
+	UserID uuid.UUID
+	ID     uuid.UUID
+}
+
+// RevokeOtherSessionsByUserID
+//
+//	update sessions
+//	    set updated_at=now(), revoked_at=now()
+//	where user_id = $1 and id <> $2 and revoked_at is null
+func (q *Queries) RevokeOtherSessionsByUserID(ctx context.Context, db DBTX, arg RevokeOtherSessionsByUserIDParams) error {
+	_, err := db.Exec(ctx, revokeOtherSessionsByUserID, arg.UserID, arg.ID)
+	return err
+}
+
+const revokeSession = `-- name: RevokeSession :exec
+update sessions
+    set updated_at=now(), revoked_at=now()
+where id = $1 and revoked_at is null
+`
+
+// RevokeSession
+//
+//	update sessions
+//	    set updated_at=now(), revoked_at=now()
+//	where id = $1 and revoked_at is null
+func (q *Queries) RevokeSession(ctx context.Context, db DBTX, id uuid.UUID) error {
+	_, err := db.Exec(ctx, revokeSession, id)
+	return err
+}
+
+const revokeSessionsByUserID = `-- name: RevokeSessionsByUserID :exec
+update sessions
+    set updated_at=now(), revoked_at=now()
+where user_id = $1 and revoked_at is null
+`
+
+// RevokeSessionsByUserID
+//
+//	update sessions
+//	    set updated_at=now(), revoked_at=now()
+//	where user_id = $1 and revoked_at is null
+func (q *Queries) RevokeSessionsByUserID(ctx context.Context, db DBTX, userID uuid.UUID) error {
+	_, err := db.Exec(ctx, revokeSessionsByUserID, userID)
+	return err
+}
+
+const touchSession = `-- name: TouchSession :exec
+update sessions
+    set last_seen_at=now(), ip=$2, user_agent=$3
+where id = $1
+`
+
+type TouchSessionParams struct {
+	ID        uuid.UUID
+	Ip        string
+	UserAgent string
+}
+
+// TouchSession
+//
+//	update sessions
+//	    set last_seen_at=now(), ip=$2, user_agent=$3
+//	where id = $1
+func (q *Queries) TouchSession(ctx context.Context, db DBTX, arg TouchSessionParams) error {
+	_, err := db.Exec(ctx, touchSession, arg.ID, arg.Ip, arg.UserAgent)
+	return err
+}
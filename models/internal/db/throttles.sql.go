@@ -0,0 +1,168 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: throttles.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const deleteThrottle = `-- name: DeleteThrottle :exec
+delete from throttles where key=$1
+`
+
+// DeleteThrottle
+//
+//	delete from throttles where key=$1
+func (q *Queries) DeleteThrottle(ctx context.Context, db DBTX, key string) error {
+	_, err := db.Exec(ctx, deleteThrottle, key)
+	return err
+}
+
+const queryThrottlesByKeys = `-- name: QueryThrottlesByKeys :many
+select key, created_at, updated_at, failures, window_started_at, locked_until from throttles where key = any($1::text[])
+`
+
+// QueryThrottlesByKeys
+//
+//	select key, created_at, updated_at, failures, window_started_at, locked_until from throttles where key = any($1::text[])
+func (q *Queries) QueryThrottlesByKeys(ctx context.Context, db DBTX, keys []string) ([]Throttle, error) {
+	rows, err := db.Query(ctx, queryThrottlesByKeys, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Throttle
+	for rows.Next() {
+		var i Throttle
+		if err := rows.Scan(
+			&i.Key,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Failures,
+			&i.WindowStartedAt,
+			&i.LockedUntil,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const refundThrottleAttempt = `-- name: RefundThrottleAttempt :exec
+update throttles
+    set updated_at=now(),
+        failures=greatest(failures - 1, 0),
+        locked_until=case
+            when failures - 1 < $1::integer then null
+            else locked_until
+        end
+where key = $2
+`
+
+type RefundThrottleAttemptParams struct {
+	LockThreshold int32
+	Key           string
+}
+
+// RefundThrottleAttempt
+//
+//	update throttles
+//	    set updated_at=now(),
+//	        failures=greatest(failures - 1, 0),
+//	        locked_until=case
+//	            when failures - 1 < $1::integer then null
+//	            else locked_until
+//	        end
+//	where key = $2
+func (q *Queries) RefundThrottleAttempt(ctx context.Context, db DBTX, arg RefundThrottleAttemptParams) error {
+	_, err := db.Exec(ctx, refundThrottleAttempt, arg.LockThreshold, arg.Key)
+	return err
+}
+
+const updateThrottleLockedUntil = `-- name: UpdateThrottleLockedUntil :exec
+update throttles
+    set updated_at=now(), locked_until=greatest(locked_until, $1::timestamptz)
+where key = $2
+`
+
+type UpdateThrottleLockedUntilParams struct {
+	LockedUntil pgtype.Timestamptz
+	Key         string
+}
+
+// UpdateThrottleLockedUntil
+//
+//	update throttles
+//	    set updated_at=now(), locked_until=greatest(locked_until, $1::timestamptz)
+//	where key = $2
+func (q *Queries) UpdateThrottleLockedUntil(ctx context.Context, db DBTX, arg UpdateThrottleLockedUntilParams) error {
+	_, err := db.Exec(ctx, updateThrottleLockedUntil, arg.LockedUntil, arg.Key)
+	return err
+}
+
+const upsertThrottleAttempt = `-- name: UpsertThrottleAttempt :one
+insert into
+    throttles (key, created_at, updated_at, failures, window_started_at)
+values
+    ($1, now(), now(), 1, now())
+on conflict (key) do update
+    set updated_at=now(),
+        failures=case
+            when throttles.locked_until > now() then throttles.failures
+            when throttles.window_started_at < $2::timestamptz then 1
+            else throttles.failures + 1
+        end,
+        window_started_at=case
+            when throttles.locked_until > now() then throttles.window_started_at
+            when throttles.window_started_at < $2::timestamptz then now()
+            else throttles.window_started_at
+        end
+returning key, created_at, updated_at, failures, window_started_at, locked_until
+`
+
+type UpsertThrottleAttemptParams struct {
+	Key         string
+	WindowStart pgtype.Timestamptz
+}
+
+// UpsertThrottleAttempt
+//
+//	insert into
+//	    throttles (key, created_at, updated_at, failures, window_started_at)
+//	values
+//	    ($1, now(), now(), 1, now())
+//	on conflict (key) do update
+//	    set updated_at=now(),
+//	        failures=case
+//	            when throttles.locked_until > now() then throttles.failures
+//	            when throttles.window_started_at < $2::timestamptz then 1
+//	            else throttles.failures + 1
+//	        end,
+//	        window_started_at=case
+//	            when throttles.locked_until > now() then throttles.window_started_at
+//	            when throttles.window_started_at < $2::timestamptz then now()
+//	            else throttles.window_started_at
+//	        end
+//	returning key, created_at, updated_at, failures, window_started_at, locked_until
+func (q *Queries) UpsertThrottleAttempt(ctx context.Context, db DBTX, arg UpsertThrottleAttemptParams) (Throttle, error) {
+	row := db.QueryRow(ctx, upsertThrottleAttempt, arg.Key, arg.WindowStart)
+	var i Throttle
+	err := row.Scan(
+		&i.Key,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Failures,
+		&i.WindowStartedAt,
+		&i.LockedUntil,
+	)
+	return i, err
+}
@@ -26,24 +26,91 @@ func (q *Queries) CountTokens(ctx context.Context, db DBTX) (int64, error) {
 	return count, err
 }
 
-const deleteToken = `-- name: DeleteToken :exec
+const deleteExpiredTokens = `-- name: DeleteExpiredTokens :execrows
+delete from tokens
+where id in (
+    select id from tokens
+    where expires_at <= now()
+    order by expires_at
+    limit $1::bigint
+)
+`
+
+// DeleteExpiredTokens
+//
+//	delete from tokens
+//	where id in (
+//	    select id from tokens
+//	    where expires_at <= now()
+//	    order by expires_at
+//	    limit $1::bigint
+//	)
+func (q *Queries) DeleteExpiredTokens(ctx context.Context, db DBTX, limit int64) (int64, error) {
+	result, err := db.Exec(ctx, deleteExpiredTokens, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteToken = `-- name: DeleteToken :execrows
 delete from tokens where id=$1
 `
 
 // DeleteToken
 //
 //	delete from tokens where id=$1
-func (q *Queries) DeleteToken(ctx context.Context, db DBTX, id uuid.UUID) error {
-	_, err := db.Exec(ctx, deleteToken, id)
+func (q *Queries) DeleteToken(ctx context.Context, db DBTX, id uuid.UUID) (int64, error) {
+	result, err := db.Exec(ctx, deleteToken, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteTokensByScopeAndUserID = `-- name: DeleteTokensByScopeAndUserID :exec
+delete from tokens where scope=$1 and user_id=$2
+`
+
+type DeleteTokensByScopeAndUserIDParams struct {
+	Scope  string
+	UserID pgtype.UUID
+}
+
+// DeleteTokensByScopeAndUserID
+//
+//	delete from tokens where scope=$1 and user_id=$2
+func (q *Queries) DeleteTokensByScopeAndUserID(ctx context.Context, db DBTX, arg DeleteTokensByScopeAndUserIDParams) error {
+	_, err := db.Exec(ctx, deleteTokensByScopeAndUserID, arg.Scope, arg.UserID)
 	return err
 }
 
+const incrementTokenAttempts = `-- name: IncrementTokenAttempts :one
+update tokens
+    set updated_at=now(), attempts=attempts + 1
+where id = $1
+returning attempts
+`
+
+// IncrementTokenAttempts
+//
+//	update tokens
+//	    set updated_at=now(), attempts=attempts + 1
+//	where id = $1
+//	returning attempts
+func (q *Queries) IncrementTokenAttempts(ctx context.Context, db DBTX, id uuid.UUID) (int32, error) {
+	row := db.QueryRow(ctx, incrementTokenAttempts, id)
+	var attempts int32
+	err := row.Scan(&attempts)
+	return attempts, err
+}
+
 const insertToken = `-- name: InsertToken :one
 insert into
-    tokens (id, created_at, updated_at, scope, expires_at, hash, meta_data)
+    tokens (id, created_at, updated_at, scope, expires_at, hash, meta_data, user_id)
 values
-    ($1, now(), now(), $2, $3, $4, $5)
-returning id, created_at, updated_at, scope, expires_at, hash, meta_data
+    ($1, now(), now(), $2, $3, $4, $5, $6)
+returning id, created_at, updated_at, scope, expires_at, hash, meta_data, user_id, attempts
 `
 
 type InsertTokenParams struct {
@@ -52,15 +119,16 @@ type InsertTokenParams struct {
 	ExpiresAt pgtype.Timestamptz
 	Hash      string
 	MetaData  []byte
+	UserID    pgtype.UUID
 }
 
 // InsertToken
 //
 //	insert into
-//	    tokens (id, created_at, updated_at, scope, expires_at, hash, meta_data)
+//	    tokens (id, created_at, updated_at, scope, expires_at, hash, meta_data, user_id)
 //	values
-//	    ($1, now(), now(), $2, $3, $4, $5)
-//	returning id, created_at, updated_at, scope, expires_at, hash, meta_data
+//	    ($1, now(), now(), $2, $3, $4, $5, $6)
+//	returning id, created_at, updated_at, scope, expires_at, hash, meta_data, user_id, attempts
 func (q *Queries) InsertToken(ctx context.Context, db DBTX, arg InsertTokenParams) (Token, error) {
 	row := db.QueryRow(ctx, insertToken,
 		arg.ID,
@@ -68,6 +136,7 @@ func (q *Queries) InsertToken(ctx context.Context, db DBTX, arg InsertTokenParam
 		arg.ExpiresAt,
 		arg.Hash,
 		arg.MetaData,
+		arg.UserID,
 	)
 	var i Token
 	err := row.Scan(
@@ -78,12 +147,49 @@ func (q *Queries) InsertToken(ctx context.Context, db DBTX, arg InsertTokenParam
 		&i.ExpiresAt,
 		&i.Hash,
 		&i.MetaData,
+		&i.UserID,
+		&i.Attempts,
+	)
+	return i, err
+}
+
+const queryLatestTokenByScopeAndUserID = `-- name: QueryLatestTokenByScopeAndUserID :one
+select id, created_at, updated_at, scope, expires_at, hash, meta_data, user_id, attempts from tokens
+where scope=$1 and user_id=$2
+order by created_at desc
+limit 1
+`
+
+type QueryLatestTokenByScopeAndUserIDParams struct {
+	Scope  string
+	UserID pgtype.UUID
+}
+
+// QueryLatestTokenByScopeAndUserID
+//
+//	select id, created_at, updated_at, scope, expires_at, hash, meta_data, user_id, attempts from tokens
+//	where scope=$1 and user_id=$2
+//	order by created_at desc
+//	limit 1
+func (q *Queries) QueryLatestTokenByScopeAndUserID(ctx context.Context, db DBTX, arg QueryLatestTokenByScopeAndUserIDParams) (Token, error) {
+	row := db.QueryRow(ctx, queryLatestTokenByScopeAndUserID, arg.Scope, arg.UserID)
+	var i Token
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Scope,
+		&i.ExpiresAt,
+		&i.Hash,
+		&i.MetaData,
+		&i.UserID,
+		&i.Attempts,
 	)
 	return i, err
 }
 
 const queryPaginatedTokens = `-- name: QueryPaginatedTokens :many
-select id, created_at, updated_at, scope, expires_at, hash, meta_data from tokens
+select id, created_at, updated_at, scope, expires_at, hash, meta_data, user_id, attempts from tokens
 order by created_at desc
 limit $2::bigint offset $1::bigint
 `
@@ -95,7 +201,7 @@ type QueryPaginatedTokensParams struct {
 
 // QueryPaginatedTokens
 //
-//	select id, created_at, updated_at, scope, expires_at, hash, meta_data from tokens
+//	select id, created_at, updated_at, scope, expires_at, hash, meta_data, user_id, attempts from tokens
 //	order by created_at desc
 //	limit $2::bigint offset $1::bigint
 func (q *Queries) QueryPaginatedTokens(ctx context.Context, db DBTX, arg QueryPaginatedTokensParams) ([]Token, error) {
@@ -115,6 +221,8 @@ func (q *Queries) QueryPaginatedTokens(ctx context.Context, db DBTX, arg QueryPa
 			&i.ExpiresAt,
 			&i.Hash,
 			&i.MetaData,
+			&i.UserID,
+			&i.Attempts,
 		); err != nil {
 			return nil, err
 		}
@@ -127,12 +235,12 @@ func (q *Queries) QueryPaginatedTokens(ctx context.Context, db DBTX, arg QueryPa
 }
 
 const queryTokenByID = `-- name: QueryTokenByID :one
-select id, created_at, updated_at, scope, expires_at, hash, meta_data from tokens where id=$1
+select id, created_at, updated_at, scope, expires_at, hash, meta_data, user_id, attempts from tokens where id=$1
 `
 
 // QueryTokenByID
 //
-//	select id, created_at, updated_at, scope, expires_at, hash, meta_data from tokens where id=$1
+//	select id, created_at, updated_at, scope, expires_at, hash, meta_data, user_id, attempts from tokens where id=$1
 func (q *Queries) QueryTokenByID(ctx context.Context, db DBTX, id uuid.UUID) (Token, error) {
 	row := db.QueryRow(ctx, queryTokenByID, id)
 	var i Token
@@ -144,12 +252,14 @@ func (q *Queries) QueryTokenByID(ctx context.Context, db DBTX, id uuid.UUID) (To
 		&i.ExpiresAt,
 		&i.Hash,
 		&i.MetaData,
+		&i.UserID,
+		&i.Attempts,
 	)
 	return i, err
 }
 
 const queryTokenByScopeAndHash = `-- name: QueryTokenByScopeAndHash :one
-select id, created_at, updated_at, scope, expires_at, hash, meta_data from tokens where scope=$1 and hash=$2 limit 1
+select id, created_at, updated_at, scope, expires_at, hash, meta_data, user_id, attempts from tokens where scope=$1 and hash=$2 limit 1
 `
 
 type QueryTokenByScopeAndHashParams struct {
@@ -159,7 +269,7 @@ type QueryTokenByScopeAndHashParams struct {
 
 // QueryTokenByScopeAndHash
 //
-//	select id, created_at, updated_at, scope, expires_at, hash, meta_data from tokens where scope=$1 and hash=$2 limit 1
+//	select id, created_at, updated_at, scope, expires_at, hash, meta_data, user_id, attempts from tokens where scope=$1 and hash=$2 limit 1
 func (q *Queries) QueryTokenByScopeAndHash(ctx context.Context, db DBTX, arg QueryTokenByScopeAndHashParams) (Token, error) {
 	row := db.QueryRow(ctx, queryTokenByScopeAndHash, arg.Scope, arg.Hash)
 	var i Token
@@ -171,17 +281,19 @@ func (q *Queries) QueryTokenByScopeAndHash(ctx context.Context, db DBTX, arg Que
 		&i.ExpiresAt,
 		&i.Hash,
 		&i.MetaData,
+		&i.UserID,
+		&i.Attempts,
 	)
 	return i, err
 }
 
 const queryTokens = `-- name: QueryTokens :many
-select id, created_at, updated_at, scope, expires_at, hash, meta_data from tokens
+select id, created_at, updated_at, scope, expires_at, hash, meta_data, user_id, attempts from tokens
 `
 
 // QueryTokens
 //
-//	select id, created_at, updated_at, scope, expires_at, hash, meta_data from tokens
+//	select id, created_at, updated_at, scope, expires_at, hash, meta_data, user_id, attempts from tokens
 func (q *Queries) QueryTokens(ctx context.Context, db DBTX) ([]Token, error) {
 	rows, err := db.Query(ctx, queryTokens)
 	if err != nil {
@@ -199,6 +311,45 @@ func (q *Queries) QueryTokens(ctx context.Context, db DBTX) ([]Token, error) {
 			&i.ExpiresAt,
 			&i.Hash,
 			&i.MetaData,
+			&i.UserID,
+			&i.Attempts,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryTokensByUserID = `-- name: QueryTokensByUserID :many
+select id, created_at, updated_at, scope, expires_at, hash, meta_data, user_id, attempts from tokens where user_id=$1 order by created_at desc
+`
+
+// QueryTokensByUserID
+//
+//	select id, created_at, updated_at, scope, expires_at, hash, meta_data, user_id, attempts from tokens where user_id=$1 order by created_at desc
+func (q *Queries) QueryTokensByUserID(ctx context.Context, db DBTX, userID pgtype.UUID) ([]Token, error) {
+	rows, err := db.Query(ctx, queryTokensByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Token
+	for rows.Next() {
+		var i Token
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Scope,
+			&i.ExpiresAt,
+			&i.Hash,
+			&i.MetaData,
+			&i.UserID,
+			&i.Attempts,
 		); err != nil {
 			return nil, err
 		}
@@ -214,7 +365,7 @@ const updateToken = `-- name: UpdateToken :one
 update tokens
     set updated_at=now(), scope=$2, expires_at=$3, hash=$4, meta_data=$5
 where id = $1
-returning id, created_at, updated_at, scope, expires_at, hash, meta_data
+returning id, created_at, updated_at, scope, expires_at, hash, meta_data, user_id, attempts
 `
 
 type UpdateTokenParams struct {
@@ -230,7 +381,7 @@ type UpdateTokenParams struct {
 //	update tokens
 //	    set updated_at=now(), scope=$2, expires_at=$3, hash=$4, meta_data=$5
 //	where id = $1
-//	returning id, created_at, updated_at, scope, expires_at, hash, meta_data
+//	returning id, created_at, updated_at, scope, expires_at, hash, meta_data, user_id, attempts
 func (q *Queries) UpdateToken(ctx context.Context, db DBTX, arg UpdateTokenParams) (Token, error) {
 	row := db.QueryRow(ctx, updateToken,
 		arg.ID,
@@ -248,6 +399,8 @@ func (q *Queries) UpdateToken(ctx context.Context, db DBTX, arg UpdateTokenParam
 		&i.ExpiresAt,
 		&i.Hash,
 		&i.MetaData,
+		&i.UserID,
+		&i.Attempts,
 	)
 	return i, err
 }
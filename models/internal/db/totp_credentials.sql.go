@@ -0,0 +1,161 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: totp_credentials.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const confirmTotpCredential = `-- name: ConfirmTotpCredential :one
+update totp_credentials
+    set updated_at=now(), confirmed_at=now(), last_used_step=$2
+where id = $1
+returning id, created_at, updated_at, user_id, secret, confirmed_at, last_used_step
+`
+
+type ConfirmTotpCredentialParams struct {
+	ID           uuid.UUID
+	LastUsedStep int64
+}
+
+// ConfirmTotpCredential
+//
+//	update totp_credentials
+//	    set updated_at=now(), confirmed_at=now(), last_used_step=$2
+//	where id = $1
+//	returning id, created_at, updated_at, user_id, secret, confirmed_at, last_used_step
+func (q *Queries) ConfirmTotpCredential(ctx context.Context, db DBTX, arg ConfirmTotpCredentialParams) (TotpCredential, error) {
+	row := db.QueryRow(ctx, confirmTotpCredential, arg.ID, arg.LastUsedStep)
+	var i TotpCredential
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Secret,
+		&i.ConfirmedAt,
+		&i.LastUsedStep,
+	)
+	return i, err
+}
+
+const deleteTotpCredentialByUserID = `-- name: DeleteTotpCredentialByUserID :exec
+delete from totp_credentials where user_id=$1
+`
+
+// DeleteTotpCredentialByUserID
+//
+//	delete from totp_credentials where user_id=$1
+func (q *Queries) DeleteTotpCredentialByUserID(ctx context.Context, db DBTX, userID uuid.UUID) error {
+	_, err := db.Exec(ctx, deleteTotpCredentialByUserID, userID)
+	return err
+}
+
+const queryTotpCredentialByUserID = `-- name: QueryTotpCredentialByUserID :one
+select id, created_at, updated_at, user_id, secret, confirmed_at, last_used_step from totp_credentials where user_id=$1
+`
+
+// QueryTotpCredentialByUserID
+//
+//	select id, created_at, updated_at, user_id, secret, confirmed_at, last_used_step from totp_credentials where user_id=$1
+func (q *Queries) QueryTotpCredentialByUserID(ctx context.Context, db DBTX, userID uuid.UUID) (TotpCredential, error) {
+	row := db.QueryRow(ctx, queryTotpCredentialByUserID, userID)
+	var i TotpCredential
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Secret,
+		&i.ConfirmedAt,
+		&i.LastUsedStep,
+	)
+	return i, err
+}
+
+const updateTotpCredentialLastUsedStep = `-- name: UpdateTotpCredentialLastUsedStep :exec
+update totp_credentials
+    set updated_at=now(), last_used_step=$2
+where id = $1
+`
+
+type UpdateTotpCredentialLastUsedStepParams struct {
+	ID           uuid.UUID
+	LastUsedStep int64
+}
+
+// UpdateTotpCredentialLastUsedStep
+//
+//	update totp_credentials
+//	    set updated_at=now(), last_used_step=$2
+//	where id = $1
+func (q *Queries) UpdateTotpCredentialLastUsedStep(ctx context.Context, db DBTX, arg UpdateTotpCredentialLastUsedStepParams) error {
+	_, err := db.Exec(ctx, updateTotpCredentialLastUsedStep, arg.ID, arg.LastUsedStep)
+	return err
+}
+
+const updateTotpCredentialSecret = `-- name: UpdateTotpCredentialSecret :exec
+update totp_credentials
+    set updated_at=now(), secret=$2
+where id = $1
+`
+
+type UpdateTotpCredentialSecretParams struct {
+	ID     uuid.UUID
+	Secret string
+}
+
+// UpdateTotpCredentialSecret
+//
+//	update totp_credentials
+//	    set updated_at=now(), secret=$2
+//	where id = $1
+func (q *Queries) UpdateTotpCredentialSecret(ctx context.Context, db DBTX, arg UpdateTotpCredentialSecretParams) error {
+	_, err := db.Exec(ctx, updateTotpCredentialSecret, arg.ID, arg.Secret)
+	return err
+}
+
+const upsertTotpCredential = `-- name: UpsertTotpCredential :one
+insert into
+    totp_credentials (id, created_at, updated_at, user_id, secret, confirmed_at, last_used_step)
+values
+    ($1, now(), now(), $2, $3, null, 0)
+on conflict (user_id) do update
+    set updated_at=now(), secret=excluded.secret, confirmed_at=null, last_used_step=0
+returning id, created_at, updated_at, user_id, secret, confirmed_at, last_used_step
+`
+
+type UpsertTotpCredentialParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	Secret string
+}
+
+// UpsertTotpCredential
+//
+//	insert into
+//	    totp_credentials (id, created_at, updated_at, user_id, secret, confirmed_at, last_used_step)
+//	values
+//	    ($1, now(), now(), $2, $3, null, 0)
+//	on conflict (user_id) do update
+//	    set updated_at=now(), secret=excluded.secret, confirmed_at=null, last_used_step=0
+//	returning id, created_at, updated_at, user_id, secret, confirmed_at, last_used_step
+func (q *Queries) UpsertTotpCredential(ctx context.Context, db DBTX, arg UpsertTotpCredentialParams) (TotpCredential, error) {
+	row := db.QueryRow(ctx, upsertTotpCredential, arg.ID, arg.UserID, arg.Secret)
+	var i TotpCredential
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Secret,
+		&i.ConfirmedAt,
+		&i.LastUsedStep,
+	)
+	return i, err
+}
@@ -14,13 +14,15 @@ import (
 
 const countUsers = `-- name: CountUsers :one
 select count(*) from users
+where $1::text = '' or email ilike '%' || $1::text || '%'
 `
 
 // CountUsers
 //
 //	select count(*) from users
-func (q *Queries) CountUsers(ctx context.Context, db DBTX) (int64, error) {
-	row := db.QueryRow(ctx, countUsers)
+//	where $1::text = '' or email ilike '%' || $1::text || '%'
+func (q *Queries) CountUsers(ctx context.Context, db DBTX, search string) (int64, error) {
+	row := db.QueryRow(ctx, countUsers, search)
 	var count int64
 	err := row.Scan(&count)
 	return count, err
@@ -43,7 +45,7 @@ insert into
     users (id, created_at, updated_at, email, email_validated_at, password, is_admin)
 values
     ($1, now(), now(), $2, $3, $4, $5)
-returning id, created_at, updated_at, email, email_validated_at, password, is_admin
+returning id, created_at, updated_at, email, email_validated_at, password, is_admin, deletion_scheduled_at
 `
 
 type InsertUserParams struct {
@@ -60,7 +62,7 @@ type InsertUserParams struct {
 //	    users (id, created_at, updated_at, email, email_validated_at, password, is_admin)
 //	values
 //	    ($1, now(), now(), $2, $3, $4, $5)
-//	returning id, created_at, updated_at, email, email_validated_at, password, is_admin
+//	returning id, created_at, updated_at, email, email_validated_at, password, is_admin, deletion_scheduled_at
 func (q *Queries) InsertUser(ctx context.Context, db DBTX, arg InsertUserParams) (User, error) {
 	row := db.QueryRow(ctx, insertUser,
 		arg.ID,
@@ -78,28 +80,32 @@ func (q *Queries) InsertUser(ctx context.Context, db DBTX, arg InsertUserParams)
 		&i.EmailValidatedAt,
 		&i.Password,
 		&i.IsAdmin,
+		&i.DeletionScheduledAt,
 	)
 	return i, err
 }
 
 const queryPaginatedUsers = `-- name: QueryPaginatedUsers :many
-select id, created_at, updated_at, email, email_validated_at, password, is_admin from users
+select id, created_at, updated_at, email, email_validated_at, password, is_admin, deletion_scheduled_at from users
+where $1::text = '' or email ilike '%' || $1::text || '%'
 order by created_at desc
-limit $2::bigint offset $1::bigint
+limit $3::bigint offset $2::bigint
 `
 
 type QueryPaginatedUsersParams struct {
+	Search string
 	Offset int64
 	Limit  int64
 }
 
 // QueryPaginatedUsers
 //
-//	select id, created_at, updated_at, email, email_validated_at, password, is_admin from users
+//	select id, created_at, updated_at, email, email_validated_at, password, is_admin, deletion_scheduled_at from users
+//	where $1::text = '' or email ilike '%' || $1::text || '%'
 //	order by created_at desc
-//	limit $2::bigint offset $1::bigint
+//	limit $3::bigint offset $2::bigint
 func (q *Queries) QueryPaginatedUsers(ctx context.Context, db DBTX, arg QueryPaginatedUsersParams) ([]User, error) {
-	rows, err := db.Query(ctx, queryPaginatedUsers, arg.Offset, arg.Limit)
+	rows, err := db.Query(ctx, queryPaginatedUsers, arg.Search, arg.Offset, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
@@ -115,6 +121,80 @@ func (q *Queries) QueryPaginatedUsers(ctx context.Context, db DBTX, arg QueryPag
 			&i.EmailValidatedAt,
 			&i.Password,
 			&i.IsAdmin,
+			&i.DeletionScheduledAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryUnverifiedUsersCreatedBefore = `-- name: QueryUnverifiedUsersCreatedBefore :many
+select id, created_at, updated_at, email, email_validated_at, password, is_admin, deletion_scheduled_at from users
+where users.email_validated_at is null
+    and not users.is_admin
+    and users.created_at < $1
+    and not exists (
+        select 1 from organization_memberships owned
+        where owned.user_id = users.id
+            and owned.role = 'owner'
+            and not exists (
+                select 1 from organization_memberships others
+                where others.organization_id = owned.organization_id
+                    and others.role = 'owner'
+                    and others.user_id <> owned.user_id
+            )
+    )
+order by users.created_at
+limit $2::bigint
+`
+
+type QueryUnverifiedUsersCreatedBeforeParams struct {
+	CreatedBefore pgtype.Timestamptz
+	Limit         int64
+}
+
+// QueryUnverifiedUsersCreatedBefore
+//
+//	select id, created_at, updated_at, email, email_validated_at, password, is_admin, deletion_scheduled_at from users
+//	where users.email_validated_at is null
+//	    and not users.is_admin
+//	    and users.created_at < $1
+//	    and not exists (
+//	        select 1 from organization_memberships owned
+//	        where owned.user_id = users.id
+//	            and owned.role = 'owner'
+//	            and not exists (
+//	                select 1 from organization_memberships others
+//	                where others.organization_id = owned.organization_id
+//	                    and others.role = 'owner'
+//	                    and others.user_id <> owned.user_id
+//	            )
+//	    )
+//	order by users.created_at
+//	limit $2::bigint
+func (q *Queries) QueryUnverifiedUsersCreatedBefore(ctx context.Context, db DBTX, arg QueryUnverifiedUsersCreatedBeforeParams) ([]User, error) {
+	rows, err := db.Query(ctx, queryUnverifiedUsersCreatedBefore, arg.CreatedBefore, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.EmailValidatedAt,
+			&i.Password,
+			&i.IsAdmin,
+			&i.DeletionScheduledAt,
 		); err != nil {
 			return nil, err
 		}
@@ -127,12 +207,12 @@ func (q *Queries) QueryPaginatedUsers(ctx context.Context, db DBTX, arg QueryPag
 }
 
 const queryUserByEmail = `-- name: QueryUserByEmail :one
-select id, created_at, updated_at, email, email_validated_at, password, is_admin from users where email=$1
+select id, created_at, updated_at, email, email_validated_at, password, is_admin, deletion_scheduled_at from users where email=$1
 `
 
 // QueryUserByEmail
 //
-//	select id, created_at, updated_at, email, email_validated_at, password, is_admin from users where email=$1
+//	select id, created_at, updated_at, email, email_validated_at, password, is_admin, deletion_scheduled_at from users where email=$1
 func (q *Queries) QueryUserByEmail(ctx context.Context, db DBTX, email string) (User, error) {
 	row := db.QueryRow(ctx, queryUserByEmail, email)
 	var i User
@@ -144,17 +224,18 @@ func (q *Queries) QueryUserByEmail(ctx context.Context, db DBTX, email string) (
 		&i.EmailValidatedAt,
 		&i.Password,
 		&i.IsAdmin,
+		&i.DeletionScheduledAt,
 	)
 	return i, err
 }
 
 const queryUserByID = `-- name: QueryUserByID :one
-select id, created_at, updated_at, email, email_validated_at, password, is_admin from users where id=$1
+select id, created_at, updated_at, email, email_validated_at, password, is_admin, deletion_scheduled_at from users where id=$1
 `
 
 // QueryUserByID
 //
-//	select id, created_at, updated_at, email, email_validated_at, password, is_admin from users where id=$1
+//	select id, created_at, updated_at, email, email_validated_at, password, is_admin, deletion_scheduled_at from users where id=$1
 func (q *Queries) QueryUserByID(ctx context.Context, db DBTX, id uuid.UUID) (User, error) {
 	row := db.QueryRow(ctx, queryUserByID, id)
 	var i User
@@ -166,17 +247,18 @@ func (q *Queries) QueryUserByID(ctx context.Context, db DBTX, id uuid.UUID) (Use
 		&i.EmailValidatedAt,
 		&i.Password,
 		&i.IsAdmin,
+		&i.DeletionScheduledAt,
 	)
 	return i, err
 }
 
 const queryUsers = `-- name: QueryUsers :many
-select id, created_at, updated_at, email, email_validated_at, password, is_admin from users
+select id, created_at, updated_at, email, email_validated_at, password, is_admin, deletion_scheduled_at from users
 `
 
 // QueryUsers
 //
-//	select id, created_at, updated_at, email, email_validated_at, password, is_admin from users
+//	select id, created_at, updated_at, email, email_validated_at, password, is_admin, deletion_scheduled_at from users
 func (q *Queries) QueryUsers(ctx context.Context, db DBTX) ([]User, error) {
 	rows, err := db.Query(ctx, queryUsers)
 	if err != nil {
@@ -194,6 +276,54 @@ func (q *Queries) QueryUsers(ctx context.Context, db DBTX) ([]User, error) {
 			&i.EmailValidatedAt,
 			&i.Password,
 			&i.IsAdmin,
+			&i.DeletionScheduledAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryUsersDueForDeletion = `-- name: QueryUsersDueForDeletion :many
+select id, created_at, updated_at, email, email_validated_at, password, is_admin, deletion_scheduled_at from users
+where deletion_scheduled_at <= now()
+order by deletion_scheduled_at, id
+limit $2::bigint offset $1::bigint
+`
+
+type QueryUsersDueForDeletionParams struct {
+	Offset int64
+	Limit  int64
+}
+
+// QueryUsersDueForDeletion
+//
+//	select id, created_at, updated_at, email, email_validated_at, password, is_admin, deletion_scheduled_at from users
+//	where deletion_scheduled_at <= now()
+//	order by deletion_scheduled_at, id
+//	limit $2::bigint offset $1::bigint
+func (q *Queries) QueryUsersDueForDeletion(ctx context.Context, db DBTX, arg QueryUsersDueForDeletionParams) ([]User, error) {
+	rows, err := db.Query(ctx, queryUsersDueForDeletion, arg.Offset, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.EmailValidatedAt,
+			&i.Password,
+			&i.IsAdmin,
+			&i.DeletionScheduledAt,
 		); err != nil {
 			return nil, err
 		}
@@ -209,7 +339,7 @@ const updateUser = `-- name: UpdateUser :one
 update users
     set updated_at=now(), email=$2, email_validated_at=$3, password=$4, is_admin=$5
 where id = $1
-returning id, created_at, updated_at, email, email_validated_at, password, is_admin
+returning id, created_at, updated_at, email, email_validated_at, password, is_admin, deletion_scheduled_at
 `
 
 type UpdateUserParams struct {
@@ -225,7 +355,7 @@ type UpdateUserParams struct {
 //	update users
 //	    set updated_at=now(), email=$2, email_validated_at=$3, password=$4, is_admin=$5
 //	where id = $1
-//	returning id, created_at, updated_at, email, email_validated_at, password, is_admin
+//	returning id, created_at, updated_at, email, email_validated_at, password, is_admin, deletion_scheduled_at
 func (q *Queries) UpdateUser(ctx context.Context, db DBTX, arg UpdateUserParams) (User, error) {
 	row := db.QueryRow(ctx, updateUser,
 		arg.ID,
@@ -243,6 +373,41 @@ func (q *Queries) UpdateUser(ctx context.Context, db DBTX, arg UpdateUserParams)
 		&i.EmailValidatedAt,
 		&i.Password,
 		&i.IsAdmin,
+		&i.DeletionScheduledAt,
+	)
+	return i, err
+}
+
+const updateUserDeletionScheduledAt = `-- name: UpdateUserDeletionScheduledAt :one
+update users
+    set updated_at=now(), deletion_scheduled_at=$2
+where id = $1
+returning id, created_at, updated_at, email, email_validated_at, password, is_admin, deletion_scheduled_at
+`
+
+type UpdateUserDeletionScheduledAtParams struct {
+	ID                  uuid.UUID
+	DeletionScheduledAt pgtype.Timestamptz
+}
+
+// UpdateUserDeletionScheduledAt
+//
+//	update users
+//	    set updated_at=now(), deletion_scheduled_at=$2
+//	where id = $1
+//	returning id, created_at, updated_at, email, email_validated_at, password, is_admin, deletion_scheduled_at
+func (q *Queries) UpdateUserDeletionScheduledAt(ctx context.Context, db DBTX, arg UpdateUserDeletionScheduledAtParams) (User, error) {
+	row := db.QueryRow(ctx, updateUserDeletionScheduledAt, arg.ID, arg.DeletionScheduledAt)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.EmailValidatedAt,
+		&i.Password,
+		&i.IsAdmin,
+		&i.DeletionScheduledAt,
 	)
 	return i, err
 }
@@ -0,0 +1,154 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: webauthn_credentials.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const deleteWebauthnCredentialByIDAndUserID = `-- name: DeleteWebauthnCredentialByIDAndUserID :exec
+delete from webauthn_credentials where id=$1 and user_id=$2
+`
+
+type DeleteWebauthnCredentialByIDAndUserIDParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+// DeleteWebauthnCredentialByIDAndUserID
+//
+//	delete from webauthn_credentials where id=$1 and user_id=$2
+func (q *Queries) DeleteWebauthnCredentialByIDAndUserID(ctx context.Context, db DBTX, arg DeleteWebauthnCredentialByIDAndUserIDParams) error {
+	_, err := db.Exec(ctx, deleteWebauthnCredentialByIDAndUserID, arg.ID, arg.UserID)
+	return err
+}
+
+const insertWebauthnCredential = `-- name: InsertWebauthnCredential :one
+insert into
+    webauthn_credentials (id, created_at, updated_at, user_id, credential_id, name, credential)
+values
+    ($1, now(), now(), $2, $3, $4, $5)
+returning id, created_at, updated_at, user_id, credential_id, name, credential, last_used_at
+`
+
+type InsertWebauthnCredentialParams struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	CredentialID []byte
+	Name         string
+	Credential   []byte
+}
+
+// InsertWebauthnCredential
+//
+//	insert into
+//	    webauthn_credentials (id, created_at, updated_at, user_id, credential_id, name, credential)
+//	values
+//	    ($1, now(), now(), $2, $3, $4, $5)
+//	returning id, created_at, updated_at, user_id, credential_id, name, credential, last_used_at
+func (q *Queries) InsertWebauthnCredential(ctx context.Context, db DBTX, arg InsertWebauthnCredentialParams) (WebauthnCredential, error) {
+	row := db.QueryRow(ctx, insertWebauthnCredential,
+		arg.ID,
+		arg.UserID,
+		arg.CredentialID,
+		arg.Name,
+		arg.Credential,
+	)
+	var i WebauthnCredential
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.CredentialID,
+		&i.Name,
+		&i.Credential,
+		&i.LastUsedAt,
+	)
+	return i, err
+}
+
+const queryWebauthnCredentialByCredentialID = `-- name: QueryWebauthnCredentialByCredentialID :one
+select id, created_at, updated_at, user_id, credential_id, name, credential, last_used_at from webauthn_credentials where credential_id=$1
+`
+
+// QueryWebauthnCredentialByCredentialID
+//
+//	select id, created_at, updated_at, user_id, credential_id, name, credential, last_used_at from webauthn_credentials where credential_id=$1
+func (q *Queries) QueryWebauthnCredentialByCredentialID(ctx context.Context, db DBTX, credentialID []byte) (WebauthnCredential, error) {
+	row := db.QueryRow(ctx, queryWebauthnCredentialByCredentialID, credentialID)
+	var i WebauthnCredential
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.CredentialID,
+		&i.Name,
+		&i.Credential,
+		&i.LastUsedAt,
+	)
+	return i, err
+}
+
+const queryWebauthnCredentialsByUserID = `-- name: QueryWebauthnCredentialsByUserID :many
+select id, created_at, updated_at, user_id, credential_id, name, credential, last_used_at from webauthn_credentials where user_id=$1 order by created_at
+`
+
+// QueryWebauthnCredentialsByUserID
+//
+//	select id, created_at, updated_at, user_id, credential_id, name, credential, last_used_at from webauthn_credentials where user_id=$1 order by created_at
+func (q *Queries) QueryWebauthnCredentialsByUserID(ctx context.Context, db DBTX, userID uuid.UUID) ([]WebauthnCredential, error) {
+	rows, err := db.Query(ctx, queryWebauthnCredentialsByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebauthnCredential
+	for rows.Next() {
+		var i WebauthnCredential
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.CredentialID,
+			&i.Name,
+			&i.Credential,
+			&i.LastUsedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateWebauthnCredentialAfterLogin = `-- name: UpdateWebauthnCredentialAfterLogin :exec
+update webauthn_credentials
+    set updated_at=now(), last_used_at=now(), credential=$2
+where id = $1
+`
+
+type UpdateWebauthnCredentialAfterLoginParams struct {
+	ID         uuid.UUID
+	Credential []byte
+}
+
+// UpdateWebauthnCredentialAfterLogin
+//
+//	update webauthn_credentials
+//	    set updated_at=now(), last_used_at=now(), credential=$2
+//	where id = $1
+func (q *Queries) UpdateWebauthnCredentialAfterLogin(ctx context.Context, db DBTX, arg UpdateWebauthnCredentialAfterLoginParams) error {
+	_, err := db.Exec(ctx, updateWebauthnCredentialAfterLogin, arg.ID, arg.Credential)
+	return err
+}
@@ -2,6 +2,8 @@
 package models
 
 import (
+	"strings"
+
 	"mbvlabs/models/internal/db"
 
 	"github.com/go-playground/validator/v10"
@@ -16,3 +18,11 @@ func setupValidator() *validator.Validate {
 	v := validator.New(validator.WithRequiredStructEnabled())
 	return v
 }
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike makes search match literally inside a LIKE or ILIKE pattern,
+// whose default escape character is the backslash.
+func escapeLike(search string) string {
+	return likeEscaper.Replace(search)
+}
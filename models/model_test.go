@@ -0,0 +1,23 @@
+package models
+
+import "testing"
+
+func TestEscapeLike(t *testing.T) {
+	tests := []struct {
+		search string
+		want   string
+	}{
+		{search: "", want: ""},
+		{search: "jane@example.com", want: "jane@example.com"},
+		{search: "100%", want: `100\%`},
+		{search: "first_last", want: `first\_last`},
+		{search: `back\slash`, want: `back\\slash`},
+		{search: `\%_`, want: `\\\%\_`},
+	}
+
+	for _, tt := range tests {
+		if got := escapeLike(tt.search); got != tt.want {
+			t.Errorf("escapeLike(%q) = %q, want %q", tt.search, got, tt.want)
+		}
+	}
+}
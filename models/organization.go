@@ -0,0 +1,278 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models/internal/db"
+)
+
+// OrganizationRole is what a member may do within one organization. Roles
+// are per organization and unrelated to the site wide roles of a user.
+type OrganizationRole string
+
+const (
+	OrganizationRoleOwner  OrganizationRole = "owner"
+	OrganizationRoleAdmin  OrganizationRole = "admin"
+	OrganizationRoleMember OrganizationRole = "member"
+)
+
+func (r OrganizationRole) Valid() bool {
+	switch r {
+	case OrganizationRoleOwner, OrganizationRoleAdmin, OrganizationRoleMember:
+		return true
+	default:
+		return false
+	}
+}
+
+// CanManageMembers reports whether the role may invite, remove and change
+// the role of admins and members.
+func (r OrganizationRole) CanManageMembers() bool {
+	return r == OrganizationRoleOwner || r == OrganizationRoleAdmin
+}
+
+// CanManageOwners reports whether the role may grant or take away
+// ownership.
+func (r OrganizationRole) CanManageOwners() bool {
+	return r == OrganizationRoleOwner
+}
+
+type Organization struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Name      string
+}
+
+func FindOrganization(
+	ctx context.Context,
+	exec storage.Executor,
+	id uuid.UUID,
+) (Organization, error) {
+	row, err := queries.QueryOrganizationByID(ctx, exec, id)
+	if err != nil {
+		return Organization{}, err
+	}
+
+	return rowToOrganization(row), nil
+}
+
+type CreateOrganizationData struct {
+	Name string `validate:"required,max=100"`
+}
+
+func CreateOrganization(
+	ctx context.Context,
+	exec storage.Executor,
+	data CreateOrganizationData,
+) (Organization, error) {
+	if err := validate.Struct(data); err != nil {
+		return Organization{}, errors.Join(ErrDomainValidation, err)
+	}
+
+	row, err := queries.InsertOrganization(ctx, exec, db.InsertOrganizationParams{
+		ID:   uuid.New(),
+		Name: data.Name,
+	})
+	if err != nil {
+		return Organization{}, err
+	}
+
+	return rowToOrganization(row), nil
+}
+
+// LockOrganization holds a row lock on the organization until exec's
+// transaction ends. Changes that must not leave it without an owner take it
+// first, so two of them cannot each see the other owner as still there.
+func LockOrganization(
+	ctx context.Context,
+	exec storage.Executor,
+	id uuid.UUID,
+) error {
+	return queries.LockOrganization(ctx, exec, id)
+}
+
+func rowToOrganization(row db.Organization) Organization {
+	return Organization{
+		ID:        row.ID,
+		CreatedAt: row.CreatedAt.Time,
+		UpdatedAt: row.UpdatedAt.Time,
+		Name:      row.Name,
+	}
+}
+
+// OrganizationMembership is a user's place in an organization, seen from
+// the user's side.
+type OrganizationMembership struct {
+	OrganizationID   uuid.UUID
+	OrganizationName string
+	UserID           uuid.UUID
+	Role             OrganizationRole
+	CreatedAt        time.Time
+}
+
+func FindOrganizationMembershipsByUserID(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+) ([]OrganizationMembership, error) {
+	rows, err := queries.QueryOrganizationMembershipsByUserID(ctx, exec, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	memberships := make([]OrganizationMembership, len(rows))
+	for i, row := range rows {
+		memberships[i] = OrganizationMembership{
+			OrganizationID:   row.OrganizationID,
+			OrganizationName: row.OrganizationName,
+			UserID:           row.UserID,
+			Role:             OrganizationRole(row.Role),
+			CreatedAt:        row.CreatedAt.Time,
+		}
+	}
+
+	return memberships, nil
+}
+
+func FindOrganizationMembership(
+	ctx context.Context,
+	exec storage.Executor,
+	organizationID uuid.UUID,
+	userID uuid.UUID,
+) (OrganizationMembership, error) {
+	row, err := queries.QueryOrganizationMembership(ctx, exec, db.QueryOrganizationMembershipParams{
+		OrganizationID: organizationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		return OrganizationMembership{}, err
+	}
+
+	return OrganizationMembership{
+		OrganizationID:   row.OrganizationID,
+		OrganizationName: row.OrganizationName,
+		UserID:           row.UserID,
+		Role:             OrganizationRole(row.Role),
+		CreatedAt:        row.CreatedAt.Time,
+	}, nil
+}
+
+// OrganizationMember is a membership seen from the organization's side.
+type OrganizationMember struct {
+	UserID    uuid.UUID
+	Email     string
+	Role      OrganizationRole
+	CreatedAt time.Time
+}
+
+func FindOrganizationMembers(
+	ctx context.Context,
+	exec storage.Executor,
+	organizationID uuid.UUID,
+) ([]OrganizationMember, error) {
+	rows, err := queries.QueryOrganizationMembers(ctx, exec, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]OrganizationMember, len(rows))
+	for i, row := range rows {
+		members[i] = OrganizationMember{
+			UserID:    row.UserID,
+			Email:     row.Email,
+			Role:      OrganizationRole(row.Role),
+			CreatedAt: row.CreatedAt.Time,
+		}
+	}
+
+	return members, nil
+}
+
+type CreateOrganizationMembershipData struct {
+	OrganizationID uuid.UUID        `validate:"required"`
+	UserID         uuid.UUID        `validate:"required"`
+	Role           OrganizationRole `validate:"required,oneof=owner admin member"`
+}
+
+// CreateOrganizationMembership adds the user to the organization. A user
+// who already is a member keeps their current role.
+func CreateOrganizationMembership(
+	ctx context.Context,
+	exec storage.Executor,
+	data CreateOrganizationMembershipData,
+) error {
+	if err := validate.Struct(data); err != nil {
+		return errors.Join(ErrDomainValidation, err)
+	}
+
+	return queries.InsertOrganizationMembership(ctx, exec, db.InsertOrganizationMembershipParams{
+		OrganizationID: data.OrganizationID,
+		UserID:         data.UserID,
+		Role:           string(data.Role),
+	})
+}
+
+// UpdateOrganizationMembershipRole returns sql.ErrNoRows if the user is not
+// a member of the organization.
+func UpdateOrganizationMembershipRole(
+	ctx context.Context,
+	exec storage.Executor,
+	organizationID uuid.UUID,
+	userID uuid.UUID,
+	role OrganizationRole,
+) error {
+	if !role.Valid() {
+		return errors.Join(ErrDomainValidation, errors.New("invalid organization role"))
+	}
+
+	updated, err := queries.UpdateOrganizationMembershipRole(ctx, exec, db.UpdateOrganizationMembershipRoleParams{
+		OrganizationID: organizationID,
+		UserID:         userID,
+		Role:           string(role),
+	})
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// DestroyOrganizationMembership returns sql.ErrNoRows if the user is not a
+// member of the organization.
+func DestroyOrganizationMembership(
+	ctx context.Context,
+	exec storage.Executor,
+	organizationID uuid.UUID,
+	userID uuid.UUID,
+) error {
+	deleted, err := queries.DeleteOrganizationMembership(ctx, exec, db.DeleteOrganizationMembershipParams{
+		OrganizationID: organizationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func CountOrganizationOwners(
+	ctx context.Context,
+	exec storage.Executor,
+	organizationID uuid.UUID,
+) (int64, error) {
+	return queries.CountOrganizationOwners(ctx, exec, organizationID)
+}
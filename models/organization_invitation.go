@@ -0,0 +1,167 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models/internal/db"
+)
+
+// OrganizationInvitation asks whoever owns Email to join an organization.
+// It is accepted through a secret link; only an HMAC of the secret is
+// stored.
+type OrganizationInvitation struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	OrganizationID uuid.UUID
+	// InvitedByID is the zero UUID once the inviting user is deleted.
+	InvitedByID uuid.UUID
+	Email       string
+	Role        OrganizationRole
+	Hash        string
+	ExpiresAt   time.Time
+}
+
+func (i OrganizationInvitation) IsExpired() bool {
+	return time.Now().After(i.ExpiresAt)
+}
+
+func FindOrganizationInvitationBySecret(
+	ctx context.Context,
+	exec storage.Executor,
+	pepper string,
+	secret string,
+) (OrganizationInvitation, error) {
+	row, err := findByStorageHash(secret, pepper, func(hash string) (db.OrganizationInvitation, error) {
+		return queries.QueryOrganizationInvitationByHash(ctx, exec, hash)
+	})
+	if err != nil {
+		return OrganizationInvitation{}, err
+	}
+
+	return rowToOrganizationInvitation(row), nil
+}
+
+// FindPendingOrganizationInvitations returns the organization's invitations
+// that have not expired, newest first.
+func FindPendingOrganizationInvitations(
+	ctx context.Context,
+	exec storage.Executor,
+	organizationID uuid.UUID,
+) ([]OrganizationInvitation, error) {
+	rows, err := queries.QueryPendingOrganizationInvitations(ctx, exec, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	invitations := make([]OrganizationInvitation, len(rows))
+	for i, row := range rows {
+		invitations[i] = rowToOrganizationInvitation(row)
+	}
+
+	return invitations, nil
+}
+
+type CreateOrganizationInvitationData struct {
+	OrganizationID uuid.UUID        `validate:"required"`
+	InvitedByID    uuid.UUID        `validate:"required"`
+	Email          string           `validate:"required,email,max=255"`
+	Role           OrganizationRole `validate:"required,oneof=admin member"`
+	ExpiresAt      time.Time        `validate:"required"`
+}
+
+// CreateOrganizationInvitation replaces any earlier invitation for the same
+// address and returns the secret for the new link, which cannot be
+// recovered later.
+func CreateOrganizationInvitation(
+	ctx context.Context,
+	exec storage.Executor,
+	pepper string,
+	data CreateOrganizationInvitationData,
+) (OrganizationInvitation, string, error) {
+	if err := validate.Struct(data); err != nil {
+		return OrganizationInvitation{}, "", errors.Join(ErrDomainValidation, err)
+	}
+
+	address := strings.ToLower(data.Email)
+
+	if err := queries.DeleteOrganizationInvitationByEmail(ctx, exec, db.DeleteOrganizationInvitationByEmailParams{
+		OrganizationID: data.OrganizationID,
+		Email:          address,
+	}); err != nil {
+		return OrganizationInvitation{}, "", err
+	}
+
+	secret, err := GenerateSecureToken()
+	if err != nil {
+		return OrganizationInvitation{}, "", err
+	}
+
+	row, err := queries.InsertOrganizationInvitation(ctx, exec, db.InsertOrganizationInvitationParams{
+		ID:             uuid.New(),
+		OrganizationID: data.OrganizationID,
+		InvitedByID: pgtype.UUID{
+			Bytes: data.InvitedByID,
+			Valid: true,
+		},
+		Email: address,
+		Role:  string(data.Role),
+		Hash:  HashForStorage(secret, pepper),
+		ExpiresAt: pgtype.Timestamptz{
+			Time:  data.ExpiresAt,
+			Valid: true,
+		},
+	})
+	if err != nil {
+		return OrganizationInvitation{}, "", err
+	}
+
+	return rowToOrganizationInvitation(row), secret, nil
+}
+
+// DestroyOrganizationInvitation returns sql.ErrNoRows if the invitation does
+// not belong to the organization.
+func DestroyOrganizationInvitation(
+	ctx context.Context,
+	exec storage.Executor,
+	organizationID uuid.UUID,
+	id uuid.UUID,
+) error {
+	deleted, err := queries.DeleteOrganizationInvitation(ctx, exec, db.DeleteOrganizationInvitationParams{
+		ID:             id,
+		OrganizationID: organizationID,
+	})
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func rowToOrganizationInvitation(row db.OrganizationInvitation) OrganizationInvitation {
+	var invitedByID uuid.UUID
+	if row.InvitedByID.Valid {
+		invitedByID = row.InvitedByID.Bytes
+	}
+
+	return OrganizationInvitation{
+		ID:             row.ID,
+		CreatedAt:      row.CreatedAt.Time,
+		OrganizationID: row.OrganizationID,
+		InvitedByID:    invitedByID,
+		Email:          row.Email,
+		Role:           OrganizationRole(row.Role),
+		Hash:           row.Hash,
+		ExpiresAt:      row.ExpiresAt.Time,
+	}
+}
@@ -0,0 +1,319 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var (
+	ErrInvalidPasswordHash = errors.New("invalid stored password format")
+	ErrUnknownPepper       = errors.New("password hash uses an unknown pepper")
+)
+
+// Argon2Params are the argon2id cost settings. Memory is in KiB.
+type Argon2Params struct {
+	Time       uint32
+	Memory     uint32
+	Threads    uint8
+	KeyLength  uint32
+	SaltLength uint32
+}
+
+// weakerThan reports whether hashes made with p are cheaper to attack than
+// hashes made with policy.
+func (p Argon2Params) weakerThan(policy Argon2Params) bool {
+	return p.Time < policy.Time ||
+		p.Memory < policy.Memory ||
+		p.Threads < policy.Threads ||
+		p.KeyLength < policy.KeyLength
+}
+
+// LegacyArgon2Params are the settings used before hashes recorded their own
+// parameters.
+var LegacyArgon2Params = Argon2Params{
+	Time:       2,
+	Memory:     19 * 1024,
+	Threads:    1,
+	KeyLength:  32,
+	SaltLength: 16,
+}
+
+// LegacyPepperID is the pepper assumed for hashes that do not name one.
+const LegacyPepperID = "1"
+
+// PasswordHashing is the policy new password hashes are created with. Stored
+// hashes that fall short of it are replaced the next time the user signs in.
+type PasswordHashing struct {
+	Params   Argon2Params
+	PepperID string
+	// RetiredPeppers holds earlier peppers by ID, so hashes made with them
+	// keep verifying until they are rehashed with the current pepper.
+	RetiredPeppers map[string]string
+}
+
+var passwordHashing = PasswordHashing{
+	Params:   LegacyArgon2Params,
+	PepperID: LegacyPepperID,
+}
+
+// ConfigurePasswordHashing sets the hashing policy. It must be called before
+// the application starts serving requests.
+func ConfigurePasswordHashing(cfg PasswordHashing) {
+	if cfg.Params.KeyLength == 0 {
+		cfg.Params.KeyLength = LegacyArgon2Params.KeyLength
+	}
+	if cfg.Params.SaltLength == 0 {
+		cfg.Params.SaltLength = LegacyArgon2Params.SaltLength
+	}
+	if cfg.PepperID == "" {
+		cfg.PepperID = LegacyPepperID
+	}
+
+	passwordHashing = cfg
+}
+
+// passwordHash is a decoded stored password. New hashes are encoded in the
+// PHC string format with the pepper ID as an extra parameter:
+//
+//	$argon2id$v=19$m=19456,t=2,p=1,pid=1$<salt>$<hash>
+//
+// Legacy hashes are "<hash>:<salt>" made with LegacyArgon2Params.
+type passwordHash struct {
+	params   Argon2Params
+	pepperID string
+	salt     []byte
+	hash     []byte
+	legacy   bool
+}
+
+func parsePasswordHash(encoded string) (passwordHash, error) {
+	if !strings.HasPrefix(encoded, "$") {
+		return parseLegacyPasswordHash(encoded)
+	}
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return passwordHash{}, ErrInvalidPasswordHash
+	}
+
+	if parts[2] != fmt.Sprintf("v=%d", argon2.Version) {
+		return passwordHash{}, ErrInvalidPasswordHash
+	}
+
+	h := passwordHash{pepperID: LegacyPepperID}
+	for _, param := range strings.Split(parts[3], ",") {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			return passwordHash{}, ErrInvalidPasswordHash
+		}
+
+		if key == "pid" {
+			h.pepperID = value
+			continue
+		}
+
+		n, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return passwordHash{}, ErrInvalidPasswordHash
+		}
+
+		switch key {
+		case "m":
+			h.params.Memory = uint32(n)
+		case "t":
+			h.params.Time = uint32(n)
+		case "p":
+			if n > 255 {
+				return passwordHash{}, ErrInvalidPasswordHash
+			}
+			h.params.Threads = uint8(n)
+		default:
+			return passwordHash{}, ErrInvalidPasswordHash
+		}
+	}
+
+	var err error
+	if h.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return passwordHash{}, fmt.Errorf("failed to decode salt: %w", err)
+	}
+	if h.hash, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return passwordHash{}, fmt.Errorf("failed to decode hash: %w", err)
+	}
+
+	if h.params.Time == 0 || h.params.Memory == 0 || h.params.Threads == 0 || len(h.hash) == 0 {
+		return passwordHash{}, ErrInvalidPasswordHash
+	}
+
+	h.params.KeyLength = uint32(len(h.hash))
+	h.params.SaltLength = uint32(len(h.salt))
+
+	return h, nil
+}
+
+func parseLegacyPasswordHash(encoded string) (passwordHash, error) {
+	parts := strings.Split(encoded, ":")
+	if len(parts) != 2 {
+		return passwordHash{}, ErrInvalidPasswordHash
+	}
+
+	hash, err := base64.RawStdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return passwordHash{}, fmt.Errorf("failed to decode hash: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return passwordHash{}, fmt.Errorf("failed to decode salt: %w", err)
+	}
+
+	params := LegacyArgon2Params
+	params.KeyLength = uint32(len(hash))
+	params.SaltLength = uint32(len(salt))
+
+	return passwordHash{
+		params:   params,
+		pepperID: LegacyPepperID,
+		salt:     salt,
+		hash:     hash,
+		legacy:   true,
+	}, nil
+}
+
+func (h passwordHash) String() string {
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d,pid=%s$%s$%s",
+		argon2.Version,
+		h.params.Memory,
+		h.params.Time,
+		h.params.Threads,
+		h.pepperID,
+		base64.RawStdEncoding.EncodeToString(h.salt),
+		base64.RawStdEncoding.EncodeToString(h.hash),
+	)
+}
+
+func (h passwordHash) matches(password, pepper string) bool {
+	computed := argon2.IDKey(
+		[]byte(password+pepper),
+		h.salt,
+		h.params.Time,
+		h.params.Memory,
+		h.params.Threads,
+		h.params.KeyLength,
+	)
+
+	return subtle.ConstantTimeCompare(computed, h.hash) == 1
+}
+
+// retiredPeppers returns the retired peppers ordered by ID, so lookups that
+// fall back to them do so in a stable order.
+func retiredPeppers() []string {
+	ids := slices.Sorted(maps.Keys(passwordHashing.RetiredPeppers))
+
+	peppers := make([]string, len(ids))
+	for i, id := range ids {
+		peppers[i] = passwordHashing.RetiredPeppers[id]
+	}
+
+	return peppers
+}
+
+// pepperFor returns the pepper a hash was made with. The current pepper is
+// passed in by the caller; retired ones come from the hashing policy.
+func pepperFor(pepperID, currentPepper string) (string, error) {
+	if pepperID == passwordHashing.PepperID {
+		return currentPepper, nil
+	}
+
+	pepper, ok := passwordHashing.RetiredPeppers[pepperID]
+	if !ok {
+		return "", ErrUnknownPepper
+	}
+
+	return pepper, nil
+}
+
+func (u User) ValidPassword(providedPassword, pepper string) (bool, error) {
+	h, err := parsePasswordHash(string(u.Password))
+	if err != nil {
+		return false, err
+	}
+
+	hashPepper, err := pepperFor(h.pepperID, pepper)
+	if err != nil {
+		return false, err
+	}
+
+	return h.matches(providedPassword, hashPepper), nil
+}
+
+// PasswordNeedsRehash reports whether the stored hash is in the legacy
+// format, is cheaper than the current policy or uses a retired pepper.
+func (u User) PasswordNeedsRehash() bool {
+	h, err := parsePasswordHash(string(u.Password))
+	if err != nil {
+		return true
+	}
+
+	return h.legacy ||
+		h.pepperID != passwordHashing.PepperID ||
+		h.params.weakerThan(passwordHashing.Params)
+}
+
+// DummyPasswordCheck spends the same time as checking a real password. Call
+// it when there is no user to check against, so response times do not tell
+// whether an account exists.
+func DummyPasswordCheck(password, pepper string) {
+	dummyPasswordHash().matches(password, pepper)
+}
+
+// dummyPasswordHash has the shape of a hash made by HashPassword now. Its
+// salt is never compared, so its value does not matter.
+func dummyPasswordHash() passwordHash {
+	params := passwordHashing.Params
+	return passwordHash{params: params, salt: make([]byte, params.SaltLength)}
+}
+
+func generateSalt(size int) ([]byte, error) {
+	salt := make([]byte, size)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	return salt, nil
+}
+
+func HashPassword(password, pepper string) (string, error) {
+	params := passwordHashing.Params
+
+	salt, err := generateSalt(int(params.SaltLength))
+	if err != nil {
+		return "", err
+	}
+
+	h := passwordHash{
+		params:   params,
+		pepperID: passwordHashing.PepperID,
+		salt:     salt,
+	}
+	h.hash = argon2.IDKey(
+		[]byte(password+pepper),
+		salt,
+		params.Time,
+		params.Memory,
+		params.Threads,
+		params.KeyLength,
+	)
+
+	return h.String(), nil
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"testing"
+)
+
+// TestDummyPasswordCheckCostsTheSame guards the sign-in path for unknown
+// emails: the dummy check must hash with the configured parameters, as a
+// wrong password for a real account does, rather than the legacy ones.
+func TestDummyPasswordCheckCostsTheSame(t *testing.T) {
+	previous := passwordHashing
+	t.Cleanup(func() { passwordHashing = previous })
+
+	tests := []struct {
+		name   string
+		params Argon2Params
+	}{
+		{name: "default", params: Argon2Params{Time: 2, Memory: 19 * 1024, Threads: 1}},
+		{name: "above the legacy cost", params: Argon2Params{Time: 4, Memory: 32 * 1024, Threads: 2}},
+		{name: "custom lengths", params: Argon2Params{Time: 1, Memory: 8 * 1024, Threads: 1, KeyLength: 64, SaltLength: 32}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ConfigurePasswordHashing(PasswordHashing{Params: tt.params, PepperID: "2"})
+
+			encoded, err := HashPassword("correct horse battery staple", "pepper")
+			if err != nil {
+				t.Fatal(err)
+			}
+			hashed, err := parsePasswordHash(encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if dummy := dummyPasswordHash(); dummy.params != hashed.params || len(dummy.salt) != len(hashed.salt) {
+				t.Errorf("dummy check hashes with %+v and a %d byte salt, HashPassword with %+v and %d",
+					dummy.params, len(dummy.salt), hashed.params, len(hashed.salt))
+			}
+		})
+	}
+}
+
+func TestValidPasswordWithRetiredPepper(t *testing.T) {
+	previous := passwordHashing
+	t.Cleanup(func() { passwordHashing = previous })
+
+	ConfigurePasswordHashing(PasswordHashing{
+		Params:   Argon2Params{Time: 1, Memory: 8 * 1024, Threads: 1},
+		PepperID: "1",
+	})
+
+	hash, err := HashPassword("correct horse battery staple", "old-pepper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := User{Password: []byte(hash)}
+
+	ConfigurePasswordHashing(PasswordHashing{
+		Params:         Argon2Params{Time: 1, Memory: 8 * 1024, Threads: 1},
+		PepperID:       "2",
+		RetiredPeppers: map[string]string{"1": "old-pepper"},
+	})
+
+	ok, err := user.ValidPassword("correct horse battery staple", "new-pepper")
+	if !ok || err != nil {
+		t.Errorf("ValidPassword with a retired pepper = %v, %v, want true", ok, err)
+	}
+	if !user.PasswordNeedsRehash() {
+		t.Error("hash made with a retired pepper does not need a rehash")
+	}
+
+	passwordHashing.RetiredPeppers = nil
+	if _, err := user.ValidPassword("correct horse battery staple", "new-pepper"); err != ErrUnknownPepper {
+		t.Errorf("ValidPassword after the pepper was dropped = %v, want ErrUnknownPepper", err)
+	}
+}
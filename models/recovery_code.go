@@ -0,0 +1,116 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models/internal/db"
+)
+
+const recoveryCodeCount = 10
+
+type RecoveryCode struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Hash      string
+	UsedAt    time.Time
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+// CreateRecoveryCodes replaces any existing recovery codes for the user with a
+// fresh set and returns the plain codes. Only the hashes are stored, so this is
+// the only time the codes can be shown.
+func CreateRecoveryCodes(
+	ctx context.Context,
+	exec storage.Executor,
+	pepper string,
+	userID uuid.UUID,
+) ([]string, error) {
+	if err := queries.DeleteRecoveryCodesByUserID(ctx, exec, userID); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := GenerateCode(10)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := queries.InsertRecoveryCode(ctx, exec, db.InsertRecoveryCodeParams{
+			ID:     uuid.New(),
+			UserID: userID,
+			Hash:   HashForStorage(code, pepper),
+		}); err != nil {
+			return nil, err
+		}
+
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+
+	return codes, nil
+}
+
+// UseRecoveryCode marks a matching unused code as spent. It reports false if
+// no unused code matches.
+func UseRecoveryCode(
+	ctx context.Context,
+	exec storage.Executor,
+	pepper string,
+	userID uuid.UUID,
+	code string,
+) (bool, error) {
+	row, err := findByStorageHash(
+		normalizeRecoveryCode(code),
+		pepper,
+		func(hash string) (db.RecoveryCode, error) {
+			return queries.QueryUnusedRecoveryCodeByUserIDAndHash(
+				ctx,
+				exec,
+				db.QueryUnusedRecoveryCodeByUserIDAndHashParams{
+					UserID: userID,
+					Hash:   hash,
+				},
+			)
+		},
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if err := queries.MarkRecoveryCodeUsed(ctx, exec, row.ID); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func CountUnusedRecoveryCodes(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+) (int64, error) {
+	return queries.CountUnusedRecoveryCodesByUserID(ctx, exec, userID)
+}
+
+func DestroyRecoveryCodesByUserID(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+) error {
+	return queries.DeleteRecoveryCodesByUserID(ctx, exec, userID)
+}
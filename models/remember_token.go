@@ -0,0 +1,176 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models/internal/db"
+)
+
+var ErrMalformedRememberToken = errors.New("malformed remember token")
+
+// RememberToken keeps a device signed in past the end of its session. The
+// secret given to the browser is the token id, which never changes, and a
+// validator that is replaced every time the token is used. Only HMACs of
+// the current and the previous validator are stored.
+type RememberToken struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	SessionID    uuid.UUID
+	Hash         string
+	PreviousHash string
+	RotatedAt    time.Time
+	ExpiresAt    time.Time
+}
+
+func (r RememberToken) IsExpired() bool {
+	return !time.Now().Before(r.ExpiresAt)
+}
+
+// MatchesCurrent reports whether validator is the one most recently handed
+// out.
+func (r RememberToken) MatchesCurrent(validator, pepper string) bool {
+	return matchesStorageHash(validator, pepper, r.Hash)
+}
+
+// MatchesPrevious reports whether validator is the one the last rotation
+// replaced.
+func (r RememberToken) MatchesPrevious(validator, pepper string) bool {
+	if r.PreviousHash == "" {
+		return false
+	}
+
+	return matchesStorageHash(validator, pepper, r.PreviousHash)
+}
+
+// ParseRememberTokenSecret splits a secret made by CreateRememberToken or
+// RotateRememberToken into the token id and the validator.
+func ParseRememberTokenSecret(secret string) (uuid.UUID, string, error) {
+	rawID, validator, ok := strings.Cut(secret, ".")
+	if !ok || validator == "" {
+		return uuid.UUID{}, "", ErrMalformedRememberToken
+	}
+
+	id, err := uuid.Parse(rawID)
+	if err != nil {
+		return uuid.UUID{}, "", ErrMalformedRememberToken
+	}
+
+	return id, validator, nil
+}
+
+func FindRememberToken(
+	ctx context.Context,
+	exec storage.Executor,
+	id uuid.UUID,
+) (RememberToken, error) {
+	row, err := queries.QueryRememberTokenByID(ctx, exec, id)
+	if err != nil {
+		return RememberToken{}, err
+	}
+
+	return rowToRememberToken(row), nil
+}
+
+type CreateRememberTokenData struct {
+	SessionID uuid.UUID `validate:"required"`
+	ExpiresAt time.Time `validate:"required"`
+}
+
+// CreateRememberToken issues a token for the session's device and returns
+// the secret for the browser, which cannot be recovered later.
+func CreateRememberToken(
+	ctx context.Context,
+	exec storage.Executor,
+	pepper string,
+	data CreateRememberTokenData,
+) (RememberToken, string, error) {
+	if err := validate.Struct(data); err != nil {
+		return RememberToken{}, "", errors.Join(ErrDomainValidation, err)
+	}
+
+	validator, err := GenerateSecureToken()
+	if err != nil {
+		return RememberToken{}, "", err
+	}
+
+	row, err := queries.InsertRememberToken(ctx, exec, db.InsertRememberTokenParams{
+		ID:        uuid.New(),
+		SessionID: data.SessionID,
+		Hash:      HashForStorage(validator, pepper),
+		ExpiresAt: pgtype.Timestamptz{
+			Time:  data.ExpiresAt,
+			Valid: true,
+		},
+	})
+	if err != nil {
+		return RememberToken{}, "", err
+	}
+
+	token := rowToRememberToken(row)
+
+	return token, rememberTokenSecret(token.ID, validator), nil
+}
+
+// RotateRememberToken replaces the validator of token and returns the new
+// secret. It only succeeds if the stored validator is still the one token
+// was read with, so of two requests racing to rotate only one wins; the
+// other gets sql.ErrNoRows.
+func RotateRememberToken(
+	ctx context.Context,
+	exec storage.Executor,
+	pepper string,
+	token RememberToken,
+) (RememberToken, string, error) {
+	validator, err := GenerateSecureToken()
+	if err != nil {
+		return RememberToken{}, "", err
+	}
+
+	row, err := queries.RotateRememberToken(ctx, exec, db.RotateRememberTokenParams{
+		ID:          token.ID,
+		NewHash:     HashForStorage(validator, pepper),
+		CurrentHash: token.Hash,
+	})
+	if err != nil {
+		return RememberToken{}, "", err
+	}
+
+	rotated := rowToRememberToken(row)
+
+	return rotated, rememberTokenSecret(rotated.ID, validator), nil
+}
+
+// DestroyRememberTokensByUserID forgets every device the user ticked
+// "remember me" on.
+func DestroyRememberTokensByUserID(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+) error {
+	return queries.DeleteRememberTokensByUserID(ctx, exec, userID)
+}
+
+func rememberTokenSecret(id uuid.UUID, validator string) string {
+	return id.String() + "." + validator
+}
+
+func rowToRememberToken(row db.RememberToken) RememberToken {
+	return RememberToken{
+		ID:           row.ID,
+		CreatedAt:    row.CreatedAt.Time,
+		UpdatedAt:    row.UpdatedAt.Time,
+		SessionID:    row.SessionID,
+		Hash:         row.Hash,
+		PreviousHash: row.PreviousHash,
+		RotatedAt:    row.RotatedAt.Time,
+		ExpiresAt:    row.ExpiresAt.Time,
+	}
+}
@@ -0,0 +1,146 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models/internal/db"
+)
+
+// Permissions seeded by the roles migration. Admins hold every permission
+// without needing a role. emails.view shows logged message bodies and is
+// kept to admins.
+const (
+	PermissionUsersManage      = "users.manage"
+	PermissionUsersImpersonate = "users.impersonate"
+	PermissionAuditView        = "audit.view"
+	PermissionJobsManage       = "jobs.manage"
+	PermissionEmailsView       = "emails.view"
+	PermissionEmailsManage     = "emails.manage"
+)
+
+// Role groups permissions so they can be granted to users together.
+type Role struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Name        string
+	Description string
+}
+
+func FindRoles(
+	ctx context.Context,
+	exec storage.Executor,
+) ([]Role, error) {
+	rows, err := queries.QueryRoles(ctx, exec)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]Role, len(rows))
+	for i, row := range rows {
+		roles[i] = rowToRole(row)
+	}
+
+	return roles, nil
+}
+
+func FindRoleByName(
+	ctx context.Context,
+	exec storage.Executor,
+	name string,
+) (Role, error) {
+	row, err := queries.QueryRoleByName(ctx, exec, name)
+	if err != nil {
+		return Role{}, err
+	}
+
+	return rowToRole(row), nil
+}
+
+func FindRolesByUserID(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+) ([]Role, error) {
+	rows, err := queries.QueryRolesByUserID(ctx, exec, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]Role, len(rows))
+	for i, row := range rows {
+		roles[i] = rowToRole(row)
+	}
+
+	return roles, nil
+}
+
+func AssignUserRole(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+	roleID uuid.UUID,
+) error {
+	return queries.InsertUserRole(ctx, exec, db.InsertUserRoleParams{
+		UserID: userID,
+		RoleID: roleID,
+	})
+}
+
+func RemoveUserRole(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+	roleID uuid.UUID,
+) error {
+	return queries.DeleteUserRole(ctx, exec, db.DeleteUserRoleParams{
+		UserID: userID,
+		RoleID: roleID,
+	})
+}
+
+// Authorize reports whether user currently holds permission. It always asks
+// the database, so granting or revoking a role applies to the next request.
+func Authorize(
+	ctx context.Context,
+	exec storage.Executor,
+	user User,
+	permission string,
+) (bool, error) {
+	if user.IsAdmin {
+		return true, nil
+	}
+
+	return queries.QueryUserHasPermission(ctx, exec, db.QueryUserHasPermissionParams{
+		UserID: user.ID,
+		Name:   permission,
+	})
+}
+
+// FindUserPermissions lists every permission user holds, either through an
+// assigned role or, for admins, all of them.
+func FindUserPermissions(
+	ctx context.Context,
+	exec storage.Executor,
+	user User,
+) ([]string, error) {
+	if user.IsAdmin {
+		return queries.QueryPermissionNames(ctx, exec)
+	}
+
+	return queries.QueryPermissionNamesByUserID(ctx, exec, user.ID)
+}
+
+func rowToRole(row db.Role) Role {
+	return Role{
+		ID:          row.ID,
+		CreatedAt:   row.CreatedAt.Time,
+		UpdatedAt:   row.UpdatedAt.Time,
+		Name:        row.Name,
+		Description: row.Description,
+	}
+}
@@ -0,0 +1,225 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/internal/useragent"
+	"mbvlabs/models/internal/db"
+)
+
+type Session struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	RevokedAt time.Time
+	// IP, UserAgent and LastSeenAt describe the device behind the session.
+	// They are refreshed as the session is used, at most every few minutes.
+	IP         string
+	UserAgent  string
+	LastSeenAt time.Time
+}
+
+func (s Session) IsRevoked() bool {
+	return !s.RevokedAt.IsZero()
+}
+
+func (s Session) IsExpired() bool {
+	return !time.Now().Before(s.ExpiresAt)
+}
+
+func (s Session) IsActive() bool {
+	return !s.IsRevoked() && !s.IsExpired()
+}
+
+func (s Session) Device() useragent.Agent {
+	return useragent.Parse(s.UserAgent)
+}
+
+func FindSession(
+	ctx context.Context,
+	exec storage.Executor,
+	id uuid.UUID,
+) (Session, error) {
+	row, err := queries.QuerySessionByID(ctx, exec, id)
+	if err != nil {
+		return Session{}, err
+	}
+
+	return rowToSession(row)
+}
+
+func FindSessionsByUserID(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+) ([]Session, error) {
+	rows, err := queries.QuerySessionsByUserID(ctx, exec, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, len(rows))
+	for i, row := range rows {
+		session, convErr := rowToSession(row)
+		if convErr != nil {
+			return nil, convErr
+		}
+		sessions[i] = session
+	}
+
+	return sessions, nil
+}
+
+// FindDevicesByUserID returns the sessions a user can still be signed in
+// with, most recently used first. That includes expired sessions whose
+// device holds a remember token that can renew them.
+func FindDevicesByUserID(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+) ([]Session, error) {
+	rows, err := queries.QueryDevicesByUserID(ctx, exec, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, len(rows))
+	for i, row := range rows {
+		session, convErr := rowToSession(row)
+		if convErr != nil {
+			return nil, convErr
+		}
+		sessions[i] = session
+	}
+
+	return sessions, nil
+}
+
+type CreateSessionData struct {
+	UserID    uuid.UUID `validate:"required"`
+	ExpiresAt time.Time `validate:"required"`
+	IP        string
+	UserAgent string `validate:"max=512"`
+}
+
+func CreateSession(
+	ctx context.Context,
+	exec storage.Executor,
+	data CreateSessionData,
+) (Session, error) {
+	if err := validate.Struct(data); err != nil {
+		return Session{}, errors.Join(ErrDomainValidation, err)
+	}
+
+	params := db.InsertSessionParams{
+		ID:     uuid.New(),
+		UserID: data.UserID,
+		ExpiresAt: pgtype.Timestamptz{
+			Time:  data.ExpiresAt,
+			Valid: true,
+		},
+		Ip:        data.IP,
+		UserAgent: data.UserAgent,
+	}
+	row, err := queries.InsertSession(ctx, exec, params)
+	if err != nil {
+		return Session{}, err
+	}
+
+	return rowToSession(row)
+}
+
+// TouchSession records that the session was just used, and from where.
+func TouchSession(
+	ctx context.Context,
+	exec storage.Executor,
+	id uuid.UUID,
+	ip string,
+	userAgent string,
+) error {
+	return queries.TouchSession(ctx, exec, db.TouchSessionParams{
+		ID:        id,
+		Ip:        ip,
+		UserAgent: userAgent,
+	})
+}
+
+func ExtendSession(
+	ctx context.Context,
+	exec storage.Executor,
+	id uuid.UUID,
+	expiresAt time.Time,
+) (Session, error) {
+	row, err := queries.ExtendSession(ctx, exec, db.ExtendSessionParams{
+		ID: id,
+		ExpiresAt: pgtype.Timestamptz{
+			Time:  expiresAt,
+			Valid: true,
+		},
+	})
+	if err != nil {
+		return Session{}, err
+	}
+
+	return rowToSession(row)
+}
+
+func RevokeSession(
+	ctx context.Context,
+	exec storage.Executor,
+	id uuid.UUID,
+) error {
+	return queries.RevokeSession(ctx, exec, id)
+}
+
+func RevokeUserSessions(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+) error {
+	return queries.RevokeSessionsByUserID(ctx, exec, userID)
+}
+
+// RevokeOtherUserSessions signs the user out everywhere except the session
+// they are using.
+func RevokeOtherUserSessions(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+	keepSessionID uuid.UUID,
+) error {
+	return queries.RevokeOtherSessionsByUserID(ctx, exec, db.RevokeOtherSessionsByUserIDParams{
+		UserID: userID,
+		ID:     keepSessionID,
+	})
+}
+
+func DestroySession(
+	ctx context.Context,
+	exec storage.Executor,
+	id uuid.UUID,
+) error {
+	return queries.DeleteSession(ctx, exec, id)
+}
+
+func rowToSession(row db.Session) (Session, error) {
+	return Session{
+		ID:         row.ID,
+		CreatedAt:  row.CreatedAt.Time,
+		UpdatedAt:  row.UpdatedAt.Time,
+		UserID:     row.UserID,
+		ExpiresAt:  row.ExpiresAt.Time,
+		RevokedAt:  row.RevokedAt.Time,
+		IP:         row.Ip,
+		UserAgent:  row.UserAgent,
+		LastSeenAt: row.LastSeenAt.Time,
+	}, nil
+}
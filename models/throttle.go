@@ -0,0 +1,120 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models/internal/db"
+)
+
+// Throttle counts failed attempts against a key, such as a client IP or an
+// email address, within a rolling window. It lives in Postgres so every
+// replica sees the same counts.
+type Throttle struct {
+	Key             string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Failures        int32
+	WindowStartedAt time.Time
+	LockedUntil     time.Time
+}
+
+func (t Throttle) IsLocked() bool {
+	return t.LockedUntil.After(time.Now())
+}
+
+func FindThrottlesByKeys(
+	ctx context.Context,
+	exec storage.Executor,
+	keys []string,
+) ([]Throttle, error) {
+	rows, err := queries.QueryThrottlesByKeys(ctx, exec, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	throttles := make([]Throttle, len(rows))
+	for i, row := range rows {
+		throttles[i] = rowToThrottle(row)
+	}
+
+	return throttles, nil
+}
+
+// ReserveThrottleAttempt counts an attempt against the key in a single
+// statement, before its outcome is known, so concurrent requests cannot all
+// slip in under the limit. Failures older than windowStart are forgotten and
+// counting starts over. A locked key is returned as it was, without counting
+// the attempt.
+func ReserveThrottleAttempt(
+	ctx context.Context,
+	exec storage.Executor,
+	key string,
+	windowStart time.Time,
+) (Throttle, error) {
+	row, err := queries.UpsertThrottleAttempt(ctx, exec, db.UpsertThrottleAttemptParams{
+		Key: key,
+		WindowStart: pgtype.Timestamptz{
+			Time:  windowStart,
+			Valid: true,
+		},
+	})
+	if err != nil {
+		return Throttle{}, err
+	}
+
+	return rowToThrottle(row), nil
+}
+
+// RefundThrottleAttempt takes back an attempt that did not fail. The lock
+// it may have set is lifted when the key drops below lockThreshold.
+func RefundThrottleAttempt(
+	ctx context.Context,
+	exec storage.Executor,
+	key string,
+	lockThreshold int32,
+) error {
+	return queries.RefundThrottleAttempt(ctx, exec, db.RefundThrottleAttemptParams{
+		Key:           key,
+		LockThreshold: lockThreshold,
+	})
+}
+
+// LockThrottle blocks the key until the given time. A lock that already runs
+// longer is kept.
+func LockThrottle(
+	ctx context.Context,
+	exec storage.Executor,
+	key string,
+	until time.Time,
+) error {
+	return queries.UpdateThrottleLockedUntil(ctx, exec, db.UpdateThrottleLockedUntilParams{
+		Key: key,
+		LockedUntil: pgtype.Timestamptz{
+			Time:  until,
+			Valid: true,
+		},
+	})
+}
+
+func DestroyThrottle(
+	ctx context.Context,
+	exec storage.Executor,
+	key string,
+) error {
+	return queries.DeleteThrottle(ctx, exec, key)
+}
+
+func rowToThrottle(row db.Throttle) Throttle {
+	return Throttle{
+		Key:             row.Key,
+		CreatedAt:       row.CreatedAt.Time,
+		UpdatedAt:       row.UpdatedAt.Time,
+		Failures:        row.Failures,
+		WindowStartedAt: row.WindowStartedAt.Time,
+		LockedUntil:     row.LockedUntil.Time,
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
+	"database/sql"
 	"encoding/base32"
 	"encoding/hex"
 	"errors"
@@ -25,12 +26,12 @@ type Token struct {
 	ExpiresAt time.Time
 	Hash      string
 	MetaData  []byte
+	UserID    uuid.UUID
+	Attempts  int32
 }
 
 func (t Token) IsValid(token, secret string) bool {
-	expected := HashForStorage(token, secret)
-
-	isEqual := hmac.Equal([]byte(expected), []byte(t.Hash))
+	isEqual := matchesStorageHash(token, secret, t.Hash)
 	isNotExpired := time.Now().Before(t.ExpiresAt)
 
 	return isEqual && isNotExpired
@@ -65,6 +66,49 @@ func HashForStorage(plain, secret string) string {
 	return hex.EncodeToString(m.Sum(nil))
 }
 
+// storageHashes returns the hash of plain under the current pepper followed
+// by its hash under each retired pepper. Stored hashes do not record their
+// pepper, so secrets handed out before a rotation are found by trying each
+// in turn until they expire or are replaced.
+func storageHashes(plain, pepper string) []string {
+	hashes := []string{HashForStorage(plain, pepper)}
+	for _, retired := range retiredPeppers() {
+		hashes = append(hashes, HashForStorage(plain, retired))
+	}
+
+	return hashes
+}
+
+func matchesStorageHash(plain, pepper, hash string) bool {
+	for _, candidate := range storageHashes(plain, pepper) {
+		if hmac.Equal([]byte(candidate), []byte(hash)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findByStorageHash runs query with each of the storage hashes of plain and
+// returns the first row found.
+func findByStorageHash[T any](
+	plain string,
+	pepper string,
+	query func(hash string) (T, error),
+) (T, error) {
+	for _, hash := range storageHashes(plain, pepper) {
+		row, err := query(hash)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+
+		return row, err
+	}
+
+	var zero T
+	return zero, sql.ErrNoRows
+}
+
 func FindToken(
 	ctx context.Context,
 	exec storage.Executor,
@@ -78,11 +122,14 @@ func FindToken(
 	return rowToToken(row)
 }
 
+// CreateCodeToken issues a short code for a user. Codes are only unique per
+// user, so they must be looked up with FindLatestTokenByScopeAndUserID.
 func CreateCodeToken(
 	ctx context.Context,
 	exec storage.Executor,
 	pepper string,
 	scope string,
+	userID uuid.UUID,
 	expiresAt time.Time,
 	metaData []byte,
 ) (string, error) {
@@ -92,6 +139,7 @@ func CreateCodeToken(
 	}
 
 	if _, err := createToken(ctx, exec, createTokenData{
+		UserID:    userID,
 		Scope:     scope,
 		ExpiresAt: expiresAt,
 		MetaData:  metaData,
@@ -128,7 +176,37 @@ func CreateToken(
 	return tkn, nil
 }
 
+// CreateUserToken issues a secure token bound to a user, so all of the
+// user's tokens in a scope can be found or revoked together.
+func CreateUserToken(
+	ctx context.Context,
+	exec storage.Executor,
+	pepper string,
+	scope string,
+	userID uuid.UUID,
+	expiresAt time.Time,
+	metaData []byte,
+) (string, error) {
+	tkn, err := GenerateSecureToken()
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := createToken(ctx, exec, createTokenData{
+		UserID:    userID,
+		Scope:     scope,
+		ExpiresAt: expiresAt,
+		MetaData:  metaData,
+		Hash:      HashForStorage(tkn, pepper),
+	}); err != nil {
+		return "", err
+	}
+
+	return tkn, nil
+}
+
 type createTokenData struct {
+	UserID    uuid.UUID
 	Scope     string    `validate:"required"`
 	ExpiresAt time.Time `validate:"required"`
 	Hash      string    `validate:"required"`
@@ -153,6 +231,10 @@ func createToken(
 		},
 		Hash:     data.Hash,
 		MetaData: data.MetaData,
+		UserID: pgtype.UUID{
+			Bytes: data.UserID,
+			Valid: data.UserID != uuid.Nil,
+		},
 	}
 	row, err := queries.InsertToken(ctx, exec, params)
 	if err != nil {
@@ -162,12 +244,24 @@ func createToken(
 	return rowToToken(row)
 }
 
+// DestroyToken returns sql.ErrNoRows if the token is already gone. Callers
+// consuming a single use token rely on that: of two requests racing to use
+// it, only the one whose delete went through may proceed.
 func DestroyToken(
 	ctx context.Context,
 	exec storage.Executor,
 	id uuid.UUID,
 ) error {
-	return queries.DeleteToken(ctx, exec, id)
+	rowsAffected, err := queries.DeleteToken(ctx, exec, id)
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
 }
 
 
@@ -241,11 +335,11 @@ func FindTokenByScopeAndHash(
 	scope string,
 	token string,
 ) (Token, error) {
-	hash := HashForStorage(token, pepper)
-
-	row, err := queries.QueryTokenByScopeAndHash(ctx, exec, db.QueryTokenByScopeAndHashParams{
-		Scope: scope,
-		Hash:  hash,
+	row, err := findByStorageHash(token, pepper, func(hash string) (db.Token, error) {
+		return queries.QueryTokenByScopeAndHash(ctx, exec, db.QueryTokenByScopeAndHashParams{
+			Scope: scope,
+			Hash:  hash,
+		})
 	})
 	if err != nil {
 		return Token{}, err
@@ -254,6 +348,82 @@ func FindTokenByScopeAndHash(
 	return rowToToken(row)
 }
 
+func FindLatestTokenByScopeAndUserID(
+	ctx context.Context,
+	exec storage.Executor,
+	scope string,
+	userID uuid.UUID,
+) (Token, error) {
+	row, err := queries.QueryLatestTokenByScopeAndUserID(
+		ctx,
+		exec,
+		db.QueryLatestTokenByScopeAndUserIDParams{
+			Scope:  scope,
+			UserID: pgtype.UUID{Bytes: userID, Valid: true},
+		},
+	)
+	if err != nil {
+		return Token{}, err
+	}
+
+	return rowToToken(row)
+}
+
+// FindTokensByUserID returns every token issued to the user, newest first.
+func FindTokensByUserID(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+) ([]Token, error) {
+	rows, err := queries.QueryTokensByUserID(ctx, exec, pgtype.UUID{Bytes: userID, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([]Token, len(rows))
+	for i, row := range rows {
+		token, err := rowToToken(row)
+		if err != nil {
+			return nil, err
+		}
+		tokens[i] = token
+	}
+
+	return tokens, nil
+}
+
+// IncrementTokenAttempts records a failed guess and returns the number of
+// failed guesses so far.
+func IncrementTokenAttempts(
+	ctx context.Context,
+	exec storage.Executor,
+	id uuid.UUID,
+) (int32, error) {
+	return queries.IncrementTokenAttempts(ctx, exec, id)
+}
+
+func DestroyTokensByScopeAndUserID(
+	ctx context.Context,
+	exec storage.Executor,
+	scope string,
+	userID uuid.UUID,
+) error {
+	return queries.DeleteTokensByScopeAndUserID(ctx, exec, db.DeleteTokensByScopeAndUserIDParams{
+		Scope:  scope,
+		UserID: pgtype.UUID{Bytes: userID, Valid: true},
+	})
+}
+
+// DestroyExpiredTokens deletes up to limit tokens that have expired and
+// returns how many it deleted.
+func DestroyExpiredTokens(
+	ctx context.Context,
+	exec storage.Executor,
+	limit int64,
+) (int64, error) {
+	return queries.DeleteExpiredTokens(ctx, exec, limit)
+}
+
 func rowToToken(row db.Token) (Token, error) {
 	return Token{
 		ID:        row.ID,
@@ -263,5 +433,7 @@ func rowToToken(row db.Token) (Token, error) {
 		ExpiresAt: row.ExpiresAt.Time,
 		Hash:      row.Hash,
 		MetaData:  row.MetaData,
+		UserID:    uuid.UUID(row.UserID.Bytes),
+		Attempts:  row.Attempts,
 	}, nil
 }
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStorageHashesIncludeRetiredPeppers(t *testing.T) {
+	withPepperRotation(t, "totp-key", map[string]string{"2": "second", "1": "first"})
+
+	want := []string{
+		HashForStorage("secret", "current"),
+		HashForStorage("secret", "first"),
+		HashForStorage("secret", "second"),
+	}
+	if got := storageHashes("secret", "current"); !slices.Equal(got, want) {
+		t.Errorf("storageHashes = %q, want %q", got, want)
+	}
+}
+
+func TestTokenIsValidAfterPepperRotation(t *testing.T) {
+	withPepperRotation(t, "totp-key", map[string]string{"1": "old-pepper"})
+
+	token := Token{
+		Hash:      HashForStorage("magic", "old-pepper"),
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+
+	if !token.IsValid("magic", "new-pepper") {
+		t.Error("token hashed with a retired pepper is not valid")
+	}
+	if token.IsValid("other", "new-pepper") {
+		t.Error("wrong token is valid")
+	}
+
+	passwordHashing.RetiredPeppers = nil
+	if token.IsValid("magic", "new-pepper") {
+		t.Error("token is valid after its pepper was dropped")
+	}
+}
+
+func TestFindByStorageHash(t *testing.T) {
+	withPepperRotation(t, "totp-key", map[string]string{"1": "old-pepper"})
+
+	stored := HashForStorage("api-secret", "old-pepper")
+	var tried []string
+	query := func(hash string) (string, error) {
+		tried = append(tried, hash)
+		if hash == stored {
+			return "row", nil
+		}
+		return "", sql.ErrNoRows
+	}
+
+	row, err := findByStorageHash("api-secret", "new-pepper", query)
+	if err != nil || row != "row" {
+		t.Fatalf("findByStorageHash = %q, %v, want row", row, err)
+	}
+	if len(tried) != 2 {
+		t.Errorf("tried %d hashes, want the current pepper then the retired one", len(tried))
+	}
+
+	if _, err := findByStorageHash("missing", "new-pepper", query); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("findByStorageHash for an unknown secret = %v, want sql.ErrNoRows", err)
+	}
+
+	failure := errors.New("connection reset")
+	_, err = findByStorageHash("api-secret", "new-pepper", func(string) (string, error) {
+		return "", failure
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("findByStorageHash = %v, want the query error", err)
+	}
+}
@@ -0,0 +1,280 @@
+package models
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pquerna/otp/totp"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models/internal/db"
+)
+
+const totpPeriod = 30
+
+var ErrTOTPKeyNotConfigured = errors.New("TOTP encryption key is not configured")
+
+// totpEncryptionKey seals TOTP secrets. It is separate from the pepper so
+// rotating the pepper leaves enrolled authenticators working.
+var totpEncryptionKey []byte
+
+// ConfigureTOTPEncryption sets the key TOTP secrets are sealed with. It must
+// be called before the application starts serving requests.
+func ConfigureTOTPEncryption(key string) {
+	totpEncryptionKey = secretKey(key)
+}
+
+type TOTPCredential struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	UserID       uuid.UUID
+	Secret       string
+	ConfirmedAt  time.Time
+	LastUsedStep int64
+}
+
+func (t TOTPCredential) IsConfirmed() bool {
+	return !t.ConfirmedAt.IsZero()
+}
+
+// ValidCode checks the code against the current time step and one step on
+// either side to allow for clock drift. It returns the matched step so callers
+// can refuse to accept the same code twice.
+//
+// pepper is only needed for secrets sealed before the encryption key was
+// introduced; see ResealTOTPCredential.
+func (t TOTPCredential) ValidCode(code, pepper string) (int64, bool, error) {
+	secret, _, err := openSecret(t.Secret, pepper)
+	if err != nil {
+		return 0, false, err
+	}
+
+	now := time.Now()
+	current := now.Unix() / totpPeriod
+
+	for _, step := range []int64{current - 1, current, current + 1} {
+		if step <= t.LastUsedStep {
+			continue
+		}
+
+		expected, err := totp.GenerateCode(secret, time.Unix(step*totpPeriod, 0))
+		if err != nil {
+			return 0, false, err
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+func FindTOTPCredentialByUserID(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+) (TOTPCredential, error) {
+	row, err := queries.QueryTotpCredentialByUserID(ctx, exec, userID)
+	if err != nil {
+		return TOTPCredential{}, err
+	}
+
+	return rowToTOTPCredential(row)
+}
+
+type CreateTOTPCredentialData struct {
+	UserID uuid.UUID `validate:"required"`
+	Secret string    `validate:"required"`
+}
+
+// CreateTOTPCredential stores a new, unconfirmed secret for the user,
+// replacing any previous one. The secret is encrypted at rest.
+func CreateTOTPCredential(
+	ctx context.Context,
+	exec storage.Executor,
+	data CreateTOTPCredentialData,
+) (TOTPCredential, error) {
+	if err := validate.Struct(data); err != nil {
+		return TOTPCredential{}, errors.Join(ErrDomainValidation, err)
+	}
+
+	sealed, err := sealSecret(data.Secret)
+	if err != nil {
+		return TOTPCredential{}, err
+	}
+
+	row, err := queries.UpsertTotpCredential(ctx, exec, db.UpsertTotpCredentialParams{
+		ID:     uuid.New(),
+		UserID: data.UserID,
+		Secret: sealed,
+	})
+	if err != nil {
+		return TOTPCredential{}, err
+	}
+
+	return rowToTOTPCredential(row)
+}
+
+func ConfirmTOTPCredential(
+	ctx context.Context,
+	exec storage.Executor,
+	id uuid.UUID,
+	step int64,
+) (TOTPCredential, error) {
+	row, err := queries.ConfirmTotpCredential(ctx, exec, db.ConfirmTotpCredentialParams{
+		ID:           id,
+		LastUsedStep: step,
+	})
+	if err != nil {
+		return TOTPCredential{}, err
+	}
+
+	return rowToTOTPCredential(row)
+}
+
+func UpdateTOTPCredentialLastUsedStep(
+	ctx context.Context,
+	exec storage.Executor,
+	id uuid.UUID,
+	step int64,
+) error {
+	return queries.UpdateTotpCredentialLastUsedStep(ctx, exec, db.UpdateTotpCredentialLastUsedStepParams{
+		ID:           id,
+		LastUsedStep: step,
+	})
+}
+
+// ResealTOTPCredential moves a secret sealed with the current or a retired
+// pepper over to the encryption key. It does nothing for secrets that are
+// already sealed with the key.
+func ResealTOTPCredential(
+	ctx context.Context,
+	exec storage.Executor,
+	pepper string,
+	credential TOTPCredential,
+) error {
+	secret, current, err := openSecret(credential.Secret, pepper)
+	if err != nil || current {
+		return err
+	}
+
+	sealed, err := sealSecret(secret)
+	if err != nil {
+		return err
+	}
+
+	return queries.UpdateTotpCredentialSecret(ctx, exec, db.UpdateTotpCredentialSecretParams{
+		ID:     credential.ID,
+		Secret: sealed,
+	})
+}
+
+func DestroyTOTPCredentialByUserID(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+) error {
+	return queries.DeleteTotpCredentialByUserID(ctx, exec, userID)
+}
+
+func rowToTOTPCredential(row db.TotpCredential) (TOTPCredential, error) {
+	return TOTPCredential{
+		ID:           row.ID,
+		CreatedAt:    row.CreatedAt.Time,
+		UpdatedAt:    row.UpdatedAt.Time,
+		UserID:       row.UserID,
+		Secret:       row.Secret,
+		ConfirmedAt:  row.ConfirmedAt.Time,
+		LastUsedStep: row.LastUsedStep,
+	}, nil
+}
+
+func secretKey(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
+
+// legacySecretKeys are the keys secrets were sealed with before they had
+// their own: a hash of the current pepper, then of each retired one.
+func legacySecretKeys(pepper string) [][]byte {
+	keys := [][]byte{secretKey(pepper)}
+	for _, retired := range retiredPeppers() {
+		keys = append(keys, secretKey(retired))
+	}
+
+	return keys
+}
+
+func sealSecret(plain string) (string, error) {
+	if totpEncryptionKey == nil {
+		return "", ErrTOTPKeyNotConfigured
+	}
+
+	gcm, err := secretCipher(totpEncryptionKey)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), nil)
+
+	return base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// openSecret decrypts a sealed secret and reports whether it was sealed
+// with the current encryption key rather than a legacy pepper key.
+func openSecret(sealed, pepper string) (string, bool, error) {
+	raw, err := base64.RawStdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", false, err
+	}
+
+	keys := legacySecretKeys(pepper)
+	if totpEncryptionKey != nil {
+		keys = append([][]byte{totpEncryptionKey}, keys...)
+	}
+
+	for i, key := range keys {
+		gcm, err := secretCipher(key)
+		if err != nil {
+			return "", false, err
+		}
+
+		if len(raw) < gcm.NonceSize() {
+			return "", false, errors.New("sealed secret is too short")
+		}
+
+		nonce, ciphertext := raw[:gcm.NonceSize()], raw[gcm.NonceSize():]
+		plain, err := gcm.Open(nil, nonce, ciphertext, nil)
+		if err != nil {
+			continue
+		}
+
+		return string(plain), totpEncryptionKey != nil && i == 0, nil
+	}
+
+	return "", false, errors.New("sealed secret does not open with any known key")
+}
+
+func secretCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+)
+
+// withPepperRotation configures an encryption key and a retired pepper for
+// the duration of the test.
+func withPepperRotation(t *testing.T, key string, retired map[string]string) {
+	t.Helper()
+
+	previousKey, previousHashing := totpEncryptionKey, passwordHashing
+	t.Cleanup(func() {
+		totpEncryptionKey, passwordHashing = previousKey, previousHashing
+	})
+
+	if key == "" {
+		totpEncryptionKey = nil
+	} else {
+		ConfigureTOTPEncryption(key)
+	}
+	passwordHashing.RetiredPeppers = retired
+}
+
+func sealWithLegacyKey(t *testing.T, plain, pepper string) string {
+	t.Helper()
+
+	gcm, err := secretCipher(secretKey(pepper))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		t.Fatal(err)
+	}
+
+	return base64.RawStdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(plain), nil))
+}
+
+func TestSealSecretUsesEncryptionKey(t *testing.T) {
+	withPepperRotation(t, "totp-key", nil)
+
+	sealed, err := sealSecret("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("sealSecret: %v", err)
+	}
+
+	plain, current, err := openSecret(sealed, "new-pepper")
+	if err != nil || plain != "JBSWY3DPEHPK3PXP" || !current {
+		t.Errorf("openSecret = %q, %v, %v, want the secret under the current key", plain, current, err)
+	}
+
+	// Rotating the pepper must not affect secrets sealed with the key.
+	if _, _, err := openSecret(sealed, "another-pepper"); err != nil {
+		t.Errorf("openSecret after pepper rotation: %v", err)
+	}
+}
+
+func TestOpenSecretFallsBackToPepperKeys(t *testing.T) {
+	withPepperRotation(t, "totp-key", map[string]string{"1": "old-pepper"})
+
+	for name, pepper := range map[string]string{
+		"current pepper": "new-pepper",
+		"retired pepper": "old-pepper",
+	} {
+		t.Run(name, func(t *testing.T) {
+			sealed := sealWithLegacyKey(t, "JBSWY3DPEHPK3PXP", pepper)
+
+			plain, current, err := openSecret(sealed, "new-pepper")
+			if err != nil || plain != "JBSWY3DPEHPK3PXP" || current {
+				t.Errorf("openSecret = %q, %v, %v, want the secret under a legacy key", plain, current, err)
+			}
+		})
+	}
+
+	sealed := sealWithLegacyKey(t, "JBSWY3DPEHPK3PXP", "unknown-pepper")
+	if _, _, err := openSecret(sealed, "new-pepper"); err == nil {
+		t.Error("openSecret opened a secret sealed with an unknown pepper")
+	}
+}
+
+func TestSealSecretRequiresKey(t *testing.T) {
+	withPepperRotation(t, "", nil)
+
+	if _, err := sealSecret("JBSWY3DPEHPK3PXP"); err != ErrTOTPKeyNotConfigured {
+		t.Errorf("sealSecret = %v, want ErrTOTPKeyNotConfigured", err)
+	}
+}
@@ -2,18 +2,15 @@ package models
 
 import (
 	"context"
-	"crypto/rand"
-	"crypto/subtle"
 	"database/sql"
-	"encoding/base64"
 	"errors"
-	"fmt"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
-	"golang.org/x/crypto/argon2"
 
 	"mbvlabs/models/internal/db"
 	"mbvlabs/internal/storage"
@@ -27,70 +24,17 @@ type User struct {
 	EmailValidatedAt time.Time
 	Password         []byte
 	IsAdmin          bool
+	// DeletionScheduledAt is when the account will be purged. It is zero
+	// unless the user has asked for their account to be deleted.
+	DeletionScheduledAt time.Time
 }
 
 func (u User) HasValidatedEmail() bool {
 	return !u.EmailValidatedAt.IsZero()
 }
 
-func (u User) ValidPassword(providedPassword, pepper string) (bool, error) {
-	parts := strings.Split(string(u.Password), ":")
-	if len(parts) != 2 {
-		return false, fmt.Errorf("invalid stored password format")
-	}
-
-	expectedHash, err := base64.RawStdEncoding.DecodeString(parts[0])
-	if err != nil {
-		return false, fmt.Errorf("failed to decode hash: %w", err)
-	}
-
-	salt, err := base64.RawStdEncoding.DecodeString(parts[1])
-	if err != nil {
-		return false, fmt.Errorf("failed to decode pepper: %w", err)
-	}
-
-	newHash := argon2.IDKey(
-		[]byte(providedPassword+pepper),
-		salt,
-		2,
-		19*1024,
-		1,
-		uint32(len(expectedHash)),
-	)
-
-	return subtle.ConstantTimeCompare(newHash, expectedHash) == 1, nil
-}
-
-func generateSalt(size int) ([]byte, error) {
-	salt := make([]byte, size)
-	_, err := rand.Read(salt)
-	if err != nil {
-		return nil, err
-	}
-
-	return salt, nil
-}
-
-func HashPassword(password, pepper string) (string, error) {
-	salt, err := generateSalt(16)
-	if err != nil {
-		return "", err
-	}
-
-	hash := argon2.IDKey(
-		[]byte(password+pepper),
-		[]byte(salt),
-		2,
-		19*1024,
-		1,
-		32,
-	)
-
-	encodedHash := fmt.Sprintf("%s:%s",
-		base64.RawStdEncoding.EncodeToString(hash),
-		base64.RawStdEncoding.EncodeToString(salt))
-
-	return encodedHash, nil
+func (u User) IsDeletionScheduled() bool {
+	return !u.DeletionScheduledAt.IsZero()
 }
 
 func FindUser(
@@ -119,9 +63,12 @@ func FindUserByEmail(
 	return rowToUser(row)
 }
 
+// PasswordPair is a new password and its confirmation. Length and strength
+// rules live in the password policy service, which runs before a pair gets
+// here.
 type PasswordPair struct {
-	Password        string `validate:"required,min=8,max=72"`
-	ConfirmPassword string `validate:"required,min=8,max=72"`
+	Password        string `validate:"required"`
+	ConfirmPassword string `validate:"required,eqfield=Password"`
 }
 
 type CreateUserData struct {
@@ -153,6 +100,10 @@ func CreateUser(
 	}
 	row, err := queries.InsertUser(ctx, exec, params)
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			return User{}, ErrEmailTaken
+		}
 		return User{}, err
 	}
 
@@ -223,6 +174,88 @@ func UpdateUser(
 	return rowToUser(row)
 }
 
+// ScheduleUserDeletion marks the account for purging at the given time. A
+// zero time cancels a scheduled deletion.
+func ScheduleUserDeletion(
+	ctx context.Context,
+	exec storage.Executor,
+	id uuid.UUID,
+	at time.Time,
+) (User, error) {
+	row, err := queries.UpdateUserDeletionScheduledAt(ctx, exec, db.UpdateUserDeletionScheduledAtParams{
+		ID: id,
+		DeletionScheduledAt: pgtype.Timestamptz{
+			Time:  at,
+			Valid: !at.IsZero(),
+		},
+	})
+	if err != nil {
+		return User{}, err
+	}
+
+	return rowToUser(row)
+}
+
+// FindUsersDueForDeletion returns users whose grace period has ended, the
+// longest overdue first.
+func FindUsersDueForDeletion(
+	ctx context.Context,
+	exec storage.Executor,
+	limit int64,
+	offset int64,
+) ([]User, error) {
+	rows, err := queries.QueryUsersDueForDeletion(ctx, exec, db.QueryUsersDueForDeletionParams{
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]User, len(rows))
+	for i, row := range rows {
+		user, err := rowToUser(row)
+		if err != nil {
+			return nil, err
+		}
+		users[i] = user
+	}
+
+	return users, nil
+}
+
+// FindUnverifiedUsersCreatedBefore returns accounts that signed up before
+// createdBefore and never verified their email, oldest first. Admins are
+// never returned.
+func FindUnverifiedUsersCreatedBefore(
+	ctx context.Context,
+	exec storage.Executor,
+	createdBefore time.Time,
+	limit int64,
+) ([]User, error) {
+	rows, err := queries.QueryUnverifiedUsersCreatedBefore(ctx, exec, db.QueryUnverifiedUsersCreatedBeforeParams{
+		CreatedBefore: pgtype.Timestamptz{
+			Time:  createdBefore,
+			Valid: true,
+		},
+		Limit: limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]User, len(rows))
+	for i, row := range rows {
+		user, err := rowToUser(row)
+		if err != nil {
+			return nil, err
+		}
+		users[i] = user
+	}
+
+	return users, nil
+}
+
 func DestroyUser(
 	ctx context.Context,
 	exec storage.Executor,
@@ -239,9 +272,12 @@ type PaginatedUsers struct {
 	TotalPages int64
 }
 
+// PaginateUsers lists users newest first. A non-empty search narrows the
+// list to emails containing it.
 func PaginateUsers(
 	ctx context.Context,
 	exec storage.Executor,
+	search string,
 	page int64,
 	pageSize int64,
 ) (PaginatedUsers, error) {
@@ -257,7 +293,9 @@ func PaginateUsers(
 
 	offset := (page - 1) * pageSize
 
-	totalCount, err := queries.CountUsers(ctx, exec)
+	search = strings.TrimSpace(search)
+
+	totalCount, err := queries.CountUsers(ctx, exec, escapeLike(search))
 	if err != nil {
 		return PaginatedUsers{}, err
 	}
@@ -266,6 +304,7 @@ func PaginateUsers(
 		ctx,
 		exec,
 		db.QueryPaginatedUsersParams{
+			Search: escapeLike(search),
 			Limit:  pageSize,
 			Offset: offset,
 		},
@@ -296,12 +335,13 @@ func PaginateUsers(
 
 func rowToUser(row db.User) (User, error) {
 	return User{
-		ID:               row.ID,
-		CreatedAt:        row.CreatedAt.Time,
-		UpdatedAt:        row.UpdatedAt.Time,
-		Email:            row.Email,
-		EmailValidatedAt: row.EmailValidatedAt.Time,
-		Password:         row.Password,
-		IsAdmin:          row.IsAdmin,
+		ID:                  row.ID,
+		CreatedAt:           row.CreatedAt.Time,
+		UpdatedAt:           row.UpdatedAt.Time,
+		Email:               row.Email,
+		EmailValidatedAt:    row.EmailValidatedAt.Time,
+		Password:            row.Password,
+		IsAdmin:             row.IsAdmin,
+		DeletionScheduledAt: row.DeletionScheduledAt.Time,
 	}, nil
 }
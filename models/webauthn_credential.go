@@ -0,0 +1,154 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models/internal/db"
+)
+
+// WebAuthnCredential is a passkey registered by a user. The full credential
+// record is kept so sign counters and backup flags can be checked on every
+// assertion.
+type WebAuthnCredential struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	UserID       uuid.UUID
+	CredentialID []byte
+	Name         string
+	Credential   webauthn.Credential
+	LastUsedAt   time.Time
+}
+
+func FindWebAuthnCredentialByCredentialID(
+	ctx context.Context,
+	exec storage.Executor,
+	credentialID []byte,
+) (WebAuthnCredential, error) {
+	row, err := queries.QueryWebauthnCredentialByCredentialID(ctx, exec, credentialID)
+	if err != nil {
+		return WebAuthnCredential{}, err
+	}
+
+	return rowToWebAuthnCredential(row)
+}
+
+func FindWebAuthnCredentialsByUserID(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+) ([]WebAuthnCredential, error) {
+	rows, err := queries.QueryWebauthnCredentialsByUserID(ctx, exec, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := make([]WebAuthnCredential, len(rows))
+	for i, row := range rows {
+		credential, err := rowToWebAuthnCredential(row)
+		if err != nil {
+			return nil, err
+		}
+
+		credentials[i] = credential
+	}
+
+	return credentials, nil
+}
+
+type CreateWebAuthnCredentialData struct {
+	UserID     uuid.UUID `validate:"required"`
+	Name       string    `validate:"required,max=100"`
+	Credential webauthn.Credential
+}
+
+func CreateWebAuthnCredential(
+	ctx context.Context,
+	exec storage.Executor,
+	data CreateWebAuthnCredentialData,
+) (WebAuthnCredential, error) {
+	if err := validate.Struct(data); err != nil {
+		return WebAuthnCredential{}, errors.Join(ErrDomainValidation, err)
+	}
+
+	if len(data.Credential.ID) == 0 {
+		return WebAuthnCredential{}, errors.Join(
+			ErrDomainValidation,
+			errors.New("credential id is required"),
+		)
+	}
+
+	credential, err := json.Marshal(data.Credential)
+	if err != nil {
+		return WebAuthnCredential{}, err
+	}
+
+	row, err := queries.InsertWebauthnCredential(ctx, exec, db.InsertWebauthnCredentialParams{
+		ID:           uuid.New(),
+		UserID:       data.UserID,
+		CredentialID: data.Credential.ID,
+		Name:         data.Name,
+		Credential:   credential,
+	})
+	if err != nil {
+		return WebAuthnCredential{}, err
+	}
+
+	return rowToWebAuthnCredential(row)
+}
+
+// TouchWebAuthnCredential stores the credential record as updated by a
+// successful assertion, which carries the new sign count and backup state.
+func TouchWebAuthnCredential(
+	ctx context.Context,
+	exec storage.Executor,
+	id uuid.UUID,
+	credential webauthn.Credential,
+) error {
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+
+	return queries.UpdateWebauthnCredentialAfterLogin(ctx, exec, db.UpdateWebauthnCredentialAfterLoginParams{
+		ID:         id,
+		Credential: data,
+	})
+}
+
+func DestroyWebAuthnCredential(
+	ctx context.Context,
+	exec storage.Executor,
+	id uuid.UUID,
+	userID uuid.UUID,
+) error {
+	return queries.DeleteWebauthnCredentialByIDAndUserID(ctx, exec, db.DeleteWebauthnCredentialByIDAndUserIDParams{
+		ID:     id,
+		UserID: userID,
+	})
+}
+
+func rowToWebAuthnCredential(row db.WebauthnCredential) (WebAuthnCredential, error) {
+	var credential webauthn.Credential
+	if err := json.Unmarshal(row.Credential, &credential); err != nil {
+		return WebAuthnCredential{}, err
+	}
+
+	return WebAuthnCredential{
+		ID:           row.ID,
+		CreatedAt:    row.CreatedAt.Time,
+		UpdatedAt:    row.UpdatedAt.Time,
+		UserID:       row.UserID,
+		CredentialID: row.CredentialID,
+		Name:         row.Name,
+		Credential:   credential,
+		LastUsedAt:   row.LastUsedAt.Time,
+	}, nil
+}
@@ -0,0 +1,9 @@
+package jobs
+
+import "github.com/google/uuid"
+
+type ExportUserDataArgs struct {
+	UserID uuid.UUID
+}
+
+func (ExportUserDataArgs) Kind() string { return "export_user_data" }
@@ -0,0 +1,5 @@
+package jobs
+
+type PurgeDeletedUsersArgs struct{}
+
+func (PurgeDeletedUsersArgs) Kind() string { return "purge_deleted_users" }
@@ -0,0 +1,5 @@
+package jobs
+
+type PurgeExpiredTokensArgs struct{}
+
+func (PurgeExpiredTokensArgs) Kind() string { return "purge_expired_tokens" }
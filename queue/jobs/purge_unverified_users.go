@@ -0,0 +1,5 @@
+package jobs
+
+type PurgeUnverifiedUsersArgs struct{}
+
+func (PurgeUnverifiedUsersArgs) Kind() string { return "purge_unverified_users" }
@@ -35,13 +35,15 @@ func NewProcessor(
 	ctx context.Context,
 	db storage.Pool,
 	workers *river.Workers,
+	periodicJobs []*river.PeriodicJob,
 ) (Processor, error) {
 	riverClient, err := river.NewClient(riverpgxv5.New(db.Conn()), &river.Config{
 		Queues: map[string]river.QueueConfig{
 			river.QueueDefault: {MaxWorkers: 100},
 		},
-		Logger:  slog.Default(),
-		Workers: workers,
+		Logger:       slog.Default(),
+		Workers:      workers,
+		PeriodicJobs: periodicJobs,
 	})
 	if err != nil {
 		return Processor{}, err
@@ -109,6 +111,17 @@ func (i *InsertOnly) InsertTx(
 
 var _ storage.InsertQueue = (*InsertOnly)(nil)
 
+// InsertOnlyFromContext returns an InsertOnly backed by the client working
+// the current job, so workers can enqueue follow-up jobs.
+func InsertOnlyFromContext(ctx context.Context) (InsertOnly, error) {
+	riverClient, err := river.ClientFromContextSafely[pgx.Tx](ctx)
+	if err != nil {
+		return InsertOnly{}, err
+	}
+
+	return InsertOnly{riverClient}, nil
+}
+
 func NewInsertOnly(db storage.Pool, workers *river.Workers) (InsertOnly, error) {
 	riverClient, err := river.NewClient(riverpgxv5.New(db.Conn()), &river.Config{
 		Workers: workers,
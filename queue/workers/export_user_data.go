@@ -0,0 +1,45 @@
+package workers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/riverqueue/river"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/queue"
+	"mbvlabs/queue/jobs"
+	"mbvlabs/services"
+)
+
+type ExportUserDataWorker struct {
+	river.WorkerDefaults[jobs.ExportUserDataArgs]
+	db     storage.Pool
+	pepper string
+}
+
+func NewExportUserDataWorker(db storage.Pool, pepper string) *ExportUserDataWorker {
+	return &ExportUserDataWorker{
+		db:     db,
+		pepper: pepper,
+	}
+}
+
+func (w *ExportUserDataWorker) Work(ctx context.Context, job *river.Job[jobs.ExportUserDataArgs]) error {
+	insertOnly, err := queue.InsertOnlyFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = services.ExportUserData(ctx, w.db, insertOnly, w.pepper, job.Args.UserID)
+	if err != nil {
+		// The account was deleted after the export was requested.
+		if errors.Is(err, sql.ErrNoRows) {
+			return river.JobCancel(err)
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/riverqueue/river"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/queue/jobs"
+	"mbvlabs/services"
+)
+
+type PurgeDeletedUsersWorker struct {
+	river.WorkerDefaults[jobs.PurgeDeletedUsersArgs]
+	db storage.Pool
+}
+
+func NewPurgeDeletedUsersWorker(db storage.Pool) *PurgeDeletedUsersWorker {
+	return &PurgeDeletedUsersWorker{
+		db: db,
+	}
+}
+
+// Work purges what it can. Accounts that failed are logged and left for the
+// next run.
+func (w *PurgeDeletedUsersWorker) Work(ctx context.Context, job *river.Job[jobs.PurgeDeletedUsersArgs]) error {
+	if err := services.PurgeDeletedUsers(ctx, w.db); err != nil {
+		slog.ErrorContext(
+			ctx,
+			"failed to purge deleted users",
+			"error",
+			err,
+		)
+		return err
+	}
+
+	return nil
+}
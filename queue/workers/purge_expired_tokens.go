@@ -0,0 +1,35 @@
+package workers
+
+import (
+	"context"
+
+	"github.com/riverqueue/river"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/queue/jobs"
+	"mbvlabs/services"
+)
+
+type PurgeExpiredTokensWorker struct {
+	river.WorkerDefaults[jobs.PurgeExpiredTokensArgs]
+	db      storage.Pool
+	deleted metric.Int64Counter
+}
+
+func NewPurgeExpiredTokensWorker(db storage.Pool, deleted metric.Int64Counter) *PurgeExpiredTokensWorker {
+	return &PurgeExpiredTokensWorker{
+		db:      db,
+		deleted: deleted,
+	}
+}
+
+func (w *PurgeExpiredTokensWorker) Work(ctx context.Context, job *river.Job[jobs.PurgeExpiredTokensArgs]) error {
+	deleted, err := services.PurgeExpiredTokens(ctx, w.db)
+
+	// Batches deleted before a failure are gone all the same.
+	w.deleted.Add(ctx, deleted, metric.WithAttributes(attribute.String("kind", "expired_tokens")))
+
+	return err
+}
@@ -0,0 +1,42 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/riverqueue/river"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/queue/jobs"
+	"mbvlabs/services"
+)
+
+type PurgeUnverifiedUsersWorker struct {
+	river.WorkerDefaults[jobs.PurgeUnverifiedUsersArgs]
+	db      storage.Pool
+	maxAge  time.Duration
+	deleted metric.Int64Counter
+}
+
+func NewPurgeUnverifiedUsersWorker(
+	db storage.Pool,
+	maxAge time.Duration,
+	deleted metric.Int64Counter,
+) *PurgeUnverifiedUsersWorker {
+	return &PurgeUnverifiedUsersWorker{
+		db:      db,
+		maxAge:  maxAge,
+		deleted: deleted,
+	}
+}
+
+func (w *PurgeUnverifiedUsersWorker) Work(ctx context.Context, job *river.Job[jobs.PurgeUnverifiedUsersArgs]) error {
+	deleted, err := services.PurgeUnverifiedUsers(ctx, w.db, w.maxAge)
+
+	// Accounts removed before a failure are gone all the same.
+	w.deleted.Add(ctx, deleted, metric.WithAttributes(attribute.String("kind", "unverified_users")))
+
+	return err
+}
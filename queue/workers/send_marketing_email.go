@@ -6,22 +6,30 @@ import (
 	"github.com/riverqueue/river"
 
 	"mbvlabs/email"
+	"mbvlabs/internal/storage"
 	"mbvlabs/queue/jobs"
 )
 
 type SendMarketingEmailWorker struct {
 	river.WorkerDefaults[jobs.SendMarketingEmailArgs]
+	db     storage.Pool
 	sender email.MarketingSender
 }
 
-func NewSendMarketingEmailWorker(sender email.MarketingSender) *SendMarketingEmailWorker {
+func NewSendMarketingEmailWorker(db storage.Pool, sender email.MarketingSender) *SendMarketingEmailWorker {
 	return &SendMarketingEmailWorker{
+		db:     db,
 		sender: sender,
 	}
 }
 
 func (w *SendMarketingEmailWorker) Work(ctx context.Context, job *river.Job[jobs.SendMarketingEmailArgs]) error {
-	err := email.SendMarketing(ctx, job.Args.Data, w.sender)
+	attempt := email.Attempt{
+		JobID: job.ID,
+		Final: job.Attempt >= job.MaxAttempts,
+	}
+
+	err := email.SendMarketing(ctx, w.db.Conn(), attempt, job.Args.Data, w.sender)
 	if err != nil {
 		if !email.IsRetryable(err) {
 			return river.JobCancel(err)
@@ -6,22 +6,30 @@ import (
 	"github.com/riverqueue/river"
 
 	"mbvlabs/email"
+	"mbvlabs/internal/storage"
 	"mbvlabs/queue/jobs"
 )
 
 type SendTransactionalEmailWorker struct {
 	river.WorkerDefaults[jobs.SendTransactionalEmailArgs]
+	db     storage.Pool
 	sender email.TransactionalSender
 }
 
-func NewSendTransactionalEmailWorker(sender email.TransactionalSender) *SendTransactionalEmailWorker {
+func NewSendTransactionalEmailWorker(db storage.Pool, sender email.TransactionalSender) *SendTransactionalEmailWorker {
 	return &SendTransactionalEmailWorker{
+		db:     db,
 		sender: sender,
 	}
 }
 
 func (w *SendTransactionalEmailWorker) Work(ctx context.Context, job *river.Job[jobs.SendTransactionalEmailArgs]) error {
-	err := email.SendTransactional(ctx, job.Args.Data, w.sender)
+	attempt := email.Attempt{
+		JobID: job.ID,
+		Final: job.Attempt >= job.MaxAttempts,
+	}
+
+	err := email.SendTransactional(ctx, w.db.Conn(), attempt, job.Args.Data, w.sender)
 	if err != nil {
 		if !email.IsRetryable(err) {
 			return river.JobCancel(err)
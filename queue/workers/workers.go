@@ -2,21 +2,86 @@
 package workers
 
 import (
+	"time"
+
 	"github.com/riverqueue/river"
 
 	"mbvlabs/email"
+	"mbvlabs/internal/storage"
+	"mbvlabs/queue/jobs"
+	"mbvlabs/telemetry"
 )
 
-func Register(transactionalSender email.TransactionalSender, marketingSender email.MarketingSender) (*river.Workers, error) {
+// Register adds every worker to a new set. unverifiedUserMaxAge is how long
+// an account may go without verifying its email before the cleanup job
+// removes it; zero keeps such accounts.
+func Register(
+	db storage.Pool,
+	pepper string,
+	unverifiedUserMaxAge time.Duration,
+	transactionalSender email.TransactionalSender,
+	marketingSender email.MarketingSender,
+) (*river.Workers, error) {
 	wrks := river.NewWorkers()
 
-	if err := river.AddWorkerSafely(wrks, NewSendTransactionalEmailWorker(transactionalSender)); err != nil {
+	cleanupDeleted, err := telemetry.CleanupDeletedTotal()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := river.AddWorkerSafely(wrks, NewSendTransactionalEmailWorker(db, transactionalSender)); err != nil {
+		return nil, err
+	}
+
+	if err := river.AddWorkerSafely(wrks, NewSendMarketingEmailWorker(db, marketingSender)); err != nil {
+		return nil, err
+	}
+
+	if err := river.AddWorkerSafely(wrks, NewExportUserDataWorker(db, pepper)); err != nil {
 		return nil, err
 	}
 
-	if err := river.AddWorkerSafely(wrks, NewSendMarketingEmailWorker(marketingSender)); err != nil {
+	if err := river.AddWorkerSafely(wrks, NewPurgeDeletedUsersWorker(db)); err != nil {
+		return nil, err
+	}
+
+	if err := river.AddWorkerSafely(wrks, NewPurgeExpiredTokensWorker(db, cleanupDeleted)); err != nil {
+		return nil, err
+	}
+
+	if err := river.AddWorkerSafely(
+		wrks,
+		NewPurgeUnverifiedUsersWorker(db, unverifiedUserMaxAge, cleanupDeleted),
+	); err != nil {
 		return nil, err
 	}
 
 	return wrks, nil
 }
+
+// PeriodicJobs lists the jobs the leader enqueues on a schedule.
+func PeriodicJobs() []*river.PeriodicJob {
+	return []*river.PeriodicJob{
+		river.NewPeriodicJob(
+			river.PeriodicInterval(time.Hour),
+			func() (river.JobArgs, *river.InsertOpts) {
+				return jobs.PurgeDeletedUsersArgs{}, nil
+			},
+			&river.PeriodicJobOpts{RunOnStart: true},
+		),
+		river.NewPeriodicJob(
+			river.PeriodicInterval(time.Hour),
+			func() (river.JobArgs, *river.InsertOpts) {
+				return jobs.PurgeExpiredTokensArgs{}, nil
+			},
+			&river.PeriodicJobOpts{RunOnStart: true},
+		),
+		river.NewPeriodicJob(
+			river.PeriodicInterval(24*time.Hour),
+			func() (river.JobArgs, *river.InsertOpts) {
+				return jobs.PurgeUnverifiedUsersArgs{}, nil
+			},
+			&river.PeriodicJobOpts{RunOnStart: true},
+		),
+	}
+}
@@ -0,0 +1,29 @@
+package router
+
+import (
+	"net/http"
+
+	"mbvlabs/controllers"
+	"mbvlabs/router/middleware"
+	"mbvlabs/router/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerAccountDeletionsRoutes(handler *echo.Echo, accountDeletionsController controllers.AccountDeletions) {
+	handler.Add(
+		http.MethodPost, routes.AccountDeletionCreate.Path(), accountDeletionsController.Create, middleware.AuthOnly, middleware.NotWhileImpersonating,
+	).Name = routes.AccountDeletionCreate.Name()
+
+	handler.Add(
+		http.MethodDelete, routes.AccountDeletionDestroy.Path(), accountDeletionsController.Destroy, middleware.AuthOnly, middleware.NotWhileImpersonating,
+	).Name = routes.AccountDeletionDestroy.Name()
+
+	handler.Add(
+		http.MethodGet, routes.AccountDeletionCancel.Path(), accountDeletionsController.ShowCancel,
+	).Name = routes.AccountDeletionCancel.Name()
+
+	handler.Add(
+		http.MethodPost, routes.AccountDeletionCancel.Path(), accountDeletionsController.Cancel,
+	).Name = routes.AccountDeletionCancel.Name()
+}
@@ -0,0 +1,17 @@
+package router
+
+import (
+	"net/http"
+
+	"mbvlabs/controllers"
+	"mbvlabs/router/middleware"
+	"mbvlabs/router/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerAccountsRoutes(handler *echo.Echo, accountsController controllers.Accounts) {
+	handler.Add(
+		http.MethodGet, routes.AccountShow.Path(), accountsController.Show, middleware.AuthOnly,
+	).Name = routes.AccountShow.Name()
+}
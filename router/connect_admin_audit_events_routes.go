@@ -0,0 +1,24 @@
+package router
+
+import (
+	"net/http"
+
+	"mbvlabs/controllers"
+	"mbvlabs/models"
+	"mbvlabs/router/middleware"
+	"mbvlabs/router/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerAdminAuditEventsRoutes(handler *echo.Echo, adminAuditEventsController controllers.AdminAuditEvents) {
+	canViewAudit := middleware.RequirePermission(models.PermissionAuditView)
+
+	handler.Add(
+		http.MethodGet, routes.AdminAuditEventIndex.Path(), adminAuditEventsController.Index, canViewAudit,
+	).Name = routes.AdminAuditEventIndex.Name()
+
+	handler.Add(
+		http.MethodGet, routes.AdminAuditEventExport.Path(), adminAuditEventsController.Export, canViewAudit,
+	).Name = routes.AdminAuditEventExport.Name()
+}
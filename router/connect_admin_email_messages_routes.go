@@ -0,0 +1,24 @@
+package router
+
+import (
+	"net/http"
+
+	"mbvlabs/controllers"
+	"mbvlabs/models"
+	"mbvlabs/router/middleware"
+	"mbvlabs/router/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerAdminEmailMessagesRoutes(handler *echo.Echo, adminEmailMessagesController controllers.AdminEmailMessages) {
+	canViewEmails := middleware.RequirePermission(models.PermissionEmailsView)
+
+	handler.Add(
+		http.MethodGet, routes.AdminEmailMessageIndex.Path(), adminEmailMessagesController.Index, canViewEmails,
+	).Name = routes.AdminEmailMessageIndex.Name()
+
+	handler.Add(
+		http.MethodGet, routes.AdminEmailMessageShow.Path(), adminEmailMessagesController.Show, canViewEmails,
+	).Name = routes.AdminEmailMessageShow.Name()
+}
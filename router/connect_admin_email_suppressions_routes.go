@@ -0,0 +1,22 @@
+package router
+
+import (
+	"net/http"
+
+	"mbvlabs/controllers"
+	"mbvlabs/models"
+	"mbvlabs/router/middleware"
+	"mbvlabs/router/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerAdminEmailSuppressionsRoutes(handler *echo.Echo, adminEmailSuppressionsController controllers.AdminEmailSuppressions) {
+	handler.Add(
+		http.MethodGet, routes.AdminEmailSuppressionIndex.Path(), adminEmailSuppressionsController.Index, middleware.RequirePermission(models.PermissionEmailsManage),
+	).Name = routes.AdminEmailSuppressionIndex.Name()
+
+	handler.Add(
+		http.MethodDelete, routes.AdminEmailSuppressionDestroy.Path(), adminEmailSuppressionsController.Destroy, middleware.RequirePermission(models.PermissionEmailsManage),
+	).Name = routes.AdminEmailSuppressionDestroy.Name()
+}
@@ -0,0 +1,44 @@
+package router
+
+import (
+	"net/http"
+
+	"mbvlabs/controllers"
+	"mbvlabs/models"
+	"mbvlabs/router/middleware"
+	"mbvlabs/router/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerAdminUsersRoutes(handler *echo.Echo, adminUsersController controllers.AdminUsers) {
+	canManageUsers := middleware.RequirePermission(models.PermissionUsersManage)
+
+	handler.Add(
+		http.MethodGet, routes.AdminUserIndex.Path(), adminUsersController.Index, canManageUsers,
+	).Name = routes.AdminUserIndex.Name()
+
+	handler.Add(
+		http.MethodGet, routes.AdminUserShow.Path(), adminUsersController.Show, canManageUsers,
+	).Name = routes.AdminUserShow.Name()
+
+	handler.Add(
+		http.MethodPost, routes.AdminUserVerifyEmail.Path(), adminUsersController.VerifyEmail, canManageUsers,
+	).Name = routes.AdminUserVerifyEmail.Name()
+
+	handler.Add(
+		http.MethodPost, routes.AdminUserToggleAdmin.Path(), adminUsersController.ToggleAdmin, canManageUsers,
+	).Name = routes.AdminUserToggleAdmin.Name()
+
+	handler.Add(
+		http.MethodPost, routes.AdminUserForcePasswordReset.Path(), adminUsersController.ForcePasswordReset, canManageUsers,
+	).Name = routes.AdminUserForcePasswordReset.Name()
+
+	handler.Add(
+		http.MethodPost, routes.AdminUserResendVerification.Path(), adminUsersController.ResendVerification, canManageUsers,
+	).Name = routes.AdminUserResendVerification.Name()
+
+	handler.Add(
+		http.MethodDelete, routes.AdminUserDestroy.Path(), adminUsersController.Destroy, canManageUsers,
+	).Name = routes.AdminUserDestroy.Name()
+}
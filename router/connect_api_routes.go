@@ -4,13 +4,19 @@ import (
 	"net/http"
 
 	"mbvlabs/controllers"
+	"mbvlabs/models"
+	"mbvlabs/router/middleware"
 	"mbvlabs/router/routes"
 
 	"github.com/labstack/echo/v4"
 )
 
-func registerAPIRoutes(handler *echo.Echo, apiController controllers.API) {
+func registerAPIRoutes(handler *echo.Echo, mw middleware.Middleware, apiController controllers.API) {
 	handler.Add(
 		http.MethodGet, routes.Health.Path(), apiController.Health,
 	).Name = routes.Health.Name()
+
+	handler.Add(
+		http.MethodGet, routes.APIMe.Path(), apiController.Me, mw.APITokenAuth, middleware.RequireAPIScope(models.APIScopeRead),
+	).Name = routes.APIMe.Name()
 }
@@ -0,0 +1,25 @@
+package router
+
+import (
+	"net/http"
+
+	"mbvlabs/controllers"
+	"mbvlabs/router/middleware"
+	"mbvlabs/router/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerAPITokensRoutes(handler *echo.Echo, apiTokensController controllers.APITokens) {
+	handler.Add(
+		http.MethodGet, routes.APITokenIndex.Path(), apiTokensController.Index, middleware.AuthOnly,
+	).Name = routes.APITokenIndex.Name()
+
+	handler.Add(
+		http.MethodPost, routes.APITokenCreate.Path(), apiTokensController.Create, middleware.AuthOnly, middleware.NotWhileImpersonating,
+	).Name = routes.APITokenCreate.Name()
+
+	handler.Add(
+		http.MethodDelete, routes.APITokenDestroy.Path(), apiTokensController.Destroy, middleware.AuthOnly, middleware.NotWhileImpersonating,
+	).Name = routes.APITokenDestroy.Name()
+}
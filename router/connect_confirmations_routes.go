@@ -17,4 +17,8 @@ func registerConfirmationsRoutes(handler *echo.Echo, confirmationsController con
 	handler.Add(
 		http.MethodPost, routes.ConfirmationCreate.Path(), confirmationsController.Create,
 	).Name = routes.ConfirmationCreate.Name()
+
+	handler.Add(
+		http.MethodPost, routes.ConfirmationResend.Path(), confirmationsController.Resend,
+	).Name = routes.ConfirmationResend.Name()
 }
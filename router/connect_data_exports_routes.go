@@ -0,0 +1,21 @@
+package router
+
+import (
+	"net/http"
+
+	"mbvlabs/controllers"
+	"mbvlabs/router/middleware"
+	"mbvlabs/router/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerDataExportsRoutes(handler *echo.Echo, dataExportsController controllers.DataExports) {
+	handler.Add(
+		http.MethodPost, routes.DataExportCreate.Path(), dataExportsController.Create, middleware.AuthOnly, middleware.NotWhileImpersonating,
+	).Name = routes.DataExportCreate.Name()
+
+	handler.Add(
+		http.MethodGet, routes.DataExportShow.Path(), dataExportsController.Show, middleware.AuthOnly, middleware.NotWhileImpersonating,
+	).Name = routes.DataExportShow.Name()
+}
@@ -0,0 +1,25 @@
+package router
+
+import (
+	"net/http"
+
+	"mbvlabs/controllers"
+	"mbvlabs/router/middleware"
+	"mbvlabs/router/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerDevicesRoutes(handler *echo.Echo, devicesController controllers.Devices) {
+	handler.Add(
+		http.MethodGet, routes.DeviceIndex.Path(), devicesController.Index, middleware.AuthOnly,
+	).Name = routes.DeviceIndex.Name()
+
+	handler.Add(
+		http.MethodDelete, routes.DeviceDestroy.Path(), devicesController.Destroy, middleware.AuthOnly, middleware.NotWhileImpersonating,
+	).Name = routes.DeviceDestroy.Name()
+
+	handler.Add(
+		http.MethodDelete, routes.DeviceDestroyOthers.Path(), devicesController.DestroyOthers, middleware.AuthOnly, middleware.NotWhileImpersonating,
+	).Name = routes.DeviceDestroyOthers.Name()
+}
@@ -0,0 +1,33 @@
+package router
+
+import (
+	"net/http"
+
+	"mbvlabs/controllers"
+	"mbvlabs/router/middleware"
+	"mbvlabs/router/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerEmailChangesRoutes(handler *echo.Echo, emailChangesController controllers.EmailChanges) {
+	handler.Add(
+		http.MethodPost, routes.EmailChangeCreate.Path(), emailChangesController.Create, middleware.AuthOnly, middleware.NotWhileImpersonating,
+	).Name = routes.EmailChangeCreate.Name()
+
+	handler.Add(
+		http.MethodGet, routes.EmailChangeConfirm.Path(), emailChangesController.ShowConfirm,
+	).Name = routes.EmailChangeConfirm.Name()
+
+	handler.Add(
+		http.MethodPost, routes.EmailChangeConfirm.Path(), emailChangesController.Confirm,
+	).Name = routes.EmailChangeConfirm.Name()
+
+	handler.Add(
+		http.MethodGet, routes.EmailChangeRevert.Path(), emailChangesController.ShowRevert,
+	).Name = routes.EmailChangeRevert.Name()
+
+	handler.Add(
+		http.MethodPost, routes.EmailChangeRevert.Path(), emailChangesController.Revert,
+	).Name = routes.EmailChangeRevert.Name()
+}
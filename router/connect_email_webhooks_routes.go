@@ -0,0 +1,20 @@
+package router
+
+import (
+	"net/http"
+
+	"mbvlabs/controllers"
+	"mbvlabs/router/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerEmailWebhooksRoutes(handler *echo.Echo, emailWebhooksController controllers.EmailWebhooks) {
+	handler.Add(
+		http.MethodPost, routes.PostmarkWebhook.Path(), emailWebhooksController.Postmark,
+	).Name = routes.PostmarkWebhook.Name()
+
+	handler.Add(
+		http.MethodPost, routes.SendGridWebhook.Path(), emailWebhooksController.SendGrid,
+	).Name = routes.SendGridWebhook.Name()
+}
@@ -0,0 +1,23 @@
+package router
+
+import (
+	"net/http"
+
+	"mbvlabs/controllers"
+	"mbvlabs/models"
+	"mbvlabs/router/middleware"
+	"mbvlabs/router/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerImpersonationsRoutes(handler *echo.Echo, impersonationsController controllers.Impersonations) {
+	handler.Add(
+		http.MethodPost, routes.ImpersonationCreate.Path(), impersonationsController.Create,
+		middleware.RequirePermission(models.PermissionUsersImpersonate), middleware.NotWhileImpersonating,
+	).Name = routes.ImpersonationCreate.Name()
+
+	handler.Add(
+		http.MethodDelete, routes.ImpersonationDestroy.Path(), impersonationsController.Destroy, middleware.AuthOnly,
+	).Name = routes.ImpersonationDestroy.Name()
+}
@@ -0,0 +1,28 @@
+package router
+
+import (
+	"net/http"
+
+	"mbvlabs/controllers"
+	"mbvlabs/router/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerMagicLinksRoutes(handler *echo.Echo, magicLinksController controllers.MagicLinks) {
+	handler.Add(
+		http.MethodGet, routes.MagicLinkNew.Path(), magicLinksController.New,
+	).Name = routes.MagicLinkNew.Name()
+
+	handler.Add(
+		http.MethodPost, routes.MagicLinkCreate.Path(), magicLinksController.Create,
+	).Name = routes.MagicLinkCreate.Name()
+
+	handler.Add(
+		http.MethodGet, routes.SessionMagicLink.Path(), magicLinksController.Show,
+	).Name = routes.SessionMagicLink.Name()
+
+	handler.Add(
+		http.MethodPost, routes.SessionMagicLink.Path(), magicLinksController.Consume,
+	).Name = routes.SessionMagicLink.Name()
+}
@@ -0,0 +1,20 @@
+package router
+
+import (
+	"net/http"
+
+	"mbvlabs/controllers"
+	"mbvlabs/router/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerOIDCSessionsRoutes(handler *echo.Echo, oidcSessionsController controllers.OIDCSessions) {
+	handler.Add(
+		http.MethodGet, routes.OIDCSessionNew.Path(), oidcSessionsController.New,
+	).Name = routes.OIDCSessionNew.Name()
+
+	handler.Add(
+		http.MethodGet, routes.OIDCSessionCallback.Path(), oidcSessionsController.Callback,
+	).Name = routes.OIDCSessionCallback.Name()
+}
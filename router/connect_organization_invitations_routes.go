@@ -0,0 +1,31 @@
+package router
+
+import (
+	"net/http"
+
+	"mbvlabs/controllers"
+	"mbvlabs/router/middleware"
+	"mbvlabs/router/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerOrganizationInvitationsRoutes(handler *echo.Echo, organizationInvitationsController controllers.OrganizationInvitations) {
+	handler.Add(
+		http.MethodPost, routes.OrganizationInvitationCreate.Path(), organizationInvitationsController.Create, middleware.AuthOnly, middleware.NotWhileImpersonating, middleware.RequireOrganization,
+	).Name = routes.OrganizationInvitationCreate.Name()
+
+	handler.Add(
+		http.MethodDelete, routes.OrganizationInvitationDestroy.Path(), organizationInvitationsController.Destroy, middleware.AuthOnly, middleware.NotWhileImpersonating, middleware.RequireOrganization,
+	).Name = routes.OrganizationInvitationDestroy.Name()
+
+	// The invitation page is public: the invited person may not have an
+	// account yet.
+	handler.Add(
+		http.MethodGet, routes.InvitationShow.Path(), organizationInvitationsController.Show,
+	).Name = routes.InvitationShow.Name()
+
+	handler.Add(
+		http.MethodPost, routes.InvitationAccept.Path(), organizationInvitationsController.Accept, middleware.NotWhileImpersonating,
+	).Name = routes.InvitationAccept.Name()
+}
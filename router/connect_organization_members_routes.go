@@ -0,0 +1,21 @@
+package router
+
+import (
+	"net/http"
+
+	"mbvlabs/controllers"
+	"mbvlabs/router/middleware"
+	"mbvlabs/router/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerOrganizationMembersRoutes(handler *echo.Echo, organizationMembersController controllers.OrganizationMembers) {
+	handler.Add(
+		http.MethodPut, routes.OrganizationMemberUpdate.Path(), organizationMembersController.Update, middleware.AuthOnly, middleware.NotWhileImpersonating, middleware.RequireOrganization,
+	).Name = routes.OrganizationMemberUpdate.Name()
+
+	handler.Add(
+		http.MethodDelete, routes.OrganizationMemberDestroy.Path(), organizationMembersController.Destroy, middleware.AuthOnly, middleware.NotWhileImpersonating, middleware.RequireOrganization,
+	).Name = routes.OrganizationMemberDestroy.Name()
+}
@@ -0,0 +1,29 @@
+package router
+
+import (
+	"net/http"
+
+	"mbvlabs/controllers"
+	"mbvlabs/router/middleware"
+	"mbvlabs/router/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerOrganizationsRoutes(handler *echo.Echo, organizationsController controllers.Organizations) {
+	handler.Add(
+		http.MethodGet, routes.OrganizationNew.Path(), organizationsController.New, middleware.AuthOnly,
+	).Name = routes.OrganizationNew.Name()
+
+	handler.Add(
+		http.MethodPost, routes.OrganizationCreate.Path(), organizationsController.Create, middleware.AuthOnly, middleware.NotWhileImpersonating,
+	).Name = routes.OrganizationCreate.Name()
+
+	handler.Add(
+		http.MethodGet, routes.OrganizationShow.Path(), organizationsController.Show, middleware.AuthOnly, middleware.RequireOrganization,
+	).Name = routes.OrganizationShow.Name()
+
+	handler.Add(
+		http.MethodPut, routes.OrganizationSwitch.Path(), organizationsController.Switch, middleware.AuthOnly,
+	).Name = routes.OrganizationSwitch.Name()
+}
@@ -0,0 +1,20 @@
+package router
+
+import (
+	"net/http"
+
+	"mbvlabs/controllers"
+	"mbvlabs/router/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerPasskeySessionsRoutes(handler *echo.Echo, passkeySessionsController controllers.PasskeySessions) {
+	handler.Add(
+		http.MethodPost, routes.PasskeySessionOptions.Path(), passkeySessionsController.Options,
+	).Name = routes.PasskeySessionOptions.Name()
+
+	handler.Add(
+		http.MethodPost, routes.PasskeySessionCreate.Path(), passkeySessionsController.Create,
+	).Name = routes.PasskeySessionCreate.Name()
+}
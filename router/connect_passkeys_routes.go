@@ -0,0 +1,29 @@
+package router
+
+import (
+	"net/http"
+
+	"mbvlabs/controllers"
+	"mbvlabs/router/middleware"
+	"mbvlabs/router/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerPasskeysRoutes(handler *echo.Echo, passkeysController controllers.Passkeys) {
+	handler.Add(
+		http.MethodGet, routes.PasskeyIndex.Path(), passkeysController.Index, middleware.AuthOnly,
+	).Name = routes.PasskeyIndex.Name()
+
+	handler.Add(
+		http.MethodPost, routes.PasskeyOptions.Path(), passkeysController.Options, middleware.AuthOnly, middleware.NotWhileImpersonating,
+	).Name = routes.PasskeyOptions.Name()
+
+	handler.Add(
+		http.MethodPost, routes.PasskeyCreate.Path(), passkeysController.Create, middleware.AuthOnly, middleware.NotWhileImpersonating,
+	).Name = routes.PasskeyCreate.Name()
+
+	handler.Add(
+		http.MethodDelete, routes.PasskeyDestroy.Path(), passkeysController.Destroy, middleware.AuthOnly, middleware.NotWhileImpersonating,
+	).Name = routes.PasskeyDestroy.Name()
+}
@@ -0,0 +1,21 @@
+package router
+
+import (
+	"net/http"
+
+	"mbvlabs/controllers"
+	"mbvlabs/router/middleware"
+	"mbvlabs/router/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerPasswordsRoutes(handler *echo.Echo, passwordsController controllers.Passwords) {
+	handler.Add(
+		http.MethodPost, routes.PasswordStrength.Path(), passwordsController.Strength,
+	).Name = routes.PasswordStrength.Name()
+
+	handler.Add(
+		http.MethodPut, routes.AccountPasswordUpdate.Path(), passwordsController.Update, middleware.AuthOnly, middleware.NotWhileImpersonating,
+	).Name = routes.AccountPasswordUpdate.Name()
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"mbvlabs/controllers"
+	"mbvlabs/router/middleware"
 	"mbvlabs/router/routes"
 
 	"github.com/labstack/echo/v4"
@@ -15,10 +16,14 @@ func registerSessionsRoutes(handler *echo.Echo, sessionsController controllers.S
 	).Name = routes.SessionNew.Name()
 
 	handler.Add(
-		http.MethodPost, routes.SessionCreate.Path(), sessionsController.Create,
+		http.MethodPost, routes.SessionCreate.Path(), sessionsController.Create, middleware.NotWhileImpersonating,
 	).Name = routes.SessionCreate.Name()
 
 	handler.Add(
 		http.MethodDelete, routes.SessionDestroy.Path(), sessionsController.Destroy,
 	).Name = routes.SessionDestroy.Name()
+
+	handler.Add(
+		http.MethodDelete, routes.SessionDestroyAll.Path(), sessionsController.DestroyAll, middleware.NotWhileImpersonating,
+	).Name = routes.SessionDestroyAll.Name()
 }
@@ -0,0 +1,20 @@
+package router
+
+import (
+	"net/http"
+
+	"mbvlabs/controllers"
+	"mbvlabs/router/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerTwoFactorChallengesRoutes(handler *echo.Echo, twoFactorChallengesController controllers.TwoFactorChallenges) {
+	handler.Add(
+		http.MethodGet, routes.TwoFactorChallengeNew.Path(), twoFactorChallengesController.New,
+	).Name = routes.TwoFactorChallengeNew.Name()
+
+	handler.Add(
+		http.MethodPost, routes.TwoFactorChallengeCreate.Path(), twoFactorChallengesController.Create,
+	).Name = routes.TwoFactorChallengeCreate.Name()
+}
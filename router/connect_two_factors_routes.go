@@ -0,0 +1,25 @@
+package router
+
+import (
+	"net/http"
+
+	"mbvlabs/controllers"
+	"mbvlabs/router/middleware"
+	"mbvlabs/router/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerTwoFactorsRoutes(handler *echo.Echo, twoFactorsController controllers.TwoFactors) {
+	handler.Add(
+		http.MethodGet, routes.TwoFactorNew.Path(), twoFactorsController.New, middleware.AuthOnly, middleware.NotWhileImpersonating,
+	).Name = routes.TwoFactorNew.Name()
+
+	handler.Add(
+		http.MethodPost, routes.TwoFactorCreate.Path(), twoFactorsController.Create, middleware.AuthOnly, middleware.NotWhileImpersonating,
+	).Name = routes.TwoFactorCreate.Name()
+
+	handler.Add(
+		http.MethodDelete, routes.TwoFactorDestroy.Path(), twoFactorsController.Destroy, middleware.AuthOnly, middleware.NotWhileImpersonating,
+	).Name = routes.TwoFactorDestroy.Name()
+}
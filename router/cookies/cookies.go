@@ -2,9 +2,13 @@ package cookies
 
 import (
 	"context"
+	"net/http"
+	"slices"
+	"time"
 
 	"mbvlabs/config"
 	"mbvlabs/internal/renderer"
+	"mbvlabs/internal/server"
 	"github.com/google/uuid"
 	"mbvlabs/models"
 
@@ -15,31 +19,76 @@ import (
 var AppKey renderer.CookieKey = "app_cookie_context"
 
 const (
-	isAuthenticated = "is_authenticated"
-	isAdmin = "is_admin"
-	userID = "user_id"
+	sessionID = "session_id"
+	twoFactorChallenge = "two_factor_challenge"
+	twoFactorRememberMe = "two_factor_remember_me"
+	passkeyCeremony = "passkey_ceremony"
+	oidcRequest = "oidc_request"
+	pendingConfirmation = "pending_confirmation"
+	impersonatorID = "impersonator_id"
+	impersonatorSessionID = "impersonator_session_id"
+	organizationID = "organization_id"
 )
 
+// rememberCookieName is kept apart from the session cookie so it can
+// outlive it.
+var rememberCookieName = config.AppCookieSessionName + "-remember"
+
 type App struct {
 	echo.Context
+	SessionID uuid.UUID
 	UserID uuid.UUID
 	IsAdmin bool
 	IsAuthenticated bool
+	Permissions []string
+	Email string
+	ImpersonatorID uuid.UUID
+	// Organizations are all the user belongs to and Organization the one
+	// they are working in. Organization is the zero value for users who
+	// belong to none.
+	Organizations []models.OrganizationMembership
+	Organization models.OrganizationMembership
+}
+
+// IsImpersonating reports whether an admin is using this session to see the
+// site as the user.
+func (a App) IsImpersonating() bool {
+	return a.ImpersonatorID != uuid.Nil
+}
+
+// HasOrganization reports whether the user is working in an organization.
+func (a App) HasOrganization() bool {
+	return a.Organization.OrganizationID != uuid.Nil
+}
+
+// Can reports whether the signed in user holds permission. Permissions are
+// loaded from the database with the session on every request, so a revoked
+// role stops working on the user's next request.
+func (a App) Can(permission string) bool {
+	return a.IsAuthenticated && slices.Contains(a.Permissions, permission)
 }
-func CreateAppSession(c echo.Context, user models.User) error {
+
+// CreateAppSession stores the opaque session id in the cookie. Everything
+// else about the signed in user is loaded from the database on each request.
+func CreateAppSession(c echo.Context, appSession models.Session) error {
 	sess, err := session.Get(config.AppCookieSessionName, c)
 	if err != nil {
 		return err
 	}
 
-	sess.Values[isAuthenticated] = true
-	sess.Values[isAdmin] = user.IsAdmin
-	sess.Values[userID] = user.ID.String()
+	sess.Values[sessionID] = appSession.ID.String()
+	delete(sess.Values, impersonatorID)
+	delete(sess.Values, impersonatorSessionID)
+	delete(sess.Values, organizationID)
 
 	return sess.Save(c.Request(), c.Response())
 }
 
+// DestroyAppSession clears the session cookie and the remember token, so
+// the device is not signed back in on the next request.
 func DestroyAppSession(c echo.Context) error {
+	ClearRememberToken(c)
+
 	sess, err := session.Get(config.AppCookieSessionName, c)
 	if err != nil {
 		return err
@@ -49,32 +98,346 @@ func DestroyAppSession(c echo.Context) error {
 	return sess.Save(c.Request(), c.Response())
 }
 
-func GetAppCtx(ctx context.Context) App {
-	appCtx, ok := ctx.Value(AppKey).(App)
+// GetSessionID returns the session id stored in the cookie, if any.
+func GetSessionID(c echo.Context) (uuid.UUID, bool) {
+	sess, err := session.Get(config.AppCookieSessionName, c)
+	if err != nil {
+		return uuid.UUID{}, false
+	}
+
+	v, ok := sess.Values[sessionID].(string)
 	if !ok {
-		return App{}
+		return uuid.UUID{}, false
 	}
 
-	return appCtx
+	id, err := uuid.Parse(v)
+	if err != nil {
+		return uuid.UUID{}, false
+	}
+
+	return id, true
 }
 
-func GetApp(c echo.Context) App {
+// SetRememberToken stores the remember token secret in its own long lived
+// cookie.
+func SetRememberToken(c echo.Context, secret string, maxAge time.Duration) {
+	c.SetCookie(rememberCookie(secret, int(maxAge.Seconds())))
+}
+
+// GetRememberToken returns the remember token secret, if the browser sent
+// one.
+func GetRememberToken(c echo.Context) (string, bool) {
+	cookie, err := c.Cookie(rememberCookieName)
+	if err != nil || cookie.Value == "" {
+		return "", false
+	}
+
+	return cookie.Value, true
+}
+
+func ClearRememberToken(c echo.Context) {
+	c.SetCookie(rememberCookie("", -1))
+}
+
+func rememberCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     rememberCookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   config.Env == server.ProdEnvironment,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// SetTwoFactorChallenge remembers the pending login between the password
+// step and the second factor step, along with whether the user asked to
+// be remembered on this device.
+func SetTwoFactorChallenge(c echo.Context, challenge string, rememberMe bool) error {
 	sess, err := session.Get(config.AppCookieSessionName, c)
 	if err != nil {
-		return App{}
+		return err
+	}
+
+	sess.Values[twoFactorChallenge] = challenge
+	sess.Values[twoFactorRememberMe] = rememberMe
+
+	return sess.Save(c.Request(), c.Response())
+}
+
+func GetTwoFactorChallenge(c echo.Context) (string, bool) {
+	sess, err := session.Get(config.AppCookieSessionName, c)
+	if err != nil {
+		return "", false
+	}
+
+	v, ok := sess.Values[twoFactorChallenge].(string)
+	if !ok || v == "" {
+		return "", false
+	}
+
+	return v, true
+}
+
+// GetTwoFactorRememberMe reports whether the pending login asked to be
+// remembered.
+func GetTwoFactorRememberMe(c echo.Context) bool {
+	sess, err := session.Get(config.AppCookieSessionName, c)
+	if err != nil {
+		return false
+	}
+
+	v, _ := sess.Values[twoFactorRememberMe].(bool)
+
+	return v
+}
+
+func ClearTwoFactorChallenge(c echo.Context) error {
+	sess, err := session.Get(config.AppCookieSessionName, c)
+	if err != nil {
+		return err
+	}
+
+	delete(sess.Values, twoFactorChallenge)
+	delete(sess.Values, twoFactorRememberMe)
+
+	return sess.Save(c.Request(), c.Response())
+}
+
+// SetPasskeyCeremony keeps the relying party state for a passkey ceremony
+// until the browser posts the authenticator response back.
+func SetPasskeyCeremony(c echo.Context, ceremony []byte) error {
+	sess, err := session.Get(config.AppCookieSessionName, c)
+	if err != nil {
+		return err
+	}
+
+	sess.Values[passkeyCeremony] = string(ceremony)
+
+	return sess.Save(c.Request(), c.Response())
+}
+
+// PopPasskeyCeremony returns the pending ceremony and removes it, so every
+// ceremony can be completed at most once.
+func PopPasskeyCeremony(c echo.Context) ([]byte, bool) {
+	sess, err := session.Get(config.AppCookieSessionName, c)
+	if err != nil {
+		return nil, false
+	}
+
+	v, ok := sess.Values[passkeyCeremony].(string)
+	if !ok || v == "" {
+		return nil, false
+	}
+
+	delete(sess.Values, passkeyCeremony)
+	if err := sess.Save(c.Request(), c.Response()); err != nil {
+		return nil, false
+	}
+
+	return []byte(v), true
+}
+
+// SetOIDCRequest keeps the state, nonce and PKCE verifier of a provider
+// login until the provider redirects back.
+func SetOIDCRequest(c echo.Context, request []byte) error {
+	sess, err := session.Get(config.AppCookieSessionName, c)
+	if err != nil {
+		return err
+	}
+
+	sess.Values[oidcRequest] = string(request)
+
+	return sess.Save(c.Request(), c.Response())
+}
+
+// PopOIDCRequest returns the pending provider login and removes it, so a
+// callback can be handled at most once.
+func PopOIDCRequest(c echo.Context) ([]byte, bool) {
+	sess, err := session.Get(config.AppCookieSessionName, c)
+	if err != nil {
+		return nil, false
+	}
+
+	v, ok := sess.Values[oidcRequest].(string)
+	if !ok || v == "" {
+		return nil, false
 	}
 
-	app := App{Context: c}
+	delete(sess.Values, oidcRequest)
+	if err := sess.Save(c.Request(), c.Response()); err != nil {
+		return nil, false
+	}
+
+	return []byte(v), true
+}
+
+// SetPendingConfirmation remembers which address is waiting for a
+// verification code, so the confirmation form can be filled in.
+func SetPendingConfirmation(c echo.Context, email string) error {
+	sess, err := session.Get(config.AppCookieSessionName, c)
+	if err != nil {
+		return err
+	}
+
+	sess.Values[pendingConfirmation] = email
+
+	return sess.Save(c.Request(), c.Response())
+}
+
+func GetPendingConfirmation(c echo.Context) string {
+	sess, err := session.Get(config.AppCookieSessionName, c)
+	if err != nil {
+		return ""
+	}
+
+	v, _ := sess.Values[pendingConfirmation].(string)
+
+	return v
+}
+
+func ClearPendingConfirmation(c echo.Context) error {
+	sess, err := session.Get(config.AppCookieSessionName, c)
+	if err != nil {
+		return err
+	}
+
+	delete(sess.Values, pendingConfirmation)
+
+	return sess.Save(c.Request(), c.Response())
+}
+
+// Impersonator is the admin, and their own session, behind an
+// impersonation.
+type Impersonator struct {
+	UserID    uuid.UUID
+	SessionID uuid.UUID
+}
+
+// StartImpersonation points the cookie at the impersonation session and
+// keeps the admin's own session so it can be restored afterwards.
+func StartImpersonation(c echo.Context, appSession models.Session, impersonator Impersonator) error {
+	sess, err := session.Get(config.AppCookieSessionName, c)
+	if err != nil {
+		return err
+	}
+
+	sess.Values[sessionID] = appSession.ID.String()
+	sess.Values[impersonatorID] = impersonator.UserID.String()
+	sess.Values[impersonatorSessionID] = impersonator.SessionID.String()
+	delete(sess.Values, organizationID)
+
+	return sess.Save(c.Request(), c.Response())
+}
+
+func GetImpersonator(c echo.Context) (Impersonator, bool) {
+	sess, err := session.Get(config.AppCookieSessionName, c)
+	if err != nil {
+		return Impersonator{}, false
+	}
+
+	rawUserID, _ := sess.Values[impersonatorID].(string)
+	rawSessionID, _ := sess.Values[impersonatorSessionID].(string)
+
+	userID, err := uuid.Parse(rawUserID)
+	if err != nil {
+		return Impersonator{}, false
+	}
+
+	impersonatorSession, err := uuid.Parse(rawSessionID)
+	if err != nil {
+		return Impersonator{}, false
+	}
+
+	return Impersonator{UserID: userID, SessionID: impersonatorSession}, true
+}
+
+// StopImpersonation puts the admin back in their own session.
+func StopImpersonation(c echo.Context) error {
+	impersonator, ok := GetImpersonator(c)
+	if !ok {
+		return nil
+	}
+
+	sess, err := session.Get(config.AppCookieSessionName, c)
+	if err != nil {
+		return err
+	}
+
+	sess.Values[sessionID] = impersonator.SessionID.String()
+	delete(sess.Values, impersonatorID)
+	delete(sess.Values, impersonatorSessionID)
+	delete(sess.Values, organizationID)
+
+	return sess.Save(c.Request(), c.Response())
+}
+
+// SetCurrentOrganization picks the organization the user works in. It is
+// only a preference; membership is checked again on every request.
+func SetCurrentOrganization(c echo.Context, id uuid.UUID) error {
+	sess, err := session.Get(config.AppCookieSessionName, c)
+	if err != nil {
+		return err
+	}
+
+	sess.Values[organizationID] = id.String()
+
+	return sess.Save(c.Request(), c.Response())
+}
+
+func GetCurrentOrganization(c echo.Context) (uuid.UUID, bool) {
+	sess, err := session.Get(config.AppCookieSessionName, c)
+	if err != nil {
+		return uuid.UUID{}, false
+	}
+
+	v, ok := sess.Values[organizationID].(string)
+	if !ok {
+		return uuid.UUID{}, false
+	}
+
+	id, err := uuid.Parse(v)
+	if err != nil {
+		return uuid.UUID{}, false
+	}
+
+	return id, true
+}
 
-	if v, ok := sess.Values[isAuthenticated].(bool); ok {
-		app.IsAuthenticated = v
+// NewApp builds the signed in context for a validated session.
+func NewApp(
+	c echo.Context,
+	appSession models.Session,
+	user models.User,
+	permissions []string,
+) App {
+	return App{
+		Context:         c,
+		SessionID:       appSession.ID,
+		UserID:          user.ID,
+		IsAdmin:         user.IsAdmin,
+		IsAuthenticated: true,
+		Permissions:     permissions,
+		Email:           user.Email,
 	}
-	if v, ok := sess.Values[isAdmin].(bool); ok {
-		app.IsAdmin = v
+}
+
+func GetAppCtx(ctx context.Context) App {
+	appCtx, ok := ctx.Value(AppKey).(App)
+	if !ok {
+		return App{}
 	}
-	if v, ok := sess.Values[userID].(string); ok {
-		app.UserID, _ = uuid.Parse(v)
+
+	return appCtx
+}
+
+// GetApp returns the context registered by the session validation
+// middleware, or an anonymous context if there is no valid session.
+func GetApp(c echo.Context) App {
+	if app, ok := c.Get(string(AppKey)).(App); ok {
+		return app
 	}
 
-	return app
+	return App{Context: c}
 }
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"mbvlabs/models"
+	"mbvlabs/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+const apiPrincipalKey = "api_principal"
+
+// APIPrincipal is the user and token behind an authenticated API request.
+type APIPrincipal struct {
+	User  models.User
+	Token models.APIToken
+}
+
+func GetAPIPrincipal(c echo.Context) (APIPrincipal, bool) {
+	principal, ok := c.Get(apiPrincipalKey).(APIPrincipal)
+	return principal, ok
+}
+
+// APITokenAuth authenticates requests carrying an
+// "Authorization: Bearer <token>" header and attaches the principal to the
+// context. Anything else is answered with 401.
+func (m Middleware) APITokenAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		scheme, secret, _ := strings.Cut(c.Request().Header.Get(echo.HeaderAuthorization), " ")
+		if !strings.EqualFold(scheme, "bearer") {
+			return apiUnauthorized(c)
+		}
+
+		user, token, err := services.AuthenticateAPIToken(
+			c.Request().Context(),
+			m.db,
+			m.cfg.Auth.Pepper,
+			strings.TrimSpace(secret),
+		)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidAPIToken) {
+				return apiUnauthorized(c)
+			}
+
+			slog.ErrorContext(
+				c.Request().Context(),
+				"failed to authenticate api token",
+				"error",
+				err,
+			)
+			return echo.ErrInternalServerError
+		}
+
+		c.Set(apiPrincipalKey, APIPrincipal{User: user, Token: token})
+
+		return next(c)
+	}
+}
+
+// RequireAPIScope rejects API requests whose token was not granted scope.
+// It must run after APITokenAuth.
+func RequireAPIScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal, ok := GetAPIPrincipal(c)
+			if !ok {
+				return apiUnauthorized(c)
+			}
+
+			if !principal.Token.HasScope(scope) {
+				c.Response().Header().Set(
+					echo.HeaderWWWAuthenticate,
+					fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, scope),
+				)
+				return c.JSON(http.StatusForbidden, map[string]string{
+					"error": "token is missing the " + scope + " scope",
+				})
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func apiUnauthorized(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+	return c.JSON(http.StatusUnauthorized, map[string]string{
+		"error": "missing or invalid api token",
+	})
+}
@@ -2,14 +2,11 @@ package middleware
 
 import (
 	"net/http"
-	"time"
 
-	"mbvlabs/internal/routing"
 	"mbvlabs/router/cookies"
 	"mbvlabs/router/routes"
 
 	"github.com/labstack/echo/v4"
-	"github.com/maypok86/otter/v2"
 )
 
 func AuthOnly(next echo.HandlerFunc) echo.HandlerFunc {
@@ -22,34 +19,47 @@ func AuthOnly(next echo.HandlerFunc) echo.HandlerFunc {
 	}
 }
 
-func IPRateLimiter(
-	limit int32,
-	redirectURL routing.Route,
-) func(next echo.HandlerFunc) echo.HandlerFunc {
-	cache := otter.Must(&otter.Options[string, int32]{
-		MaximumSize:      1000,
-		ExpiryCalculator: otter.ExpiryCreating[string, int32](10 * time.Minute),
-	})
-
+// RequirePermission lets through signed in users holding permission.
+// Anonymous visitors are sent to sign in and everyone else gets not found, so
+// restricted tooling is not advertised.
+func RequirePermission(permission string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			ip := c.RealIP()
-
-			hits, ok := cache.GetIfPresent(ip)
-			if !ok {
-				cache.Set(ip, 1)
-				return next(c)
+			app := cookies.GetApp(c)
+			if !app.IsAuthenticated {
+				return c.Redirect(http.StatusSeeOther, routes.SessionNew.URL())
 			}
 
-			if hits <= limit {
-				cache.Set(ip, hits+1)
+			if !app.Can(permission) {
+				return echo.ErrNotFound
 			}
 
-			if hits > limit {
-				return c.String(http.StatusTooManyRequests, redirectURL.URL())
-			}
+			return next(c)
+		}
+	}
+}
 
+// NotWhileImpersonating keeps admins who are impersonating a user away from
+// credentials and other settings only the user should change.
+func NotWhileImpersonating(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if cookies.GetApp(c).IsImpersonating() {
+			return echo.ErrForbidden
+		}
+
+		return next(c)
+	}
+}
+
+// RequireOrganization lets through signed in users working in an
+// organization. Users who belong to none are sent to create one. Use it
+// after AuthOnly.
+func RequireOrganization(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if cookies.GetApp(c).HasOrganization() {
 			return next(c)
 		}
+
+		return c.Redirect(http.StatusSeeOther, routes.OrganizationNew.URL())
 	}
 }
@@ -2,7 +2,10 @@
 package middleware
 
 import (
+	"database/sql"
+	"errors"
 	"log/slog"
+	"net/http"
 	"strings"
 	"time"
 
@@ -10,19 +13,39 @@ import (
 	"mbvlabs/router/cookies"
 	"mbvlabs/config"
 	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/services"
 	"mbvlabs/telemetry"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 )
 
 type Middleware struct {
-	db storage.Pool
+	db  storage.Pool
+	cfg config.Config
 }
 
-func New(db storage.Pool) Middleware {
-	return Middleware{db: db}
+func New(db storage.Pool, cfg config.Config) Middleware {
+	return Middleware{db: db, cfg: cfg}
+}
+
+// RegisterRequestMetadata makes the client IP and user agent available to
+// services, which record them in the audit log.
+func (m Middleware) RegisterRequestMetadata(
+	next echo.HandlerFunc,
+) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := services.WithRequestMetadata(c.Request().Context(), services.RequestMetadata{
+			IP:        c.RealIP(),
+			UserAgent: c.Request().UserAgent(),
+		})
+		c.SetRequest(c.Request().WithContext(ctx))
+
+		return next(c)
+	}
 }
 
 func (m Middleware) RegisterAppContext(
@@ -72,10 +95,251 @@ func (m Middleware) ValidateSession(
 			return next(c)
 		}
 
+		ctx := c.Request().Context()
+
+		id, ok := cookies.GetSessionID(c)
+		if !ok {
+			restoredSession, restored, err := m.restoreRememberedSession(c)
+			if err != nil {
+				return err
+			}
+
+			if restored {
+				if err := m.registerSession(c, restoredSession, uuid.Nil); err != nil {
+					return err
+				}
+			}
+
+			return next(c)
+		}
+
+		appSession, err := models.FindSession(ctx, m.db.Conn(), id)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		impersonator, impersonating := cookies.GetImpersonator(c)
+		if impersonating {
+			allowed, checkErr := services.ImpersonationAllowed(
+				ctx,
+				m.db,
+				impersonator.UserID,
+				impersonator.SessionID,
+			)
+			if checkErr != nil {
+				return checkErr
+			}
+
+			// An impersonation that expired or whose admin lost access is
+			// ended and recorded here, then the admin's own session is
+			// validated as usual.
+			if !allowed || err != nil || !appSession.IsActive() {
+				if err := services.StopImpersonation(ctx, m.db, services.StopImpersonationData{
+					ImpersonatorID: impersonator.UserID,
+					SubjectID:      appSession.UserID,
+					SessionID:      id,
+					Reason:         "expired",
+				}); err != nil {
+					return err
+				}
+
+				if err := cookies.StopImpersonation(c); err != nil {
+					return err
+				}
+
+				impersonating = false
+				appSession, err = models.FindSession(ctx, m.db.Conn(), impersonator.SessionID)
+				if err != nil && !errors.Is(err, sql.ErrNoRows) {
+					return err
+				}
+			}
+		}
+
+		if err != nil || !appSession.IsActive() {
+			if !impersonating {
+				restoredSession, restored, err := m.restoreRememberedSession(c)
+				if err != nil {
+					return err
+				}
+
+				if restored {
+					if err := m.registerSession(c, restoredSession, uuid.Nil); err != nil {
+						return err
+					}
+
+					return next(c)
+				}
+			}
+
+			if err := cookies.DestroyAppSession(c); err != nil {
+				slog.ErrorContext(ctx, "could not destroy invalid session cookie", "error", err)
+			}
+
+			return next(c)
+		}
+
+		impersonatorID := uuid.Nil
+		if impersonating {
+			impersonatorID = impersonator.UserID
+		}
+
+		if err := m.registerSession(c, appSession, impersonatorID); err != nil {
+			return err
+		}
+
 		return next(c)
 	}
 }
 
+// registerSession loads the user behind a valid session into the request
+// context and records that the session was used. Impersonation sessions
+// are not touched, so the admin's device is not shown as one of the
+// user's.
+func (m Middleware) registerSession(
+	c echo.Context,
+	appSession models.Session,
+	impersonatorID uuid.UUID,
+) error {
+	ctx := c.Request().Context()
+
+	user, err := models.FindUser(ctx, m.db.Conn(), appSession.UserID)
+	if err != nil {
+		return err
+	}
+
+	permissions, err := models.FindUserPermissions(ctx, m.db.Conn(), user)
+	if err != nil {
+		return err
+	}
+
+	memberships, err := models.FindOrganizationMembershipsByUserID(ctx, m.db.Conn(), user.ID)
+	if err != nil {
+		return err
+	}
+
+	app := cookies.NewApp(c, appSession, user, permissions)
+	app.ImpersonatorID = impersonatorID
+	app.Organizations = memberships
+	app.Organization = currentOrganization(c, memberships)
+
+	if !app.IsImpersonating() {
+		if err := services.TouchSession(ctx, m.db, appSession); err != nil {
+			slog.ErrorContext(ctx, "could not record session activity", "error", err)
+		}
+	}
+
+	c.Set(string(cookies.AppKey), app)
+
+	return nil
+}
+
+// currentOrganization is the organization chosen with the switcher, or the
+// first one if the choice is missing or the user has since left it.
+func currentOrganization(
+	c echo.Context,
+	memberships []models.OrganizationMembership,
+) models.OrganizationMembership {
+	if len(memberships) == 0 {
+		return models.OrganizationMembership{}
+	}
+
+	if id, ok := cookies.GetCurrentOrganization(c); ok {
+		for _, membership := range memberships {
+			if membership.OrganizationID == id {
+				return membership
+			}
+		}
+	}
+
+	return memberships[0]
+}
+
+// restoreRememberedSession signs a remembered device back in once its
+// session has run out, rotating the remember token in the cookie. A token
+// that is invalid, or that was replayed after rotation, is cleared.
+func (m Middleware) restoreRememberedSession(
+	c echo.Context,
+) (models.Session, bool, error) {
+	secret, ok := cookies.GetRememberToken(c)
+	if !ok {
+		return models.Session{}, false, nil
+	}
+
+	ctx := c.Request().Context()
+
+	restored, err := services.RestoreRememberedSession(ctx, m.db, m.cfg.Auth.Pepper, secret)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrRememberTokenReused):
+			slog.WarnContext(ctx, "remember token reused, signed the user out everywhere")
+		case !errors.Is(err, services.ErrInvalidRememberToken):
+			return models.Session{}, false, err
+		}
+
+		cookies.ClearRememberToken(c)
+
+		return models.Session{}, false, nil
+	}
+
+	if restored.RememberSecret != "" {
+		cookies.SetRememberToken(c, restored.RememberSecret, services.RememberMeDuration)
+	}
+
+	if err := cookies.CreateAppSession(c, restored.Session); err != nil {
+		return models.Session{}, false, err
+	}
+
+	return restored.Session, true, nil
+}
+
+// RequireAdminTwoFactor sends admins without a confirmed TOTP secret or a
+// passkey to the enrollment page until they have set one up.
+func (m Middleware) RequireAdminTwoFactor(
+	next echo.HandlerFunc,
+) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !m.cfg.Auth.RequireAdminTwoFactor {
+			return next(c)
+		}
+
+		path := c.Request().URL.Path
+		if strings.Contains(path, routes.AssetsPrefix) ||
+			strings.Contains(path, routes.APIPrefix) ||
+			strings.HasSuffix(path, routes.TwoFactorNew.Path()) ||
+			strings.HasSuffix(path, routes.TwoFactorCreate.Path()) ||
+			strings.HasSuffix(path, routes.PasskeyIndex.Path()) ||
+			strings.HasSuffix(path, routes.PasskeyOptions.Path()) ||
+			strings.HasSuffix(path, routes.SessionDestroy.Path()) {
+			return next(c)
+		}
+
+		app := cookies.GetApp(c)
+		if !app.IsAuthenticated || !app.IsAdmin {
+			return next(c)
+		}
+
+		credential, err := models.FindTOTPCredentialByUserID(c.Request().Context(), m.db.Conn(), app.UserID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		if err == nil && credential.IsConfirmed() {
+			return next(c)
+		}
+
+		passkeys, err := models.FindWebAuthnCredentialsByUserID(c.Request().Context(), m.db.Conn(), app.UserID)
+		if err != nil {
+			return err
+		}
+
+		if len(passkeys) > 0 {
+			return next(c)
+		}
+
+		return c.Redirect(http.StatusSeeOther, routes.TwoFactorNew.URL())
+	}
+}
+
 func (m Middleware) Logger(tel *telemetry.Telemetry) echo.MiddlewareFunc {
 	var httpRequestsTotal metric.Int64Counter
 	var httpDuration metric.Float64Histogram
@@ -14,6 +14,7 @@ import (
 	"mbvlabs/controllers"
 	"mbvlabs/router/routes"
 	"mbvlabs/router/middleware"
+	"mbvlabs/models"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/sessions"
@@ -60,6 +61,7 @@ func SetupGlobalMiddleware(
 	return []echo.MiddlewareFunc{
 		otelecho.Middleware(config.ServiceName),
 		mw.Logger(tel),
+		mw.RegisterRequestMetadata,
 		session.Middleware(
 			sessions.NewCookieStore(
 				authKey,
@@ -68,6 +70,7 @@ func SetupGlobalMiddleware(
 		),
 		mw.ValidateSession,
 		mw.RegisterAppContext,
+		mw.RequireAdminTwoFactor,
 		mw.RegisterFlashMessagesContext,
 		echomw.CORSWithConfig(echomw.CORSConfig{
 			AllowOrigins:     []string{"https://*", "http://*"},
@@ -103,20 +106,64 @@ func (r *Router) RegisterCtrlRoutes(
 	registrations controllers.Registrations,
 	confirmations controllers.Confirmations,
 	resetPasswords controllers.ResetPasswords,
+	twoFactors controllers.TwoFactors,
+	twoFactorChallenges controllers.TwoFactorChallenges,
+	passkeys controllers.Passkeys,
+	passkeySessions controllers.PasskeySessions,
+	magicLinks controllers.MagicLinks,
+	oidcSessions controllers.OIDCSessions,
+	accounts controllers.Accounts,
+	emailChanges controllers.EmailChanges,
+	apiTokens controllers.APITokens,
+	adminUsers controllers.AdminUsers,
+	impersonations controllers.Impersonations,
+	adminAuditEvents controllers.AdminAuditEvents,
+	dataExports controllers.DataExports,
+	accountDeletions controllers.AccountDeletions,
+	passwords controllers.Passwords,
+	devices controllers.Devices,
+	organizations controllers.Organizations,
+	organizationMembers controllers.OrganizationMembers,
+	organizationInvitations controllers.OrganizationInvitations,
+	adminEmailMessages controllers.AdminEmailMessages,
+	adminEmailSuppressions controllers.AdminEmailSuppressions,
+	emailWebhooks controllers.EmailWebhooks,
 ) {
-	registerAPIRoutes(r.Handler, api)
+	registerAPIRoutes(r.Handler, mw, api)
 	registerAssetsRoutes(r.Handler, assets)
 	registerPagesRoutes(r.Handler, pages)
 	registerSessionsRoutes(r.Handler, sessions)
 	registerRegistrationsRoutes(r.Handler, registrations)
 	registerConfirmationsRoutes(r.Handler, confirmations)
 	registerResetPasswordsRoutes(r.Handler, resetPasswords)
+	registerTwoFactorsRoutes(r.Handler, twoFactors)
+	registerTwoFactorChallengesRoutes(r.Handler, twoFactorChallenges)
+	registerPasskeysRoutes(r.Handler, passkeys)
+	registerPasskeySessionsRoutes(r.Handler, passkeySessions)
+	registerMagicLinksRoutes(r.Handler, magicLinks)
+	registerOIDCSessionsRoutes(r.Handler, oidcSessions)
+	registerAccountsRoutes(r.Handler, accounts)
+	registerEmailChangesRoutes(r.Handler, emailChanges)
+	registerAPITokensRoutes(r.Handler, apiTokens)
+	registerAdminUsersRoutes(r.Handler, adminUsers)
+	registerImpersonationsRoutes(r.Handler, impersonations)
+	registerAdminAuditEventsRoutes(r.Handler, adminAuditEvents)
+	registerDataExportsRoutes(r.Handler, dataExports)
+	registerAccountDeletionsRoutes(r.Handler, accountDeletions)
+	registerPasswordsRoutes(r.Handler, passwords)
+	registerDevicesRoutes(r.Handler, devices)
+	registerOrganizationsRoutes(r.Handler, organizations)
+	registerOrganizationMembersRoutes(r.Handler, organizationMembers)
+	registerOrganizationInvitationsRoutes(r.Handler, organizationInvitations)
+	registerAdminEmailMessagesRoutes(r.Handler, adminEmailMessages)
+	registerAdminEmailSuppressionsRoutes(r.Handler, adminEmailSuppressions)
+	registerEmailWebhooksRoutes(r.Handler, emailWebhooks)
 }
 
 func (r *Router) RegisterCustomRoutes(
 	riverHandler interface{ ServeHTTP(http.ResponseWriter, *http.Request) },
 	notFoundHandler echo.HandlerFunc,
 ) {
-	r.Handler.Any("/riverui*", echo.WrapHandler(riverHandler))
+	r.Handler.Any("/riverui*", echo.WrapHandler(riverHandler), middleware.RequirePermission(models.PermissionJobsManage))
 	r.Handler.RouteNotFound("/*", notFoundHandler)
 }
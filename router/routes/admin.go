@@ -0,0 +1,97 @@
+package routes
+
+import (
+	"mbvlabs/internal/routing"
+)
+
+const AdminPrefix = "admin"
+
+var AdminUserIndex = routing.NewSimpleRoute(
+	"/users",
+	"admin_users",
+	AdminPrefix,
+)
+
+var AdminUserShow = routing.NewRouteWithID(
+	"/users/:id",
+	"admin_user",
+	AdminPrefix,
+)
+
+var AdminUserVerifyEmail = routing.NewRouteWithID(
+	"/users/:id/verify_email",
+	"verify_email_admin_user",
+	AdminPrefix,
+)
+
+var AdminUserToggleAdmin = routing.NewRouteWithID(
+	"/users/:id/toggle_admin",
+	"toggle_admin_admin_user",
+	AdminPrefix,
+)
+
+var AdminUserForcePasswordReset = routing.NewRouteWithID(
+	"/users/:id/password_reset",
+	"password_reset_admin_user",
+	AdminPrefix,
+)
+
+var AdminUserResendVerification = routing.NewRouteWithID(
+	"/users/:id/resend_verification",
+	"resend_verification_admin_user",
+	AdminPrefix,
+)
+
+var AdminUserDestroy = routing.NewRouteWithID(
+	"/users/:id",
+	"destroy_admin_user",
+	AdminPrefix,
+)
+
+var ImpersonationCreate = routing.NewRouteWithID(
+	"/users/:id/impersonation",
+	"admin_user_impersonation",
+	AdminPrefix,
+)
+
+var ImpersonationDestroy = routing.NewSimpleRoute(
+	"/impersonation",
+	"destroy_admin_impersonation",
+	AdminPrefix,
+)
+
+var AdminAuditEventIndex = routing.NewSimpleRoute(
+	"/audit_events",
+	"admin_audit_events",
+	AdminPrefix,
+)
+
+var AdminAuditEventExport = routing.NewSimpleRoute(
+	"/audit_events/export",
+	"export_admin_audit_events",
+	AdminPrefix,
+)
+
+var AdminEmailMessageIndex = routing.NewSimpleRoute(
+	"/email_messages",
+	"admin_email_messages",
+	AdminPrefix,
+)
+
+var AdminEmailMessageShow = routing.NewRouteWithID(
+	"/email_messages/:id",
+	"admin_email_message",
+	AdminPrefix,
+)
+
+var AdminEmailSuppressionIndex = routing.NewSimpleRoute(
+	"/email_suppressions",
+	"admin_email_suppressions",
+	AdminPrefix,
+)
+
+var AdminEmailSuppressionDestroy = routing.NewRouteWithID(
+	"/email_suppressions/:id",
+	"destroy_admin_email_suppression",
+	AdminPrefix,
+)
@@ -11,3 +11,9 @@ var Health = routing.NewSimpleRoute(
 	"health",
 	APIPrefix,
 )
+
+var APIMe = routing.NewSimpleRoute(
+	"/me",
+	"api_me",
+	APIPrefix,
+)
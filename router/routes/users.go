@@ -24,6 +24,12 @@ var SessionDestroy = routing.NewSimpleRoute(
 	UserPrefix,
 )
 
+var SessionDestroyAll = routing.NewSimpleRoute(
+	"/sign_out/everywhere",
+	"destroy_all_user_sessions",
+	UserPrefix,
+)
+
 var PasswordNew = routing.NewSimpleRoute(
 	"/password/new",
 	"new_user_password",
@@ -71,3 +77,267 @@ var ConfirmationCreate = routing.NewSimpleRoute(
 	"user_confirmation",
 	UserPrefix,
 )
+
+var ConfirmationResend = routing.NewSimpleRoute(
+	"/confirmation/resend",
+	"resend_user_confirmation",
+	UserPrefix,
+)
+
+var TwoFactorNew = routing.NewSimpleRoute(
+	"/two_factor/new",
+	"new_user_two_factor",
+	UserPrefix,
+)
+
+var TwoFactorCreate = routing.NewSimpleRoute(
+	"/two_factor",
+	"user_two_factor",
+	UserPrefix,
+)
+
+var TwoFactorDestroy = routing.NewSimpleRoute(
+	"/two_factor",
+	"destroy_user_two_factor",
+	UserPrefix,
+)
+
+var TwoFactorChallengeNew = routing.NewSimpleRoute(
+	"/two_factor/challenge/new",
+	"new_user_two_factor_challenge",
+	UserPrefix,
+)
+
+var TwoFactorChallengeCreate = routing.NewSimpleRoute(
+	"/two_factor/challenge",
+	"user_two_factor_challenge",
+	UserPrefix,
+)
+
+var PasskeyIndex = routing.NewSimpleRoute(
+	"/passkeys",
+	"user_passkeys",
+	UserPrefix,
+)
+
+var PasskeyOptions = routing.NewSimpleRoute(
+	"/passkeys/options",
+	"user_passkey_options",
+	UserPrefix,
+)
+
+var PasskeyCreate = routing.NewSimpleRoute(
+	"/passkeys",
+	"user_passkey",
+	UserPrefix,
+)
+
+var PasskeyDestroy = routing.NewRouteWithID(
+	"/passkeys/:id",
+	"destroy_user_passkey",
+	UserPrefix,
+)
+
+var PasskeySessionOptions = routing.NewSimpleRoute(
+	"/sign_in/passkey/options",
+	"user_passkey_session_options",
+	UserPrefix,
+)
+
+var PasskeySessionCreate = routing.NewSimpleRoute(
+	"/sign_in/passkey",
+	"user_passkey_session",
+	UserPrefix,
+)
+
+var MagicLinkNew = routing.NewSimpleRoute(
+	"/sign_in/link/new",
+	"new_user_magic_link",
+	UserPrefix,
+)
+
+var MagicLinkCreate = routing.NewSimpleRoute(
+	"/sign_in/link",
+	"user_magic_link",
+	UserPrefix,
+)
+
+var SessionMagicLink = routing.NewRouteWithToken(
+	"/sign_in/link/:token",
+	"user_session_magic_link",
+	UserPrefix,
+)
+
+var OIDCSessionNew = routing.NewRouteWithSlug(
+	"/auth/:slug",
+	"new_user_oidc_session",
+	UserPrefix,
+)
+
+var OIDCSessionCallback = routing.NewRouteWithSlug(
+	"/auth/:slug/callback",
+	"user_oidc_session_callback",
+	UserPrefix,
+)
+
+var AccountShow = routing.NewSimpleRoute(
+	"/account",
+	"user_account",
+	UserPrefix,
+)
+
+var EmailChangeCreate = routing.NewSimpleRoute(
+	"/account/email",
+	"user_email_change",
+	UserPrefix,
+)
+
+var EmailChangeConfirm = routing.NewRouteWithToken(
+	"/account/email/:token/confirm",
+	"confirm_user_email_change",
+	UserPrefix,
+)
+
+var EmailChangeRevert = routing.NewRouteWithToken(
+	"/account/email/:token/revert",
+	"revert_user_email_change",
+	UserPrefix,
+)
+
+var APITokenIndex = routing.NewSimpleRoute(
+	"/account/tokens",
+	"user_api_tokens",
+	UserPrefix,
+)
+
+var APITokenCreate = routing.NewSimpleRoute(
+	"/account/tokens",
+	"user_api_token",
+	UserPrefix,
+)
+
+var APITokenDestroy = routing.NewRouteWithID(
+	"/account/tokens/:id",
+	"destroy_user_api_token",
+	UserPrefix,
+)
+
+var DeviceIndex = routing.NewSimpleRoute(
+	"/account/devices",
+	"user_devices",
+	UserPrefix,
+)
+
+var DeviceDestroy = routing.NewRouteWithID(
+	"/account/devices/:id",
+	"destroy_user_device",
+	UserPrefix,
+)
+
+var DeviceDestroyOthers = routing.NewSimpleRoute(
+	"/account/devices",
+	"destroy_other_user_devices",
+	UserPrefix,
+)
+
+var DataExportCreate = routing.NewSimpleRoute(
+	"/account/data_exports",
+	"user_data_export",
+	UserPrefix,
+)
+
+var DataExportShow = routing.NewRouteWithToken(
+	"/account/data_exports/:token",
+	"show_user_data_export",
+	UserPrefix,
+)
+
+var AccountDeletionCreate = routing.NewSimpleRoute(
+	"/account/deletion",
+	"user_account_deletion",
+	UserPrefix,
+)
+
+var AccountDeletionDestroy = routing.NewSimpleRoute(
+	"/account/deletion",
+	"destroy_user_account_deletion",
+	UserPrefix,
+)
+
+var AccountDeletionCancel = routing.NewRouteWithToken(
+	"/account/deletion/:token/cancel",
+	"cancel_user_account_deletion",
+	UserPrefix,
+)
+
+var PasswordStrength = routing.NewSimpleRoute(
+	"/passwords/strength",
+	"user_password_strength",
+	UserPrefix,
+)
+
+var AccountPasswordUpdate = routing.NewSimpleRoute(
+	"/account/password",
+	"update_user_account_password",
+	UserPrefix,
+)
+
+var OrganizationNew = routing.NewSimpleRoute(
+	"/organizations/new",
+	"new_user_organization",
+	UserPrefix,
+)
+
+var OrganizationCreate = routing.NewSimpleRoute(
+	"/organizations",
+	"user_organization",
+	UserPrefix,
+)
+
+var OrganizationSwitch = routing.NewRouteWithID(
+	"/organizations/:id/switch",
+	"switch_user_organization",
+	UserPrefix,
+)
+
+var OrganizationShow = routing.NewSimpleRoute(
+	"/organization",
+	"show_user_organization",
+	UserPrefix,
+)
+
+var OrganizationMemberUpdate = routing.NewRouteWithID(
+	"/organization/members/:id",
+	"update_user_organization_member",
+	UserPrefix,
+)
+
+var OrganizationMemberDestroy = routing.NewRouteWithID(
+	"/organization/members/:id",
+	"destroy_user_organization_member",
+	UserPrefix,
+)
+
+var OrganizationInvitationCreate = routing.NewSimpleRoute(
+	"/organization/invitations",
+	"user_organization_invitation",
+	UserPrefix,
+)
+
+var OrganizationInvitationDestroy = routing.NewRouteWithID(
+	"/organization/invitations/:id",
+	"destroy_user_organization_invitation",
+	UserPrefix,
+)
+
+var InvitationShow = routing.NewRouteWithToken(
+	"/invitations/:token",
+	"show_user_invitation",
+	UserPrefix,
+)
+
+var InvitationAccept = routing.NewRouteWithToken(
+	"/invitations/:token",
+	"accept_user_invitation",
+	UserPrefix,
+)
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"mbvlabs/internal/routing"
+)
+
+// WebhooksPrefix sits under the API prefix so provider callbacks skip the
+// session and CSRF handling browser requests get. Each webhook
+// authenticates its provider instead.
+const WebhooksPrefix = APIPrefix + "/webhooks"
+
+var PostmarkWebhook = routing.NewSimpleRoute(
+	"/postmark",
+	"postmark",
+	WebhooksPrefix,
+)
+
+var SendGridWebhook = routing.NewSimpleRoute(
+	"/sendgrid",
+	"sendgrid",
+	WebhooksPrefix,
+)
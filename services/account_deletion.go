@@ -0,0 +1,333 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/config"
+	"mbvlabs/email"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/queue"
+	"mbvlabs/router/routes"
+)
+
+const (
+	userAccountDeletionCancel = "user_account_deletion_cancel"
+
+	AccountDeletionGracePeriod = 30 * 24 * time.Hour
+
+	purgeDeletedUsersBatchSize = 100
+)
+
+var (
+	ErrDeletionConfirmationMismatch = errors.New("confirmation does not match the account email")
+	ErrDeletionAlreadyScheduled     = errors.New("account is already scheduled for deletion")
+	ErrDeletionNotScheduled         = errors.New("account is not scheduled for deletion")
+	ErrInvalidDeletionCancel        = errors.New("invalid or expired cancellation link")
+)
+
+type ScheduleAccountDeletionData struct {
+	UserID uuid.UUID
+	// Confirmation must repeat the account email, which works whether the
+	// user signs in with a password, a passkey or a provider.
+	Confirmation string
+}
+
+// ScheduleAccountDeletion starts the grace period before an account is
+// purged. Every session is revoked, and the owner is emailed a link that
+// keeps the account, in case the request was not theirs.
+func ScheduleAccountDeletion(
+	ctx context.Context,
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	pepper string,
+	data ScheduleAccountDeletionData,
+) (models.User, error) {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	user, err := models.FindUser(ctx, tx, data.UserID)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if !strings.EqualFold(strings.TrimSpace(data.Confirmation), user.Email) {
+		return models.User{}, ErrDeletionConfirmationMismatch
+	}
+
+	if user.IsDeletionScheduled() {
+		return models.User{}, ErrDeletionAlreadyScheduled
+	}
+
+	if err := ensureNoSoleOwnedOrganizations(ctx, tx, user.ID); err != nil {
+		return models.User{}, err
+	}
+
+	deleteAt := time.Now().Add(AccountDeletionGracePeriod)
+
+	user, err = models.ScheduleUserDeletion(ctx, tx, user.ID, deleteAt)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if err := models.RevokeUserSessions(ctx, tx, user.ID); err != nil {
+		return models.User{}, err
+	}
+
+	if err := models.DestroyTokensByScopeAndUserID(ctx, tx, userAccountDeletionCancel, user.ID); err != nil {
+		return models.User{}, err
+	}
+
+	cancelToken, err := models.CreateUserToken(
+		ctx,
+		tx,
+		pepper,
+		userAccountDeletionCancel,
+		user.ID,
+		deleteAt,
+		nil,
+	)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	cancelURL, err := url.JoinPath(config.BaseURL, routes.AccountDeletionCancel.URL(cancelToken))
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if err := enqueueTransactionalEmail(
+		ctx,
+		tx,
+		insertOnly,
+		user.Email,
+		"Your Account Will Be Deleted",
+		email.AccountDeletionScheduled{
+			CancelURL: cancelURL,
+			DeleteAt:  deleteAt,
+		},
+		cancelToken,
+	); err != nil {
+		return models.User{}, err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   user.ID,
+		SubjectID: user.ID,
+		Action:    AuditUserDeletionScheduled,
+		Details: map[string]any{
+			"delete_at": deleteAt,
+		},
+	}); err != nil {
+		return models.User{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// CancelAccountDeletion keeps an account that is still in its grace period.
+// It is used by the owner after signing back in.
+func CancelAccountDeletion(
+	ctx context.Context,
+	db storage.Pool,
+	userID uuid.UUID,
+) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	user, err := models.FindUser(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := cancelAccountDeletion(ctx, tx, user); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// CancelAccountDeletionWithToken keeps an account from the link in the
+// deletion email, without needing a session.
+func CancelAccountDeletionWithToken(
+	ctx context.Context,
+	db storage.Pool,
+	pepper string,
+	token string,
+) (models.User, error) {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	tkn, err := models.FindTokenByScopeAndHash(ctx, tx, pepper, userAccountDeletionCancel, token)
+	if err != nil || !tkn.IsValid(token, pepper) {
+		return models.User{}, ErrInvalidDeletionCancel
+	}
+
+	user, err := models.FindUser(ctx, tx, tkn.UserID)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if err := cancelAccountDeletion(ctx, tx, user); err != nil {
+		if errors.Is(err, ErrDeletionNotScheduled) {
+			return models.User{}, ErrInvalidDeletionCancel
+		}
+		return models.User{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+func cancelAccountDeletion(
+	ctx context.Context,
+	exec storage.Executor,
+	user models.User,
+) error {
+	if !user.IsDeletionScheduled() {
+		return ErrDeletionNotScheduled
+	}
+
+	if _, err := models.ScheduleUserDeletion(ctx, exec, user.ID, time.Time{}); err != nil {
+		return err
+	}
+
+	if err := models.DestroyTokensByScopeAndUserID(ctx, exec, userAccountDeletionCancel, user.ID); err != nil {
+		return err
+	}
+
+	return Audit(ctx, exec, AuditEntry{
+		ActorID:   user.ID,
+		SubjectID: user.ID,
+		Action:    AuditUserDeletionCancelled,
+	})
+}
+
+// PurgeDeletedUsers removes every account whose grace period has ended, and
+// drops data exports whose links have expired. The user row goes, taking
+// everything that references it with it. Audit events are kept for the
+// record but stripped of the IP, user agent and details. Each account is
+// purged in its own transaction, so one failure does not hold up the rest;
+// the failures are returned together once every account was tried.
+func PurgeDeletedUsers(
+	ctx context.Context,
+	db storage.Pool,
+) error {
+	// Accounts that failed stay due, so skip past them rather than read
+	// the same batch again.
+	var failures []error
+	var offset int64
+	for {
+		users, err := models.FindUsersDueForDeletion(ctx, db.Conn(), purgeDeletedUsersBatchSize, offset)
+		if err != nil {
+			return errors.Join(append(failures, err)...)
+		}
+
+		for _, user := range users {
+			if err := purgeDeletedUser(ctx, db, user.ID); err != nil {
+				failures = append(failures, fmt.Errorf("purge user %s: %w", user.ID, err))
+				offset++
+			}
+		}
+
+		if len(users) < purgeDeletedUsersBatchSize {
+			break
+		}
+	}
+
+	if _, err := models.DestroyExpiredDataExports(ctx, db.Conn()); err != nil {
+		failures = append(failures, err)
+	}
+
+	return errors.Join(failures...)
+}
+
+func purgeDeletedUser(
+	ctx context.Context,
+	db storage.Pool,
+	userID uuid.UUID,
+) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// The owner may have cancelled since the batch was read.
+	user, err := models.FindUser(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !user.IsDeletionScheduled() || user.DeletionScheduledAt.After(time.Now()) {
+		return nil
+	}
+
+	if err := eraseUser(ctx, tx, user.ID, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// eraseUser removes an account for good. The user row goes, taking
+// everything that references it with it, and so does the logged email sent
+// to its address. Audit events are kept for the record but stripped of the
+// IP, user agent and details. The last owner of an organization is refused
+// with ErrLastOrganizationOwner.
+func eraseUser(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+	details map[string]any,
+) error {
+	user, err := models.FindUser(ctx, exec, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := ensureNoSoleOwnedOrganizations(ctx, exec, userID); err != nil {
+		return err
+	}
+
+	if _, err := models.RedactAuditEventsByUser(ctx, exec, userID, user.Email); err != nil {
+		return err
+	}
+
+	if _, err := models.DestroyEmailMessagesByRecipient(ctx, exec, user.Email); err != nil {
+		return err
+	}
+
+	if err := models.DestroyUser(ctx, exec, userID); err != nil {
+		return err
+	}
+
+	return Audit(ctx, exec, AuditEntry{
+		SubjectID: userID,
+		Action:    AuditUserDeleted,
+		Details:   details,
+	})
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/models"
+	"mbvlabs/models/factories"
+)
+
+func TestAccountDeletionGracePeriod(t *testing.T) {
+	db, insertOnly := testDB(t)
+	ctx := context.Background()
+
+	user, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schedule := func() (models.User, error) {
+		return ScheduleAccountDeletion(ctx, db, insertOnly, factories.TestPepper, ScheduleAccountDeletionData{
+			UserID:       user.ID,
+			Confirmation: " " + user.Email + " ",
+		})
+	}
+
+	if _, err := ScheduleAccountDeletion(ctx, db, insertOnly, factories.TestPepper, ScheduleAccountDeletionData{
+		UserID:       user.ID,
+		Confirmation: "someone@example.com",
+	}); !errors.Is(err, ErrDeletionConfirmationMismatch) {
+		t.Fatalf("scheduling with the wrong confirmation = %v, want ErrDeletionConfirmationMismatch", err)
+	}
+
+	scheduled, err := schedule()
+	if err != nil {
+		t.Fatalf("ScheduleAccountDeletion: %v", err)
+	}
+	if wait := time.Until(scheduled.DeletionScheduledAt); wait < AccountDeletionGracePeriod-time.Minute || wait > AccountDeletionGracePeriod {
+		t.Errorf("deletion is due in %s, want the %s grace period", wait, AccountDeletionGracePeriod)
+	}
+
+	if _, err := schedule(); !errors.Is(err, ErrDeletionAlreadyScheduled) {
+		t.Errorf("scheduling twice = %v, want ErrDeletionAlreadyScheduled", err)
+	}
+
+	// The account is not due yet, so a purge leaves it alone.
+	if err := PurgeDeletedUsers(ctx, db); err != nil {
+		t.Fatalf("PurgeDeletedUsers: %v", err)
+	}
+	if _, err := models.FindUser(ctx, db.Conn(), user.ID); err != nil {
+		t.Fatalf("account was purged during its grace period: %v", err)
+	}
+
+	emails := enqueuedEmails(t, db, user.Email)
+	if len(emails) != 1 || len(emails[0].Secrets) != 1 {
+		t.Fatalf("sent %d emails, want one with a cancellation link", len(emails))
+	}
+	cancel := emails[0].Secrets[0]
+
+	kept, err := CancelAccountDeletionWithToken(ctx, db, factories.TestPepper, cancel)
+	if err != nil {
+		t.Fatalf("CancelAccountDeletionWithToken: %v", err)
+	}
+	if kept.ID != user.ID {
+		t.Errorf("cancelled the deletion of %s, want %s", kept.ID, user.ID)
+	}
+
+	user, err = models.FindUser(ctx, db.Conn(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.IsDeletionScheduled() {
+		t.Error("account is still scheduled for deletion")
+	}
+
+	if _, err := CancelAccountDeletionWithToken(ctx, db, factories.TestPepper, cancel); !errors.Is(err, ErrInvalidDeletionCancel) {
+		t.Errorf("using the cancellation link twice = %v, want ErrInvalidDeletionCancel", err)
+	}
+}
+
+func TestPurgeDeletedUsers(t *testing.T) {
+	db, _ := testDB(t)
+	ctx := context.Background()
+
+	newUser := func(t *testing.T, deleteAt time.Time) models.User {
+		t.Helper()
+
+		user, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !deleteAt.IsZero() {
+			if user, err = models.ScheduleUserDeletion(ctx, db.Conn(), user.ID, deleteAt); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return user
+	}
+
+	due := newUser(t, time.Now().Add(-time.Minute))
+	notDue := newUser(t, time.Now().Add(time.Hour))
+	kept := newUser(t, time.Time{})
+
+	// A lockout names the address without a subject.
+	if err := Audit(ctx, db.Conn(), AuditEntry{
+		Action:  AuditUserLockedOut,
+		IP:      "192.0.2.1",
+		Details: map[string]any{"email": due.Email},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The sole owner of an organization cannot be purged; the others are
+	// purged regardless.
+	organization, owner := createTestOrganization(t, db)
+	if _, err := models.ScheduleUserDeletion(ctx, db.Conn(), owner.ID, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := CancelAccountDeletion(ctx, db, owner.ID); err != nil {
+			t.Error(err)
+		}
+	})
+
+	if err := PurgeDeletedUsers(ctx, db); !errors.Is(err, ErrLastOrganizationOwner) {
+		t.Fatalf("PurgeDeletedUsers = %v, want the sole owner's ErrLastOrganizationOwner", err)
+	}
+
+	tests := []struct {
+		name   string
+		userID uuid.UUID
+		purged bool
+	}{
+		{name: "due", userID: due.ID, purged: true},
+		{name: "still in the grace period", userID: notDue.ID},
+		{name: "not scheduled", userID: kept.ID},
+		{name: "sole organization owner", userID: owner.ID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := models.FindUser(ctx, db.Conn(), tt.userID)
+			if purged := errors.Is(err, sql.ErrNoRows); purged != tt.purged {
+				t.Errorf("purged = %v (%v), want %v", purged, err, tt.purged)
+			}
+		})
+	}
+
+	if got := auditCount(t, db, AuditUserLockedOut, due.Email); got != 0 {
+		t.Errorf("%d lockout audits still name the purged address", got)
+	}
+
+	if owners, err := models.CountOrganizationOwners(ctx, db.Conn(), organization.ID); err != nil || owners != 1 {
+		t.Errorf("organization has %d owners (%v), want 1", owners, err)
+	}
+}
+
+func TestDataExport(t *testing.T) {
+	db, insertOnly := testDB(t)
+	ctx := context.Background()
+
+	user, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ExportUserData(ctx, db, insertOnly, factories.TestPepper, user.ID); err != nil {
+		t.Fatalf("ExportUserData: %v", err)
+	}
+
+	emails := enqueuedEmails(t, db, user.Email)
+	if len(emails) != 1 || len(emails[0].Secrets) != 1 {
+		t.Fatalf("sent %d emails, want one with a download link", len(emails))
+	}
+	secret := emails[0].Secrets[0]
+
+	tests := []struct {
+		name    string
+		userID  uuid.UUID
+		secret  string
+		wantErr error
+	}{
+		{name: "owner", userID: user.ID, secret: secret},
+		{name: "someone else with the link", userID: other.ID, secret: secret, wantErr: ErrInvalidDataExport},
+		{name: "unknown link", userID: user.ID, secret: "not-a-secret", wantErr: ErrInvalidDataExport},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			export, err := DownloadDataExport(ctx, db, factories.TestPepper, tt.userID, tt.secret)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DownloadDataExport = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			archive, err := zip.NewReader(bytes.NewReader(export.Archive), int64(len(export.Archive)))
+			if err != nil {
+				t.Fatalf("archive is not a ZIP file: %v", err)
+			}
+			if _, err := archive.Open("account.json"); err != nil {
+				t.Errorf("archive has no account.json: %v", err)
+			}
+		})
+	}
+}
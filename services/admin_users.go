@@ -0,0 +1,295 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/queue"
+)
+
+var (
+	ErrAdminRequired        = errors.New("only admins can do this")
+	ErrCannotManageSelf     = errors.New("admins cannot do this to their own account")
+	ErrEmailAlreadyVerified = errors.New("email is already verified")
+)
+
+// AdminVerifyUserEmail marks the user's email as verified on their behalf,
+// for example after support confirmed ownership another way.
+func AdminVerifyUserEmail(
+	ctx context.Context,
+	db storage.Pool,
+	actor models.User,
+	id uuid.UUID,
+) (models.User, error) {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	user, err := findManagedUser(ctx, tx, actor, id)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if user.HasValidatedEmail() {
+		return models.User{}, ErrEmailAlreadyVerified
+	}
+
+	user, err = models.UpdateUser(ctx, tx, models.UpdateUserData{
+		ID:    user.ID,
+		Email: user.Email,
+		EmailValidatedAt: sql.NullTime{
+			Time:  time.Now(),
+			Valid: true,
+		},
+		IsAdmin: user.IsAdmin,
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if err := models.DestroyTokensByScopeAndUserID(ctx, tx, userEmailVerification, user.ID); err != nil {
+		return models.User{}, err
+	}
+
+	if err := recordAdminAction(ctx, tx, actor, user, AuditAdminUserEmailVerified); err != nil {
+		return models.User{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// AdminToggleUserAdmin grants or revokes admin. Only admins may do it, and
+// never to themselves, so the last admin cannot lock everyone out by accident.
+func AdminToggleUserAdmin(
+	ctx context.Context,
+	db storage.Pool,
+	actor models.User,
+	id uuid.UUID,
+) (models.User, error) {
+	if !actor.IsAdmin {
+		return models.User{}, ErrAdminRequired
+	}
+
+	if actor.ID == id {
+		return models.User{}, ErrCannotManageSelf
+	}
+
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	user, err := findManagedUser(ctx, tx, actor, id)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user, err = models.UpdateUser(ctx, tx, models.UpdateUserData{
+		ID:    user.ID,
+		Email: user.Email,
+		EmailValidatedAt: sql.NullTime{
+			Time:  user.EmailValidatedAt,
+			Valid: user.HasValidatedEmail(),
+		},
+		IsAdmin: !user.IsAdmin,
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	action := AuditAdminUserAdminRevoked
+	if user.IsAdmin {
+		action = AuditAdminUserAdminGranted
+	}
+
+	if err := recordAdminAction(ctx, tx, actor, user, action); err != nil {
+		return models.User{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// AdminForcePasswordReset replaces the user's password with a random one,
+// signs them out everywhere and emails them a reset link.
+func AdminForcePasswordReset(
+	ctx context.Context,
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	pepper string,
+	actor models.User,
+	id uuid.UUID,
+) (models.User, error) {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	user, err := findManagedUser(ctx, tx, actor, id)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user, err = scramblePassword(ctx, tx, pepper, user)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if err := models.RevokeUserSessions(ctx, tx, user.ID); err != nil {
+		return models.User{}, err
+	}
+
+	if err := sendPasswordReset(ctx, tx, insertOnly, pepper, user); err != nil {
+		return models.User{}, err
+	}
+
+	if err := recordAdminAction(ctx, tx, actor, user, AuditAdminUserPasswordResetForced); err != nil {
+		return models.User{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// AdminResendVerification sends a fresh verification code, skipping the
+// cooldown users are held to.
+func AdminResendVerification(
+	ctx context.Context,
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	pepper string,
+	actor models.User,
+	id uuid.UUID,
+) (models.User, error) {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	user, err := findManagedUser(ctx, tx, actor, id)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if user.HasValidatedEmail() {
+		return models.User{}, ErrEmailAlreadyVerified
+	}
+
+	if err := models.DestroyTokensByScopeAndUserID(ctx, tx, userEmailVerification, user.ID); err != nil {
+		return models.User{}, err
+	}
+
+	if err := sendVerificationCode(ctx, tx, insertOnly, pepper, user); err != nil {
+		return models.User{}, err
+	}
+
+	if err := recordAdminAction(ctx, tx, actor, user, AuditAdminUserVerificationResent); err != nil {
+		return models.User{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+func AdminDestroyUser(
+	ctx context.Context,
+	db storage.Pool,
+	actor models.User,
+	id uuid.UUID,
+) error {
+	if actor.ID == id {
+		return ErrCannotManageSelf
+	}
+
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	user, err := findManagedUser(ctx, tx, actor, id)
+	if err != nil {
+		return err
+	}
+
+	if err := ensureNoSoleOwnedOrganizations(ctx, tx, user.ID); err != nil {
+		return err
+	}
+
+	if err := models.DestroyUser(ctx, tx, user.ID); err != nil {
+		return err
+	}
+
+	if err := recordAdminAction(ctx, tx, actor, user, AuditAdminUserDeleted); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// findManagedUser loads the target of an admin action. Admin accounts can
+// only be managed by other admins.
+func findManagedUser(
+	ctx context.Context,
+	tx pgx.Tx,
+	actor models.User,
+	id uuid.UUID,
+) (models.User, error) {
+	user, err := models.FindUser(ctx, tx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, ErrUserNotFound
+		}
+		return models.User{}, err
+	}
+
+	if user.IsAdmin && !actor.IsAdmin {
+		return models.User{}, ErrAdminRequired
+	}
+
+	return user, nil
+}
+
+// recordAdminAction writes the audit entry for an admin action. The email is
+// kept in the details so the entry still makes sense after a deletion.
+func recordAdminAction(
+	ctx context.Context,
+	tx pgx.Tx,
+	actor models.User,
+	user models.User,
+	action string,
+) error {
+	return Audit(ctx, tx, AuditEntry{
+		ActorID:   actor.ID,
+		SubjectID: user.ID,
+		Action:    action,
+		Details: map[string]any{
+			"email": user.Email,
+		},
+	})
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/models"
+	"mbvlabs/models/factories"
+)
+
+func TestAdminUserGuards(t *testing.T) {
+	db, insertOnly := testDB(t)
+	ctx := context.Background()
+
+	newUser := func(t *testing.T, isAdmin bool) models.User {
+		t.Helper()
+		user, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail(), factories.WithIsAdmin(isAdmin))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user
+	}
+
+	actions := []struct {
+		name string
+		run  func(actor models.User, id uuid.UUID) error
+	}{
+		{
+			name: "toggle admin",
+			run: func(actor models.User, id uuid.UUID) error {
+				_, err := AdminToggleUserAdmin(ctx, db, actor, id)
+				return err
+			},
+		},
+		{
+			name: "force password reset",
+			run: func(actor models.User, id uuid.UUID) error {
+				_, err := AdminForcePasswordReset(ctx, db, insertOnly, factories.TestPepper, actor, id)
+				return err
+			},
+		},
+		{
+			name: "delete",
+			run: func(actor models.User, id uuid.UUID) error {
+				return AdminDestroyUser(ctx, db, actor, id)
+			},
+		},
+	}
+
+	targets := []struct {
+		name         string
+		actorIsAdmin bool
+		targetAdmin  bool
+		self         bool
+		wantErr      error
+	}{
+		{name: "support on a user", targetAdmin: false},
+		{name: "support on an admin", targetAdmin: true, wantErr: ErrAdminRequired},
+		{name: "admin on an admin", actorIsAdmin: true, targetAdmin: true},
+		{name: "admin on themselves", actorIsAdmin: true, self: true, wantErr: ErrCannotManageSelf},
+	}
+
+	for _, action := range actions {
+		for _, tt := range targets {
+			t.Run(action.name+"/"+tt.name, func(t *testing.T) {
+				wantErr := tt.wantErr
+				// Only admins may hand out or take away admin rights.
+				if action.name == "toggle admin" && !tt.actorIsAdmin {
+					wantErr = ErrAdminRequired
+				}
+
+				actor := newUser(t, tt.actorIsAdmin)
+				target := newUser(t, tt.targetAdmin)
+				if tt.self {
+					target = actor
+				}
+
+				if err := action.run(actor, target.ID); !errors.Is(err, wantErr) {
+					t.Errorf("got %v, want %v", err, wantErr)
+				}
+			})
+		}
+	}
+
+	t.Run("unknown user", func(t *testing.T) {
+		if err := AdminDestroyUser(ctx, db, newUser(t, true), uuid.New()); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("AdminDestroyUser = %v, want ErrUserNotFound", err)
+		}
+	})
+}
+
+func TestPaginateUsersSearchIsLiteral(t *testing.T) {
+	db, _ := testDB(t)
+	ctx := context.Background()
+
+	prefix := uuid.NewString()[:8]
+	for _, local := range []string{"a_b", "axb", "100%", "1000", "backslash"} {
+		if _, err := factories.CreateUser(ctx, db.Conn(), factories.WithEmail(prefix+local+"@example.com")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		search string
+		want   int64
+	}{
+		{search: prefix + "a_b", want: 1},
+		{search: prefix + "100%", want: 1},
+		{search: prefix + `back\slash`, want: 0},
+		{search: prefix + "%", want: 1},
+		{search: prefix + "_", want: 0},
+		{search: prefix, want: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.search, func(t *testing.T) {
+			users, err := models.PaginateUsers(ctx, db.Conn(), tt.search, 1, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if users.TotalCount != tt.want || int64(len(users.Users)) != tt.want {
+				t.Errorf("search %q found %d users (%d on the page), want %d", tt.search, users.TotalCount, len(users.Users), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+)
+
+// apiTokenTouchInterval limits how often last_used_at is written, so a busy
+// client does not turn every API call into an update.
+const apiTokenTouchInterval = time.Minute
+
+var ErrInvalidAPIToken = errors.New("invalid or expired api token")
+
+type CreateAPITokenData struct {
+	UserID    uuid.UUID
+	Name      string
+	Scopes    []string
+	ExpiresIn time.Duration
+}
+
+// CreateAPIToken issues a personal access token and returns its secret, which
+// is not stored and must be shown to the user straight away.
+func CreateAPIToken(
+	ctx context.Context,
+	db storage.Pool,
+	pepper string,
+	data CreateAPITokenData,
+) (models.APIToken, string, error) {
+	var expiresAt time.Time
+	if data.ExpiresIn > 0 {
+		expiresAt = time.Now().Add(data.ExpiresIn)
+	}
+
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return models.APIToken{}, "", err
+	}
+	defer tx.Rollback(ctx)
+
+	token, secret, err := models.CreateAPIToken(ctx, tx, pepper, models.CreateAPITokenData{
+		UserID:    data.UserID,
+		Name:      strings.TrimSpace(data.Name),
+		Scopes:    data.Scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return models.APIToken{}, "", err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   data.UserID,
+		SubjectID: data.UserID,
+		Action:    AuditUserAPITokenCreated,
+		Details: map[string]any{
+			"token_id": token.ID,
+			"name":     token.Name,
+			"scopes":   token.Scopes,
+		},
+	}); err != nil {
+		return models.APIToken{}, "", err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.APIToken{}, "", err
+	}
+
+	return token, secret, nil
+}
+
+// AuthenticateAPIToken resolves a bearer secret to its token and owner.
+// Unknown, expired and unverified-owner tokens all fail the same way.
+func AuthenticateAPIToken(
+	ctx context.Context,
+	db storage.Pool,
+	pepper string,
+	secret string,
+) (models.User, models.APIToken, error) {
+	if secret == "" {
+		return models.User{}, models.APIToken{}, ErrInvalidAPIToken
+	}
+
+	token, err := models.FindAPITokenBySecret(ctx, db.Conn(), pepper, secret)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, models.APIToken{}, ErrInvalidAPIToken
+		}
+		return models.User{}, models.APIToken{}, err
+	}
+
+	if token.IsExpired() {
+		return models.User{}, models.APIToken{}, ErrInvalidAPIToken
+	}
+
+	user, err := models.FindUser(ctx, db.Conn(), token.UserID)
+	if err != nil {
+		return models.User{}, models.APIToken{}, err
+	}
+
+	if !user.HasValidatedEmail() || user.IsDeletionScheduled() {
+		return models.User{}, models.APIToken{}, ErrInvalidAPIToken
+	}
+
+	if time.Since(token.LastUsedAt) > apiTokenTouchInterval {
+		if err := models.TouchAPIToken(ctx, db.Conn(), token.ID); err != nil {
+			return models.User{}, models.APIToken{}, err
+		}
+		token.LastUsedAt = time.Now()
+	}
+
+	return user, token, nil
+}
+
+func RevokeAPIToken(
+	ctx context.Context,
+	db storage.Pool,
+	userID uuid.UUID,
+	id uuid.UUID,
+) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := models.DestroyAPIToken(ctx, tx, id, userID); err != nil {
+		return err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   userID,
+		SubjectID: userID,
+		Action:    AuditUserAPITokenRevoked,
+		Details: map[string]any{
+			"token_id": id,
+		},
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/models"
+	"mbvlabs/models/factories"
+)
+
+func TestCreateAPITokenScopes(t *testing.T) {
+	db, _ := testDB(t)
+	ctx := context.Background()
+
+	user, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		scopes  []string
+		wantErr error
+	}{
+		{name: "read", scopes: []string{models.APIScopeRead}},
+		{name: "read and write", scopes: []string{models.APIScopeRead, models.APIScopeWrite}},
+		{name: "none", scopes: nil, wantErr: models.ErrDomainValidation},
+		{name: "unknown", scopes: []string{"admin"}, wantErr: models.ErrDomainValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, secret, err := CreateAPIToken(ctx, db, factories.TestPepper, CreateAPITokenData{
+				UserID: user.ID,
+				Name:   "CI",
+				Scopes: tt.scopes,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateAPIToken = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			_, token, err := AuthenticateAPIToken(ctx, db, factories.TestPepper, secret)
+			if err != nil {
+				t.Fatalf("AuthenticateAPIToken: %v", err)
+			}
+			for _, scope := range models.APIScopes {
+				want := false
+				for _, granted := range tt.scopes {
+					want = want || granted == scope
+				}
+				if got := token.HasScope(scope); got != want {
+					t.Errorf("HasScope(%q) = %v, want %v", scope, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthenticateAPIToken(t *testing.T) {
+	db, _ := testDB(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		// prepare changes the token or its owner before it is used.
+		prepare func(t *testing.T, user models.User, token models.APIToken)
+		secret  func(secret string) string
+		wantErr error
+	}{
+		{name: "valid"},
+		{
+			name:    "empty secret",
+			secret:  func(string) string { return "" },
+			wantErr: ErrInvalidAPIToken,
+		},
+		{
+			name:    "wrong secret",
+			secret:  func(secret string) string { return secret + "x" },
+			wantErr: ErrInvalidAPIToken,
+		},
+		{
+			name: "expired",
+			prepare: func(t *testing.T, _ models.User, token models.APIToken) {
+				if _, err := db.Conn().Exec(
+					ctx,
+					`update api_tokens set expires_at = now() - interval '1 minute' where id = $1`,
+					token.ID,
+				); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: ErrInvalidAPIToken,
+		},
+		{
+			name: "revoked",
+			prepare: func(t *testing.T, user models.User, token models.APIToken) {
+				if err := RevokeAPIToken(ctx, db, user.ID, token.ID); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: ErrInvalidAPIToken,
+		},
+		{
+			name: "revoked by someone else",
+			prepare: func(t *testing.T, _ models.User, token models.APIToken) {
+				other, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := RevokeAPIToken(ctx, db, other.ID, token.ID); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "owner scheduled for deletion",
+			prepare: func(t *testing.T, user models.User, _ models.APIToken) {
+				if _, err := models.ScheduleUserDeletion(ctx, db.Conn(), user.ID, time.Now().Add(time.Hour)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: ErrInvalidAPIToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			token, secret, err := CreateAPIToken(ctx, db, factories.TestPepper, CreateAPITokenData{
+				UserID:    user.ID,
+				Name:      "CI " + uuid.NewString(),
+				Scopes:    []string{models.APIScopeRead},
+				ExpiresIn: time.Hour,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.prepare != nil {
+				tt.prepare(t, user, token)
+			}
+			if tt.secret != nil {
+				secret = tt.secret(secret)
+			}
+
+			owner, got, err := AuthenticateAPIToken(ctx, db, factories.TestPepper, secret)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AuthenticateAPIToken = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (owner.ID != user.ID || got.ID != token.ID) {
+				t.Errorf("authenticated token %s of %s, want %s of %s", got.ID, owner.ID, token.ID, user.ID)
+			}
+		})
+	}
+}
+
+func TestAPITokenNeedsVerifiedOwner(t *testing.T) {
+	db, _ := testDB(t)
+	ctx := context.Background()
+
+	user, err := factories.CreateUser(ctx, db.Conn())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, secret, err := CreateAPIToken(ctx, db, factories.TestPepper, CreateAPITokenData{
+		UserID: user.ID,
+		Name:   "CI",
+		Scopes: []string{models.APIScopeRead},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := AuthenticateAPIToken(ctx, db, factories.TestPepper, secret); !errors.Is(err, ErrInvalidAPIToken) {
+		t.Errorf("AuthenticateAPIToken for an unverified owner = %v, want ErrInvalidAPIToken", err)
+	}
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/telemetry"
+)
+
+// Audit actions. They are namespaced by who acts, so a prefix filter such as
+// "admin." selects a whole group.
+const (
+	AuditUserRegistered             = "user.registered"
+	AuditUserSignedIn               = "user.signed_in"
+	AuditUserSignInFailed           = "user.sign_in_failed"
+	AuditUserSignedOut              = "user.signed_out"
+	AuditUserSessionsRevoked        = "user.sessions_revoked"
+	AuditUserLockedOut              = "user.locked_out"
+	AuditUserEmailVerified          = "user.email_verified"
+	AuditUserPasswordResetRequested = "user.password_reset_requested"
+	AuditUserPasswordReset          = "user.password_reset"
+	AuditUserPasswordChanged        = "user.password_changed"
+	AuditUserEmailChangeRequested   = "user.email_change_requested"
+	AuditUserEmailChanged           = "user.email_changed"
+	AuditUserEmailChangeReverted    = "user.email_change_reverted"
+	AuditUserTwoFactorEnabled       = "user.two_factor_enabled"
+	AuditUserTwoFactorDisabled      = "user.two_factor_disabled"
+	AuditUserPasskeyAdded           = "user.passkey_added"
+	AuditUserPasskeyRemoved         = "user.passkey_removed"
+	AuditUserAPITokenCreated        = "user.api_token_created"
+	AuditUserAPITokenRevoked        = "user.api_token_revoked"
+	AuditUserIdentityLinked         = "user.identity_linked"
+	AuditUserDataExportRequested    = "user.data_export_requested"
+	AuditUserDataExported           = "user.data_exported"
+	AuditUserDeletionScheduled      = "user.deletion_scheduled"
+	AuditUserDeletionCancelled      = "user.deletion_cancelled"
+	AuditUserDeleted                = "user.deleted"
+	AuditUserDeviceRevoked          = "user.device_revoked"
+	AuditUserRememberTokenReused    = "user.remember_token_reused"
+
+	AuditOrganizationCreated           = "organization.created"
+	AuditOrganizationInvitationSent    = "organization.invitation_sent"
+	AuditOrganizationInvitationRevoked = "organization.invitation_revoked"
+	AuditOrganizationMemberJoined      = "organization.member_joined"
+	AuditOrganizationMemberRoleChanged = "organization.member_role_changed"
+	AuditOrganizationMemberRemoved     = "organization.member_removed"
+
+	AuditAdminUserEmailVerified       = "admin.user.email_verified"
+	AuditAdminUserAdminGranted        = "admin.user.admin_granted"
+	AuditAdminUserAdminRevoked        = "admin.user.admin_revoked"
+	AuditAdminUserPasswordResetForced = "admin.user.password_reset_forced"
+	AuditAdminUserDeleted             = "admin.user.deleted"
+	AuditAdminUserVerificationResent  = "admin.user.verification_resent"
+	AuditImpersonationStarted         = "admin.impersonation.started"
+	AuditImpersonationStopped         = "admin.impersonation.stopped"
+	AuditAdminAuditExported           = "admin.audit.exported"
+	AuditAdminEmailSuppressionLifted  = "admin.email_suppression.lifted"
+)
+
+// RequestMetadata describes the client behind a request. It travels in the
+// context so services can audit without knowing about HTTP.
+type RequestMetadata struct {
+	IP        string
+	UserAgent string
+}
+
+type requestMetadataKey struct{}
+
+func WithRequestMetadata(ctx context.Context, metadata RequestMetadata) context.Context {
+	return context.WithValue(ctx, requestMetadataKey{}, metadata)
+}
+
+func requestMetadataFromContext(ctx context.Context) RequestMetadata {
+	metadata, _ := ctx.Value(requestMetadataKey{}).(RequestMetadata)
+	return metadata
+}
+
+// truncateUserAgent caps a client supplied user agent before it is stored,
+// so an oversized header cannot bloat the tables that record it.
+func truncateUserAgent(userAgent string) string {
+	if len(userAgent) > 512 {
+		return userAgent[:512]
+	}
+
+	return userAgent
+}
+
+// AuditEntry is one security relevant event. The actor is who did it and the
+// subject whose account it concerns; for self service they are the same.
+type AuditEntry struct {
+	ActorID   uuid.UUID
+	SubjectID uuid.UUID
+	Action    string
+	IP        string
+	UserAgent string
+	Details   map[string]any
+}
+
+// Audit appends entry to the audit log. Pass the transaction of the change
+// being audited so the entry is written if and only if the change is. IP and
+// user agent default to the request metadata in ctx, and the current trace ID
+// is attached so the entry can be found in traces.
+func Audit(
+	ctx context.Context,
+	exec storage.Executor,
+	entry AuditEntry,
+) error {
+	metadata := requestMetadataFromContext(ctx)
+	if entry.IP == "" {
+		entry.IP = metadata.IP
+	}
+	if entry.UserAgent == "" {
+		entry.UserAgent = metadata.UserAgent
+	}
+	entry.UserAgent = truncateUserAgent(entry.UserAgent)
+
+	_, err := models.CreateAuditEvent(ctx, exec, models.CreateAuditEventData{
+		ActorID:   entry.ActorID,
+		SubjectID: entry.SubjectID,
+		Action:    entry.Action,
+		IP:        entry.IP,
+		UserAgent: entry.UserAgent,
+		TraceID:   telemetry.TraceID(ctx),
+		Details:   entry.Details,
+	})
+
+	return err
+}
+
+// auditExportBatchSize is how many events are read per query while
+// streaming an export.
+const auditExportBatchSize = 500
+
+// ExportAuditEvents writes every event matching filter to w as CSV, newest
+// first, and records that the export happened.
+func ExportAuditEvents(
+	ctx context.Context,
+	db storage.Pool,
+	actorID uuid.UUID,
+	filter models.AuditEventFilter,
+	w io.Writer,
+) error {
+	if err := Audit(ctx, db.Conn(), AuditEntry{
+		ActorID: actorID,
+		Action:  AuditAdminAuditExported,
+		Details: map[string]any{
+			"actor_id":   filter.ActorID,
+			"subject_id": filter.SubjectID,
+			"action":     filter.Action,
+			"since":      filter.Since,
+			"until":      filter.Until,
+		},
+	}); err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{
+		"id", "created_at", "action", "actor_id", "subject_id",
+		"ip", "user_agent", "trace_id", "details",
+	}); err != nil {
+		return err
+	}
+
+	// Events are appended newest first while paging, so pin the upper bound
+	// to keep pages from shifting mid export.
+	if filter.Until.IsZero() {
+		filter.Until = time.Now()
+	}
+
+	for offset := int64(0); ; offset += auditExportBatchSize {
+		events, err := models.FindAuditEvents(ctx, db.Conn(), filter, auditExportBatchSize, offset)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			details, err := json.Marshal(event.Details)
+			if err != nil {
+				return err
+			}
+
+			if err := writer.Write([]string{
+				event.ID.String(),
+				event.CreatedAt.UTC().Format(time.RFC3339),
+				csvCell(event.Action),
+				auditID(event.ActorID),
+				auditID(event.SubjectID),
+				csvCell(event.IP),
+				csvCell(event.UserAgent),
+				csvCell(event.TraceID),
+				csvCell(string(details)),
+			}); err != nil {
+				return err
+			}
+		}
+
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+
+		if len(events) < auditExportBatchSize {
+			return nil
+		}
+	}
+}
+
+// csvCell keeps a client supplied value from being read as a formula by
+// spreadsheets opening the export, by prefixing the characters that start
+// one with a quote.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
+
+func auditID(id uuid.UUID) string {
+	if id == uuid.Nil {
+		return ""
+	}
+
+	return id.String()
+}
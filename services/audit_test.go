@@ -0,0 +1,23 @@
+package services
+
+import "testing"
+
+func TestCSVCell(t *testing.T) {
+	tests := map[string]string{
+		"":                           "",
+		"Mozilla/5.0":                "Mozilla/5.0",
+		"=HYPERLINK(\"http://x\")":   "'=HYPERLINK(\"http://x\")",
+		"+1":                         "'+1",
+		"-2+3":                       "'-2+3",
+		"@SUM(A1)":                   "'@SUM(A1)",
+		"\t=1":                       "'\t=1",
+		"\r=1":                       "'\r=1",
+		`{"email":"=cmd|' /C calc"}`: `{"email":"=cmd|' /C calc"}`,
+	}
+
+	for value, want := range tests {
+		if got := csvCell(value); got != want {
+			t.Errorf("csvCell(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
@@ -19,6 +19,9 @@ type LoginData struct {
 	Password string
 }
 
+// AuthenticateUser checks an email and password. Unknown emails and wrong
+// passwords both return ErrInvalidCredentials after one password hash, so
+// neither the error nor the response time tells whether an account exists.
 func AuthenticateUser(
 	ctx context.Context,
 	db storage.Pool,
@@ -28,6 +31,18 @@ func AuthenticateUser(
 	user, err := models.FindUserByEmail(ctx, db.Conn(), data.Email)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			models.DummyPasswordCheck(data.Password, salt)
+
+			if err := Audit(ctx, db.Conn(), AuditEntry{
+				Action: AuditUserSignInFailed,
+				Details: map[string]any{
+					"email":  data.Email,
+					"reason": "unknown_email",
+				},
+			}); err != nil {
+				return models.User{}, err
+			}
+
 			return models.User{}, ErrInvalidCredentials
 		}
 
@@ -40,7 +55,39 @@ func AuthenticateUser(
 	}
 
 	if !validPassword {
-		return models.User{}, errors.New("invalid password")
+		if err := Audit(ctx, db.Conn(), AuditEntry{
+			SubjectID: user.ID,
+			Action:    AuditUserSignInFailed,
+			Details: map[string]any{
+				"email":  user.Email,
+				"reason": "invalid_password",
+			},
+		}); err != nil {
+			return models.User{}, err
+		}
+
+		return models.User{}, ErrInvalidCredentials
+	}
+
+	if user.PasswordNeedsRehash() {
+		hashedPassword, err := models.HashPassword(data.Password, salt)
+		if err != nil {
+			return models.User{}, err
+		}
+
+		user, err = models.UpdateUser(ctx, db.Conn(), models.UpdateUserData{
+			ID:    user.ID,
+			Email: user.Email,
+			EmailValidatedAt: sql.NullTime{
+				Time:  user.EmailValidatedAt,
+				Valid: !user.EmailValidatedAt.IsZero(),
+			},
+			Password: []byte(hashedPassword),
+			IsAdmin:  user.IsAdmin,
+		})
+		if err != nil {
+			return models.User{}, err
+		}
 	}
 
 	if user.EmailValidatedAt.IsZero() {
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/models/factories"
+)
+
+// TestUnknownEmailsLookLikeKnownOnes checks that sign-in, registration and
+// password reset answer the same for an address with an account as for one
+// without, so none of them can be used to find accounts.
+func TestUnknownEmailsLookLikeKnownOnes(t *testing.T) {
+	db, insertOnly := testDB(t)
+	ctx := context.Background()
+
+	const password = "violet harbor lantern 42"
+
+	tests := []struct {
+		name string
+		// try runs the flow for the address and returns its error.
+		try func(address string) error
+		// wantErr is what both kinds of address must get.
+		wantErr error
+	}{
+		{
+			name: "sign in with a wrong password",
+			try: func(address string) error {
+				_, err := AuthenticateUser(ctx, db, factories.TestPepper, LoginData{
+					Email:    address,
+					Password: "not the password",
+				})
+				return err
+			},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name: "register",
+			try: func(address string) error {
+				return RegisterUser(ctx, db, insertOnly, factories.TestPepper, RegisterUserData{
+					Email:           address,
+					Password:        password,
+					ConfirmPassword: password,
+				})
+			},
+		},
+		{
+			name: "request a password reset",
+			try: func(address string) error {
+				return RequestResetPassword(ctx, db, insertOnly, factories.TestPepper, RequestResetPasswordData{
+					Email: address,
+				})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			known, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+			if err != nil {
+				t.Fatal(err)
+			}
+			unverified, err := factories.CreateUser(ctx, db.Conn())
+			if err != nil {
+				t.Fatal(err)
+			}
+			unknown := uuid.NewString() + "@example.com"
+
+			for _, address := range []string{known.Email, unverified.Email, unknown} {
+				if err := tt.try(address); !errors.Is(err, tt.wantErr) {
+					t.Errorf("%s: got %v, want %v", address, err, tt.wantErr)
+				}
+			}
+
+			// Every address gets one email, or none, alike.
+			want := len(enqueuedEmails(t, db, unknown))
+			for _, address := range []string{known.Email, unverified.Email} {
+				if got := len(enqueuedEmails(t, db, address)); got != want {
+					t.Errorf("%s got %d emails, an unknown address %d", address, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSignInFailuresAreAudited(t *testing.T) {
+	db, _ := testDB(t)
+	ctx := context.Background()
+
+	known, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown := uuid.NewString() + "@example.com"
+
+	for _, address := range []string{known.Email, unknown} {
+		if _, err := AuthenticateUser(ctx, db, factories.TestPepper, LoginData{
+			Email:    address,
+			Password: "not the password",
+		}); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("%s: AuthenticateUser = %v, want ErrInvalidCredentials", address, err)
+		}
+
+		if got := auditCount(t, db, AuditUserSignInFailed, address); got != 1 {
+			t.Errorf("%s: %d failed sign-ins audited, want 1", address, got)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"mbvlabs/models"
+	"mbvlabs/models/factories"
+)
+
+func TestAuthorize(t *testing.T) {
+	db, _ := testDB(t)
+	ctx := context.Background()
+
+	permissions := []string{
+		models.PermissionUsersManage,
+		models.PermissionUsersImpersonate,
+		models.PermissionAuditView,
+		models.PermissionJobsManage,
+		models.PermissionEmailsView,
+		models.PermissionEmailsManage,
+	}
+
+	tests := []struct {
+		name    string
+		isAdmin bool
+		role    string
+		want    []string
+	}{
+		{name: "user"},
+		{
+			name: "support",
+			role: "support",
+			want: []string{
+				models.PermissionUsersManage,
+				models.PermissionUsersImpersonate,
+				models.PermissionEmailsManage,
+			},
+		},
+		{name: "auditor", role: "auditor", want: []string{models.PermissionAuditView}},
+		{name: "admin", isAdmin: true, want: permissions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail(), factories.WithIsAdmin(tt.isAdmin))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.role != "" {
+				role, err := models.FindRoleByName(ctx, db.Conn(), tt.role)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := models.AssignUserRole(ctx, db.Conn(), user.ID, role.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, permission := range permissions {
+				want := slices.Contains(tt.want, permission)
+				got, err := models.Authorize(ctx, db.Conn(), user, permission)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != want {
+					t.Errorf("Authorize(%q) = %v, want %v", permission, got, want)
+				}
+			}
+
+			got, err := models.FindUserPermissions(ctx, db.Conn(), user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, permission := range permissions {
+				if slices.Contains(got, permission) != slices.Contains(tt.want, permission) {
+					t.Errorf("FindUserPermissions = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestRemovedRoleRevokesPermissions(t *testing.T) {
+	db, _ := testDB(t)
+	ctx := context.Background()
+
+	user, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	role, err := models.FindRoleByName(ctx, db.Conn(), "auditor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := models.AssignUserRole(ctx, db.Conn(), user.ID, role.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := models.Authorize(ctx, db.Conn(), user, models.PermissionAuditView); err != nil || !ok {
+		t.Fatalf("Authorize with the role = %v (%v), want true", ok, err)
+	}
+
+	if err := models.RemoveUserRole(ctx, db.Conn(), user.ID, role.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := models.Authorize(ctx, db.Conn(), user, models.PermissionAuditView); err != nil || ok {
+		t.Errorf("Authorize after the role was removed = %v (%v), want false", ok, err)
+	}
+
+	permissions, err := models.FindUserPermissions(ctx, db.Conn(), user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(permissions) != 0 {
+		t.Errorf("user still holds %v after the role was removed", permissions)
+	}
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+)
+
+const (
+	purgeExpiredTokensBatchSize   = 1000
+	purgeUnverifiedUsersBatchSize = 100
+)
+
+// PurgeExpiredTokens deletes every expired token, a batch at a time so no
+// single statement holds locks for long. It returns how many were deleted.
+func PurgeExpiredTokens(
+	ctx context.Context,
+	db storage.Pool,
+) (int64, error) {
+	var total int64
+	for {
+		deleted, err := models.DestroyExpiredTokens(ctx, db.Conn(), purgeExpiredTokensBatchSize)
+		if err != nil {
+			return total, err
+		}
+
+		total += deleted
+
+		if deleted < purgeExpiredTokensBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// PurgeUnverifiedUsers removes accounts that signed up more than maxAge ago
+// and never verified their email. They cannot sign in, and keeping them
+// would hold on to the address and personal data for nothing. It returns
+// how many accounts were removed. A maxAge of zero or less keeps them.
+func PurgeUnverifiedUsers(
+	ctx context.Context,
+	db storage.Pool,
+	maxAge time.Duration,
+) (int64, error) {
+	if maxAge <= 0 {
+		return 0, nil
+	}
+
+	createdBefore := time.Now().Add(-maxAge)
+
+	var total int64
+	for {
+		users, err := models.FindUnverifiedUsersCreatedBefore(
+			ctx,
+			db.Conn(),
+			createdBefore,
+			purgeUnverifiedUsersBatchSize,
+		)
+		if err != nil {
+			return total, err
+		}
+
+		for _, user := range users {
+			purged, err := purgeUnverifiedUser(ctx, db, user.ID, createdBefore)
+			if err != nil {
+				return total, err
+			}
+
+			if purged {
+				total++
+			}
+		}
+
+		if len(users) < purgeUnverifiedUsersBatchSize {
+			return total, nil
+		}
+	}
+}
+
+func purgeUnverifiedUser(
+	ctx context.Context,
+	db storage.Pool,
+	userID uuid.UUID,
+	createdBefore time.Time,
+) (bool, error) {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	// The user may have verified since the batch was read.
+	user, err := models.FindUser(ctx, tx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	if user.HasValidatedEmail() || user.IsAdmin || !user.CreatedAt.Before(createdBefore) {
+		return false, nil
+	}
+
+	if err := eraseUser(ctx, tx, user.ID, map[string]any{
+		"reason": "unverified",
+	}); err != nil {
+		// Only possible if the user became a sole owner since the batch
+		// was read; the next batch leaves them out.
+		if errors.Is(err, ErrLastOrganizationOwner) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
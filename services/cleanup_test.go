@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/models"
+	"mbvlabs/models/factories"
+)
+
+func TestPurgeExpiredTokens(t *testing.T) {
+	db, _ := testDB(t)
+	ctx := context.Background()
+
+	scope := "cleanup-" + uuid.NewString()
+	expired := 2*purgeExpiredTokensBatchSize + 1
+
+	if _, err := db.Conn().Exec(
+		ctx,
+		`insert into tokens (id, created_at, updated_at, scope, expires_at, hash, meta_data)
+		select gen_random_uuid(), now(), now(), $1, now() - interval '1 minute', 'expired-' || n, '{}'
+		from generate_series(1, $2::int) n`,
+		scope,
+		expired,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := factories.CreateToken(ctx, db.Conn(), factories.WithScope(scope)); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := PurgeExpiredTokens(ctx, db)
+	if err != nil {
+		t.Fatalf("PurgeExpiredTokens: %v", err)
+	}
+	if deleted < int64(expired) {
+		t.Errorf("deleted %d tokens, want at least the %d expired ones", deleted, expired)
+	}
+
+	var left int
+	if err := db.Conn().QueryRow(
+		ctx,
+		`select count(*) from tokens where scope = $1`,
+		scope,
+	).Scan(&left); err != nil {
+		t.Fatal(err)
+	}
+	if left != 1 {
+		t.Errorf("%d tokens left, want only the valid one", left)
+	}
+}
+
+func TestPurgeUnverifiedUsers(t *testing.T) {
+	db, _ := testDB(t)
+	ctx := context.Background()
+
+	// newUser creates a user who signed up age ago.
+	newUser := func(t *testing.T, age time.Duration, opts ...factories.UserOption) models.User {
+		t.Helper()
+
+		user, err := factories.CreateUser(ctx, db.Conn(), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := db.Conn().Exec(
+			ctx,
+			`update users set created_at = $2 where id = $1`,
+			user.ID,
+			time.Now().Add(-age),
+		); err != nil {
+			t.Fatal(err)
+		}
+
+		return user
+	}
+
+	if purged, err := PurgeUnverifiedUsers(ctx, db, 0); err != nil || purged != 0 {
+		t.Fatalf("PurgeUnverifiedUsers without a max age = %d (%v), want 0", purged, err)
+	}
+
+	// More stale accounts than fit in one batch.
+	stale := make([]models.User, purgeUnverifiedUsersBatchSize+1)
+	for i := range stale {
+		stale[i] = newUser(t, 2*time.Hour)
+	}
+
+	tests := []struct {
+		name   string
+		user   models.User
+		purged bool
+	}{
+		{name: "unverified, first batch", user: stale[0], purged: true},
+		{name: "unverified, second batch", user: stale[len(stale)-1], purged: true},
+		{name: "unverified, recent", user: newUser(t, time.Minute)},
+		{name: "verified", user: newUser(t, 2*time.Hour, factories.WithValidatedEmail())},
+		{name: "admin", user: newUser(t, 2*time.Hour, factories.WithIsAdmin(true))},
+	}
+
+	purged, err := PurgeUnverifiedUsers(ctx, db, time.Hour)
+	if err != nil {
+		t.Fatalf("PurgeUnverifiedUsers: %v", err)
+	}
+	if purged < int64(len(stale)) {
+		t.Errorf("purged %d accounts, want at least the %d stale ones", purged, len(stale))
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := models.FindUser(ctx, db.Conn(), tt.user.ID)
+			if purged := errors.Is(err, sql.ErrNoRows); purged != tt.purged {
+				t.Errorf("purged = %v (%v), want %v", purged, err, tt.purged)
+			}
+		})
+	}
+}
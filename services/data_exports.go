@@ -0,0 +1,443 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/riverqueue/river"
+
+	"mbvlabs/config"
+	"mbvlabs/email"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/queue"
+	"mbvlabs/queue/jobs"
+	"mbvlabs/router/routes"
+)
+
+const (
+	DataExportDuration = 7 * 24 * time.Hour
+
+	// dataExportRequestWindow folds repeated clicks into a single job.
+	dataExportRequestWindow = 10 * time.Minute
+)
+
+var ErrInvalidDataExport = errors.New("invalid or expired data export link")
+
+// RequestDataExport queues a job that builds an archive of the user's data
+// and emails them a link to it.
+func RequestDataExport(
+	ctx context.Context,
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	userID uuid.UUID,
+) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := insertOnly.InsertTx(ctx, tx, jobs.ExportUserDataArgs{
+		UserID: userID,
+	}, &river.InsertOpts{
+		UniqueOpts: river.UniqueOpts{
+			ByArgs:   true,
+			ByPeriod: dataExportRequestWindow,
+		},
+	}); err != nil {
+		return err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   userID,
+		SubjectID: userID,
+		Action:    AuditUserDataExportRequested,
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ExportUserData builds the archive, stores it and emails the owner a
+// download link. It runs from the export job.
+func ExportUserData(
+	ctx context.Context,
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	pepper string,
+	userID uuid.UUID,
+) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	user, err := models.FindUser(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+
+	archive, err := buildDataExportArchive(ctx, tx, user)
+	if err != nil {
+		return err
+	}
+
+	export, secret, err := models.CreateDataExport(ctx, tx, pepper, models.CreateDataExportData{
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(DataExportDuration),
+		Archive:   archive,
+	})
+	if err != nil {
+		return err
+	}
+
+	downloadURL, err := url.JoinPath(config.BaseURL, routes.DataExportShow.URL(secret))
+	if err != nil {
+		return err
+	}
+
+	if err := enqueueTransactionalEmail(
+		ctx,
+		tx,
+		insertOnly,
+		user.Email,
+		"Your Data Export Is Ready",
+		email.DataExportReady{
+			DownloadURL: downloadURL,
+			ExpiresAt:   export.ExpiresAt,
+		},
+		secret,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// DownloadDataExport returns the archive behind a download link. The link
+// only works for the account it was made for, so a forwarded email is not
+// enough to read someone's data.
+func DownloadDataExport(
+	ctx context.Context,
+	db storage.Pool,
+	pepper string,
+	userID uuid.UUID,
+	secret string,
+) (models.DataExport, error) {
+	export, err := models.FindDataExportBySecret(ctx, db.Conn(), pepper, secret)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.DataExport{}, ErrInvalidDataExport
+		}
+		return models.DataExport{}, err
+	}
+
+	if export.UserID != userID || export.IsExpired() {
+		return models.DataExport{}, ErrInvalidDataExport
+	}
+
+	if err := Audit(ctx, db.Conn(), AuditEntry{
+		ActorID:   userID,
+		SubjectID: userID,
+		Action:    AuditUserDataExported,
+		Details: map[string]any{
+			"export_id": export.ID,
+		},
+	}); err != nil {
+		return models.DataExport{}, err
+	}
+
+	return export, nil
+}
+
+// dataExportSection is one JSON file in the archive. Tables that hold
+// personal data get an entry here, so the export keeps up as the schema
+// grows. Secrets and hashes are never included.
+type dataExportSection struct {
+	name    string
+	collect func(ctx context.Context, exec storage.Executor, user models.User) (any, error)
+}
+
+var dataExportSections = []dataExportSection{
+	{
+		name: "account.json",
+		collect: func(ctx context.Context, exec storage.Executor, user models.User) (any, error) {
+			return map[string]any{
+				"id":                    user.ID,
+				"email":                 user.Email,
+				"created_at":            user.CreatedAt,
+				"email_verified_at":     optionalTime(user.EmailValidatedAt),
+				"is_admin":              user.IsAdmin,
+				"deletion_scheduled_at": optionalTime(user.DeletionScheduledAt),
+			}, nil
+		},
+	},
+	{
+		name: "roles.json",
+		collect: func(ctx context.Context, exec storage.Executor, user models.User) (any, error) {
+			roles, err := models.FindRolesByUserID(ctx, exec, user.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			names := make([]string, len(roles))
+			for i, role := range roles {
+				names[i] = role.Name
+			}
+
+			return names, nil
+		},
+	},
+	{
+		name: "sessions.json",
+		collect: func(ctx context.Context, exec storage.Executor, user models.User) (any, error) {
+			sessions, err := models.FindSessionsByUserID(ctx, exec, user.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			entries := make([]map[string]any, len(sessions))
+			for i, session := range sessions {
+				entries[i] = map[string]any{
+					"id":           session.ID,
+					"created_at":   session.CreatedAt,
+					"expires_at":   session.ExpiresAt,
+					"revoked_at":   optionalTime(session.RevokedAt),
+					"last_seen_at": session.LastSeenAt,
+					"ip":           session.IP,
+					"user_agent":   session.UserAgent,
+				}
+			}
+
+			return entries, nil
+		},
+	},
+	{
+		name: "two_factor.json",
+		collect: func(ctx context.Context, exec storage.Executor, user models.User) (any, error) {
+			credential, err := models.FindTOTPCredentialByUserID(ctx, exec, user.ID)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return map[string]any{"enabled": false}, nil
+				}
+				return nil, err
+			}
+
+			return map[string]any{
+				"enabled":    credential.IsConfirmed(),
+				"created_at": credential.CreatedAt,
+			}, nil
+		},
+	},
+	{
+		name: "passkeys.json",
+		collect: func(ctx context.Context, exec storage.Executor, user models.User) (any, error) {
+			passkeys, err := models.FindWebAuthnCredentialsByUserID(ctx, exec, user.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			entries := make([]map[string]any, len(passkeys))
+			for i, passkey := range passkeys {
+				entries[i] = map[string]any{
+					"id":           passkey.ID,
+					"name":         passkey.Name,
+					"created_at":   passkey.CreatedAt,
+					"last_used_at": optionalTime(passkey.LastUsedAt),
+				}
+			}
+
+			return entries, nil
+		},
+	},
+	{
+		name: "identities.json",
+		collect: func(ctx context.Context, exec storage.Executor, user models.User) (any, error) {
+			identities, err := models.FindIdentitiesByUserID(ctx, exec, user.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			entries := make([]map[string]any, len(identities))
+			for i, identity := range identities {
+				entries[i] = map[string]any{
+					"provider":   identity.Provider,
+					"email":      identity.Email,
+					"created_at": identity.CreatedAt,
+				}
+			}
+
+			return entries, nil
+		},
+	},
+	{
+		name: "api_tokens.json",
+		collect: func(ctx context.Context, exec storage.Executor, user models.User) (any, error) {
+			tokens, err := models.FindAPITokensByUserID(ctx, exec, user.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			entries := make([]map[string]any, len(tokens))
+			for i, token := range tokens {
+				entries[i] = map[string]any{
+					"id":           token.ID,
+					"name":         token.Name,
+					"prefix":       token.Prefix,
+					"scopes":       token.Scopes,
+					"created_at":   token.CreatedAt,
+					"last_used_at": optionalTime(token.LastUsedAt),
+					"expires_at":   optionalTime(token.ExpiresAt),
+				}
+			}
+
+			return entries, nil
+		},
+	},
+	{
+		name: "tokens.json",
+		collect: func(ctx context.Context, exec storage.Executor, user models.User) (any, error) {
+			tokens, err := models.FindTokensByUserID(ctx, exec, user.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			entries := make([]map[string]any, len(tokens))
+			for i, token := range tokens {
+				entries[i] = map[string]any{
+					"id":         token.ID,
+					"scope":      token.Scope,
+					"created_at": token.CreatedAt,
+					"expires_at": token.ExpiresAt,
+					"attempts":   token.Attempts,
+					"meta_data":  json.RawMessage(token.MetaData),
+				}
+			}
+
+			return entries, nil
+		},
+	},
+	{
+		name: "organizations.json",
+		collect: func(ctx context.Context, exec storage.Executor, user models.User) (any, error) {
+			memberships, err := models.FindOrganizationMembershipsByUserID(ctx, exec, user.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			entries := make([]map[string]any, len(memberships))
+			for i, membership := range memberships {
+				entries[i] = map[string]any{
+					"organization_id": membership.OrganizationID,
+					"name":            membership.OrganizationName,
+					"role":            membership.Role,
+					"joined_at":       membership.CreatedAt,
+				}
+			}
+
+			return entries, nil
+		},
+	},
+	{
+		// The bodies are left out: they can carry links that still work.
+		name: "email_messages.json",
+		collect: func(ctx context.Context, exec storage.Executor, user models.User) (any, error) {
+			messages, err := models.FindEmailMessagesByRecipient(ctx, exec, user.Email)
+			if err != nil {
+				return nil, err
+			}
+
+			entries := make([]map[string]any, len(messages))
+			for i, message := range messages {
+				entries[i] = map[string]any{
+					"id":         message.ID,
+					"created_at": message.CreatedAt,
+					"template":   message.Template,
+					"sender":     message.Sender,
+					"recipients": message.Recipients,
+					"subject":    message.Subject,
+					"status":     message.Status,
+					"sent_at":    optionalTime(message.SentAt),
+				}
+			}
+
+			return entries, nil
+		},
+	},
+	{
+		name: "audit_events.json",
+		collect: func(ctx context.Context, exec storage.Executor, user models.User) (any, error) {
+			events, err := models.FindAuditEventsByUserID(ctx, exec, user.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			entries := make([]map[string]any, len(events))
+			for i, event := range events {
+				entries[i] = map[string]any{
+					"created_at": event.CreatedAt,
+					"action":     event.Action,
+					"actor_id":   auditID(event.ActorID),
+					"subject_id": auditID(event.SubjectID),
+					"ip":         event.IP,
+					"user_agent": event.UserAgent,
+					"details":    event.Details,
+				}
+			}
+
+			return entries, nil
+		},
+	},
+}
+
+func buildDataExportArchive(
+	ctx context.Context,
+	exec storage.Executor,
+	user models.User,
+) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	for _, section := range dataExportSections {
+		data, err := section.collect(ctx, exec, user)
+		if err != nil {
+			return nil, err
+		}
+
+		w, err := archive.Create(section.name)
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// optionalTime turns a zero time into null rather than year one.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
@@ -0,0 +1,427 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"mbvlabs/config"
+	"mbvlabs/email"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/queue"
+	"mbvlabs/router/routes"
+)
+
+const (
+	userEmailChange       = "user_email_change"
+	userEmailChangeRevert = "user_email_change_revert"
+
+	EmailChangeDuration       = 24 * time.Hour
+	EmailChangeRevertDuration = 7 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidEmailChange = errors.New("invalid or expired email change link")
+	ErrEmailUnchanged     = errors.New("new email is the same as the current one")
+	ErrInvalidEmail       = errors.New("invalid email address")
+)
+
+type RequestEmailChangeData struct {
+	UserID   uuid.UUID
+	NewEmail string
+}
+
+// RequestEmailChange starts moving a user to a new address. Nothing changes
+// until the link sent to the new address is used. The old address is told
+// about the request and gets a link that cancels or reverts it. An address
+// that already has an account is treated the same from the outside, so the
+// form cannot tell who has signed up; its owner is told about the attempt
+// instead of getting a confirmation link.
+func RequestEmailChange(
+	ctx context.Context,
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	pepper string,
+	data RequestEmailChangeData,
+) error {
+	newEmail := strings.ToLower(strings.TrimSpace(data.NewEmail))
+	if addr, err := mail.ParseAddress(newEmail); err != nil || addr.Address != newEmail {
+		return ErrInvalidEmail
+	}
+
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	user, err := models.FindUser(ctx, tx, data.UserID)
+	if err != nil {
+		return err
+	}
+
+	if newEmail == user.Email {
+		return ErrEmailUnchanged
+	}
+
+	_, err = models.FindUserByEmail(ctx, tx, newEmail)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	taken := err == nil
+
+	if err := models.DestroyTokensByScopeAndUserID(ctx, tx, userEmailChange, user.ID); err != nil {
+		return err
+	}
+
+	changeMeta, err := json.Marshal(map[string]string{
+		"old_email": user.Email,
+		"new_email": newEmail,
+	})
+	if err != nil {
+		return err
+	}
+
+	changeToken, err := models.CreateUserToken(
+		ctx,
+		tx,
+		pepper,
+		userEmailChange,
+		user.ID,
+		time.Now().Add(EmailChangeDuration),
+		changeMeta,
+	)
+	if err != nil {
+		return err
+	}
+
+	revertMeta, err := json.Marshal(map[string]string{
+		"old_email": user.Email,
+		"new_email": newEmail,
+	})
+	if err != nil {
+		return err
+	}
+
+	revertToken, err := models.CreateUserToken(
+		ctx,
+		tx,
+		pepper,
+		userEmailChangeRevert,
+		user.ID,
+		time.Now().Add(EmailChangeRevertDuration),
+		revertMeta,
+	)
+	if err != nil {
+		return err
+	}
+
+	confirmURL, err := url.JoinPath(config.BaseURL, routes.EmailChangeConfirm.URL(changeToken))
+	if err != nil {
+		return err
+	}
+
+	revertURL, err := url.JoinPath(config.BaseURL, routes.EmailChangeRevert.URL(revertToken))
+	if err != nil {
+		return err
+	}
+
+	if taken {
+		if err := notifyEmailAddressInUse(ctx, tx, insertOnly, newEmail); err != nil {
+			return err
+		}
+	} else if err := enqueueTransactionalEmail(
+		ctx,
+		tx,
+		insertOnly,
+		newEmail,
+		"Confirm Your New Email Address",
+		email.ConfirmEmailChange{ConfirmURL: confirmURL},
+		changeToken,
+	); err != nil {
+		return err
+	}
+
+	if err := enqueueTransactionalEmail(
+		ctx,
+		tx,
+		insertOnly,
+		user.Email,
+		"Your Email Address Is Being Changed",
+		email.EmailChangeRequested{
+			NewEmail:  newEmail,
+			RevertURL: revertURL,
+		},
+		revertToken,
+	); err != nil {
+		return err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   user.ID,
+		SubjectID: user.ID,
+		Action:    AuditUserEmailChangeRequested,
+		Details: map[string]any{
+			"old_email": user.Email,
+			"new_email": newEmail,
+		},
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func notifyEmailAddressInUse(
+	ctx context.Context,
+	tx pgx.Tx,
+	insertOnly queue.InsertOnly,
+	address string,
+) error {
+	resetURL, err := url.JoinPath(config.BaseURL, routes.PasswordNew.URL())
+	if err != nil {
+		return err
+	}
+
+	return enqueueTransactionalEmail(
+		ctx,
+		tx,
+		insertOnly,
+		address,
+		"This Address Already Has an Account",
+		email.EmailAddressInUse{ResetPasswordURL: resetURL},
+	)
+}
+
+// PendingEmailChange returns the address a user has asked to move to, or an
+// empty string if there is no unconfirmed request.
+func PendingEmailChange(
+	ctx context.Context,
+	db storage.Pool,
+	userID uuid.UUID,
+) (string, error) {
+	token, err := models.FindLatestTokenByScopeAndUserID(ctx, db.Conn(), userEmailChange, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	if time.Now().After(token.ExpiresAt) {
+		return "", nil
+	}
+
+	var meta map[string]string
+	if err := json.Unmarshal(token.MetaData, &meta); err != nil {
+		return "", err
+	}
+
+	return meta["new_email"], nil
+}
+
+// ConfirmEmailChange moves the user to the new address once they have shown
+// they control it, and marks it as verified.
+func ConfirmEmailChange(
+	ctx context.Context,
+	db storage.Pool,
+	pepper string,
+	token string,
+) (models.User, error) {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	user, meta, err := consumeEmailChangeToken(ctx, tx, pepper, userEmailChange, token)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if user.Email != meta["old_email"] {
+		return models.User{}, ErrInvalidEmailChange
+	}
+
+	newEmail := meta["new_email"]
+	if _, err := models.FindUserByEmail(ctx, tx, newEmail); err == nil {
+		return models.User{}, models.ErrEmailTaken
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, err
+	}
+
+	user, err = models.UpdateUser(ctx, tx, models.UpdateUserData{
+		ID:    user.ID,
+		Email: newEmail,
+		EmailValidatedAt: sql.NullTime{
+			Time:  time.Now(),
+			Valid: true,
+		},
+		Password: user.Password,
+		IsAdmin:  user.IsAdmin,
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if err := models.DestroyTokensByScopeAndUserID(ctx, tx, userEmailChange, user.ID); err != nil {
+		return models.User{}, err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   user.ID,
+		SubjectID: user.ID,
+		Action:    AuditUserEmailChanged,
+		Details: map[string]any{
+			"old_email": meta["old_email"],
+			"new_email": newEmail,
+		},
+	}); err != nil {
+		return models.User{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// RevertEmailChange is used from the link sent to the old address. It
+// cancels a pending change, or moves the account back if the change already
+// went through. The change may not have been the owner's, and whoever made
+// it could have reset the password through the new address, so every way
+// into the account is closed: sessions, remembered devices and API tokens
+// are revoked and the password is replaced by one no one knows, with a reset
+// link sent to the restored address.
+func RevertEmailChange(
+	ctx context.Context,
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	pepper string,
+	token string,
+) (models.User, error) {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	user, meta, err := consumeEmailChangeToken(ctx, tx, pepper, userEmailChangeRevert, token)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if err := models.DestroyTokensByScopeAndUserID(ctx, tx, userEmailChange, user.ID); err != nil {
+		return models.User{}, err
+	}
+
+	oldEmail := meta["old_email"]
+	if user.Email != oldEmail {
+		if _, err := models.FindUserByEmail(ctx, tx, oldEmail); err == nil {
+			return models.User{}, models.ErrEmailTaken
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, err
+		}
+
+		user, err = models.UpdateUser(ctx, tx, models.UpdateUserData{
+			ID:    user.ID,
+			Email: oldEmail,
+			EmailValidatedAt: sql.NullTime{
+				Time:  time.Now(),
+				Valid: true,
+			},
+			Password: user.Password,
+			IsAdmin:  user.IsAdmin,
+		})
+		if err != nil {
+			return models.User{}, err
+		}
+	}
+
+	if err := models.RevokeUserSessions(ctx, tx, user.ID); err != nil {
+		return models.User{}, err
+	}
+
+	if err := models.DestroyRememberTokensByUserID(ctx, tx, user.ID); err != nil {
+		return models.User{}, err
+	}
+
+	if err := models.DestroyAPITokensByUserID(ctx, tx, user.ID); err != nil {
+		return models.User{}, err
+	}
+
+	user, err = scramblePassword(ctx, tx, pepper, user)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if err := sendPasswordReset(ctx, tx, insertOnly, pepper, user); err != nil {
+		return models.User{}, err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   user.ID,
+		SubjectID: user.ID,
+		Action:    AuditUserEmailChangeReverted,
+		Details: map[string]any{
+			"old_email": oldEmail,
+			"new_email": meta["new_email"],
+		},
+	}); err != nil {
+		return models.User{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// consumeEmailChangeToken checks an email change or revert token, destroys it
+// and returns its owner and metadata.
+func consumeEmailChangeToken(
+	ctx context.Context,
+	exec storage.Executor,
+	pepper string,
+	scope string,
+	token string,
+) (models.User, map[string]string, error) {
+	tkn, err := models.FindTokenByScopeAndHash(ctx, exec, pepper, scope, token)
+	if err != nil {
+		return models.User{}, nil, ErrInvalidEmailChange
+	}
+
+	if !tkn.IsValid(token, pepper) {
+		return models.User{}, nil, ErrInvalidEmailChange
+	}
+
+	var meta map[string]string
+	if err := json.Unmarshal(tkn.MetaData, &meta); err != nil {
+		return models.User{}, nil, err
+	}
+
+	user, err := models.FindUser(ctx, exec, tkn.UserID)
+	if err != nil {
+		return models.User{}, nil, err
+	}
+
+	if err := models.DestroyToken(ctx, exec, tkn.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, nil, ErrInvalidEmailChange
+		}
+		return models.User{}, nil, err
+	}
+
+	return user, meta, nil
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/models"
+	"mbvlabs/models/factories"
+)
+
+func TestEmailChange(t *testing.T) {
+	db, insertOnly := testDB(t)
+	ctx := context.Background()
+
+	// request asks to move a new user to a new address and returns the
+	// user with the confirmation and revert links' tokens.
+	request := func(t *testing.T) (user models.User, newEmail, confirm, revert string) {
+		t.Helper()
+
+		user, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+		if err != nil {
+			t.Fatal(err)
+		}
+		newEmail = uuid.NewString() + "@example.com"
+
+		if err := RequestEmailChange(ctx, db, insertOnly, factories.TestPepper, RequestEmailChangeData{
+			UserID:   user.ID,
+			NewEmail: newEmail,
+		}); err != nil {
+			t.Fatalf("RequestEmailChange: %v", err)
+		}
+
+		toNew := enqueuedEmails(t, db, newEmail)
+		toOld := enqueuedEmails(t, db, user.Email)
+		if len(toNew) != 1 || len(toNew[0].Secrets) != 1 || len(toOld) != 1 || len(toOld[0].Secrets) != 1 {
+			t.Fatalf("sent %d emails to the new address and %d to the old, want one link each", len(toNew), len(toOld))
+		}
+
+		return user, newEmail, toNew[0].Secrets[0], toOld[0].Secrets[0]
+	}
+
+	revertAudits := func(t *testing.T, oldEmail string, newEmail string) int {
+		t.Helper()
+
+		var count int
+		if err := db.Conn().QueryRow(
+			ctx,
+			`select count(*) from audit_events
+			where action = $1 and details->>'old_email' = $2 and details->>'new_email' = $3`,
+			AuditUserEmailChangeReverted,
+			oldEmail,
+			newEmail,
+		).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+
+		return count
+	}
+
+	tests := []struct {
+		name string
+		// confirmFirst uses the link sent to the new address before the
+		// revert link.
+		confirmFirst bool
+	}{
+		{name: "revert before confirming cancels the change"},
+		{name: "revert after confirming moves the account back", confirmFirst: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, newEmail, confirm, revert := request(t)
+
+			if tt.confirmFirst {
+				changed, err := ConfirmEmailChange(ctx, db, factories.TestPepper, confirm)
+				if err != nil {
+					t.Fatalf("ConfirmEmailChange: %v", err)
+				}
+				if changed.Email != newEmail {
+					t.Fatalf("email = %q after confirming, want %q", changed.Email, newEmail)
+				}
+			}
+
+			reverted, err := RevertEmailChange(ctx, db, insertOnly, factories.TestPepper, revert)
+			if err != nil {
+				t.Fatalf("RevertEmailChange: %v", err)
+			}
+			if reverted.Email != user.Email {
+				t.Errorf("email = %q after reverting, want %q", reverted.Email, user.Email)
+			}
+
+			if _, err := AuthenticateUser(ctx, db, factories.TestPepper, LoginData{
+				Email:    user.Email,
+				Password: "password123",
+			}); !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("signing in with the old password = %v, want ErrInvalidCredentials", err)
+			}
+
+			if got := revertAudits(t, user.Email, newEmail); got != 1 {
+				t.Errorf("%d revert audits name both addresses, want 1", got)
+			}
+
+			emails := enqueuedEmails(t, db, user.Email)
+			if last := emails[len(emails)-1]; last.Subject != "Reset Your Password" {
+				t.Errorf("last email to the restored address is %q, want a password reset", last.Subject)
+			}
+
+			if _, err := ConfirmEmailChange(ctx, db, factories.TestPepper, confirm); !errors.Is(err, ErrInvalidEmailChange) {
+				t.Errorf("confirming after the revert = %v, want ErrInvalidEmailChange", err)
+			}
+			if _, err := RevertEmailChange(ctx, db, insertOnly, factories.TestPepper, revert); !errors.Is(err, ErrInvalidEmailChange) {
+				t.Errorf("reverting twice = %v, want ErrInvalidEmailChange", err)
+			}
+		})
+	}
+}
+
+func TestEmailChangeToTakenAddress(t *testing.T) {
+	db, insertOnly := testDB(t)
+	ctx := context.Background()
+
+	user, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RequestEmailChange(ctx, db, insertOnly, factories.TestPepper, RequestEmailChangeData{
+		UserID:   user.ID,
+		NewEmail: other.Email,
+	}); err != nil {
+		t.Fatalf("RequestEmailChange = %v, want the same reply as for a free address", err)
+	}
+
+	emails := enqueuedEmails(t, db, other.Email)
+	if len(emails) != 1 || len(emails[0].Secrets) != 0 {
+		t.Fatalf("sent %d emails to the taken address, want one notice without a link", len(emails))
+	}
+	if emails[0].Subject != "This Address Already Has an Account" {
+		t.Errorf("subject = %q", emails[0].Subject)
+	}
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/email"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+)
+
+// RecordEmailEvents suppresses the addresses that bounced or complained and
+// marks the messages reported on accordingly. A message to several
+// recipients keeps its status, as one report says nothing about the others.
+// Events about addresses that are not valid are skipped, so one bad record
+// does not make the provider send the whole batch again.
+func RecordEmailEvents(
+	ctx context.Context,
+	db storage.Pool,
+	events []email.Event,
+) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, event := range events {
+		reason := models.EmailSuppressionBounce
+		status := models.EmailMessageBounced
+		if event.Type == email.EventComplaint {
+			reason = models.EmailSuppressionComplaint
+			status = models.EmailMessageComplained
+		}
+
+		message, err := findReportedEmailMessage(ctx, tx, event)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		if len(message.Recipients) == 1 {
+			details := event.Details
+			if details == "" {
+				details = string(event.Type)
+			}
+
+			if err := models.RecordEmailMessageError(ctx, tx, message.ID, status, details); err != nil {
+				return err
+			}
+		}
+
+		_, err = models.SuppressEmail(ctx, tx, models.SuppressEmailData{
+			Email:          event.Recipient,
+			Reason:         reason,
+			Provider:       event.Provider,
+			Details:        event.Details,
+			EmailMessageID: message.ID,
+		})
+		if errors.Is(err, models.ErrDomainValidation) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// findReportedEmailMessage looks the message up by the id it was logged
+// under, falling back to the id the provider gave it.
+func findReportedEmailMessage(
+	ctx context.Context,
+	exec storage.Executor,
+	event email.Event,
+) (models.EmailMessage, error) {
+	if id, err := uuid.Parse(event.MessageID); err == nil {
+		message, err := models.FindEmailMessage(ctx, exec, id)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return message, err
+		}
+	}
+
+	if event.ProviderMessageID == "" {
+		return models.EmailMessage{}, sql.ErrNoRows
+	}
+
+	return models.FindEmailMessageByProviderMessageID(ctx, exec, event.ProviderMessageID)
+}
+
+// LiftEmailSuppression lets email go to a suppressed address again, for
+// example once its owner has fixed their mailbox. The audit entry is about
+// the user with the address, if there is one.
+func LiftEmailSuppression(
+	ctx context.Context,
+	db storage.Pool,
+	actor models.User,
+	id uuid.UUID,
+) (models.EmailSuppression, error) {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return models.EmailSuppression{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	suppression, err := models.FindEmailSuppression(ctx, tx, id)
+	if err != nil {
+		return models.EmailSuppression{}, err
+	}
+
+	if err := models.DestroyEmailSuppression(ctx, tx, suppression.ID); err != nil {
+		return models.EmailSuppression{}, err
+	}
+
+	user, err := models.FindUserByEmail(ctx, tx, suppression.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.EmailSuppression{}, err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   actor.ID,
+		SubjectID: user.ID,
+		Action:    AuditAdminEmailSuppressionLifted,
+		Details: map[string]any{
+			"email":  suppression.Email,
+			"reason": suppression.Reason,
+		},
+	}); err != nil {
+		return models.EmailSuppression{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.EmailSuppression{}, err
+	}
+
+	return suppression, nil
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+
+	"mbvlabs/email"
+	"mbvlabs/queue"
+	"mbvlabs/queue/jobs"
+)
+
+// enqueueTransactionalEmail renders the message and queues it in tx, so the
+// email is only sent if the surrounding change commits. secrets are the
+// tokens in the message, kept out of the message log.
+func enqueueTransactionalEmail(
+	ctx context.Context,
+	tx pgx.Tx,
+	insertOnly queue.InsertOnly,
+	to string,
+	subject string,
+	message email.Transformer,
+	secrets ...string,
+) error {
+	html, err := message.ToHTML()
+	if err != nil {
+		return err
+	}
+
+	text, err := message.ToText()
+	if err != nil {
+		return err
+	}
+
+	_, err = insertOnly.InsertTx(ctx, tx, jobs.SendTransactionalEmailArgs{
+		Data: email.TransactionalData{
+			Template: email.TemplateName(message),
+			To:       to,
+			From:     "noreply@andurel.com",
+			Subject:  subject,
+			HTMLBody: html,
+			TextBody: text,
+			Secrets:  secrets,
+		},
+	}, nil)
+
+	return err
+}
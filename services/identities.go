@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/queue"
+)
+
+var (
+	ErrIdentityEmailMissing    = errors.New("identity provider did not share an email address")
+	ErrIdentityEmailUnverified = errors.New("identity provider has not verified the email address")
+)
+
+type IdentitySignInData struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// SignInWithIdentity resolves a verified provider login to a user. A known
+// provider+subject signs straight in. Otherwise the identity is linked to the
+// account with the same email, which is only done when the provider vouches
+// for the address, or a new account is registered.
+func SignInWithIdentity(
+	ctx context.Context,
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	pepper string,
+	data IdentitySignInData,
+) (models.User, error) {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	identity, err := models.FindIdentityByProviderAndSubject(ctx, tx, data.Provider, data.Subject)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, err
+	}
+
+	if err == nil {
+		if data.Email != "" && data.Email != identity.Email {
+			if err := models.UpdateIdentityEmail(ctx, tx, identity.ID, data.Email); err != nil {
+				return models.User{}, err
+			}
+		}
+
+		user, err := models.FindUser(ctx, tx, identity.UserID)
+		if err != nil {
+			return models.User{}, err
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			return models.User{}, err
+		}
+
+		if !user.HasValidatedEmail() {
+			return models.User{}, ErrEmailNotVerified
+		}
+
+		return user, nil
+	}
+
+	if data.Email == "" {
+		return models.User{}, ErrIdentityEmailMissing
+	}
+
+	user, err := models.FindUserByEmail(ctx, tx, data.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, err
+	}
+
+	if err == nil {
+		if !data.EmailVerified {
+			return models.User{}, ErrIdentityEmailUnverified
+		}
+
+		if !user.HasValidatedEmail() {
+			user, err = claimUnverifiedUser(ctx, tx, pepper, user)
+			if err != nil {
+				return models.User{}, err
+			}
+		}
+	} else {
+		password, err := models.GenerateSecureToken()
+		if err != nil {
+			return models.User{}, err
+		}
+
+		user, err = registerUser(ctx, tx, insertOnly, pepper, RegisterUserData{
+			Email:           data.Email,
+			Password:        password,
+			ConfirmPassword: password,
+			EmailVerified:   data.EmailVerified,
+		})
+		if err != nil {
+			return models.User{}, err
+		}
+	}
+
+	if _, err := models.CreateIdentity(ctx, tx, models.CreateIdentityData{
+		UserID:   user.ID,
+		Provider: data.Provider,
+		Subject:  data.Subject,
+		Email:    data.Email,
+	}); err != nil {
+		return models.User{}, err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   user.ID,
+		SubjectID: user.ID,
+		Action:    AuditUserIdentityLinked,
+		Details: map[string]any{
+			"provider": data.Provider,
+			"email":    data.Email,
+		},
+	}); err != nil {
+		return models.User{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.User{}, err
+	}
+
+	if !user.HasValidatedEmail() {
+		return models.User{}, ErrEmailNotVerified
+	}
+
+	return user, nil
+}
+
+// claimUnverifiedUser hands an account that never confirmed its email to the
+// person the provider vouches for. The password is replaced and sessions are
+// revoked, so whoever registered the address first cannot keep access.
+func claimUnverifiedUser(
+	ctx context.Context,
+	exec storage.Executor,
+	pepper string,
+	user models.User,
+) (models.User, error) {
+	password, err := models.GenerateSecureToken()
+	if err != nil {
+		return models.User{}, err
+	}
+
+	hashedPassword, err := models.HashPassword(password, pepper)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if err := models.RevokeUserSessions(ctx, exec, user.ID); err != nil {
+		return models.User{}, err
+	}
+
+	return models.UpdateUser(ctx, exec, models.UpdateUserData{
+		ID:    user.ID,
+		Email: user.Email,
+		EmailValidatedAt: sql.NullTime{
+			Time:  time.Now(),
+			Valid: true,
+		},
+		Password: []byte(hashedPassword),
+		IsAdmin:  user.IsAdmin,
+	})
+}
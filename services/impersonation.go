@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+)
+
+// ImpersonationDuration caps how long a support session as another user can
+// last before the admin is put back in their own session.
+const ImpersonationDuration = time.Hour
+
+var (
+	ErrPermissionDenied  = errors.New("permission denied")
+	ErrCannotImpersonate = errors.New("staff accounts cannot be impersonated")
+)
+
+// StartImpersonation opens a short lived session as the subject on behalf of
+// actor. Admins and anyone holding a permission are off limits, so the
+// feature cannot be used to borrow someone else's privileges.
+func StartImpersonation(
+	ctx context.Context,
+	db storage.Pool,
+	actor models.User,
+	subjectID uuid.UUID,
+) (models.Session, error) {
+	if actor.ID == subjectID {
+		return models.Session{}, ErrCannotManageSelf
+	}
+
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return models.Session{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	allowed, err := models.Authorize(ctx, tx, actor, models.PermissionUsersImpersonate)
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	if !allowed {
+		return models.Session{}, ErrPermissionDenied
+	}
+
+	subject, err := models.FindUser(ctx, tx, subjectID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Session{}, ErrUserNotFound
+		}
+		return models.Session{}, err
+	}
+
+	permissions, err := models.FindUserPermissions(ctx, tx, subject)
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	if subject.IsAdmin || len(permissions) > 0 {
+		return models.Session{}, ErrCannotImpersonate
+	}
+
+	session, err := models.CreateSession(ctx, tx, models.CreateSessionData{
+		UserID:    subject.ID,
+		ExpiresAt: time.Now().Add(ImpersonationDuration),
+	})
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   actor.ID,
+		SubjectID: subject.ID,
+		Action:    AuditImpersonationStarted,
+		Details: map[string]any{
+			"email":      subject.Email,
+			"session_id": session.ID,
+		},
+	}); err != nil {
+		return models.Session{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.Session{}, err
+	}
+
+	return session, nil
+}
+
+type StopImpersonationData struct {
+	ImpersonatorID uuid.UUID
+	SubjectID      uuid.UUID
+	SessionID      uuid.UUID
+	Reason         string
+}
+
+// StopImpersonation revokes the impersonation session and records why it
+// ended.
+func StopImpersonation(
+	ctx context.Context,
+	db storage.Pool,
+	data StopImpersonationData,
+) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := models.RevokeSession(ctx, tx, data.SessionID); err != nil {
+		return err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   data.ImpersonatorID,
+		SubjectID: data.SubjectID,
+		Action:    AuditImpersonationStopped,
+		Details: map[string]any{
+			"reason":     data.Reason,
+			"session_id": data.SessionID,
+		},
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ImpersonationAllowed reports whether the admin behind an impersonation is
+// still signed in and still allowed to impersonate.
+func ImpersonationAllowed(
+	ctx context.Context,
+	db storage.Pool,
+	impersonatorID uuid.UUID,
+	impersonatorSessionID uuid.UUID,
+) (bool, error) {
+	session, err := models.FindSession(ctx, db.Conn(), impersonatorSessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if !session.IsActive() || session.UserID != impersonatorID {
+		return false, nil
+	}
+
+	impersonator, err := models.FindUser(ctx, db.Conn(), impersonatorID)
+	if err != nil {
+		return false, err
+	}
+
+	return models.Authorize(ctx, db.Conn(), impersonator, models.PermissionUsersImpersonate)
+}
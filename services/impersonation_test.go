@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/models"
+	"mbvlabs/models/factories"
+)
+
+func TestStartImpersonation(t *testing.T) {
+	db, _ := testDB(t)
+	ctx := context.Background()
+
+	// newUser creates a verified user, optionally an admin or holding role.
+	newUser := func(t *testing.T, isAdmin bool, role string) models.User {
+		t.Helper()
+
+		user, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail(), factories.WithIsAdmin(isAdmin))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if role != "" {
+			found, err := models.FindRoleByName(ctx, db.Conn(), role)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := models.AssignUserRole(ctx, db.Conn(), user.ID, found.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return user
+	}
+
+	tests := []struct {
+		name         string
+		actorIsAdmin bool
+		actorRole    string
+		// subject returns who to impersonate; nil means a plain user.
+		subject func(t *testing.T, actor models.User) uuid.UUID
+		wantErr error
+	}{
+		{name: "admin", actorIsAdmin: true},
+		{name: "support", actorRole: "support"},
+		{name: "auditor", actorRole: "auditor", wantErr: ErrPermissionDenied},
+		{name: "plain user", wantErr: ErrPermissionDenied},
+		{
+			name:         "an admin",
+			actorIsAdmin: true,
+			subject: func(t *testing.T, _ models.User) uuid.UUID {
+				return newUser(t, true, "").ID
+			},
+			wantErr: ErrCannotImpersonate,
+		},
+		{
+			name:      "someone with a role",
+			actorRole: "support",
+			subject: func(t *testing.T, _ models.User) uuid.UUID {
+				return newUser(t, false, "auditor").ID
+			},
+			wantErr: ErrCannotImpersonate,
+		},
+		{
+			name:         "themselves",
+			actorIsAdmin: true,
+			subject: func(_ *testing.T, actor models.User) uuid.UUID {
+				return actor.ID
+			},
+			wantErr: ErrCannotManageSelf,
+		},
+		{
+			name:         "unknown user",
+			actorIsAdmin: true,
+			subject: func(*testing.T, models.User) uuid.UUID {
+				return uuid.New()
+			},
+			wantErr: ErrUserNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actor := newUser(t, tt.actorIsAdmin, tt.actorRole)
+
+			var subjectID uuid.UUID
+			if tt.subject != nil {
+				subjectID = tt.subject(t, actor)
+			} else {
+				subjectID = newUser(t, false, "").ID
+			}
+
+			session, err := StartImpersonation(ctx, db, actor, subjectID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("StartImpersonation = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if session.UserID != subjectID || !session.IsActive() {
+				t.Errorf("got session of %s (active %v), want an active session of %s", session.UserID, session.IsActive(), subjectID)
+			}
+		})
+	}
+}
+
+func TestImpersonationAllowed(t *testing.T) {
+	db, _ := testDB(t)
+	ctx := context.Background()
+
+	support, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+	role, err := models.FindRoleByName(ctx, db.Conn(), "support")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := models.AssignUserRole(ctx, db.Conn(), support.ID, role.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions := signIn(t, db, support.ID, 2)
+
+	check := func(t *testing.T, sessionID uuid.UUID, want bool) {
+		t.Helper()
+		got, err := ImpersonationAllowed(ctx, db, support.ID, sessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("ImpersonationAllowed = %v, want %v", got, want)
+		}
+	}
+
+	check(t, sessions[0].ID, true)
+	check(t, uuid.New(), false)
+
+	if err := RevokeSession(ctx, db, support.ID, sessions[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	check(t, sessions[0].ID, false)
+
+	if err := models.RemoveUserRole(ctx, db.Conn(), support.ID, role.ID); err != nil {
+		t.Fatal(err)
+	}
+	check(t, sessions[1].ID, false)
+}
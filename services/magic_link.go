@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/config"
+	"mbvlabs/email"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/queue"
+	"mbvlabs/queue/jobs"
+	"mbvlabs/router/routes"
+)
+
+const (
+	userMagicLink = "user_magic_link"
+
+	MagicLinkDuration = 15 * time.Minute
+)
+
+var ErrInvalidMagicLink = errors.New("invalid or expired sign-in link")
+
+type RequestMagicLinkData struct {
+	Email string
+}
+
+// RequestMagicLink emails a single use sign-in link. Like password resets it
+// says nothing about whether the address belongs to an account.
+func RequestMagicLink(
+	ctx context.Context,
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	pepper string,
+	data RequestMagicLinkData,
+) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	user, err := models.FindUserByEmail(ctx, tx, data.Email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	if user.EmailValidatedAt.IsZero() {
+		return nil
+	}
+
+	meta, err := json.Marshal(map[string]string{
+		"user_id": user.ID.String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	token, err := models.CreateToken(
+		ctx,
+		tx,
+		pepper,
+		userMagicLink,
+		time.Now().Add(MagicLinkDuration),
+		meta,
+	)
+	if err != nil {
+		return err
+	}
+
+	signInURL, err := url.JoinPath(config.BaseURL, routes.SessionMagicLink.URL(token))
+	if err != nil {
+		return err
+	}
+
+	mlEmail := email.MagicLink{SignInURL: signInURL}
+
+	html, err := mlEmail.ToHTML()
+	if err != nil {
+		return err
+	}
+
+	text, err := mlEmail.ToText()
+	if err != nil {
+		return err
+	}
+
+	_, err = insertOnly.InsertTx(ctx, tx, jobs.SendTransactionalEmailArgs{
+		Data: email.TransactionalData{
+			Template: email.TemplateName(mlEmail),
+			To:       user.Email,
+			From:     "noreply@andurel.com",
+			Subject:  "Your Sign-in Link",
+			HTMLBody: html,
+			TextBody: text,
+			Secrets:  []string{token},
+		},
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ConsumeMagicLink exchanges a sign-in link for the user it was sent to. The
+// token is destroyed in the same transaction, so a link works only once: of
+// two requests racing with the same link, only the one whose delete removed
+// the token signs in.
+func ConsumeMagicLink(
+	ctx context.Context,
+	db storage.Pool,
+	pepper string,
+	token string,
+) (models.User, error) {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	tkn, err := models.FindTokenByScopeAndHash(ctx, tx, pepper, userMagicLink, token)
+	if err != nil {
+		return models.User{}, ErrInvalidMagicLink
+	}
+
+	if !tkn.IsValid(token, pepper) {
+		return models.User{}, ErrInvalidMagicLink
+	}
+
+	var meta map[string]string
+	if err := json.Unmarshal(tkn.MetaData, &meta); err != nil {
+		return models.User{}, err
+	}
+
+	userID, err := uuid.Parse(meta["user_id"])
+	if err != nil {
+		return models.User{}, ErrInvalidMagicLink
+	}
+
+	user, err := models.FindUser(ctx, tx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, ErrInvalidMagicLink
+		}
+		return models.User{}, err
+	}
+
+	if err := models.DestroyToken(ctx, tx, tkn.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, ErrInvalidMagicLink
+		}
+		return models.User{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
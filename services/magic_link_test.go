@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/models"
+	"mbvlabs/models/factories"
+)
+
+func TestMagicLink(t *testing.T) {
+	db, insertOnly := testDB(t)
+	ctx := context.Background()
+
+	// requestLink has a link sent to a new verified user and returns the
+	// user with the link's token.
+	requestLink := func(t *testing.T) (models.User, string) {
+		t.Helper()
+
+		user, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := RequestMagicLink(ctx, db, insertOnly, factories.TestPepper, RequestMagicLinkData{
+			Email: user.Email,
+		}); err != nil {
+			t.Fatalf("RequestMagicLink: %v", err)
+		}
+
+		emails := enqueuedEmails(t, db, user.Email)
+		if len(emails) != 1 || len(emails[0].Secrets) != 1 {
+			t.Fatalf("sent %d emails, want one with a sign-in link", len(emails))
+		}
+
+		return user, emails[0].Secrets[0]
+	}
+
+	t.Run("works once", func(t *testing.T) {
+		user, token := requestLink(t)
+
+		signedIn, err := ConsumeMagicLink(ctx, db, factories.TestPepper, token)
+		if err != nil {
+			t.Fatalf("ConsumeMagicLink: %v", err)
+		}
+		if signedIn.ID != user.ID {
+			t.Errorf("signed in as %s, want %s", signedIn.ID, user.ID)
+		}
+
+		if _, err := ConsumeMagicLink(ctx, db, factories.TestPepper, token); !errors.Is(err, ErrInvalidMagicLink) {
+			t.Errorf("using the link again = %v, want ErrInvalidMagicLink", err)
+		}
+	})
+
+	t.Run("works once when used concurrently", func(t *testing.T) {
+		_, token := requestLink(t)
+
+		var signIns atomic.Int32
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Go(func() {
+				_, err := ConsumeMagicLink(ctx, db, factories.TestPepper, token)
+				switch {
+				case err == nil:
+					signIns.Add(1)
+				case !errors.Is(err, ErrInvalidMagicLink):
+					t.Errorf("ConsumeMagicLink: %v", err)
+				}
+			})
+		}
+		wg.Wait()
+
+		if got := signIns.Load(); got != 1 {
+			t.Errorf("link signed in %d times, want once", got)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		user, token := requestLink(t)
+
+		if _, err := db.Conn().Exec(
+			ctx,
+			`update tokens set expires_at = now() - interval '1 minute'
+			where scope = $1 and meta_data->>'user_id' = $2`,
+			userMagicLink,
+			user.ID.String(),
+		); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := ConsumeMagicLink(ctx, db, factories.TestPepper, token); !errors.Is(err, ErrInvalidMagicLink) {
+			t.Errorf("expired link = %v, want ErrInvalidMagicLink", err)
+		}
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		if _, err := ConsumeMagicLink(ctx, db, factories.TestPepper, "not-a-token"); !errors.Is(err, ErrInvalidMagicLink) {
+			t.Errorf("unknown token = %v, want ErrInvalidMagicLink", err)
+		}
+	})
+}
+
+func TestMagicLinkOnlyForVerifiedAccounts(t *testing.T) {
+	db, insertOnly := testDB(t)
+	ctx := context.Background()
+
+	unverified, err := factories.CreateUser(ctx, db.Conn())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, address := range []string{unverified.Email, uuid.NewString() + "@example.com"} {
+		if err := RequestMagicLink(ctx, db, insertOnly, factories.TestPepper, RequestMagicLinkData{
+			Email: address,
+		}); err != nil {
+			t.Errorf("%s: RequestMagicLink = %v, want nil", address, err)
+		}
+
+		if n := len(enqueuedEmails(t, db, address)); n != 0 {
+			t.Errorf("%s: sent %d sign-in links, want none", address, n)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"mbvlabs/database"
+	"mbvlabs/email"
+	"mbvlabs/internal/storage"
+	"mbvlabs/queue"
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	database.CloseSharedTestDB()
+	os.Exit(code)
+}
+
+// testDB returns the shared test database and a queue that inserts jobs
+// into it without working them. Tests on it use fresh users and keys, so
+// they need no cleanup.
+func testDB(t *testing.T) (storage.Pool, queue.InsertOnly) {
+	t.Helper()
+
+	db := database.SharedTestDB(t).DB
+
+	insertOnly, err := queue.NewInsertOnly(db, nil)
+	if err != nil {
+		t.Fatalf("failed to create queue: %v", err)
+	}
+
+	return db, insertOnly
+}
+
+// enqueuedEmails returns the transactional emails queued for an address,
+// oldest first.
+func enqueuedEmails(t *testing.T, db storage.Pool, to string) []email.TransactionalData {
+	t.Helper()
+
+	rows, err := db.Conn().Query(
+		context.Background(),
+		`select args from river_job
+		where kind = 'send_transactional_email' and args->'Data'->>'To' = $1
+		order by id`,
+		to,
+	)
+	if err != nil {
+		t.Fatalf("failed to query queued emails: %v", err)
+	}
+	defer rows.Close()
+
+	var emails []email.TransactionalData
+	for rows.Next() {
+		var args []byte
+		if err := rows.Scan(&args); err != nil {
+			t.Fatal(err)
+		}
+
+		var job struct{ Data email.TransactionalData }
+		if err := json.Unmarshal(args, &job); err != nil {
+			t.Fatal(err)
+		}
+		emails = append(emails, job.Data)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return emails
+}
+
+// auditCount counts the audit events with the action whose details carry
+// the given email address.
+func auditCount(t *testing.T, db storage.Pool, action string, address string) int {
+	t.Helper()
+
+	var count int
+	if err := db.Conn().QueryRow(
+		context.Background(),
+		`select count(*) from audit_events where action = $1 and details->>'email' = $2`,
+		action,
+		address,
+	).Scan(&count); err != nil {
+		t.Fatalf("failed to count audit events: %v", err)
+	}
+
+	return count
+}
@@ -0,0 +1,652 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"mbvlabs/config"
+	"mbvlabs/email"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/queue"
+	"mbvlabs/router/routes"
+)
+
+// OrganizationInvitationDuration is how long an invitation link works.
+const OrganizationInvitationDuration = 7 * 24 * time.Hour
+
+var (
+	ErrOrganizationNotFound           = errors.New("organization not found")
+	ErrOrganizationForbidden          = errors.New("not allowed to manage this organization")
+	ErrOrganizationMemberNotFound     = errors.New("organization member not found")
+	ErrOrganizationInvitationNotFound = errors.New("organization invitation not found")
+	ErrAlreadyOrganizationMember      = errors.New("already a member of the organization")
+	ErrLastOrganizationOwner          = errors.New("organization must keep at least one owner")
+	ErrInvalidOrganizationInvitation  = errors.New("invalid or expired invitation link")
+	// ErrInvitationEmailMismatch means the signed in user is not the one the
+	// invitation was sent to.
+	ErrInvitationEmailMismatch = errors.New("invitation was sent to another email address")
+	// ErrInvitationAccountExists means the invited address already has an
+	// account, whose owner has to sign in to accept.
+	ErrInvitationAccountExists = errors.New("invited email already has an account")
+)
+
+// CreateOrganization creates an organization owned by the user.
+func CreateOrganization(
+	ctx context.Context,
+	db storage.Pool,
+	userID uuid.UUID,
+	name string,
+) (models.Organization, error) {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return models.Organization{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	organization, err := models.CreateOrganization(ctx, tx, models.CreateOrganizationData{
+		Name: strings.TrimSpace(name),
+	})
+	if err != nil {
+		return models.Organization{}, err
+	}
+
+	if err := models.CreateOrganizationMembership(ctx, tx, models.CreateOrganizationMembershipData{
+		OrganizationID: organization.ID,
+		UserID:         userID,
+		Role:           models.OrganizationRoleOwner,
+	}); err != nil {
+		return models.Organization{}, err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   userID,
+		SubjectID: userID,
+		Action:    AuditOrganizationCreated,
+		Details: map[string]any{
+			"organization_id": organization.ID,
+			"name":            organization.Name,
+		},
+	}); err != nil {
+		return models.Organization{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.Organization{}, err
+	}
+
+	return organization, nil
+}
+
+type InviteToOrganizationData struct {
+	OrganizationID uuid.UUID
+	InviterID      uuid.UUID
+	Email          string
+	Role           models.OrganizationRole
+}
+
+// InviteToOrganization emails a link for joining the organization. Inviting
+// the same address again replaces the earlier link.
+func InviteToOrganization(
+	ctx context.Context,
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	pepper string,
+	data InviteToOrganizationData,
+) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	inviter, err := requireOrganizationManager(ctx, tx, data.OrganizationID, data.InviterID)
+	if err != nil {
+		return err
+	}
+
+	address := strings.ToLower(strings.TrimSpace(data.Email))
+
+	existing, err := models.FindUserByEmail(ctx, tx, address)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err == nil {
+		_, err := models.FindOrganizationMembership(ctx, tx, data.OrganizationID, existing.ID)
+		if err == nil {
+			return ErrAlreadyOrganizationMember
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+	}
+
+	inviterUser, err := models.FindUser(ctx, tx, data.InviterID)
+	if err != nil {
+		return err
+	}
+
+	invitation, secret, err := models.CreateOrganizationInvitation(ctx, tx, pepper, models.CreateOrganizationInvitationData{
+		OrganizationID: data.OrganizationID,
+		InvitedByID:    data.InviterID,
+		Email:          address,
+		Role:           data.Role,
+		ExpiresAt:      time.Now().Add(OrganizationInvitationDuration),
+	})
+	if err != nil {
+		return err
+	}
+
+	acceptURL, err := url.JoinPath(config.BaseURL, routes.InvitationShow.URL(secret))
+	if err != nil {
+		return err
+	}
+
+	if err := enqueueTransactionalEmail(
+		ctx,
+		tx,
+		insertOnly,
+		invitation.Email,
+		"You Have Been Invited to "+inviter.OrganizationName,
+		email.OrganizationInvitation{
+			OrganizationName: inviter.OrganizationName,
+			InviterEmail:     inviterUser.Email,
+			AcceptURL:        acceptURL,
+			ExpiresAt:        invitation.ExpiresAt,
+		},
+		secret,
+	); err != nil {
+		return err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   data.InviterID,
+		SubjectID: data.InviterID,
+		Action:    AuditOrganizationInvitationSent,
+		Details: map[string]any{
+			"organization_id": data.OrganizationID,
+			"invitation_id":   invitation.ID,
+			"email":           invitation.Email,
+			"role":            invitation.Role,
+		},
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RevokeOrganizationInvitation voids a pending invitation of the
+// organization.
+func RevokeOrganizationInvitation(
+	ctx context.Context,
+	db storage.Pool,
+	organizationID uuid.UUID,
+	actorID uuid.UUID,
+	invitationID uuid.UUID,
+) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := requireOrganizationManager(ctx, tx, organizationID, actorID); err != nil {
+		return err
+	}
+
+	if err := models.DestroyOrganizationInvitation(ctx, tx, organizationID, invitationID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrOrganizationInvitationNotFound
+		}
+		return err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   actorID,
+		SubjectID: actorID,
+		Action:    AuditOrganizationInvitationRevoked,
+		Details: map[string]any{
+			"organization_id": organizationID,
+			"invitation_id":   invitationID,
+		},
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// OrganizationInvitationDetails is what the invitation page shows.
+// AccountExists tells whether the invited address can sign in, or has to
+// create an account to accept.
+type OrganizationInvitationDetails struct {
+	Invitation       models.OrganizationInvitation
+	OrganizationName string
+	AccountExists    bool
+}
+
+func FindOrganizationInvitation(
+	ctx context.Context,
+	db storage.Pool,
+	pepper string,
+	secret string,
+) (OrganizationInvitationDetails, error) {
+	invitation, err := findOrganizationInvitation(ctx, db.Conn(), pepper, secret)
+	if err != nil {
+		return OrganizationInvitationDetails{}, err
+	}
+
+	organization, err := models.FindOrganization(ctx, db.Conn(), invitation.OrganizationID)
+	if err != nil {
+		return OrganizationInvitationDetails{}, err
+	}
+
+	_, err = models.FindUserByEmail(ctx, db.Conn(), invitation.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return OrganizationInvitationDetails{}, err
+	}
+
+	return OrganizationInvitationDetails{
+		Invitation:       invitation,
+		OrganizationName: organization.Name,
+		AccountExists:    err == nil,
+	}, nil
+}
+
+// AcceptOrganizationInvitation adds the signed in user to the organization.
+// The invitation only works for the address it was sent to.
+func AcceptOrganizationInvitation(
+	ctx context.Context,
+	db storage.Pool,
+	pepper string,
+	secret string,
+	userID uuid.UUID,
+) (models.OrganizationMembership, error) {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return models.OrganizationMembership{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	invitation, err := findOrganizationInvitation(ctx, tx, pepper, secret)
+	if err != nil {
+		return models.OrganizationMembership{}, err
+	}
+
+	user, err := models.FindUser(ctx, tx, userID)
+	if err != nil {
+		return models.OrganizationMembership{}, err
+	}
+
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		return models.OrganizationMembership{}, ErrInvitationEmailMismatch
+	}
+
+	membership, err := joinOrganization(ctx, tx, invitation, user.ID)
+	if err != nil {
+		return models.OrganizationMembership{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.OrganizationMembership{}, err
+	}
+
+	return membership, nil
+}
+
+type AcceptOrganizationInvitationWithSignUpData struct {
+	Password        string
+	ConfirmPassword string
+}
+
+// AcceptOrganizationInvitationWithSignUp creates an account for the invited
+// address and adds it to the organization. The link was emailed to the
+// address, so the account starts out verified.
+func AcceptOrganizationInvitationWithSignUp(
+	ctx context.Context,
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	pepper string,
+	secret string,
+	data AcceptOrganizationInvitationWithSignUpData,
+) (models.User, models.OrganizationMembership, error) {
+	invitation, err := findOrganizationInvitation(ctx, db.Conn(), pepper, secret)
+	if err != nil {
+		return models.User{}, models.OrganizationMembership{}, err
+	}
+
+	if err := validateNewPassword(ctx, data.Password, data.ConfirmPassword, invitation.Email); err != nil {
+		return models.User{}, models.OrganizationMembership{}, err
+	}
+
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return models.User{}, models.OrganizationMembership{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Read again in the transaction, in case the invitation was used or
+	// revoked while the password was checked.
+	invitation, err = findOrganizationInvitation(ctx, tx, pepper, secret)
+	if err != nil {
+		return models.User{}, models.OrganizationMembership{}, err
+	}
+
+	user, err := registerUser(ctx, tx, insertOnly, pepper, RegisterUserData{
+		Email:           invitation.Email,
+		Password:        data.Password,
+		ConfirmPassword: data.ConfirmPassword,
+		EmailVerified:   true,
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrEmailTaken) {
+			return models.User{}, models.OrganizationMembership{}, ErrInvitationAccountExists
+		}
+		return models.User{}, models.OrganizationMembership{}, err
+	}
+
+	membership, err := joinOrganization(ctx, tx, invitation, user.ID)
+	if err != nil {
+		return models.User{}, models.OrganizationMembership{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.User{}, models.OrganizationMembership{}, err
+	}
+
+	return user, membership, nil
+}
+
+// ChangeOrganizationMemberRole is open to owners and admins. Only owners can
+// make or unmake other owners, and the last owner cannot step down.
+func ChangeOrganizationMemberRole(
+	ctx context.Context,
+	db storage.Pool,
+	organizationID uuid.UUID,
+	actorID uuid.UUID,
+	memberID uuid.UUID,
+	role models.OrganizationRole,
+) error {
+	if !role.Valid() {
+		return errors.Join(models.ErrDomainValidation, errors.New("invalid organization role"))
+	}
+
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := models.LockOrganization(ctx, tx, organizationID); err != nil {
+		return err
+	}
+
+	actor, err := requireOrganizationManager(ctx, tx, organizationID, actorID)
+	if err != nil {
+		return err
+	}
+
+	member, err := findOrganizationMember(ctx, tx, organizationID, memberID)
+	if err != nil {
+		return err
+	}
+
+	if member.Role == role {
+		return nil
+	}
+
+	if (member.Role == models.OrganizationRoleOwner || role == models.OrganizationRoleOwner) &&
+		!actor.Role.CanManageOwners() {
+		return ErrOrganizationForbidden
+	}
+
+	if member.Role == models.OrganizationRoleOwner {
+		if err := ensureAnotherOrganizationOwner(ctx, tx, organizationID); err != nil {
+			return err
+		}
+	}
+
+	if err := models.UpdateOrganizationMembershipRole(ctx, tx, organizationID, memberID, role); err != nil {
+		return err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   actorID,
+		SubjectID: memberID,
+		Action:    AuditOrganizationMemberRoleChanged,
+		Details: map[string]any{
+			"organization_id": organizationID,
+			"from":            member.Role,
+			"to":              role,
+		},
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RemoveOrganizationMember takes a member out of the organization. Members
+// may always remove themselves, that is leave, unless they are the last
+// owner.
+func RemoveOrganizationMember(
+	ctx context.Context,
+	db storage.Pool,
+	organizationID uuid.UUID,
+	actorID uuid.UUID,
+	memberID uuid.UUID,
+) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := models.LockOrganization(ctx, tx, organizationID); err != nil {
+		return err
+	}
+
+	leaving := actorID == memberID
+
+	var actor models.OrganizationMembership
+	if !leaving {
+		actor, err = requireOrganizationManager(ctx, tx, organizationID, actorID)
+		if err != nil {
+			return err
+		}
+	}
+
+	member, err := findOrganizationMember(ctx, tx, organizationID, memberID)
+	if err != nil {
+		return err
+	}
+
+	if !leaving && member.Role == models.OrganizationRoleOwner && !actor.Role.CanManageOwners() {
+		return ErrOrganizationForbidden
+	}
+
+	if member.Role == models.OrganizationRoleOwner {
+		if err := ensureAnotherOrganizationOwner(ctx, tx, organizationID); err != nil {
+			return err
+		}
+	}
+
+	if err := models.DestroyOrganizationMembership(ctx, tx, organizationID, memberID); err != nil {
+		return err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   actorID,
+		SubjectID: memberID,
+		Action:    AuditOrganizationMemberRemoved,
+		Details: map[string]any{
+			"organization_id": organizationID,
+			"role":            member.Role,
+		},
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func findOrganizationInvitation(
+	ctx context.Context,
+	exec storage.Executor,
+	pepper string,
+	secret string,
+) (models.OrganizationInvitation, error) {
+	invitation, err := models.FindOrganizationInvitationBySecret(ctx, exec, pepper, secret)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.OrganizationInvitation{}, ErrInvalidOrganizationInvitation
+		}
+		return models.OrganizationInvitation{}, err
+	}
+
+	if invitation.IsExpired() {
+		return models.OrganizationInvitation{}, ErrInvalidOrganizationInvitation
+	}
+
+	return invitation, nil
+}
+
+// joinOrganization turns the invitation into a membership and uses it up.
+func joinOrganization(
+	ctx context.Context,
+	tx pgx.Tx,
+	invitation models.OrganizationInvitation,
+	userID uuid.UUID,
+) (models.OrganizationMembership, error) {
+	if err := models.CreateOrganizationMembership(ctx, tx, models.CreateOrganizationMembershipData{
+		OrganizationID: invitation.OrganizationID,
+		UserID:         userID,
+		Role:           invitation.Role,
+	}); err != nil {
+		return models.OrganizationMembership{}, err
+	}
+
+	if err := models.DestroyOrganizationInvitation(ctx, tx, invitation.OrganizationID, invitation.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.OrganizationMembership{}, ErrInvalidOrganizationInvitation
+		}
+		return models.OrganizationMembership{}, err
+	}
+
+	membership, err := models.FindOrganizationMembership(ctx, tx, invitation.OrganizationID, userID)
+	if err != nil {
+		return models.OrganizationMembership{}, err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   userID,
+		SubjectID: userID,
+		Action:    AuditOrganizationMemberJoined,
+		Details: map[string]any{
+			"organization_id": invitation.OrganizationID,
+			"invitation_id":   invitation.ID,
+			"role":            membership.Role,
+		},
+	}); err != nil {
+		return models.OrganizationMembership{}, err
+	}
+
+	return membership, nil
+}
+
+// requireOrganizationManager returns the actor's membership if it allows
+// managing members. Users outside the organization get
+// ErrOrganizationNotFound, so they learn nothing about it.
+func requireOrganizationManager(
+	ctx context.Context,
+	exec storage.Executor,
+	organizationID uuid.UUID,
+	actorID uuid.UUID,
+) (models.OrganizationMembership, error) {
+	membership, err := models.FindOrganizationMembership(ctx, exec, organizationID, actorID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.OrganizationMembership{}, ErrOrganizationNotFound
+		}
+		return models.OrganizationMembership{}, err
+	}
+
+	if !membership.Role.CanManageMembers() {
+		return models.OrganizationMembership{}, ErrOrganizationForbidden
+	}
+
+	return membership, nil
+}
+
+func findOrganizationMember(
+	ctx context.Context,
+	exec storage.Executor,
+	organizationID uuid.UUID,
+	userID uuid.UUID,
+) (models.OrganizationMembership, error) {
+	membership, err := models.FindOrganizationMembership(ctx, exec, organizationID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.OrganizationMembership{}, ErrOrganizationMemberNotFound
+		}
+		return models.OrganizationMembership{}, err
+	}
+
+	return membership, nil
+}
+
+// ensureAnotherOrganizationOwner must run with the organization locked.
+func ensureAnotherOrganizationOwner(
+	ctx context.Context,
+	exec storage.Executor,
+	organizationID uuid.UUID,
+) error {
+	owners, err := models.CountOrganizationOwners(ctx, exec, organizationID)
+	if err != nil {
+		return err
+	}
+
+	if owners <= 1 {
+		return ErrLastOrganizationOwner
+	}
+
+	return nil
+}
+
+// ensureNoSoleOwnedOrganizations refuses to remove a user who is the last
+// owner of an organization, which would leave nobody able to manage it. It
+// locks each organization the user owns until the transaction ends.
+func ensureNoSoleOwnedOrganizations(
+	ctx context.Context,
+	exec storage.Executor,
+	userID uuid.UUID,
+) error {
+	memberships, err := models.FindOrganizationMembershipsByUserID(ctx, exec, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, membership := range memberships {
+		if membership.Role != models.OrganizationRoleOwner {
+			continue
+		}
+
+		if err := models.LockOrganization(ctx, exec, membership.OrganizationID); err != nil {
+			return err
+		}
+
+		if err := ensureAnotherOrganizationOwner(ctx, exec, membership.OrganizationID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
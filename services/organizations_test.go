@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/models/factories"
+)
+
+// createTestOrganization creates an organization owned by a new verified
+// user.
+func createTestOrganization(t *testing.T, db storage.Pool) (models.Organization, models.User) {
+	t.Helper()
+	ctx := context.Background()
+
+	owner, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	organization, err := CreateOrganization(ctx, db, owner.ID, "Acme "+uuid.NewString())
+	if err != nil {
+		t.Fatalf("CreateOrganization: %v", err)
+	}
+
+	return organization, owner
+}
+
+// addTestOrganizationMember adds a new verified user with the role.
+func addTestOrganizationMember(
+	t *testing.T,
+	db storage.Pool,
+	organizationID uuid.UUID,
+	role models.OrganizationRole,
+) models.User {
+	t.Helper()
+	ctx := context.Background()
+
+	user, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := models.CreateOrganizationMembership(ctx, db.Conn(), models.CreateOrganizationMembershipData{
+		OrganizationID: organizationID,
+		UserID:         user.ID,
+		Role:           role,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+func TestOrganizationKeepsAnOwner(t *testing.T) {
+	db, insertOnly := testDB(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		otherOwner  bool
+		act         func(t *testing.T, organizationID uuid.UUID, owner models.User) error
+		wantErr     error
+		wantRemoved bool
+	}{
+		{
+			name: "last owner cannot step down",
+			act: func(_ *testing.T, organizationID uuid.UUID, owner models.User) error {
+				return ChangeOrganizationMemberRole(ctx, db, organizationID, owner.ID, owner.ID, models.OrganizationRoleAdmin)
+			},
+			wantErr: ErrLastOrganizationOwner,
+		},
+		{
+			name:       "owner steps down next to another owner",
+			otherOwner: true,
+			act: func(_ *testing.T, organizationID uuid.UUID, owner models.User) error {
+				return ChangeOrganizationMemberRole(ctx, db, organizationID, owner.ID, owner.ID, models.OrganizationRoleAdmin)
+			},
+		},
+		{
+			name: "last owner cannot leave",
+			act: func(_ *testing.T, organizationID uuid.UUID, owner models.User) error {
+				return RemoveOrganizationMember(ctx, db, organizationID, owner.ID, owner.ID)
+			},
+			wantErr: ErrLastOrganizationOwner,
+		},
+		{
+			name:       "owner leaves next to another owner",
+			otherOwner: true,
+			act: func(_ *testing.T, organizationID uuid.UUID, owner models.User) error {
+				return RemoveOrganizationMember(ctx, db, organizationID, owner.ID, owner.ID)
+			},
+			wantRemoved: true,
+		},
+		{
+			name: "last owner cannot schedule account deletion",
+			act: func(t *testing.T, _ uuid.UUID, owner models.User) error {
+				_, err := ScheduleAccountDeletion(ctx, db, insertOnly, factories.TestPepper, ScheduleAccountDeletionData{
+					UserID:       owner.ID,
+					Confirmation: owner.Email,
+				})
+				return err
+			},
+			wantErr: ErrLastOrganizationOwner,
+		},
+		{
+			name: "last owner cannot be deleted by an admin",
+			act: func(t *testing.T, _ uuid.UUID, owner models.User) error {
+				admin, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail(), factories.WithIsAdmin(true))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return AdminDestroyUser(ctx, db, admin, owner.ID)
+			},
+			wantErr: ErrLastOrganizationOwner,
+		},
+		{
+			name:       "owner is deleted by an admin next to another owner",
+			otherOwner: true,
+			act: func(t *testing.T, _ uuid.UUID, owner models.User) error {
+				admin, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail(), factories.WithIsAdmin(true))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return AdminDestroyUser(ctx, db, admin, owner.ID)
+			},
+			wantRemoved: true,
+		},
+		{
+			name: "last owner is not purged after the grace period",
+			act: func(t *testing.T, _ uuid.UUID, owner models.User) error {
+				if _, err := models.ScheduleUserDeletion(ctx, db.Conn(), owner.ID, time.Now().Add(-time.Minute)); err != nil {
+					t.Fatal(err)
+				}
+				// Keep the account out of later purges.
+				defer func() {
+					if err := CancelAccountDeletion(ctx, db, owner.ID); err != nil {
+						t.Error(err)
+					}
+				}()
+				return PurgeDeletedUsers(ctx, db)
+			},
+			wantErr: ErrLastOrganizationOwner,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			organization, owner := createTestOrganization(t, db)
+			if tt.otherOwner {
+				addTestOrganizationMember(t, db, organization.ID, models.OrganizationRoleOwner)
+			}
+
+			if err := tt.act(t, organization.ID, owner); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+
+			owners, err := models.CountOrganizationOwners(ctx, db.Conn(), organization.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if owners < 1 {
+				t.Errorf("organization has %d owners, want at least one", owners)
+			}
+
+			_, err = models.FindOrganizationMembership(ctx, db.Conn(), organization.ID, owner.ID)
+			if removed := errors.Is(err, sql.ErrNoRows); removed != tt.wantRemoved {
+				t.Errorf("owner removed = %v (%v), want %v", removed, err, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestUnverifiedCleanupKeepsOrganizationOwners(t *testing.T) {
+	db, _ := testDB(t)
+	ctx := context.Background()
+
+	owner, err := factories.CreateUser(ctx, db.Conn())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CreateOrganization(ctx, db, owner.ID, "Acme "+uuid.NewString()); err != nil {
+		t.Fatal(err)
+	}
+
+	stale, err := factories.CreateUser(ctx, db.Conn())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	if _, err := PurgeUnverifiedUsers(ctx, db, time.Millisecond); err != nil {
+		t.Fatalf("PurgeUnverifiedUsers: %v", err)
+	}
+
+	if _, err := models.FindUser(ctx, db.Conn(), owner.ID); err != nil {
+		t.Errorf("sole owner was purged: %v", err)
+	}
+	if _, err := models.FindUser(ctx, db.Conn(), stale.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("unverified user was kept: %v", err)
+	}
+}
+
+func TestOrganizationInvitationIsBoundToItsEmail(t *testing.T) {
+	db, insertOnly := testDB(t)
+	ctx := context.Background()
+
+	organization, owner := createTestOrganization(t, db)
+
+	invited, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := InviteToOrganization(ctx, db, insertOnly, factories.TestPepper, InviteToOrganizationData{
+		OrganizationID: organization.ID,
+		InviterID:      owner.ID,
+		Email:          invited.Email,
+		Role:           models.OrganizationRoleMember,
+	}); err != nil {
+		t.Fatalf("InviteToOrganization: %v", err)
+	}
+
+	emails := enqueuedEmails(t, db, invited.Email)
+	if len(emails) != 1 || len(emails[0].Secrets) != 1 {
+		t.Fatalf("got %d invitation emails, want one carrying the link", len(emails))
+	}
+	secret := emails[0].Secrets[0]
+
+	tests := []struct {
+		name    string
+		userID  uuid.UUID
+		wantErr error
+	}{
+		{name: "someone else", userID: other.ID, wantErr: ErrInvitationEmailMismatch},
+		{name: "invited address", userID: invited.ID},
+		{name: "used again", userID: invited.ID, wantErr: ErrInvalidOrganizationInvitation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			membership, err := AcceptOrganizationInvitation(ctx, db, factories.TestPepper, secret, tt.userID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AcceptOrganizationInvitation = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && membership.Role != models.OrganizationRoleMember {
+				t.Errorf("joined as %q, want %q", membership.Role, models.OrganizationRoleMember)
+			}
+		})
+	}
+
+	if _, err := models.FindOrganizationMembership(ctx, db.Conn(), organization.ID, other.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("uninvited user joined the organization: %v", err)
+	}
+}
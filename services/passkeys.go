@@ -0,0 +1,385 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+)
+
+var (
+	ErrInvalidPasskey         = errors.New("passkey could not be verified")
+	ErrInvalidPasskeyCeremony = errors.New("invalid or expired passkey ceremony")
+)
+
+// passkeyUser adapts a user and their registered passkeys to what the
+// relying party expects.
+type passkeyUser struct {
+	user        models.User
+	credentials []models.WebAuthnCredential
+}
+
+func (p passkeyUser) WebAuthnID() []byte {
+	return p.user.ID[:]
+}
+
+func (p passkeyUser) WebAuthnName() string {
+	return p.user.Email
+}
+
+func (p passkeyUser) WebAuthnDisplayName() string {
+	return p.user.Email
+}
+
+func (p passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, len(p.credentials))
+	for i, credential := range p.credentials {
+		credentials[i] = credential.Credential
+	}
+
+	return credentials
+}
+
+func relyingParty() (*webauthn.WebAuthn, error) {
+	rpID := config.Domain
+	if host, _, err := net.SplitHostPort(config.Domain); err == nil {
+		rpID = host
+	}
+
+	return webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: config.ProjectName,
+		RPOrigins:     []string{config.BaseURL},
+	})
+}
+
+func loadPasskeyUser(
+	ctx context.Context,
+	exec storage.Executor,
+	user models.User,
+) (passkeyUser, error) {
+	credentials, err := models.FindWebAuthnCredentialsByUserID(ctx, exec, user.ID)
+	if err != nil {
+		return passkeyUser{}, err
+	}
+
+	return passkeyUser{user: user, credentials: credentials}, nil
+}
+
+// PasskeyCeremony holds the options sent to the browser and the state that
+// must be kept server side until the browser answers.
+type PasskeyCeremony struct {
+	Options []byte
+	Session []byte
+}
+
+// BeginPasskeyRegistration starts a registration ceremony for a signed in
+// user. Passkeys the user already has are excluded so the same authenticator
+// is not registered twice.
+func BeginPasskeyRegistration(
+	ctx context.Context,
+	db storage.Pool,
+	user models.User,
+) (PasskeyCeremony, error) {
+	rp, err := relyingParty()
+	if err != nil {
+		return PasskeyCeremony{}, err
+	}
+
+	pu, err := loadPasskeyUser(ctx, db.Conn(), user)
+	if err != nil {
+		return PasskeyCeremony{}, err
+	}
+
+	return beginPasskeyRegistration(rp, pu)
+}
+
+func beginPasskeyRegistration(rp *webauthn.WebAuthn, pu passkeyUser) (PasskeyCeremony, error) {
+	exclusions := make([]protocol.CredentialDescriptor, len(pu.credentials))
+	for i, credential := range pu.credentials {
+		exclusions[i] = credential.Credential.Descriptor()
+	}
+
+	creation, session, err := rp.BeginRegistration(
+		pu,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementRequired,
+			UserVerification: protocol.VerificationRequired,
+		}),
+	)
+	if err != nil {
+		return PasskeyCeremony{}, err
+	}
+
+	return newPasskeyCeremony(creation, session)
+}
+
+type FinishPasskeyRegistrationData struct {
+	Name     string
+	Session  []byte
+	Response []byte
+}
+
+// FinishPasskeyRegistration verifies the attestation returned by the browser
+// and stores the new passkey.
+func FinishPasskeyRegistration(
+	ctx context.Context,
+	db storage.Pool,
+	user models.User,
+	data FinishPasskeyRegistrationData,
+) (models.WebAuthnCredential, error) {
+	rp, err := relyingParty()
+	if err != nil {
+		return models.WebAuthnCredential{}, err
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal(data.Session, &session); err != nil {
+		return models.WebAuthnCredential{}, ErrInvalidPasskeyCeremony
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(data.Response)
+	if err != nil {
+		return models.WebAuthnCredential{}, errors.Join(ErrInvalidPasskey, err)
+	}
+
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return models.WebAuthnCredential{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	pu, err := loadPasskeyUser(ctx, tx, user)
+	if err != nil {
+		return models.WebAuthnCredential{}, err
+	}
+
+	credential, err := rp.CreateCredential(pu, session, parsed)
+	if err != nil {
+		return models.WebAuthnCredential{}, errors.Join(ErrInvalidPasskey, err)
+	}
+
+	name := strings.TrimSpace(data.Name)
+	if name == "" {
+		name = "Passkey"
+	}
+
+	stored, err := models.CreateWebAuthnCredential(ctx, tx, models.CreateWebAuthnCredentialData{
+		UserID:     user.ID,
+		Name:       name,
+		Credential: *credential,
+	})
+	if err != nil {
+		return models.WebAuthnCredential{}, err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   user.ID,
+		SubjectID: user.ID,
+		Action:    AuditUserPasskeyAdded,
+		Details: map[string]any{
+			"passkey_id": stored.ID,
+			"name":       stored.Name,
+		},
+	}); err != nil {
+		return models.WebAuthnCredential{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.WebAuthnCredential{}, err
+	}
+
+	return stored, nil
+}
+
+// BeginPasskeySignIn starts a discoverable login, so the browser offers every
+// passkey it holds for this site without asking for an email first.
+func BeginPasskeySignIn() (PasskeyCeremony, error) {
+	rp, err := relyingParty()
+	if err != nil {
+		return PasskeyCeremony{}, err
+	}
+
+	assertion, session, err := rp.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		return PasskeyCeremony{}, err
+	}
+
+	return newPasskeyCeremony(assertion, session)
+}
+
+type FinishPasskeySignInData struct {
+	Session  []byte
+	Response []byte
+}
+
+// FinishPasskeySignIn verifies the assertion and returns the user owning the
+// passkey. The stored credential is updated with the new sign count; a
+// counter that goes backwards is treated as a cloned authenticator.
+func FinishPasskeySignIn(
+	ctx context.Context,
+	db storage.Pool,
+	data FinishPasskeySignInData,
+) (models.User, error) {
+	rp, err := relyingParty()
+	if err != nil {
+		return models.User{}, err
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal(data.Session, &session); err != nil {
+		return models.User{}, ErrInvalidPasskeyCeremony
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(data.Response)
+	if err != nil {
+		return models.User{}, errors.Join(ErrInvalidPasskey, err)
+	}
+
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	stored, err := models.FindWebAuthnCredentialByCredentialID(ctx, tx, parsed.RawID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, ErrInvalidPasskey
+		}
+		return models.User{}, err
+	}
+
+	user, err := models.FindUser(ctx, tx, stored.UserID)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	pu, err := loadPasskeyUser(ctx, tx, user)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	credential, err := validatePasskeyAssertion(rp, pu, session, parsed)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if err := models.TouchWebAuthnCredential(ctx, tx, stored.ID, *credential); err != nil {
+		return models.User{}, err
+	}
+
+	if user.EmailValidatedAt.IsZero() {
+		return models.User{}, ErrEmailNotVerified
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// validatePasskeyAssertion checks the assertion was made by one of the
+// user's passkeys for the session's challenge.
+func validatePasskeyAssertion(
+	rp *webauthn.WebAuthn,
+	pu passkeyUser,
+	session webauthn.SessionData,
+	parsed *protocol.ParsedCredentialAssertionData,
+) (*webauthn.Credential, error) {
+	credential, err := rp.ValidateDiscoverableLogin(
+		func(rawID, userHandle []byte) (webauthn.User, error) {
+			if !bytes.Equal(userHandle, pu.WebAuthnID()) {
+				return nil, ErrInvalidPasskey
+			}
+
+			return pu, nil
+		},
+		session,
+		parsed,
+	)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidPasskey, err)
+	}
+
+	if credential.Authenticator.CloneWarning {
+		return nil, ErrInvalidPasskey
+	}
+
+	return credential, nil
+}
+
+func HasPasskey(
+	ctx context.Context,
+	db storage.Pool,
+	userID uuid.UUID,
+) (bool, error) {
+	credentials, err := models.FindWebAuthnCredentialsByUserID(ctx, db.Conn(), userID)
+	if err != nil {
+		return false, err
+	}
+
+	return len(credentials) > 0, nil
+}
+
+func RemovePasskey(
+	ctx context.Context,
+	db storage.Pool,
+	userID uuid.UUID,
+	id uuid.UUID,
+) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := models.DestroyWebAuthnCredential(ctx, tx, id, userID); err != nil {
+		return err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   userID,
+		SubjectID: userID,
+		Action:    AuditUserPasskeyRemoved,
+		Details: map[string]any{
+			"passkey_id": id,
+		},
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func newPasskeyCeremony(options any, session *webauthn.SessionData) (PasskeyCeremony, error) {
+	encodedOptions, err := json.Marshal(options)
+	if err != nil {
+		return PasskeyCeremony{}, err
+	}
+
+	encodedSession, err := json.Marshal(session)
+	if err != nil {
+		return PasskeyCeremony{}, err
+	}
+
+	return PasskeyCeremony{
+		Options: encodedOptions,
+		Session: encodedSession,
+	}, nil
+}
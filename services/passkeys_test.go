@@ -0,0 +1,334 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+
+	"mbvlabs/config"
+	"mbvlabs/models"
+)
+
+const (
+	authenticatorUserPresent  = 0x01
+	authenticatorUserVerified = 0x04
+	authenticatorHasAttested  = 0x40
+)
+
+// softwareAuthenticator is a passkey held in memory. It answers ceremonies
+// the way a platform authenticator would, with "none" attestation and an
+// ES256 key.
+type softwareAuthenticator struct {
+	t            *testing.T
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+	origin       string
+	rpID         string
+}
+
+func newSoftwareAuthenticator(t *testing.T, rpID string) *softwareAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatal(err)
+	}
+
+	return &softwareAuthenticator{
+		t:            t,
+		key:          key,
+		credentialID: credentialID,
+		origin:       config.BaseURL,
+		rpID:         rpID,
+	}
+}
+
+func (a *softwareAuthenticator) clientData(kind string, challenge protocol.URLEncodedBase64) []byte {
+	data, err := json.Marshal(map[string]string{
+		"type":      kind,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    a.origin,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return data
+}
+
+func (a *softwareAuthenticator) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flags)
+
+	return binary.BigEndian.AppendUint32(data, a.signCount)
+}
+
+// create answers a registration ceremony.
+func (a *softwareAuthenticator) create(options []byte) []byte {
+	a.t.Helper()
+
+	var creation protocol.CredentialCreation
+	if err := json.Unmarshal(options, &creation); err != nil {
+		a.t.Fatal(err)
+	}
+	userHandle, err := base64.RawURLEncoding.DecodeString(creation.Response.User.ID.(string))
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	a.userHandle = userHandle
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  1,
+		XCoord: a.key.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	authData := a.authData(authenticatorUserPresent | authenticatorUserVerified | authenticatorHasAttested)
+	authData = append(authData, make([]byte, 16)...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return a.response(map[string]string{
+		"clientDataJSON":    encodeBase64URL(a.clientData("webauthn.create", creation.Response.Challenge)),
+		"attestationObject": encodeBase64URL(attestation),
+	})
+}
+
+// get answers a sign in ceremony, bumping the sign counter first.
+func (a *softwareAuthenticator) get(options []byte, flags byte) []byte {
+	a.t.Helper()
+
+	var assertion protocol.CredentialAssertion
+	if err := json.Unmarshal(options, &assertion); err != nil {
+		a.t.Fatal(err)
+	}
+
+	a.signCount++
+	authData := a.authData(flags)
+	clientData := a.clientData("webauthn.get", assertion.Response.Challenge)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return a.response(map[string]string{
+		"clientDataJSON":    encodeBase64URL(clientData),
+		"authenticatorData": encodeBase64URL(authData),
+		"signature":         encodeBase64URL(signature),
+		"userHandle":        encodeBase64URL(a.userHandle),
+	})
+}
+
+func (a *softwareAuthenticator) response(fields map[string]string) []byte {
+	id := encodeBase64URL(a.credentialID)
+	body, err := json.Marshal(map[string]any{
+		"id":       id,
+		"rawId":    id,
+		"type":     "public-key",
+		"response": fields,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return body
+}
+
+func encodeBase64URL(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// registerSoftwarePasskey runs a registration ceremony and returns the user
+// holding the new passkey.
+func registerSoftwarePasskey(t *testing.T, rp *webauthn.WebAuthn, authenticator *softwareAuthenticator) passkeyUser {
+	t.Helper()
+
+	pu := passkeyUser{user: models.User{ID: uuid.New(), Email: "jane@example.com"}}
+
+	ceremony, err := beginPasskeyRegistration(rp, pu)
+	if err != nil {
+		t.Fatalf("beginPasskeyRegistration: %v", err)
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal(ceremony.Session, &session); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(authenticator.create(ceremony.Options))
+	if err != nil {
+		t.Fatalf("parse attestation: %v", err)
+	}
+
+	credential, err := rp.CreateCredential(pu, session, parsed)
+	if err != nil {
+		t.Fatalf("CreateCredential: %v", err)
+	}
+
+	pu.credentials = []models.WebAuthnCredential{{
+		UserID:       pu.user.ID,
+		CredentialID: credential.ID,
+		Credential:   *credential,
+	}}
+
+	return pu
+}
+
+func signInWithSoftwarePasskey(
+	t *testing.T,
+	pu passkeyUser,
+	authenticator *softwareAuthenticator,
+	flags byte,
+) (*webauthn.Credential, error) {
+	t.Helper()
+
+	rp, err := relyingParty()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ceremony, err := BeginPasskeySignIn()
+	if err != nil {
+		t.Fatalf("BeginPasskeySignIn: %v", err)
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal(ceremony.Session, &session); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(authenticator.get(ceremony.Options, flags))
+	if err != nil {
+		t.Fatalf("parse assertion: %v", err)
+	}
+
+	return validatePasskeyAssertion(rp, pu, session, parsed)
+}
+
+func TestPasskeyCeremonies(t *testing.T) {
+	rp, err := relyingParty()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verified := byte(authenticatorUserPresent | authenticatorUserVerified)
+
+	t.Run("registers and signs in", func(t *testing.T) {
+		authenticator := newSoftwareAuthenticator(t, rp.Config.RPID)
+		pu := registerSoftwarePasskey(t, rp, authenticator)
+
+		credential, err := signInWithSoftwarePasskey(t, pu, authenticator, verified)
+		if err != nil {
+			t.Fatalf("sign in: %v", err)
+		}
+		if credential.Authenticator.SignCount != 1 {
+			t.Errorf("sign count = %d, want 1", credential.Authenticator.SignCount)
+		}
+	})
+
+	t.Run("excludes registered passkeys", func(t *testing.T) {
+		authenticator := newSoftwareAuthenticator(t, rp.Config.RPID)
+		pu := registerSoftwarePasskey(t, rp, authenticator)
+
+		ceremony, err := beginPasskeyRegistration(rp, pu)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var creation protocol.CredentialCreation
+		if err := json.Unmarshal(ceremony.Options, &creation); err != nil {
+			t.Fatal(err)
+		}
+		if len(creation.Response.CredentialExcludeList) != 1 {
+			t.Errorf("exclude list has %d entries, want 1", len(creation.Response.CredentialExcludeList))
+		}
+	})
+
+	t.Run("requires user verification", func(t *testing.T) {
+		authenticator := newSoftwareAuthenticator(t, rp.Config.RPID)
+		pu := registerSoftwarePasskey(t, rp, authenticator)
+
+		_, err := signInWithSoftwarePasskey(t, pu, authenticator, authenticatorUserPresent)
+		if !errors.Is(err, ErrInvalidPasskey) {
+			t.Errorf("sign in without verification = %v, want ErrInvalidPasskey", err)
+		}
+	})
+
+	t.Run("rejects another origin", func(t *testing.T) {
+		authenticator := newSoftwareAuthenticator(t, rp.Config.RPID)
+		pu := registerSoftwarePasskey(t, rp, authenticator)
+
+		authenticator.origin = "https://evil.example.com"
+		_, err := signInWithSoftwarePasskey(t, pu, authenticator, verified)
+		if !errors.Is(err, ErrInvalidPasskey) {
+			t.Errorf("sign in from another origin = %v, want ErrInvalidPasskey", err)
+		}
+	})
+
+	t.Run("rejects a passkey presented for another user", func(t *testing.T) {
+		authenticator := newSoftwareAuthenticator(t, rp.Config.RPID)
+		pu := registerSoftwarePasskey(t, rp, authenticator)
+
+		other := uuid.New()
+		authenticator.userHandle = other[:]
+		_, err := signInWithSoftwarePasskey(t, pu, authenticator, verified)
+		if !errors.Is(err, ErrInvalidPasskey) {
+			t.Errorf("sign in with another user handle = %v, want ErrInvalidPasskey", err)
+		}
+	})
+
+	t.Run("rejects a cloned authenticator", func(t *testing.T) {
+		authenticator := newSoftwareAuthenticator(t, rp.Config.RPID)
+		pu := registerSoftwarePasskey(t, rp, authenticator)
+
+		// The server has seen counter 5 from the real key; a copy still at
+		// 1 answers with 2.
+		pu.credentials[0].Credential.Authenticator.SignCount = 5
+		authenticator.signCount = 1
+		_, err := signInWithSoftwarePasskey(t, pu, authenticator, verified)
+		if !errors.Is(err, ErrInvalidPasskey) {
+			t.Errorf("sign in with a stale counter = %v, want ErrInvalidPasskey", err)
+		}
+	})
+}
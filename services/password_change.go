@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+)
+
+var ErrInvalidCurrentPassword = errors.New("current password is incorrect")
+
+type ChangePasswordData struct {
+	UserID uuid.UUID
+	// SessionID is the session making the change, which stays signed in.
+	SessionID       uuid.UUID
+	CurrentPassword string
+	Password        string
+	ConfirmPassword string
+}
+
+// ChangePassword sets a new password for a signed in user who knows their
+// current one. Every other session is revoked.
+func ChangePassword(
+	ctx context.Context,
+	db storage.Pool,
+	pepper string,
+	data ChangePasswordData,
+) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	user, err := models.FindUser(ctx, tx, data.UserID)
+	if err != nil {
+		return err
+	}
+
+	valid, err := user.ValidPassword(data.CurrentPassword, pepper)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return ErrInvalidCurrentPassword
+	}
+
+	if err := validateNewPassword(ctx, data.Password, data.ConfirmPassword, user.Email); err != nil {
+		return err
+	}
+
+	hashedPassword, err := models.HashPassword(data.Password, pepper)
+	if err != nil {
+		return err
+	}
+
+	if _, err := models.UpdateUser(ctx, tx, models.UpdateUserData{
+		ID:    user.ID,
+		Email: user.Email,
+		EmailValidatedAt: sql.NullTime{
+			Time:  user.EmailValidatedAt,
+			Valid: !user.EmailValidatedAt.IsZero(),
+		},
+		Password: []byte(hashedPassword),
+		IsAdmin:  user.IsAdmin,
+	}); err != nil {
+		return err
+	}
+
+	if err := models.RevokeOtherUserSessions(ctx, tx, user.ID, data.SessionID); err != nil {
+		return err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   user.ID,
+		SubjectID: user.ID,
+		Action:    AuditUserPasswordChanged,
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
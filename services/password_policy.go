@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"unicode/utf8"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/passwords"
+)
+
+// maxAssessedPasswordLength bounds the work done for the public strength
+// endpoint: the estimator's cost grows quickly with length. Longer
+// passwords are rejected without being estimated, whatever the policy
+// allows.
+const maxAssessedPasswordLength = 72
+
+var (
+	ErrPasswordMismatch = errors.New("passwords do not match")
+	ErrWeakPassword     = errors.New("password does not meet the password policy")
+)
+
+// PasswordPolicy decides which new passwords are accepted. Registration,
+// reset and change all go through it; passwords already stored are not
+// checked again at sign in.
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	// MinScore is the lowest accepted strength estimate, from 0 to 4.
+	MinScore int
+	Breaches passwords.BreachChecker
+}
+
+var passwordPolicy = PasswordPolicy{
+	MinLength: 8,
+	MaxLength: 72,
+	MinScore:  3,
+	Breaches:  passwords.Bundled(),
+}
+
+// ConfigurePasswordPolicy replaces the default policy. Call it once at
+// startup, before any requests are served.
+func ConfigurePasswordPolicy(policy PasswordPolicy) {
+	passwordPolicy = policy
+}
+
+// PasswordAssessment is a password measured against the policy. Problems
+// lists every reason it would be rejected; Suggestions are tips for a
+// stronger one.
+type PasswordAssessment struct {
+	Score       int
+	Breached    bool
+	Problems    []string
+	Suggestions []string
+}
+
+func (a PasswordAssessment) Acceptable() bool {
+	return len(a.Problems) == 0
+}
+
+// PasswordPolicyError carries the problems found with a rejected password.
+// It matches ErrWeakPassword.
+type PasswordPolicyError struct {
+	Problems []string
+}
+
+func (e PasswordPolicyError) Error() string {
+	return strings.Join(e.Problems, ". ")
+}
+
+func (e PasswordPolicyError) Is(target error) bool {
+	return target == ErrWeakPassword
+}
+
+// AssessPassword measures password against the policy. email is the
+// address of the account the password is for, if known. It and the site
+// name are among the first things an attacker would try.
+func AssessPassword(
+	ctx context.Context,
+	password string,
+	email string,
+) (PasswordAssessment, error) {
+	policy := passwordPolicy
+
+	maxLength := maxAssessedPasswordLength
+	if policy.MaxLength > 0 {
+		maxLength = min(policy.MaxLength, maxAssessedPasswordLength)
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length > maxLength {
+		return PasswordAssessment{
+			Problems: []string{fmt.Sprintf("Use at most %d characters", maxLength)},
+		}, nil
+	}
+
+	strength := passwords.Estimate(password, passwordUserInputs(email)...)
+	assessment := PasswordAssessment{
+		Score:       strength.Score,
+		Suggestions: strength.Suggestions,
+	}
+
+	if length < policy.MinLength {
+		assessment.Problems = append(
+			assessment.Problems,
+			fmt.Sprintf("Use at least %d characters", policy.MinLength),
+		)
+	}
+
+	if password != "" && policy.Breaches != nil {
+		occurrences, err := policy.Breaches.Occurrences(ctx, password)
+		if err != nil {
+			return PasswordAssessment{}, err
+		}
+
+		if occurrences > 0 {
+			assessment.Breached = true
+			assessment.Problems = append(
+				assessment.Problems,
+				"This password has appeared in a data breach and must not be used",
+			)
+		}
+	}
+
+	if strength.Score < policy.MinScore {
+		warning := strength.Warning
+		if warning == "" {
+			warning = "This password is too easy to guess"
+		}
+		assessment.Problems = append(assessment.Problems, warning)
+	}
+
+	return assessment, nil
+}
+
+// validateNewPassword is the check every flow that sets a password runs.
+func validateNewPassword(
+	ctx context.Context,
+	password string,
+	confirmation string,
+	email string,
+) error {
+	if password != confirmation {
+		return ErrPasswordMismatch
+	}
+
+	assessment, err := AssessPassword(ctx, password, email)
+	if err != nil {
+		return err
+	}
+
+	if !assessment.Acceptable() {
+		return PasswordPolicyError{Problems: assessment.Problems}
+	}
+
+	return nil
+}
+
+func passwordUserInputs(email string) []string {
+	siteHost := config.Domain
+	if host, _, err := net.SplitHostPort(config.Domain); err == nil {
+		siteHost = host
+	}
+
+	inputs := []string{config.ProjectName, siteHost}
+	if email != "" {
+		inputs = append(inputs, email)
+	}
+
+	return inputs
+}
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAssessPasswordRejectsLongPasswordsWithoutEstimating(t *testing.T) {
+	maxLength := min(passwordPolicy.MaxLength, maxAssessedPasswordLength)
+
+	got, err := AssessPassword(
+		context.Background(),
+		strings.Repeat("ab1!", maxLength/4+1),
+		"jane.doe@example.com",
+	)
+	if err != nil {
+		t.Fatalf("AssessPassword: %v", err)
+	}
+
+	want := []string{fmt.Sprintf("Use at most %d characters", maxLength)}
+	if !slices.Equal(got.Problems, want) || got.Score != 0 || got.Suggestions != nil {
+		t.Errorf("AssessPassword = %+v, want only %q", got, want)
+	}
+}
@@ -5,23 +5,44 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+
+	"mbvlabs/config"
 	"mbvlabs/email"
 	"mbvlabs/internal/storage"
 	"mbvlabs/models"
 	"mbvlabs/queue"
 	"mbvlabs/queue/jobs"
+	"mbvlabs/router/routes"
 )
 
-const userEmailVerification = "user_email_verification"
+const (
+	userEmailVerification = "user_email_verification"
+
+	// MaxVerificationAttempts is how many wrong guesses void a code.
+	MaxVerificationAttempts = 5
+	// VerificationResendCooldown is how long a user must wait before asking
+	// for another code.
+	VerificationResendCooldown = time.Minute
+)
 
 type RegisterUserData struct {
 	Email           string
 	Password        string
 	ConfirmPassword string
+	// EmailVerified skips the verification email. Only set it when a trusted
+	// identity provider has asserted that the address is verified, or when
+	// the user arrived through a link that was emailed to it.
+	EmailVerified bool
 }
 
+// RegisterUser creates an account and sends the verification code. When the
+// email is already taken it succeeds without creating anything and emails
+// the existing owner instead, so the response does not reveal the account.
 func RegisterUser(
 	ctx context.Context,
 	db storage.Pool,
@@ -29,79 +50,141 @@ func RegisterUser(
 	salt string,
 	data RegisterUserData,
 ) error {
+	if err := validateNewPassword(ctx, data.Password, data.ConfirmPassword, data.Email); err != nil {
+		return err
+	}
+
 	tx, err := db.BeginTx(ctx)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback(ctx)
 
-	user, err := models.CreateUser(ctx, tx, salt, models.CreateUserData{
-		Email: data.Email,
-		PasswordPair: models.PasswordPair{
-			Password:        data.Password,
-			ConfirmPassword: data.ConfirmPassword,
-		},
-	})
+	// The user is created in a savepoint so a taken email can be rolled
+	// back without losing the transaction.
+	savepoint, err := tx.Begin(ctx)
 	if err != nil {
 		return err
 	}
+	defer savepoint.Rollback(ctx)
 
-	meta, err := json.Marshal(map[string]string{
-		"email": user.Email,
-	})
-	if err != nil {
+	_, err = registerUser(ctx, savepoint, insertOnly, salt, data)
+	if err != nil && !errors.Is(err, models.ErrEmailTaken) {
 		return err
 	}
 
-	code, err := models.CreateCodeToken(
-		ctx,
-		tx,
-		salt,
-		userEmailVerification,
-		time.Now().Add(24*time.Hour),
-		meta,
-	)
-	if err != nil {
+	if errors.Is(err, models.ErrEmailTaken) {
+		if err := savepoint.Rollback(ctx); err != nil {
+			return err
+		}
+
+		if err := notifyEmailTaken(ctx, tx, insertOnly, data.Email); err != nil {
+			return err
+		}
+	} else if err := savepoint.Commit(ctx); err != nil {
 		return err
 	}
 
-	vEmail := email.VerifyEmail{VerificationCode: code}
+	return tx.Commit(ctx)
+}
 
-	html, err := vEmail.ToHTML()
+// notifyEmailTaken tells the owner of an existing account that someone tried
+// to register with their address. Registration then looks the same to the
+// caller whether or not the email was taken.
+func notifyEmailTaken(
+	ctx context.Context,
+	tx pgx.Tx,
+	insertOnly queue.InsertOnly,
+	address string,
+) error {
+	signInURL, err := url.JoinPath(config.BaseURL, routes.SessionNew.URL())
 	if err != nil {
 		return err
 	}
 
-	text, err := vEmail.ToText()
+	resetURL, err := url.JoinPath(config.BaseURL, routes.PasswordNew.URL())
 	if err != nil {
 		return err
 	}
 
-	_, err = insertOnly.InsertTx(ctx, tx, jobs.SendTransactionalEmailArgs{
-		Data: email.TransactionalData{
-			To:       user.Email,
-			From:     "noreply@andurel.com",
-			Subject:  "Verify Your Email Address",
-			HTMLBody: html,
-			TextBody: text,
+	return enqueueTransactionalEmail(
+		ctx,
+		tx,
+		insertOnly,
+		strings.ToLower(address),
+		"You Already Have an Account",
+		email.AccountExists{
+			SignInURL:        signInURL,
+			ResetPasswordURL: resetURL,
 		},
-	}, nil)
+	)
+}
+
+func registerUser(
+	ctx context.Context,
+	tx pgx.Tx,
+	insertOnly queue.InsertOnly,
+	salt string,
+	data RegisterUserData,
+) (models.User, error) {
+	user, err := models.CreateUser(ctx, tx, salt, models.CreateUserData{
+		Email: data.Email,
+		PasswordPair: models.PasswordPair{
+			Password:        data.Password,
+			ConfirmPassword: data.ConfirmPassword,
+		},
+	})
 	if err != nil {
-		return err
+		return models.User{}, err
 	}
 
-	return tx.Commit(ctx)
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   user.ID,
+		SubjectID: user.ID,
+		Action:    AuditUserRegistered,
+		Details: map[string]any{
+			"email":          user.Email,
+			"email_verified": data.EmailVerified,
+		},
+	}); err != nil {
+		return models.User{}, err
+	}
+
+	if data.EmailVerified {
+		return models.UpdateUser(ctx, tx, models.UpdateUserData{
+			ID:    user.ID,
+			Email: user.Email,
+			EmailValidatedAt: sql.NullTime{
+				Time:  time.Now(),
+				Valid: true,
+			},
+			Password: user.Password,
+			IsAdmin:  user.IsAdmin,
+		})
+	}
+
+	if err := sendVerificationCode(ctx, tx, insertOnly, salt, user); err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
 }
 
 var (
-	ErrInvalidVerificationCode = errors.New("invalid verification code")
-	ErrExpiredVerificationCode = errors.New("verification code has expired")
-	ErrUserNotFound            = errors.New("user not found")
+	ErrInvalidVerificationCode     = errors.New("invalid verification code")
+	ErrExpiredVerificationCode     = errors.New("verification code has expired")
+	ErrTooManyVerificationAttempts = errors.New("too many incorrect verification codes")
+	ErrUserNotFound                = errors.New("user not found")
 )
 
 type VerifyEmailData struct {
-	Code string
+	Email string
+	Code  string
 }
 
+// VerifyEmail checks the code against the latest one issued to the user with
+// the given email. Each wrong guess is counted and the code is voided after
+// MaxVerificationAttempts misses.
 func VerifyEmail(
 	ctx context.Context,
 	db storage.Pool,
@@ -114,34 +197,55 @@ func VerifyEmail(
 	}
 	defer tx.Rollback(ctx)
 
-	token, err := models.FindTokenByScopeAndHash(
+	user, err := models.FindUserByEmail(ctx, tx, data.Email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidVerificationCode
+		}
+		return err
+	}
+
+	if user.HasValidatedEmail() {
+		return ErrInvalidVerificationCode
+	}
+
+	token, err := models.FindLatestTokenByScopeAndUserID(
 		ctx,
 		tx,
-		salt,
 		userEmailVerification,
-		data.Code,
+		user.ID,
 	)
 	if err != nil {
-		return ErrInvalidVerificationCode
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidVerificationCode
+		}
+		return err
 	}
 
-	if !token.IsValid(data.Code, salt) {
+	if time.Now().After(token.ExpiresAt) {
 		return ErrExpiredVerificationCode
 	}
 
-	var meta map[string]string
-	if err := json.Unmarshal(token.MetaData, &meta); err != nil {
-		return err
-	}
+	if !token.IsValid(strings.ToUpper(strings.TrimSpace(data.Code)), salt) {
+		attempts, err := models.IncrementTokenAttempts(ctx, tx, token.ID)
+		if err != nil {
+			return err
+		}
 
-	email, ok := meta["email"]
-	if !ok {
-		return errors.New("token metadata missing email")
-	}
+		verifyErr := ErrInvalidVerificationCode
+		if attempts >= MaxVerificationAttempts {
+			if err := models.DestroyToken(ctx, tx, token.ID); err != nil &&
+				!errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			verifyErr = ErrTooManyVerificationAttempts
+		}
 
-	user, err := models.FindUserByEmail(ctx, tx, email)
-	if err != nil {
-		return err
+		if err := tx.Commit(ctx); err != nil {
+			return err
+		}
+
+		return verifyErr
 	}
 
 	_, err = models.UpdateUser(ctx, tx, models.UpdateUserData{
@@ -158,9 +262,139 @@ func VerifyEmail(
 		return err
 	}
 
-	if err := models.DestroyToken(ctx, tx, token.ID); err != nil {
+	if err := models.DestroyTokensByScopeAndUserID(
+		ctx,
+		tx,
+		userEmailVerification,
+		user.ID,
+	); err != nil {
+		return err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   user.ID,
+		SubjectID: user.ID,
+		Action:    AuditUserEmailVerified,
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+type ResendVerificationCodeData struct {
+	Email string
+}
+
+// ResendVerificationCode replaces any outstanding codes with a fresh one. It
+// stays silent for unknown or already verified addresses, and for codes
+// asked for again within VerificationResendCooldown, so it cannot be used
+// to find accounts or pending sign-ups.
+func ResendVerificationCode(
+	ctx context.Context,
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	salt string,
+	data ResendVerificationCodeData,
+) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	user, err := models.FindUserByEmail(ctx, tx, data.Email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	if user.HasValidatedEmail() {
+		return nil
+	}
+
+	latest, err := models.FindLatestTokenByScopeAndUserID(
+		ctx,
+		tx,
+		userEmailVerification,
+		user.ID,
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if err == nil && time.Since(latest.CreatedAt) < VerificationResendCooldown {
+		return nil
+	}
+
+	if err := models.DestroyTokensByScopeAndUserID(
+		ctx,
+		tx,
+		userEmailVerification,
+		user.ID,
+	); err != nil {
+		return err
+	}
+
+	if err := sendVerificationCode(ctx, tx, insertOnly, salt, user); err != nil {
 		return err
 	}
 
 	return tx.Commit(ctx)
 }
+
+func sendVerificationCode(
+	ctx context.Context,
+	tx pgx.Tx,
+	insertOnly queue.InsertOnly,
+	salt string,
+	user models.User,
+) error {
+	meta, err := json.Marshal(map[string]string{
+		"email": user.Email,
+	})
+	if err != nil {
+		return err
+	}
+
+	code, err := models.CreateCodeToken(
+		ctx,
+		tx,
+		salt,
+		userEmailVerification,
+		user.ID,
+		time.Now().Add(24*time.Hour),
+		meta,
+	)
+	if err != nil {
+		return err
+	}
+
+	vEmail := email.VerifyEmail{VerificationCode: code}
+
+	html, err := vEmail.ToHTML()
+	if err != nil {
+		return err
+	}
+
+	text, err := vEmail.ToText()
+	if err != nil {
+		return err
+	}
+
+	_, err = insertOnly.InsertTx(ctx, tx, jobs.SendTransactionalEmailArgs{
+		Data: email.TransactionalData{
+			Template: email.TemplateName(vEmail),
+			To:       user.Email,
+			From:     "noreply@andurel.com",
+			Subject:  "Verify Your Email Address",
+			HTMLBody: html,
+			TextBody: text,
+			Secrets:  []string{code},
+		},
+	}, nil)
+
+	return err
+}
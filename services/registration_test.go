@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/models/factories"
+	"mbvlabs/queue"
+)
+
+// requestVerificationCode has a code sent to the user and returns it.
+func requestVerificationCode(
+	t *testing.T,
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	user models.User,
+) string {
+	t.Helper()
+
+	if err := ResendVerificationCode(context.Background(), db, insertOnly, factories.TestPepper, ResendVerificationCodeData{
+		Email: user.Email,
+	}); err != nil {
+		t.Fatalf("ResendVerificationCode: %v", err)
+	}
+
+	emails := enqueuedEmails(t, db, user.Email)
+	if len(emails) == 0 || len(emails[len(emails)-1].Secrets) != 1 {
+		t.Fatalf("no verification code was sent to %s", user.Email)
+	}
+
+	return emails[len(emails)-1].Secrets[0]
+}
+
+func TestResendVerificationCodeRevealsNothing(t *testing.T) {
+	db, insertOnly := testDB(t)
+	ctx := context.Background()
+
+	pending, err := factories.CreateUser(ctx, db.Conn())
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requestVerificationCode(t, db, insertOnly, pending)
+
+	tests := []struct {
+		name       string
+		email      string
+		wantEmails int
+	}{
+		{name: "unknown address", email: uuid.NewString() + "@example.com"},
+		{name: "verified address", email: verified.Email},
+		{name: "pending address within the cooldown", email: pending.Email, wantEmails: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ResendVerificationCode(ctx, db, insertOnly, factories.TestPepper, ResendVerificationCodeData{
+				Email: tt.email,
+			}); err != nil {
+				t.Fatalf("ResendVerificationCode = %v, want nil", err)
+			}
+
+			if got := len(enqueuedEmails(t, db, tt.email)); got != tt.wantEmails {
+				t.Errorf("%d emails sent to %s, want %d", got, tt.email, tt.wantEmails)
+			}
+		})
+	}
+}
+
+func TestVerifyEmailCode(t *testing.T) {
+	db, insertOnly := testDB(t)
+	ctx := context.Background()
+
+	newPending := func(t *testing.T) (models.User, string) {
+		t.Helper()
+		user, err := factories.CreateUser(ctx, db.Conn())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user, requestVerificationCode(t, db, insertOnly, user)
+	}
+
+	verify := func(user models.User, code string) error {
+		return VerifyEmail(ctx, db, factories.TestPepper, VerifyEmailData{Email: user.Email, Code: code})
+	}
+
+	t.Run("correct code verifies", func(t *testing.T) {
+		user, code := newPending(t)
+		if err := verify(user, code); err != nil {
+			t.Fatalf("VerifyEmail = %v", err)
+		}
+
+		user, err := models.FindUser(ctx, db.Conn(), user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !user.HasValidatedEmail() {
+			t.Error("email is not verified")
+		}
+
+		if err := verify(user, code); !errors.Is(err, ErrInvalidVerificationCode) {
+			t.Errorf("using the code again = %v, want ErrInvalidVerificationCode", err)
+		}
+	})
+
+	t.Run("code is bound to its user", func(t *testing.T) {
+		user, _ := newPending(t)
+		_, otherCode := newPending(t)
+
+		if err := verify(user, otherCode); !errors.Is(err, ErrInvalidVerificationCode) {
+			t.Errorf("another user's code = %v, want ErrInvalidVerificationCode", err)
+		}
+	})
+
+	t.Run("wrong guesses void the code", func(t *testing.T) {
+		user, code := newPending(t)
+
+		for i := 1; i <= MaxVerificationAttempts; i++ {
+			want := ErrInvalidVerificationCode
+			if i == MaxVerificationAttempts {
+				want = ErrTooManyVerificationAttempts
+			}
+			if err := verify(user, "WRONG1"); !errors.Is(err, want) {
+				t.Fatalf("guess %d = %v, want %v", i, err, want)
+			}
+		}
+
+		if err := verify(user, code); !errors.Is(err, ErrInvalidVerificationCode) {
+			t.Errorf("correct code after the cap = %v, want ErrInvalidVerificationCode", err)
+		}
+	})
+}
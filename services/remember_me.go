@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+)
+
+const (
+	// RememberMeDuration is how long a remembered device stays signed in
+	// without its owner entering their credentials again.
+	RememberMeDuration = 30 * 24 * time.Hour
+
+	// rememberTokenRotationGrace covers requests that were already in
+	// flight with the old validator when another request rotated it.
+	rememberTokenRotationGrace = time.Minute
+)
+
+var (
+	ErrInvalidRememberToken = errors.New("invalid or expired remember token")
+	// ErrRememberTokenReused means a validator that had already been rotated
+	// out was presented again. Only a copy of the cookie can do that, so
+	// the token is treated as stolen.
+	ErrRememberTokenReused = errors.New("remember token was reused")
+)
+
+// RestoredSession is a session brought back by a remember token.
+// RememberSecret is the token's new secret and must replace the one in the
+// browser. It is empty when the request raced another rotation, in which
+// case the browser already has the new secret.
+type RestoredSession struct {
+	Session        models.Session
+	RememberSecret string
+}
+
+// RestoreRememberedSession signs a remembered device back in. The session
+// the token belongs to is extended and the token rotated. If a rotated out
+// validator is replayed, every session of the user is revoked, signing out
+// both the thief and the owner, who has to sign in again with their
+// credentials.
+func RestoreRememberedSession(
+	ctx context.Context,
+	db storage.Pool,
+	pepper string,
+	secret string,
+) (RestoredSession, error) {
+	id, validator, err := models.ParseRememberTokenSecret(secret)
+	if err != nil {
+		return RestoredSession{}, ErrInvalidRememberToken
+	}
+
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return RestoredSession{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	token, err := models.FindRememberToken(ctx, tx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return RestoredSession{}, ErrInvalidRememberToken
+		}
+		return RestoredSession{}, err
+	}
+
+	session, err := models.FindSession(ctx, tx, token.SessionID)
+	if err != nil {
+		return RestoredSession{}, err
+	}
+
+	if token.IsExpired() || session.IsRevoked() {
+		return RestoredSession{}, ErrInvalidRememberToken
+	}
+
+	raced := false
+	switch {
+	case token.MatchesCurrent(validator, pepper):
+	case token.MatchesPrevious(validator, pepper) &&
+		time.Since(token.RotatedAt) < rememberTokenRotationGrace:
+		raced = true
+	default:
+		if err := revokeForRememberTokenReuse(ctx, tx, session); err != nil {
+			return RestoredSession{}, err
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			return RestoredSession{}, err
+		}
+
+		return RestoredSession{}, ErrRememberTokenReused
+	}
+
+	expiresAt := time.Now().Add(SessionDuration)
+	if token.ExpiresAt.Before(expiresAt) {
+		expiresAt = token.ExpiresAt
+	}
+
+	session, err = models.ExtendSession(ctx, tx, session.ID, expiresAt)
+	if err != nil {
+		return RestoredSession{}, err
+	}
+
+	var rememberSecret string
+	if !raced {
+		_, rememberSecret, err = models.RotateRememberToken(ctx, tx, pepper, token)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Another request rotated the token since it was read.
+			err = nil
+		}
+		if err != nil {
+			return RestoredSession{}, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return RestoredSession{}, err
+	}
+
+	return RestoredSession{Session: session, RememberSecret: rememberSecret}, nil
+}
+
+func revokeForRememberTokenReuse(
+	ctx context.Context,
+	exec storage.Executor,
+	session models.Session,
+) error {
+	if err := models.RevokeUserSessions(ctx, exec, session.UserID); err != nil {
+		return err
+	}
+
+	return Audit(ctx, exec, AuditEntry{
+		SubjectID: session.UserID,
+		Action:    AuditUserRememberTokenReused,
+		Details: map[string]any{
+			"session_id": session.ID,
+			"device":     session.Device().String(),
+		},
+	})
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"mbvlabs/models"
+	"mbvlabs/models/factories"
+)
+
+func TestRestoreRememberedSession(t *testing.T) {
+	db, _ := testDB(t)
+	ctx := context.Background()
+
+	// expireGrace moves the last rotation of the session's token past the
+	// grace period.
+	expireGrace := func(t *testing.T, session models.Session) {
+		t.Helper()
+		if _, err := db.Conn().Exec(
+			ctx,
+			`update remember_tokens set rotated_at = now() - interval '2 minutes' where session_id = $1`,
+			session.ID,
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		// replay is what the browser presents after the token was rotated
+		// once; first is the original secret, current its replacement.
+		replay func(t *testing.T, session models.Session, first, current string) string
+		// wantRotated tells whether a new secret is handed out.
+		wantRotated bool
+		wantErr     error
+		// wantSignedOut tells whether every session of the user is revoked.
+		wantSignedOut bool
+	}{
+		{
+			name:        "current secret",
+			replay:      func(_ *testing.T, _ models.Session, _, current string) string { return current },
+			wantRotated: true,
+		},
+		{
+			name:   "old secret racing the rotation",
+			replay: func(_ *testing.T, _ models.Session, first, _ string) string { return first },
+		},
+		{
+			name: "old secret after the grace period",
+			replay: func(t *testing.T, session models.Session, first, _ string) string {
+				expireGrace(t, session)
+				return first
+			},
+			wantErr:       ErrRememberTokenReused,
+			wantSignedOut: true,
+		},
+		{
+			name: "revoked device",
+			replay: func(t *testing.T, session models.Session, _, current string) string {
+				if err := RevokeDevice(ctx, db, session.UserID, session.ID); err != nil {
+					t.Fatal(err)
+				}
+				return current
+			},
+			wantErr: ErrInvalidRememberToken,
+		},
+		{
+			name:    "malformed secret",
+			replay:  func(*testing.T, models.Session, string, string) string { return "not-a-secret" },
+			wantErr: ErrInvalidRememberToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			session, first, err := CreateSession(ctx, db, factories.TestPepper, user.ID, true)
+			if err != nil {
+				t.Fatalf("CreateSession: %v", err)
+			}
+			other := signIn(t, db, user.ID, 1)[0]
+
+			restored, err := RestoreRememberedSession(ctx, db, factories.TestPepper, first)
+			if err != nil {
+				t.Fatalf("RestoreRememberedSession: %v", err)
+			}
+			if restored.Session.ID != session.ID || restored.RememberSecret == "" || restored.RememberSecret == first {
+				t.Fatalf("restoring did not rotate the token of session %s", session.ID)
+			}
+
+			secret := tt.replay(t, session, first, restored.RememberSecret)
+			restored, err = RestoreRememberedSession(ctx, db, factories.TestPepper, secret)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RestoreRememberedSession = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (restored.Session.ID != session.ID || (restored.RememberSecret != "") != tt.wantRotated) {
+				t.Errorf("restored session %s with new secret %v, want session %s with new secret %v",
+					restored.Session.ID, restored.RememberSecret != "", session.ID, tt.wantRotated)
+			}
+
+			other, err = models.FindSession(ctx, db.Conn(), other.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if other.IsActive() == tt.wantSignedOut {
+				t.Errorf("other session active = %v, want %v", other.IsActive(), !tt.wantSignedOut)
+			}
+		})
+	}
+}
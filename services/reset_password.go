@@ -6,8 +6,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+
 	"mbvlabs/config"
 	"mbvlabs/email"
 	"mbvlabs/internal/storage"
@@ -28,6 +32,8 @@ type RequestResetPasswordData struct {
 	Email string
 }
 
+// RequestResetPassword emails a reset link to the account owner, or a notice
+// that there is no account to addresses that are not registered.
 func RequestResetPassword(
 	ctx context.Context,
 	db storage.Pool,
@@ -43,55 +49,39 @@ func RequestResetPassword(
 
 	user, err := models.FindUserByEmail(ctx, tx, data.Email)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
 		}
-		return err
-	}
-
-	meta, err := json.Marshal(map[string]string{
-		"email": user.Email,
-	})
-	if err != nil {
-		return err
-	}
 
-	token, err := models.CreateToken(
-		ctx,
-		tx,
-		salt,
-		userResetPassword,
-		time.Now().Add(1*time.Hour), // 1 hour expiry
-		meta,
-	)
-	if err != nil {
-		return err
-	}
-
-	resetURL := fmt.Sprintf("%s%s", config.BaseURL, routes.PasswordEdit.URL(token))
+		// Unknown addresses get an email too, so the request costs the
+		// same whether or not an account exists.
+		registerURL, err := url.JoinPath(config.BaseURL, routes.RegistrationNew.URL())
+		if err != nil {
+			return err
+		}
 
-	rpEmail := email.ResetPassword{ResetURL: resetURL}
+		if err := enqueueTransactionalEmail(
+			ctx,
+			tx,
+			insertOnly,
+			strings.ToLower(data.Email),
+			"Password Reset Requested",
+			email.NoAccount{RegisterURL: registerURL},
+		); err != nil {
+			return err
+		}
 
-	html, err := rpEmail.ToHTML()
-	if err != nil {
-		return err
+		return tx.Commit(ctx)
 	}
 
-	text, err := rpEmail.ToText()
-	if err != nil {
+	if err := sendPasswordReset(ctx, tx, insertOnly, salt, user); err != nil {
 		return err
 	}
 
-	_, err = insertOnly.InsertTx(ctx, tx, jobs.SendTransactionalEmailArgs{
-		Data: email.TransactionalData{
-			To:       user.Email,
-			From:     "noreply@andurel.com",
-			Subject:  "Reset Your Password",
-			HTMLBody: html,
-			TextBody: text,
-		},
-	}, nil)
-	if err != nil {
+	if err := Audit(ctx, tx, AuditEntry{
+		SubjectID: user.ID,
+		Action:    AuditUserPasswordResetRequested,
+	}); err != nil {
 		return err
 	}
 
@@ -116,14 +106,6 @@ func ResetPassword(
 	}
 	defer tx.Rollback(ctx)
 
-	if data.Password != data.ConfirmPassword {
-		return errors.New("passwords do not match")
-	}
-
-	if len(data.Password) < 8 {
-		return errors.New("password must be at least 8 characters")
-	}
-
 	token, err := models.FindTokenByScopeAndHash(
 		ctx,
 		tx,
@@ -154,6 +136,10 @@ func ResetPassword(
 		return err
 	}
 
+	if err := validateNewPassword(ctx, data.Password, data.ConfirmPassword, user.Email); err != nil {
+		return err
+	}
+
 	hashedPassword, err := models.HashPassword(data.Password, salt)
 	if err != nil {
 		return err
@@ -173,9 +159,111 @@ func ResetPassword(
 		return err
 	}
 
+	if err := models.RevokeUserSessions(ctx, tx, user.ID); err != nil {
+		return err
+	}
+
 	if err := models.DestroyToken(ctx, tx, token.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidResetCode
+		}
+		return err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   user.ID,
+		SubjectID: user.ID,
+		Action:    AuditUserPasswordReset,
+	}); err != nil {
 		return err
 	}
 
 	return tx.Commit(ctx)
 }
+
+// sendPasswordReset issues a one hour reset token for user and queues the
+// email carrying the link.
+// scramblePassword replaces the user's password with a random one no one
+// is told, so the account can only be entered again through a reset.
+func scramblePassword(
+	ctx context.Context,
+	tx pgx.Tx,
+	pepper string,
+	user models.User,
+) (models.User, error) {
+	password, err := models.GenerateSecureToken()
+	if err != nil {
+		return models.User{}, err
+	}
+
+	hashedPassword, err := models.HashPassword(password, pepper)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return models.UpdateUser(ctx, tx, models.UpdateUserData{
+		ID:    user.ID,
+		Email: user.Email,
+		EmailValidatedAt: sql.NullTime{
+			Time:  user.EmailValidatedAt,
+			Valid: user.HasValidatedEmail(),
+		},
+		Password: []byte(hashedPassword),
+		IsAdmin:  user.IsAdmin,
+	})
+}
+
+func sendPasswordReset(
+	ctx context.Context,
+	tx pgx.Tx,
+	insertOnly queue.InsertOnly,
+	salt string,
+	user models.User,
+) error {
+	meta, err := json.Marshal(map[string]string{
+		"email": user.Email,
+	})
+	if err != nil {
+		return err
+	}
+
+	token, err := models.CreateToken(
+		ctx,
+		tx,
+		salt,
+		userResetPassword,
+		time.Now().Add(1*time.Hour), // 1 hour expiry
+		meta,
+	)
+	if err != nil {
+		return err
+	}
+
+	resetURL := fmt.Sprintf("%s%s", config.BaseURL, routes.PasswordEdit.URL(token))
+
+	rpEmail := email.ResetPassword{ResetURL: resetURL}
+
+	html, err := rpEmail.ToHTML()
+	if err != nil {
+		return err
+	}
+
+	text, err := rpEmail.ToText()
+	if err != nil {
+		return err
+	}
+
+	_, err = insertOnly.InsertTx(ctx, tx, jobs.SendTransactionalEmailArgs{
+		Data: email.TransactionalData{
+			Template: email.TemplateName(rpEmail),
+			To:       user.Email,
+			From:     "noreply@andurel.com",
+			Subject:  "Reset Your Password",
+			HTMLBody: html,
+			TextBody: text,
+			Secrets:  []string{token},
+		},
+	}, nil)
+
+	return err
+}
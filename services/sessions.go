@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+)
+
+const (
+	// SessionDuration is how long a sign in lasts. Devices that were
+	// remembered get a fresh one from their remember token when it runs out.
+	SessionDuration = 24 * time.Hour
+
+	// sessionTouchInterval throttles the last seen updates, so a busy
+	// session does not write on every request.
+	sessionTouchInterval = 5 * time.Minute
+)
+
+var ErrDeviceNotFound = errors.New("device not found")
+
+// CreateSession signs the user in on the device behind the request. With
+// remember set it also issues a remember token and returns its secret,
+// which is empty otherwise.
+func CreateSession(
+	ctx context.Context,
+	db storage.Pool,
+	pepper string,
+	userID uuid.UUID,
+	remember bool,
+) (models.Session, string, error) {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return models.Session{}, "", err
+	}
+	defer tx.Rollback(ctx)
+
+	metadata := requestMetadataFromContext(ctx)
+
+	session, err := models.CreateSession(ctx, tx, models.CreateSessionData{
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(SessionDuration),
+		IP:        metadata.IP,
+		UserAgent: truncateUserAgent(metadata.UserAgent),
+	})
+	if err != nil {
+		return models.Session{}, "", err
+	}
+
+	var rememberSecret string
+	if remember {
+		_, rememberSecret, err = models.CreateRememberToken(ctx, tx, pepper, models.CreateRememberTokenData{
+			SessionID: session.ID,
+			ExpiresAt: time.Now().Add(RememberMeDuration),
+		})
+		if err != nil {
+			return models.Session{}, "", err
+		}
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   userID,
+		SubjectID: userID,
+		Action:    AuditUserSignedIn,
+		Details: map[string]any{
+			"session_id": session.ID,
+			"remember":   remember,
+		},
+	}); err != nil {
+		return models.Session{}, "", err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.Session{}, "", err
+	}
+
+	return session, rememberSecret, nil
+}
+
+// TouchSession records that session was used by the current request. It
+// writes at most once every few minutes per session.
+func TouchSession(
+	ctx context.Context,
+	db storage.Pool,
+	session models.Session,
+) error {
+	if time.Since(session.LastSeenAt) < sessionTouchInterval {
+		return nil
+	}
+
+	metadata := requestMetadataFromContext(ctx)
+
+	return models.TouchSession(
+		ctx,
+		db.Conn(),
+		session.ID,
+		metadata.IP,
+		truncateUserAgent(metadata.UserAgent),
+	)
+}
+
+// ListDevices returns the devices the user is signed in on.
+func ListDevices(
+	ctx context.Context,
+	db storage.Pool,
+	userID uuid.UUID,
+) ([]models.Session, error) {
+	return models.FindDevicesByUserID(ctx, db.Conn(), userID)
+}
+
+// RevokeDevice signs the user out on one of their devices. The device's
+// remember token stops working with it.
+func RevokeDevice(
+	ctx context.Context,
+	db storage.Pool,
+	userID uuid.UUID,
+	sessionID uuid.UUID,
+) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	session, err := models.FindSession(ctx, tx, sessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrDeviceNotFound
+		}
+		return err
+	}
+
+	if session.UserID != userID {
+		return ErrDeviceNotFound
+	}
+
+	if err := models.RevokeSession(ctx, tx, session.ID); err != nil {
+		return err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   userID,
+		SubjectID: userID,
+		Action:    AuditUserDeviceRevoked,
+		Details: map[string]any{
+			"session_id": session.ID,
+			"device":     session.Device().String(),
+		},
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RevokeOtherDevices signs the user out everywhere except the device they
+// are using.
+func RevokeOtherDevices(
+	ctx context.Context,
+	db storage.Pool,
+	userID uuid.UUID,
+	keepSessionID uuid.UUID,
+) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := models.RevokeOtherUserSessions(ctx, tx, userID, keepSessionID); err != nil {
+		return err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   userID,
+		SubjectID: userID,
+		Action:    AuditUserSessionsRevoked,
+		Details: map[string]any{
+			"kept_session_id": keepSessionID,
+		},
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func RevokeSession(
+	ctx context.Context,
+	db storage.Pool,
+	userID uuid.UUID,
+	sessionID uuid.UUID,
+) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := models.RevokeSession(ctx, tx, sessionID); err != nil {
+		return err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   userID,
+		SubjectID: userID,
+		Action:    AuditUserSignedOut,
+		Details: map[string]any{
+			"session_id": sessionID,
+		},
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RevokeAllSessions signs the user out everywhere by revoking every session
+// that is still active.
+func RevokeAllSessions(
+	ctx context.Context,
+	db storage.Pool,
+	userID uuid.UUID,
+) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := models.RevokeUserSessions(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   userID,
+		SubjectID: userID,
+		Action:    AuditUserSessionsRevoked,
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
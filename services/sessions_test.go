@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/models/factories"
+)
+
+// signIn creates n sessions for the user, one per device.
+func signIn(t *testing.T, db storage.Pool, userID uuid.UUID, n int) []models.Session {
+	t.Helper()
+
+	sessions := make([]models.Session, n)
+	for i := range sessions {
+		session, _, err := CreateSession(context.Background(), db, factories.TestPepper, userID, false)
+		if err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		sessions[i] = session
+	}
+
+	return sessions
+}
+
+func TestSessionRevocation(t *testing.T) {
+	db, _ := testDB(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		// revoke signs out of some of the user's three sessions; other is
+		// a session of another user.
+		revoke func(userID uuid.UUID, sessions []models.Session, other models.Session) error
+		// wantActive tells which of the user's sessions stay signed in.
+		wantActive []bool
+		wantErr    error
+	}{
+		{
+			name: "sign out",
+			revoke: func(userID uuid.UUID, sessions []models.Session, _ models.Session) error {
+				return RevokeSession(ctx, db, userID, sessions[0].ID)
+			},
+			wantActive: []bool{false, true, true},
+		},
+		{
+			name: "revoke a device",
+			revoke: func(userID uuid.UUID, sessions []models.Session, _ models.Session) error {
+				return RevokeDevice(ctx, db, userID, sessions[1].ID)
+			},
+			wantActive: []bool{true, false, true},
+		},
+		{
+			name: "revoke another user's device",
+			revoke: func(userID uuid.UUID, _ []models.Session, other models.Session) error {
+				return RevokeDevice(ctx, db, userID, other.ID)
+			},
+			wantActive: []bool{true, true, true},
+			wantErr:    ErrDeviceNotFound,
+		},
+		{
+			name: "revoke an unknown device",
+			revoke: func(userID uuid.UUID, _ []models.Session, _ models.Session) error {
+				return RevokeDevice(ctx, db, userID, uuid.New())
+			},
+			wantActive: []bool{true, true, true},
+			wantErr:    ErrDeviceNotFound,
+		},
+		{
+			name: "sign out other devices",
+			revoke: func(userID uuid.UUID, sessions []models.Session, _ models.Session) error {
+				return RevokeOtherDevices(ctx, db, userID, sessions[2].ID)
+			},
+			wantActive: []bool{false, false, true},
+		},
+		{
+			name: "sign out everywhere",
+			revoke: func(userID uuid.UUID, _ []models.Session, _ models.Session) error {
+				return RevokeAllSessions(ctx, db, userID)
+			},
+			wantActive: []bool{false, false, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+			if err != nil {
+				t.Fatal(err)
+			}
+			otherUser, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sessions := signIn(t, db, user.ID, len(tt.wantActive))
+			other := signIn(t, db, otherUser.ID, 1)[0]
+
+			if err := tt.revoke(user.ID, sessions, other); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+
+			for i, want := range tt.wantActive {
+				session, err := models.FindSession(ctx, db.Conn(), sessions[i].ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if session.IsActive() != want {
+					t.Errorf("session %d active = %v, want %v", i, session.IsActive(), want)
+				}
+			}
+
+			other, err = models.FindSession(ctx, db.Conn(), other.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !other.IsActive() {
+				t.Error("another user's session was revoked")
+			}
+		})
+	}
+}
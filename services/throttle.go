@@ -0,0 +1,430 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"mbvlabs/config"
+	"mbvlabs/email"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/queue"
+	"mbvlabs/router/routes"
+)
+
+var ErrThrottled = errors.New("too many attempts")
+
+// ThrottledError is returned while a client or account is locked out.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e ThrottledError) Error() string {
+	return ErrThrottled.Error()
+}
+
+func (e ThrottledError) Unwrap() error {
+	return ErrThrottled
+}
+
+type ThrottleAction string
+
+const (
+	ThrottleSignIn             ThrottleAction = "sign_in"
+	ThrottleEmailConfirmation  ThrottleAction = "email_confirmation"
+	ThrottleConfirmationResend ThrottleAction = "confirmation_resend"
+	ThrottlePasswordReset      ThrottleAction = "password_reset"
+	ThrottleMagicLink          ThrottleAction = "magic_link"
+	ThrottleTwoFactor          ThrottleAction = "two_factor"
+	// ThrottlePasswordStrength counts every request, not only failures:
+	// each one costs an estimate.
+	ThrottlePasswordStrength ThrottleAction = "password_strength"
+)
+
+// throttleLimit allows a number of failures within window. Reaching the limit
+// locks the key for lockout, and every failure after that doubles the lock up
+// to maxLockout. A zero limit disables the key.
+type throttleLimit struct {
+	failures   int32
+	window     time.Duration
+	lockout    time.Duration
+	maxLockout time.Duration
+}
+
+func (l throttleLimit) lockoutFor(failures int32) time.Duration {
+	lockout := l.lockout
+	for i := l.failures; i < failures && lockout < l.maxLockout; i++ {
+		lockout *= 2
+	}
+
+	return min(lockout, l.maxLockout)
+}
+
+type throttlePolicy struct {
+	ip    throttleLimit
+	email throttleLimit
+	user  throttleLimit
+	// notifyOwner emails the account owner the first time the email key locks.
+	notifyOwner bool
+}
+
+var throttlePolicies = map[ThrottleAction]throttlePolicy{
+	ThrottleSignIn: {
+		ip: throttleLimit{
+			failures:   50,
+			window:     time.Hour,
+			lockout:    5 * time.Minute,
+			maxLockout: time.Hour,
+		},
+		email: throttleLimit{
+			failures:   5,
+			window:     24 * time.Hour,
+			lockout:    time.Minute,
+			maxLockout: time.Hour,
+		},
+		notifyOwner: true,
+	},
+	ThrottleEmailConfirmation: {
+		ip: throttleLimit{
+			failures:   10,
+			window:     time.Hour,
+			lockout:    5 * time.Minute,
+			maxLockout: time.Hour,
+		},
+		email: throttleLimit{
+			failures:   10,
+			window:     time.Hour,
+			lockout:    15 * time.Minute,
+			maxLockout: time.Hour,
+		},
+	},
+	ThrottleConfirmationResend: {
+		ip: throttleLimit{
+			failures:   10,
+			window:     time.Hour,
+			lockout:    15 * time.Minute,
+			maxLockout: time.Hour,
+		},
+		email: throttleLimit{
+			failures:   5,
+			window:     time.Hour,
+			lockout:    15 * time.Minute,
+			maxLockout: time.Hour,
+		},
+	},
+	ThrottlePasswordReset: {
+		ip: throttleLimit{
+			failures:   10,
+			window:     time.Hour,
+			lockout:    15 * time.Minute,
+			maxLockout: time.Hour,
+		},
+		email: throttleLimit{
+			failures:   3,
+			window:     time.Hour,
+			lockout:    15 * time.Minute,
+			maxLockout: time.Hour,
+		},
+	},
+	ThrottleMagicLink: {
+		ip: throttleLimit{
+			failures:   10,
+			window:     time.Hour,
+			lockout:    15 * time.Minute,
+			maxLockout: time.Hour,
+		},
+		email: throttleLimit{
+			failures:   3,
+			window:     time.Hour,
+			lockout:    15 * time.Minute,
+			maxLockout: time.Hour,
+		},
+	},
+	// A fresh challenge only takes a password, so the user key limits
+	// guesses across every challenge the password holder starts.
+	ThrottleTwoFactor: {
+		ip: throttleLimit{
+			failures:   20,
+			window:     time.Hour,
+			lockout:    15 * time.Minute,
+			maxLockout: time.Hour,
+		},
+		user: throttleLimit{
+			failures:   10,
+			window:     time.Hour,
+			lockout:    15 * time.Minute,
+			maxLockout: 4 * time.Hour,
+		},
+	},
+	ThrottlePasswordStrength: {
+		ip: throttleLimit{
+			failures:   300,
+			window:     10 * time.Minute,
+			lockout:    time.Minute,
+			maxLockout: 15 * time.Minute,
+		},
+	},
+}
+
+// ThrottleTarget identifies who is attempting an action. Any field may be
+// empty, in which case that key is not tracked. UserID is used once the
+// account is known but the email address was not typed in.
+type ThrottleTarget struct {
+	Action ThrottleAction
+	IP     string
+	Email  string
+	UserID uuid.UUID
+}
+
+type throttleKey struct {
+	key       string
+	limit     throttleLimit
+	isEmail   bool
+	isAccount bool
+}
+
+func (t ThrottleTarget) keys() []throttleKey {
+	policy := throttlePolicies[t.Action]
+
+	var keys []throttleKey
+	if t.IP != "" && policy.ip.failures > 0 {
+		keys = append(keys, throttleKey{
+			key:   string(t.Action) + ":ip:" + t.IP,
+			limit: policy.ip,
+		})
+	}
+
+	if normalized := normalizeThrottleEmail(t.Email); normalized != "" &&
+		policy.email.failures > 0 {
+		sum := sha256.Sum256([]byte(normalized))
+		keys = append(keys, throttleKey{
+			key:       string(t.Action) + ":email:" + hex.EncodeToString(sum[:]),
+			limit:     policy.email,
+			isEmail:   true,
+			isAccount: true,
+		})
+	}
+
+	if t.UserID != uuid.Nil && policy.user.failures > 0 {
+		keys = append(keys, throttleKey{
+			key:       string(t.Action) + ":user:" + t.UserID.String(),
+			limit:     policy.user,
+			isAccount: true,
+		})
+	}
+
+	return keys
+}
+
+func normalizeThrottleEmail(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+// ThrottleAttempt is an attempt ReserveThrottleAttempt counted against the
+// keys of its target. Once the outcome is known it goes to
+// RecordThrottleFailure, RefundThrottleAttempt or ResetThrottle.
+type ThrottleAttempt struct {
+	target ThrottleTarget
+	keys   []throttleKey
+	// locks are the keys this attempt locked by reaching their limit.
+	locks []throttleLock
+}
+
+type throttleLock struct {
+	key         throttleKey
+	failures    int32
+	lockedUntil time.Time
+}
+
+// ReserveThrottleAttempt counts an attempt against every key of the target
+// before the credentials are checked, and returns a ThrottledError without
+// counting it if any key is locked. The attempt that reaches a limit locks
+// the key at once, so however many requests arrive together, at most the
+// limit of them get through to the credentials.
+func ReserveThrottleAttempt(
+	ctx context.Context,
+	db storage.Pool,
+	target ThrottleTarget,
+) (ThrottleAttempt, error) {
+	attempt := ThrottleAttempt{target: target, keys: target.keys()}
+	if len(attempt.keys) == 0 {
+		return attempt, nil
+	}
+
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return ThrottleAttempt{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, key := range attempt.keys {
+		throttle, err := models.ReserveThrottleAttempt(ctx, tx, key.key, now.Add(-key.limit.window))
+		if err != nil {
+			return ThrottleAttempt{}, err
+		}
+
+		if throttle.IsLocked() {
+			retryAfter = max(retryAfter, time.Until(throttle.LockedUntil))
+			continue
+		}
+
+		if throttle.Failures < key.limit.failures {
+			continue
+		}
+
+		lockedUntil := now.Add(key.limit.lockoutFor(throttle.Failures))
+		if err := models.LockThrottle(ctx, tx, key.key, lockedUntil); err != nil {
+			return ThrottleAttempt{}, err
+		}
+		attempt.locks = append(attempt.locks, throttleLock{key, throttle.Failures, lockedUntil})
+	}
+
+	// Rolling back leaves the other keys as they were: a refused attempt
+	// is not counted.
+	if retryAfter > 0 {
+		return ThrottleAttempt{}, ThrottledError{RetryAfter: retryAfter}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return ThrottleAttempt{}, err
+	}
+
+	return attempt, nil
+}
+
+// RecordThrottleFailure settles a reserved attempt as failed. The attempt is
+// already counted; this audits the keys it locked and, for sign-in, tells
+// the owner of the account the first time it is locked in a window.
+func RecordThrottleFailure(
+	ctx context.Context,
+	db storage.Pool,
+	insertOnly queue.InsertOnly,
+	attempt ThrottleAttempt,
+) error {
+	if len(attempt.locks) == 0 {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	target := attempt.target
+	for _, lock := range attempt.locks {
+		details := map[string]any{
+			"throttle":     target.Action,
+			"locked_until": lock.lockedUntil,
+			"failures":     lock.failures,
+		}
+		var subjectID uuid.UUID
+		switch {
+		case lock.key.isEmail:
+			details["email"] = normalizeThrottleEmail(target.Email)
+		case lock.key.isAccount:
+			subjectID = target.UserID
+		}
+
+		if err := Audit(ctx, tx, AuditEntry{
+			SubjectID: subjectID,
+			Action:    AuditUserLockedOut,
+			Details:   details,
+		}); err != nil {
+			return err
+		}
+
+		if lock.key.isEmail && throttlePolicies[target.Action].notifyOwner &&
+			lock.failures == lock.key.limit.failures {
+			if err := notifyAccountLocked(ctx, tx, insertOnly, target.Email, lock.lockedUntil); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RefundThrottleAttempt takes back a reserved attempt that neither failed
+// nor succeeded, such as one that hit an internal error.
+func RefundThrottleAttempt(
+	ctx context.Context,
+	db storage.Pool,
+	attempt ThrottleAttempt,
+) error {
+	for _, key := range attempt.keys {
+		if err := models.RefundThrottleAttempt(ctx, db.Conn(), key.key, key.limit.failures); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ResetThrottle settles a reserved attempt as successful: it clears the
+// email and user keys and takes the attempt back from the IP key. The IP
+// key is not cleared, otherwise an attacker could reset it with their own
+// account.
+func ResetThrottle(
+	ctx context.Context,
+	db storage.Pool,
+	attempt ThrottleAttempt,
+) error {
+	for _, key := range attempt.keys {
+		var err error
+		if key.isAccount {
+			err = models.DestroyThrottle(ctx, db.Conn(), key.key)
+		} else {
+			err = models.RefundThrottleAttempt(ctx, db.Conn(), key.key, key.limit.failures)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func notifyAccountLocked(
+	ctx context.Context,
+	tx pgx.Tx,
+	insertOnly queue.InsertOnly,
+	address string,
+	lockedUntil time.Time,
+) error {
+	user, err := models.FindUserByEmail(ctx, tx, normalizeThrottleEmail(address))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	resetURL, err := url.JoinPath(config.BaseURL, routes.PasswordNew.URL())
+	if err != nil {
+		return err
+	}
+
+	return enqueueTransactionalEmail(
+		ctx,
+		tx,
+		insertOnly,
+		user.Email,
+		"Sign-in Temporarily Locked",
+		email.AccountLocked{
+			LockedUntil:      lockedUntil.UTC().Format("15:04 UTC on January 2"),
+			ResetPasswordURL: resetURL,
+		},
+	)
+}
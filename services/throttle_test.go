@@ -0,0 +1,295 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mbvlabs/email"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+	"mbvlabs/models/factories"
+)
+
+func TestThrottleTargetKeys(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name   string
+		target ThrottleTarget
+		want   []string
+	}{
+		{
+			name:   "sign in by ip and email",
+			target: ThrottleTarget{Action: ThrottleSignIn, IP: "10.0.0.1", Email: " Jane@Example.com "},
+			want:   []string{"sign_in:ip:10.0.0.1", "sign_in:email:"},
+		},
+		{
+			name:   "two factor by ip and user",
+			target: ThrottleTarget{Action: ThrottleTwoFactor, IP: "10.0.0.1", UserID: userID},
+			want:   []string{"two_factor:ip:10.0.0.1", "two_factor:user:" + userID.String()},
+		},
+		{
+			name:   "user key ignored without a user limit",
+			target: ThrottleTarget{Action: ThrottleSignIn, UserID: userID},
+			want:   nil,
+		},
+		{
+			name:   "email key ignored without an email limit",
+			target: ThrottleTarget{Action: ThrottlePasswordStrength, IP: "10.0.0.1", Email: "jane@example.com"},
+			want:   []string{"password_strength:ip:10.0.0.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := tt.target.keys()
+			if len(keys) != len(tt.want) {
+				t.Fatalf("keys() = %d keys, want %d", len(keys), len(tt.want))
+			}
+
+			for i, key := range keys {
+				if !strings.HasPrefix(key.key, tt.want[i]) {
+					t.Errorf("keys()[%d] = %q, want prefix %q", i, key.key, tt.want[i])
+				}
+				if key.isAccount == strings.Contains(key.key, ":ip:") {
+					t.Errorf("keys()[%d] = %q has isAccount %v", i, key.key, key.isAccount)
+				}
+			}
+		})
+	}
+
+	same := ThrottleTarget{Action: ThrottleSignIn, Email: "jane@example.com"}.keys()
+	other := ThrottleTarget{Action: ThrottleSignIn, Email: " JANE@example.com"}.keys()
+	if same[0].key != other[0].key {
+		t.Errorf("email keys differ by case: %q and %q", same[0].key, other[0].key)
+	}
+}
+
+func TestThrottleLimitLockoutFor(t *testing.T) {
+	limit := throttleLimit{
+		failures:   5,
+		window:     time.Hour,
+		lockout:    15 * time.Minute,
+		maxLockout: time.Hour,
+	}
+
+	tests := map[int32]time.Duration{
+		5: 15 * time.Minute,
+		6: 30 * time.Minute,
+		7: time.Hour,
+		9: time.Hour,
+	}
+
+	for failures, want := range tests {
+		if got := limit.lockoutFor(failures); got != want {
+			t.Errorf("lockoutFor(%d) = %s, want %s", failures, got, want)
+		}
+	}
+}
+
+// uniqueSignInTarget returns a sign-in target no other test shares.
+func uniqueSignInTarget() ThrottleTarget {
+	id := uuid.NewString()
+	return ThrottleTarget{
+		Action: ThrottleSignIn,
+		IP:     "ip-" + id,
+		Email:  id + "@example.com",
+	}
+}
+
+func throttleFailures(t *testing.T, db storage.Pool, target ThrottleTarget) map[string]models.Throttle {
+	t.Helper()
+
+	var names []string
+	for _, key := range target.keys() {
+		names = append(names, key.key)
+	}
+
+	throttles, err := models.FindThrottlesByKeys(context.Background(), db.Conn(), names)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byKey := make(map[string]models.Throttle, len(throttles))
+	for _, throttle := range throttles {
+		byKey[throttle.Key] = throttle
+	}
+
+	return byKey
+}
+
+// TestThrottleConcurrentSignIns fires a burst of bad passwords at one
+// account from many addresses at once. No more than the email limit may
+// reach the password check.
+func TestThrottleConcurrentSignIns(t *testing.T) {
+	db, insertOnly := testDB(t)
+	ctx := context.Background()
+
+	user, err := factories.CreateUser(ctx, db.Conn(), factories.WithValidatedEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limit := throttlePolicies[ThrottleSignIn].email.failures
+	const attempts = 40
+
+	var reached atomic.Int32
+	var wg sync.WaitGroup
+	for i := range attempts {
+		wg.Go(func() {
+			attempt, err := ReserveThrottleAttempt(ctx, db, ThrottleTarget{
+				Action: ThrottleSignIn,
+				IP:     fmt.Sprintf("198.51.100.%d", i),
+				Email:  user.Email,
+			})
+			if errors.Is(err, ErrThrottled) {
+				return
+			}
+			if err != nil {
+				t.Errorf("ReserveThrottleAttempt: %v", err)
+				return
+			}
+
+			reached.Add(1)
+			_, err = AuthenticateUser(ctx, db, factories.TestPepper, LoginData{
+				Email:    user.Email,
+				Password: "not the password",
+			})
+			if !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("AuthenticateUser = %v, want ErrInvalidCredentials", err)
+			}
+
+			if err := RecordThrottleFailure(ctx, db, insertOnly, attempt); err != nil {
+				t.Errorf("RecordThrottleFailure: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if got := reached.Load(); got != limit {
+		t.Errorf("%d of %d concurrent attempts reached the password check, want %d", got, attempts, limit)
+	}
+
+	if got := auditCount(t, db, AuditUserLockedOut, user.Email); got != 1 {
+		t.Errorf("recorded %d lockouts, want 1", got)
+	}
+
+	var notices int
+	for _, queued := range enqueuedEmails(t, db, user.Email) {
+		if queued.Subject == "Sign-in Temporarily Locked" {
+			notices++
+			if want := email.TemplateName(email.AccountLocked{}); queued.Template != want {
+				t.Errorf("lockout notice template = %q, want %q", queued.Template, want)
+			}
+		}
+	}
+	if notices != 1 {
+		t.Errorf("queued %d lockout notices, want 1", notices)
+	}
+
+	// The right password is refused too while the account is locked.
+	_, err = ReserveThrottleAttempt(ctx, db, ThrottleTarget{
+		Action: ThrottleSignIn,
+		IP:     "198.51.100.250",
+		Email:  user.Email,
+	})
+	var throttled ThrottledError
+	if !errors.As(err, &throttled) || throttled.RetryAfter <= 0 {
+		t.Errorf("ReserveThrottleAttempt on a locked account = %v, want a ThrottledError", err)
+	}
+}
+
+func TestThrottleAttemptSettlement(t *testing.T) {
+	db, insertOnly := testDB(t)
+	ctx := context.Background()
+	limit := throttlePolicies[ThrottleSignIn].email.failures
+
+	reserve := func(t *testing.T, target ThrottleTarget) ThrottleAttempt {
+		t.Helper()
+		attempt, err := ReserveThrottleAttempt(ctx, db, target)
+		if err != nil {
+			t.Fatalf("ReserveThrottleAttempt: %v", err)
+		}
+		return attempt
+	}
+
+	t.Run("failures lock at the limit", func(t *testing.T) {
+		target := uniqueSignInTarget()
+		for range limit {
+			if err := RecordThrottleFailure(ctx, db, insertOnly, reserve(t, target)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		_, err := ReserveThrottleAttempt(ctx, db, target)
+		if !errors.Is(err, ErrThrottled) {
+			t.Fatalf("attempt after %d failures = %v, want ErrThrottled", limit, err)
+		}
+
+		// The refused attempt is not counted.
+		emailKey := target.keys()[1].key
+		if got := throttleFailures(t, db, target)[emailKey].Failures; got != limit {
+			t.Errorf("email key has %d failures, want %d", got, limit)
+		}
+	})
+
+	t.Run("refunded attempts do not count", func(t *testing.T) {
+		target := uniqueSignInTarget()
+		for range limit * 2 {
+			if err := RefundThrottleAttempt(ctx, db, reserve(t, target)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for key, throttle := range throttleFailures(t, db, target) {
+			if throttle.Failures != 0 || throttle.IsLocked() {
+				t.Errorf("%s has %d failures, locked %v, want none", key, throttle.Failures, throttle.IsLocked())
+			}
+		}
+	})
+
+	t.Run("refunding the locking attempt lifts the lock", func(t *testing.T) {
+		target := uniqueSignInTarget()
+		for range limit - 1 {
+			if err := RecordThrottleFailure(ctx, db, insertOnly, reserve(t, target)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := RefundThrottleAttempt(ctx, db, reserve(t, target)); err != nil {
+			t.Fatal(err)
+		}
+
+		reserve(t, target)
+	})
+
+	t.Run("success clears the account but not the address", func(t *testing.T) {
+		target := uniqueSignInTarget()
+		for range limit - 1 {
+			if err := RecordThrottleFailure(ctx, db, insertOnly, reserve(t, target)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := ResetThrottle(ctx, db, reserve(t, target)); err != nil {
+			t.Fatal(err)
+		}
+
+		keys := target.keys()
+		throttles := throttleFailures(t, db, target)
+		if got := throttles[keys[0].key].Failures; got != limit-1 {
+			t.Errorf("ip key has %d failures, want the %d failed ones", got, limit-1)
+		}
+		if _, ok := throttles[keys[1].key]; ok {
+			t.Error("email key survived a successful sign in")
+		}
+	})
+}
@@ -0,0 +1,402 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"image/png"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pquerna/otp/totp"
+
+	"mbvlabs/config"
+	"mbvlabs/internal/storage"
+	"mbvlabs/models"
+)
+
+const (
+	userTwoFactorChallenge = "user_two_factor_challenge"
+
+	// MaxTwoFactorAttempts is how many wrong codes void a challenge.
+	MaxTwoFactorAttempts = 5
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnrolled      = errors.New("two-factor authentication is not enrolled")
+	ErrInvalidTwoFactorCode      = errors.New("invalid two-factor code")
+	ErrInvalidTwoFactorChallenge = errors.New("invalid or expired two-factor challenge")
+	ErrTooManyTwoFactorAttempts  = errors.New("too many incorrect two-factor codes")
+)
+
+type TOTPEnrollment struct {
+	Secret string
+	URI    string
+	QRCode string
+}
+
+// BeginTOTPEnrollment generates a new secret for the user and stores it
+// unconfirmed. The returned QR code is a PNG data URI.
+func BeginTOTPEnrollment(
+	ctx context.Context,
+	db storage.Pool,
+	user models.User,
+) (TOTPEnrollment, error) {
+	existing, err := models.FindTOTPCredentialByUserID(ctx, db.Conn(), user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return TOTPEnrollment{}, err
+	}
+
+	if err == nil && existing.IsConfirmed() {
+		return TOTPEnrollment{}, ErrTwoFactorAlreadyEnabled
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      config.ProjectName,
+		AccountName: user.Email,
+	})
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	if _, err := models.CreateTOTPCredential(ctx, db.Conn(), models.CreateTOTPCredentialData{
+		UserID: user.ID,
+		Secret: key.Secret(),
+	}); err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	img, err := key.Image(200, 200)
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	return TOTPEnrollment{
+		Secret: key.Secret(),
+		URI:    key.URL(),
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
+
+// ConfirmTOTPEnrollment activates the pending secret once the user proves
+// they can generate codes for it, and returns a fresh set of recovery codes.
+func ConfirmTOTPEnrollment(
+	ctx context.Context,
+	db storage.Pool,
+	pepper string,
+	userID uuid.UUID,
+	code string,
+) ([]string, error) {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	credential, err := models.FindTOTPCredentialByUserID(ctx, tx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTwoFactorNotEnrolled
+		}
+		return nil, err
+	}
+
+	if credential.IsConfirmed() {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	step, ok, err := credential.ValidCode(code, pepper)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	if _, err := models.ConfirmTOTPCredential(ctx, tx, credential.ID, step); err != nil {
+		return nil, err
+	}
+
+	codes, err := models.CreateRecoveryCodes(ctx, tx, pepper, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   userID,
+		SubjectID: userID,
+		Action:    AuditUserTwoFactorEnabled,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DisableTwoFactor removes the user's TOTP secret and recovery codes. A
+// current code or an unused recovery code is required.
+func DisableTwoFactor(
+	ctx context.Context,
+	db storage.Pool,
+	pepper string,
+	userID uuid.UUID,
+	code string,
+) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := verifySecondFactor(ctx, tx, pepper, userID, code); err != nil {
+		return err
+	}
+
+	if err := models.DestroyTOTPCredentialByUserID(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	if err := models.DestroyRecoveryCodesByUserID(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	if err := Audit(ctx, tx, AuditEntry{
+		ActorID:   userID,
+		SubjectID: userID,
+		Action:    AuditUserTwoFactorDisabled,
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func TwoFactorEnabled(
+	ctx context.Context,
+	db storage.Pool,
+	userID uuid.UUID,
+) (bool, error) {
+	credential, err := models.FindTOTPCredentialByUserID(ctx, db.Conn(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return credential.IsConfirmed(), nil
+}
+
+// StartTwoFactorChallenge is called after the password step succeeds. The
+// returned token identifies the pending login until the second factor is
+// provided.
+func StartTwoFactorChallenge(
+	ctx context.Context,
+	db storage.Pool,
+	pepper string,
+	userID uuid.UUID,
+) (string, error) {
+	meta, err := json.Marshal(map[string]string{
+		"user_id": userID.String(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return models.CreateToken(
+		ctx,
+		db.Conn(),
+		pepper,
+		userTwoFactorChallenge,
+		time.Now().Add(5*time.Minute),
+		meta,
+	)
+}
+
+// TwoFactorChallengeUser returns the user a pending challenge was issued
+// for, so attempts can be throttled per account before a code is checked.
+func TwoFactorChallengeUser(
+	ctx context.Context,
+	db storage.Pool,
+	pepper string,
+	challenge string,
+) (uuid.UUID, error) {
+	_, userID, err := findTwoFactorChallenge(ctx, db.Conn(), pepper, challenge)
+
+	return userID, err
+}
+
+func findTwoFactorChallenge(
+	ctx context.Context,
+	exec storage.Executor,
+	pepper string,
+	challenge string,
+) (models.Token, uuid.UUID, error) {
+	token, err := models.FindTokenByScopeAndHash(
+		ctx,
+		exec,
+		pepper,
+		userTwoFactorChallenge,
+		challenge,
+	)
+	if err != nil {
+		return models.Token{}, uuid.Nil, ErrInvalidTwoFactorChallenge
+	}
+
+	if !token.IsValid(challenge, pepper) {
+		return models.Token{}, uuid.Nil, ErrInvalidTwoFactorChallenge
+	}
+
+	var meta map[string]string
+	if err := json.Unmarshal(token.MetaData, &meta); err != nil {
+		return models.Token{}, uuid.Nil, err
+	}
+
+	userID, err := uuid.Parse(meta["user_id"])
+	if err != nil {
+		return models.Token{}, uuid.Nil, ErrInvalidTwoFactorChallenge
+	}
+
+	return token, userID, nil
+}
+
+type CompleteTwoFactorChallengeData struct {
+	Challenge string
+	Code      string
+}
+
+// CompleteTwoFactorChallenge accepts either a TOTP code or a recovery code for
+// the pending login and returns the user the challenge was issued for. After
+// MaxTwoFactorAttempts wrong codes the challenge is voided and
+// ErrTooManyTwoFactorAttempts is returned.
+func CompleteTwoFactorChallenge(
+	ctx context.Context,
+	db storage.Pool,
+	pepper string,
+	data CompleteTwoFactorChallengeData,
+) (models.User, error) {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	token, userID, err := findTwoFactorChallenge(ctx, tx, pepper, data.Challenge)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if err := verifySecondFactor(ctx, tx, pepper, userID, data.Code); err != nil {
+		if !errors.Is(err, ErrInvalidTwoFactorCode) {
+			return models.User{}, err
+		}
+
+		// A wrong code writes nothing else, so the attempt is committed.
+		attempts, err := models.IncrementTokenAttempts(ctx, tx, token.ID)
+		if err != nil {
+			return models.User{}, err
+		}
+
+		verifyErr := ErrInvalidTwoFactorCode
+		if attempts >= MaxTwoFactorAttempts {
+			if err := models.DestroyToken(ctx, tx, token.ID); err != nil &&
+				!errors.Is(err, sql.ErrNoRows) {
+				return models.User{}, err
+			}
+			verifyErr = ErrTooManyTwoFactorAttempts
+		}
+
+		if err := Audit(ctx, tx, AuditEntry{
+			SubjectID: userID,
+			Action:    AuditUserSignInFailed,
+			Details: map[string]any{
+				"reason":   "invalid_second_factor",
+				"attempts": attempts,
+			},
+		}); err != nil {
+			return models.User{}, err
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			return models.User{}, err
+		}
+
+		return models.User{}, verifyErr
+	}
+
+	user, err := models.FindUser(ctx, tx, userID)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if err := models.DestroyToken(ctx, tx, token.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, ErrInvalidTwoFactorChallenge
+		}
+		return models.User{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+func verifySecondFactor(
+	ctx context.Context,
+	exec storage.Executor,
+	pepper string,
+	userID uuid.UUID,
+	code string,
+) error {
+	credential, err := models.FindTOTPCredentialByUserID(ctx, exec, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTwoFactorNotEnrolled
+		}
+		return err
+	}
+
+	if !credential.IsConfirmed() {
+		return ErrTwoFactorNotEnrolled
+	}
+
+	step, ok, err := credential.ValidCode(code, pepper)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		if err := models.ResealTOTPCredential(ctx, exec, pepper, credential); err != nil {
+			return err
+		}
+
+		return models.UpdateTOTPCredentialLastUsedStep(ctx, exec, credential.ID, step)
+	}
+
+	used, err := models.UseRecoveryCode(ctx, exec, pepper, userID, code)
+	if err != nil {
+		return err
+	}
+
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
@@ -36,3 +36,14 @@ func RecErr(span trace.Span, err error) {
 func AddEvent(span trace.Span, name string, attrs ...attribute.KeyValue) {
 	span.AddEvent(name, trace.WithAttributes(attrs...))
 }
+
+// TraceID returns the id of the trace active in ctx, or an empty string when
+// there is none.
+func TraceID(ctx context.Context) string {
+	spanCtx := trace.SpanContextFromContext(ctx)
+	if !spanCtx.IsValid() {
+		return ""
+	}
+
+	return spanCtx.TraceID().String()
+}
@@ -87,6 +87,20 @@ func HTTPResponseSize() (metric.Float64Histogram, error) {
 	return histogram, nil
 }
 
+// CleanupDeletedTotal counts rows removed by the periodic cleanup jobs.
+// Record it with a "kind" attribute naming what was removed.
+func CleanupDeletedTotal() (metric.Int64Counter, error) {
+	counter, err := GetMeter(config.ServiceName).Int64Counter(
+		"cleanup_deleted_total",
+		metric.WithDescription("Total number of records removed by cleanup jobs"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cleanup_deleted_total counter: %w", err)
+	}
+	return counter, nil
+}
+
 func SetupRuntimeMetricsInCallback(meter metric.Meter) error {
 	_, err := meter.Int64ObservableGauge(
 		"go_goroutines",
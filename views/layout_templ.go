@@ -10,10 +10,12 @@ import templruntime "github.com/a-h/templ/runtime"
 
 import (
 	"mbvlabs/config"
+	"mbvlabs/internal/hypermedia"
 	"mbvlabs/internal/server"
 	"mbvlabs/router/cookies"
 	"mbvlabs/router/routes"
 	"mbvlabs/views/components"
+	"net/http"
 	"time"
 )
 
@@ -46,20 +48,66 @@ func base(headOpts ...components.HeadDataOption) templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "<body class=\"min-h-screen flex flex-col bg-background\"><nav class=\"px-4 md:px-0 h-16 mx-auto container flex items-center justify-between\"><section class=\"w-[max-content] mr-4 md:mr-10\"><a class=\"text-lg md:text-xl\" href=\"")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "<body class=\"min-h-screen flex flex-col bg-background\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var2 templ.SafeURL
-		templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinURLErrs(templ.SafeURL(routes.HomePage.URL()))
+		if app := cookies.GetAppCtx(ctx); app.IsImpersonating() {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<div id=\"impersonation-banner\" role=\"alert\" class=\"w-full bg-slate-700 text-white px-4 py-2 flex items-center justify-center gap-4\"><span>You are impersonating <strong>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var2 string
+			templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs(app.Email)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `views/layout.templ`, Line: 21, Col: 52}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var2))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "</strong>. Everything you do is recorded.</span> <button type=\"button\" class=\"btn-outline\" data-on:click=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var3 string
+			templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(hypermedia.DataAction(http.MethodDelete, routes.ImpersonationDestroy.URL()))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `views/layout.templ`, Line: 22, Col: 138}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "\">Stop impersonating</button></div>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "<nav class=\"px-4 md:px-0 h-16 mx-auto container flex items-center justify-between\"><section class=\"w-[max-content] mr-4 md:mr-10\"><a class=\"text-lg md:text-xl\" href=\"")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var4 templ.SafeURL
+		templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinURLErrs(templ.SafeURL(routes.HomePage.URL()))
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `views/layout.templ`, Line: 19, Col: 78}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `views/layout.templ`, Line: 29, Col: 78}
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var2))
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "\"><span class=\"font-semibold text-sm\">mbv</span><br><span class=\"font-bold text-xl\">labs.</span></a></section><section id=\"demo-dropdown-menu\" class=\"md:hidden dropdown-menu\"><button type=\"button\" id=\"demo-dropdown-menu-trigger\" aria-haspopup=\"menu\" aria-controls=\"demo-dropdown-menu-menu\" aria-expanded=\"false\" class=\"btn-outline\" aria-label=\"Navigation menu\"><svg xmlns=\"http://www.w3.org/2000/svg\" width=\"24\" height=\"24\" viewBox=\"0 0 24 24\" fill=\"none\" stroke=\"currentColor\" stroke-width=\"2\" stroke-linecap=\"round\" stroke-linejoin=\"round\"><line x1=\"4\" x2=\"20\" y1=\"12\" y2=\"12\"></line> <line x1=\"4\" x2=\"20\" y1=\"6\" y2=\"6\"></line> <line x1=\"4\" x2=\"20\" y1=\"18\" y2=\"18\"></line></svg></button><div id=\"demo-dropdown-menu-popover\" data-popover data-side=\"left\" aria-hidden=\"true\" class=\"min-w-56\"><div role=\"menu\" id=\"demo-dropdown-menu-menu\" aria-labelledby=\"demo-dropdown-menu-trigger\"><div role=\"menuitem\"><a class=\"block w-full\" tabindex=\"0\">Services</a></div><div role=\"menuitem\"><a class=\"block w-full\" tabindex=\"0\">Work</a></div><div role=\"menuitem\"><a class=\"block w-full\" tabindex=\"0\">Case Studies</a></div><div role=\"menuitem\"><a class=\"block w-full\" tabindex=\"0\">Blog</a></div><hr role=\"separator\"><div role=\"menuitem\"><a class=\"block w-full\" tabindex=\"0\">Contact Me</a></div></div></div></section><section class=\"hidden md:flex items-center gap-4\"><a class=\"btn-link text-slate-700\" tabindex=\"0\">Services</a> <a class=\"btn-link text-slate-700\" tabindex=\"0\">Work</a> <a class=\"btn-link text-slate-700\" tabindex=\"0\">Case Studies</a> <a class=\"btn-link text-slate-700\" tabindex=\"0\">Blog</a> <a class=\"btn bg-slate-700 text-white\" tabindex=\"0\">Contact Me</a></section></nav>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "\"><span class=\"font-semibold text-sm\">mbv</span><br><span class=\"font-bold text-xl\">labs.</span></a></section><section id=\"demo-dropdown-menu\" class=\"md:hidden dropdown-menu\"><button type=\"button\" id=\"demo-dropdown-menu-trigger\" aria-haspopup=\"menu\" aria-controls=\"demo-dropdown-menu-menu\" aria-expanded=\"false\" class=\"btn-outline\" aria-label=\"Navigation menu\"><svg xmlns=\"http://www.w3.org/2000/svg\" width=\"24\" height=\"24\" viewBox=\"0 0 24 24\" fill=\"none\" stroke=\"currentColor\" stroke-width=\"2\" stroke-linecap=\"round\" stroke-linejoin=\"round\"><line x1=\"4\" x2=\"20\" y1=\"12\" y2=\"12\"></line> <line x1=\"4\" x2=\"20\" y1=\"6\" y2=\"6\"></line> <line x1=\"4\" x2=\"20\" y1=\"18\" y2=\"18\"></line></svg></button><div id=\"demo-dropdown-menu-popover\" data-popover data-side=\"left\" aria-hidden=\"true\" class=\"min-w-56\"><div role=\"menu\" id=\"demo-dropdown-menu-menu\" aria-labelledby=\"demo-dropdown-menu-trigger\"><div role=\"menuitem\"><a class=\"block w-full\" tabindex=\"0\">Services</a></div><div role=\"menuitem\"><a class=\"block w-full\" tabindex=\"0\">Work</a></div><div role=\"menuitem\"><a class=\"block w-full\" tabindex=\"0\">Case Studies</a></div><div role=\"menuitem\"><a class=\"block w-full\" tabindex=\"0\">Blog</a></div><hr role=\"separator\"><div role=\"menuitem\"><a class=\"block w-full\" tabindex=\"0\">Contact Me</a></div></div></div></section><section class=\"hidden md:flex items-center gap-4\"><a class=\"btn-link text-slate-700\" tabindex=\"0\">Services</a> <a class=\"btn-link text-slate-700\" tabindex=\"0\">Work</a> <a class=\"btn-link text-slate-700\" tabindex=\"0\">Case Studies</a> <a class=\"btn-link text-slate-700\" tabindex=\"0\">Blog</a> <a class=\"btn bg-slate-700 text-white\" tabindex=\"0\">Contact Me</a> ")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		if app := cookies.GetAppCtx(ctx); app.IsAuthenticated {
+			templ_7745c5c3_Err = OrganizationSwitcher(app.Organizations, app.Organization.OrganizationID).Render(ctx, templ_7745c5c3_Buffer)
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "</section></nav>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -67,20 +115,20 @@ func base(headOpts ...components.HeadDataOption) templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "<footer class=\"bg-background mt-32\"><div class=\"container mx-auto px-4 lg:px-24\"><div class=\"grid grid-cols-2 md:grid-cols-4 gap-8 md:gap-12 pb-12 pt-8\"><div><h3 class=\"font-semibold text-base-content mb-4\">Work</h3><ul class=\"space-y-3 text-sm\"><li><a href=\"#\" class=\"text-base-content/60 hover:text-base-content transition-colors\">Case Studies</a></li><li><a href=\"#\" class=\"text-base-content/60 hover:text-base-content transition-colors\">Projects</a></li><li><a href=\"#\" class=\"text-base-content/60 hover:text-base-content transition-colors\">Clients</a></li></ul></div><div><h3 class=\"font-semibold text-base-content mb-4\">Services</h3><ul class=\"space-y-3 text-sm\"><li><a href=\"#\" class=\"text-base-content/60 hover:text-base-content transition-colors\">Go Development</a></li><li><a href=\"#\" class=\"text-base-content/60 hover:text-base-content transition-colors\">AI Integration</a></li><li><a href=\"#\" class=\"text-base-content/60 hover:text-base-content transition-colors\">Andurel Framework</a></li><li><a href=\"#\" class=\"text-base-content/60 hover:text-base-content transition-colors\">Custom Solutions</a></li></ul></div><div><h3 class=\"font-semibold text-base-content mb-4\">Connect</h3><ul class=\"space-y-3 text-sm\"><li><a href=\"https://linkedin.com/in/mortenvistisen\" target=\"_blank\" rel=\"noopener noreferrer\" class=\"text-base-content/60 hover:text-base-content transition-colors\">LinkedIn</a></li><li><a href=\"https://github.com/mbvlabs\" target=\"_blank\" rel=\"noopener noreferrer\" class=\"text-base-content/60 hover:text-base-content transition-colors\">GitHub</a></li><li><a href=\"https://twitter.com/mbvisti\" target=\"_blank\" rel=\"noopener noreferrer\" class=\"text-base-content/60 hover:text-base-content transition-colors\">Twitter/X</a></li><li><a href=\"mailto:hello@mbvlabs.com\" class=\"text-base-content/60 hover:text-base-content transition-colors\">Email</a></li></ul></div><div><h3 class=\"font-semibold text-base-content mb-4\">Company</h3><ul class=\"space-y-3 text-sm\"><li><a href=\"#\" class=\"text-base-content/60 hover:text-base-content transition-colors\">About</a></li><li><a href=\"#\" class=\"text-base-content/60 hover:text-base-content transition-colors\">Blog</a></li><li><a href=\"#\" class=\"text-base-content/60 hover:text-base-content transition-colors\">Philosophy</a></li><li><a href=\"#\" class=\"text-base-content/60 hover:text-base-content transition-colors\">Contact</a></li></ul></div></div><div class=\"border-t border-base-300 py-6\"><div class=\"flex flex-col md:flex-row justify-between items-center gap-4\"><p class=\"text-sm text-base-content/50\">&copy; ")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, "<footer class=\"bg-background mt-32\"><div class=\"container mx-auto px-4 lg:px-24\"><div class=\"grid grid-cols-2 md:grid-cols-4 gap-8 md:gap-12 pb-12 pt-8\"><div><h3 class=\"font-semibold text-base-content mb-4\">Work</h3><ul class=\"space-y-3 text-sm\"><li><a href=\"#\" class=\"text-base-content/60 hover:text-base-content transition-colors\">Case Studies</a></li><li><a href=\"#\" class=\"text-base-content/60 hover:text-base-content transition-colors\">Projects</a></li><li><a href=\"#\" class=\"text-base-content/60 hover:text-base-content transition-colors\">Clients</a></li></ul></div><div><h3 class=\"font-semibold text-base-content mb-4\">Services</h3><ul class=\"space-y-3 text-sm\"><li><a href=\"#\" class=\"text-base-content/60 hover:text-base-content transition-colors\">Go Development</a></li><li><a href=\"#\" class=\"text-base-content/60 hover:text-base-content transition-colors\">AI Integration</a></li><li><a href=\"#\" class=\"text-base-content/60 hover:text-base-content transition-colors\">Andurel Framework</a></li><li><a href=\"#\" class=\"text-base-content/60 hover:text-base-content transition-colors\">Custom Solutions</a></li></ul></div><div><h3 class=\"font-semibold text-base-content mb-4\">Connect</h3><ul class=\"space-y-3 text-sm\"><li><a href=\"https://linkedin.com/in/mortenvistisen\" target=\"_blank\" rel=\"noopener noreferrer\" class=\"text-base-content/60 hover:text-base-content transition-colors\">LinkedIn</a></li><li><a href=\"https://github.com/mbvlabs\" target=\"_blank\" rel=\"noopener noreferrer\" class=\"text-base-content/60 hover:text-base-content transition-colors\">GitHub</a></li><li><a href=\"https://twitter.com/mbvisti\" target=\"_blank\" rel=\"noopener noreferrer\" class=\"text-base-content/60 hover:text-base-content transition-colors\">Twitter/X</a></li><li><a href=\"mailto:hello@mbvlabs.com\" class=\"text-base-content/60 hover:text-base-content transition-colors\">Email</a></li></ul></div><div><h3 class=\"font-semibold text-base-content mb-4\">Company</h3><ul class=\"space-y-3 text-sm\"><li><a href=\"#\" class=\"text-base-content/60 hover:text-base-content transition-colors\">About</a></li><li><a href=\"#\" class=\"text-base-content/60 hover:text-base-content transition-colors\">Blog</a></li><li><a href=\"#\" class=\"text-base-content/60 hover:text-base-content transition-colors\">Philosophy</a></li><li><a href=\"#\" class=\"text-base-content/60 hover:text-base-content transition-colors\">Contact</a></li></ul></div></div><div class=\"border-t border-base-300 py-6\"><div class=\"flex flex-col md:flex-row justify-between items-center gap-4\"><p class=\"text-sm text-base-content/50\">&copy; ")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var3 string
-		templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(time.Now().Format("2006"))
+		var templ_7745c5c3_Var5 string
+		templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(time.Now().Format("2006"))
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `views/layout.templ`, Line: 203, Col: 42}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `views/layout.templ`, Line: 216, Col: 42}
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, " mbv labs. All rights reserved.</p><div class=\"flex gap-6 text-sm\"><a href=\"#\" class=\"text-base-content/50 hover:text-base-content transition-colors\">Privacy Policy</a> <a href=\"#\" class=\"text-base-content/50 hover:text-base-content transition-colors\">Terms of Service</a></div></div></div></div></footer></body><div id=\"flashContainer\" class=\"fixed top-4 right-4 z-50 space-y-2\">")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 10, " mbv labs. All rights reserved.</p><div class=\"flex gap-6 text-sm\"><a href=\"#\" class=\"text-base-content/50 hover:text-base-content transition-colors\">Privacy Policy</a> <a href=\"#\" class=\"text-base-content/50 hover:text-base-content transition-colors\">Terms of Service</a></div></div></div></div></footer></body><div id=\"flashContainer\" class=\"fixed top-4 right-4 z-50 space-y-2\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -90,17 +138,17 @@ func base(headOpts ...components.HeadDataOption) templ.Component {
 				return templ_7745c5c3_Err
 			}
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "</div>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 11, "</div>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
 		if config.Env == server.ProdEnvironment {
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "<script defer src=\"https://analytics.mbvlabs.com/script.js\" data-website-id=\"9b0def08-0deb-47cd-ac4a-413d2230db6e\"></script>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 12, "<script defer src=\"https://analytics.mbvlabs.com/script.js\" data-website-id=\"9b0def08-0deb-47cd-ac4a-413d2230db6e\"></script>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "<script type=\"text/javascript\">\n  (function (C, A, L) { let p = function (a, ar) { a.q.push(ar); }; let d = C.document; C.Cal = C.Cal || function () { let cal = C.Cal; let ar = arguments; if (!cal.loaded) { cal.ns = {}; cal.q = cal.q || []; d.head.appendChild(d.createElement(\"script\")).src = A; cal.loaded = true; } if (ar[0] === L) { const api = function () { p(api, arguments); }; const namespace = ar[1]; api.q = api.q || []; if(typeof namespace === \"string\"){cal.ns[namespace] = cal.ns[namespace] || api;p(cal.ns[namespace], ar);p(cal, [\"initNamespace\", namespace]);} else p(cal, ar); return;} p(cal, ar); }; })(window, \"https://app.cal.com/embed/embed.js\", \"init\");\nCal(\"init\", \"footer-cta\", {origin:\"https://app.cal.com\"});\nCal.ns[\"footer-cta\"](\"ui\", {\"hideEventTypeDetails\":false,\"layout\":\"month_view\"});\n  </script></html>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 13, "<script type=\"text/javascript\">\n  (function (C, A, L) { let p = function (a, ar) { a.q.push(ar); }; let d = C.document; C.Cal = C.Cal || function () { let cal = C.Cal; let ar = arguments; if (!cal.loaded) { cal.ns = {}; cal.q = cal.q || []; d.head.appendChild(d.createElement(\"script\")).src = A; cal.loaded = true; } if (ar[0] === L) { const api = function () { p(api, arguments); }; const namespace = ar[1]; api.q = api.q || []; if(typeof namespace === \"string\"){cal.ns[namespace] = cal.ns[namespace] || api;p(cal.ns[namespace], ar);p(cal, [\"initNamespace\", namespace]);} else p(cal, ar); return;} p(cal, ar); }; })(window, \"https://app.cal.com/embed/embed.js\", \"init\");\nCal(\"init\", \"footer-cta\", {origin:\"https://app.cal.com\"});\nCal.ns[\"footer-cta\"](\"ui\", {\"hideEventTypeDetails\":false,\"layout\":\"month_view\"});\n  </script></html>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -61,7 +61,15 @@ func RegistrationForm() templ.Component {
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "\"><div><label for=\"email\">Email</label> <input type=\"email\" id=\"email\" data-bind=\"email\" data-attr:disabled=\"$submitting\" required></div><div><label for=\"password\">Password</label> <input type=\"password\" id=\"password\" data-bind=\"password\" data-attr:disabled=\"$submitting\" required minlength=\"8\"></div><div><label for=\"confirmPassword\">Confirm Password</label> <input type=\"password\" id=\"confirmPassword\" data-bind=\"confirmPassword\" data-attr:disabled=\"$submitting\" required minlength=\"8\"></div>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "\"><div><label for=\"email\">Email</label> <input type=\"email\" id=\"email\" data-bind=\"email\" data-attr:disabled=\"$submitting\" required></div>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = components.NewPasswordField("Password").Render(ctx, templ_7745c5c3_Buffer)
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<div><label for=\"confirmPassword\">Confirm Password</label> <input type=\"password\" id=\"confirmPassword\" data-bind=\"confirmPassword\" data-attr:disabled=\"$submitting\" autocomplete=\"new-password\" required></div>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
@@ -69,7 +77,7 @@ func RegistrationForm() templ.Component {
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "</form></main>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "</form></main>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}